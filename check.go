@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// runCheck reports which experiments work on the host, each action is ready, degraded or unavailable
+// with the reasons, for example: chaos_os --check
+func runCheck(args []string) *spec.Response {
+	cmd := flag.NewFlagSet(os.Args[0]+" --check", flag.ContinueOnError)
+	cmd.SetOutput(io.Discard)
+	debugValue := cmd.String("debug", "", "debug")
+	if err := cmd.Parse(args); err != nil {
+		return spec.ReturnFail(spec.ParameterIllegal, fmt.Sprintf("invalid parameter, %v", err))
+	}
+	if *debugValue == spec.True {
+		util.Debug = true
+	}
+	util.InitLog(util.Bin)
+	return spec.ReturnSuccess(exec.CheckReadiness(context.Background(), channel.NewLocalChannel(), models))
+}
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"sort"
 
 	"github.com/chaosblade-io/chaosblade-exec-os/version"
 )
@@ -49,4 +50,13 @@ func main() {
 	fmt.Printf("Platform:    %s\n", info.Platform)
 	fmt.Printf("Architecture: %s\n", info.Architecture)
 	fmt.Printf("Is Release:  %t\n", version.IsRelease())
+	fmt.Printf("Min Spec:    %s\n", info.Requirements.MinSpecVersion)
+	features := make([]string, 0, len(info.Requirements.Features))
+	for feature := range info.Requirements.Features {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	for _, feature := range features {
+		fmt.Printf("Feature %s: %t\n", feature, info.Requirements.HasFeature(feature))
+	}
 }
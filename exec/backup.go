@@ -0,0 +1,347 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// BackupSuffix is in the canonical name of the backup, <path>.chaos-blade-backup-<uid>, which is the name
+// of the backups created by the file append of the old versions too
+const BackupSuffix = ".chaos-blade-backup-"
+
+// legacyBackupFormats are the names of the backups created by the old versions, which are formatted by the
+// path and the uid, the dns experiment backed up the hosts file to <hosts>-<uid>
+var legacyBackupFormats = []string{"%s-%s"}
+
+// BackupEntry is the record of the backup in the state of the experiment, see Backup
+type BackupEntry struct {
+	Path string `json:"path"`
+	// Backup is the copy of the file, it's empty if only the mode is recorded, see BackupMode
+	Backup   string `json:"backup,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+	Mode     string `json:"mode,omitempty"`
+	// Identity is the device and the inode of the file whose mode is recorded, the mode isn't restored to
+	// the file replaced since, such as by a deploy, see FileIdentity
+	Identity string `json:"identity,omitempty"`
+	Restored bool   `json:"restored,omitempty"`
+	// Note explains why the entry is restored without changing the file
+	Note string `json:"note,omitempty"`
+}
+
+// GetBackupFile returns the canonical backup of the file for the experiment
+func GetBackupFile(path, uid string) string {
+	return path + BackupSuffix + uid
+}
+
+// Backup copies the file to its canonical backup and records it with the checksum in the state of the
+// experiment, the record is created if the experiment has none. Backing up the file again returns the
+// recorded backup, so the original content is never overwritten by the changed one.
+func Backup(ctx context.Context, cl spec.Channel, path, uid string) (*BackupEntry, *spec.Response) {
+	state := loadBackupState(ctx, uid)
+	if entry := state.findBackup(path); entry != nil && entry.Backup != "" && !entry.Restored {
+		return entry, nil
+	}
+	backup := GetBackupFile(path, uid)
+	if response := copyBackupFile(ctx, cl, path, backup); !response.Success {
+		log.Errorf(ctx, "back up %s failed, %s", path, response.Err)
+		return nil, WithFailure(response, BackupFailed, "backup", "copy "+path)
+	}
+	checksum, err := checksumBackupFile(ctx, cl, backup)
+	if err != nil {
+		// the backup is still restored without the checksum, such as on the hosts without sha256sum
+		log.Warnf(ctx, "get the checksum of %s failed, it's not verified on restore, %v", backup, err)
+	}
+	entry := &BackupEntry{Path: path, Backup: backup, Checksum: checksum}
+	if response := state.addBackup(ctx, entry); response != nil {
+		removeBackupFile(ctx, cl, backup)
+		return nil, response
+	}
+	log.Infof(ctx, "%s is backed up to %s", path, backup)
+	return entry, nil
+}
+
+// BackupMode records the permission bits of the file instead of copying it, they are restored by Restore too.
+// The identity of the file is optional, the mode is restored to the file of the same identity only.
+func BackupMode(ctx context.Context, uid, path, mode, identity string) (*BackupEntry, *spec.Response) {
+	state := loadBackupState(ctx, uid)
+	if entry := state.findBackup(path); entry != nil && !entry.Restored {
+		return entry, nil
+	}
+	entry := &BackupEntry{Path: path, Mode: mode, Identity: identity}
+	if response := state.addBackup(ctx, entry); response != nil {
+		return nil, response
+	}
+	return entry, nil
+}
+
+// FindBackup returns the recorded backup of the file, or the existing backup of the canonical and the legacy
+// names, which is created by the old versions without the record
+func FindBackup(ctx context.Context, cl spec.Channel, uid, path string) (*BackupEntry, bool) {
+	state := loadBackupState(ctx, uid)
+	if entry := state.findBackup(path); entry != nil {
+		return entry, true
+	}
+	candidates := []string{GetBackupFile(path, uid)}
+	for _, format := range legacyBackupFormats {
+		candidates = append(candidates, fmt.Sprintf(format, path, uid))
+	}
+	for _, backup := range candidates {
+		if backupFileExists(ctx, cl, backup) {
+			return &BackupEntry{Path: path, Backup: backup}, true
+		}
+	}
+	return nil, false
+}
+
+// BackupOwner returns the uid of the experiment which has backed up the file and not restored it yet. The file
+// is matched by the path, or by the identity if it's not empty, such as the hard link of the recorded file.
+func BackupOwner(path, identity string) string {
+	states, err := ListStates()
+	if err != nil {
+		return ""
+	}
+	for _, state := range states {
+		if state.Destroyed {
+			continue
+		}
+		for _, entry := range state.Backups {
+			if !entry.Restored && (entry.Path == path || identity != "" && entry.Identity == identity) {
+				return state.Uid
+			}
+		}
+	}
+	return ""
+}
+
+// Restore restores the file from its backup after verifying the checksum, then removes the backup. It returns
+// false if the file has no backup. Restoring the file again succeeds without changing it.
+func Restore(ctx context.Context, cl spec.Channel, uid, path string) (*spec.Response, bool) {
+	entry, ok := FindBackup(ctx, cl, uid, path)
+	if !ok {
+		return nil, false
+	}
+	response := restoreBackup(ctx, cl, entry)
+	if response.Success {
+		markRestored(ctx, uid, path)
+	}
+	return response, true
+}
+
+// RestoreTo restores the content of the file from its backup to the other path after verifying the checksum,
+// such as for the manual review when the file is replaced since the backup. The backup is removed then, and the
+// file is recorded as restored. It returns false if the file has no backup of the content.
+func RestoreTo(ctx context.Context, cl spec.Channel, uid, path, target string) (*spec.Response, bool) {
+	entry, ok := FindBackup(ctx, cl, uid, path)
+	if !ok || entry.Backup == "" {
+		return nil, false
+	}
+	aside := *entry
+	aside.Path, aside.Mode = target, ""
+	response := restoreBackup(ctx, cl, &aside)
+	if response.Success {
+		markRestored(ctx, uid, path)
+	}
+	return response, true
+}
+
+// markRestored records the backup of the file as restored
+func markRestored(ctx context.Context, uid, path string) {
+	state := loadBackupState(ctx, uid)
+	if recorded := state.findBackup(path); recorded != nil {
+		recorded.Restored = true
+		state.saveBackups(ctx)
+	}
+}
+
+// RemoveBackup removes the backup of the file without restoring it, the record is kept as restored
+func RemoveBackup(ctx context.Context, cl spec.Channel, uid, path string) *spec.Response {
+	entry, ok := FindBackup(ctx, cl, uid, path)
+	if !ok || entry.Restored {
+		return spec.ReturnSuccess(path)
+	}
+	if entry.Backup != "" {
+		if response := removeBackupFile(ctx, cl, entry.Backup); !response.Success {
+			return WithFailure(response, CommandFailed, "backup", "remove "+entry.Backup)
+		}
+	}
+	markRestored(ctx, uid, path)
+	return spec.ReturnSuccess(path)
+}
+
+// restoreBackup restores the content and the mode of the entry, the entry restored is skipped
+func restoreBackup(ctx context.Context, cl spec.Channel, entry *BackupEntry) *spec.Response {
+	if entry.Restored {
+		log.Infof(ctx, "%s has been restored", entry.Path)
+		return spec.ReturnSuccess(entry.Path)
+	}
+	if entry.Backup != "" {
+		if !backupFileExists(ctx, cl, entry.Backup) {
+			log.Errorf(ctx, "the backup %s of %s is not found", entry.Backup, entry.Path)
+			return Fail(RestoreFailed, "backup", "restore "+entry.Path,
+				fmt.Sprintf("the backup %s of %s is not found", entry.Backup, entry.Path))
+		}
+		if entry.Checksum != "" {
+			checksum, err := checksumBackupFile(ctx, cl, entry.Backup)
+			if err == nil && checksum != entry.Checksum {
+				log.Errorf(ctx, "the checksum of the backup %s mismatches, it may be tampered or truncated", entry.Backup)
+				return Fail(RestoreFailed, "backup", "restore "+entry.Path,
+					fmt.Sprintf("the checksum of the backup %s mismatches, it may be tampered or truncated", entry.Backup))
+			}
+		}
+		if response := copyBackupFile(ctx, cl, entry.Backup, entry.Path); !response.Success {
+			log.Errorf(ctx, "restore %s from %s failed, %s", entry.Path, entry.Backup, response.Err)
+			return WithFailure(response, RestoreFailed, "backup", "restore "+entry.Path)
+		}
+	}
+	if entry.Mode != "" && entry.Identity != "" {
+		// the file replaced has the mode of its own, applying the stale one may break it
+		if identity, err := FileIdentity(ctx, cl, entry.Path); err != nil || identity != entry.Identity {
+			entry.Note = fmt.Sprintf("the mode %s isn't restored, %s is replaced or removed since the experiment", entry.Mode, entry.Path)
+			log.Warnf(ctx, "%s", entry.Note)
+			entry.Restored = true
+			return spec.ReturnSuccess(entry.Note)
+		}
+	}
+	if entry.Mode != "" {
+		if response := chmodBackupFile(ctx, cl, entry.Path, entry.Mode); !response.Success {
+			log.Errorf(ctx, "restore the mode %s of %s failed, %s", entry.Mode, entry.Path, response.Err)
+			return WithFailure(response, RestoreFailed, "backup", "chmod "+entry.Path)
+		}
+	}
+	if entry.Backup != "" {
+		if response := removeBackupFile(ctx, cl, entry.Backup); !response.Success {
+			log.Warnf(ctx, "remove the backup %s failed, %s", entry.Backup, response.Err)
+		}
+	}
+	entry.Restored = true
+	log.Infof(ctx, "%s is restored", entry.Path)
+	return spec.ReturnSuccess(entry.Path)
+}
+
+// FileChecksum returns the sha256 of the file in hex
+func FileChecksum(ctx context.Context, cl spec.Channel, path string) (string, error) {
+	return checksumBackupFile(ctx, cl, path)
+}
+
+// BackupChanges returns the files backed up by the experiment and not restored yet, with the current checksums
+// of the files and whether the backups still verify. The entries only of the mode are left to the executors.
+func BackupChanges(ctx context.Context, cl spec.Channel, state *ExperimentState) []FileChange {
+	changes := make([]FileChange, 0)
+	for _, entry := range state.Backups {
+		if entry.Restored || entry.Backup == "" {
+			continue
+		}
+		change := FileChange{Path: entry.Path, Change: "backup", Backup: entry.Backup}
+		if change.Exists = CheckFilepathExists(ctx, cl, entry.Path); change.Exists {
+			change.Checksum, _ = checksumBackupFile(ctx, cl, entry.Path)
+		} else {
+			change.Divergence = "the file is deleted since the backup"
+		}
+		if !backupFileExists(ctx, cl, entry.Backup) {
+			change.Divergence = fmt.Sprintf("the backup %s is missing", entry.Backup)
+			changes = append(changes, change)
+			continue
+		}
+		// the backup recorded without the checksum is verified by its existence only
+		if entry.Checksum == "" {
+			change.BackupVerified = true
+		} else if checksum, err := checksumBackupFile(ctx, cl, entry.Backup); err != nil {
+			change.Divergence = fmt.Sprintf("the checksum of the backup %s failed, %v", entry.Backup, err)
+		} else if checksum != entry.Checksum {
+			change.Divergence = fmt.Sprintf("the checksum of the backup %s mismatches", entry.Backup)
+		} else {
+			change.BackupVerified = true
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// SetBackupDetail records the detail of the experiment like the backups, the record is created for the detail
+// if there is none, such as the file append whose changes are recorded without NewExperimentState
+func SetBackupDetail(ctx context.Context, uid, key, value string) *spec.Response {
+	state := loadBackupState(ctx, uid)
+	if state.Target == "" {
+		state.Destroyed = false
+	}
+	if err := state.SetDetail(key, value); err != nil {
+		log.Errorf(ctx, "record the %s of the experiment %s failed, %v", key, uid, err)
+		return Fail(StateRecordFailed, "backup", "record "+key, fmt.Sprintf("record the %s failed, %v", key, err))
+	}
+	return nil
+}
+
+// loadBackupState returns the record of the experiment, or a new one for the backups only
+func loadBackupState(ctx context.Context, uid string) *ExperimentState {
+	state, err := LoadState(uid)
+	if err != nil {
+		state = &ExperimentState{Uid: uid, Undo: make([]UndoCommand, 0), CreateTime: time.Now()}
+	}
+	state.dryRun = IsDryRun(ctx)
+	return state
+}
+
+func (s *ExperimentState) findBackup(path string) *BackupEntry {
+	for i := range s.Backups {
+		if s.Backups[i].Path == path {
+			return &s.Backups[i]
+		}
+	}
+	return nil
+}
+
+func (s *ExperimentState) addBackup(ctx context.Context, entry *BackupEntry) *spec.Response {
+	if recorded := s.findBackup(entry.Path); recorded != nil {
+		*recorded = *entry
+	} else {
+		s.Backups = append(s.Backups, *entry)
+	}
+	// the record only for the backups is reopened by the new backup
+	if s.Target == "" {
+		s.Destroyed = false
+	}
+	if err := s.Save(); err != nil {
+		log.Errorf(ctx, "record the backup of %s failed, %v", entry.Path, err)
+		return Fail(StateRecordFailed, "backup", "record "+entry.Path, fmt.Sprintf("record the backup of %s failed, %v", entry.Path, err))
+	}
+	return nil
+}
+
+// saveBackups saves the restored backups, the record only for the backups is destroyed if all are restored
+func (s *ExperimentState) saveBackups(ctx context.Context) {
+	if s.Target == "" {
+		s.setDestroyed(s.allBackupsRestored())
+	}
+	if err := s.Save(); err != nil {
+		log.Warnf(ctx, "save the record of the experiment %s failed, %v", s.Uid, err)
+	}
+}
+
+func (s *ExperimentState) allBackupsRestored() bool {
+	for _, entry := range s.Backups {
+		if !entry.Restored {
+			return false
+		}
+	}
+	return true
+}
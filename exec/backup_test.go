@@ -0,0 +1,106 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := NewMockChannel().OnRun("sha256sum", "", spec.ReturnSuccess("9f86d081  /data/app.conf.chaos-blade-backup-uid\n"))
+
+	entry, response := Backup(ctx, cl, "/data/app.conf", "uid")
+	if response != nil || entry.Backup != "/data/app.conf.chaos-blade-backup-uid" || entry.Checksum != "9f86d081" {
+		t.Fatalf("unexpected backup %+v, %+v", entry, response)
+	}
+	// the second backup returns the recorded one instead of overwriting it by the changed file
+	cl.Reset()
+	if _, response := Backup(ctx, cl, "/data/app.conf", "uid"); response != nil || len(cl.Commands()) != 0 {
+		t.Fatalf("expected the recorded backup, %q", cl.CommandLines())
+	}
+	if owner := BackupOwner("/data/app.conf", ""); owner != "uid" {
+		t.Errorf("expected the owner uid, got %s", owner)
+	}
+
+	if response, ok := Restore(ctx, cl, "uid", "/data/app.conf"); !ok || !response.Success {
+		t.Fatalf("unexpected restore, %+v", response)
+	}
+	expected := []string{
+		"test -e /data/app.conf.chaos-blade-backup-uid",
+		"sha256sum -- /data/app.conf.chaos-blade-backup-uid",
+		"cp -p -- /data/app.conf.chaos-blade-backup-uid /data/app.conf",
+		"rm -f -- /data/app.conf.chaos-blade-backup-uid",
+	}
+	if actual := cl.CommandLines(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected commands %q", actual)
+	}
+	// restoring it again changes nothing
+	cl.Reset()
+	if response, ok := Restore(ctx, cl, "uid", "/data/app.conf"); !ok || !response.Success || len(cl.Commands()) != 0 {
+		t.Errorf("expected the idempotent restore, %+v, %q", response, cl.CommandLines())
+	}
+	if state, err := LoadState("uid"); err != nil || !state.Destroyed {
+		t.Errorf("expected the record only for the backups destroyed, %+v, %v", state, err)
+	}
+	if owner := BackupOwner("/data/app.conf", ""); owner != "" {
+		t.Errorf("expected no owner after the restore, got %s", owner)
+	}
+}
+
+func TestBackupModeByDestroyByState(t *testing.T) {
+	StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := NewMockChannel()
+	state, response := NewExperimentState(ctx, "uid", "file", "chmod", nil)
+	if response != nil {
+		t.Fatal(response.Err)
+	}
+	if err := state.AddUndo("rm", "-f -- /tmp/marker"); err != nil {
+		t.Fatal(err)
+	}
+	if _, response := BackupMode(ctx, "uid", "/data/app.log", "644", ""); response != nil {
+		t.Fatal(response.Err)
+	}
+
+	// the backups are restored after the undo commands
+	if response, ok := DestroyByState(ctx, cl, "uid"); !ok || !response.Success {
+		t.Fatalf("unexpected destroy, %+v", response)
+	}
+	expected := []string{"rm -f -- /tmp/marker", "chmod 644 -- /data/app.log"}
+	if actual := cl.CommandLines(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected commands %q", actual)
+	}
+}
+
+func TestRestoreWithoutBackup(t *testing.T) {
+	StateDir = t.TempDir()
+	cl := NewMockChannel().OnRun("test", "", spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+	if response, ok := Restore(context.Background(), cl, "uid", "/data/app.conf"); ok {
+		t.Errorf("expected no backup, %+v", response)
+	}
+	if response := RemoveBackup(context.Background(), cl, "uid", "/data/app.conf"); !response.Success {
+		t.Errorf("expected removing the absent backup succeeds, %s", response.Err)
+	}
+}
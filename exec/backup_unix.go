@@ -0,0 +1,75 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// the backups are handled by the commands run by the channel, so that they work in the namespaces of the containers
+
+// copyBackupFile copies the file by cp, which overwrites the existing target in place, so that the file mounted
+// by the container, such as the hosts file, is restored too
+func copyBackupFile(ctx context.Context, cl spec.Channel, source, target string) *spec.Response {
+	return RunArgv(ctx, cl, "cp", "-p", "--", source, target)
+}
+
+func checksumBackupFile(ctx context.Context, cl spec.Channel, path string) (string, error) {
+	response := RunReadOnlyArgv(ctx, cl, "sha256sum", "--", path)
+	if !response.Success {
+		return "", fmt.Errorf("%s", response.Err)
+	}
+	fields := strings.Fields(fmt.Sprint(response.Result))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("the output of sha256sum is empty")
+	}
+	return fields[0], nil
+}
+
+func backupFileExists(ctx context.Context, cl spec.Channel, path string) bool {
+	return CheckFilepathExists(ctx, cl, path)
+}
+
+func removeBackupFile(ctx context.Context, cl spec.Channel, path string) *spec.Response {
+	return RunArgv(ctx, cl, "rm", "-f", "--", path)
+}
+
+func chmodBackupFile(ctx context.Context, cl spec.Channel, path, mode string) *spec.Response {
+	return RunArgv(ctx, cl, "chmod", mode, "--", path)
+}
+
+// FileIdentity returns the device and the inode of the file as dev:ino, the BSD stat is tried if the GNU one fails
+func FileIdentity(ctx context.Context, cl spec.Channel, path string) (string, error) {
+	response := RunReadOnlyArgv(ctx, cl, "stat", "-L", "-c", "%d:%i", "--", path)
+	if !response.Success {
+		response = RunReadOnlyArgv(ctx, cl, "stat", "-L", "-f", "%d:%i", "--", path)
+	}
+	if !response.Success {
+		return "", fmt.Errorf("%s", response.Err)
+	}
+	identity := strings.TrimSpace(fmt.Sprint(response.Result))
+	if identity == "" {
+		return "", fmt.Errorf("the output of stat is empty")
+	}
+	return identity, nil
+}
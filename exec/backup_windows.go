@@ -0,0 +1,89 @@
+//go:build windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// there is no shell command to copy the files on Windows, the backups are handled by the native file operations
+
+func copyBackupFile(ctx context.Context, cl spec.Channel, source, target string) *spec.Response {
+	info, err := os.Stat(source)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "copy "+source, err)
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "copy "+source, err)
+	}
+	if err := os.WriteFile(target, data, info.Mode().Perm()); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "copy "+source, err)
+	}
+	return spec.ReturnSuccess(target)
+}
+
+func checksumBackupFile(ctx context.Context, cl spec.Channel, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func backupFileExists(ctx context.Context, cl spec.Channel, path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func removeBackupFile(ctx context.Context, cl spec.Channel, path string) *spec.Response {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove "+path, err)
+	}
+	return spec.ReturnSuccess(path)
+}
+
+func chmodBackupFile(ctx context.Context, cl spec.Channel, path, mode string) *spec.Response {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "chmod "+path, err)
+	}
+	if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "chmod "+path, err)
+	}
+	return spec.ReturnSuccess(path)
+}
+
+// FileIdentity returns the empty identity, the file index isn't exposed by os.Stat on Windows, so the replaced
+// file isn't detected
+func FileIdentity(ctx context.Context, cl spec.Channel, path string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// the arguments only containing these characters are not quoted, so the commands in the logs and the
+// dry-run plans are still readable
+var shellSafeArg = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// ShellQuote quotes the argument for the POSIX shell, so that it's passed as the literal data
+func ShellQuote(arg string) string {
+	if shellSafeArg.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// ShellJoin quotes and joins the arguments for the POSIX shell
+func ShellJoin(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = ShellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// RunArgv runs the command with the explicit arguments. The local channel runs it without the shell, so
+// the arguments are never interpreted. The other channels, such as the nsexec channel which enters the
+// namespaces of the container by the shell, and the DryRunChannel, get the quoted arguments.
+func RunArgv(ctx context.Context, cl spec.Channel, name string, args ...string) *spec.Response {
+	if _, ok := cl.(*channel.LocalChannel); ok {
+		return runLocalArgv(ctx, name, args)
+	}
+	return cl.Run(ctx, name, ShellJoin(args...))
+}
+
+// RunReadOnlyArgv is RunArgv of the command which doesn't change the system, see RunReadOnly
+func RunReadOnlyArgv(ctx context.Context, cl spec.Channel, name string, args ...string) *spec.Response {
+	if d, ok := cl.(*DryRunChannel); ok {
+		return RunArgv(ctx, d.Channel, name, args...)
+	}
+	return RunArgv(ctx, cl, name, args...)
+}
+
+// runLocalArgv is the same as the local channel, except that the command is run without the shell
+func runLocalArgv(ctx context.Context, name string, args []string) *spec.Response {
+	if ctx == context.Background() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 60*time.Second)
+		defer cancel()
+	}
+	command := name + " " + ShellJoin(args...)
+	log.Debugf(ctx, "Command: %s", command)
+	output, err := osexec.CommandContext(ctx, name, args...).CombinedOutput()
+	log.Debugf(ctx, "Command Result, output: %s, err: %v", output, err)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, command, fmt.Sprintf("%s %v", output, err))
+	}
+	return spec.ReturnSuccess(string(output))
+}
+
+// AppendFile appends the content to the file, and creates the file if it doesn't exist. The local channel
+// writes the file by Go, the other channels write it by printf and the redirection of the shell.
+func AppendFile(ctx context.Context, cl spec.Channel, file, content string) *spec.Response {
+	return writeFile(ctx, cl, file, content, os.O_APPEND, ">>", false)
+}
+
+// SyncAppendFile is AppendFile which returns after the content reaches the disk rather than the page cache.
+// The local channel calls fsync on the file, the other channels run sync on the file after the redirection.
+func SyncAppendFile(ctx context.Context, cl spec.Channel, file, content string) *spec.Response {
+	return writeFile(ctx, cl, file, content, os.O_APPEND, ">>", true)
+}
+
+// WriteFile overwrites the file with the content, see AppendFile
+func WriteFile(ctx context.Context, cl spec.Channel, file, content string) *spec.Response {
+	return writeFile(ctx, cl, file, content, os.O_TRUNC, ">", false)
+}
+
+func writeFile(ctx context.Context, cl spec.Channel, file, content string, flag int, redirection string, sync bool) *spec.Response {
+	if _, ok := cl.(*channel.LocalChannel); !ok {
+		args := fmt.Sprintf("%%s %s %s %s", ShellQuote(content), redirection, ShellQuote(file))
+		if sync {
+			args += " && sync -- " + ShellQuote(file)
+		}
+		return cl.Run(ctx, "printf", args)
+	}
+	f, err := os.OpenFile(file, flag|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Errorf(ctx, "open %s failed, %v", file, err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "write "+file, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		log.Errorf(ctx, "write %s failed, %v", file, err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "write "+file, err)
+	}
+	if sync {
+		if err := f.Sync(); err != nil {
+			log.Errorf(ctx, "sync %s failed, %v", file, err)
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "sync "+file, err)
+		}
+	}
+	return spec.ReturnSuccess(file)
+}
@@ -0,0 +1,159 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// shellChannel runs the commands by the shell like the nsexec channel, it's not the local channel
+type shellChannel struct {
+	spec.Channel
+}
+
+// hostileInputs returns the inputs which run the commands if they are interpreted by the shell,
+// every command creates the marker file
+func hostileInputs(marker string) []string {
+	return []string{
+		`"; touch ` + marker + `; "`,
+		`'; touch ` + marker + `; '`,
+		`$(touch ` + marker + `)`,
+		"`touch " + marker + "`",
+		`a && touch ` + marker,
+		`a | touch ` + marker,
+		`a > ` + marker,
+		"a\ntouch " + marker,
+		`it's a "quoted" \value\ with $HOME and *`,
+		"",
+	}
+}
+
+func assertNoMarker(t *testing.T, marker, input string) {
+	t.Helper()
+	if _, err := os.Stat(marker); err == nil {
+		t.Errorf("the input %q is interpreted by the shell", input)
+		_ = os.Remove(marker)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"/etc/hosts", "/etc/hosts"},
+		{"8000:8080,9000", "8000:8080,9000"},
+		{"", "''"},
+		{"a b", "'a b'"},
+		{"it's", `'it'\''s'`},
+		{"$(id)", "'$(id)'"},
+	}
+	for _, tt := range tests {
+		if got := ShellQuote(tt.arg); got != tt.want {
+			t.Errorf("ShellQuote(%q) = %s, want %s", tt.arg, got, tt.want)
+		}
+	}
+	if got := ShellJoin("-D", "INPUT", "--string", "a b"); got != "-D INPUT --string 'a b'" {
+		t.Errorf("ShellJoin() = %s", got)
+	}
+
+	marker := filepath.Join(t.TempDir(), "x")
+	cl := channel.NewLocalChannel()
+	for _, input := range hostileInputs(marker) {
+		response := cl.Run(context.Background(), "printf", "%s "+ShellQuote(input))
+		if !response.Success {
+			t.Errorf("printf %q failed, %s", input, response.Err)
+		} else if response.Result != input {
+			t.Errorf("the shell gets %q, want %q", response.Result, input)
+		}
+		assertNoMarker(t, marker, input)
+	}
+}
+
+func TestRunArgv(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "x")
+	for _, cl := range []spec.Channel{channel.NewLocalChannel(), &shellChannel{channel.NewLocalChannel()}} {
+		for _, input := range hostileInputs(marker) {
+			response := RunArgv(context.Background(), cl, "printf", "%s", input)
+			if !response.Success {
+				t.Errorf("%T: printf %q failed, %s", cl, input, response.Err)
+			} else if response.Result != input {
+				t.Errorf("%T: printf gets %q, want %q", cl, response.Result, input)
+			}
+			assertNoMarker(t, marker, input)
+		}
+	}
+	if response := RunArgv(context.Background(), channel.NewLocalChannel(), "false"); response.Success {
+		t.Errorf("RunArgv(false) succeeds")
+	}
+}
+
+func TestAppendFile(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "x")
+	for _, cl := range []spec.Channel{channel.NewLocalChannel(), &shellChannel{channel.NewLocalChannel()}} {
+		file := filepath.Join(dir, "it's a file; $(touch x)")
+		want := ""
+		for _, input := range hostileInputs(marker) {
+			if response := AppendFile(context.Background(), cl, file, input+"\n"); !response.Success {
+				t.Fatalf("%T: append %q failed, %s", cl, input, response.Err)
+			}
+			want += input + "\n"
+			assertNoMarker(t, marker, input)
+		}
+		bytes, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("%T: read the file failed, %v", cl, err)
+		}
+		if string(bytes) != want {
+			t.Errorf("%T: the file is %q, want %q", cl, bytes, want)
+		}
+
+		if response := WriteFile(context.Background(), cl, file, "$(touch "+marker+")"); !response.Success {
+			t.Fatalf("%T: write the file failed, %s", cl, response.Err)
+		}
+		if bytes, _ := os.ReadFile(file); string(bytes) != "$(touch "+marker+")" {
+			t.Errorf("%T: the file is %q after overwriting", cl, bytes)
+		}
+		assertNoMarker(t, marker, "$(touch "+marker+")")
+		_ = os.Remove(file)
+	}
+}
+
+func TestSyncAppendFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, cl := range []spec.Channel{channel.NewLocalChannel(), &shellChannel{channel.NewLocalChannel()}} {
+		file := filepath.Join(dir, "it's a file; $(touch x)")
+		for i := 0; i < 2; i++ {
+			if response := SyncAppendFile(context.Background(), cl, file, "hello\n"); !response.Success {
+				t.Fatalf("%T: append failed, %s", cl, response.Err)
+			}
+		}
+		if bytes, _ := os.ReadFile(file); string(bytes) != "hello\nhello\n" {
+			t.Errorf("%T: the file is %q", cl, bytes)
+		}
+		_ = os.Remove(file)
+	}
+}
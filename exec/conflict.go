@@ -0,0 +1,148 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// AllowOverlapKey is the flag and the context key which skips the conflict check, the experiments
+// changing the same resource are stacked then
+const AllowOverlapKey = "allow-overlap"
+
+// ExecutorFactory returns a new executor of the target action, it's set by the main package which knows
+// all the executors. The conflict check verifies the recorded experiments by their status checkers.
+var ExecutorFactory func(target, action string) spec.Executor
+
+// WithAllowOverlap returns the context which skips the conflict check
+func WithAllowOverlap(ctx context.Context) context.Context {
+	return context.WithValue(ctx, AllowOverlapKey, spec.True)
+}
+
+// IsAllowOverlap returns true if the conflict check is skipped
+func IsAllowOverlap(ctx context.Context) bool {
+	value, ok := ctx.Value(AllowOverlapKey).(string)
+	return ok && value == spec.True
+}
+
+// InterfaceResource is the qdisc of the network interface
+func InterfaceResource(netInterface string) string {
+	return "interface " + netInterface
+}
+
+// FilesystemResource is the filesystem mounted on the mount point
+func FilesystemResource(mountPoint string) string {
+	return "filesystem " + mountPoint
+}
+
+// HostsResource is the hosts file, which is shared by all the processes
+func HostsResource(hosts string) string {
+	return "hosts file " + hosts
+}
+
+// ClockResource is the system clock
+const ClockResource = "system clock"
+
+// ClaimResources creates the record of the experiment with the resources which it changes exclusively,
+// such as the qdisc of the interface. It fails with the uid of the conflicting experiment if an effective
+// experiment has claimed one of them, unless the overlap is allowed.
+func ClaimResources(ctx context.Context, cl spec.Channel, uid, target, action string, flags map[string]string,
+	resources ...string) (*ExperimentState, *spec.Response) {
+	if !IsAllowOverlap(ctx) {
+		if response := CheckConflict(ctx, cl, uid, resources...); response != nil {
+			return nil, response
+		}
+	}
+	return newExperimentState(ctx, uid, target, action, flags, resources)
+}
+
+// ReleaseResources marks the record destroyed, it's invoked by the executors which destroy the experiment
+// by themselves instead of the undo commands, see DestroyByState
+func ReleaseResources(ctx context.Context, uid string) {
+	state, err := LoadState(uid)
+	if err != nil || state.Destroyed {
+		return
+	}
+	state.dryRun = IsDryRun(ctx)
+	state.setDestroyed(true)
+	if err := state.Save(); err != nil {
+		log.Warnf(ctx, "save the record of the experiment %s failed, %v", uid, err)
+	}
+}
+
+// CheckConflict returns the failure if an effective experiment other than the uid has claimed one of the
+// resources. The stale records, whose processes or rules are gone, are ignored.
+func CheckConflict(ctx context.Context, cl spec.Channel, uid string, resources ...string) *spec.Response {
+	states, err := ListStates()
+	if err != nil {
+		log.Warnf(ctx, "list the records of the experiments failed, %v", err)
+		return nil
+	}
+	for _, state := range states {
+		if state.Uid == uid || state.Destroyed {
+			continue
+		}
+		resource := overlappedResource(state.Resources, resources)
+		if resource == "" {
+			continue
+		}
+		if !isEffective(ctx, cl, state) {
+			log.Infof(ctx, "the experiment %s on %s is not effective, ignore it", state.Uid, resource)
+			continue
+		}
+		log.Errorf(ctx, "%s is changed by the experiment %s", resource, state.Uid)
+		return spec.ReturnFail(spec.OsCmdExecFailed,
+			fmt.Sprintf("%s is changed by the experiment %s (%s %s), destroy it first or use --%s to stack them",
+				resource, state.Uid, state.Target, state.Action, AllowOverlapKey))
+	}
+	return nil
+}
+
+func overlappedResource(claimed, resources []string) string {
+	for _, resource := range resources {
+		for _, c := range claimed {
+			if c == resource {
+				return resource
+			}
+		}
+	}
+	return ""
+}
+
+// isEffective verifies the recorded experiment by the status checker of its executor, the experiment
+// which can't be verified is assumed effective
+func isEffective(ctx context.Context, cl spec.Channel, state *ExperimentState) bool {
+	if ExecutorFactory == nil {
+		return true
+	}
+	executor := ExecutorFactory(state.Target, state.Action)
+	if executor == nil {
+		return true
+	}
+	executor.SetChannel(cl)
+	model := &spec.ExpModel{Target: state.Target, ActionName: state.Action, ActionFlags: state.Flags}
+	response := WithStatusPhase(executor).Exec(state.Uid, WithStatus(ctx), model)
+	report, ok := response.Result.(*StatusReport)
+	if !response.Success || !ok {
+		return true
+	}
+	return report.Effective
+}
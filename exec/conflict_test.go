@@ -0,0 +1,106 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// conflictExecutor reports the experiments in the effective set as effective
+type conflictExecutor struct {
+	effective map[string]bool
+	channel   spec.Channel
+}
+
+func (e *conflictExecutor) Name() string { return "delay" }
+
+func (e *conflictExecutor) SetChannel(channel spec.Channel) { e.channel = channel }
+
+func (e *conflictExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	return spec.ReturnSuccess(uid)
+}
+
+func (e *conflictExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *ExperimentState) *StatusReport {
+	report := NewStatusReport(uid, model, state)
+	report.Add(Artifact{Kind: "qdisc", Name: model.ActionFlags["interface"], Present: e.effective[uid]})
+	return report
+}
+
+func TestClaimResources(t *testing.T) {
+	StateDir = t.TempDir()
+	factory := ExecutorFactory
+	defer func() { ExecutorFactory = factory }()
+	executor := &conflictExecutor{effective: map[string]bool{}}
+	ExecutorFactory = func(target, action string) spec.Executor {
+		if target+action == "networkdelay" {
+			return executor
+		}
+		return nil
+	}
+	ctx := context.Background()
+	cl := channel.NewMockLocalChannel()
+	claim := func(ctx context.Context, uid, netInterface string) *spec.Response {
+		_, response := ClaimResources(ctx, cl, uid, "network", "delay", map[string]string{"interface": netInterface},
+			InterfaceResource(netInterface))
+		return response
+	}
+
+	if response := claim(ctx, "uid-a", "eth0"); response != nil {
+		t.Fatalf("claim the free interface failed, %s", response.Err)
+	}
+	executor.effective["uid-a"] = true
+	response := claim(ctx, "uid-b", "eth0")
+	if response == nil || !strings.Contains(response.Err, "uid-a") {
+		t.Fatalf("the conflict with uid-a is not reported, got %v", response)
+	}
+	if executor.channel != cl {
+		t.Errorf("the status checker doesn't get the channel")
+	}
+	if response := claim(ctx, "uid-c", "eth1"); response != nil {
+		t.Errorf("claim the other interface failed, %s", response.Err)
+	}
+	if response := claim(WithAllowOverlap(ctx), "uid-d", "eth0"); response != nil {
+		t.Errorf("the overlap is not allowed, %s", response.Err)
+	}
+	executor.effective["uid-d"] = true
+
+	// the stale record whose qdisc is gone doesn't block the new experiment
+	executor.effective["uid-a"] = false
+	if response := claim(ctx, "uid-b", "eth0"); response == nil || !strings.Contains(response.Err, "uid-d") {
+		t.Errorf("the conflict with uid-d is not reported, got %v", response)
+	}
+	ReleaseResources(ctx, "uid-d")
+	if response := claim(ctx, "uid-b", "eth0"); response != nil {
+		t.Errorf("the stale and the destroyed records block the experiment, %s", response.Err)
+	}
+	if state, err := LoadState("uid-b"); err != nil || len(state.Resources) != 1 || state.Resources[0] != "interface eth0" {
+		t.Errorf("the resources are not recorded, %v, %v", state, err)
+	}
+
+	// the experiment which can't be verified is assumed effective
+	if _, response := ClaimResources(ctx, cl, "uid-e", "time", "travel", nil, ClockResource); response != nil {
+		t.Fatalf("claim the clock failed, %s", response.Err)
+	}
+	if _, response := ClaimResources(ctx, cl, "uid-f", "time", "travel", nil, ClockResource); response == nil {
+		t.Errorf("the conflict with the unverifiable experiment is not reported")
+	}
+}
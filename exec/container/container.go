@@ -0,0 +1,327 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package container resolves the --container-id flag to the main pid and the cgroup of the container, which
+// are the ns_target and the cgroup-path of the experiments, so the executors enter the container without
+// knowing the container runtime.
+package container
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+const (
+	IdFlagName      = "container-id"
+	RuntimeFlagName = "container-runtime"
+
+	RuntimeDocker     = "docker"
+	RuntimeContainerd = "containerd"
+	RuntimeCrio       = "crio"
+
+	channelFlagName    = "channel"
+	cgroupPathFlagName = "cgroup-path"
+	cgroupRootFlagName = "cgroup-root"
+)
+
+// ProcRoot is the mount point of the proc filesystem, it's changed by the tests
+var ProcRoot = "/proc"
+
+var (
+	idPattern     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	hexPattern    = regexp.MustCompile(`^[0-9a-f]+$`)
+	fullIdPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+)
+
+// Target is the resolved container
+type Target struct {
+	// Id is the full id of the container, the short id or the name is expanded by the runtime or the cgroups
+	Id      string
+	Runtime string
+	Pid     int
+	// CgroupPath is the cgroup of the main process relative to the cgroup root, the path of the pids
+	// controller is used on the cgroup v1
+	CgroupPath string
+}
+
+// Resolve finds the main process of the container by the runtime cli which talks to the docker or the CRI
+// api, and falls back to scanning the cgroups of /proc/*/cgroup if the runtime cli is absent or fails.
+// The container names are only resolved by the runtime cli, the ids which are not hex are treated as names.
+func Resolve(ctx context.Context, id, runtime string) (*Target, *spec.Response) {
+	if !idPattern.MatchString(id) {
+		return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, IdFlagName, id,
+			"it only contains letters, digits, '_', '.' and '-'")
+	}
+	var runtimes []string
+	switch runtime {
+	case "":
+		runtimes = []string{RuntimeDocker, RuntimeContainerd}
+	case RuntimeDocker, RuntimeContainerd, RuntimeCrio:
+		runtimes = []string{runtime}
+	default:
+		return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, RuntimeFlagName, runtime,
+			fmt.Sprintf("it must be one of %s, %s and %s", RuntimeDocker, RuntimeContainerd, RuntimeCrio))
+	}
+	for _, r := range runtimes {
+		target, err := resolveByRuntime(ctx, id, r)
+		if err != nil {
+			log.Warnf(ctx, "resolve the container %s by %s failed, %v", id, r, err)
+			continue
+		}
+		if target != nil {
+			return target, nil
+		}
+	}
+	if !hexPattern.MatchString(id) {
+		return nil, spec.ResponseFailWithFlags(spec.ParameterInvalidDockContainerName, id)
+	}
+	target, err := resolveByCgroups(id)
+	if err != nil {
+		log.Warnf(ctx, "resolve the container %s by the cgroups failed, %v", id, err)
+		return nil, spec.ResponseFailWithFlags(spec.ParameterInvalidDockContainerId, id)
+	}
+	target.Runtime = runtime
+	log.Infof(ctx, "the main pid of the container %s is %d, cgroup: %s", target.Id, target.Pid, target.CgroupPath)
+	return target, nil
+}
+
+// Apply resolves the container of the --container-id flag, or the container which the ns_target of the nsexec
+// channel runs in, and fills the flags which the executors already understand, the ns_target is the main pid
+// and the empty cgroup-path is the cgroup of the container of the --container-id flag. The cgroup-root is put
+// into the context for the cpu and mem experiments which read the cgroup of the ns_target. The target is nil
+// if the experiment doesn't run in a container.
+func Apply(ctx context.Context, flags map[string]string) (context.Context, *Target, *spec.Response) {
+	id, pid := flags[IdFlagName], flags[channel.NSTargetFlagName]
+	var target *Target
+	var response *spec.Response
+	switch {
+	case id != "":
+		if pid != "" {
+			return ctx, nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, channel.NSTargetFlagName, pid,
+				fmt.Sprintf("it can't be used with --%s", IdFlagName))
+		}
+		target, response = Resolve(ctx, id, flags[RuntimeFlagName])
+	case pid != "" && flags[channelFlagName] == spec.NSExecBin:
+		target, response = attach(ctx, pid)
+	}
+	if target == nil {
+		return ctx, nil, response
+	}
+	flags[channel.NSTargetFlagName] = strconv.Itoa(target.Pid)
+	if cgroupPath, ok := flags[cgroupPathFlagName]; ok && cgroupPath == "" && id != "" {
+		flags[cgroupPathFlagName] = target.CgroupPath
+	}
+	return context.WithValue(ctx, cgroupRootFlagName, flags[cgroupRootFlagName]), target, nil
+}
+
+// attach returns the container of the ns_target. The destroy uses the container recorded by the create, the
+// main pid of which changes if the container is restarted, the changes in the container are still there.
+func attach(ctx context.Context, pid string) (*Target, *spec.Response) {
+	p, err := strconv.Atoi(pid)
+	if err != nil || p <= 0 {
+		return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, channel.NSTargetFlagName, pid, "it must be a positive integer")
+	}
+	if uid, ok := spec.IsDestroy(ctx); ok {
+		if record, err := LoadRecord(uid); err == nil {
+			if current, _ := FromPid(p); current != nil && current.Id == record.Id {
+				return current, nil
+			}
+			target, response := Resolve(ctx, record.Id, record.Runtime)
+			if response != nil {
+				return nil, response
+			}
+			log.Infof(ctx, "the container %s has been restarted, the main pid changes from %s to %d", record.Id, pid, target.Pid)
+			return target, nil
+		}
+	}
+	target, err := FromPid(p)
+	if err != nil {
+		log.Warnf(ctx, "read the container of the pid %d failed, %v", p, err)
+	}
+	return target, nil
+}
+
+// Validate checks that the pid still belongs to the container right before entering its namespaces, the
+// pid is reused by another process if the container is restarted after Resolve.
+func Validate(ctx context.Context, target *Target) *spec.Response {
+	containerId, _, err := readCgroup(target.Pid)
+	if err != nil || containerId != target.Id {
+		log.Errorf(ctx, "the pid %d doesn't belong to the container %s any more, container: %s, err: %v",
+			target.Pid, target.Id, containerId, err)
+		return spec.ResponseFailWithFlags(spec.ContainerInContextNotFound)
+	}
+	return nil
+}
+
+// NewChannel returns the channel which validates the target before each command of the nsexec channel
+func NewChannel(target *Target, cl spec.Channel) spec.Channel {
+	return &validatingChannel{Channel: cl, target: target}
+}
+
+type validatingChannel struct {
+	spec.Channel
+	target *Target
+}
+
+func (v *validatingChannel) Run(ctx context.Context, script, args string) *spec.Response {
+	if response := Validate(ctx, v.target); response != nil {
+		return response
+	}
+	return v.Channel.Run(ctx, script, args)
+}
+
+// resolveByRuntime returns nil without error if the runtime cli doesn't exist
+func resolveByRuntime(ctx context.Context, id, runtime string) (*Target, error) {
+	var cli string
+	var args []string
+	switch runtime {
+	case RuntimeDocker:
+		cli, args = "docker", []string{"inspect", "--type", "container", "-f", "{{.State.Pid}} {{.Id}}", id}
+	default:
+		// crictl talks to the CRI api of containerd and cri-o
+		cli, args = "crictl", []string{"inspect", "-o", "go-template", "--template", "{{.info.pid}} {{.status.id}}", id}
+	}
+	cl := channel.NewLocalChannel()
+	if !cl.IsCommandAvailable(ctx, cli) {
+		return nil, nil
+	}
+	response := exec.RunReadOnlyArgv(ctx, cl, cli, args...)
+	if !response.Success {
+		return nil, fmt.Errorf("%s", response.Err)
+	}
+	fields := strings.Fields(response.Result.(string))
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("unexpected output of %s inspect: %s", cli, response.Result)
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || pid <= 0 {
+		return nil, fmt.Errorf("the container %s is not running", id)
+	}
+	containerId, cgroupPath, err := readCgroup(pid)
+	if err != nil {
+		return nil, err
+	}
+	if containerId != fields[1] {
+		return nil, fmt.Errorf("the pid %d belongs to the container %s rather than %s", pid, containerId, fields[1])
+	}
+	return &Target{Id: containerId, Runtime: runtime, Pid: pid, CgroupPath: cgroupPath}, nil
+}
+
+// resolveByCgroups finds the processes whose cgroup contains the id, the id must be a prefix of the full id
+// and must match only one container. The main process is the one whose parent is outside the container.
+func resolveByCgroups(id string) (*Target, error) {
+	entries, err := os.ReadDir(ProcRoot)
+	if err != nil {
+		return nil, err
+	}
+	var containerId, cgroupPath string
+	pids := make(map[int]bool)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		cid, path, err := readCgroup(pid)
+		if err != nil || cid == "" || !strings.HasPrefix(cid, id) {
+			continue
+		}
+		if containerId != "" && cid != containerId {
+			return nil, fmt.Errorf("the id %s matches more than one container, %s and %s", id, containerId, cid)
+		}
+		containerId, cgroupPath = cid, path
+		pids[pid] = true
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("no process found in the container %s", id)
+	}
+	var mains []int
+	for pid := range pids {
+		if ppid, err := readPPid(pid); err != nil || !pids[ppid] {
+			mains = append(mains, pid)
+		}
+	}
+	if len(mains) == 0 {
+		return nil, fmt.Errorf("the main process of the container %s not found", id)
+	}
+	sort.Ints(mains)
+	return &Target{Id: containerId, Pid: mains[0], CgroupPath: cgroupPath}, nil
+}
+
+// readCgroup returns the full container id in the cgroup of the pid and the cgroup path, such as
+// /system.slice/docker-<id>.scope, /kubepods/burstable/pod<uid>/<id> or
+// /kubepods.slice/.../cri-containerd-<id>.scope. The container id is empty if the pid is on the host.
+func readCgroup(pid int) (string, string, error) {
+	bytes, err := os.ReadFile(filepath.Join(ProcRoot, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return "", "", err
+	}
+	var containerId, cgroupPath string
+	for _, line := range strings.Split(string(bytes), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		ids := fullIdPattern.FindAllString(fields[2], -1)
+		if len(ids) == 0 {
+			continue
+		}
+		id := ids[len(ids)-1]
+		if containerId != "" && id != containerId {
+			return "", "", fmt.Errorf("the cgroups of the pid %d belong to different containers", pid)
+		}
+		containerId = id
+		if cgroupPath == "" || fields[0] == "0" || hasController(fields[1], "pids") {
+			cgroupPath = fields[2]
+		}
+	}
+	return containerId, cgroupPath, nil
+}
+
+func hasController(controllers, name string) bool {
+	for _, controller := range strings.Split(controllers, ",") {
+		if controller == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readPPid reads the parent pid from /proc/<pid>/stat, the command name in the parentheses may contain spaces
+func readPPid(pid int) (int, error) {
+	bytes, err := os.ReadFile(filepath.Join(ProcRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+	stat := string(bytes)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("unexpected stat of the pid %d: %s", pid, stat)
+	}
+	return strconv.Atoi(fields[1])
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+var (
+	dockerId = strings.Repeat("ab", 32)
+	podId    = strings.Repeat("cd", 32)
+)
+
+// fakeProc writes the cgroup and the stat of the pid into the fake proc root
+func fakeProc(t *testing.T, pid, ppid int, cgroup string) {
+	dir := filepath.Join(ProcRoot, strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	stat := fmt.Sprintf("%d (my app) S %d 1 1 0 -1", pid, ppid)
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup"), []byte(cgroup), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setupProc(t *testing.T) {
+	procRoot := ProcRoot
+	ProcRoot = t.TempDir()
+	t.Cleanup(func() { ProcRoot = procRoot })
+
+	fakeProc(t, 1, 0, "0::/init.scope\n")
+	// the docker container on the cgroup v2, 100 is the main process and 101 is its child
+	fakeProc(t, 100, 1, "0::/system.slice/docker-"+dockerId+".scope\n")
+	fakeProc(t, 101, 100, "0::/system.slice/docker-"+dockerId+".scope\n")
+	// the kubernetes container on the cgroup v1
+	v1 := strings.Join([]string{
+		"12:pids:/kubepods/burstable/pod1/" + podId,
+		"4:cpu,cpuacct:/kubepods/burstable/pod1/" + podId,
+		"1:name=systemd:/kubepods/burstable/pod1/" + podId,
+	}, "\n")
+	fakeProc(t, 200, 50, v1)
+	fakeProc(t, 300, 200, v1)
+}
+
+func TestResolveByCgroups(t *testing.T) {
+	setupProc(t)
+	tests := []struct {
+		id         string
+		pid        int
+		cgroupPath string
+	}{
+		{dockerId, 100, "/system.slice/docker-" + dockerId + ".scope"},
+		{dockerId[:12], 100, "/system.slice/docker-" + dockerId + ".scope"},
+		{podId[:12], 200, "/kubepods/burstable/pod1/" + podId},
+	}
+	for _, tt := range tests {
+		target, err := resolveByCgroups(tt.id)
+		if err != nil {
+			t.Fatalf("resolveByCgroups(%s) failed, %v", tt.id, err)
+		}
+		if target.Pid != tt.pid || target.CgroupPath != tt.cgroupPath || !strings.HasPrefix(target.Id, tt.id) {
+			t.Errorf("resolveByCgroups(%s) = %+v, want pid %d, cgroup %s", tt.id, target, tt.pid, tt.cgroupPath)
+		}
+	}
+	for _, id := range []string{"ef", strings.Repeat("ef", 32)} {
+		if target, err := resolveByCgroups(id); err == nil {
+			t.Errorf("resolveByCgroups(%s) = %+v, want error", id, target)
+		}
+	}
+}
+
+func TestResolveByCgroupsAmbiguous(t *testing.T) {
+	setupProc(t)
+	fakeProc(t, 400, 1, "0::/system.slice/docker-"+strings.Repeat("a", 64)+".scope\n")
+	if target, err := resolveByCgroups("a"); err == nil {
+		t.Errorf("resolveByCgroups(a) = %+v, want error", target)
+	}
+}
+
+func TestResolveFailure(t *testing.T) {
+	setupProc(t)
+	tests := []struct {
+		id, runtime string
+		code        int32
+	}{
+		{"ef01", "", spec.ParameterInvalidDockContainerId.Code},
+		{"nginx", RuntimeCrio, spec.ParameterInvalidDockContainerName.Code},
+		{"a;b", "", spec.ParameterIllegal.Code},
+		{dockerId, "podman", spec.ParameterIllegal.Code},
+	}
+	// the runtime cli is disabled by the empty PATH, so only the cgroups are scanned
+	t.Setenv("PATH", "")
+	for _, tt := range tests {
+		target, response := Resolve(context.Background(), tt.id, tt.runtime)
+		if response == nil || response.Code != tt.code {
+			t.Errorf("Resolve(%s, %s) = %+v, %+v, want code %d", tt.id, tt.runtime, target, response, tt.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	setupProc(t)
+	t.Setenv("PATH", "")
+	ctx := context.Background()
+	target, response := Resolve(ctx, dockerId[:12], RuntimeDocker)
+	if response != nil {
+		t.Fatalf("Resolve failed, %+v", response)
+	}
+	if response := Validate(ctx, target); response != nil {
+		t.Fatalf("Validate failed, %+v", response)
+	}
+	// the container is restarted and the pid is reused by a host process
+	fakeProc(t, 100, 1, "0::/user.slice\n")
+	if response := Validate(ctx, target); response == nil || response.Code != spec.ContainerInContextNotFound.Code {
+		t.Errorf("Validate of the reused pid = %+v, want code %d", response, spec.ContainerInContextNotFound.Code)
+	}
+}
+
+func TestApplyAfterRestart(t *testing.T) {
+	setupProc(t)
+	t.Setenv("PATH", "")
+	recordDir := RecordDir
+	RecordDir = t.TempDir()
+	t.Cleanup(func() { RecordDir = recordDir })
+
+	flags := map[string]string{channelFlagName: spec.NSExecBin, channel.NSTargetFlagName: "101"}
+	ctx := context.WithValue(context.Background(), spec.Uid, "uid-a")
+	_, target, response := Apply(ctx, flags)
+	if response != nil || target == nil || target.Id != dockerId || target.Pid != 101 {
+		t.Fatalf("Apply on create = %+v, %+v, want the pid 101 of the container %s", target, response, dockerId)
+	}
+	if err := SaveRecord("uid-a", target); err != nil {
+		t.Fatal(err)
+	}
+
+	// the container is restarted, the main pid is 500 and the old pids are reused by the host processes
+	fakeProc(t, 100, 1, "0::/user.slice\n")
+	fakeProc(t, 101, 1, "0::/user.slice\n")
+	fakeProc(t, 500, 1, "0::/system.slice/docker-"+dockerId+".scope\n")
+	flags = map[string]string{channelFlagName: spec.NSExecBin, channel.NSTargetFlagName: "101"}
+	_, target, response = Apply(spec.SetDestroyFlag(ctx, "uid-a"), flags)
+	if response != nil || target == nil || target.Pid != 500 || flags[channel.NSTargetFlagName] != "500" {
+		t.Fatalf("Apply on destroy = %+v, %+v, flags: %v, want the new pid 500", target, response, flags)
+	}
+
+	// the pid on the host isn't a container
+	flags = map[string]string{channelFlagName: spec.NSExecBin, channel.NSTargetFlagName: "1"}
+	if _, target, response = Apply(ctx, flags); target != nil || response != nil {
+		t.Errorf("Apply of the host pid = %+v, %+v, want nil", target, response)
+	}
+}
+
+func TestRootOwner(t *testing.T) {
+	setupProc(t)
+	dir := filepath.Join(ProcRoot, "100")
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("uid_map", "         0          0 4294967295\n")
+	write("gid_map", "         0          0 4294967295\n")
+	if uid, gid, err := RootOwner(100); err != nil || uid != 0 || gid != 0 {
+		t.Errorf("RootOwner without the user namespace = %d, %d, %v, want 0, 0", uid, gid, err)
+	}
+	write("uid_map", "         0     100000      65536\n")
+	write("gid_map", "         0     200000      65536\n")
+	if uid, gid, err := RootOwner(100); err != nil || uid != 100000 || gid != 200000 {
+		t.Errorf("RootOwner in the user namespace = %d, %d, %v, want 100000, 200000", uid, gid, err)
+	}
+	write("uid_map", "      1000     100000      65536\n")
+	if _, _, err := RootOwner(100); err == nil {
+		t.Errorf("RootOwner without the mapped root, want error")
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+)
+
+// RecordDir is the directory of the containers which the experiments run in, the destroy from the host
+// finds the new main pid of the restarted container by the record
+var RecordDir = "/var/run/chaosblade/container"
+
+func getRecordFile(uid string) string {
+	return filepath.Join(RecordDir, uid+".json")
+}
+
+// SaveRecord records the container of the experiment
+func SaveRecord(uid string, target *Target) error {
+	if err := os.MkdirAll(RecordDir, 0700); err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getRecordFile(uid), bytes, 0600)
+}
+
+// LoadRecord reads the container of the experiment, the error is os.ErrNotExist if there is no record
+func LoadRecord(uid string) (*Target, error) {
+	bytes, err := os.ReadFile(getRecordFile(uid))
+	if err != nil {
+		return nil, err
+	}
+	target := &Target{}
+	if err := json.Unmarshal(bytes, target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// RemoveRecord removes the record after the experiment is destroyed
+func RemoveRecord(uid string) {
+	if err := os.Remove(getRecordFile(uid)); err != nil && !os.IsNotExist(err) {
+		log.Warnf(context.Background(), "remove the container record of the experiment %s failed, %v", uid, err)
+	}
+}
+
+// FromPid returns the container which the pid runs in, it's nil if the pid is on the host
+func FromPid(pid int) (*Target, error) {
+	containerId, cgroupPath, err := readCgroup(pid)
+	if err != nil || containerId == "" {
+		return nil, err
+	}
+	return &Target{Id: containerId, Pid: pid, CgroupPath: cgroupPath}, nil
+}
+
+// RootOwner returns the host uid and gid which the root of the user namespace of the pid is mapped to, they are
+// 0 if the pid isn't in a user namespace. The files created from the host are owned by them, otherwise they
+// are owned by the overflow uid in the container.
+func RootOwner(pid int) (int, int, error) {
+	uid, err := mapRoot(filepath.Join(ProcRoot, strconv.Itoa(pid), "uid_map"))
+	if err != nil {
+		return 0, 0, err
+	}
+	gid, err := mapRoot(filepath.Join(ProcRoot, strconv.Itoa(pid), "gid_map"))
+	if err != nil {
+		return 0, 0, err
+	}
+	return uid, gid, nil
+}
+
+// mapRoot reads the id of the outer namespace which the id 0 is mapped to, each line of the map is
+// `inner-id outer-id count`
+func mapRoot(mapFile string) (int, error) {
+	file, err := os.Open(mapFile)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != "0" {
+			continue
+		}
+		return strconv.Atoi(fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("the root isn't mapped in %s", mapFile)
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+const (
+	// PidFlagName is the pid of a process in the container whose host side veth is the target
+	PidFlagName = "container-pid"
+	// VethFlagName is the container whose host side veth is the target, unlike the container-id flag the
+	// experiment runs on the host rather than in the container
+	VethFlagName = "veth-container"
+	// DefaultVethInterface is the interface in the container which the host side veth is the peer of
+	DefaultVethInterface = "eth0"
+)
+
+// VethFlags are the flags of the network experiments which change the host side veth of the container
+var VethFlags = []spec.ExpFlagSpec{
+	&spec.ExpFlag{
+		Name: PidFlagName,
+		Desc: "The pid of a process in the container, the host side veth peer of the container interface is the target " +
+			"instead, so the container namespaces are not entered",
+	},
+	&spec.ExpFlag{
+		Name: VethFlagName,
+		Desc: "The id or the name of the container, the host side veth peer of the container interface is the target " +
+			"instead, so the container namespaces are not entered. It's resolved like --container-id",
+	},
+}
+
+// ResolveHostVeth returns the host side veth peer of the interface in the container of the VethFlags, it's
+// empty if the flags are absent. The interface is in the network namespace of the container, such as eth0,
+// which is a veth whose peer index is shown as eth0@if7.
+func ResolveHostVeth(ctx context.Context, cl spec.Channel, flags map[string]string, netInterface string) (string, *spec.Response) {
+	pid, id := flags[PidFlagName], flags[VethFlagName]
+	switch {
+	case pid != "" && id != "":
+		return "", spec.ResponseFailWithFlags(spec.ParameterIllegal, VethFlagName, id, fmt.Sprintf("it can't be used with --%s", PidFlagName))
+	case id != "":
+		target, response := Resolve(ctx, id, flags[RuntimeFlagName])
+		if response != nil {
+			return "", response
+		}
+		pid = strconv.Itoa(target.Pid)
+	case pid == "":
+		return "", nil
+	}
+	if p, err := strconv.Atoi(pid); err != nil || p <= 0 {
+		return "", spec.ResponseFailWithFlags(spec.ParameterIllegal, PidFlagName, pid, "it must be a positive integer")
+	}
+	veth, response := resolvePeerVeth(ctx, cl, pid, netInterface)
+	if response != nil {
+		return "", response
+	}
+	log.Infof(ctx, "the interface %s of the process %s is the peer of the host side veth %s", netInterface, pid, veth)
+	return veth, nil
+}
+
+// resolvePeerVeth finds the host interface by the peer index of the interface in the network namespace of the
+// process, such as eth0@if7 in the namespace and 7: veth1a2b@if2 on the host
+func resolvePeerVeth(ctx context.Context, cl spec.Channel, pid, netInterface string) (string, *spec.Response) {
+	response := exec.RunReadOnlyArgv(ctx, cl, "nsenter", "-t", pid, "-n", "ip", "-o", "link", "show", "dev", netInterface)
+	if !response.Success {
+		return "", exec.Fail(exec.TargetNotFound, "network", "veth",
+			fmt.Sprintf("the interface %s of the process %s is not found, %s", netInterface, pid, response.Err))
+	}
+	_, peerIndex := parseLinkName(fmt.Sprint(response.Result))
+	if peerIndex == "" {
+		return "", exec.Fail(exec.TargetNotFound, "network", "veth",
+			fmt.Sprintf("the interface %s of the process %s is not a veth with the peer on the host", netInterface, pid))
+	}
+	response = exec.RunReadOnlyArgv(ctx, cl, "ip", "-o", "link", "show")
+	if !response.Success {
+		return "", exec.Fail(exec.TargetNotFound, "network", "veth", fmt.Sprintf("list the interfaces failed, %s", response.Err))
+	}
+	for _, line := range strings.Split(fmt.Sprint(response.Result), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), peerIndex+":") {
+			continue
+		}
+		if name, _ := parseLinkName(line); name != "" {
+			return name, nil
+		}
+	}
+	return "", exec.Fail(exec.TargetNotFound, "network", "veth",
+		fmt.Sprintf("the peer %s of the interface %s of the process %s is not found on the host", peerIndex, netInterface, pid))
+}
+
+// parseLinkName returns the name and the peer index of the line of ip -o link show, such as
+// 2: eth0@if7: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500, the peer index is empty if it's not shown
+func parseLinkName(line string) (string, string) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return "", ""
+	}
+	name, link, _ := strings.Cut(strings.TrimSuffix(fields[1], ":"), "@")
+	return name, strings.TrimPrefix(link, "if")
+}
+
+// LinkExists returns true if the interface exists on the host, the veth is removed with the container
+func LinkExists(ctx context.Context, cl spec.Channel, netInterface string) bool {
+	return exec.RunReadOnlyArgv(ctx, cl, "ip", "-o", "link", "show", "dev", netInterface).Success
+}
+
+// IsBridgePort returns true if the interface is enslaved to a bridge, such as the veth on docker0, whose
+// packets are matched by the physdev of iptables rather than the interface
+func IsBridgePort(ctx context.Context, cl spec.Channel, netInterface string) bool {
+	response := exec.RunReadOnlyArgv(ctx, cl, "ip", "-o", "link", "show", "dev", netInterface)
+	return response.Success && strings.Contains(fmt.Sprint(response.Result), " master ")
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func TestResolveHostVeth(t *testing.T) {
+	cl := exec.NewMockChannel().
+		OnRun("nsenter", "-t 1234 -n ip -o link show dev eth0",
+			spec.ReturnSuccess(`2: eth0@if7: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue state UP mode DEFAULT group default \    link/ether 02:42:ac:11:00:02 brd ff:ff:ff:ff:ff:ff link-netnsid 0`)).
+		OnRun("ip", "^-o link show$", spec.ReturnSuccess(`1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536 qdisc noqueue state UNKNOWN mode DEFAULT group default qlen 1000
+17: vethffee@if2: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue master docker0 state UP mode DEFAULT group default
+7: veth1a2b@if2: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue master docker0 state UP mode DEFAULT group default`))
+	ctx := context.Background()
+	veth, response := ResolveHostVeth(ctx, cl, map[string]string{PidFlagName: "1234"}, "eth0")
+	if response != nil || veth != "veth1a2b" {
+		t.Errorf("expected the peer veth1a2b, got %s, %+v", veth, response)
+	}
+
+	if _, response := ResolveHostVeth(ctx, cl, map[string]string{PidFlagName: "1234"}, "eth1"); response == nil {
+		t.Errorf("expected the interface without the peer index is refused")
+	}
+	if veth, response := ResolveHostVeth(ctx, cl, map[string]string{}, "eth0"); response != nil || veth != "" {
+		t.Errorf("expected nothing is resolved without the flags, got %s, %+v", veth, response)
+	}
+	for _, flags := range []map[string]string{
+		{PidFlagName: "-1"},
+		{PidFlagName: "1234", VethFlagName: "4ee9e4a1e2ea"},
+	} {
+		if _, response := ResolveHostVeth(ctx, cl, flags, "eth0"); response == nil || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the flags %v are refused, got %+v", flags, response)
+		}
+	}
+}
@@ -19,6 +19,7 @@ package cpu
 import (
 	"context"
 	"fmt"
+	"math"
 	"os"
 	osexec "os/exec"
 	"runtime"
@@ -34,7 +35,9 @@ import (
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
 	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs"
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
 
 	_ "go.uber.org/automaxprocs/maxprocs"
 )
@@ -52,7 +55,38 @@ func NewCpuCommandModelSpec() spec.ExpModelCommandSpec {
 				&FullLoadActionCommand{
 					spec.BaseExpActionCommandSpec{
 						ActionMatchers: []spec.ExpFlagSpec{},
-						ActionFlags:    []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:     "cpu-count",
+								Desc:     "Cpu count",
+								Required: false,
+							},
+							&spec.ExpFlag{
+								Name:     "cpu-percent",
+								Desc:     "percent of burn CPU (0-100), it may be a decimal, such as 0.5",
+								Required: false,
+							},
+							&spec.ExpFlag{
+								Name:     "cpu-cores",
+								Desc:     "cores of burn CPU, such as 0.5 for half a core, it can't be used with cpu-percent or cpu-count, and it can't exceed the detected cores or the cores of cpu-list",
+								Required: false,
+							},
+							&spec.ExpFlag{
+								Name:     "cpu-index",
+								Desc:     "cpu index, user unavailable!",
+								Required: false,
+							},
+							&spec.ExpFlag{
+								Name:     "climb-time",
+								Desc:     "durations(s) to climb",
+								Required: false,
+							},
+							&spec.ExpFlag{
+								Name:     "migrate-interval",
+								Desc:     "move each burn worker to a random core of the cpu-list or the cpuset every interval, which is jittered by ±50%, such as 5s, only supported on Linux",
+								Required: false,
+							},
+						},
 						ActionExecutor: &cpuExecutor{},
 						ActionExample: `
 # Create a CPU full load experiment
@@ -68,45 +102,46 @@ blade create cpu load --cpu-list 0,3
 blade create cpu load --cpu-list 1-3
 
 # Specified percentage load
-blade create cpu load --cpu-percent 60`,
+blade create cpu load --cpu-percent 60
+
+# Consume exactly half a core of the host
+blade create cpu load --cpu-cores 0.5
+
+# Burn 60% of the cores 0-3, and move the workers to the random cores of them about every 5 seconds
+blade create cpu load --cpu-percent 60 --cpu-list 0-3 --migrate-interval 5s`,
 						ActionPrograms:    []string{BurnCpuBin},
 						ActionCategories:  []string{category.SystemCpu},
 						ActionProcessHang: true,
 					},
 				},
+				NewFrequencyActionCommandSpec(),
 			},
 			ExpFlags: []spec.ExpFlagSpec{
-				&spec.ExpFlag{
-					Name:     "cpu-count",
-					Desc:     "Cpu count",
-					Required: false,
-				},
 				&spec.ExpFlag{
 					Name:     "cpu-list",
-					Desc:     "CPUs in which to allow burning (0-3 or 1,3)",
-					Required: false,
-				},
-				&spec.ExpFlag{
-					Name:     "cpu-percent",
-					Desc:     "percent of burn CPU (0-100)",
+					Desc:     "CPUs in which to allow burning or changing the frequency (0-3 or 1,3)",
 					Required: false,
 				},
 				&spec.ExpFlag{
-					Name:     "cpu-index",
-					Desc:     "cpu index, user unavailable!",
+					Name:     "cgroup-root",
+					Desc:     "cgroup root path, such as the mount of the host cgroupfs /host-sys/fs/cgroup in the container, it's detected from the cgroup mounts if absent",
+					NoArgs:   false,
 					Required: false,
+					Default:  "",
 				},
 				&spec.ExpFlag{
-					Name:     "climb-time",
-					Desc:     "durations(s) to climb",
+					Name:     cgroups.HostProcKey,
+					Desc:     "the mount point of the host proc filesystem, such as /host/proc, which the cgroup of the host pid is read from when running in the container",
+					NoArgs:   false,
 					Required: false,
+					Default:  "",
 				},
 				&spec.ExpFlag{
-					Name:     "cgroup-root",
-					Desc:     "cgroup root path, default value /sys/fs/cgroup",
+					Name:     cgroups.CGroupScopeKey,
+					Desc:     "the cgroup which the usage of the target is measured in, leaf is the cgroup of the target, limit-owner is its nearest ancestor which sets the limit, such as the service slice of the scope, default limit-owner",
 					NoArgs:   false,
 					Required: false,
-					Default:  "/sys/fs/cgroup",
+					Default:  "",
 				},
 			},
 		},
@@ -122,7 +157,7 @@ func (*CpuCommandModelSpec) ShortDesc() string {
 }
 
 func (*CpuCommandModelSpec) LongDesc() string {
-	return "Cpu experiment, for example full load"
+	return "Cpu experiment, for example full load or lower the frequency"
 }
 
 type FullLoadActionCommand struct {
@@ -152,8 +187,8 @@ func (*FullLoadActionCommand) Matchers() []spec.ExpFlagSpec {
 	return []spec.ExpFlagSpec{}
 }
 
-func (*FullLoadActionCommand) Flags() []spec.ExpFlagSpec {
-	return []spec.ExpFlagSpec{}
+func (f *FullLoadActionCommand) Flags() []spec.ExpFlagSpec {
+	return f.ActionFlags
 }
 
 type cpuExecutor struct {
@@ -175,23 +210,43 @@ func (ce *cpuExecutor) Exec(uid string, ctx context.Context, model *spec.ExpMode
 	if _, ok := spec.IsDestroy(ctx); ok {
 		return ce.stop(ctx)
 	}
+	ctx = cgroups.WithHostProc(ctx, model.ActionFlags[cgroups.HostProcKey])
+	if err := cgroups.CheckCGroupScope(model.ActionFlags[cgroups.CGroupScopeKey]); err != nil {
+		log.Errorf(ctx, "`%s`: %s is illegal, %v", model.ActionFlags[cgroups.CGroupScopeKey], cgroups.CGroupScopeKey, err)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, cgroups.CGroupScopeKey, model.ActionFlags[cgroups.CGroupScopeKey], err.Error())
+	}
+	ctx = cgroups.WithCGroupScope(ctx, model.ActionFlags[cgroups.CGroupScopeKey])
 
 	var cpuCount int
 	var cpuList string
-	var cpuPercent int
+	var cpuPercent float64
 	var climbTime int
+	var migrateInterval time.Duration
+
+	if migrateIntervalStr := model.ActionFlags["migrate-interval"]; migrateIntervalStr != "" {
+		if !migrationSupported {
+			return exec.Fail(exec.UnsupportedPlatform, "cpu", "migrate", "the core migration is only supported on Linux")
+		}
+		var response *spec.Response
+		if migrateInterval, response = validation.ValidateDuration("migrate-interval", migrateIntervalStr, time.Second); response != nil {
+			log.Errorf(ctx, "`%s`: migrate-interval is illegal, %s", migrateIntervalStr, response.Err)
+			return response
+		}
+	}
 
 	cpuPercentStr := model.ActionFlags["cpu-percent"]
+	cpuCoresStr := model.ActionFlags["cpu-cores"]
+	if cpuCoresStr != "" && cpuPercentStr != "" {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "cpu-cores", cpuCoresStr, "it can't be used with cpu-percent")
+	}
+	if cpuCoresStr != "" && model.ActionFlags["cpu-count"] != "" {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "cpu-cores", cpuCoresStr, "it can't be used with cpu-count")
+	}
 	if cpuPercentStr != "" {
-		var err error
-		cpuPercent, err = strconv.Atoi(cpuPercentStr)
-		if err != nil {
-			log.Errorf(ctx, "`%s`: cpu-percent is illegal, it must be a positive integer", cpuPercentStr)
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "cpu-percent", cpuPercentStr, "it must be a positive integer")
-		}
-		if cpuPercent > 100 || cpuPercent < 0 {
-			log.Errorf(ctx, "`%s`: cpu-percent is illegal, it must be a positive integer and not bigger than 100", cpuPercentStr)
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "cpu-percent", cpuPercentStr, "it must be a positive integer and not bigger than 100")
+		var response *spec.Response
+		if cpuPercent, response = validation.ValidateDecimalPercent("cpu-percent", cpuPercentStr); response != nil {
+			log.Errorf(ctx, "`%s`: cpu-percent is illegal, %s", cpuPercentStr, response.Err)
+			return response
 		}
 	} else {
 		cpuPercent = 100
@@ -199,7 +254,8 @@ func (ce *cpuExecutor) Exec(uid string, ctx context.Context, model *spec.ExpMode
 
 	cpuListStr := model.ActionFlags["cpu-list"]
 	if cpuListStr != "" {
-		if !ce.channel.IsCommandAvailable(ctx, "taskset") {
+		// the migrated workers are pinned by sched_setaffinity instead of taskset
+		if migrateInterval == 0 && !ce.channel.IsCommandAvailable(ctx, "taskset") {
 			return spec.ResponseFailWithFlags(spec.CommandTasksetNotFound)
 		}
 		cores, err := util.ParseIntegerListToStringSlice("cpu-list", cpuListStr)
@@ -208,15 +264,24 @@ func (ce *cpuExecutor) Exec(uid string, ctx context.Context, model *spec.ExpMode
 			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "cpu-list", cpuListStr, err.Error())
 		}
 		cpuList = strings.Join(cores, ",")
+		if cpuCoresStr != "" {
+			// the cores are spread over the listed cores evenly
+			cpuCores, response := validation.ValidatePositiveDecimal("cpu-cores", cpuCoresStr, float64(len(cores)))
+			if response != nil {
+				log.Errorf(ctx, "`%s`: cpu-cores is illegal, %s", cpuCoresStr, response.Err)
+				return response
+			}
+			cpuPercent = cpuCores / float64(len(cores)) * 100
+		}
 	} else {
 		// if cpu-list value is not empty, then the cpu-count flag is invalid
 		var err error
 		cpuCountStr := model.ActionFlags["cpu-count"]
 		if cpuCountStr != "" {
-			cpuCount, err = strconv.Atoi(cpuCountStr)
-			if err != nil {
-				log.Errorf(ctx, "`%s`: cpu-count is illegal, cpu-count value must be a positive integer", cpuCountStr)
-				return spec.ResponseFailWithFlags(spec.ParameterIllegal, "cpu-count", cpuCountStr, "it must be a positive integer")
+			var response *spec.Response
+			if cpuCount, response = validation.ValidateInt("cpu-count", cpuCountStr, 0, math.MaxInt); response != nil {
+				log.Errorf(ctx, "`%s`: cpu-count is illegal, %s", cpuCountStr, response.Err)
+				return response
 			}
 		}
 
@@ -235,56 +300,69 @@ func (ce *cpuExecutor) Exec(uid string, ctx context.Context, model *spec.ExpMode
 		if cpuCount <= 0 || cpuCount > tmpCpuCnt {
 			cpuCount = tmpCpuCnt
 		}
+		if cpuCoresStr != "" {
+			cpuCores, response := validation.ValidatePositiveDecimal("cpu-cores", cpuCoresStr, float64(tmpCpuCnt))
+			if response != nil {
+				log.Errorf(ctx, "`%s`: cpu-cores is illegal, %s", cpuCoresStr, response.Err)
+				return response
+			}
+			cpuPercent = cpuCores / float64(tmpCpuCnt) * 100
+			ctx = context.WithValue(ctx, "workers", int(math.Ceil(cpuCores)))
+		}
 	}
 
 	climbTimeStr := model.ActionFlags["climb-time"]
 	if climbTimeStr != "" {
-		var err error
-		climbTime, err = strconv.Atoi(climbTimeStr)
-		if err != nil {
-			log.Errorf(ctx, "`%s`: climb-time is illegal, climb-time value must be a positive integer", climbTimeStr)
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "climb-time", climbTimeStr, "it must be a positive integer")
-		}
-		if climbTime > 600 || climbTime < 0 {
-			log.Errorf(ctx, "`%s`: climb-time is illegal, climb-time value must be a positive integer and not bigger than 600", climbTimeStr)
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "climb-time", climbTimeStr, "must be a positive integer and not bigger than 600")
+		var response *spec.Response
+		if climbTime, response = validation.ValidateInt("climb-time", climbTimeStr, 0, 600); response != nil {
+			log.Errorf(ctx, "`%s`: climb-time is illegal, %s", climbTimeStr, response.Err)
+			return response
 		}
 	}
 
 	ctx = context.WithValue(ctx, "cgroup-root", model.ActionFlags["cgroup-root"])
+	if migrateInterval > 0 {
+		ctx = context.WithValue(ctx, "migrate-interval", migrateInterval)
+	}
 
 	return ce.start(ctx, cpuList, cpuCount, cpuPercent, climbTime, model.ActionFlags["cpu-index"])
 }
 
-// start burn cpu
-func (ce *cpuExecutor) start(ctx context.Context, cpuList string, cpuCount, cpuPercent, climbTime int, cpuIndexStr string) *spec.Response {
-	ctx = context.WithValue(ctx, "cpuCount", cpuCount)
+// start burn cpu, the usage is measured on the cpuCount cores, and it's burned by the workers in the context,
+// which are fewer than the cores for the cpu-cores flag, default cpuCount
+func (ce *cpuExecutor) start(ctx context.Context, cpuList string, cpuCount int, cpuPercent float64, climbTime int, cpuIndexStr string) *spec.Response {
+	migrateInterval, _ := ctx.Value("migrate-interval").(time.Duration)
+	var cpus []int
 	if cpuList != "" {
 		cores, err := util.ParseIntegerListToStringSlice("cpu-list", cpuList)
 		if err != nil {
 			log.Errorf(ctx, "`%s`: cpu-list is illegal, %s", cpuList, err.Error())
 			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "cpu-list", cpuList, err.Error())
 		}
+		if migrateInterval == 0 {
+			return ce.startPerCore(ctx, cores, cpuPercent, climbTime)
+		}
+		// the workers hop among the listed cores, so the usage is measured on them as a whole
+		// instead of by the burn of each core
 		for _, core := range cores {
-
-			args := fmt.Sprintf(`%s create cpu fullload --cpu-count 1 --cpu-percent %d --climb-time %d --cpu-index %s --uid %s`,
-				os.Args[0], cpuPercent, climbTime, core, ctx.Value(spec.Uid))
-
-			args = fmt.Sprintf("-c %s %s", core, args)
-			argsArray := strings.Split(args, " ")
-			command := osexec.CommandContext(ctx, "taskset", argsArray...)
-			command.SysProcAttr = &syscall.SysProcAttr{}
-
-			if err := command.Start(); err != nil {
-				return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("taskset exec failed, %v", err))
-			}
+			cpu, _ := strconv.Atoi(core)
+			cpus = append(cpus, cpu)
 		}
-		return spec.ReturnSuccess(ctx.Value(spec.Uid))
+		cpuCount = len(cpus)
+		ctx = context.WithValue(ctx, "cpuSet", cpus)
 	}
+	ctx = context.WithValue(ctx, "cpuCount", cpuCount)
 
-	runtime.GOMAXPROCS(cpuCount)
-	log.Debugf(ctx, "cpu counts: %d", cpuCount)
-	slopePercent := float64(cpuPercent)
+	workers, ok := ctx.Value("workers").(int)
+	if !ok || workers <= 0 || workers > cpuCount {
+		workers = cpuCount
+	}
+	// the duty cycle of a worker is the share of its core, but the usage is the share of the cpuCount cores,
+	// so the fewer workers take the larger steps to reach the target
+	gain := float64(cpuCount) / float64(workers)
+	runtime.GOMAXPROCS(workers)
+	log.Debugf(ctx, "cpu counts: %d, workers: %d", cpuCount, workers)
+	slopePercent := cpuPercent
 
 	var cpuIndex int
 	percpu := false
@@ -302,31 +380,90 @@ func (ce *cpuExecutor) start(ctx context.Context, cpuList string, cpuCount, cpuP
 	// which system faults cannot be quickly noticed by monitoring system.
 	slope(ctx, cpuPercent, climbTime, &slopePercent, percpu, cpuIndex)
 
-	quota := make(chan int64, cpuCount)
-	for i := 0; i < cpuCount; i++ {
-		go burn(ctx, quota, slopePercent, percpu, cpuIndex)
+	var m *migration
+	if migrateInterval > 0 {
+		if cpus == nil {
+			var err error
+			if cpus, err = allowedCpus(); err != nil {
+				return exec.Fail(exec.ProcessControlFailed, "cpu", "migrate", fmt.Sprintf("get the allowed cores failed, %v", err))
+			}
+		}
+		m = newMigration(migrateInterval, cpus, workers)
+		log.Infof(ctx, "move the %d workers among the cores %v about every %v", workers, cpus, migrateInterval)
 	}
 
+	quota := make(chan int64, workers)
+	for i := 0; i < workers; i++ {
+		go burn(ctx, quota, slopePercent, gain, percpu, cpuIndex, i, m)
+	}
+
+	uid, _ := ctx.Value(spec.Uid).(string)
+	instance := ""
+	if percpu {
+		instance = fmt.Sprintf("cpu%d", cpuIndex)
+	}
+	metrics := exec.NewMetricsWriter(ctx, uid, "cpu", "fullload", instance)
+	window := newUsageWindow(usageWindowSize)
+	initialThrottled, cgroupMode := getThrottled(ctx)
 	for {
-		q := getQuota(ctx, slopePercent, percpu, cpuIndex)
-		for i := 0; i < cpuCount; i++ {
+		q, used := getQuota(ctx, slopePercent, gain, percpu, cpuIndex)
+		window.add(used, slopePercent)
+		achieved, target := window.averages()
+		metrics.Set("cpu_percent", used)
+		metrics.Set("target_cpu_percent", slopePercent)
+		metrics.Set("avg_cpu_percent", achieved)
+		metrics.Set("avg_target_cpu_percent", target)
+		metrics.Set("avg_samples", float64(len(window.achieved)))
+		if cgroupMode {
+			// the counter of the recreated cgroup restarts from zero
+			if throttled, ok := getThrottled(ctx); ok && throttled >= initialThrottled {
+				metrics.Set("nr_throttled", float64(throttled-initialThrottled))
+			}
+		}
+		if m != nil {
+			m.setMetrics(metrics)
+		}
+		metrics.Flush(ctx)
+		for i := 0; i < workers; i++ {
 			quota <- q
 		}
 	}
 }
 
+// startPerCore starts a burn of a core for each core by taskset, the usage is measured on the core
+func (ce *cpuExecutor) startPerCore(ctx context.Context, cores []string, cpuPercent float64, climbTime int) *spec.Response {
+	for _, core := range cores {
+
+		args := fmt.Sprintf(`%s create cpu fullload --cpu-count 1 --cpu-percent %s --climb-time %d --cpu-index %s --uid %s`,
+			os.Args[0], strconv.FormatFloat(cpuPercent, 'f', -1, 64), climbTime, core, ctx.Value(spec.Uid))
+		if metricsDir, ok := ctx.Value(exec.MetricsDirKey).(string); ok && metricsDir != "" {
+			args = fmt.Sprintf("%s --%s %s", args, exec.MetricsDirKey, metricsDir)
+		}
+
+		args = fmt.Sprintf("-c %s %s", core, args)
+		argsArray := strings.Split(args, " ")
+		command := osexec.CommandContext(ctx, "taskset", argsArray...)
+		command.SysProcAttr = &syscall.SysProcAttr{}
+
+		if err := command.Start(); err != nil {
+			return exec.Fail(exec.ProcessControlFailed, "cpu", "taskset", fmt.Sprintf("taskset exec failed, %v", err))
+		}
+	}
+	return spec.ReturnSuccess(ctx.Value(spec.Uid))
+}
+
 const period = int64(1000000000)
 
-func slope(ctx context.Context, cpuPercent int, climbTime int, slopePercent *float64, percpu bool, cpuIndex int) {
+func slope(ctx context.Context, cpuPercent float64, climbTime int, slopePercent *float64, percpu bool, cpuIndex int) {
 	if climbTime != 0 {
 		ticker := time.NewTicker(time.Second)
 		*slopePercent = getUsed(ctx, percpu, cpuIndex)
-		startPercent := float64(cpuPercent) - *slopePercent
+		startPercent := cpuPercent - *slopePercent
 		go func() {
 			for range ticker.C {
-				if *slopePercent < float64(cpuPercent) {
+				if *slopePercent < cpuPercent {
 					*slopePercent += startPercent / float64(climbTime)
-				} else if *slopePercent > float64(cpuPercent) {
+				} else if *slopePercent > cpuPercent {
 					*slopePercent -= startPercent / float64(climbTime)
 				}
 			}
@@ -334,16 +471,31 @@ func slope(ctx context.Context, cpuPercent int, climbTime int, slopePercent *flo
 	}
 }
 
-func getQuota(ctx context.Context, slopePercent float64, percpu bool, cpuIndex int) int64 {
+// getQuota returns the busy time in the period and the current cpu usage
+func getQuota(ctx context.Context, slopePercent, gain float64, percpu bool, cpuIndex int) (int64, float64) {
 	used := getUsed(ctx, percpu, cpuIndex)
 	log.Debugf(ctx, "cpu usage: %f , percpu: %v, cpuIndex %d", used, percpu, cpuIndex)
-	dx := (slopePercent - used) / 100
-	busy := int64(dx * float64(period))
-	return busy
+	return dutyQuota(slopePercent, used, gain), used
 }
 
-func burn(ctx context.Context, quota <-chan int64, slopePercent float64, percpu bool, cpuIndex int) {
-	q := getQuota(ctx, slopePercent, percpu, cpuIndex)
+// dutyQuota returns the change of the busy time of a worker in the period, the gain is the cores measured
+// per worker
+func dutyQuota(slopePercent, used, gain float64) int64 {
+	dx := (slopePercent - used) / 100 * gain
+	return int64(dx * float64(period))
+}
+
+// burn is a worker which is busy for the quota in every period, it's moved to a random core at the jittered
+// intervals of the migration if it's not nil
+func burn(ctx context.Context, quota <-chan int64, slopePercent, gain float64, percpu bool, cpuIndex int, worker int, m *migration) {
+	var moveAt time.Time
+	if m != nil {
+		// the affinity is of the thread, so the worker keeps its thread
+		runtime.LockOSThread()
+		m.move(ctx, worker)
+		moveAt = time.Now().Add(m.next())
+	}
+	q, _ := getQuota(ctx, slopePercent, gain, percpu, cpuIndex)
 	ds := period - q
 	if ds < 0 {
 		ds = 0
@@ -356,6 +508,9 @@ func burn(ctx context.Context, quota <-chan int64, slopePercent float64, percpu
 			q = q + offset
 			if q < 0 {
 				q = 0
+			} else if q > period {
+				// the large gain of the fewer workers shouldn't wind up beyond the whole period
+				q = period
 			}
 			ds := period - q
 			if ds < 0 {
@@ -367,12 +522,138 @@ func burn(ctx context.Context, quota <-chan int64, slopePercent float64, percpu
 			}
 			runtime.Gosched()
 			time.Sleep(s)
+			if m != nil && time.Now().After(moveAt) {
+				m.move(ctx, worker)
+				moveAt = time.Now().Add(m.next())
+			}
+		}
+	}
+}
+
+// usageWindowSize is the samples of the rolling averages, the usage is sampled about every second
+const usageWindowSize = 30
+
+// degradedGap is the percentage points which the achieved usage is below the target for the whole window,
+// then the burn is degraded, such as throttled by the cpu quota of the container
+const degradedGap = 20
+
+// usageWindow is the rolling averages of the achieved and the target usage
+type usageWindow struct {
+	size     int
+	achieved []float64
+	target   []float64
+}
+
+func newUsageWindow(size int) *usageWindow {
+	return &usageWindow{size: size}
+}
+
+func (w *usageWindow) add(achieved, target float64) {
+	w.achieved = append(w.achieved, achieved)
+	w.target = append(w.target, target)
+	if len(w.achieved) > w.size {
+		w.achieved, w.target = w.achieved[1:], w.target[1:]
+	}
+}
+
+func (w *usageWindow) averages() (float64, float64) {
+	if len(w.achieved) == 0 {
+		return 0, 0
+	}
+	var achieved, target float64
+	for i := range w.achieved {
+		achieved += w.achieved[i]
+		target += w.target[i]
+	}
+	return achieved / float64(len(w.achieved)), target / float64(len(w.target))
+}
+
+// the states of the burn assessed by the metrics
+const (
+	burnEffective = "effective"
+	burnDegraded  = "degraded"
+	burnUnknown   = "unknown"
+)
+
+// burnResult is the achieved usage of the burn against the target, the throttled periods are reported in the
+// cgroup mode only
+type burnResult struct {
+	Uid             string   `json:"uid"`
+	TargetPercent   float64  `json:"targetPercent"`
+	AchievedPercent float64  `json:"achievedPercent"`
+	NrThrottled     *float64 `json:"nrThrottled,omitempty"`
+	Status          string   `json:"status"`
+	Reason          string   `json:"reason,omitempty"`
+}
+
+// assessBurn averages the rolling usage of the burning processes, the burn is degraded if the achieved usage is
+// far below the target for the whole window of every process
+func assessBurn(uid string, snapshots []exec.MetricsSnapshot) burnResult {
+	result := burnResult{Uid: uid, Status: burnUnknown}
+	count, sustained := 0, true
+	for _, snapshot := range snapshots {
+		achieved, ok := snapshot.Values["avg_cpu_percent"]
+		if !ok {
+			continue
+		}
+		count++
+		result.AchievedPercent += achieved
+		result.TargetPercent += snapshot.Values["avg_target_cpu_percent"]
+		if snapshot.Values["avg_samples"] < usageWindowSize {
+			sustained = false
 		}
+		if throttled, ok := snapshot.Values["nr_throttled"]; ok {
+			if result.NrThrottled == nil {
+				result.NrThrottled = new(float64)
+			}
+			*result.NrThrottled += throttled
+		}
+	}
+	if count == 0 {
+		return result
 	}
+	result.AchievedPercent /= float64(count)
+	result.TargetPercent /= float64(count)
+	result.Status = burnEffective
+	if gap := result.TargetPercent - result.AchievedPercent; sustained && gap > degradedGap {
+		result.Status = burnDegraded
+		result.Reason = fmt.Sprintf("the achieved cpu usage %.1f%% is %.1f points below the target %.1f%% in the last %d samples",
+			result.AchievedPercent, gap, result.TargetPercent, usageWindowSize)
+		if result.NrThrottled != nil && *result.NrThrottled > 0 {
+			result.Reason = fmt.Sprintf("%s, the cgroup is throttled %.0f times", result.Reason, *result.NrThrottled)
+		}
+	}
+	return result
 }
 
-// stop burn cpu
+// stop burn cpu, the achieved usage is reported by the metrics which are removed with the processes
 func (ce *cpuExecutor) stop(ctx context.Context) *spec.Response {
+	uid, _ := ctx.Value(spec.Uid).(string)
+	result := assessBurn(uid, exec.LoadMetrics(uid))
 	ctx = context.WithValue(ctx, "bin", BurnCpuBin)
-	return exec.Destroy(ctx, ce.channel, "cpu fullload")
+	response := exec.Destroy(ctx, ce.channel, "cpu fullload")
+	if response.Success && result.Status != burnUnknown {
+		return spec.ReturnSuccess(result)
+	}
+	return response
+}
+
+// Status checks the burning process of the experiment, and the achieved usage against the target
+func (ce *cpuExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	report.Add(exec.ProcessArtifact(ctx, uid, model.Target, model.ActionName))
+	report.AddMetrics()
+	result := assessBurn(uid, report.Metrics)
+	if result.Status == burnUnknown {
+		return report
+	}
+	detail := fmt.Sprintf("target %.1f%%, achieved %.1f%%", result.TargetPercent, result.AchievedPercent)
+	if result.NrThrottled != nil {
+		detail = fmt.Sprintf("%s, throttled %.0f times", detail, *result.NrThrottled)
+	}
+	report.Add(exec.Artifact{Kind: "cpu", Name: "usage", Present: true, Detail: detail})
+	if result.Status == burnDegraded {
+		report.Degrade(result.Reason)
+	}
+	return report
 }
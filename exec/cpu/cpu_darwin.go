@@ -18,6 +18,7 @@ package cpu
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
@@ -37,3 +38,19 @@ func getUsed(ctx context.Context, percpu bool, cpuIndex int) float64 {
 	}
 	return totalCpuPercent[0]
 }
+
+// getThrottled returns false, the burn is throttled by the cgroup on Linux only
+func getThrottled(ctx context.Context) (uint64, bool) {
+	return 0, false
+}
+
+// migrationSupported is false, the threads can't be pinned to the cores by sched_setaffinity on darwin
+const migrationSupported = false
+
+func allowedCpus() ([]int, error) {
+	return nil, fmt.Errorf("the core migration is only supported on Linux")
+}
+
+func pinThread(cpu int) error {
+	return fmt.Errorf("the core migration is only supported on Linux")
+}
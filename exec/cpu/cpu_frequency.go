@@ -0,0 +1,348 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const cpuSysfsRoot = "/sys/devices/system/cpu"
+
+type FrequencyActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewFrequencyActionCommandSpec() spec.ExpActionCommandSpec {
+	return &FrequencyActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "governor",
+					Desc: "The cpufreq governor, for example: powersave, it must be one of the scaling_available_governors",
+				},
+				&spec.ExpFlag{
+					Name: "max-freq",
+					Desc: "The max frequency in kHz, it must be between cpuinfo_min_freq and cpuinfo_max_freq",
+				},
+			},
+			ActionExecutor: &frequencyExecutor{},
+			ActionExample: `
+# Switch the governor of all cores to powersave
+blade create cpu frequency --governor powersave
+
+# Limit the max frequency of the cores 0-3 to 800MHz
+blade create cpu frequency --max-freq 800000 --cpu-list 0-3`,
+			ActionCategories: []string{category.SystemCpu},
+		},
+	}
+}
+
+func (*FrequencyActionCommandSpec) Name() string {
+	return "frequency"
+}
+
+func (*FrequencyActionCommandSpec) Aliases() []string {
+	return []string{"freq"}
+}
+
+func (*FrequencyActionCommandSpec) ShortDesc() string {
+	return "Change cpu frequency"
+}
+
+func (f *FrequencyActionCommandSpec) LongDesc() string {
+	if f.ActionLongDesc != "" {
+		return f.ActionLongDesc
+	}
+	return "Override the cpufreq scaling_governor or scaling_max_freq of all or the cores specified by cpu-list, " +
+		"the original values are restored when the experiment is destroyed. It fails if cpufreq is not supported, which is common in virtual machines"
+}
+
+type frequencyExecutor struct {
+	channel spec.Channel
+}
+
+func (*frequencyExecutor) Name() string {
+	return "frequency"
+}
+
+func (fe *frequencyExecutor) SetChannel(channel spec.Channel) {
+	fe.channel = channel
+}
+
+// cpuFrequency is the cpufreq policy of a core, the min frequency is recorded only if it is lowered
+type cpuFrequency struct {
+	Cpu      string `json:"cpu"`
+	Governor string `json:"governor,omitempty"`
+	MaxFreq  string `json:"maxFreq,omitempty"`
+	MinFreq  string `json:"minFreq,omitempty"`
+}
+
+func (fe *frequencyExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return fe.stop(ctx, uid)
+	}
+	governor := model.ActionFlags["governor"]
+	maxFreqStr := model.ActionFlags["max-freq"]
+	if governor == "" && maxFreqStr == "" {
+		log.Errorf(ctx, "less governor and max-freq")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "governor|max-freq")
+	}
+	var maxFreq int
+	if maxFreqStr != "" {
+		var err error
+		maxFreq, err = strconv.Atoi(maxFreqStr)
+		if err != nil || maxFreq <= 0 {
+			log.Errorf(ctx, "`%s`: max-freq is illegal, it must be a positive integer", maxFreqStr)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "max-freq", maxFreqStr, "it must be a positive integer")
+		}
+	}
+	cpus, response := getCpufreqCpus(ctx, model.ActionFlags["cpu-list"])
+	if response != nil {
+		return response
+	}
+	for _, cpu := range cpus {
+		if response := checkCpuFrequency(ctx, cpu, governor, maxFreq); response != nil {
+			return response
+		}
+	}
+	return fe.start(ctx, uid, cpus, governor, maxFreq)
+}
+
+func (fe *frequencyExecutor) start(ctx context.Context, uid string, cpus []string, governor string, maxFreq int) *spec.Response {
+	stateFile := getFrequencyStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the cpu frequency state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	// all the original values are recorded before any change, so that the cores changed are restored if one fails
+	state := make([]cpuFrequency, 0, len(cpus))
+	for _, cpu := range cpus {
+		original := cpuFrequency{Cpu: cpu}
+		if governor != "" {
+			original.Governor, _ = readCpufreq(cpu, "scaling_governor")
+		}
+		if maxFreq > 0 {
+			original.MaxFreq, _ = readCpufreq(cpu, "scaling_max_freq")
+			// the max frequency cannot be lower than the min frequency, so the min frequency is lowered too
+			if minFreq, err := readCpufreq(cpu, "scaling_min_freq"); err == nil {
+				if value, err := strconv.Atoi(minFreq); err == nil && value > maxFreq {
+					original.MinFreq = minFreq
+				}
+			}
+		}
+		state = append(state, original)
+	}
+	if err := writeFrequencyState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write the cpu frequency state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "cpufreq", "writeFrequencyState", err)
+	}
+	for _, original := range state {
+		var err error
+		if governor != "" {
+			err = writeCpufreq(original.Cpu, "scaling_governor", governor)
+		}
+		if err == nil && original.MinFreq != "" {
+			err = writeCpufreq(original.Cpu, "scaling_min_freq", strconv.Itoa(maxFreq))
+		}
+		if err == nil && maxFreq > 0 {
+			err = writeCpufreq(original.Cpu, "scaling_max_freq", strconv.Itoa(maxFreq))
+		}
+		if err != nil {
+			log.Errorf(ctx, "change the frequency of cpu%s failed, %v", original.Cpu, err)
+			fe.stop(ctx, uid)
+			return exec.FailWithFlags(exec.SettingApplyFailed, "cpufreq", "writeCpufreq", err)
+		}
+		log.Infof(ctx, "cpu%s frequency changed, governor: %s, max-freq: %d", original.Cpu, governor, maxFreq)
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (fe *frequencyExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	stateFile := getFrequencyStateFile(uid)
+	state, err := readFrequencyState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warnf(ctx, "`%s`: the cpu frequency state file not found, the experiment may be destroyed", stateFile)
+			return spec.ReturnSuccess(uid)
+		}
+		log.Errorf(ctx, "read the cpu frequency state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "cpufreq", "readFrequencyState", err)
+	}
+	failed := make([]string, 0)
+	for _, original := range state {
+		// the max frequency is raised before the min frequency, so that the min frequency is never above the max
+		files := []struct{ name, value string }{
+			{"scaling_max_freq", original.MaxFreq},
+			{"scaling_min_freq", original.MinFreq},
+			{"scaling_governor", original.Governor},
+		}
+		for _, file := range files {
+			if file.value == "" {
+				continue
+			}
+			if err := writeCpufreq(original.Cpu, file.name, file.value); err != nil {
+				log.Errorf(ctx, "restore %s of cpu%s to %s failed, %v", file.name, original.Cpu, file.value, err)
+				failed = append(failed, fmt.Sprintf("cpu%s/%s", original.Cpu, file.name))
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return exec.Fail(exec.RestoreFailed, "cpufreq", "restore", fmt.Sprintf("restore the cpu frequency %s failed", strings.Join(failed, ",")))
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+// getCpufreqCpus returns the cores in the cpu-list or all the online cores, they must support cpufreq
+func getCpufreqCpus(ctx context.Context, cpuListStr string) ([]string, *spec.Response) {
+	if cpuListStr != "" {
+		cpus, err := util.ParseIntegerListToStringSlice("cpu-list", cpuListStr)
+		if err != nil {
+			log.Errorf(ctx, "`%s`: cpu-list is illegal, %s", cpuListStr, err.Error())
+			return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "cpu-list", cpuListStr, err.Error())
+		}
+		for _, cpu := range cpus {
+			if _, err := os.Stat(path.Join(cpuSysfsRoot, "cpu"+cpu)); err != nil {
+				log.Errorf(ctx, "`%s`: cpu-list is illegal, cpu%s not found", cpuListStr, cpu)
+				return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "cpu-list", cpuListStr, fmt.Sprintf("cpu%s not found", cpu))
+			}
+			if response := checkCpufreqSupported(ctx, cpu); response != nil {
+				return nil, response
+			}
+		}
+		return cpus, nil
+	}
+	dirs, _ := filepath.Glob(path.Join(cpuSysfsRoot, "cpu[0-9]*"))
+	cpus := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		cpu := strings.TrimPrefix(path.Base(dir), "cpu")
+		if _, err := os.Stat(path.Join(dir, "cpufreq")); err != nil {
+			// the offline cores have no cpufreq directory
+			log.Warnf(ctx, "cpu%s has no cpufreq directory, skip it", cpu)
+			continue
+		}
+		cpus = append(cpus, cpu)
+	}
+	if len(cpus) == 0 {
+		return nil, checkCpufreqSupported(ctx, "0")
+	}
+	sort.Slice(cpus, func(i, j int) bool {
+		left, _ := strconv.Atoi(cpus[i])
+		right, _ := strconv.Atoi(cpus[j])
+		return left < right
+	})
+	return cpus, nil
+}
+
+// checkCpufreqSupported fails with a specific error if the core has no cpufreq driver
+func checkCpufreqSupported(ctx context.Context, cpu string) *spec.Response {
+	cpufreqDir := path.Join(cpuSysfsRoot, "cpu"+cpu, "cpufreq")
+	if _, err := os.Stat(path.Join(cpufreqDir, "scaling_governor")); err == nil {
+		return nil
+	}
+	log.Errorf(ctx, "`%s`: cpufreq is not supported", cpufreqDir)
+	return spec.ReturnFail(spec.FileNotExist, fmt.Sprintf("`%s`: cpufreq is not supported, "+
+		"the cpu frequency scaling driver is not loaded, it's common in virtual machines", cpufreqDir))
+}
+
+// checkCpuFrequency checks the governor and the max frequency are available for the core
+func checkCpuFrequency(ctx context.Context, cpu, governor string, maxFreq int) *spec.Response {
+	if governor != "" {
+		if available, err := readCpufreq(cpu, "scaling_available_governors"); err == nil {
+			if !containsField(available, governor) {
+				log.Errorf(ctx, "`%s`: governor is invalid, the available governors of cpu%s are %s", governor, cpu, available)
+				return spec.ResponseFailWithFlags(spec.ParameterInvalid, "governor", governor,
+					fmt.Sprintf("the available governors of cpu%s are %s", cpu, available))
+			}
+		}
+	}
+	if maxFreq > 0 {
+		minLimit, minErr := readCpufreq(cpu, "cpuinfo_min_freq")
+		maxLimit, maxErr := readCpufreq(cpu, "cpuinfo_max_freq")
+		if minErr == nil && maxErr == nil {
+			minValue, _ := strconv.Atoi(minLimit)
+			maxValue, _ := strconv.Atoi(maxLimit)
+			if maxFreq < minValue || maxFreq > maxValue {
+				log.Errorf(ctx, "`%d`: max-freq is invalid, the frequency range of cpu%s is %s-%s", maxFreq, cpu, minLimit, maxLimit)
+				return spec.ResponseFailWithFlags(spec.ParameterInvalid, "max-freq", maxFreq,
+					fmt.Sprintf("the frequency range of cpu%s is %s-%s kHz", cpu, minLimit, maxLimit))
+			}
+		}
+	}
+	return nil
+}
+
+func containsField(fields, field string) bool {
+	for _, item := range strings.Fields(fields) {
+		if item == field {
+			return true
+		}
+	}
+	return false
+}
+
+func readCpufreq(cpu, name string) (string, error) {
+	bytes, err := os.ReadFile(path.Join(cpuSysfsRoot, "cpu"+cpu, "cpufreq", name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bytes)), nil
+}
+
+func writeCpufreq(cpu, name, value string) error {
+	return os.WriteFile(path.Join(cpuSysfsRoot, "cpu"+cpu, "cpufreq", name), []byte(value), 0644)
+}
+
+func getFrequencyStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-cpu-frequency-%s.json", uid)
+}
+
+func writeFrequencyState(stateFile string, state []cpuFrequency) error {
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, bytes, 0600)
+}
+
+func readFrequencyState(stateFile string) ([]cpuFrequency, error) {
+	bytes, err := os.ReadFile(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	var state []cpuFrequency
+	if err := json.Unmarshal(bytes, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
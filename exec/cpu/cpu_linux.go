@@ -19,16 +19,14 @@ package cpu
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/channel"
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	containerdCgroups "github.com/containerd/cgroups"
 	"github.com/shirou/gopsutil/cpu"
+	"golang.org/x/sys/unix"
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
@@ -37,101 +35,27 @@ import (
 // getCGroupV2CPUUsage 获取 cgroup v2 环境下的 CPU 使用率
 func getCGroupV2CPUUsage(ctx context.Context, cgroupPath string, cpuCount int) (float64, error) {
 	// 读取 cpu.stat 文件获取 CPU 使用统计
-	cpuStatFile := filepath.Join(cgroupPath, "cpu.stat")
-	content, err := os.ReadFile(cpuStatFile)
+	cg := cgroups.NewCGroupV2Impl(cgroupPath)
+	first, err := cg.CPUStat()
 	if err != nil {
 		return 0, fmt.Errorf("failed to read cpu.stat file: %v", err)
 	}
 
-	// 解析 cpu.stat 文件内容
-	lines := strings.Split(string(content), "\n")
-	var usageUsec int64
-	var userUsec int64
-	var systemUsec int64
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		parts := strings.Fields(line)
-		if len(parts) != 2 {
-			continue
-		}
-
-		value, err := strconv.ParseInt(parts[1], 10, 64)
-		if err != nil {
-			continue
-		}
-
-		switch parts[0] {
-		case "usage_usec":
-			usageUsec = value
-		case "user_usec":
-			userUsec = value
-		case "system_usec":
-			systemUsec = value
-		}
-	}
-
-	// 计算第一次的 CPU 使用时间（微秒）
-	firstTotal := usageUsec
-	if firstTotal == 0 {
-		// 如果没有 usage_usec，使用 user_usec + system_usec
-		firstTotal = userUsec + systemUsec
-	}
-
 	// 等待 1 秒
 	time.Sleep(time.Second)
 
 	// 再次读取 cpu.stat 文件
-	content, err = os.ReadFile(cpuStatFile)
+	second, err := cg.CPUStat()
 	if err != nil {
 		return 0, fmt.Errorf("failed to read cpu.stat file again: %v", err)
 	}
 
-	// 重新解析
-	lines = strings.Split(string(content), "\n")
-	usageUsec = 0
-	userUsec = 0
-	systemUsec = 0
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		parts := strings.Fields(line)
-		if len(parts) != 2 {
-			continue
-		}
-
-		value, err := strconv.ParseInt(parts[1], 10, 64)
-		if err != nil {
-			continue
-		}
-
-		switch parts[0] {
-		case "usage_usec":
-			usageUsec = value
-		case "user_usec":
-			userUsec = value
-		case "system_usec":
-			systemUsec = value
-		}
-	}
-
-	// 计算第二次的 CPU 使用时间（微秒）
-	secondTotal := usageUsec
-	if secondTotal == 0 {
-		secondTotal = userUsec + systemUsec
-	}
+	// 计算 CPU 使用时间（微秒），没有 usage_usec 时使用 user_usec + system_usec
+	firstTotal, secondTotal := cpuStatUsage(first), cpuStatUsage(second)
 
 	// 计算 CPU 使用率
 	// 时间差（微秒）转换为秒，然后除以 CPU 核心数
-	timeDiff := float64(secondTotal-firstTotal) / 1000000.0 // 转换为秒
+	timeDiff := (float64(secondTotal) - float64(firstTotal)) / 1000000.0 // 转换为秒
 	cpuUsage := (timeDiff * 100.0) / float64(cpuCount)
 
 	log.Debugf(ctx, "cgroup v2 cpu usage: first=%d, second=%d, diff=%f, cpuCount=%d, usage=%f%%",
@@ -140,6 +64,13 @@ func getCGroupV2CPUUsage(ctx context.Context, cgroupPath string, cpuCount int) (
 	return cpuUsage, nil
 }
 
+func cpuStatUsage(stat *cgroups.CPUStat) uint64 {
+	if stat.UsageUsec == 0 {
+		return stat.UserUsec + stat.SystemUsec
+	}
+	return stat.UsageUsec
+}
+
 func getUsed(ctx context.Context, percpu bool, cpuIndex int) float64 {
 	pid := ctx.Value(channel.NSTargetFlagName)
 	cpuCount := ctx.Value("cpuCount").(int)
@@ -150,16 +81,18 @@ func getUsed(ctx context.Context, percpu bool, cpuIndex int) float64 {
 			log.Fatalf(ctx, "get cpu usage fail, %s", err.Error())
 		}
 
-		cgroupRoot := ctx.Value("cgroup-root")
-		if cgroupRoot == "" {
-			cgroupRoot = "/sys/fs/cgroup"
-		}
+		cgroupRoot, _ := ctx.Value("cgroup-root").(string)
+		cgroupRoot = cgroups.ResolveCGroupRoot(ctx, cgroupRoot)
 
 		log.Debugf(ctx, "get cpu usage by cgroup, root path: %s", cgroupRoot)
 
-		// 首先尝试 cgroup v2
-		cgroupPath, err := cgroups.FindCGroupV2Path(ctx, strconv.Itoa(p), cgroupRoot.(string))
+		// 首先尝试 cgroup v2, hybrid 模式下仅当 cpu 控制器在 unified 层级时使用
+		var cgroupPath string
+		if cgroups.IsControllerV2(ctx, cgroupRoot, "cpu") {
+			cgroupPath, err = cgroups.FindCGroupV2Path(ctx, strconv.Itoa(p), cgroupRoot)
+		}
 		if err == nil && cgroupPath != "" {
+			cgroupPath = cgroups.ScopedCGroupV2Path(ctx, cgroupPath, cgroups.CGroupV2CPUController)
 			log.Debugf(ctx, "using cgroup v2 path: %s", cgroupPath)
 			cpuUsage, err := getCGroupV2CPUUsage(ctx, cgroupPath, cpuCount)
 			if err != nil {
@@ -172,7 +105,7 @@ func getUsed(ctx context.Context, percpu bool, cpuIndex int) float64 {
 		}
 
 		// 回退到 cgroup v1
-		cgroup, err := containerdCgroups.Load(exec.Hierarchy(cgroupRoot.(string)), exec.PidPath(p))
+		cgroup, err := containerdCgroups.Load(exec.Hierarchy(cgroupRoot), exec.ScopedPidPath(ctx, cgroupRoot, p))
 		if err != nil {
 			log.Fatalf(ctx, "get cpu usage fail, %s", err.Error())
 		}
@@ -193,6 +126,13 @@ func getUsed(ctx context.Context, percpu bool, cpuIndex int) float64 {
 		}
 	}
 
+	if cpus, ok := ctx.Value("cpuSet").([]int); ok && len(cpus) > 0 {
+		percents, err := cpu.Percent(time.Second, true)
+		if err != nil {
+			log.Fatalf(ctx, "get cpu usage fail, %s", err.Error())
+		}
+		return averageUsage(percents, cpus)
+	}
 	totalCpuPercent, err := cpu.Percent(time.Second, percpu)
 	if err != nil {
 		log.Fatalf(ctx, "get cpu usage fail, %s", err.Error())
@@ -205,3 +145,76 @@ func getUsed(ctx context.Context, percpu bool, cpuIndex int) float64 {
 	}
 	return totalCpuPercent[0]
 }
+
+// getThrottled returns the throttled periods of the cgroup of the target, it returns false without the target
+// or if the cgroup can't be read, then the burn isn't in the cgroup mode
+func getThrottled(ctx context.Context) (uint64, bool) {
+	pid, _ := ctx.Value(channel.NSTargetFlagName).(string)
+	if pid == "" {
+		return 0, false
+	}
+	cgroupRoot, _ := ctx.Value("cgroup-root").(string)
+	cgroupRoot = cgroups.ResolveCGroupRoot(ctx, cgroupRoot)
+	if cgroups.IsControllerV2(ctx, cgroupRoot, "cpu") {
+		if cgroupPath, err := cgroups.FindCGroupV2Path(ctx, pid, cgroupRoot); err == nil && cgroupPath != "" {
+			cgroupPath = cgroups.ScopedCGroupV2Path(ctx, cgroupPath, cgroups.CGroupV2CPUController)
+			if stat, err := cgroups.NewCGroupV2Impl(cgroupPath).CPUStat(); err == nil {
+				return stat.NrThrottled, true
+			}
+		}
+	}
+	p, err := strconv.Atoi(pid)
+	if err != nil {
+		return 0, false
+	}
+	cgroup, err := containerdCgroups.Load(exec.Hierarchy(cgroupRoot), exec.ScopedPidPath(ctx, cgroupRoot, p))
+	if err != nil {
+		log.Debugf(ctx, "load the cgroup of %d failed, the throttling isn't reported, %v", p, err)
+		return 0, false
+	}
+	stats, err := cgroup.Stat(containerdCgroups.IgnoreNotExist)
+	if err != nil || stats.CPU == nil || stats.CPU.Throttling == nil {
+		return 0, false
+	}
+	return stats.CPU.Throttling.ThrottledPeriods, true
+}
+
+// averageUsage returns the average usage of the cores, the cores which aren't online are ignored
+func averageUsage(percents []float64, cpus []int) float64 {
+	var sum float64
+	var count int
+	for _, cpu := range cpus {
+		if cpu >= 0 && cpu < len(percents) {
+			sum += percents[cpu]
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+const migrationSupported = true
+
+// allowedCpus returns the cores in the affinity of the process, which is limited by the cpuset
+func allowedCpus() ([]int, error) {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		return nil, err
+	}
+	cpus := make([]int, 0, set.Count())
+	for cpu := 0; len(cpus) < set.Count(); cpu++ {
+		if set.IsSet(cpu) {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// pinThread sets the affinity of the calling thread to the core
+func pinThread(cpu int) error {
+	var set unix.CPUSet
+	set.Set(cpu)
+	return unix.SchedSetaffinity(0, &set)
+}
@@ -0,0 +1,67 @@
+//go:build linux
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cpu
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestAverageUsage(t *testing.T) {
+	percents := []float64{10, 20, 30, 40}
+	if usage := averageUsage(percents, []int{1, 3}); usage != 30 {
+		t.Errorf("expected 30, got %v", usage)
+	}
+	// the offline core is ignored
+	if usage := averageUsage(percents, []int{0, 8}); usage != 10 {
+		t.Errorf("expected 10, got %v", usage)
+	}
+	if usage := averageUsage(percents, []int{8}); usage != 0 {
+		t.Errorf("expected 0, got %v", usage)
+	}
+}
+
+func TestMigrationMove(t *testing.T) {
+	cpus, err := allowedCpus()
+	if err != nil || len(cpus) == 0 {
+		t.Fatalf("get the allowed cores failed, %v %v", cpus, err)
+	}
+	done := make(chan struct{})
+	m := newMigration(time.Second, cpus[:1], 1)
+	go func() {
+		defer close(done)
+		// the thread isn't returned to the pool, its affinity is changed
+		runtime.LockOSThread()
+		m.move(context.Background(), 0)
+		var set unix.CPUSet
+		if err := unix.SchedGetaffinity(0, &set); err != nil || set.Count() != 1 || !set.IsSet(cpus[0]) {
+			t.Errorf("expected the thread pinned to cpu%d, got %v %v", cpus[0], set, err)
+		}
+		// neither the first pin nor the pin to the same core is a move
+		m.move(context.Background(), 0)
+	}()
+	<-done
+	if m.assigned[0] != int32(cpus[0]) || m.moves != 0 {
+		t.Errorf("expected the worker on cpu%d without the moves, got cpu%d and %d moves", cpus[0], m.assigned[0], m.moves)
+	}
+}
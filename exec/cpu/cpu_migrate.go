@@ -0,0 +1,77 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cpu
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// migration moves the burn workers to the random cores of the allowed ones, so the load doesn't stay on the
+// same cores which the scheduler adapts to
+type migration struct {
+	interval time.Duration
+	cpus     []int
+	// assigned is the current core of each worker, -1 before the worker is pinned
+	assigned []int32
+	moves    int64
+}
+
+func newMigration(interval time.Duration, cpus []int, workers int) *migration {
+	assigned := make([]int32, workers)
+	for i := range assigned {
+		assigned[i] = -1
+	}
+	return &migration{interval: interval, cpus: cpus, assigned: assigned}
+}
+
+// next returns the interval until the next move, it's jittered by ±50%
+func (m *migration) next() time.Duration {
+	return jitter(m.interval, rand.Float64())
+}
+
+// jitter scales the interval to [0.5, 1.5) of it by r in [0, 1)
+func jitter(interval time.Duration, r float64) time.Duration {
+	return time.Duration(float64(interval) * (0.5 + r))
+}
+
+// move pins the thread of the worker to a random allowed core, the worker must be locked to its thread
+func (m *migration) move(ctx context.Context, worker int) {
+	cpu := m.cpus[rand.Intn(len(m.cpus))]
+	if err := pinThread(cpu); err != nil {
+		log.Warnf(ctx, "move the burn worker %d to cpu%d failed, %v", worker, cpu, err)
+		return
+	}
+	if previous := atomic.SwapInt32(&m.assigned[worker], int32(cpu)); previous != -1 && previous != int32(cpu) {
+		atomic.AddInt64(&m.moves, 1)
+	}
+}
+
+// setMetrics writes the current core of each worker and the moves to the other cores
+func (m *migration) setMetrics(metrics *exec.MetricsWriter) {
+	for i := range m.assigned {
+		metrics.Set(fmt.Sprintf("worker%d_cpu", i), float64(atomic.LoadInt32(&m.assigned[i])))
+	}
+	metrics.Set("migrations", float64(atomic.LoadInt64(&m.moves)))
+}
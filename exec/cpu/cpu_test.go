@@ -18,8 +18,11 @@ package cpu
 
 import (
 	"testing"
+	"time"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 )
 
 func TestParseCpuList(t *testing.T) {
@@ -46,3 +49,84 @@ func TestParseCpuList(t *testing.T) {
 		}
 	}
 }
+
+func TestUsageWindow(t *testing.T) {
+	window := newUsageWindow(3)
+	for _, used := range []float64{10, 20, 30, 40} {
+		window.add(used, 80)
+	}
+	achieved, target := window.averages()
+	if achieved != 30 || target != 80 {
+		t.Errorf("expected the averages of the last 3 samples, got %v and %v", achieved, target)
+	}
+}
+
+func TestAssessBurn(t *testing.T) {
+	snapshot := func(achieved, samples float64, throttled ...float64) exec.MetricsSnapshot {
+		values := map[string]float64{
+			"avg_cpu_percent":        achieved,
+			"avg_target_cpu_percent": 80,
+			"avg_samples":            samples,
+		}
+		if len(throttled) > 0 {
+			values["nr_throttled"] = throttled[0]
+		}
+		return exec.MetricsSnapshot{Values: values}
+	}
+	tests := []struct {
+		name      string
+		snapshots []exec.MetricsSnapshot
+		status    string
+		throttled bool
+	}{
+		{"no metrics", nil, burnUnknown, false},
+		{"achieved", []exec.MetricsSnapshot{snapshot(78, usageWindowSize)}, burnEffective, false},
+		{"throttled", []exec.MetricsSnapshot{snapshot(30, usageWindowSize, 120), snapshot(30, usageWindowSize, 80)}, burnDegraded, true},
+		{"warming up", []exec.MetricsSnapshot{snapshot(30, usageWindowSize), snapshot(30, 5)}, burnEffective, false},
+	}
+	for _, tt := range tests {
+		result := assessBurn("uid", tt.snapshots)
+		if result.Status != tt.status {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.status, result.Status)
+		}
+		if (result.NrThrottled != nil) != tt.throttled {
+			t.Errorf("%s: unexpected throttled %v", tt.name, result.NrThrottled)
+		}
+	}
+	result := assessBurn("uid", []exec.MetricsSnapshot{snapshot(30, usageWindowSize, 120), snapshot(30, usageWindowSize, 80)})
+	if result.TargetPercent != 80 || result.AchievedPercent != 30 || *result.NrThrottled != 200 || result.Reason == "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestDutyQuota(t *testing.T) {
+	tests := []struct {
+		name               string
+		target, used, gain float64
+		expect             int64
+	}{
+		{"a worker per core", 60, 0, 1, period * 6 / 10},
+		{"half a core of 128 cores", 0.5 / 128 * 100, 0, 128, period / 2},
+		{"over the target", 0.5, 1, 1, -period / 200},
+	}
+	for _, tt := range tests {
+		if quota := dutyQuota(tt.target, tt.used, tt.gain); quota != tt.expect {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.expect, quota)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for _, tt := range []struct {
+		r      float64
+		expect time.Duration
+	}{
+		{0, 5 * time.Second / 2},
+		{0.5, 5 * time.Second},
+		{0.99, 5*time.Second*3/2 - 50*time.Millisecond},
+	} {
+		if interval := jitter(5*time.Second, tt.r); interval != tt.expect {
+			t.Errorf("r %v: expected %v, got %v", tt.r, tt.expect, interval)
+		}
+	}
+}
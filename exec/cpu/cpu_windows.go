@@ -0,0 +1,115 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cpu
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/shirou/gopsutil/cpu"
+	"golang.org/x/sys/windows"
+)
+
+var procGetSystemTimes = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetSystemTimes")
+
+// systemTimes is the idle, kernel and user times of all the cores in 100ns, the kernel time includes the idle time
+type systemTimes struct {
+	idle   uint64
+	kernel uint64
+	user   uint64
+}
+
+// the interval between the two samples of the system times
+var sampleInterval = time.Second
+
+// getSystemTimes calls GetSystemTimes, it's replaced in the tests
+var getSystemTimes = func() (systemTimes, error) {
+	var idle, kernel, user windows.Filetime
+	ret, _, err := procGetSystemTimes.Call(
+		uintptr(unsafe.Pointer(&idle)),
+		uintptr(unsafe.Pointer(&kernel)),
+		uintptr(unsafe.Pointer(&user)),
+	)
+	if ret == 0 {
+		return systemTimes{}, err
+	}
+	return systemTimes{
+		idle:   filetimeToUint64(idle),
+		kernel: filetimeToUint64(kernel),
+		user:   filetimeToUint64(user),
+	}, nil
+}
+
+func getUsed(ctx context.Context, percpu bool, cpuIndex int) float64 {
+	if percpu {
+		// GetSystemTimes returns the times of all the cores, so the usage of a core is got by gopsutil
+		totalCpuPercent, err := cpu.Percent(sampleInterval, true)
+		if err != nil {
+			log.Fatalf(ctx, "get cpu usage fail, %s", err.Error())
+		}
+		if cpuIndex >= len(totalCpuPercent) {
+			log.Fatalf(ctx, "illegal cpu index %d", cpuIndex)
+		}
+		return totalCpuPercent[cpuIndex]
+	}
+	before, err := getSystemTimes()
+	if err != nil {
+		log.Fatalf(ctx, "get cpu usage fail, %s", err.Error())
+	}
+	time.Sleep(sampleInterval)
+	after, err := getSystemTimes()
+	if err != nil {
+		log.Fatalf(ctx, "get cpu usage fail, %s", err.Error())
+	}
+	return calculateUsage(before, after)
+}
+
+// calculateUsage returns the percent of the busy time between the two samples
+func calculateUsage(before, after systemTimes) float64 {
+	total := (after.kernel - before.kernel) + (after.user - before.user)
+	if total == 0 {
+		return 0
+	}
+	idle := after.idle - before.idle
+	if idle > total {
+		idle = total
+	}
+	return float64(total-idle) / float64(total) * 100
+}
+
+func filetimeToUint64(ft windows.Filetime) uint64 {
+	return uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime)
+}
+
+// getThrottled returns false, the burn is throttled by the cgroup on Linux only
+func getThrottled(ctx context.Context) (uint64, bool) {
+	return 0, false
+}
+
+// migrationSupported is false, the threads can't be pinned to the cores by sched_setaffinity on Windows
+const migrationSupported = false
+
+func allowedCpus() ([]int, error) {
+	return nil, fmt.Errorf("the core migration is only supported on Linux")
+}
+
+func pinThread(cpu int) error {
+	return fmt.Errorf("the core migration is only supported on Linux")
+}
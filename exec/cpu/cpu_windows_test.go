@@ -0,0 +1,56 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cpu
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCalculateUsage(t *testing.T) {
+	tests := []struct {
+		before, after systemTimes
+		expect        float64
+	}{
+		{systemTimes{0, 0, 0}, systemTimes{0, 0, 0}, 0},
+		{systemTimes{100, 200, 100}, systemTimes{200, 400, 100}, 50},
+		{systemTimes{0, 0, 0}, systemTimes{0, 100, 300}, 100},
+		{systemTimes{0, 0, 0}, systemTimes{400, 400, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := calculateUsage(tt.before, tt.after); got != tt.expect {
+			t.Errorf("unexpected usage: %f, expected: %f", got, tt.expect)
+		}
+	}
+}
+
+func TestGetUsedBySystemTimes(t *testing.T) {
+	samples := []systemTimes{{1000, 2000, 1000}, {1750, 3000, 1000}}
+	original, originalInterval := getSystemTimes, sampleInterval
+	defer func() {
+		getSystemTimes, sampleInterval = original, originalInterval
+	}()
+	getSystemTimes = func() (systemTimes, error) {
+		sample := samples[0]
+		samples = samples[1:]
+		return sample, nil
+	}
+	sampleInterval = 0
+	if got := getUsed(context.Background(), false, 0); got != 25 {
+		t.Errorf("unexpected usage: %f, expected: 25", got)
+	}
+}
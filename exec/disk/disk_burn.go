@@ -19,16 +19,15 @@ package disk
 import (
 	"context"
 	"fmt"
-	"path"
-	"strings"
+	"strconv"
 
-	"github.com/chaosblade-io/chaosblade-spec-go/channel"
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
 )
 
 const BurnIOBin = "chaos_burnio"
@@ -53,21 +52,37 @@ func NewBurnActionSpec() spec.ExpActionCommandSpec {
 				},
 				&spec.ExpFlag{
 					Name:     "cgroup-root",
-					Desc:     "cgroup root path, default value /sys/fs/cgroup",
+					Desc:     "cgroup root path, it's detected from the cgroup mounts if absent",
 					NoArgs:   false,
 					Required: false,
-					Default:  "/sys/fs/cgroup",
+					Default:  "",
 				},
 			},
 			ActionFlags: []spec.ExpFlagSpec{
 				&spec.ExpFlag{
 					Name: "size",
-					Desc: "Block size, MB, default is 10",
+					Desc: "Block size, MB, default is 10. The unit K, M, G or T is supported, for example, --size 1G",
 				},
 				&spec.ExpFlag{
 					Name: "path",
 					Desc: "The path of directory where the disk is burning, default value is /",
 				},
+				&spec.ExpFlag{
+					Name: engineFlag,
+					Desc: "The io generator, auto, native or fio. The auto uses fio if it's available, otherwise the native one, default value is auto",
+				},
+				&spec.ExpFlag{
+					Name: iodepthFlag,
+					Desc: fmt.Sprintf("The io depth of fio, default value is %d, only support for fio engine", defaultIODepth),
+				},
+				&spec.ExpFlag{
+					Name: rwmixReadFlag,
+					Desc: fmt.Sprintf("The percent of the reads if both read and write are burned, default value is %d, only support for fio engine", defaultRWMixRead),
+				},
+				&spec.ExpFlag{
+					Name: ioengineFlag,
+					Desc: "The ioengine of fio, such as libaio, io_uring or psync, default value is libaio on Linux and posixaio on the others, only support for fio engine",
+				},
 			},
 			ActionExecutor: &BurnIOExecutor{},
 			ActionExample: `
@@ -78,7 +93,10 @@ blade create disk burn --read --path /home
 blade create disk burn --write --path /home
 
 # Read and write IO load scenarios are performed at the same time. Path is not specified. The default is /
-blade create disk burn --read --write`,
+blade create disk burn --read --write
+
+# Random read and write by fio with io_uring, 70% of the io are reads
+blade create disk burn --read --write --path /home --engine fio --ioengine io_uring --iodepth 32 --rwmix-read 70`,
 			ActionPrograms:    []string{BurnIOBin},
 			ActionCategories:  []string{category.SystemDisk},
 			ActionProcessHang: true,
@@ -113,17 +131,13 @@ func (*BurnIOExecutor) Name() string {
 	return "burn"
 }
 
-var localChannel = channel.NewLocalChannel()
-
 func (be *BurnIOExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
-	commands := []string{"rm", "dd"}
-	// use local channel
-	if response, ok := localChannel.IsAllCommandsAvailable(ctx, commands); !ok {
+	if response, ok := checkBurnCommands(ctx); !ok {
 		return response
 	}
 	directory := model.ActionFlags["path"]
 	if directory == "" {
-		directory = "/"
+		directory = defaultDirectory
 	}
 	if _, ok := spec.IsDestroy(ctx); ok {
 		readExists := model.ActionFlags["read"] == "true"
@@ -133,7 +147,11 @@ func (be *BurnIOExecutor) Exec(uid string, ctx context.Context, model *spec.ExpM
 			readExists = true
 			writeExists = true
 		}
-		return be.stop(ctx, readExists, writeExists, directory)
+		response := be.stop(ctx, uid, readExists, writeExists, directory)
+		if response.Success {
+			exec.ReleaseResources(ctx, uid)
+		}
+		return response
 	}
 	if !util.IsDir(directory) {
 		log.Errorf(ctx, "`%s`: path is illegal, is not a directory", directory)
@@ -145,101 +163,119 @@ func (be *BurnIOExecutor) Exec(uid string, ctx context.Context, model *spec.ExpM
 		log.Errorf(ctx, "less params, read|write")
 		return spec.ResponseFailWithFlags(spec.ParameterLess, "read|write")
 	}
+	if mount, readOnly := readOnlyMount(ctx, be.channel, directory); readOnly {
+		if writeExists {
+			return readOnlyResponse(ctx, mount, directory)
+		}
+		// the read burn reads the existing file or the device instead of creating the file
+		source, response := readOnlySource(ctx, mount, directory)
+		if response != nil {
+			return response
+		}
+		log.Infof(ctx, "%s is on the read-only filesystem %s, burn io by reading %s", directory, mount.MountPoint, source)
+		ctx = context.WithValue(ctx, readSourceKey{}, source)
+	}
 	size := model.ActionFlags["size"]
 	if size == "" {
 		size = "10"
 	}
-	return be.start(ctx, readExists, writeExists, directory, size)
-}
-
-func (be *BurnIOExecutor) start(ctx context.Context, read, write bool, directory, size string) *spec.Response {
-	if read {
-		go burnRead(ctx, directory, size, be.channel)
+	bytes, response := validation.ValidateSizeBytes("size", size, validation.MB, validation.MB)
+	if response != nil {
+		log.Errorf(ctx, "`%s`: size is illegal, %s", size, response.Err)
+		return response
 	}
-	if write {
-		go burnWrite(ctx, directory, size, be.channel)
+	engine, response := chooseEngine(ctx, be.channel, model.ActionFlags[engineFlag])
+	if response != nil {
+		return response
 	}
-	select {}
-}
-
-func (be *BurnIOExecutor) stop(ctx context.Context, read, write bool, directory string) *spec.Response {
-	if read {
-		resp := localChannel.Run(ctx, "rm", fmt.Sprintf("-rf %s*", path.Join(directory, readFile)))
-		if !resp.Success {
-			log.Errorf(ctx, "clean read file: %s", resp.Err)
+	if engine == engineFio {
+		job, response := newFioJob(model.ActionFlags, readExists, writeExists, bytes/validation.MB)
+		if response != nil {
+			return response
 		}
+		ctx = context.WithValue(ctx, fioJobKey{}, job)
 	}
-	if write {
-		resp := localChannel.Run(ctx, "rm", fmt.Sprintf("-rf %s*", path.Join(directory, writeFile)))
-		if !resp.Success {
-			log.Errorf(ctx, "clean write file: %s", resp.Err)
-		}
+	// the burns and the fills of the same filesystem change the io and the space seen by each other
+	state, response := exec.ClaimResources(ctx, be.channel, uid, model.Target, model.ActionName, model.ActionFlags,
+		exec.FilesystemResource(mountPoint(directory)))
+	if response != nil {
+		return response
+	}
+	if err := state.SetDetail(engineFlag, engine); err != nil {
+		log.Warnf(ctx, "record the engine %s of %s failed, %v", engine, uid, err)
+	}
+	log.Infof(ctx, "burn io of %s by the %s engine", directory, engine)
+	response = be.start(ctx, uid, readExists, writeExists, directory, strconv.FormatInt(bytes/validation.MB, 10))
+	if !response.Success {
+		exec.ReleaseResources(ctx, uid)
 	}
-	ctx = context.WithValue(ctx, "bin", BurnIOBin)
-	return exec.Destroy(ctx, be.channel, "disk burn")
+	return response
 }
 
 func (be *BurnIOExecutor) SetChannel(channel spec.Channel) {
 	be.channel = channel
 }
 
-var (
-	readFile  = "chaos_burnio.read"
-	writeFile = "chaos_burnio.write"
+// Status checks the burning process and attaches its io metrics
+func (be *BurnIOExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	report.Add(exec.ProcessArtifact(ctx, uid, model.Target, model.ActionName))
+	if state != nil && state.Details[engineFlag] != "" {
+		report.Add(exec.Artifact{Kind: engineFlag, Name: state.Details[engineFlag], Present: true})
+	}
+	report.AddMetrics()
+	return report
+}
+
+// the flags of the io generator
+const (
+	engineFlag    = "engine"
+	iodepthFlag   = "iodepth"
+	rwmixReadFlag = "rwmix-read"
+	ioengineFlag  = "ioengine"
 )
 
-const count = 100
+// the io generators of the burn, the native one is dd on Linux and macOS and the file operations on Windows
+const (
+	engineAuto   = "auto"
+	engineNative = "native"
+	engineFio    = "fio"
+)
 
-// write burn
-func burnWrite(ctx context.Context, directory, size string, cl spec.Channel) {
-	tmpFileForWrite := path.Join(directory, writeFile)
-	_, _, ddRunningWriteArg := getArgs(ctx, localChannel)
-	for {
-		args := fmt.Sprintf(ddRunningWriteArg, tmpFileForWrite, size, count)
-		response := localChannel.Run(ctx, "dd", args)
-		if !response.Success {
-			log.Errorf(ctx, "disk burn write, run dd err: %s", response.Err)
-			break
-		}
-	}
-}
+const (
+	defaultIODepth   = 16
+	defaultRWMixRead = 50
+)
 
-// read burn
-func burnRead(ctx context.Context, directory, size string, cl spec.Channel) {
-	// create a 600M file under the directory
-	tmpFileForRead := path.Join(directory, readFile)
-	ddCreateArg, ddRunningReadArg, _ := getArgs(ctx, localChannel)
-	createArgs := fmt.Sprintf(ddCreateArg, tmpFileForRead, 6, count)
-	response := localChannel.Run(ctx, "dd", createArgs)
-	if !response.Success {
-		log.Errorf(ctx, "disk burn read, run dd err: %s", response.Err)
-	}
+// fioJobKey is the context key of the fio job which the burn runs, it's absent for the native engine
+type fioJobKey struct{}
 
-	for {
-		args := fmt.Sprintf(ddRunningReadArg, tmpFileForRead, size, count)
-		// run with local channel
-		response := localChannel.Run(ctx, "dd", args)
-		if !response.Success {
-			log.Errorf(ctx, "disk burn read, run dd err: %s", response.Err)
-			break
+// chooseEngine returns the engine of the flag, the auto probes fio and falls back to the native engine
+func chooseEngine(ctx context.Context, cl spec.Channel, engine string) (string, *spec.Response) {
+	switch engine {
+	case "", engineAuto:
+		if fioSupported && cl.IsCommandAvailable(ctx, engineFio) {
+			return engineFio, nil
+		}
+		return engineNative, nil
+	case engineNative:
+		return engineNative, nil
+	case engineFio:
+		if !fioSupported {
+			return "", exec.Fail(exec.UnsupportedPlatform, "disk", "burn", "the fio engine is not supported on this platform")
 		}
+		if !cl.IsCommandAvailable(ctx, engineFio) {
+			log.Errorf(ctx, "`%s`: engine is illegal, fio is not found", engine)
+			return "", spec.ResponseFailWithFlags(spec.ParameterInvalid, engineFlag, engine, "the fio command is not found")
+		}
+		return engineFio, nil
 	}
+	return "", spec.ResponseFailWithFlags(spec.ParameterIllegal, engineFlag, engine, "it must be auto, native or fio")
 }
 
-func getArgs(ctx context.Context, cl spec.Channel) (string, string, string) {
-	createArgs := "if=/dev/zero of=%s bs=%dM count=%d oflag=dsync"
-	runningReadArgs := "if=%s of=/dev/null bs=%sM count=%d iflag=dsync,direct,fullblock"
-	runningWriteArgs := "if=/dev/zero of=%s bs=%sM count=%d oflag=dsync"
-	response := cl.Run(ctx, "cat", "/etc/os-release")
-	if !response.Success {
-		log.Warnf(ctx, "cat /etc/os-release failed, %v. use the default value.", response.Err)
-		return createArgs, runningReadArgs, runningWriteArgs
-	}
-	if response.Result != nil && strings.Contains(strings.ToUpper(response.Result.(string)), "ID=ALPINE") {
-		// alpine linux
-		createArgs = "if=/dev/zero of=%s bs=%dM count=%d oflag=append"
-		runningReadArgs = "if=%s of=/dev/null bs=%sM count=%d iflag=fullblock oflag=append"
-		runningWriteArgs = "if=/dev/zero of=%s bs=%sM count=%d oflag=append"
-	}
-	return createArgs, runningReadArgs, runningWriteArgs
-}
+var (
+	readFile  = "chaos_burnio.read"
+	writeFile = "chaos_burnio.write"
+)
+
+const count = 100
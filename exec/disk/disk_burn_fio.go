@@ -0,0 +1,133 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package disk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
+)
+
+// fioRuntime keeps fio running until the destroy kills it
+const fioRuntime = "8760h"
+
+// ioenginePattern is the name of the ioengine, it's written into the job file, so the options can't be injected
+var ioenginePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// fioJob is the job file of fio generated from the flags of the burn
+type fioJob struct {
+	Read      bool
+	Write     bool
+	BlockSize int64
+	IODepth   int
+	RWMixRead int
+	IOEngine  string
+}
+
+// newFioJob validates the flags of the fio engine, the block size is in MB
+func newFioJob(flags map[string]string, read, write bool, blockSize int64) (*fioJob, *spec.Response) {
+	job := &fioJob{Read: read, Write: write, BlockSize: blockSize, IODepth: defaultIODepth, RWMixRead: defaultRWMixRead}
+	var response *spec.Response
+	if value := flags[iodepthFlag]; value != "" {
+		if job.IODepth, response = validation.ValidateInt(iodepthFlag, value, 1, 65536); response != nil {
+			return nil, response
+		}
+	}
+	if value := flags[rwmixReadFlag]; value != "" {
+		if job.RWMixRead, response = validation.ValidatePercent(rwmixReadFlag, value); response != nil {
+			return nil, response
+		}
+	}
+	job.IOEngine = strings.TrimSpace(flags[ioengineFlag])
+	if job.IOEngine == "" {
+		job.IOEngine = "posixaio"
+		if runtime.GOOS == "linux" {
+			job.IOEngine = "libaio"
+		}
+	}
+	if !ioenginePattern.MatchString(job.IOEngine) {
+		return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, ioengineFlag, job.IOEngine, "it must be the name of the fio ioengine, such as libaio")
+	}
+	return job, nil
+}
+
+// render returns the job file which burns the file of the size in MB, the readOnly job only reads the
+// existing file or the device
+func (j *fioJob) render(filename string, size int64, readOnly bool) string {
+	rw := "randrw"
+	switch {
+	case readOnly || !j.Write:
+		rw = "randread"
+	case !j.Read:
+		rw = "randwrite"
+	}
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "[global]\nioengine=%s\ndirect=1\nbs=%dM\niodepth=%d\ntime_based=1\nruntime=%s\ngroup_reporting=1\n",
+		j.IOEngine, j.BlockSize, j.IODepth, fioRuntime)
+	fmt.Fprintf(&builder, "\n[chaos_burnio]\nfilename=%s\nrw=%s\n", strings.ReplaceAll(filename, ":", `\:`), rw)
+	if rw == "randrw" {
+		fmt.Fprintf(&builder, "rwmixread=%d\n", j.RWMixRead)
+	}
+	if readOnly {
+		builder.WriteString("readonly=1\n")
+	} else {
+		fmt.Fprintf(&builder, "size=%dM\n", size)
+	}
+	return builder.String()
+}
+
+// fioStats are the cumulative statistics of a direction of the fio job
+type fioStats struct {
+	IOBytes  int64   `json:"io_bytes"`
+	TotalIOs int64   `json:"total_ios"`
+	IOPS     float64 `json:"iops"`
+	BwBytes  int64   `json:"bw_bytes"`
+}
+
+type fioReport struct {
+	Jobs []struct {
+		Read  fioStats `json:"read"`
+		Write fioStats `json:"write"`
+	} `json:"jobs"`
+}
+
+// parseFioOutput returns the latest status of the json output of fio, which appends a json object every status
+// interval, the last object may be half written
+func parseFioOutput(content []byte) (*fioReport, bool) {
+	// the warnings of fio may precede the json
+	if start := bytes.IndexByte(content, '{'); start > 0 {
+		content = content[start:]
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	var latest *fioReport
+	for {
+		report := &fioReport{}
+		if err := decoder.Decode(report); err != nil {
+			return latest, latest != nil
+		}
+		if len(report.Jobs) > 0 {
+			latest = report
+		}
+	}
+}
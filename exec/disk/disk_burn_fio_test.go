@@ -0,0 +1,88 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package disk
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func TestChooseEngine(t *testing.T) {
+	ctx := context.Background()
+	cl := exec.NewMockChannel()
+	if engine, response := chooseEngine(ctx, cl, ""); response != nil || engine != engineFio {
+		t.Errorf("expected fio chosen by auto, got %s %v", engine, response)
+	}
+	cl.SetCommandAvailable(engineFio, false)
+	if engine, response := chooseEngine(ctx, cl, engineAuto); response != nil || engine != engineNative {
+		t.Errorf("expected auto falls back to native, got %s %v", engine, response)
+	}
+	if _, response := chooseEngine(ctx, cl, engineFio); response == nil || response.Code != spec.ParameterInvalid.Code {
+		t.Errorf("expected fio refused without the command, got %v", response)
+	}
+	if _, response := chooseEngine(ctx, cl, "dd"); response == nil || response.Code != spec.ParameterIllegal.Code {
+		t.Errorf("expected the unknown engine illegal, got %v", response)
+	}
+}
+
+func TestFioJob(t *testing.T) {
+	job, response := newFioJob(map[string]string{iodepthFlag: "32", rwmixReadFlag: "70", ioengineFlag: "io_uring"}, true, true, 10)
+	if response != nil {
+		t.Fatalf("unexpected response %v", response)
+	}
+	expect := "[global]\nioengine=io_uring\ndirect=1\nbs=10M\niodepth=32\ntime_based=1\nruntime=8760h\ngroup_reporting=1\n" +
+		"\n[chaos_burnio]\nfilename=/data/chaos_burnio.fio.uid\nrw=randrw\nrwmixread=70\nsize=1000M\n"
+	if content := job.render("/data/chaos_burnio.fio.uid", 1000, false); content != expect {
+		t.Errorf("unexpected job file\n%s", content)
+	}
+	// only the existing file is read on the read-only filesystem
+	content := job.render("/data/app.log", 1000, true)
+	if !strings.Contains(content, "rw=randread\nreadonly=1\n") || strings.Contains(content, "size=") {
+		t.Errorf("unexpected read-only job file\n%s", content)
+	}
+	job.Read = false
+	if content := job.render("/data/chaos_burnio.fio.uid", 1000, false); !strings.Contains(content, "rw=randwrite\n") {
+		t.Errorf("unexpected write job file\n%s", content)
+	}
+	for flag, value := range map[string]string{iodepthFlag: "0", rwmixReadFlag: "101", ioengineFlag: "libaio\nfilename=/dev/sda"} {
+		if _, response := newFioJob(map[string]string{flag: value}, true, false, 10); response == nil {
+			t.Errorf("expected %s=%q illegal", flag, value)
+		}
+	}
+}
+
+func TestParseFioOutput(t *testing.T) {
+	status := func(readBytes string) string {
+		return `{"jobs": [{"jobname": "chaos_burnio", "read": {"io_bytes": ` + readBytes +
+			`, "total_ios": 10, "iops": 2.5, "bw_bytes": 100}, "write": {"io_bytes": 0, "total_ios": 0}}]}` + "\n"
+	}
+	content := "fio: this platform does not support direct io\n" + status("1024") + status("2048") + `{"jobs": [{"jobname"`
+	report, ok := parseFioOutput([]byte(content))
+	if !ok || len(report.Jobs) != 1 || report.Jobs[0].Read.IOBytes != 2048 || report.Jobs[0].Read.IOPS != 2.5 {
+		t.Errorf("expected the latest complete status, got %+v", report)
+	}
+	if _, ok := parseFioOutput([]byte(`{"jobs": [`)); ok {
+		t.Errorf("expected no status in the half written output")
+	}
+}
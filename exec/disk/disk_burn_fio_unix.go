@@ -0,0 +1,150 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package disk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/shirou/gopsutil/process"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// fioSupported is true, fio runs on Linux and macOS
+const fioSupported = true
+
+// fioStatusInterval is the interval which fio appends the status to the json output and the metrics are updated
+const fioStatusInterval = 5 * time.Second
+
+// fioFiles returns the job file and the json output of the experiment, they are in the temp directory, so the
+// read-only filesystem can be burned too
+func fioFiles(uid string) (string, string) {
+	prefix := filepath.Join(os.TempDir(), fmt.Sprintf("chaos_burnio.%s", uid))
+	return prefix + ".fio", prefix + ".json"
+}
+
+// fioDataFile returns the file which fio burns under the directory
+func fioDataFile(directory, uid string) string {
+	return path.Join(directory, fmt.Sprintf("chaos_burnio.fio.%s", uid))
+}
+
+// burnFio runs fio as the child of the chaos process, which is killed with it, and updates the metrics by the
+// json output of fio until fio exits
+func burnFio(ctx context.Context, uid, directory string, job *fioJob, metrics *exec.MetricsWriter) *spec.Response {
+	jobFile, outputFile := fioFiles(uid)
+	filename, readOnly := fioDataFile(directory, uid), false
+	if source, ok := ctx.Value(readSourceKey{}).(string); ok && source != "" {
+		filename, readOnly = source, true
+	}
+	if err := os.WriteFile(jobFile, []byte(job.render(filename, job.BlockSize*count, readOnly)), 0o600); err != nil {
+		return exec.Fail(exec.CommandFailed, "disk", "fio", fmt.Sprintf("write the fio job file %s failed, %v", jobFile, err))
+	}
+	command := osexec.Command(engineFio, "--output-format=json",
+		fmt.Sprintf("--status-interval=%d", int(fioStatusInterval/time.Second)), "--output="+outputFile, jobFile)
+	setParentDeathSignal(command)
+	if err := command.Start(); err != nil {
+		return exec.Fail(exec.ProcessControlFailed, "disk", "fio", fmt.Sprintf("start the fio engine failed, %v", err))
+	}
+	log.Infof(ctx, "the fio engine %d burns %s by %s", command.Process.Pid, filename, jobFile)
+	done := make(chan error, 1)
+	go func() {
+		done <- command.Wait()
+	}()
+	ticker := time.NewTicker(fioStatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			output, _ := os.ReadFile(outputFile)
+			message := fmt.Sprintf("the fio engine exited, %v, %s", err, lastLines(string(output), 5))
+			log.Errorf(ctx, "%s", message)
+			metrics.SetError(fmt.Errorf("%s", message))
+			metrics.Flush(ctx)
+			return exec.Fail(exec.CommandFailed, "disk", "fio", message)
+		case <-ticker.C:
+			content, err := os.ReadFile(outputFile)
+			if err != nil {
+				log.Debugf(ctx, "read the fio output %s failed, %v", outputFile, err)
+				continue
+			}
+			if report, ok := parseFioOutput(content); ok {
+				setFioMetrics(ctx, metrics, report)
+			}
+		}
+	}
+}
+
+// setFioMetrics sets the cumulative statistics of fio, they have the same names as the ones of the native engine
+func setFioMetrics(ctx context.Context, metrics *exec.MetricsWriter, report *fioReport) {
+	var read, write fioStats
+	for _, job := range report.Jobs {
+		read.IOBytes += job.Read.IOBytes
+		read.TotalIOs += job.Read.TotalIOs
+		read.IOPS += job.Read.IOPS
+		write.IOBytes += job.Write.IOBytes
+		write.TotalIOs += job.Write.TotalIOs
+		write.IOPS += job.Write.IOPS
+	}
+	metrics.Set("read_bytes", float64(read.IOBytes))
+	metrics.Set("read_ops", float64(read.TotalIOs))
+	metrics.Set("read_iops", read.IOPS)
+	metrics.Set("write_bytes", float64(write.IOBytes))
+	metrics.Set("write_ops", float64(write.TotalIOs))
+	metrics.Set("write_iops", write.IOPS)
+	metrics.Flush(ctx)
+}
+
+// stopFio kills fio of the experiment, which survives the chaos process on macOS, and removes its files
+func stopFio(ctx context.Context, uid, directory string) {
+	jobFile, outputFile := fioFiles(uid)
+	if _, err := os.Stat(jobFile); err != nil {
+		return
+	}
+	if processes, err := process.Processes(); err == nil {
+		for _, p := range processes {
+			if cmdline, err := p.Cmdline(); err == nil && strings.Contains(cmdline, jobFile) {
+				if err := p.Kill(); err != nil {
+					log.Warnf(ctx, "kill the fio engine %d failed, %v", p.Pid, err)
+				}
+			}
+		}
+	}
+	for _, file := range []string{fioDataFile(directory, uid), jobFile, outputFile} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.Errorf(ctx, "clean the fio file %s failed, %v", file, err)
+		}
+	}
+}
+
+func lastLines(content string, n int) string {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
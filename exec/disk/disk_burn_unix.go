@@ -0,0 +1,229 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package disk
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+var localChannel = channel.NewLocalChannel()
+
+func checkBurnCommands(ctx context.Context) (*spec.Response, bool) {
+	commands := []string{"rm", "dd"}
+	// use local channel
+	return localChannel.IsAllCommandsAvailable(ctx, commands)
+}
+
+func (be *BurnIOExecutor) start(ctx context.Context, uid string, read, write bool, directory, size string) *spec.Response {
+	metrics := exec.NewMetricsWriter(ctx, uid, "disk", "burn", "")
+	if job, ok := ctx.Value(fioJobKey{}).(*fioJob); ok {
+		return burnFio(ctx, uid, directory, job, metrics)
+	}
+	if read {
+		go burnRead(ctx, directory, size, be.channel, metrics)
+	}
+	if write {
+		go burnWrite(ctx, directory, size, be.channel, metrics)
+	}
+	select {}
+}
+
+func (be *BurnIOExecutor) stop(ctx context.Context, uid string, read, write bool, directory string) *spec.Response {
+	if _, readOnly := readOnlyMount(ctx, be.channel, directory); readOnly {
+		// nothing is created on the read-only filesystem
+		read, write = false, false
+	}
+	if uid != "" {
+		stopFio(ctx, uid, directory)
+	}
+	if read {
+		resp := localChannel.Run(ctx, "rm", fmt.Sprintf("-rf %s*", path.Join(directory, readFile)))
+		if !resp.Success {
+			log.Errorf(ctx, "clean read file: %s", resp.Err)
+		}
+	}
+	if write {
+		resp := localChannel.Run(ctx, "rm", fmt.Sprintf("-rf %s*", path.Join(directory, writeFile)))
+		if !resp.Success {
+			log.Errorf(ctx, "clean write file: %s", resp.Err)
+		}
+	}
+	ctx = context.WithValue(ctx, "bin", BurnIOBin)
+	return exec.Destroy(ctx, be.channel, "disk burn")
+}
+
+// write burn
+func burnWrite(ctx context.Context, directory, size string, cl spec.Channel, metrics *exec.MetricsWriter) {
+	tmpFileForWrite := path.Join(directory, writeFile)
+	_, _, ddRunningWriteArg := getArgs(ctx, localChannel)
+	for {
+		args := fmt.Sprintf(ddRunningWriteArg, tmpFileForWrite, size, count)
+		response := localChannel.Run(ctx, "dd", args)
+		if !response.Success {
+			log.Errorf(ctx, "disk burn write, run dd err: %s", response.Err)
+			metrics.SetError(fmt.Errorf("run dd err: %s", response.Err))
+			metrics.Flush(ctx)
+			break
+		}
+		addBurnMetrics(ctx, metrics, "write", size)
+	}
+}
+
+// read burn
+func burnRead(ctx context.Context, directory, size string, cl spec.Channel, metrics *exec.MetricsWriter) {
+	// create a 600M file under the directory, or read the existing one on the read-only filesystem
+	tmpFileForRead := path.Join(directory, readFile)
+	ddCreateArg, ddRunningReadArg, _ := getArgs(ctx, localChannel)
+	if source, ok := ctx.Value(readSourceKey{}).(string); ok && source != "" {
+		tmpFileForRead = source
+	} else {
+		createArgs := fmt.Sprintf(ddCreateArg, tmpFileForRead, 6, count)
+		response := localChannel.Run(ctx, "dd", createArgs)
+		if !response.Success {
+			log.Errorf(ctx, "disk burn read, run dd err: %s", response.Err)
+		}
+	}
+
+	for {
+		args := fmt.Sprintf(ddRunningReadArg, tmpFileForRead, size, count)
+		// run with local channel
+		response := localChannel.Run(ctx, "dd", args)
+		if !response.Success {
+			log.Errorf(ctx, "disk burn read, run dd err: %s", response.Err)
+			metrics.SetError(fmt.Errorf("run dd err: %s", response.Err))
+			metrics.Flush(ctx)
+			break
+		}
+		addBurnMetrics(ctx, metrics, "read", size)
+	}
+}
+
+func getArgs(ctx context.Context, cl spec.Channel) (string, string, string) {
+	createArgs := "if=/dev/zero of=%s bs=%dM count=%d oflag=dsync"
+	runningReadArgs := "if=%s of=/dev/null bs=%sM count=%d iflag=dsync,direct,fullblock"
+	runningWriteArgs := "if=/dev/zero of=%s bs=%sM count=%d oflag=dsync"
+	response := cl.Run(ctx, "cat", "/etc/os-release")
+	if !response.Success {
+		log.Warnf(ctx, "cat /etc/os-release failed, %v. use the default value.", response.Err)
+		return createArgs, runningReadArgs, runningWriteArgs
+	}
+	if response.Result != nil && strings.Contains(strings.ToUpper(response.Result.(string)), "ID=ALPINE") {
+		// alpine linux
+		createArgs = "if=/dev/zero of=%s bs=%dM count=%d oflag=append"
+		runningReadArgs = "if=%s of=/dev/null bs=%sM count=%d iflag=fullblock oflag=append"
+		runningWriteArgs = "if=/dev/zero of=%s bs=%sM count=%d oflag=append"
+	}
+	return createArgs, runningReadArgs, runningWriteArgs
+}
+
+// addBurnMetrics counts a dd run, which transfers count blocks of size MB
+func addBurnMetrics(ctx context.Context, metrics *exec.MetricsWriter, kind, size string) {
+	blockSize, _ := strconv.Atoi(size)
+	metrics.Add(kind+"_ops", count)
+	metrics.Add(kind+"_bytes", float64(count*blockSize*1024*1024))
+	metrics.Flush(ctx)
+}
+
+const (
+	// readSourceMinSize is the size of the existing file which is large enough to read, the largest file is
+	// read if none of them reaches it
+	readSourceMinSize = 64 * 1024 * 1024
+	// readSourceScanLimit is the entries scanned for the existing file, so the large tree isn't walked through
+	readSourceScanLimit = 10000
+)
+
+// readOnlySource returns the existing file under the directory or the device of the read-only filesystem, which
+// the read burn reads instead of creating the file. The device is read only if it's a block device which can
+// be opened read-only, and it's never written, the dd of the read burn only reads it to /dev/null.
+func readOnlySource(ctx context.Context, mount exec.MountInfo, directory string) (string, *spec.Response) {
+	if file := findReadableFile(ctx, directory); file != "" {
+		return file, nil
+	}
+	if strings.HasPrefix(mount.Source, "/dev/") {
+		if info, err := os.Stat(mount.Source); err == nil && info.Mode()&os.ModeDevice != 0 && info.Mode()&os.ModeCharDevice == 0 {
+			if device, err := os.Open(mount.Source); err == nil {
+				device.Close()
+				return mount.Source, nil
+			}
+		}
+	}
+	reason := fmt.Sprintf("it's on the read-only %s filesystem %s, and there is neither a readable file under it nor the readable block device %s to burn io by reading",
+		mount.FsType, mount.MountPoint, mount.Source)
+	log.Errorf(ctx, "`%s`: %s", directory, reason)
+	return "", spec.ResponseFailWithFlags(spec.ParameterIllegal, "path", directory, reason)
+}
+
+// findReadableFile returns the first readable regular file of readSourceMinSize under the directory, or the
+// largest one of the scanned entries, the other filesystems mounted under it are skipped
+func findReadableFile(ctx context.Context, directory string) string {
+	var root syscall.Stat_t
+	if err := syscall.Stat(directory, &root); err != nil {
+		return ""
+	}
+	var largest string
+	var largestSize int64
+	scanned := 0
+	filepath.WalkDir(directory, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if scanned++; scanned > readSourceScanLimit {
+			return filepath.SkipAll
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Dev != root.Dev {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || info.Size() <= largestSize {
+			return nil
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return nil
+		}
+		file.Close()
+		largest, largestSize = p, info.Size()
+		if largestSize >= readSourceMinSize {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	log.Debugf(ctx, "found %s of %d bytes to read in %d entries of %s", largest, largestSize, scanned, directory)
+	return largest
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package disk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unsafe"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"golang.org/x/sys/windows"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// the buffers of the unbuffered io must be aligned with the sector size, 4096 covers the 512 bytes sector too
+const sectorAlignment = 4096
+
+// fioSupported is false, the io is burned by the native file operations on Windows
+const fioSupported = false
+
+// checkBurnCommands returns ok directly, the io is burned by the native file operations on Windows
+func checkBurnCommands(ctx context.Context) (*spec.Response, bool) {
+	return nil, true
+}
+
+// getBurnFile returns the file which is named by the experiment uid
+func getBurnFile(uid, directory, name string) string {
+	return filepath.Join(directory, fmt.Sprintf("%s.%s", name, uid))
+}
+
+// readOnlySource refuses the read burn on the read-only filesystem, the mount options aren't available on
+// Windows, so it's not called
+func readOnlySource(ctx context.Context, mount exec.MountInfo, directory string) (string, *spec.Response) {
+	return "", exec.Fail(exec.UnsupportedPlatform, "disk", "burn", "the read burn on the read-only filesystem is not supported on Windows")
+}
+
+func (be *BurnIOExecutor) start(ctx context.Context, uid string, read, write bool, directory, size string) *spec.Response {
+	blockSize, err := strconv.Atoi(size)
+	if err != nil || blockSize <= 0 {
+		log.Errorf(ctx, "`%s`: size is illegal, it must be positive integer", size)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "size", size, "it must be positive integer")
+	}
+	metrics := exec.NewMetricsWriter(ctx, uid, "disk", "burn", "")
+	if read {
+		go burnRead(ctx, getBurnFile(uid, directory, readFile), blockSize, metrics)
+	}
+	if write {
+		go burnWrite(ctx, getBurnFile(uid, directory, writeFile), blockSize, metrics)
+	}
+	select {}
+}
+
+func (be *BurnIOExecutor) stop(ctx context.Context, uid string, read, write bool, directory string) *spec.Response {
+	// the files can't be removed until the burning process exits
+	response := exec.DestroyByPidFile(ctx, uid)
+	if !response.Success {
+		return response
+	}
+	if read {
+		if err := os.Remove(getBurnFile(uid, directory, readFile)); err != nil && !os.IsNotExist(err) {
+			log.Errorf(ctx, "clean read file: %v", err)
+		}
+	}
+	if write {
+		if err := os.Remove(getBurnFile(uid, directory, writeFile)); err != nil && !os.IsNotExist(err) {
+			log.Errorf(ctx, "clean write file: %v", err)
+		}
+	}
+	return response
+}
+
+// write burn
+func burnWrite(ctx context.Context, tmpFileForWrite string, blockSize int, metrics *exec.MetricsWriter) {
+	for {
+		if err := writeDirect(ctx, tmpFileForWrite, blockSize, count, metrics); err != nil {
+			log.Errorf(ctx, "disk burn write err: %v", err)
+			metrics.SetError(err)
+			metrics.Flush(ctx)
+			break
+		}
+	}
+}
+
+// read burn
+func burnRead(ctx context.Context, tmpFileForRead string, blockSize int, metrics *exec.MetricsWriter) {
+	// create a 600M file under the directory
+	if err := writeDirect(ctx, tmpFileForRead, 6, count, nil); err != nil {
+		log.Errorf(ctx, "disk burn read, create file err: %v", err)
+	}
+	for {
+		if err := readDirect(ctx, tmpFileForRead, blockSize, metrics); err != nil {
+			log.Errorf(ctx, "disk burn read err: %v", err)
+			metrics.SetError(err)
+			metrics.Flush(ctx)
+			break
+		}
+	}
+}
+
+// writeDirect writes count blocks to the file from the beginning, the size of the block is MB,
+// the blocks are counted by the metrics if it's not nil
+func writeDirect(ctx context.Context, name string, blockSize, count int, metrics *exec.MetricsWriter) error {
+	file, err := openDirect(name, true)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	buffer := alignedBuffer(blockSize * 1024 * 1024)
+	for i := 0; i < count; i++ {
+		n, err := file.Write(buffer)
+		if err != nil {
+			return err
+		}
+		metrics.Add("write_ops", 1)
+		metrics.Add("write_bytes", float64(n))
+		metrics.Flush(ctx)
+	}
+	return nil
+}
+
+// readDirect reads the whole file, the size of the block is MB
+func readDirect(ctx context.Context, name string, blockSize int, metrics *exec.MetricsWriter) error {
+	file, err := openDirect(name, false)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	buffer := alignedBuffer(blockSize * 1024 * 1024)
+	for {
+		n, err := file.Read(buffer)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		metrics.Add("read_ops", 1)
+		metrics.Add("read_bytes", float64(n))
+		metrics.Flush(ctx)
+	}
+}
+
+// openDirect opens the file with FILE_FLAG_NO_BUFFERING to bypass the system cache like the direct io,
+// the written data is flushed to the disk by FILE_FLAG_WRITE_THROUGH like the dsync
+func openDirect(name string, write bool) (*os.File, error) {
+	path, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	access := uint32(windows.GENERIC_READ)
+	disposition := uint32(windows.OPEN_EXISTING)
+	flags := uint32(windows.FILE_ATTRIBUTE_NORMAL | windows.FILE_FLAG_NO_BUFFERING)
+	if write {
+		access = windows.GENERIC_WRITE
+		disposition = windows.CREATE_ALWAYS
+		flags |= windows.FILE_FLAG_WRITE_THROUGH
+	}
+	handle, err := windows.CreateFile(path, access, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil, disposition, flags, 0)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(handle), name), nil
+}
+
+// alignedBuffer returns the buffer whose address is aligned with the sector, the size must be the multiple of it
+func alignedBuffer(size int) []byte {
+	buffer := make([]byte, size+sectorAlignment)
+	offset := 0
+	if remainder := int(uintptr(unsafe.Pointer(&buffer[0])) & (sectorAlignment - 1)); remainder != 0 {
+		offset = sectorAlignment - remainder
+	}
+	return buffer[offset : offset+size]
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package disk
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestAlignedBuffer(t *testing.T) {
+	for _, size := range []int{sectorAlignment, 1024 * 1024, 10 * 1024 * 1024} {
+		buffer := alignedBuffer(size)
+		if len(buffer) != size {
+			t.Errorf("unexpected buffer size, expect: %d, actual: %d", size, len(buffer))
+		}
+		if uintptr(unsafe.Pointer(&buffer[0]))%sectorAlignment != 0 {
+			t.Errorf("the buffer of %d bytes is not aligned", size)
+		}
+	}
+}
+
+func TestGetBurnFile(t *testing.T) {
+	if got := getBurnFile("abc", `D:\`, readFile); got != `D:\chaos_burnio.read.abc` {
+		t.Errorf("unexpected burn file: %s", got)
+	}
+}
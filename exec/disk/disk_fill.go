@@ -20,11 +20,7 @@ import (
 	"context"
 	"fmt"
 	"math"
-	"os"
-	"path"
 	"strconv"
-	"strings"
-	"syscall"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
@@ -32,6 +28,7 @@ import (
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
 )
 
 const FillDiskBin = "chaos_filldisk"
@@ -52,7 +49,7 @@ func NewFillActionSpec() spec.ExpActionCommandSpec {
 			ActionFlags: []spec.ExpFlagSpec{
 				&spec.ExpFlag{
 					Name: "size",
-					Desc: "Disk fill size, unit is MB. The value is a positive integer without unit, for example, --size 1024",
+					Desc: "Disk fill size, unit is MB. The value is a positive integer without unit, or with the unit K, M, G or T, for example, --size 1024 or --size 1G",
 				},
 				&spec.ExpFlag{
 					Name: "percent",
@@ -60,13 +57,18 @@ func NewFillActionSpec() spec.ExpActionCommandSpec {
 				},
 				&spec.ExpFlag{
 					Name: "reserve",
-					Desc: "Disk reserve size, unit is MB. The value is a positive integer without unit. If size, percent and reserve flags exist, the priority is as follows: percent > reserve > size",
+					Desc: "Disk reserve size, unit is MB. The value is a positive integer without unit, or with the unit K, M, G or T. If size, percent and reserve flags exist, the priority is as follows: percent > reserve > size",
 				},
 				&spec.ExpFlag{
 					Name:   "retain-handle",
 					Desc:   "Whether to retain the big file handle, default value is false.",
 					NoArgs: true,
 				},
+				&spec.ExpFlag{
+					Name:   exec.ForceFlagName,
+					Desc:   "Fill the protected path, such as /etc or the installation of the blade, default value is false.",
+					NoArgs: true,
+				},
 			},
 			ActionExecutor: &FillActionExecutor{},
 			ActionExample: `
@@ -112,7 +114,7 @@ func (*FillActionExecutor) Name() string {
 }
 
 func (fae *FillActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
-	directory := "/"
+	directory := defaultDirectory
 	path := model.ActionFlags["path"]
 	if path != "" {
 		directory = path
@@ -122,111 +124,93 @@ func (fae *FillActionExecutor) Exec(uid string, ctx context.Context, model *spec
 		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "path", directory, "it must be a directory")
 	}
 	if _, ok := spec.IsDestroy(ctx); ok {
-		return fae.stop(directory, ctx)
-	} else {
-		retainHandle := model.ActionFlags["retain-handle"] == "true"
-		percent := model.ActionFlags["percent"]
-		if percent == "" {
-			reserve := model.ActionFlags["reserve"]
-			if reserve == "" {
-				size := model.ActionFlags["size"]
-				if size == "" {
-					return spec.ResponseFailWithFlags(spec.ParameterLess, "size|percent")
-				}
-				_, err := strconv.Atoi(size)
-				if err != nil {
-					log.Errorf(ctx, "`%s`: size is illegal, it must be positive integer", size)
-					return spec.ResponseFailWithFlags(spec.ParameterIllegal, "size", size, "it must be positive integer")
-				}
-				return fae.start(uid, directory, size, percent, reserve, retainHandle, ctx)
+		return fae.stop(uid, directory, ctx)
+	}
+	if model.ActionFlags[exec.ForceFlagName] != "true" {
+		if response := exec.CheckProtectedPath(ctx, fae.channel, "path", directory, false); response != nil {
+			return response
+		}
+	}
+	if mount, readOnly := readOnlyMount(ctx, fae.channel, directory); readOnly {
+		return readOnlyResponse(ctx, mount, directory)
+	}
+	retainHandle := model.ActionFlags["retain-handle"] == "true"
+	var size, reserve string
+	percent := model.ActionFlags["percent"]
+	if percent == "" {
+		reserve = model.ActionFlags["reserve"]
+		if reserve == "" {
+			size = model.ActionFlags["size"]
+			if size == "" {
+				return spec.ResponseFailWithFlags(spec.ParameterLess, "size|percent")
 			}
-			_, err := strconv.Atoi(reserve)
-			if err != nil {
-				log.Errorf(ctx, "`%s`: reserve is illegal, it must be positive integer", reserve)
-				return spec.ResponseFailWithFlags(spec.ParameterIllegal, "reserve", reserve, "it must be positive integer")
+			bytes, response := validation.ValidateSizeBytes("size", size, validation.MB, validation.MB)
+			if response != nil {
+				log.Errorf(ctx, "`%s`: size is illegal, %s", size, response.Err)
+				return response
+			}
+			size = strconv.FormatInt(bytes/validation.MB, 10)
+		} else {
+			bytes, response := validation.ValidateSizeBytes("reserve", reserve, validation.MB, 0)
+			if response != nil {
+				log.Errorf(ctx, "`%s`: reserve is illegal, %s", reserve, response.Err)
+				return response
 			}
-			return fae.start(uid, directory, "", percent, reserve, retainHandle, ctx)
+			reserve = strconv.FormatInt(bytes/validation.MB, 10)
 		}
-		_, err := strconv.Atoi(percent)
-		if err != nil {
-			log.Errorf(ctx, "`%s`: percent is illegal, it must be positive integer", percent)
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "percent", percent, "it must be positive integer")
+	} else {
+		p, response := validation.ValidatePercent("percent", percent)
+		if response != nil {
+			log.Errorf(ctx, "`%s`: percent is illegal, %s", percent, response.Err)
+			return response
 		}
-		return fae.start(uid, directory, "", percent, "", retainHandle, ctx)
+		percent = strconv.Itoa(p)
+	}
+	// the fills of the same filesystem calculate the size by the space which is changed by each other
+	if _, response := exec.ClaimResources(ctx, fae.channel, uid, model.Target, model.ActionName, model.ActionFlags,
+		exec.FilesystemResource(mountPoint(directory))); response != nil {
+		return response
 	}
+	response := fae.start(uid, directory, size, percent, reserve, retainHandle, ctx)
+	if !response.Success {
+		exec.ReleaseResources(ctx, uid)
+	}
+	return response
 }
 
 func (fae *FillActionExecutor) start(uid, directory, size, percent, reserve string, retainHandle bool, ctx context.Context) *spec.Response {
 	return startFill(ctx, uid, directory, size, percent, reserve, retainHandle, fae.channel)
 }
 
-func (fae *FillActionExecutor) stop(directory string, ctx context.Context) *spec.Response {
-	return stopFill(ctx, directory, fae.channel)
-}
-
-func (fae *FillActionExecutor) SetChannel(channel spec.Channel) {
-	fae.channel = channel
-}
-
-var fillDataFile = "chaos_filldisk.log.dat"
-
-// retainFileHandle by opening the file
-func retainFileHandle(ctx context.Context, cl spec.Channel, fillDiskDirectory string) *spec.Response {
-	// open the temp file to retain file handle
-	dataFilePath := path.Join(fillDiskDirectory, fillDataFile)
-	file, err := os.Open(dataFilePath)
-	if err != nil {
-		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("failed to read %s file, %s", dataFilePath, err.Error()))
+func (fae *FillActionExecutor) stop(uid, directory string, ctx context.Context) *spec.Response {
+	response := stopFill(ctx, uid, directory, fae.channel)
+	if response.Success {
+		exec.ReleaseResources(ctx, uid)
 	}
-	defer file.Close()
-	select {}
+	return response
 }
 
-const diskFillErrorMessage = "No space left on device"
-
-func startFill(ctx context.Context, uid, directory, size, percent, reserve string, retainHandle bool, cl spec.Channel) *spec.Response {
-	if directory == "" {
-		log.Errorf(ctx, "`%s`: directory is nil", directory)
-		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "directory", directory, "directory is nil")
-	}
-	if size == "" && percent == "" && reserve == "" {
-		log.Errorf(ctx, "`%s`: less --size or --percent or --reserve flag", directory)
-		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "directory", directory, "less --size or --percent or --reserve flag")
-	}
-	dataFile := path.Join(directory, fillDataFile)
-	size, err := calculateFileSize(ctx, directory, size, percent, reserve)
-	if err != nil {
-		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("calculate size err, %v", err))
+// Status checks the data file which fills the disk
+func (fae *FillActionExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	if state != nil && state.Destroyed {
+		report.Add(exec.Artifact{Kind: "record", Name: exec.GetStateFile(uid), Detail: "the experiment is destroyed"})
+		return report
 	}
-	var response *spec.Response
-	// Some normal filesystems (ext4, xfs, btrfs and ocfs2) tack quick works
-	if cl.IsCommandAvailable(ctx, "fallocate") {
-		response = fillDiskByFallocate(ctx, size, dataFile, cl)
+	directory := model.ActionFlags["path"]
+	if state != nil && state.Flags["path"] != "" {
+		directory = state.Flags["path"]
 	}
-	if response == nil || !response.Success {
-		// If execute fallocate command failed, use dd command to retry.
-		response = fillDiskByDD(ctx, dataFile, directory, size, cl)
-	}
-	if response.Success {
-		if retainHandle {
-			// start a process to hold the file handle
-			response := retainFileHandle(ctx, cl, directory)
-			if !response.Success {
-				return response
-			}
-		}
-		return response
-	}
-	if err = stopFill(ctx, directory, cl); err != nil {
-		log.Warnf(ctx, "failed to stop fill when starting failed, %v, starting err: %s", err, response.Err)
+	if directory == "" {
+		directory = defaultDirectory
 	}
-	return response
+	dataFile := getFillDataFile(uid, directory)
+	report.Add(exec.Artifact{Kind: "file", Name: dataFile, Present: exec.CheckFilepathExists(ctx, fae.channel, dataFile)})
+	return report
 }
 
-var getSysStatFunc = func(directory string) *syscall.Statfs_t {
-	var stat syscall.Statfs_t
-	syscall.Statfs(directory, &stat)
-	return &stat
+func (fae *FillActionExecutor) SetChannel(channel spec.Channel) {
+	fae.channel = channel
 }
 
 // calculateFileSize returns the size which should be filled, unit is M
@@ -234,9 +218,7 @@ func calculateFileSize(ctx context.Context, directory, size, percent, reserve st
 	if percent == "" && reserve == "" {
 		return size, nil
 	}
-	stat := getSysStatFunc(directory)
-	allBytes := stat.Blocks * uint64(stat.Bsize)
-	availableBytes := stat.Bavail * uint64(stat.Bsize)
+	allBytes, availableBytes := getDiskSpaceFunc(directory)
 	usedBytes := allBytes - availableBytes
 
 	if percent != "" {
@@ -273,57 +255,3 @@ func calculateFileSize(ctx context.Context, directory, size, percent, reserve st
 		return fmt.Sprintf("%.f", expectSize), nil
 	}
 }
-
-func fillDiskByFallocate(ctx context.Context, size string, dataFile string, cl spec.Channel) *spec.Response {
-	response := cl.Run(ctx, "fallocate", fmt.Sprintf(`-l %sM %s`, size, dataFile))
-	if response.Success {
-		return response
-	}
-	// Need to judge that the disk is full or not. If the disk is full, return success
-	if strings.Contains(response.Err, diskFillErrorMessage) {
-		return spec.ReturnSuccess(fmt.Sprintf("success because of %s", diskFillErrorMessage))
-	}
-	log.Warnf(ctx, "execute fallocate err, %s", response.Err)
-	return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "fallocate", response.Err)
-}
-
-func fillDiskByDD(ctx context.Context, dataFile string, directory string, size string, cl spec.Channel) *spec.Response {
-	if !cl.IsCommandAvailable(ctx, "dd") {
-		return spec.ResponseFailWithFlags(spec.CommandDdNotFound)
-	}
-
-	// Because of filling disk slowly using dd, so execute dd with 1b size first to test the command.
-	response := cl.Run(ctx, "dd", fmt.Sprintf(`if=/dev/zero of=%s bs=1b count=1 iflag=fullblock`, dataFile))
-	if !response.Success {
-		return response
-	}
-	return cl.Run(ctx, "nohup",
-		fmt.Sprintf(`dd if=/dev/zero of=%s bs=1M count=%s iflag=fullblock >/dev/null 2>&1 &`, dataFile, size))
-}
-
-// stopFill contains kill the filldisk process and delete the temp file actions
-func stopFill(ctx context.Context, directory string, cl spec.Channel) *spec.Response {
-	if directory == "" {
-		log.Errorf(ctx, "`%s`: directory is nil", directory)
-		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "directory", directory, "directory is nil")
-	}
-	// kill dd or fallocate process
-	pids, _ := cl.GetPidsByProcessName(fillDataFile, ctx)
-	if pids != nil && len(pids) >= 0 {
-		resp := cl.Run(ctx, "kill", fmt.Sprintf("-9 %s", strings.Join(pids, " ")))
-		log.Errorf(ctx, "kill fallocate process err: %s", resp.Err)
-	}
-	// kill daemon process
-	// todo
-	// ctx = context.WithValue(ctx, channel.ProcessKey, fillDiskBin)
-	pids, _ = cl.GetPidsByProcessName("disk fill", ctx)
-	if pids != nil && len(pids) >= 0 {
-		resp := cl.Run(ctx, "kill", fmt.Sprintf("-9 %s", strings.Join(pids, " ")))
-		log.Errorf(ctx, "kill disk fill daemon process err: %s", resp.Err)
-	}
-	fileName := path.Join(directory, fillDataFile)
-	if exec.CheckFilepathExists(ctx, cl, fileName) {
-		return cl.Run(ctx, "rm", fmt.Sprintf(`-rf %s`, fileName))
-	}
-	return spec.Success()
-}
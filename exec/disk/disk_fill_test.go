@@ -0,0 +1,52 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package disk
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCalculateFileSize(t *testing.T) {
+	getDiskSpace := getDiskSpaceFunc
+	defer func() { getDiskSpaceFunc = getDiskSpace }()
+	// 10G in total and 4G available
+	getDiskSpaceFunc = func(directory string) (uint64, uint64) {
+		return 10 * 1024 * 1024 * 1024, 4 * 1024 * 1024 * 1024
+	}
+	tests := []struct {
+		size, percent, reserve string
+		expect                 string
+		err                    bool
+	}{
+		{"100", "", "", "100", false},
+		{"", "80", "", "2048", false},
+		{"", "100", "", "4096", false},
+		{"", "50", "", "", true},
+		{"", "", "1024", "3072", false},
+		{"", "", "4096", "", true},
+	}
+	for _, tt := range tests {
+		got, err := calculateFileSize(context.Background(), defaultDirectory, tt.size, tt.percent, tt.reserve)
+		if (err != nil) != tt.err {
+			t.Errorf("unexpected err of %+v, %v", tt, err)
+		}
+		if got != tt.expect {
+			t.Errorf("unexpected size of %+v, expect: %s, actual: %s", tt, tt.expect, got)
+		}
+	}
+}
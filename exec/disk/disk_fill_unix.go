@@ -0,0 +1,182 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package disk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+const defaultDirectory = "/"
+
+var fillDataFile = "chaos_filldisk.log.dat"
+
+// getFillDataFile returns the file which fills the disk, it's shared by the experiments on the directory
+func getFillDataFile(uid, directory string) string {
+	return path.Join(directory, fillDataFile)
+}
+
+// mountPoint returns the mount point of the filesystem where the directory is, the device of the directory
+// changes at the mount point
+func mountPoint(directory string) string {
+	dir, err := filepath.Abs(directory)
+	if err != nil {
+		return directory
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	var stat syscall.Stat_t
+	if err := syscall.Stat(dir, &stat); err != nil {
+		return dir
+	}
+	for dir != "/" {
+		parent := filepath.Dir(dir)
+		var parentStat syscall.Stat_t
+		if err := syscall.Stat(parent, &parentStat); err != nil || parentStat.Dev != stat.Dev {
+			break
+		}
+		dir = parent
+	}
+	return dir
+}
+
+// retainFileHandle by opening the file
+func retainFileHandle(ctx context.Context, cl spec.Channel, fillDiskDirectory string) *spec.Response {
+	// open the temp file to retain file handle
+	dataFilePath := path.Join(fillDiskDirectory, fillDataFile)
+	file, err := os.Open(dataFilePath)
+	if err != nil {
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("failed to read %s file, %s", dataFilePath, err.Error()))
+	}
+	defer file.Close()
+	select {}
+}
+
+const diskFillErrorMessage = "No space left on device"
+
+func startFill(ctx context.Context, uid, directory, size, percent, reserve string, retainHandle bool, cl spec.Channel) *spec.Response {
+	if directory == "" {
+		log.Errorf(ctx, "`%s`: directory is nil", directory)
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "directory", directory, "directory is nil")
+	}
+	if size == "" && percent == "" && reserve == "" {
+		log.Errorf(ctx, "`%s`: less --size or --percent or --reserve flag", directory)
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "directory", directory, "less --size or --percent or --reserve flag")
+	}
+	dataFile := path.Join(directory, fillDataFile)
+	size, err := calculateFileSize(ctx, directory, size, percent, reserve)
+	if err != nil {
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("calculate size err, %v", err))
+	}
+	var response *spec.Response
+	// Some normal filesystems (ext4, xfs, btrfs and ocfs2) tack quick works
+	if cl.IsCommandAvailable(ctx, "fallocate") {
+		response = fillDiskByFallocate(ctx, size, dataFile, cl)
+	}
+	if response == nil || !response.Success {
+		// If execute fallocate command failed, use dd command to retry.
+		response = fillDiskByDD(ctx, dataFile, directory, size, cl)
+	}
+	if response.Success {
+		if retainHandle {
+			// start a process to hold the file handle
+			response := retainFileHandle(ctx, cl, directory)
+			if !response.Success {
+				return response
+			}
+		}
+		return response
+	}
+	if err = stopFill(ctx, uid, directory, cl); err != nil {
+		log.Warnf(ctx, "failed to stop fill when starting failed, %v, starting err: %s", err, response.Err)
+	}
+	return response
+}
+
+// getDiskSpaceFunc returns the total bytes and the available bytes of the filesystem where the directory is
+var getDiskSpaceFunc = func(directory string) (uint64, uint64) {
+	var stat syscall.Statfs_t
+	syscall.Statfs(directory, &stat)
+	return stat.Blocks * uint64(stat.Bsize), stat.Bavail * uint64(stat.Bsize)
+}
+
+func fillDiskByFallocate(ctx context.Context, size string, dataFile string, cl spec.Channel) *spec.Response {
+	response := cl.Run(ctx, "fallocate", fmt.Sprintf(`-l %sM %s`, size, dataFile))
+	if response.Success {
+		return response
+	}
+	// Need to judge that the disk is full or not. If the disk is full, return success
+	if strings.Contains(response.Err, diskFillErrorMessage) {
+		return spec.ReturnSuccess(fmt.Sprintf("success because of %s", diskFillErrorMessage))
+	}
+	log.Warnf(ctx, "execute fallocate err, %s", response.Err)
+	return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "fallocate", response.Err)
+}
+
+func fillDiskByDD(ctx context.Context, dataFile string, directory string, size string, cl spec.Channel) *spec.Response {
+	if !cl.IsCommandAvailable(ctx, "dd") {
+		return spec.ResponseFailWithFlags(spec.CommandDdNotFound)
+	}
+
+	// Because of filling disk slowly using dd, so execute dd with 1b size first to test the command.
+	response := cl.Run(ctx, "dd", fmt.Sprintf(`if=/dev/zero of=%s bs=1b count=1 iflag=fullblock`, dataFile))
+	if !response.Success {
+		return response
+	}
+	return cl.Run(ctx, "nohup",
+		fmt.Sprintf(`dd if=/dev/zero of=%s bs=1M count=%s iflag=fullblock >/dev/null 2>&1 &`, dataFile, size))
+}
+
+// stopFill contains kill the filldisk process and delete the temp file actions
+func stopFill(ctx context.Context, uid, directory string, cl spec.Channel) *spec.Response {
+	if directory == "" {
+		log.Errorf(ctx, "`%s`: directory is nil", directory)
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "directory", directory, "directory is nil")
+	}
+	// kill dd or fallocate process
+	pids, _ := cl.GetPidsByProcessName(fillDataFile, ctx)
+	if pids != nil && len(pids) >= 0 {
+		resp := cl.Run(ctx, "kill", fmt.Sprintf("-9 %s", strings.Join(pids, " ")))
+		log.Errorf(ctx, "kill fallocate process err: %s", resp.Err)
+	}
+	// kill daemon process
+	// todo
+	// ctx = context.WithValue(ctx, channel.ProcessKey, fillDiskBin)
+	pids, _ = cl.GetPidsByProcessName("disk fill", ctx)
+	if pids != nil && len(pids) >= 0 {
+		resp := cl.Run(ctx, "kill", fmt.Sprintf("-9 %s", strings.Join(pids, " ")))
+		log.Errorf(ctx, "kill disk fill daemon process err: %s", resp.Err)
+	}
+	fileName := path.Join(directory, fillDataFile)
+	if exec.CheckFilepathExists(ctx, cl, fileName) {
+		return cl.Run(ctx, "rm", fmt.Sprintf(`-rf %s`, fileName))
+	}
+	return spec.Success()
+}
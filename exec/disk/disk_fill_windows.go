@@ -0,0 +1,202 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package disk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unsafe"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"golang.org/x/sys/windows"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// defaultDirectory is the root of the system drive, for example C:\
+var defaultDirectory = getSystemDrive() + `\`
+
+var procSetFileValidData = windows.NewLazySystemDLL("kernel32.dll").NewProc("SetFileValidData")
+
+const (
+	// the privilege which is required by SetFileValidData
+	manageVolumePrivilege = "SeManageVolumePrivilege"
+	// the size of the blocks which are written when SetFileValidData is not allowed
+	fillBlockSize = 1024 * 1024
+)
+
+func getSystemDrive() string {
+	if drive := os.Getenv("SystemDrive"); drive != "" {
+		return drive
+	}
+	return "C:"
+}
+
+// getFillDataFile returns the file which is named by the experiment uid, so that the experiments on the same
+// drive don't remove the files of the others
+func getFillDataFile(uid, directory string) string {
+	return filepath.Join(directory, fmt.Sprintf("chaos_filldisk-%s.log.dat", uid))
+}
+
+// mountPoint returns the drive where the directory is
+func mountPoint(directory string) string {
+	if dir, err := filepath.Abs(directory); err == nil {
+		directory = dir
+	}
+	return filepath.VolumeName(directory) + `\`
+}
+
+// getDiskSpaceFunc returns the total bytes and the available bytes of the drive where the directory is
+var getDiskSpaceFunc = func(directory string) (uint64, uint64) {
+	var available, total, free uint64
+	name, err := windows.UTF16PtrFromString(directory)
+	if err != nil {
+		return 0, 0
+	}
+	windows.GetDiskFreeSpaceEx(name, &available, &total, &free)
+	return total, available
+}
+
+func startFill(ctx context.Context, uid, directory, size, percent, reserve string, retainHandle bool, cl spec.Channel) *spec.Response {
+	size, err := calculateFileSize(ctx, directory, size, percent, reserve)
+	if err != nil {
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("calculate size err, %v", err))
+	}
+	mb, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("calculate size err, %v", err))
+	}
+	dataFile := getFillDataFile(uid, directory)
+	response := fillFile(ctx, dataFile, mb*1024*1024)
+	if !response.Success {
+		if resp := stopFill(ctx, uid, directory, cl); !resp.Success {
+			log.Warnf(ctx, "failed to stop fill when starting failed, %s, starting err: %s", resp.Err, response.Err)
+		}
+		return response
+	}
+	if retainHandle {
+		// the process holds the file handle, so record it to be killed when destroying
+		if err := exec.RecordPid(uid); err != nil {
+			log.Warnf(ctx, "record the pid of the disk fill failed, %v", err)
+		}
+		file, err := os.Open(dataFile)
+		if err != nil {
+			return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("failed to read %s file, %s", dataFile, err.Error()))
+		}
+		defer file.Close()
+		select {}
+	}
+	return response
+}
+
+// fillFile allocates the file by SetFileValidData, so that the clusters are taken without writing them. If the
+// privilege is not held, the zero blocks are written instead, the file is never sparse
+func fillFile(ctx context.Context, dataFile string, size int64) *spec.Response {
+	file, err := os.OpenFile(dataFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "createFillFile", err)
+	}
+	defer file.Close()
+	if err := file.Truncate(size); err != nil {
+		if isDiskFull(err) {
+			log.Infof(ctx, "the disk is not enough for %d bytes, fill it by writing", size)
+			return writeZeroBlocks(file, size)
+		}
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "allocateFillFile", err)
+	}
+	if err := setFileValidData(file, size); err != nil {
+		log.Infof(ctx, "set valid data of %s failed, %v, fill it by writing", dataFile, err)
+		return writeZeroBlocks(file, size)
+	}
+	return spec.ReturnSuccess(dataFile)
+}
+
+func setFileValidData(file *os.File, size int64) error {
+	if err := enablePrivilege(manageVolumePrivilege); err != nil {
+		return err
+	}
+	if ret, _, err := procSetFileValidData.Call(file.Fd(), uintptr(size)); ret == 0 {
+		return err
+	}
+	return nil
+}
+
+// enablePrivilege enables the privilege of the current process, which is held by the administrators but disabled
+func enablePrivilege(name string) error {
+	var token windows.Token
+	if err := windows.OpenProcessToken(windows.CurrentProcess(),
+		windows.TOKEN_ADJUST_PRIVILEGES|windows.TOKEN_QUERY, &token); err != nil {
+		return err
+	}
+	defer token.Close()
+	privilege, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	privileges := windows.Tokenprivileges{PrivilegeCount: 1}
+	if err := windows.LookupPrivilegeValue(nil, privilege, &privileges.Privileges[0].Luid); err != nil {
+		return err
+	}
+	privileges.Privileges[0].Attributes = windows.SE_PRIVILEGE_ENABLED
+	// AdjustTokenPrivileges succeeds even if the privilege is not held, then SetFileValidData fails
+	return windows.AdjustTokenPrivileges(token, false, &privileges, uint32(unsafe.Sizeof(privileges)), nil, nil)
+}
+
+// writeZeroBlocks writes the file from the beginning until the size is reached or the disk is full
+func writeZeroBlocks(file *os.File, size int64) *spec.Response {
+	if _, err := file.Seek(0, 0); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writeFillFile", err)
+	}
+	block := make([]byte, fillBlockSize)
+	for written := int64(0); written < size; {
+		n := int64(len(block))
+		if size-written < n {
+			n = size - written
+		}
+		if _, err := file.Write(block[:n]); err != nil {
+			// Need to judge that the disk is full or not. If the disk is full, return success
+			if isDiskFull(err) {
+				return spec.ReturnSuccess("success because of the disk is full")
+			}
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writeFillFile", err)
+		}
+		written += n
+	}
+	return spec.ReturnSuccess(file.Name())
+}
+
+func isDiskFull(err error) bool {
+	return errors.Is(err, windows.ERROR_DISK_FULL) || errors.Is(err, windows.ERROR_HANDLE_DISK_FULL)
+}
+
+// stopFill kills the process which retains the file handle and deletes the file of the experiment
+func stopFill(ctx context.Context, uid, directory string, cl spec.Channel) *spec.Response {
+	if response := exec.DestroyByPidFile(ctx, uid); !response.Success {
+		return response
+	}
+	dataFile := getFillDataFile(uid, directory)
+	if err := os.Remove(dataFile); err != nil && !os.IsNotExist(err) {
+		log.Errorf(ctx, "remove the fill file %s failed, %v", dataFile, err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "removeFillFile", err)
+	}
+	return spec.Success()
+}
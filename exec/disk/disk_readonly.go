@@ -0,0 +1,54 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package disk
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// readOnlyMount returns the filesystem of the directory if it's mounted read-only. The directory is resolved
+// but not walked up to the device boundary, so the read-only bind mount of the same device is found.
+func readOnlyMount(ctx context.Context, cl spec.Channel, directory string) (exec.MountInfo, bool) {
+	if dir, err := filepath.Abs(directory); err == nil {
+		directory = dir
+	}
+	if resolved, err := filepath.EvalSymlinks(directory); err == nil {
+		directory = resolved
+	}
+	mount, ok := exec.GetMount(ctx, cl, directory)
+	return mount, ok && mount.ReadOnly()
+}
+
+// readOnlyResponse refuses to write the directory on the read-only filesystem, instead of the dd errors
+func readOnlyResponse(ctx context.Context, mount exec.MountInfo, directory string) *spec.Response {
+	reason := fmt.Sprintf("it's on the read-only %s filesystem %s from %s mounted with %s",
+		mount.FsType, mount.MountPoint, mount.Source, strings.Join(mount.Options, ","))
+	log.Errorf(ctx, "`%s`: %s", directory, reason)
+	return spec.ResponseFailWithFlags(spec.ParameterIllegal, "path", directory, reason)
+}
+
+// readSourceKey is the context key of the existing file or the device which the read burn reads on the
+// read-only filesystem, instead of the file created by it
+type readSourceKey struct{}
@@ -0,0 +1,97 @@
+//go:build linux
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package disk
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	chaosexec "github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// mountReadOnly bind mounts the directory on itself read-only, the test is skipped without the permission
+func mountReadOnly(t *testing.T, directory string) {
+	if output, err := exec.Command("mount", "--bind", directory, directory).CombinedOutput(); err != nil {
+		t.Skipf("bind mount is not permitted, %s", output)
+	}
+	t.Cleanup(func() { exec.Command("umount", directory).Run() })
+	if output, err := exec.Command("mount", "-o", "remount,bind,ro", directory).CombinedOutput(); err != nil {
+		t.Skipf("remount read-only is not permitted, %s", output)
+	}
+}
+
+func TestReadOnlyFilesystem(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "ro")
+	if err := os.MkdirAll(filepath.Join(directory, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(directory, "data", "app.log"), []byte("chaosblade"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mountReadOnly(t, directory)
+	ctx := context.Background()
+	cl := channel.NewLocalChannel()
+
+	mount, readOnly := readOnlyMount(ctx, cl, directory)
+	if !readOnly || mount.MountPoint != directory {
+		t.Fatalf("expected the read-only mount of %s, got %+v", directory, mount)
+	}
+	if _, readOnly := readOnlyMount(ctx, cl, filepath.Dir(directory)); readOnly {
+		t.Errorf("the parent of the read-only bind mount is taken as read-only")
+	}
+
+	fill := &FillActionExecutor{channel: cl}
+	response := fill.Exec("uid", ctx, &spec.ExpModel{ActionFlags: map[string]string{
+		"path": directory, "size": "1", chaosexec.ForceFlagName: "true",
+	}})
+	if response.Success || response.Code != spec.ParameterIllegal.Code || !strings.Contains(response.Err, directory) ||
+		!strings.Contains(response.Err, "ro,") {
+		t.Errorf("expected the fill refused naming the mount and its options, got %+v", response)
+	}
+	burn := &BurnIOExecutor{channel: cl}
+	response = burn.Exec("uid", ctx, &spec.ExpModel{ActionFlags: map[string]string{"path": directory, "write": "true"}})
+	if response.Success || response.Code != spec.ParameterIllegal.Code || !strings.Contains(response.Err, "read-only") {
+		t.Errorf("expected the write burn refused, got %+v", response)
+	}
+
+	// the read burn reads the existing file instead of creating one
+	source, response := readOnlySource(ctx, mount, directory)
+	if response != nil || source != filepath.Join(directory, "data", "app.log") {
+		t.Errorf("expected to read the existing file, got %s %v", source, response)
+	}
+	if err := exec.Command("umount", directory).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(directory, "data", "app.log")); err != nil {
+		t.Fatal(err)
+	}
+	mountReadOnly(t, directory)
+	// without any file, only the block device of the filesystem is read
+	source, response = readOnlySource(ctx, mount, directory)
+	if response == nil && source != mount.Source {
+		t.Errorf("expected to read the device %s, got %s", mount.Source, source)
+	}
+}
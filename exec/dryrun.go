@@ -0,0 +1,163 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// DryRunKey is the context key which enables the dry-run, the executor runs with the DryRunChannel then
+const DryRunKey = "dry-run"
+
+// DryRunSupport describes how the plan of an action is recorded by the DryRunChannel
+type DryRunSupport int
+
+const (
+	// DryRunUnsupported means the action changes the system without the channel, so it can not run in dry-run
+	DryRunUnsupported DryRunSupport = iota
+	// DryRunNative means the action handles the dry-run flag itself, such as process kill
+	DryRunNative
+	// DryRunIncomplete means some commands depend on the results of the recorded commands, which are assumed
+	DryRunIncomplete
+	// DryRunComplete means all the commands which change the system are recorded
+	DryRunComplete
+)
+
+// WithDryRun returns the context which enables the dry-run
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, DryRunKey, spec.True)
+}
+
+// IsDryRun returns true if the dry-run is enabled in the context
+func IsDryRun(ctx context.Context) bool {
+	value, ok := ctx.Value(DryRunKey).(string)
+	return ok && value == spec.True
+}
+
+// PlannedCommand is the command recorded by the DryRunChannel
+type PlannedCommand struct {
+	Script string `json:"script"`
+	Args   string `json:"args"`
+	// Assumed is true if the result of the command is declared by the executor
+	Assumed bool `json:"assumed,omitempty"`
+}
+
+// DryRunPlan is the result of the experiment in dry-run
+type DryRunPlan struct {
+	Target     string           `json:"target"`
+	Action     string           `json:"action"`
+	Commands   []PlannedCommand `json:"commands"`
+	Incomplete bool             `json:"incomplete,omitempty"`
+	// Success and Error are the response of the executor with the recorded commands
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DryRunChannel records the commands instead of running them, the other methods, such as the process
+// lookup, are delegated to the wrapped channel because they don't change the system.
+type DryRunChannel struct {
+	spec.Channel
+	lock        sync.Mutex
+	commands    []PlannedCommand
+	assumptions map[string]*spec.Response
+}
+
+func NewDryRunChannel(channel spec.Channel) *DryRunChannel {
+	return &DryRunChannel{
+		Channel:     channel,
+		commands:    make([]PlannedCommand, 0),
+		assumptions: make(map[string]*spec.Response),
+	}
+}
+
+func (d *DryRunChannel) Name() string {
+	return "dry-run"
+}
+
+// Run records the command, and returns the assumed response of the script or an empty success
+func (d *DryRunChannel) Run(ctx context.Context, script, args string) *spec.Response {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	response, assumed := d.assumptions[strings.TrimSpace(script)]
+	d.commands = append(d.commands, PlannedCommand{Script: script, Args: args, Assumed: assumed})
+	if assumed {
+		return response
+	}
+	return spec.ReturnSuccess("")
+}
+
+// Commands returns the recorded commands in order
+func (d *DryRunChannel) Commands() []PlannedCommand {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return append([]PlannedCommand{}, d.commands...)
+}
+
+// AssumeResponse declares the response of the script in dry-run, it's used by the executors which branch
+// on the command output, so that the plan goes on the branch changing the system. It does nothing if the
+// channel is not a DryRunChannel.
+func AssumeResponse(cl spec.Channel, script string, response *spec.Response) {
+	if d, ok := cl.(*DryRunChannel); ok {
+		d.lock.Lock()
+		d.assumptions[strings.TrimSpace(script)] = response
+		d.lock.Unlock()
+	}
+}
+
+// RunReadOnly runs the command which doesn't change the system, such as checking whether a file exists,
+// it's run by the wrapped channel in dry-run, so the plan is based on the real state.
+func RunReadOnly(ctx context.Context, cl spec.Channel, script, args string) *spec.Response {
+	if d, ok := cl.(*DryRunChannel); ok {
+		return d.Channel.Run(ctx, script, args)
+	}
+	return cl.Run(ctx, script, args)
+}
+
+// ExecDryRun runs the experiment with the DryRunChannel wrapping the channel, and returns the plan
+// in the result. The executor keeps the DryRunChannel, so set the channel again before reusing it.
+func ExecDryRun(ctx context.Context, executor spec.Executor, cl spec.Channel, uid string,
+	expModel *spec.ExpModel, support DryRunSupport) *spec.Response {
+	ctx = WithDryRun(ctx)
+	if support == DryRunNative {
+		expModel.ActionFlags[DryRunKey] = spec.True
+		executor.SetChannel(cl)
+		return executor.Exec(uid, ctx, expModel)
+	}
+	if support == DryRunUnsupported {
+		return spec.ResponseFailWithFlags(spec.ActionNotSupport, "dry-run of "+expModel.Target+" "+expModel.ActionName)
+	}
+	dryRunChannel := NewDryRunChannel(cl)
+	executor.SetChannel(dryRunChannel)
+	response := executor.Exec(uid, ctx, expModel)
+	plan := DryRunPlan{
+		Target:     expModel.Target,
+		Action:     expModel.ActionName,
+		Commands:   dryRunChannel.Commands(),
+		Incomplete: support == DryRunIncomplete,
+		Success:    response.Success,
+		Error:      response.Err,
+	}
+	if !response.Success {
+		response.Result = plan
+		return response
+	}
+	return spec.ReturnSuccess(plan)
+}
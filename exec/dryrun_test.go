@@ -0,0 +1,64 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestDryRunChannel(t *testing.T) {
+	mock := channel.NewMockLocalChannel().(*channel.MockLocalChannel)
+	executed := make([]string, 0)
+	mock.RunFunc = func(ctx context.Context, script, args string) *spec.Response {
+		executed = append(executed, script)
+		return spec.ReturnSuccess("true")
+	}
+	cl := NewDryRunChannel(mock)
+	ctx := context.Background()
+
+	AssumeResponse(cl, "grep", spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+	if response := cl.Run(ctx, "grep", "-q pair /etc/hosts"); response.Success {
+		t.Errorf("unexpected success of the assumed grep")
+	}
+	if response := cl.Run(ctx, "echo", "pair >> /etc/hosts"); !response.Success || response.Result != "" {
+		t.Errorf("unexpected response %v", response)
+	}
+	if !CheckFilepathExists(ctx, cl, "/etc/hosts") {
+		t.Errorf("the read only command should be run by the wrapped channel")
+	}
+
+	commands := cl.Commands()
+	expected := []PlannedCommand{
+		{Script: "grep", Args: "-q pair /etc/hosts", Assumed: true},
+		{Script: "echo", Args: "pair >> /etc/hosts"},
+	}
+	if len(commands) != len(expected) {
+		t.Fatalf("unexpected commands %v", commands)
+	}
+	for i := range expected {
+		if commands[i] != expected[i] {
+			t.Errorf("command %d, expected %v, got %v", i, expected[i], commands[i])
+		}
+	}
+	if len(executed) != 1 {
+		t.Errorf("only the read only command should be executed, got %v", executed)
+	}
+}
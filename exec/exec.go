@@ -19,36 +19,54 @@ package exec
 import (
 	"context"
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/shirou/gopsutil/process"
 )
 
 // todo
 var cl = channel.NewLocalChannel()
 
+// ProcessHangChecker is implemented by the actions which keep running only in some modes, such as the
+// periodic re-apply of process affinity, the ProcessHang of the spec is the result of the hanging mode
+type ProcessHangChecker interface {
+	// IsProcessHang returns whether the action keeps running until destroyed with the flags
+	IsProcessHang(flags map[string]string) bool
+}
+
 // stop hang process
 func Destroy(ctx context.Context, c spec.Channel, action string) *spec.Response {
 	suid := ctx.Value(spec.Uid)
-	/* If suid is specified, it will be deleted exactly
-	 * according to suid, otherwise it will be based on action. */
-	if suid != nil && suid != spec.UnknownUid && suid != "" {
-		ctx = context.WithValue(ctx, channel.ProcessKey, suid)
-	} else {
-		ctx = context.WithValue(ctx, channel.ProcessKey, action)
+	if uid, ok := suid.(string); ok && uid != "" {
+		// the metrics are removed after the process is killed, so they are not written again
+		defer RemoveMetrics(ctx, uid)
+		// the chaos process recorded in the pid file is killed directly, see RecordPid
+		if _, err := os.Stat(GetPidFile(uid)); err == nil {
+			return DestroyByPidFile(ctx, uid)
+		}
 	}
-
 	// Adapt to old versions.
-	originalBin := ctx.Value("bin")
-	pids := make([]string, 0)
-	if originalBin != nil {
-		originalPids, _ := cl.GetPidsByProcessName(originalBin.(string), ctx)
-		pids = append(pids, originalPids...)
+	bins := []string{spec.ChaosOsBin}
+	if originalBin, ok := ctx.Value("bin").(string); ok && originalBin != "" {
+		bins = append(bins, originalBin)
+	}
+	var pids []string
+	/* If suid is specified, only the processes with the uid in the command line are deleted,
+	 * otherwise or if they are not found, it will be based on action. */
+	if uid, ok := suid.(string); ok && uid != "" && uid != spec.UnknownUid {
+		pids = filterPidsByUid(findChaosPids(ctx, uid, bins), uid)
+		if len(pids) == 0 {
+			// the legacy processes started without the uid
+			pids = filterPidsByUid(findChaosPids(ctx, action, bins), "")
+		}
+	} else {
+		pids = findChaosPids(ctx, action, bins)
 	}
-
-	ps, _ := cl.GetPidsByProcessName(spec.ChaosOsBin, ctx)
-	pids = append(ps, pids...)
 	if len(pids) == 0 {
 		// If no processes found, consider the destroy operation successful
 		// This can happen when processes have already been cleaned up or never existed
@@ -57,10 +75,55 @@ func Destroy(ctx context.Context, c spec.Channel, action string) *spec.Response
 	return cl.Run(ctx, "kill", fmt.Sprintf(`-9 %s`, strings.Join(pids, " ")))
 }
 
+// findChaosPids returns the pids of the processes started from the bins, whose command lines contain the keyword
+func findChaosPids(ctx context.Context, keyword string, bins []string) []string {
+	ctx = context.WithValue(ctx, channel.ProcessKey, keyword)
+	pids := make([]string, 0)
+	for _, bin := range bins {
+		ps, _ := cl.GetPidsByProcessName(bin, ctx)
+		pids = append(pids, ps...)
+	}
+	return pids
+}
+
+// filterPidsByUid keeps the processes whose uid flag in the command line is the uid, the empty uid keeps
+// the processes without the uid flag
+func filterPidsByUid(pids []string, uid string) []string {
+	result := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		value, err := strconv.ParseInt(pid, 10, 32)
+		if err != nil {
+			continue
+		}
+		p, err := process.NewProcess(int32(value))
+		if err != nil {
+			continue
+		}
+		args, err := p.CmdlineSlice()
+		if err != nil {
+			continue
+		}
+		if parseUidFromArgs(args) == uid {
+			result = append(result, pid)
+		}
+	}
+	return result
+}
+
+// CheckFilepathExists returns true if the file exists. On the remote filesystem marked by WithRemoteFilesystem,
+// the file is stat directly, only the missing file is taken as absent, the other errors such as the stale handle
+// are taken as existing, so the file isn't overwritten by mistake.
 func CheckFilepathExists(ctx context.Context, cl spec.Channel, filepath string) bool {
-	response := cl.Run(ctx, fmt.Sprintf("[ -e %s ] && echo true || echo false", filepath), "")
-	if response.Success && strings.Contains(response.Result.(string), "true") {
+	if _, ok := RemoteFilesystem(ctx); ok {
+		response := RunReadOnlyArgv(ctx, cl, "stat", "--", filepath)
+		if response.Success {
+			return true
+		}
+		if strings.Contains(response.Err, "No such file") {
+			return false
+		}
+		log.Warnf(ctx, "stat %s on the remote filesystem failed, it's taken as existing, %s", filepath, response.Err)
 		return true
 	}
-	return false
+	return RunReadOnlyArgv(ctx, cl, "test", "-e", filepath).Success
 }
@@ -17,11 +17,16 @@
 package exec
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/containerd/cgroups"
+
+	cgroupsv2 "github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
 )
 
 func PidPath(pid int) cgroups.Path {
@@ -44,6 +49,39 @@ func PidPath(pid int) cgroups.Path {
 	}
 }
 
+// ScopedPidPath returns the cgroup paths of the pid, the paths of the cpu, cpuacct and memory controllers are the
+// nearest ancestors which own the cpu and memory limits if the cgroup scope of the ctx is limit-owner. cpuacct
+// counts the usage of the descendants, so does memory unless memory.use_hierarchy is 0, see MemoryUsageV1.
+func ScopedPidPath(ctx context.Context, root string, pid int) cgroups.Path {
+	path := PidPath(pid)
+	if cgroupsv2.GetCGroupScope(ctx) != cgroupsv2.CGroupScopeLimitOwner {
+		return path
+	}
+	return func(name cgroups.Name) (string, error) {
+		p, err := path(name)
+		if err != nil {
+			return p, err
+		}
+		controller := string(name)
+		if name == cgroups.Cpuacct {
+			controller = string(cgroups.Cpu)
+		}
+		if controller != string(cgroups.Cpu) && controller != string(cgroups.Memory) {
+			return p, nil
+		}
+		owner := cgroupsv2.LimitOwnerV1(filepath.Join(root, controller), p, controller)
+		if owner == p {
+			return p, nil
+		}
+		// cpuacct may be mounted apart from cpu
+		if _, err := os.Stat(filepath.Join(root, string(name), owner)); err != nil {
+			return p, nil
+		}
+		log.Debugf(ctx, "the %s limit of %s is owned by %s, measure %s there", controller, p, owner, name)
+		return owner, nil
+	}
+}
+
 func Hierarchy(root string) func() ([]cgroups.Subsystem, error) {
 	return func() ([]cgroups.Subsystem, error) {
 		subsystems, err := defaults(root)
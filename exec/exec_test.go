@@ -0,0 +1,95 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	osexec "os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+type fakeProcess struct {
+	exited chan struct{}
+}
+
+// startFakeChaosProcess starts the shell whose command line looks like the chaos process, the sleep is not
+// the last command, so the shell is not replaced by it
+func startFakeChaosProcess(t *testing.T, args ...string) *fakeProcess {
+	t.Helper()
+	cmd := osexec.Command("sh", append([]string{"-c", "sleep 30; :", spec.ChaosOsBin, "create", "file", "append"}, args...)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start the fake chaos process failed, %v", err)
+	}
+	p := &fakeProcess{exited: make(chan struct{})}
+	go func() {
+		_ = cmd.Wait()
+		close(p.exited)
+	}()
+	t.Cleanup(func() {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-p.exited
+	})
+	return p
+}
+
+// killed returns true if the process exits in the timeout, the kill -9 returns after the signal is sent
+func (p *fakeProcess) killed(timeout time.Duration) bool {
+	select {
+	case <-p.exited:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestDestroyByUid(t *testing.T) {
+	first := startFakeChaosProcess(t, "--filepath", "/tmp/a", "--uid", "destroy-uid-a")
+	second := startFakeChaosProcess(t, "--filepath", "/tmp/b", "--uid=destroy-uid-b")
+	legacy := startFakeChaosProcess(t, "--filepath", "/tmp/c")
+	// the process of the uid with the same prefix isn't destroyed
+	prefixed := startFakeChaosProcess(t, "--filepath", "/tmp/d", "--uid", "destroy-uid-a2")
+
+	ctx := context.WithValue(context.Background(), spec.Uid, "destroy-uid-a")
+	if response := Destroy(ctx, cl, "file append"); !response.Success {
+		t.Fatalf("Destroy() failed, %s", response.Err)
+	}
+	if !first.killed(time.Second) {
+		t.Errorf("the process of the uid is not destroyed")
+	}
+	if second.killed(100*time.Millisecond) || legacy.killed(0) || prefixed.killed(0) {
+		t.Fatalf("the other processes are destroyed")
+	}
+
+	// the legacy process without the uid is destroyed by the action if no process has the uid
+	ctx = context.WithValue(context.Background(), spec.Uid, "destroy-uid-c")
+	if response := Destroy(ctx, cl, "file append"); !response.Success {
+		t.Fatalf("Destroy() failed, %s", response.Err)
+	}
+	if !legacy.killed(time.Second) {
+		t.Errorf("the legacy process is not destroyed")
+	}
+	if second.killed(100*time.Millisecond) || prefixed.killed(0) {
+		t.Errorf("the processes with the other uids are destroyed")
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// FailureKind is the class of the failure, it decides the code of the response and the remediation hint
+// which is returned in the result
+type FailureKind struct {
+	Name string
+	Code spec.CodeType
+	Hint string
+}
+
+var (
+	BackupFailed = FailureKind{"BackupFailed", spec.OsCmdExecFailed,
+		"check the free space and the permission of the backup directory, then retry"}
+	RestoreFailed = FailureKind{"RestoreFailed", spec.OsCmdExecFailed,
+		"restore the resource manually from the backup or the state of the experiment, then destroy it again"}
+	RuleInstallFailed = FailureKind{"RuleInstallFailed", spec.OsCmdExecFailed,
+		"check the command and the kernel module of the rule are available, run with --check to see the readiness"}
+	RuleRemoveFailed = FailureKind{"RuleRemoveFailed", spec.OsCmdExecFailed,
+		"list the rules and remove the ones left by the experiment manually"}
+	StateRecordFailed = FailureKind{"StateRecordFailed", spec.OsCmdExecFailed,
+		"check the permission and the free space of the state directory"}
+	CgroupResolveFailed = FailureKind{"CgroupResolveFailed", spec.OsCmdExecFailed,
+		"check the cgroup filesystem is mounted, specify the cgroup-root flag if it's not detected"}
+	TargetNotFound = FailureKind{"TargetNotFound", spec.ParameterInvalid,
+		"check the target of the experiment exists on the host"}
+	UnsupportedPlatform = FailureKind{"UnsupportedPlatform", spec.ActionNotSupport,
+		"the action isn't supported on the platform, run with --check to see the ready actions"}
+	ProcessControlFailed = FailureKind{"ProcessControlFailed", spec.OsCmdExecFailed,
+		"check the process exists and is permitted to be signaled or started by the current user"}
+	SettingApplyFailed = FailureKind{"SettingApplyFailed", spec.OsCmdExecFailed,
+		"check the setting is writable, which requires root in most cases"}
+	ProtectedPathRefused = FailureKind{"ProtectedPathRefused", spec.Forbidden,
+		"choose a path out of the protected ones, or specify --force if the experiment is intended"}
+	LoopbackRefused = FailureKind{"LoopbackRefused", spec.Forbidden,
+		"choose an interface and ips out of the loopback, or specify --affect-loopback if the experiment is intended"}
+	PortInUse = FailureKind{"PortInUse", spec.Forbidden,
+		"stop the process listening on the port, or specify --force to kill it"}
+	LockContention = FailureKind{"LockContention", spec.OsCmdExecFailed,
+		"another process such as kube-proxy holds the xtables lock, retry later"}
+	CommandFailed = FailureKind{"CommandFailed", spec.OsCmdExecFailed,
+		"see the err of the response for the output of the command, run with --debug for the full log"}
+)
+
+// Failure is the machine-readable result of the failed response, see Fail
+type Failure struct {
+	Kind      string `json:"kind"`
+	Subsystem string `json:"subsystem"`
+	Operation string `json:"operation,omitempty"`
+	Hint      string `json:"hint,omitempty"`
+	// LogTail is the tail of the experiment log, see WithExperimentLogTail
+	LogTail *ExperimentLogTail `json:"logTail,omitempty"`
+}
+
+// Fail returns the failed response of the kind with the message, the result is the Failure of the subsystem
+// and the operation
+func Fail(kind FailureKind, subsystem, operation, message string) *spec.Response {
+	return WithFailure(spec.ReturnFail(kind.Code, message), kind, subsystem, operation)
+}
+
+// FailWithFlags is Fail with the message formatted by the code of the kind, the operation is the first flag,
+// so it's only used by the kinds of OsCmdExecFailed whose format is "`operation`: cmd exec failed, err: %v"
+func FailWithFlags(kind FailureKind, subsystem, operation string, err interface{}) *spec.Response {
+	return WithFailure(spec.ResponseFailWithFlags(kind.Code, operation, err), kind, subsystem, operation)
+}
+
+// WithFailure sets the Failure to the result of the failed response, the response returned by the channel
+// is passed through in this way. The successful response and the response with a result are returned as is.
+func WithFailure(response *spec.Response, kind FailureKind, subsystem, operation string) *spec.Response {
+	if response == nil || response.Success || response.Result != nil {
+		return response
+	}
+	response.Result = &Failure{
+		Kind:      kind.Name,
+		Subsystem: subsystem,
+		Operation: operation,
+		Hint:      kind.Hint,
+	}
+	return response
+}
+
+// GetFailure returns the Failure of the response, which is decoded as a map if the response is returned
+// by the remote blade
+func GetFailure(response *spec.Response) (*Failure, bool) {
+	if response == nil || response.Success {
+		return nil, false
+	}
+	switch result := response.Result.(type) {
+	case *Failure:
+		return result, true
+	case Failure:
+		return &result, true
+	case map[string]interface{}:
+		kind, _ := result["kind"].(string)
+		if kind == "" {
+			return nil, false
+		}
+		failure := &Failure{Kind: kind}
+		failure.Subsystem, _ = result["subsystem"].(string)
+		failure.Operation, _ = result["operation"].(string)
+		failure.Hint, _ = result["hint"].(string)
+		return failure, true
+	}
+	return nil, false
+}
+
+type failureExecutor struct {
+	spec.Executor
+}
+
+// WithFailurePayload wraps the executor, so that the failed response of the command which is passed through
+// without a result gets the Failure of CommandFailed, the subsystem is the target of the experiment.
+// It wraps WithStatusPhase rather than being wrapped, which asserts the StatusChecker of the executor.
+func WithFailurePayload(executor spec.Executor) spec.Executor {
+	if executor == nil {
+		return nil
+	}
+	if _, ok := executor.(*failureExecutor); ok {
+		return executor
+	}
+	return &failureExecutor{Executor: executor}
+}
+
+func (f *failureExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	response := f.Executor.Exec(uid, ctx, model)
+	if response == nil || response.Code != spec.OsCmdExecFailed.Code {
+		return response
+	}
+	return WithFailure(response, CommandFailed, model.Target, model.ActionName)
+}
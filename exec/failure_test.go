@@ -0,0 +1,156 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestFail(t *testing.T) {
+	response := Fail(BackupFailed, "hosts", "backup", "backup hosts file failed")
+	if response.Success || response.Code != spec.OsCmdExecFailed.Code || response.Err != "backup hosts file failed" {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	failure, ok := GetFailure(response)
+	if !ok || failure.Kind != "BackupFailed" || failure.Subsystem != "hosts" || failure.Operation != "backup" ||
+		failure.Hint != BackupFailed.Hint {
+		t.Fatalf("unexpected failure %+v", failure)
+	}
+
+	// the remote blade returns the failure in json
+	bytes, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, ok := GetFailure(spec.Decode(string(bytes), nil))
+	if !ok || *decoded != *failure {
+		t.Errorf("unexpected decoded failure %+v", decoded)
+	}
+
+	response = FailWithFlags(StateRecordFailed, "arp", "writeArpState", "permission denied")
+	if expected := spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writeArpState", "permission denied").Err; response.Err != expected {
+		t.Errorf("expected err %s, got %s", expected, response.Err)
+	}
+	if response := Fail(UnsupportedPlatform, "hwclock", "hwclock -w", "not available"); response.Code != spec.ActionNotSupport.Code {
+		t.Errorf("expected the code of the kind, got %d", response.Code)
+	}
+}
+
+type failingExecutor struct {
+	fakeExecutor
+	response *spec.Response
+}
+
+func (f *failingExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	return f.response
+}
+
+func TestWithFailurePayload(t *testing.T) {
+	model := &spec.ExpModel{Target: "network", ActionName: "drop"}
+	executor := &failingExecutor{response: spec.ReturnFail(spec.OsCmdExecFailed, "iptables failed")}
+	failure, ok := GetFailure(WithFailurePayload(executor).Exec("uid", context.Background(), model))
+	if !ok || failure.Kind != CommandFailed.Name || failure.Subsystem != "network" || failure.Operation != "drop" {
+		t.Fatalf("unexpected failure %+v", failure)
+	}
+
+	executor.response = FailWithFlags(RuleRemoveFailed, "iptables", "iptables-restore", "failed")
+	if failure, _ := GetFailure(WithFailurePayload(executor).Exec("uid", context.Background(), model)); failure.Kind != RuleRemoveFailed.Name {
+		t.Errorf("expected the failure of the executor kept, got %+v", failure)
+	}
+	executor.response = spec.ResponseFailWithFlags(spec.ParameterLess, "ip")
+	if response := WithFailurePayload(executor).Exec("uid", context.Background(), model); response.Result != nil {
+		t.Errorf("expected the parameter failure unchanged, got %+v", response.Result)
+	}
+}
+
+func TestWithExperimentLogTailIntoFailure(t *testing.T) {
+	logDir := t.TempDir()
+	if err := os.WriteFile(GetExperimentLogFile(logDir, "uid"), []byte("restore failed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	response := WithExperimentLogTail(Fail(RestoreFailed, "arp", "restore", "failed"), logDir, "uid")
+	failure, ok := response.Result.(*Failure)
+	if !ok || failure.LogTail == nil || len(failure.LogTail.Lines) != 1 {
+		t.Fatalf("expected the log tail in the failure, %+v", response.Result)
+	}
+}
+
+// TestNoBareOsCmdExecFailed asserts the executors create the failures of OsCmdExecFailed by Fail, so that
+// the result has the subsystem and the hint
+func TestNoBareOsCmdExecFailed(t *testing.T) {
+	constructors := map[string]bool{"ReturnFail": true, "ResponseFailWithFlags": true, "ResponseFail": true}
+	for _, dir := range []string{"file", "network", "cpu", "mem", "time"} {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			if strings.HasSuffix(file, "_test.go") {
+				continue
+			}
+			fset := token.NewFileSet()
+			node, err := parser.ParseFile(fset, file, nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast.Inspect(node, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.CallExpr:
+					if isSpecSelector(n.Fun, constructors) && len(n.Args) > 0 && isOsCmdExecFailed(n.Args[0]) {
+						t.Errorf("%s: bare OsCmdExecFailed, use Fail or FailWithFlags instead", fset.Position(n.Pos()))
+					}
+				case *ast.CompositeLit:
+					if isSpecSelector(n.Type, map[string]bool{"Response": true}) {
+						for _, elt := range n.Elts {
+							if kv, ok := elt.(*ast.KeyValueExpr); ok && isOsCmdExecFailed(kv.Value) {
+								t.Errorf("%s: bare OsCmdExecFailed, use Fail or FailWithFlags instead", fset.Position(n.Pos()))
+							}
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+}
+
+func isSpecSelector(expr ast.Expr, names map[string]bool) bool {
+	selector, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := selector.X.(*ast.Ident)
+	return ok && pkg.Name == "spec" && names[selector.Sel.Name]
+}
+
+// isOsCmdExecFailed returns true for spec.OsCmdExecFailed and spec.OsCmdExecFailed.Code
+func isOsCmdExecFailed(expr ast.Expr) bool {
+	if selector, ok := expr.(*ast.SelectorExpr); ok && selector.Sel.Name == "Code" {
+		expr = selector.X
+	}
+	return isSpecSelector(expr, map[string]bool{"OsCmdExecFailed": true})
+}
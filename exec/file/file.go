@@ -17,7 +17,11 @@
 package file
 
 import (
+	"context"
+
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 )
 
 type FileCommandSpec struct {
@@ -39,6 +43,35 @@ func NewFileCommandSpec() spec.ExpModelCommandSpec {
 	}
 }
 
+// forceFlag allows the experiment on the protected path, see exec.CheckProtectedPath
+var forceFlag = &spec.ExpFlag{
+	Name:   exec.ForceFlagName,
+	Desc:   "run the experiment on the protected path, such as /etc or the installation of the blade",
+	NoArgs: true,
+}
+
+// allowRemoteFsFlag allows the destructive experiment on the remote filesystem, see exec.CheckRemoteFilesystem
+var allowRemoteFsFlag = &spec.ExpFlag{
+	Name:   exec.AllowRemoteFsFlagName,
+	Desc:   "run the experiment on the remote filesystem such as NFS or CIFS, whose changes are seen by the other clients late and whose backups are transferred over the network",
+	NoArgs: true,
+}
+
+// remoteFsResult is the response of the experiment on the remote filesystem
+type remoteFsResult struct {
+	Result  interface{} `json:"result"`
+	Warning string      `json:"warning"`
+}
+
+// withRemoteFsWarning adds the warning to the successful response if the experiment is on the remote filesystem
+func withRemoteFsWarning(ctx context.Context, response *spec.Response) *spec.Response {
+	mount, ok := exec.RemoteFilesystem(ctx)
+	if !ok || !response.Success {
+		return response
+	}
+	return spec.ReturnSuccess(remoteFsResult{Result: response.Result, Warning: exec.RemoteFilesystemWarning(mount)})
+}
+
 var fileCommFlags = []spec.ExpFlagSpec{
 	&spec.ExpFlag{
 		Name:     "filepath",
@@ -21,12 +21,15 @@ import (
 	"encoding/base64"
 	"fmt"
 	"path"
+	"strconv"
+	"time"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
 )
 
 const AddFileBin = "chaos_addfile"
@@ -59,6 +62,23 @@ func NewFileAddActionSpec() spec.ExpActionCommandSpec {
 					Desc:   "automatically creates a directory that does not exist",
 					NoArgs: true,
 				},
+				&spec.ExpFlag{
+					Name: "size",
+					Desc: "generate the content of the size, unit is MB. The value is a positive integer without unit, or with the unit K, M, G or T. The file must leave 5% of the filesystem free",
+				},
+				&spec.ExpFlag{
+					Name: "fill",
+					Desc: "the generated content, random, zero or text which looks like the application logs, default random",
+				},
+				&spec.ExpFlag{
+					Name: "seed",
+					Desc: "the seed of the random and the text fills, which reproduces the content, the current time by default",
+				},
+				&spec.ExpFlag{
+					Name: "rate",
+					Desc: "the max size of the generated content written per second, unit is MB, unlimited by default",
+				},
+				forceFlag,
 			},
 			ActionExecutor: &FileAddActionExecutor{},
 			ActionExample: `
@@ -73,6 +93,9 @@ blade create file add --filepath /temp/nginx.log --auto-create-dir
 
 # Create a directory named /nginx in the /temp directory and automatically create directories that don't exist
 blade create file add --directory --filepath /temp/nginx --auto-create-dir
+
+# Create a file named backup.dat of 250MB random data in the /home directory, written at 50MB per second
+blade create file add --filepath /home/backup.dat --size 250M --fill random --rate 50M
 `,
 			ActionPrograms:   []string{AddFileBin},
 			ActionCategories: []string{category.SystemFile},
@@ -105,14 +128,20 @@ func (*FileAddActionExecutor) Name() string {
 }
 
 func (f *FileAddActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
-	commands := []string{"touch", "mkdir", "echo", "rm"}
+	commands := []string{"touch", "mkdir", "printf", "rm"}
 	if response, ok := f.channel.IsAllCommandsAvailable(ctx, commands); !ok {
 		return response
 	}
 
 	filepath := model.ActionFlags["filepath"]
 	if _, ok := spec.IsDestroy(ctx); ok {
-		return f.stop(filepath, ctx)
+		return f.stop(uid, filepath, ctx)
+	}
+
+	if model.ActionFlags[exec.ForceFlagName] != "true" {
+		if response := exec.CheckProtectedPath(ctx, f.channel, "filepath", filepath, false); response != nil {
+			return response
+		}
 	}
 
 	if exec.CheckFilepathExists(ctx, f.channel, filepath) {
@@ -124,22 +153,88 @@ func (f *FileAddActionExecutor) Exec(uid string, ctx context.Context, model *spe
 	content := model.ActionFlags["content"]
 	enableBase64 := model.ActionFlags["enable-base64"] == "true"
 	autoCreateDir := model.ActionFlags["auto-create-dir"] == "true"
+	generate, response := getGenerateOptions(model.ActionFlags)
+	if response != nil {
+		return response
+	}
+	if generate.size > 0 && (directory || content != "") {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "size", model.ActionFlags["size"],
+			"the content is generated, it can't be used with --directory or --content")
+	}
 
-	return f.start(f.channel, filepath, content, directory, enableBase64, autoCreateDir, ctx)
+	state, response := exec.NewExperimentState(ctx, uid, "file", "add", map[string]string{"filepath": filepath})
+	if response != nil {
+		return response
+	}
+	response = f.start(f.channel, filepath, content, directory, enableBase64, autoCreateDir, generate, ctx)
+	if response != nil && !response.Success {
+		exec.ReleaseResources(ctx, uid)
+		return response
+	}
+	if err := state.AddUndo("rm", exec.ShellJoin("-rf", "--", filepath)); err != nil {
+		log.Errorf(ctx, "record the file %s failed, %v", filepath, err)
+		f.stop("", filepath, ctx)
+		return exec.Fail(exec.StateRecordFailed, "file", "AddUndo", fmt.Sprintf("record the file %s failed, %v", filepath, err))
+	}
+	return response
+}
+
+// getGenerateOptions returns the options of the generated content, the size is 0 without the size flag
+func getGenerateOptions(flags map[string]string) (generateOptions, *spec.Response) {
+	options := generateOptions{fill: FillRandom, seed: time.Now().UnixNano()}
+	if flags["size"] == "" {
+		return options, nil
+	}
+	var response *spec.Response
+	if options.size, response = validation.ValidateSizeBytes("size", flags["size"], validation.MB, 1); response != nil {
+		return options, response
+	}
+	switch fill := flags["fill"]; fill {
+	case "":
+	case FillRandom, FillZero, FillText:
+		options.fill = fill
+	default:
+		return options, spec.ResponseFailWithFlags(spec.ParameterIllegal, "fill", fill, "it must be random, zero or text")
+	}
+	if seed := flags["seed"]; seed != "" {
+		value, err := strconv.ParseInt(seed, 10, 64)
+		if err != nil {
+			return options, spec.ResponseFailWithFlags(spec.ParameterIllegal, "seed", seed, "it must be an integer")
+		}
+		options.seed, options.seeded = value, true
+	}
+	if rate := flags["rate"]; rate != "" {
+		if options.rate, response = validation.ValidateSizeBytes("rate", rate, validation.MB, 1); response != nil {
+			return options, response
+		}
+	}
+	return options, nil
 }
 
-func (f *FileAddActionExecutor) start(cl spec.Channel, filepath, content string, directory, enableBase64, autoCreateDir bool, ctx context.Context) *spec.Response {
-	dir := path.Dir(filepath)
+func (f *FileAddActionExecutor) start(cl spec.Channel, filepath, content string, directory, enableBase64, autoCreateDir bool,
+	generate generateOptions, ctx context.Context) *spec.Response {
+	// the top directory created by the auto-create-dir, it's changed to the owner of the container with the file
+	created := filepath
 	if autoCreateDir && !exec.CheckFilepathExists(ctx, cl, filepath) {
-		if response := f.channel.Run(ctx, "mkdir", fmt.Sprintf(`-p %s`, dir)); !response.Success {
+		created = topMissingDir(ctx, cl, filepath)
+		if response := exec.RunArgv(ctx, f.channel, "mkdir", "-p", "--", path.Dir(filepath)); !response.Success {
 			return response
 		}
 	}
+	var response *spec.Response
 	if directory {
-		return f.channel.Run(ctx, "mkdir", fmt.Sprintf(`%s`, filepath))
+		response = exec.RunArgv(ctx, f.channel, "mkdir", "--", filepath)
+	} else if generate.size > 0 {
+		// the free space is checked once, the other writers may fill the filesystem during the generation
+		if total, available, err := fileSystemSpace(ctx, f.channel, path.Dir(filepath)); err != nil {
+			log.Warnf(ctx, "get the free space of %s failed, it's not checked, %v", path.Dir(filepath), err)
+		} else if err := checkGenerateSpace(generate.size, total, available); err != nil {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "size", generate.size, err.Error())
+		}
+		response = generateFile(ctx, f.channel, filepath, generate)
 	} else {
 		if content == "" {
-			return f.channel.Run(ctx, "touch", fmt.Sprintf(`%s`, filepath))
+			response = exec.RunArgv(ctx, f.channel, "touch", "--", filepath)
 		} else {
 			if enableBase64 {
 				if decodeBytes, err := base64.StdEncoding.DecodeString(content); err != nil {
@@ -149,13 +244,33 @@ func (f *FileAddActionExecutor) start(cl spec.Channel, filepath, content string,
 					content = string(decodeBytes)
 				}
 			}
-			return f.channel.Run(ctx, "echo", fmt.Sprintf(`-e "%s" >> "%s"`, content, filepath))
+			response = exec.AppendFile(ctx, f.channel, filepath, interpretEscapes(content)+"\n")
 		}
 	}
+	if !response.Success {
+		return response
+	}
+	if chown := chownToContainer(ctx, f.channel, created, created != filepath); chown != nil {
+		return chown
+	}
+	return response
 }
 
-func (f *FileAddActionExecutor) stop(filepath string, ctx context.Context) *spec.Response {
-	return f.channel.Run(ctx, "rm", fmt.Sprintf(`-rf %s`, filepath))
+// topMissingDir returns the top directory of the filepath which doesn't exist
+func topMissingDir(ctx context.Context, cl spec.Channel, filepath string) string {
+	top := filepath
+	for dir := path.Dir(filepath); dir != "/" && dir != "." && !exec.CheckFilepathExists(ctx, cl, dir); dir = path.Dir(dir) {
+		top = dir
+	}
+	return top
+}
+
+// stop removes the recorded file, or the file of the flags if it's not recorded
+func (f *FileAddActionExecutor) stop(uid, filepath string, ctx context.Context) *spec.Response {
+	if response, ok := exec.DestroyByState(ctx, f.channel, uid); ok {
+		return response
+	}
+	return exec.RunArgv(ctx, f.channel, "rm", "-rf", "--", filepath)
 }
 
 func (f *FileAddActionExecutor) SetChannel(channel spec.Channel) {
@@ -0,0 +1,84 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func execAdd(ctx context.Context, cl spec.Channel, uid string, flags map[string]string) *spec.Response {
+	executor := &FileAddActionExecutor{}
+	executor.SetChannel(cl)
+	return executor.Exec(uid, ctx, &spec.ExpModel{Target: "file", ActionName: "add", ActionFlags: flags})
+}
+
+func TestFileAddActionExecutorSize(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	df := spec.ReturnSuccess("Filesystem     1024-blocks    Used Available Capacity Mounted on\n" +
+		"/dev/vda1         10485760 5242880   5242880      50% /\n")
+	cl := exec.NewMockChannel().
+		OnRun("test", "", spec.ReturnFail(spec.OsCmdExecFailed, "not found")).
+		OnRun("df", "", df)
+
+	// 5G are available, 512M of the 10G filesystem are reserved
+	flags := map[string]string{"filepath": "/data/backup.dat", "size": "5G"}
+	if response := execAdd(ctx, cl, "add-1", flags); response.Success || response.Code != spec.ParameterIllegal.Code {
+		t.Fatalf("expected the size is refused, %+v", response)
+	}
+
+	cl.Reset()
+	flags = map[string]string{"filepath": "/data/backup.dat", "size": "250M", "fill": "zero"}
+	if response := execAdd(ctx, cl, "add-2", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"readlink -f -- /data/backup.dat",
+		"test -e /data/backup.dat",
+		"df -Pk -- /data",
+		"head -c 262144000 /dev/zero > /data/backup.dat",
+	})
+
+	// the recorded file is removed on destroy
+	cl.Reset()
+	if response := execAdd(spec.SetDestroyFlag(ctx, "add-2"), cl, "add-2", map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{"rm -rf -- /data/backup.dat"})
+
+	// the seed and the rate are supported on the host only
+	flags = map[string]string{"filepath": "/data/backup.dat", "size": "1M", "rate": "1M"}
+	if response := execAdd(ctx, cl, "add-3", flags); response.Success {
+		t.Errorf("expected the rate is refused in the channel")
+	}
+	for _, flags := range []map[string]string{
+		{"filepath": "/data/a", "size": "1M", "fill": "ones"},
+		{"filepath": "/data/a", "size": "1M", "content": "hello"},
+		{"filepath": "/data/a", "size": "1M", "seed": "x"},
+	} {
+		if response := execAdd(ctx, cl, "add-4", flags); response.Success || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the flags %v are refused, %+v", flags, response)
+		}
+	}
+}
@@ -19,7 +19,9 @@ package file
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"path"
 	"regexp"
@@ -32,6 +34,7 @@ import (
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
 )
 
 const AppendFileBin = "chaos_appendfile"
@@ -63,6 +66,11 @@ func NewFileAppendActionSpec() spec.ExpActionCommandSpec {
 					Desc:   "symbols to escape, use --escape, at this --count is invalid",
 					NoArgs: true,
 				},
+				&spec.ExpFlag{
+					Name:   "sync",
+					Desc:   "flush each batch of the count lines to the disk by fsync, the synced bytes are reported. With --interval, the batches missed since the fsync takes longer than the interval are reported too",
+					NoArgs: true,
+				},
 				&spec.ExpFlag{
 					Name:   "enable-base64",
 					Desc:   "append content enable base64 encoding",
@@ -70,10 +78,23 @@ func NewFileAppendActionSpec() spec.ExpActionCommandSpec {
 				},
 				&spec.ExpFlag{
 					Name:     "cgroup-root",
-					Desc:     "cgroup root path, default value /sys/fs/cgroup",
+					Desc:     "cgroup root path, it's detected from the cgroup mounts if absent",
 					NoArgs:   false,
 					Required: false,
-					Default:  "/sys/fs/cgroup",
+					Default:  "",
+				},
+				&spec.ExpFlag{
+					Name:    "create-dirs",
+					Desc:    "create the missing parent directories of the file, default true",
+					Default: "true",
+				},
+				&spec.ExpFlag{
+					Name: "max-create-depth",
+					Desc: "the max number of the missing parent directories created, the append fails with the first missing one if more are missing, unlimited by default",
+				},
+				&spec.ExpFlag{
+					Name: "dir-mode",
+					Desc: "the octal permission bits of the parent directories created, such as 0755, the default of the umask is used if absent",
 				},
 				&spec.ExpFlag{
 					Name:   "enable-backup",
@@ -82,9 +103,14 @@ func NewFileAppendActionSpec() spec.ExpActionCommandSpec {
 				},
 				&spec.ExpFlag{
 					Name:   "delete-file",
-					Desc:   "delete file on destroy operation, default false. When used with enable-backup, this parameter has higher priority",
+					Desc:   "delete file on destroy operation, default false. When used with enable-backup, this parameter has higher priority. The empty parent directories created by the experiment are removed too",
 					NoArgs: true,
 				},
+				&spec.ExpFlag{
+					Name: "rotated-restore",
+					Desc: "how the backup is restored on destroy if the file is rotated or replaced since the backup, such as by logrotate. skip leaves the file and the backup alone, aside restores the backup to <filepath>.chaosblade-restored for the manual review, default skip",
+				},
+				forceFlag,
 			},
 			ActionExecutor: &FileAppendActionExecutor{},
 			ActionExample: `
@@ -103,9 +129,18 @@ blade create file append --filepath=/home/logs/nginx.log --content="HELLO WORLD"
 # Appends content and delete file on destroy (delete-file has higher priority than enable-backup)
 blade create file append --filepath=/home/logs/nginx.log --content="HELLO WORLD" --delete-file=true
 
+# Appends content with backup, if the file is rotated by logrotate before destroy, the backup is restored to /home/logs/nginx.log.chaosblade-restored
+blade create file append --filepath=/home/logs/nginx.log --content="HELLO WORLD" --enable-backup=true --delete-file=true --rotated-restore aside
+
 # Appends content with backup but preserve file on destroy (delete-file=false overrides enable-backup=true)
 blade create file append --filepath=/home/logs/nginx.log --content="HELLO WORLD" --enable-backup=true --delete-file=false
 
+# Appends 100 lines to the /home/logs/app.log file every second, the lines reach the disk rather than the page cache
+blade create file append --filepath=/home/logs/app.log --content="HELLO WORLD" --count 100 --interval 1 --sync
+
+# Appends content, the parent directories are created with the mode 0750 unless more than 1 of them are missing
+blade create file append --filepath=/home/logs/app/app.log --content="HELLO WORLD" --max-create-depth 1 --dir-mode 0750
+
 # mock interface timeout exception
 blade create file append --filepath=/home/logs/nginx.log --content="@{DATE:+%Y-%m-%d %H:%M:%S} ERROR invoke getUser timeout [@{RANDOM:100-200}]ms abc  mock exception"
 `,
@@ -141,19 +176,38 @@ func (*FileAppendActionExecutor) Name() string {
 }
 
 func (f *FileAppendActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
-	commands := []string{"echo", "kill", "mkdir"}
-	if response, ok := f.channel.IsAllCommandsAvailable(ctx, commands); !ok {
+	commands := []string{"printf", "kill", "mkdir"}
+	if response, ok := checkFileCommands(ctx, f.channel, commands); !ok {
 		return response
 	}
 
-	filepath := model.ActionFlags["filepath"]
+	filepath := normalizePath(model.ActionFlags["filepath"])
+	rotated := model.ActionFlags["rotated-restore"]
+	switch rotated {
+	case "":
+		rotated = RotatedSkip
+	case RotatedSkip, RotatedAside:
+	default:
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "rotated-restore", rotated, "it must be skip or aside")
+	}
 	if _, ok := spec.IsDestroy(ctx); ok {
 		enableBackup := model.ActionFlags["enable-backup"] == "true" // default false
 		deleteFile := model.ActionFlags["delete-file"] == "true"     // default false
-		return f.stop(filepath, enableBackup, deleteFile, ctx)
+		return f.stop(filepath, enableBackup, deleteFile, rotated, ctx)
 	}
 
-	if !exec.CheckFilepathExists(ctx, f.channel, filepath) {
+	if model.ActionFlags[exec.ForceFlagName] != "true" {
+		if response := exec.CheckProtectedPath(ctx, f.channel, "filepath", filepath, false); response != nil {
+			return response
+		}
+	}
+
+	ctx, response := exec.CheckRemoteFilesystem(ctx, f.channel, "filepath", filepath, false, false)
+	if response != nil {
+		return response
+	}
+
+	if !fileExists(ctx, f.channel, filepath) {
 		log.Errorf(ctx, "`%s`: file-append-Exec-file does not exist", filepath)
 		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "filepath", filepath, "the file does not exist")
 	}
@@ -170,46 +224,51 @@ func (f *FileAppendActionExecutor) Exec(uid string, ctx context.Context, model *
 	countStr := model.ActionFlags["count"]
 	intervalStr := model.ActionFlags["interval"]
 	if countStr != "" {
-		var err error
-		count, err = strconv.Atoi(countStr)
-		if err != nil || count < 1 {
+		if count, response = validation.ValidateInt("count", countStr, 1, math.MaxInt); response != nil {
 			log.Errorf(ctx, "`%s` value must be a positive integer", "count")
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "count", count, "it must be a positive integer")
+			return response
 		}
 	}
 	if intervalStr != "" {
-		var err error
-		interval, err = strconv.Atoi(intervalStr)
-		if err != nil || interval < 1 {
+		if interval, response = validation.ValidateInt("interval", intervalStr, 1, math.MaxInt); response != nil {
 			log.Errorf(ctx, "`%s` value must be a positive integer", "interval")
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "interval", interval, "it must be a positive integer")
+			return response
 		}
 	}
 
+	dirs, response := getDirOptions(model.ActionFlags)
+	if response != nil {
+		return response
+	}
+	ctx = context.WithValue(ctx, dirOptionsKey{}, dirs)
+
 	escape := model.ActionFlags["escape"] == "true"
 	enableBase64 := model.ActionFlags["enable-base64"] == "true"
 	enableBackup := model.ActionFlags["enable-backup"] == "true" // default false
+	sync := model.ActionFlags["sync"] == "true"
+
+	return withRemoteFsWarning(ctx, f.start(filepath, content, count, interval, escape, enableBase64, enableBackup, sync, ctx))
+}
 
-	return f.start(filepath, content, count, interval, escape, enableBase64, enableBackup, ctx)
+// fileAppendResult is the response of the synced append
+type fileAppendResult struct {
+	File        string `json:"file"`
+	SyncedBytes int    `json:"syncedBytes"`
 }
 
-func (f *FileAppendActionExecutor) start(filepath string, content string, count int, interval int, escape bool, enableBase64 bool, enableBackup bool, ctx context.Context) *spec.Response {
+func (f *FileAppendActionExecutor) start(filepath string, content string, count int, interval int, escape bool, enableBase64 bool, enableBackup bool, sync bool, ctx context.Context) *spec.Response {
 	// Create backup of original file before appending content (if enabled and file exists)
 	if enableBackup {
 		uid := ctx.Value(spec.Uid)
 		if uid != nil && uid != spec.UnknownUid && uid != "" {
 			// Only create backup if the original file exists
-			if exec.CheckFilepathExists(ctx, f.channel, filepath) {
-				backupFile := filepath + ".chaos-blade-backup-" + uid.(string)
-				// Only create backup if it doesn't exist (to avoid overwriting existing backup)
-				if !exec.CheckFilepathExists(ctx, f.channel, backupFile) {
-					response := f.channel.Run(ctx, "cp", fmt.Sprintf(`"%s" "%s"`, filepath, backupFile))
-					if !response.Success {
-						log.Errorf(ctx, "Failed to create backup file: %s", response.Err)
-						// Continue with append operation even if backup fails
-					} else {
-						log.Infof(ctx, "Created backup file: %s", backupFile)
-					}
+			if fileExists(ctx, f.channel, filepath) {
+				// The recorded backup is returned if it exists, so the original content is never overwritten
+				if _, response := exec.Backup(ctx, f.channel, filepath, uid.(string)); response != nil {
+					log.Errorf(ctx, "Failed to create backup file: %s", response.Err)
+					// Continue with append operation even if backup fails
+				} else {
+					recordBackupStat(ctx, f.channel, uid.(string), filepath)
 				}
 			} else {
 				log.Infof(ctx, "File does not exist, skipping backup creation: %s", filepath)
@@ -218,12 +277,22 @@ func (f *FileAppendActionExecutor) start(filepath string, content string, count
 	}
 
 	// first append
-	response := appendFile(f.channel, count, ctx, content, filepath, escape, enableBase64)
+	created := !fileExists(ctx, f.channel, filepath)
+	f.recordOriginSize(ctx, filepath, created)
+	synced, response := appendFile(f.channel, count, ctx, content, filepath, escape, enableBase64, sync)
 	if !response.Success {
 		return response
 	}
+	if created {
+		if response := chownToContainer(ctx, f.channel, filepath, false); response != nil {
+			return response
+		}
+	}
 	// Without interval, it will not be executed regularly.
 	if interval < 1 {
+		if sync {
+			return spec.ReturnSuccess(fileAppendResult{File: filepath, SyncedBytes: synced})
+		}
 		return nil
 	}
 
@@ -232,14 +301,37 @@ func (f *FileAppendActionExecutor) start(filepath string, content string, count
 	ticker := time.NewTicker(time.Second * time.Duration(interval))
 	defer ticker.Stop()
 
+	uid, _ := ctx.Value(spec.Uid).(string)
+	metrics := exec.NewMetricsWriter(ctx, uid, "file", "append", "")
+	metrics.Add("appends", float64(count))
+	metrics.Add("synced_bytes", float64(synced))
+	metrics.Flush(ctx)
+	// the ticker drops the ticks while the fsync is slower than the interval, the missed batches are reported
+	// rather than the throughput drifts below the requested one silently
+	begin, batches, missed := time.Now(), 1, 0
 	for {
 		select {
 		case <-ticker.C:
-			response := appendFile(f.channel, count, ctx, content, filepath, escape, enableBase64)
+			start := time.Now()
+			synced, response := appendFile(f.channel, count, ctx, content, filepath, escape, enableBase64, sync)
+			batches++
 			if !response.Success {
 				log.Errorf(ctx, "Failed to append file content: %s", response.Err)
+				metrics.SetError(errors.New(response.Err))
 				// Continue running even if one append fails
+			} else {
+				metrics.Add("appends", float64(count))
+				metrics.Add("synced_bytes", float64(synced))
 			}
+			if sync {
+				metrics.Set("sync_seconds", time.Since(start).Seconds())
+				if behind := missedBatches(time.Since(begin), time.Duration(interval)*time.Second, batches); behind > missed {
+					log.Warnf(ctx, "the fsync is slower than the interval, %d batches of %d lines are missed", behind, count)
+					missed = behind
+					metrics.Set("missed_batches", float64(missed))
+				}
+			}
+			metrics.Flush(ctx)
 		case <-ctx.Done():
 			// Context cancelled, stop the ticker
 			log.Infof(ctx, "File append interval operation stopped")
@@ -248,7 +340,7 @@ func (f *FileAppendActionExecutor) start(filepath string, content string, count
 	}
 }
 
-func (f *FileAppendActionExecutor) stop(filepath string, enableBackup bool, deleteFile bool, ctx context.Context) *spec.Response {
+func (f *FileAppendActionExecutor) stop(filepath string, enableBackup bool, deleteFile bool, rotated string, ctx context.Context) *spec.Response {
 	// For file append operation, we need to handle both one-time and interval-based operations
 	// If it's an interval-based operation, we need to stop the chaos_os process first
 
@@ -260,14 +352,68 @@ func (f *FileAppendActionExecutor) stop(filepath string, enableBackup bool, dele
 	// In that case, we handle file restoration/deletion based on backup settings
 	if !response.Success {
 		log.Infof(ctx, "No running process found, treating as one-time operation")
-		return f.handleOneTimeOperation(filepath, enableBackup, deleteFile, ctx)
 	}
 
 	// For interval-based operations, we also need to handle file restoration/deletion
-	return f.handleOneTimeOperation(filepath, enableBackup, deleteFile, ctx)
+	response = f.handleOneTimeOperation(filepath, enableBackup, deleteFile, rotated, ctx)
+	if uid, _ := ctx.Value(spec.Uid).(string); uid != "" && response.Success {
+		// the record of the backups and the details isn't reported by the status after the destroy
+		exec.ReleaseResources(ctx, uid)
+	}
+	return response
+}
+
+// Status checks the appended content in the file, and the appending process if the interval is set
+func (f *FileAppendActionExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	filepath := normalizePath(model.ActionFlags["filepath"])
+	content := model.ActionFlags["content"]
+	artifact := exec.Artifact{Kind: "file", Name: filepath}
+	if !fileExists(ctx, f.channel, filepath) {
+		artifact.Detail = "the file does not exist"
+	} else if model.ActionFlags["enable-base64"] == "true" || model.ActionFlags["escape"] == "true" {
+		// the content is decoded or escaped when appended, so only the file is checked
+		artifact.Present = true
+	} else {
+		artifact.Present = fileContains(ctx, f.channel, filepath, content)
+		if !artifact.Present {
+			artifact.Detail = "the content is not found"
+		}
+	}
+	report.Add(artifact)
+	if state != nil && !state.Destroyed {
+		for _, change := range exec.BackupChanges(ctx, f.channel, state) {
+			report.AddFileChange(change)
+		}
+	}
+	report.Files = append(report.Files, appendChanges(ctx, f.channel, uid, filepath)...)
+	if model.ActionFlags["interval"] != "" {
+		report.Add(exec.ProcessArtifact(ctx, uid, model.Target, model.ActionName))
+		report.AddMetrics()
+	}
+	return report
 }
 
-func (f *FileAppendActionExecutor) handleOneTimeOperation(filepath string, enableBackup bool, deleteFile bool, ctx context.Context) *spec.Response {
+// recordOriginSize records the size of the file before the append, so the status reports the appended bytes
+func (f *FileAppendActionExecutor) recordOriginSize(ctx context.Context, filepath string, created bool) {
+	uid, _ := ctx.Value(spec.Uid).(string)
+	if uid == "" || uid == spec.UnknownUid {
+		return
+	}
+	var size int64
+	if !created {
+		var err error
+		if size, err = fileSize(ctx, f.channel, filepath); err != nil {
+			log.Warnf(ctx, "get the size of %s failed, the appended bytes are not reported, %v", filepath, err)
+			return
+		}
+	}
+	if response := exec.SetBackupDetail(ctx, uid, originSizeKey, strconv.FormatInt(size, 10)); response != nil {
+		log.Warnf(ctx, "record the size of %s failed, %s", filepath, response.Err)
+	}
+}
+
+func (f *FileAppendActionExecutor) handleOneTimeOperation(filepath string, enableBackup bool, deleteFile bool, rotated string, ctx context.Context) *spec.Response {
 	// Priority logic: delete-file parameter has higher priority than enable-backup
 	if deleteFile {
 		// If delete-file is true, handle based on backup settings
@@ -279,40 +425,44 @@ func (f *FileAppendActionExecutor) handleOneTimeOperation(filepath string, enabl
 				return spec.ReturnFail(spec.ParameterInvalid, "experiment UID is required for destroy operation")
 			}
 
-			// The backup file should be stored as .chaos-blade-backup-{uid}
-			backupFile := filepath + ".chaos-blade-backup-" + uid.(string)
+			// The backup isn't copied over the file rotated since, such as by logrotate
+			check := f.checkRotated(ctx, uid.(string), filepath)
+			if check != nil && check.Reason != "" {
+				return f.restoreRotated(ctx, uid.(string), *check, rotated)
+			}
 
-			// Check if backup file exists
-			if !exec.CheckFilepathExists(ctx, f.channel, backupFile) {
+			// Restore the original file content, the backup file is removed then
+			response, ok := exec.Restore(ctx, f.channel, uid.(string), filepath)
+			if !ok {
 				// If no backup file exists, it means the original file didn't exist
 				// In this case, we should delete the file that was created by the append operation
-				if exec.CheckFilepathExists(ctx, f.channel, filepath) {
-					response := f.channel.Run(ctx, "rm", fmt.Sprintf(`"%s"`, filepath))
+				if fileExists(ctx, f.channel, filepath) {
+					response := removeFile(ctx, f.channel, filepath)
 					if !response.Success {
 						log.Errorf(ctx, "Failed to delete created file: %s", response.Err)
 						return response
 					}
 					log.Infof(ctx, "Deleted file that was created by append operation: %s", filepath)
 				}
+				removeCreatedDirs(ctx, f.channel, getCreatedDirs(uid.(string)))
 				return spec.ReturnSuccess("File append destroy operation completed (deleted created file)")
 			}
 
-			// Restore the original file content and remove the backup file
-			response := f.channel.Run(ctx, "cp", fmt.Sprintf(`"%s" "%s"`, backupFile, filepath))
 			if !response.Success {
 				log.Errorf(ctx, "Failed to restore original file content: %s", response.Err)
 				return response
 			}
 
-			// Remove the backup file
-			_ = f.channel.Run(ctx, "rm", fmt.Sprintf(`"%s"`, backupFile))
-
 			log.Infof(ctx, "File append destroy operation completed for file: %s (original content restored)", filepath)
+			if check != nil {
+				check.Decision = "restored"
+				return spec.ReturnSuccess(*check)
+			}
 			return spec.ReturnSuccess("File append destroy operation completed successfully (original content restored)")
 		} else {
 			// If delete-file is true but enable-backup is false, delete the file
-			if exec.CheckFilepathExists(ctx, f.channel, filepath) {
-				response := f.channel.Run(ctx, "rm", fmt.Sprintf(`"%s"`, filepath))
+			if fileExists(ctx, f.channel, filepath) {
+				response := removeFile(ctx, f.channel, filepath)
 				if !response.Success {
 					log.Errorf(ctx, "Failed to delete file: %s, error: %s", filepath, response.Err)
 					return response
@@ -321,6 +471,8 @@ func (f *FileAppendActionExecutor) handleOneTimeOperation(filepath string, enabl
 			} else {
 				log.Infof(ctx, "File does not exist, nothing to delete: %s", filepath)
 			}
+			uid, _ := ctx.Value(spec.Uid).(string)
+			removeCreatedDirs(ctx, f.channel, getCreatedDirs(uid))
 			return spec.ReturnSuccess("File append destroy operation completed (file deleted)")
 		}
 	}
@@ -339,11 +491,8 @@ func (f *FileAppendActionExecutor) handleOneTimeOperation(filepath string, enabl
 		return spec.ReturnFail(spec.ParameterInvalid, "experiment UID is required for destroy operation")
 	}
 
-	// The backup file should be stored as .chaos-blade-backup-{uid}
-	backupFile := filepath + ".chaos-blade-backup-" + uid.(string)
-
-	// Check if backup file exists
-	if !exec.CheckFilepathExists(ctx, f.channel, backupFile) {
+	// Check if backup file exists, including the one created by the old versions without the record
+	if _, ok := exec.FindBackup(ctx, f.channel, uid.(string), filepath); !ok {
 		// If no backup file exists, it means the original file didn't exist
 		// Since delete-file is false, we keep the file that was created by the append operation
 		log.Infof(ctx, "No backup file exists, keeping created file: %s", filepath)
@@ -360,41 +509,156 @@ func (f *FileAppendActionExecutor) SetChannel(channel spec.Channel) {
 	f.channel = channel
 }
 
-func appendFile(cl spec.Channel, count int, ctx context.Context, content string, filepath string, escape bool, enableBase64 bool) *spec.Response {
+// dirOptions are the flags of creating the missing parent directories of the file
+type dirOptions struct {
+	create bool
+	// maxDepth is the max number of the missing directories created, it's unlimited if negative
+	maxDepth int
+	mode     string
+}
+
+// dirOptionsKey is the context key of the dirOptions, the default options are used without it
+type dirOptionsKey struct{}
+
+// createdDirsKey is the detail of the record of the directories created by the experiment, from the top
+const createdDirsKey = "created-dirs"
+
+func getDirOptions(flags map[string]string) (dirOptions, *spec.Response) {
+	options := dirOptions{create: flags["create-dirs"] != "false", maxDepth: -1, mode: flags["dir-mode"]}
 	var response *spec.Response
+	if value := flags["max-create-depth"]; value != "" {
+		if options.maxDepth, response = validation.ValidateInt("max-create-depth", value, 0, math.MaxInt); response != nil {
+			return options, response
+		}
+	}
+	if options.mode != "" {
+		if mode, err := strconv.ParseUint(options.mode, 8, 32); err != nil || mode > 0777 {
+			return options, spec.ResponseFailWithFlags(spec.ParameterIllegal, "dir-mode", options.mode,
+				"it must be the octal permission bits, such as 0755")
+		}
+	}
+	return options, nil
+}
 
-	// Check if the directory exists, if not create it
-	dir := path.Dir(filepath)
-	if !exec.CheckFilepathExists(ctx, cl, dir) {
-		response = cl.Run(ctx, "mkdir", fmt.Sprintf(`-p "%s"`, dir))
-		if !response.Success {
+// ensureDir creates the missing parent directories of the file from the top, they are recorded for the removal
+// on destroy. The creation fails with the first missing ancestor if it's disabled or exceeds the max depth, so a
+// typo of the path doesn't create a whole tree.
+func ensureDir(ctx context.Context, cl spec.Channel, filepath string) *spec.Response {
+	options, ok := ctx.Value(dirOptionsKey{}).(dirOptions)
+	if !ok {
+		options = dirOptions{create: true, maxDepth: -1}
+	}
+	missing := make([]string, 0)
+	for dir := path.Dir(filepath); !fileExists(ctx, cl, dir); dir = path.Dir(dir) {
+		missing = append([]string{dir}, missing...)
+		if path.Dir(dir) == dir {
+			break
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if !options.create {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "filepath", filepath,
+			fmt.Sprintf("the directory %s does not exist and create-dirs is false", missing[0]))
+	}
+	if options.maxDepth >= 0 && len(missing) > options.maxDepth {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "filepath", filepath,
+			fmt.Sprintf("the directory %s does not exist, %d directories are missing, more than the max-create-depth %d",
+				missing[0], len(missing), options.maxDepth))
+	}
+	created := make([]string, 0, len(missing))
+	for _, dir := range missing {
+		if response := makeDir(ctx, cl, dir, options.mode); !response.Success {
 			log.Errorf(ctx, "Failed to create directory: %s, error: %s", dir, response.Err)
-			return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("failed to create directory %s: %s", dir, response.Err))
+			removeCreatedDirs(ctx, cl, created)
+			return exec.Fail(exec.CommandFailed, "file", "mkdir", fmt.Sprintf("failed to create directory %s: %s", dir, response.Err))
 		}
+		created = append(created, dir)
 		log.Infof(ctx, "Created directory: %s", dir)
 	}
+	if uid, _ := ctx.Value(spec.Uid).(string); uid != "" && uid != spec.UnknownUid {
+		recorded := append(getCreatedDirs(uid), created...)
+		if response := exec.SetBackupDetail(ctx, uid, createdDirsKey, strings.Join(recorded, "\n")); response != nil {
+			removeCreatedDirs(ctx, cl, created)
+			return response
+		}
+	}
+	return nil
+}
+
+// getCreatedDirs returns the recorded directories created by the experiment
+func getCreatedDirs(uid string) []string {
+	state, err := exec.LoadState(uid)
+	if err != nil || state.Details[createdDirsKey] == "" {
+		return nil
+	}
+	return strings.Split(state.Details[createdDirsKey], "\n")
+}
+
+// removeCreatedDirs removes the created directories from the bottom, it stops at the first one which is not
+// empty, since its ancestors are not empty either
+func removeCreatedDirs(ctx context.Context, cl spec.Channel, dirs []string) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if !fileExists(ctx, cl, dirs[i]) {
+			continue
+		}
+		if response := removeDir(ctx, cl, dirs[i]); !response.Success {
+			log.Infof(ctx, "the directory %s created by the experiment is kept, %s", dirs[i], response.Err)
+			return
+		}
+		log.Infof(ctx, "Deleted directory created by append operation: %s", dirs[i])
+	}
+}
+
+// missedBatches returns the batches which should have been appended in the elapsed time but are not, the first
+// batch is appended at the start
+func missedBatches(elapsed, interval time.Duration, batches int) int {
+	if expected := int(elapsed/interval) + 1; expected > batches {
+		return expected - batches
+	}
+	return 0
+}
+
+// appendFile appends the count lines, the lines are flushed to the disk by one fsync if sync is set, and the
+// synced bytes are returned
+func appendFile(cl spec.Channel, count int, ctx context.Context, content string, filepath string, escape bool, enableBase64 bool, sync bool) (int, *spec.Response) {
+	var response *spec.Response
+
+	// Check if the directory exists, if not create it
+	if response := ensureDir(ctx, cl, filepath); response != nil {
+		return 0, response
+	}
 
 	if enableBase64 {
 		decodeBytes, err := base64.StdEncoding.DecodeString(content)
 		if err != nil {
-			return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("%s base64 decode err", content))
+			return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, "content", content, "base64 decode err")
 		}
 		content = string(decodeBytes)
 	}
 	content = parseDate(content)
+	lines := make([]string, 0, count)
 	for i := 0; i < count; i++ {
 		response = parseRandom(content)
 		if !response.Success {
-			return response
+			return 0, response
 		}
 		content = response.Result.(string)
-		if escape {
-			response = cl.Run(ctx, "echo", fmt.Sprintf(`-e '%s' >> %s`, content, filepath))
-		} else {
-			response = cl.Run(ctx, "echo", fmt.Sprintf(`'%s' >> %s`, content, filepath))
+		if sync {
+			lines = append(lines, content)
+			continue
 		}
+		response = appendLine(ctx, cl, filepath, content, escape)
 	}
-	return response
+	if !sync {
+		return 0, response
+	}
+	synced, response := syncAppendLines(ctx, cl, filepath, lines, escape)
+	if response != nil {
+		return 0, response
+	}
+	return synced, spec.ReturnSuccess(filepath)
 }
 
 func parseDate(content string) string {
@@ -475,3 +739,61 @@ func parseRandom(content string) *spec.Response {
 	}
 	return spec.ReturnSuccess(content)
 }
+
+// interpretEscapes interprets the backslash escapes like the %b of printf, such as \n, \t, \\, the octal
+// \0nnn and the hex \xHH, the unknown escapes are kept
+func interpretEscapes(content string) string {
+	var builder strings.Builder
+	for i := 0; i < len(content); i++ {
+		if content[i] != '\\' || i == len(content)-1 {
+			builder.WriteByte(content[i])
+			continue
+		}
+		i++
+		switch c := content[i]; c {
+		case '\\':
+			builder.WriteByte('\\')
+		case 'a':
+			builder.WriteByte('\a')
+		case 'b':
+			builder.WriteByte('\b')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 't':
+			builder.WriteByte('\t')
+		case 'v':
+			builder.WriteByte('\v')
+		case '0', 'x':
+			base, width := 8, 3
+			if c == 'x' {
+				base, width = 16, 2
+			}
+			end := i + 1
+			for end < len(content) && end-i <= width && isDigit(content[end], base) {
+				end++
+			}
+			if c == 'x' && end == i+1 {
+				builder.WriteString(`\x`)
+				continue
+			}
+			value, _ := strconv.ParseUint("0"+content[i+1:end], base, 8)
+			builder.WriteByte(byte(value))
+			i = end - 1
+		default:
+			builder.WriteByte('\\')
+			builder.WriteByte(c)
+		}
+	}
+	return builder.String()
+}
+
+func isDigit(c byte, base int) bool {
+	if base == 8 {
+		return c >= '0' && c <= '7'
+	}
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
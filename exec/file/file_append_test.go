@@ -0,0 +1,327 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func execAppend(ctx context.Context, cl spec.Channel, uid string, flags map[string]string) *spec.Response {
+	executor := &FileAppendActionExecutor{}
+	executor.SetChannel(cl)
+	return executor.Exec(uid, ctx, &spec.ExpModel{Target: "file", ActionName: "append", ActionFlags: flags})
+}
+
+func assertCommands(t *testing.T, cl *exec.MockChannel, expected []string) {
+	t.Helper()
+	if actual := cl.CommandLines(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected commands\nactual:   %q\nexpected: %q", actual, expected)
+	}
+}
+
+func TestFileAppendActionExecutor(t *testing.T) {
+	cl := exec.NewMockChannel()
+	flags := map[string]string{"filepath": "/var/log/app.log", "content": "hello world", "count": "2"}
+
+	// the one-time append returns without the response
+	if response := execAppend(context.Background(), cl, "append-1", flags); response != nil && !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"readlink -f -- /var/log/app.log",
+		"cat -- /proc/mounts",
+		"test -e /var/log/app.log",
+		"test -e /var/log/app.log",
+		"test -e /var/log",
+		"printf %s 'hello world\n' >> /var/log/app.log",
+		"printf %s 'hello world\n' >> /var/log/app.log",
+	})
+}
+
+func TestFileAppendActionExecutorBackup(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.WithValue(context.Background(), spec.Uid, "append-2")
+	checksum := spec.ReturnSuccess("9f86d081  /data/app.log.chaos-blade-backup-append-2\n")
+	cl := exec.NewMockChannel().
+		OnRun("test", "^-e /data$", spec.ReturnFail(spec.OsCmdExecFailed, "not found")).
+		OnRun("sha256sum", "", checksum)
+	flags := map[string]string{"filepath": "/data/app.log", "content": "a\\tb", "escape": "true", "enable-backup": "true"}
+
+	if response := execAppend(ctx, cl, "append-2", flags); response != nil && !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"readlink -f -- /data/app.log",
+		"cat -- /proc/mounts",
+		"test -e /data/app.log",
+		"test -e /data/app.log",
+		"cp -p -- /data/app.log /data/app.log.chaos-blade-backup-append-2",
+		"sha256sum -- /data/app.log.chaos-blade-backup-append-2",
+		"stat -L -c '%d:%i %s %Y' -- /data/app.log",
+		"test -e /data/app.log",
+		"wc -c -- /data/app.log",
+		"test -e /data",
+		"test -e /",
+		"mkdir -- /data",
+		"printf %s 'a\tb\n' >> /data/app.log",
+	})
+
+	// the original content is restored from the backup after verifying the checksum
+	cl = exec.NewMockChannel().OnRun("sha256sum", "", checksum)
+	flags["delete-file"] = "true"
+	if response := execAppend(spec.SetDestroyFlag(ctx, "append-2"), cl, "append-2", flags); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"test -e /data/app.log.chaos-blade-backup-append-2",
+		"sha256sum -- /data/app.log.chaos-blade-backup-append-2",
+		"cp -p -- /data/app.log.chaos-blade-backup-append-2 /data/app.log",
+		"rm -f -- /data/app.log.chaos-blade-backup-append-2",
+	})
+
+	// the restored file is neither restored nor deleted again
+	cl.Reset()
+	if response := execAppend(spec.SetDestroyFlag(ctx, "append-2"), cl, "append-2", flags); !response.Success {
+		t.Fatalf("unexpected failure of the second destroy, %s", response.Err)
+	}
+	if commands := cl.CommandLines(); len(commands) != 0 {
+		t.Errorf("unexpected commands of the second destroy, %q", commands)
+	}
+}
+
+func TestFileAppendActionExecutorRotated(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	checksum := spec.ReturnSuccess("9f86d081  backup\n")
+	for _, policy := range []string{"", RotatedAside} {
+		uid := "append-rotated-" + policy
+		ctx := context.WithValue(context.Background(), spec.Uid, uid)
+		cl := exec.NewMockChannel().
+			OnRun("sha256sum", "", checksum).
+			OnRun("stat", "%Y", spec.ReturnSuccess("2049:42 100 1700000000\n"))
+		flags := map[string]string{"filepath": "/data/app.log", "content": "hello", "enable-backup": "true",
+			"delete-file": "true", "rotated-restore": policy}
+		if response := execAppend(ctx, cl, uid, flags); response != nil && !response.Success {
+			t.Fatalf("unexpected failure, %s", response.Err)
+		}
+
+		// logrotate renamed the file and created a new one
+		cl = exec.NewMockChannel().
+			OnRun("sha256sum", "", checksum).
+			OnRun("stat", "%Y", spec.ReturnSuccess("2049:57 0 1700000100\n"))
+		response := execAppend(spec.SetDestroyFlag(ctx, uid), cl, uid, flags)
+		if !response.Success {
+			t.Fatalf("unexpected failure of destroy, %s", response.Err)
+		}
+		result, ok := response.Result.(appendDestroyResult)
+		if !ok || !strings.Contains(result.Reason, "identity is changed from 2049:42 to 2049:57") ||
+			result.Recorded.Size != 100 || result.Current.Size != 0 {
+			t.Fatalf("expected the rotation in the result, %+v", response.Result)
+		}
+		backup := exec.GetBackupFile("/data/app.log", uid)
+		if containsLine(cl.CommandLines(), "cp -p -- "+backup+" /data/app.log") {
+			t.Errorf("expected the backup isn't copied over the rotated file, %q", cl.CommandLines())
+		}
+		aside := containsLine(cl.CommandLines(), "cp -p -- "+backup+" /data/app.log.chaosblade-restored")
+		if policy == RotatedAside && (result.Decision != "restored-aside" || !aside) {
+			t.Errorf("expected the backup restored aside, %+v, %q", result, cl.CommandLines())
+		}
+		if policy == "" && (result.Decision != "skipped" || aside || result.Backup != backup) {
+			t.Errorf("expected the backup left alone, %+v, %q", result, cl.CommandLines())
+		}
+	}
+}
+
+func TestRotatedReason(t *testing.T) {
+	recorded := fileStat{Identity: "2049:42", Size: 100, ModTime: 1700000000}
+	tests := []struct {
+		current *fileStat
+		rotated bool
+	}{
+		{&fileStat{Identity: "2049:42", Size: 160, ModTime: 1700000100}, false},
+		{nil, true},
+		{&fileStat{Identity: "2049:57", Size: 160, ModTime: 1700000100}, true},
+		{&fileStat{Identity: "2049:42", Size: 0, ModTime: 1700000100}, true},
+		{&fileStat{Identity: "2049:42", Size: 100, ModTime: 1600000000}, true},
+	}
+	for _, tt := range tests {
+		if reason := rotatedReason(recorded, tt.current); (reason != "") != tt.rotated {
+			t.Errorf("unexpected reason for %+v, %q", tt.current, reason)
+		}
+	}
+}
+
+func TestFileAppendActionExecutorLegacyBackup(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.WithValue(context.Background(), spec.Uid, "append-5")
+	cl := exec.NewMockChannel()
+	flags := map[string]string{"filepath": "/data/app.log", "content": "hello", "enable-backup": "true", "delete-file": "true"}
+
+	// the backup created by the old versions has no record, it's restored without the checksum
+	if response := execAppend(spec.SetDestroyFlag(ctx, "append-5"), cl, "append-5", flags); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"test -e /data/app.log.chaos-blade-backup-append-5",
+		"test -e /data/app.log.chaos-blade-backup-append-5",
+		"cp -p -- /data/app.log.chaos-blade-backup-append-5 /data/app.log",
+		"rm -f -- /data/app.log.chaos-blade-backup-append-5",
+	})
+}
+
+func TestFileAppendActionExecutorDestroyWithoutBackup(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.WithValue(context.Background(), spec.Uid, "append-3")
+	cl := exec.NewMockChannel()
+	flags := map[string]string{"filepath": "/data/app.log", "content": "hello", "delete-file": "true"}
+
+	if response := execAppend(spec.SetDestroyFlag(ctx, "append-3"), cl, "append-3", flags); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"test -e /data/app.log",
+		"rm -- /data/app.log",
+	})
+}
+
+func TestFileAppendActionExecutorFileNotExist(t *testing.T) {
+	cl := exec.NewMockChannel().OnRun("test", "", spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+	response := execAppend(context.Background(), cl, "append-4", map[string]string{"filepath": "/data/app.log", "content": "hello"})
+	if response == nil || response.Success || response.Code != spec.ParameterInvalid.Code {
+		t.Errorf("expected the invalid filepath, %+v", response)
+	}
+	assertCommands(t, cl, []string{"readlink -f -- /data/app.log", "cat -- /proc/mounts", "test -e /data/app.log"})
+}
+
+func TestFileAppendActionExecutorProtectedPath(t *testing.T) {
+	// the symlink is resolved to the protected path
+	cl := exec.NewMockChannel().OnRun("readlink", "", spec.ReturnSuccess("/etc/passwd\n"))
+	flags := map[string]string{"filepath": "/data/passwd", "content": "hello"}
+	response := execAppend(context.Background(), cl, "append-6", flags)
+	if failure, ok := exec.GetFailure(response); !ok || failure.Kind != exec.ProtectedPathRefused.Name {
+		t.Fatalf("expected the protected path refused, %+v", response)
+	}
+	assertCommands(t, cl, []string{"readlink -f -- /data/passwd"})
+
+	// the force flag skips the check
+	cl.Reset()
+	flags["force"] = "true"
+	if response := execAppend(context.Background(), cl, "append-6", flags); response != nil && !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if commands := cl.CommandLines(); len(commands) < 2 || commands[1] != "test -e /data/passwd" {
+		t.Errorf("unexpected commands, %q", commands)
+	}
+}
+
+func TestFileAppendActionExecutorSync(t *testing.T) {
+	cl := exec.NewMockChannel()
+	flags := map[string]string{"filepath": "/var/log/app.log", "content": "hello world", "count": "2", "sync": "true"}
+
+	// the lines are appended by one write and flushed to the disk
+	response := execAppend(context.Background(), cl, "append-sync", flags)
+	if response == nil || !response.Success {
+		t.Fatalf("unexpected response, %+v", response)
+	}
+	if result, ok := response.Result.(fileAppendResult); !ok || result.SyncedBytes != 24 {
+		t.Errorf("expected the synced bytes 24, got %+v", response.Result)
+	}
+	assertCommands(t, cl, []string{
+		"readlink -f -- /var/log/app.log",
+		"cat -- /proc/mounts",
+		"test -e /var/log/app.log",
+		"test -e /var/log/app.log",
+		"test -e /var/log",
+		"printf %s 'hello world\nhello world\n' >> /var/log/app.log && sync -- /var/log/app.log",
+	})
+}
+
+func TestMissedBatches(t *testing.T) {
+	tests := []struct {
+		elapsed  time.Duration
+		batches  int
+		expected int
+	}{
+		{elapsed: 0, batches: 1, expected: 0},
+		{elapsed: 3500 * time.Millisecond, batches: 4, expected: 0},
+		{elapsed: 3500 * time.Millisecond, batches: 2, expected: 2},
+		{elapsed: 10 * time.Second, batches: 12, expected: 0},
+	}
+	for _, tt := range tests {
+		if actual := missedBatches(tt.elapsed, time.Second, tt.batches); actual != tt.expected {
+			t.Errorf("missedBatches(%v, 1s, %d) = %d, want %d", tt.elapsed, tt.batches, actual, tt.expected)
+		}
+	}
+}
+
+func TestFileAppendActionExecutorCreateDirs(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.WithValue(context.Background(), spec.Uid, "append-dirs")
+	// the file is checked by the exact path, the directories /data/logs and /data/logs/app are missing
+	cl := exec.NewMockChannel().
+		OnRun("test", "^-e /data/logs", spec.ReturnFail(spec.OsCmdExecFailed, "not found")).
+		OnRun("test", "^-e /data/logs/app/app.log$", spec.ReturnSuccess(""))
+	flags := map[string]string{"filepath": "/data/logs/app/app.log", "content": "hello", "max-create-depth": "1"}
+	response := execAppend(ctx, cl, "append-dirs", flags)
+	if response == nil || response.Success || response.Code != spec.ParameterInvalid.Code ||
+		!strings.Contains(response.Err, "/data/logs does not exist") {
+		t.Fatalf("expected the first missing directory is refused, %+v", response)
+	}
+
+	flags["create-dirs"] = "false"
+	delete(flags, "max-create-depth")
+	if response := execAppend(ctx, cl, "append-dirs", flags); response == nil || response.Success {
+		t.Fatalf("expected the creation is disabled, %+v", response)
+	}
+
+	// the directories are created from the top with the mode, and removed from the bottom on destroy
+	cl.Reset()
+	flags = map[string]string{"filepath": "/data/logs/app/app.log", "content": "hello", "max-create-depth": "2", "dir-mode": "0750"}
+	if response := execAppend(ctx, cl, "append-dirs", flags); response != nil && !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	lines := cl.CommandLines()
+	if !strings.Contains(strings.Join(lines, "\n"), "mkdir -m 0750 -- /data/logs\nmkdir -m 0750 -- /data/logs/app\n") {
+		t.Errorf("expected the directories are created with the mode, got %q", lines)
+	}
+	cl = exec.NewMockChannel().OnRun("rmdir", "^-- /data/logs$", spec.ReturnFail(spec.OsCmdExecFailed, "Directory not empty"))
+	flags["delete-file"] = "true"
+	if response := execAppend(spec.SetDestroyFlag(ctx, "append-dirs"), cl, "append-dirs", flags); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"test -e /data/logs/app/app.log",
+		"rm -- /data/logs/app/app.log",
+		"test -e /data/logs/app",
+		"rmdir -- /data/logs/app",
+		"test -e /data/logs",
+		"rmdir -- /data/logs",
+	})
+
+	if _, response := getDirOptions(map[string]string{"dir-mode": "0999"}); response == nil {
+		t.Errorf("expected the illegal mode is refused")
+	}
+}
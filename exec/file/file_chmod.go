@@ -45,6 +45,7 @@ func NewFileChmodActionSpec() spec.ExpActionCommandSpec {
 					Desc:     "--mark 777",
 					Required: true,
 				},
+				forceFlag,
 			},
 			ActionExecutor: &FileChmodActionExecutor{},
 			ActionExample: `
@@ -84,7 +85,7 @@ func (*FileChmodActionExecutor) Name() string {
 const tmpFileChmod = "/tmp/chaos-file-chmod.tmp"
 
 func (f *FileChmodActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
-	commands := []string{"chmod", "grep", "echo", "rm", "awk", "cat", "stat"}
+	commands := []string{"chmod", "rm", "cat", "stat"}
 	if response, ok := f.channel.IsAllCommandsAvailable(ctx, commands); !ok {
 		return response
 	}
@@ -97,7 +98,13 @@ func (f *FileChmodActionExecutor) Exec(uid string, ctx context.Context, model *s
 
 	filepath := model.ActionFlags["filepath"]
 	if _, ok := spec.IsDestroy(ctx); ok {
-		return f.stopChmodFile(ctx, filepath, mark)
+		return f.stopChmodFile(ctx, uid, filepath)
+	}
+
+	if model.ActionFlags[exec.ForceFlagName] != "true" {
+		if response := exec.CheckProtectedPath(ctx, f.channel, "filepath", filepath, false); response != nil {
+			return response
+		}
 	}
 
 	if !exec.CheckFilepathExists(ctx, f.channel, filepath) {
@@ -105,53 +112,118 @@ func (f *FileChmodActionExecutor) Exec(uid string, ctx context.Context, model *s
 		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "filepath", filepath, "the file does not exist")
 	}
 
-	response := f.channel.Run(ctx, "grep", fmt.Sprintf(`-q "%s:" "%s"`, filepath, tmpFileChmod))
-	if response.Success {
+	// the record is keyed by the resolved path and the identity, so the symlinks and the hard links of the
+	// file experimented are refused too
+	resolved := exec.ResolvePath(ctx, f.channel, filepath)
+	identity, err := exec.FileIdentity(ctx, f.channel, resolved)
+	if err != nil {
+		log.Warnf(ctx, "get the identity of %s failed, the replaced file isn't detected on destroy, %v", resolved, err)
+	}
+	if owner := exec.BackupOwner(resolved, identity); owner != "" {
+		log.Errorf(ctx, "%s is already being experimented by %s", filepath, owner)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "filepath", filepath,
+			fmt.Sprintf("already being experimented by %s", owner))
+	}
+	if _, ok := findOriginMark(f.readChmodRecords(ctx), filepath); ok {
 		log.Errorf(ctx, "%s is already being experimented", filepath)
 		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "filepath", filepath, "already being experimented")
 	}
-	response = f.channel.Run(ctx, "stat", fmt.Sprintf(`-c "%%a" %s`, filepath))
+	response := getFileMode(ctx, f.channel, resolved)
 	if !response.Success {
 		log.Errorf(ctx, "`%s`: can't get file's origin mark", filepath)
 		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "filepath", filepath, "can't get file's mark")
 	}
-	originMark := response.Result.(string)
+	originMark := strings.TrimSpace(response.Result.(string))
 
-	response = f.channel.Run(ctx, "echo", fmt.Sprintf(`'%s:%s' >> %s`, filepath, originMark, tmpFileChmod))
-	if !response.Success {
+	if _, response := exec.NewExperimentState(ctx, uid, "file", "chmod", map[string]string{
+		"filepath": resolved, "identity": identity, "mark": mark,
+	}); response != nil {
+		return response
+	}
+	if _, response := exec.BackupMode(ctx, uid, resolved, originMark, identity); response != nil {
+		exec.ReleaseResources(ctx, uid)
 		return response
 	}
-	return f.channel.Run(ctx, "chmod", fmt.Sprintf(`%s "%s"`, mark, filepath))
+	response = exec.RunArgv(ctx, f.channel, "chmod", mark, "--", resolved)
+	if !response.Success {
+		exec.RemoveBackup(ctx, f.channel, uid, resolved)
+		exec.ReleaseResources(ctx, uid)
+	}
+	return response
 }
 
-func (f *FileChmodActionExecutor) stopChmodFile(ctx context.Context, filepath, mark string) *spec.Response {
-	// get origin mark
-	response := f.channel.Run(ctx, "grep", fmt.Sprintf(`%s: %s | awk -F ':' '{printf $2}'`, filepath, tmpFileChmod))
-	if !response.Success {
-		f.clearTempFile(filepath, response, ctx)
+// stopChmodFile restores the origin mark by the record, which is skipped with a note if the file is replaced
+// since. The experiments created by the old versions are restored by the backup of the path, or the temp file.
+func (f *FileChmodActionExecutor) stopChmodFile(ctx context.Context, uid, filepath string) *spec.Response {
+	if response, ok := exec.DestroyByState(ctx, f.channel, uid); ok {
+		if !response.Success {
+			return response
+		}
+		if state, err := exec.LoadState(uid); err == nil {
+			for _, entry := range state.Backups {
+				if entry.Note != "" {
+					return spec.ReturnSuccess(entry.Note)
+				}
+			}
+		}
+		return response
+	}
+	if response, ok := exec.Restore(ctx, f.channel, uid, filepath); ok {
 		return response
 	}
-	originMark := response.Result.(string)
-	response = f.channel.Run(ctx, "chmod", fmt.Sprintf(`%s %s`, originMark, filepath))
-	f.clearTempFile(filepath, response, ctx)
+	records := f.readChmodRecords(ctx)
+	originMark, ok := findOriginMark(records, filepath)
+	if !ok {
+		log.Errorf(ctx, "`%s`: the origin mark is not found in %s", filepath, tmpFileChmod)
+		return exec.FailWithFlags(exec.RestoreFailed, "file", "get the origin mark of "+filepath, "the record is not found")
+	}
+	response := exec.RunArgv(ctx, f.channel, "chmod", originMark, "--", filepath)
+	f.clearTempFile(ctx, records, filepath)
 	return response
 }
 
-func (f *FileChmodActionExecutor) clearTempFile(filepath string, response *spec.Response, ctx context.Context) {
-	response = f.channel.Run(ctx, "cat", fmt.Sprintf(`"%s"| grep -v %s:`, tmpFileChmod, filepath))
+// readChmodRecords returns the records of the experiments created by the old versions, each record is filepath:mark
+func (f *FileChmodActionExecutor) readChmodRecords(ctx context.Context) []string {
+	response := exec.RunReadOnlyArgv(ctx, f.channel, "cat", "--", tmpFileChmod)
 	if !response.Success {
-		response = f.channel.Run(ctx, "rm", fmt.Sprintf(`-rf "%s"`, tmpFileChmod))
-		if !response.Success {
-			log.Errorf(ctx, "clean temp file error %s", response.Err)
+		return nil
+	}
+	records := make([]string, 0)
+	for _, line := range strings.Split(response.Result.(string), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			records = append(records, line)
 		}
-	} else {
-		response = f.channel.Run(ctx, "echo", fmt.Sprintf(`"%s" > %s`,
-			strings.TrimRight(response.Result.(string), "\n"),
-			tmpFileChmod))
-		if !response.Success {
-			log.Errorf(ctx, "clean temp file error %s", response.Err)
+	}
+	return records
+}
+
+// findOriginMark returns the mark of the file, the filepath may contain the colon, so the last one is the separator
+func findOriginMark(records []string, filepath string) (string, bool) {
+	for _, record := range records {
+		if i := strings.LastIndex(record, ":"); i >= 0 && record[:i] == filepath {
+			return record[i+1:], true
 		}
 	}
+	return "", false
+}
+
+// clearTempFile removes the record of the file, and the record file if there are no other records
+func (f *FileChmodActionExecutor) clearTempFile(ctx context.Context, records []string, filepath string) {
+	remains := make([]string, 0, len(records))
+	for _, record := range records {
+		if i := strings.LastIndex(record, ":"); i < 0 || record[:i] != filepath {
+			remains = append(remains, record+"\n")
+		}
+	}
+	var response *spec.Response
+	if len(remains) == 0 {
+		response = exec.RunArgv(ctx, f.channel, "rm", "-f", "--", tmpFileChmod)
+	} else {
+		response = exec.WriteFile(ctx, f.channel, tmpFileChmod, strings.Join(remains, ""))
+	}
+	if !response.Success {
+		log.Errorf(ctx, "clean temp file error %s", response.Err)
+	}
 }
 
 func (f *FileChmodActionExecutor) SetChannel(channel spec.Channel) {
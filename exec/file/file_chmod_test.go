@@ -0,0 +1,99 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func execChmod(ctx context.Context, cl spec.Channel, uid, filepath string) *spec.Response {
+	executor := &FileChmodActionExecutor{}
+	executor.SetChannel(cl)
+	return executor.Exec(uid, ctx, &spec.ExpModel{
+		Target: "file", ActionName: "chmod", ActionFlags: map[string]string{"filepath": filepath, "mark": "777"},
+	})
+}
+
+// chmodChannel returns the channel on which /data/app.log links to /data/releases/app.log with the inode 42,
+// and the legacy record file doesn't exist
+func chmodChannel(identity string) *exec.MockChannel {
+	return exec.NewMockChannel().
+		OnRun("readlink", "", spec.ReturnSuccess("/data/releases/app.log\n")).
+		OnRun("stat", "%d:%i", spec.ReturnSuccess(identity+"\n")).
+		OnRun("stat", "%a", spec.ReturnSuccess("644\n")).
+		OnRun("cat", "", spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+}
+
+func TestFileChmodActionExecutor(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := chmodChannel("2049:42")
+	if response := execChmod(ctx, cl, "chmod-1", "/data/app.log"); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if lines := cl.CommandLines(); lines[len(lines)-1] != "chmod 777 -- /data/releases/app.log" {
+		t.Errorf("expected the resolved file is changed, got %q", lines)
+	}
+
+	// the same file by the other path is refused with the uid of the experiment
+	response := execChmod(ctx, chmodChannel("2049:42"), "chmod-2", "/data/releases/app.log")
+	if response.Success || !strings.Contains(response.Err, "chmod-1") {
+		t.Errorf("expected the conflict with chmod-1, %+v", response)
+	}
+
+	cl = chmodChannel("2049:42")
+	if response := execChmod(spec.SetDestroyFlag(ctx, "chmod-1"), cl, "chmod-1", "/data/app.log"); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	if lines := cl.CommandLines(); lines[len(lines)-1] != "chmod 644 -- /data/releases/app.log" {
+		t.Errorf("expected the origin mode is restored, got %q", lines)
+	}
+	if owner := exec.BackupOwner("/data/releases/app.log", ""); owner != "" {
+		t.Errorf("expected no owner after the destroy, got %s", owner)
+	}
+}
+
+func TestFileChmodActionExecutorReplaced(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	if response := execChmod(ctx, chmodChannel("2049:42"), "chmod-1", "/data/app.log"); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+
+	// the file is replaced by a deploy, the stale mode isn't applied to the new one
+	cl := chmodChannel("2049:57")
+	response := execChmod(spec.SetDestroyFlag(ctx, "chmod-1"), cl, "chmod-1", "/data/app.log")
+	if !response.Success || !strings.Contains(response.Result.(string), "is replaced") {
+		t.Fatalf("expected the restore is skipped with the note, %+v", response)
+	}
+	for _, line := range cl.CommandLines() {
+		if strings.HasPrefix(line, "chmod") {
+			t.Errorf("expected no chmod, got %q", line)
+		}
+	}
+	if state, err := exec.LoadState("chmod-1"); err != nil || !state.Destroyed {
+		t.Errorf("expected the record is destroyed, %v", err)
+	}
+}
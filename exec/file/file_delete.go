@@ -28,6 +28,7 @@ import (
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
 )
 
 const DeleteFileBin = "chaos_deletefile"
@@ -43,9 +44,23 @@ func NewFileDeleteActionSpec() spec.ExpActionCommandSpec {
 			ActionFlags: []spec.ExpFlagSpec{
 				&spec.ExpFlag{
 					Name:   "force",
-					Desc:   "use --force flag can't be restored",
+					Desc:   "use --force flag can't be restored, it also allows deleting the protected path",
 					NoArgs: true,
 				},
+				&spec.ExpFlag{
+					Name:   "recursive",
+					Desc:   "delete the directory tree, it's archived by tar with the owners, the modes, the symlinks and the xattrs where possible, and extracted to the original path on destroy. Without the flag, the directory is moved aside",
+					NoArgs: true,
+				},
+				&spec.ExpFlag{
+					Name: "allowed-root",
+					Desc: "the directory which the filepath must be under, the symlinks are resolved",
+				},
+				&spec.ExpFlag{
+					Name: "max-backup-size",
+					Desc: "the max size of the directory tree estimated by du for the backup of --recursive, unit is MB. The value is a positive integer without unit, or with the unit K, M, G or T",
+				},
+				allowRemoteFsFlag,
 			},
 			ActionExecutor: &FileRemoveActionExecutor{},
 			ActionExample: `
@@ -54,6 +69,9 @@ blade create file delete --filepath /home/logs/nginx.log
 
 # Force delete the file /home/logs/nginx.log unrecoverable
 blade create file delete --filepath /home/logs/nginx.log --force
+
+# Delete the directory /home/app/cache under /home/app, it's restored from the backup of 1G at most on destroy
+blade create file delete --filepath /home/app/cache --recursive --allowed-root /home/app --max-backup-size 1G
 `,
 			ActionPrograms:   []string{DeleteFileBin},
 			ActionCategories: []string{category.SystemFile},
@@ -87,24 +105,88 @@ func (*FileRemoveActionExecutor) Name() string {
 
 func (f *FileRemoveActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
 	commands := []string{"rm", "mv"}
-	if response, ok := f.channel.IsAllCommandsAvailable(ctx, commands); !ok {
+	if response, ok := checkFileCommands(ctx, f.channel, commands); !ok {
 		return response
 	}
 
-	filepath := model.ActionFlags["filepath"]
+	filepath := normalizePath(model.ActionFlags["filepath"])
 
 	force := model.ActionFlags["force"] == "true"
 
 	if _, ok := spec.IsDestroy(ctx); ok {
-		return f.stop(filepath, force, ctx)
+		return f.stop(uid, filepath, force, ctx)
 	}
 
-	if !exec.CheckFilepathExists(ctx, f.channel, filepath) {
+	if model.ActionFlags[exec.ForceFlagName] != "true" {
+		if response := exec.CheckProtectedPath(ctx, f.channel, "filepath", filepath, true); response != nil {
+			return response
+		}
+	}
+	if response := exec.CheckAllowedRoot(ctx, f.channel, "filepath", filepath, normalizePath(model.ActionFlags["allowed-root"])); response != nil {
+		return response
+	}
+
+	ctx, response := exec.CheckRemoteFilesystem(ctx, f.channel, "filepath", filepath, true,
+		model.ActionFlags[exec.AllowRemoteFsFlagName] == "true")
+	if response != nil {
+		return response
+	}
+
+	if !fileExists(ctx, f.channel, filepath) {
 		log.Errorf(ctx, "`%s`: file does not exist", filepath)
 		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "filepath", filepath, "the file does not exist")
 	}
 
-	return f.start(filepath, force, ctx)
+	if model.ActionFlags["recursive"] == "true" && !force && isDir(ctx, f.channel, filepath) {
+		if value := model.ActionFlags["max-backup-size"]; value != "" {
+			maxSize, response := validation.ValidateSizeBytes("max-backup-size", value, validation.MB, 1)
+			if response != nil {
+				return response
+			}
+			size, err := treeSize(ctx, f.channel, filepath)
+			if err != nil {
+				return exec.FailWithFlags(exec.CommandFailed, "file", "du "+filepath, err)
+			}
+			if size > maxSize {
+				return spec.ResponseFailWithFlags(spec.ParameterIllegal, "max-backup-size", value,
+					fmt.Sprintf("the directory %s is about %d bytes, larger than the max backup size", filepath, size))
+			}
+		}
+		return withRemoteFsWarning(ctx, f.startTree(uid, filepath, ctx))
+	}
+	return withRemoteFsWarning(ctx, f.start(filepath, force, ctx))
+}
+
+// startTree archives the directory tree next to it, records the extraction and removes the tree. The tree is
+// extracted from the backup if it's not removed completely.
+func (f *FileRemoveActionExecutor) startTree(uid, dir string, ctx context.Context) *spec.Response {
+	state, response := exec.NewExperimentState(ctx, uid, "file", "delete", map[string]string{"filepath": dir, "recursive": "true"})
+	if response != nil {
+		return response
+	}
+	backup := exec.GetBackupFile(dir, uid) + ".tar"
+	extract, response := archiveTree(ctx, f.channel, dir, backup)
+	if response != nil {
+		removeFile(ctx, f.channel, backup)
+		exec.ReleaseResources(ctx, uid)
+		return response
+	}
+	// the undo commands run in the reverse order, the backup is removed after the extraction
+	for _, undo := range [][]string{{"rm", "-f", "--", backup}, append([]string{"tar"}, extract...)} {
+		if err := state.AddUndo(undo[0], exec.ShellJoin(undo[1:]...)); err != nil {
+			log.Errorf(ctx, "record the backup of %s failed, %v", dir, err)
+			removeFile(ctx, f.channel, backup)
+			exec.ReleaseResources(ctx, uid)
+			return exec.Fail(exec.StateRecordFailed, "file", "AddUndo", fmt.Sprintf("record the backup of %s failed, %v", dir, err))
+		}
+	}
+	log.Infof(ctx, "the directory %s is archived to %s", dir, backup)
+	if response := removeAll(ctx, f.channel, dir); !response.Success {
+		log.Errorf(ctx, "remove the directory %s failed, it's restored, %s", dir, response.Err)
+		exec.DestroyByState(ctx, f.channel, uid)
+		return response
+	}
+	return spec.ReturnSuccess(backup)
 }
 
 func md5Hex(s string) string {
@@ -115,20 +197,24 @@ func md5Hex(s string) string {
 
 func (f *FileRemoveActionExecutor) start(filepath string, force bool, ctx context.Context) *spec.Response {
 	if force {
-		return f.channel.Run(ctx, "rm", fmt.Sprintf(`-rf "%s"`, filepath))
+		return removeAll(ctx, f.channel, filepath)
 	} else {
 		target := path.Join(path.Dir(filepath), "."+md5Hex(path.Base(filepath)))
-		return f.channel.Run(ctx, "mv", fmt.Sprintf(`"%s" "%s"`, filepath, target))
+		return moveFile(ctx, f.channel, filepath, target)
 	}
 }
 
-func (f *FileRemoveActionExecutor) stop(filepath string, force bool, ctx context.Context) *spec.Response {
+func (f *FileRemoveActionExecutor) stop(uid, filepath string, force bool, ctx context.Context) *spec.Response {
+	// the directory tree deleted by --recursive is recorded
+	if response, ok := exec.DestroyByState(ctx, f.channel, uid); ok {
+		return response
+	}
 	if force {
 		// nothing to do
 		return nil
 	} else {
 		target := path.Join(path.Dir(filepath), "."+md5Hex(path.Base(filepath)))
-		return f.channel.Run(ctx, "mv", fmt.Sprintf(`"%s" "%s"`, target, filepath))
+		return moveFile(ctx, f.channel, target, filepath)
 	}
 }
 
@@ -0,0 +1,130 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func execDelete(ctx context.Context, cl spec.Channel, uid string, flags map[string]string) *spec.Response {
+	executor := &FileRemoveActionExecutor{}
+	executor.SetChannel(cl)
+	return executor.Exec(uid, ctx, &spec.ExpModel{Target: "file", ActionName: "delete", ActionFlags: flags})
+}
+
+func TestFileRemoveActionExecutorRecursive(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	root := t.TempDir()
+	dir := filepath.Join(root, "cache")
+	if err := os.MkdirAll(filepath.Join(dir, "plugins"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plugins", "a.so"), []byte("plugin"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("plugins/a.so", filepath.Join(dir, "current")); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	cl := channel.NewLocalChannel()
+	flags := map[string]string{"filepath": dir, "recursive": "true", "allowed-root": root, "max-backup-size": "1M"}
+	if response := execDelete(ctx, cl, "delete-tree", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if _, err := os.Lstat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected the directory is removed, %v", err)
+	}
+
+	if response := execDelete(spec.SetDestroyFlag(ctx, "delete-tree"), cl, "delete-tree", flags); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "plugins")); err != nil || info.Mode().Perm() != 0750 {
+		t.Errorf("expected the directory is restored with the mode, %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "current")); err != nil || target != "plugins/a.so" {
+		t.Errorf("expected the symlink is restored, %s, %v", target, err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "current")); err != nil || string(content) != "plugin" {
+		t.Errorf("expected the file is restored, %q, %v", content, err)
+	}
+	if _, err := os.Stat(exec.GetBackupFile(dir, "delete-tree") + ".tar"); !os.IsNotExist(err) {
+		t.Errorf("expected the backup is removed, %v", err)
+	}
+}
+
+func TestFileRemoveActionExecutorRecursiveRefused(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().OnRun("du", "", spec.ReturnSuccess("2097152\t/data/app/cache\n"))
+
+	flags := map[string]string{"filepath": "/data/app/cache", "recursive": "true", "allowed-root": "/data/other"}
+	if response := execDelete(ctx, cl, "delete-1", flags); response.Success || response.Code != spec.ParameterIllegal.Code {
+		t.Errorf("expected the path out of the allowed root is refused, %+v", response)
+	}
+
+	// 2G is estimated by du
+	cl.Reset()
+	flags = map[string]string{"filepath": "/data/app/cache", "recursive": "true", "max-backup-size": "1G"}
+	if response := execDelete(ctx, cl, "delete-2", flags); response.Success || response.Code != spec.ParameterIllegal.Code {
+		t.Errorf("expected the backup larger than the max size is refused, %+v", response)
+	}
+	assertCommands(t, cl, []string{
+		"readlink -f -- /data/app/cache",
+		"cat -- /proc/mounts",
+		"test -e /data/app/cache",
+		"test -d /data/app/cache",
+		"du -sk -- /data/app/cache",
+	})
+}
+
+func TestFileRemoveActionExecutorRemoteFilesystem(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().
+		OnRun("cat", "/proc/mounts", spec.ReturnSuccess("/dev/vda1 / ext4 rw 0 0\nnas:/export /data nfs rw,vers=3 0 0\n")).
+		OnRun("readlink", "", spec.ReturnSuccess("/data/app.log\n"))
+
+	flags := map[string]string{"filepath": "/data/app.log"}
+	if response := execDelete(ctx, cl, "delete-1", flags); response.Success || response.Code != spec.ParameterIllegal.Code {
+		t.Fatalf("expected the file on nfs is refused, %+v", response)
+	}
+
+	cl.Reset()
+	flags[exec.AllowRemoteFsFlagName] = "true"
+	response := execDelete(ctx, cl, "delete-2", flags)
+	if !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if result, ok := response.Result.(remoteFsResult); !ok || !strings.Contains(result.Warning, "nfs") {
+		t.Errorf("expected the warning of the remote filesystem, %+v", response.Result)
+	}
+	// the existence is checked by stat rather than test
+	if lines := cl.CommandLines(); !containsLine(lines, "stat -- /data/app.log") || containsLine(lines, "test -e /data/app.log") {
+		t.Errorf("expected the file is stat directly, got %q", lines)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// the fills of the generated content
+const (
+	FillRandom = "random"
+	FillZero   = "zero"
+	FillText   = "text"
+)
+
+// generateReservePercent is the percent of the filesystem kept free by the generated file
+const generateReservePercent = 5
+
+// generateChunkSize is the size of each write of the generated content
+const generateChunkSize = 1024 * 1024
+
+// generateProgressInterval is the interval of the progress logs of the generation
+var generateProgressInterval = 5 * time.Second
+
+// generateOptions are the flags of the content generated by the size
+type generateOptions struct {
+	size int64
+	fill string
+	seed int64
+	// seeded is true if the seed is specified rather than the time
+	seeded bool
+	// rate is the bytes written per second, it's unlimited if 0
+	rate int64
+}
+
+// textWords are the words of the text fill, which look like the lines of the application logs
+var textWords = []string{
+	"INFO", "WARN", "ERROR", "DEBUG", "request", "response", "user", "order", "payment", "timeout", "retry",
+	"connection", "established", "closed", "cache", "miss", "hit", "query", "completed", "failed", "in", "ms",
+	"session", "token", "refreshed", "upstream", "service", "latency", "bytes", "sent", "received", "job",
+}
+
+// fillReader generates the endless content of the fill, the random and the text ones are reproducible by the seed
+type fillReader struct {
+	fill   string
+	random *rand.Rand
+	line   []byte
+}
+
+func newFillReader(fill string, seed int64) *fillReader {
+	return &fillReader{fill: fill, random: rand.New(rand.NewSource(seed))}
+}
+
+func (r *fillReader) Read(p []byte) (int, error) {
+	switch r.fill {
+	case FillZero:
+		clear(p)
+	case FillText:
+		for n := 0; n < len(p); {
+			if len(r.line) == 0 {
+				r.line = r.nextLine()
+			}
+			copied := copy(p[n:], r.line)
+			r.line, n = r.line[copied:], n+copied
+		}
+	default:
+		r.random.Read(p)
+	}
+	return len(p), nil
+}
+
+// nextLine returns a line of the text fill, such as 2026-01-02T15:04:05Z WARN [worker-3] request timeout in 120 ms
+func (r *fillReader) nextLine() []byte {
+	timestamp := time.Unix(1700000000+r.random.Int63n(100000000), 0).UTC().Format(time.RFC3339)
+	line := fmt.Sprintf("%s %s [worker-%d]", timestamp, textWords[r.random.Intn(4)], r.random.Intn(16))
+	for i, words := 0, 4+r.random.Intn(8); i < words; i++ {
+		line += " " + textWords[4+r.random.Intn(len(textWords)-4)]
+	}
+	return []byte(line + " " + strconv.Itoa(r.random.Intn(1000)) + "\n")
+}
+
+// writeGenerated writes the size bytes of the fill to the writer, it's paced by the rate, and the progress is
+// logged periodically. It returns the bytes written, which are less than the size if the context is done.
+func writeGenerated(ctx context.Context, w io.Writer, options generateOptions) (int64, error) {
+	reader := newFillReader(options.fill, options.seed)
+	chunk := make([]byte, generateChunkSize)
+	if options.rate > 0 && options.rate < generateChunkSize {
+		// the small rate is paced by the smaller writes rather than the bursts
+		chunk = chunk[:max(options.rate/10, 1)]
+	}
+	start, lastLog := time.Now(), time.Now()
+	var written int64
+	for written < options.size {
+		select {
+		case <-ctx.Done():
+			return written, ctx.Err()
+		default:
+		}
+		buffer := chunk[:min(int64(len(chunk)), options.size-written)]
+		reader.Read(buffer)
+		n, err := w.Write(buffer)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		if options.rate > 0 {
+			expected := time.Duration(float64(written) / float64(options.rate) * float64(time.Second))
+			if wait := expected - time.Since(start); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return written, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+		if time.Since(lastLog) >= generateProgressInterval {
+			log.Infof(ctx, "generated %d of %d bytes of the %s fill", written, options.size, options.fill)
+			lastLog = time.Now()
+		}
+	}
+	return written, nil
+}
+
+// generateFileResult is the response of the file add with the generated content
+type generateFileResult struct {
+	File string `json:"file"`
+	Size int64  `json:"size"`
+	Fill string `json:"fill"`
+	// Seed reproduces the random and the text fills, it's 0 if the content is not generated natively
+	Seed int64 `json:"seed,omitempty"`
+}
+
+// generateFileNatively creates the file and writes the generated content, the partial file is removed on failure
+func generateFileNatively(ctx context.Context, filepath string, options generateOptions) *spec.Response {
+	file, err := os.OpenFile(filepath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Errorf(ctx, "create %s failed, %v", filepath, err)
+		return exec.FailWithFlags(exec.CommandFailed, "file", "create "+filepath, err)
+	}
+	log.Infof(ctx, "generate %d bytes of the %s fill to %s, the seed is %d", options.size, options.fill, filepath, options.seed)
+	written, err := writeGenerated(ctx, file, options)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Errorf(ctx, "generate %s failed after %d bytes, %v", filepath, written, err)
+		_ = os.Remove(filepath)
+		return exec.FailWithFlags(exec.CommandFailed, "file", "generate "+filepath, err)
+	}
+	return spec.ReturnSuccess(generateFileResult{File: filepath, Size: written, Fill: options.fill, Seed: options.seed})
+}
+
+// checkGenerateSpace returns the error if the file of the size leaves less than the reserve of the filesystem
+func checkGenerateSpace(size int64, total, available uint64) error {
+	reserve := total * generateReservePercent / 100
+	if available < reserve || uint64(size) > available-reserve {
+		return fmt.Errorf("the size %d is larger than the available %d bytes minus the reserve %d bytes, which is %d%% of the filesystem",
+			size, available, reserve, generateReservePercent)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteGenerated(t *testing.T) {
+	for _, fill := range []string{FillRandom, FillZero, FillText} {
+		var first, second bytes.Buffer
+		options := generateOptions{size: 3*generateChunkSize + 17, fill: fill, seed: 42}
+		if written, err := writeGenerated(context.Background(), &first, options); err != nil || written != options.size {
+			t.Fatalf("%s: expected %d bytes, got %d, %v", fill, options.size, written, err)
+		}
+		// the content is reproduced by the seed
+		writeGenerated(context.Background(), &second, options)
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Errorf("%s: expected the same content of the seed", fill)
+		}
+		switch fill {
+		case FillZero:
+			if bytes.Count(first.Bytes(), []byte{0}) != first.Len() {
+				t.Errorf("expected the zero fill")
+			}
+		case FillText:
+			if lines := strings.Split(first.String(), "\n"); len(lines) < 1000 || !strings.Contains(lines[0], "[worker-") {
+				t.Errorf("expected the lines of the text fill, got %q", lines[0])
+			}
+		}
+	}
+}
+
+func TestWriteGeneratedRate(t *testing.T) {
+	var buffer bytes.Buffer
+	start := time.Now()
+	options := generateOptions{size: 4000, fill: FillRandom, rate: 20000}
+	if written, err := writeGenerated(context.Background(), &buffer, options); err != nil || written != options.size {
+		t.Fatalf("expected %d bytes, got %d, %v", options.size, written, err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("expected the writes are paced by the rate, elapsed %v", elapsed)
+	}
+
+	// the generation stops with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := writeGenerated(ctx, &buffer, options); err == nil {
+		t.Errorf("expected the generation is canceled")
+	}
+}
+
+func TestGenerateFileNatively(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "backup.dat")
+	response := generateFileNatively(context.Background(), file, generateOptions{size: 1000, fill: FillText, seed: 1})
+	if !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Size() != 1000 {
+		t.Errorf("expected the file of 1000 bytes, %v", err)
+	}
+	// the existing file is not overwritten
+	if response := generateFileNatively(context.Background(), file, generateOptions{size: 10, fill: FillZero}); response.Success {
+		t.Errorf("expected the existing file is refused")
+	}
+}
+
+func TestCheckGenerateSpace(t *testing.T) {
+	tests := []struct {
+		size             int64
+		total, available uint64
+		refused          bool
+	}{
+		{size: 100, total: 1000, available: 200},
+		{size: 151, total: 1000, available: 200, refused: true},
+		{size: 1, total: 1000, available: 40, refused: true},
+	}
+	for _, tt := range tests {
+		if err := checkGenerateSpace(tt.size, tt.total, tt.available); (err != nil) != tt.refused {
+			t.Errorf("checkGenerateSpace(%d, %d, %d) = %v, refused %v", tt.size, tt.total, tt.available, err, tt.refused)
+		}
+	}
+}
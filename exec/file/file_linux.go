@@ -0,0 +1,35 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// getFileMode returns the permission bits of the file in octal, for example 644
+func getFileMode(ctx context.Context, cl spec.Channel, filepath string) *spec.Response {
+	return exec.RunReadOnlyArgv(ctx, cl, "stat", "-c", "%a", "--", filepath)
+}
+
+// statFile returns the identity, the size and the mtime of the file, the symlink is followed
+func statFile(ctx context.Context, cl spec.Channel, filepath string) (fileStat, error) {
+	return parseFileStat(exec.RunReadOnlyArgv(ctx, cl, "stat", "-L", "-c", "%d:%i %s %Y", "--", filepath))
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"path"
+	"strconv"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
@@ -49,7 +50,12 @@ func NewFileMoveActionSpec() spec.ExpActionCommandSpec {
 				},
 				&spec.ExpFlag{
 					Name:   "force",
-					Desc:   "use --force flag overwrite target file",
+					Desc:   "use --force flag overwrite target file, it also allows moving the protected path",
+					NoArgs: true,
+				},
+				&spec.ExpFlag{
+					Name:   "overwrite",
+					Desc:   "overwrite the existing file in the target folder, it's backed up and restored on destroy",
 					NoArgs: true,
 				},
 				&spec.ExpFlag{
@@ -57,6 +63,7 @@ func NewFileMoveActionSpec() spec.ExpActionCommandSpec {
 					Desc:   "automatically creates a directory that does not exist",
 					NoArgs: true,
 				},
+				allowRemoteFsFlag,
 			},
 			ActionExecutor: &FileMoveActionExecutor{},
 			ActionExample: `
@@ -68,6 +75,9 @@ blade create file move --filepath /home/logs/nginx.log --target /tmp --force
 
 # Move the file /home/logs/nginx.log to /temp/ and automatically create directories that don't exist
 blade create file move --filepath /home/logs/nginx.log --target /temp --auto-create-dir
+
+# Move the file /home/logs/nginx.log to /data/logs on another filesystem, the existing nginx.log there is restored on destroy
+blade create file move --filepath /home/logs/nginx.log --target /data/logs --overwrite
 `,
 			ActionPrograms:   []string{MoveFileBin},
 			ActionCategories: []string{category.SystemFile},
@@ -110,51 +120,154 @@ func (f *FileMoveActionExecutor) Exec(uid string, ctx context.Context, model *sp
 	target := model.ActionFlags["target"]
 
 	if _, ok := spec.IsDestroy(ctx); ok {
-		return f.stop(filepath, target, ctx)
+		return f.stop(uid, filepath, target, ctx)
 	}
 
 	force := model.ActionFlags["force"] == "true"
+	// the force flag overwrites the target file too
+	overwrite := force || model.ActionFlags["overwrite"] == "true"
 	autoCreateDir := model.ActionFlags["auto-create-dir"] == "true"
 
 	if !force {
-		targetFile := path.Join(target, "/", path.Base(filepath))
-		if exec.CheckFilepathExists(ctx, f.channel, targetFile) {
+		if response := exec.CheckProtectedPath(ctx, f.channel, "filepath", filepath, true); response != nil {
+			return response
+		}
+		if response := exec.CheckProtectedPath(ctx, f.channel, "target", target, false); response != nil {
+			return response
+		}
+	}
+
+	allowRemoteFs := model.ActionFlags[exec.AllowRemoteFsFlagName] == "true"
+	ctx, response := exec.CheckRemoteFilesystem(ctx, f.channel, "filepath", filepath, true, allowRemoteFs)
+	if response != nil {
+		return response
+	}
+	if _, ok := exec.RemoteFilesystem(ctx); !ok {
+		if ctx, response = exec.CheckRemoteFilesystem(ctx, f.channel, "target", target, true, allowRemoteFs); response != nil {
+			return response
+		}
+	}
+
+	targetFile := path.Join(target, "/", path.Base(filepath))
+	if exec.CheckFilepathExists(ctx, f.channel, targetFile) {
+		if !overwrite {
 			log.Errorf(ctx, "`%s`: target file already exists", targetFile)
 			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "target", targetFile, "the target file already exists")
 		}
+		if isDir(ctx, f.channel, targetFile) {
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "target", targetFile, "the target directory can't be overwritten")
+		}
 	}
-	return f.start(filepath, target, force, autoCreateDir, ctx)
+	return withRemoteFsWarning(ctx, f.start(uid, filepath, target, autoCreateDir, ctx))
 }
 
-func (f *FileMoveActionExecutor) start(filepath, target string, force, autoCreateDir bool, ctx context.Context) *spec.Response {
-	var response *spec.Response
+// the methods of the move, the copy is used across the filesystems, where the rename fails with EXDEV
+const (
+	moveByRename = "rename"
+	moveByCopy   = "copy"
+)
 
+// start moves the file into the target folder and records the destination and the method, so the destroy moves
+// it back by the same method. The existing destination is backed up and restored on destroy.
+func (f *FileMoveActionExecutor) start(uid, filepath, target string, autoCreateDir bool, ctx context.Context) *spec.Response {
 	if autoCreateDir && !exec.CheckFilepathExists(ctx, f.channel, target) {
-		response = f.channel.Run(ctx, "mkdir", fmt.Sprintf(`-p %s`, target))
-		if !response.Success {
+		if response := exec.RunArgv(ctx, f.channel, "mkdir", "-p", "--", target); !response.Success {
+			return response
+		}
+	}
+	destination := path.Join(target, path.Base(filepath))
+	method := moveByRename
+	if !sameFileSystem(ctx, f.channel, filepath, target) {
+		method = moveByCopy
+	}
+	clobbered := exec.CheckFilepathExists(ctx, f.channel, destination)
+	_, response := exec.NewExperimentState(ctx, uid, "file", "move", map[string]string{
+		"filepath": filepath, "target": target, "destination": destination, "method": method,
+		"overwritten": strconv.FormatBool(clobbered),
+	})
+	if response != nil {
+		return response
+	}
+	if clobbered {
+		if _, response := exec.Backup(ctx, f.channel, destination, uid); response != nil {
+			exec.ReleaseResources(ctx, uid)
 			return response
 		}
 	}
+	if response := moveByMethod(ctx, f.channel, method, filepath, destination); !response.Success {
+		if clobbered {
+			if restored, _ := exec.Restore(ctx, f.channel, uid, destination); restored != nil && !restored.Success {
+				log.Errorf(ctx, "restore the overwritten %s failed, %s", destination, restored.Err)
+			}
+		}
+		exec.ReleaseResources(ctx, uid)
+		return response
+	}
+	log.Infof(ctx, "%s is moved to %s by %s", filepath, destination, method)
+	return spec.ReturnSuccess(destination)
+}
 
-	if force {
-		// backup
-		_ = f.channel.Run(ctx, "cp", fmt.Sprintf(`"%s" "%s"`, path.Join(target, path.Base(filepath)),
-			path.Join(target, path.Base(filepath)+suffix)))
+// moveByMethod moves the source to the destination path. The copy removes the incomplete destination if it fails,
+// and removes the source after the copy completes.
+func moveByMethod(ctx context.Context, cl spec.Channel, method, source, destination string) *spec.Response {
+	if method == moveByRename {
+		return exec.RunArgv(ctx, cl, "mv", "-f", "--", source, destination)
+	}
+	// the existing destination is backed up, it's removed so the directory isn't copied into it
+	if exec.CheckFilepathExists(ctx, cl, destination) {
+		if response := removeAll(ctx, cl, destination); !response.Success {
+			return response
+		}
+	}
+	if response := copyPreserving(ctx, cl, source, destination); !response.Success {
+		log.Errorf(ctx, "copy %s to %s failed, the incomplete copy is removed, %s", source, destination, response.Err)
+		if removed := removeAll(ctx, cl, destination); !removed.Success {
+			log.Errorf(ctx, "remove the incomplete copy %s failed, %s", destination, removed.Err)
+		}
+		return exec.WithFailure(response, exec.CommandFailed, "file", fmt.Sprintf("copy %s", source))
+	}
+	if response := removeAll(ctx, cl, source); !response.Success {
+		// the source which is partially removed is replaced by the copy on destroy
+		log.Errorf(ctx, "remove %s after the copy failed, %s", source, response.Err)
+		return response
+	}
+	return spec.ReturnSuccess(destination)
+}
 
-		response = f.channel.Run(ctx, "mv", fmt.Sprintf(`-f "%s" "%s"`, filepath, target))
-	} else {
-		response = f.channel.Run(ctx, "mv", fmt.Sprintf(`"%s" "%s"`, filepath, target))
+// stop moves the recorded file back by the inverse of the method, then restores the overwritten file. Without
+// the record, the file is moved back by the flags.
+func (f *FileMoveActionExecutor) stop(uid, filepath, target string, ctx context.Context) *spec.Response {
+	if state, err := exec.LoadState(uid); err == nil && state.Flags["destination"] != "" {
+		if state.Destroyed {
+			log.Infof(ctx, "the experiment %s has been destroyed", uid)
+			return spec.ReturnSuccess(uid)
+		}
+		source, destination := state.Flags["filepath"], state.Flags["destination"]
+		if exec.CheckFilepathExists(ctx, f.channel, destination) {
+			if response := moveByMethod(ctx, f.channel, state.Flags["method"], destination, source); !response.Success {
+				return response
+			}
+		} else {
+			log.Warnf(ctx, "%s is not found, it's not moved back to %s", destination, source)
+		}
+		if state.Flags["overwritten"] == "true" {
+			if response, ok := exec.Restore(ctx, f.channel, uid, destination); ok && !response.Success {
+				return response
+			}
+		}
+		exec.ReleaseResources(ctx, uid)
+		return spec.ReturnSuccess(source)
 	}
-	return response
+	return f.stopByFlags(filepath, target, ctx)
 }
 
-func (f *FileMoveActionExecutor) stop(filepath, target string, ctx context.Context) *spec.Response {
+func (f *FileMoveActionExecutor) stopByFlags(filepath, target string, ctx context.Context) *spec.Response {
 	origin := path.Join(target, "/", path.Base(filepath))
-	response := f.channel.Run(ctx, "mv", fmt.Sprintf(`-f "%s" "%s"`, origin, path.Dir(filepath)))
+	response := exec.RunArgv(ctx, f.channel, "mv", "-f", "--", origin, path.Dir(filepath))
 	if response.Success {
 		// restore backup
-		_ = f.channel.Run(ctx, "mv", fmt.Sprintf(`"%s" "%s"`, path.Join(target, path.Base(filepath)+suffix),
-			path.Join(target, path.Base(filepath))))
+		_ = exec.RunArgv(ctx, f.channel, "mv", "--", path.Join(target, path.Base(filepath)+suffix),
+			path.Join(target, path.Base(filepath)))
 	}
 	return response
 }
@@ -0,0 +1,165 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func execMove(ctx context.Context, cl spec.Channel, uid string, flags map[string]string) *spec.Response {
+	executor := &FileMoveActionExecutor{}
+	executor.SetChannel(cl)
+	return executor.Exec(uid, ctx, &spec.ExpModel{Target: "file", ActionName: "move", ActionFlags: flags})
+}
+
+// crossDeviceChannel returns the channel on which /home and /data are on the different filesystems, and the
+// target file doesn't exist
+func crossDeviceChannel() *exec.MockChannel {
+	return exec.NewMockChannel().
+		OnRun("stat", "-- /home", spec.ReturnSuccess("2049\n")).
+		OnRun("stat", "-- /data", spec.ReturnSuccess("2065\n")).
+		OnRun("test", "^-e /data/logs/app.log$", spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+}
+
+func TestFileMoveActionExecutorRename(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().OnRun("test", "^-e /tmp/app.log$", spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+	flags := map[string]string{"filepath": "/home/logs/app.log", "target": "/tmp"}
+	if response := execMove(ctx, cl, "move-1", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"readlink -f -- /home/logs/app.log",
+		"readlink -f -- /tmp",
+		"cat -- /proc/mounts",
+		"cat -- /proc/mounts",
+		"test -e /tmp/app.log",
+		"stat -c %d -- /home/logs/app.log",
+		"stat -c %d -- /tmp",
+		"test -e /tmp/app.log",
+		"mv -f -- /home/logs/app.log /tmp/app.log",
+	})
+
+	// the file is renamed back by the record
+	cl = exec.NewMockChannel()
+	if response := execMove(spec.SetDestroyFlag(ctx, "move-1"), cl, "move-1", map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	if lines := cl.CommandLines(); len(lines) < 2 || lines[1] != "mv -f -- /tmp/app.log /home/logs/app.log" {
+		t.Errorf("expected the file is renamed back, got %q", lines)
+	}
+}
+
+func TestFileMoveActionExecutorCrossDevice(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := crossDeviceChannel()
+	flags := map[string]string{"filepath": "/home/app.log", "target": "/data/logs"}
+	cl.OnRun("stat", "-- /home/app.log", spec.ReturnSuccess("2049\n")).OnRun("stat", "-- /data/logs", spec.ReturnSuccess("2065\n"))
+	if response := execMove(ctx, cl, "move-2", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	lines := cl.CommandLines()
+	expected := []string{"cp -a -- /home/app.log /data/logs/app.log", "rm -rf -- /home/app.log"}
+	if len(lines) < 2 || lines[len(lines)-2] != expected[0] || lines[len(lines)-1] != expected[1] {
+		t.Errorf("expected the copy and the removal of the source, got %q", lines)
+	}
+	if state, err := exec.LoadState("move-2"); err != nil || state.Flags["method"] != moveByCopy {
+		t.Errorf("expected the method copy is recorded, %+v, %v", state, err)
+	}
+
+	// the file is copied back
+	cl = exec.NewMockChannel().OnRun("test", "^-e /home/app.log$", spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+	if response := execMove(spec.SetDestroyFlag(ctx, "move-2"), cl, "move-2", map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	lines = cl.CommandLines()
+	expected = []string{"cp -a -- /data/logs/app.log /home/app.log", "rm -rf -- /data/logs/app.log"}
+	if len(lines) < 2 || lines[len(lines)-2] != expected[0] || lines[len(lines)-1] != expected[1] {
+		t.Errorf("expected the copy back and the removal of the destination, got %q", lines)
+	}
+}
+
+func TestFileMoveActionExecutorPartialCopy(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := crossDeviceChannel().
+		OnRun("stat", "-- /home/app.log", spec.ReturnSuccess("2049\n")).
+		OnRun("stat", "-- /data/logs", spec.ReturnSuccess("2065\n")).
+		OnRun("cp", "-a", spec.ReturnFail(spec.OsCmdExecFailed, "No space left on device"))
+	flags := map[string]string{"filepath": "/home/app.log", "target": "/data/logs"}
+	if response := execMove(ctx, cl, "move-3", flags); response.Success {
+		t.Fatalf("expected the failure of the copy")
+	}
+	// the incomplete copy is removed, the source is kept
+	lines := cl.CommandLines()
+	if last := lines[len(lines)-1]; last != "rm -rf -- /data/logs/app.log" {
+		t.Errorf("expected the incomplete copy is removed, got %q", lines)
+	}
+	if state, err := exec.LoadState("move-3"); err != nil || !state.Destroyed {
+		t.Errorf("expected the record is released, %v", err)
+	}
+}
+
+func TestFileMoveActionExecutorOverwrite(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().
+		OnRun("test", "^-d ", spec.ReturnFail(spec.OsCmdExecFailed, "not a directory")).
+		OnRun("sha256sum", "", spec.ReturnSuccess("9f86d081  /tmp/app.log.chaos-blade-backup-move-4\n"))
+	flags := map[string]string{"filepath": "/home/app.log", "target": "/tmp"}
+	if response := execMove(ctx, cl, "move-4", flags); response.Success || response.Code != spec.ParameterInvalid.Code {
+		t.Fatalf("expected the existing target file is refused, %+v", response)
+	}
+
+	cl.Reset()
+	flags["overwrite"] = "true"
+	if response := execMove(ctx, cl, "move-4", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	lines := cl.CommandLines()
+	if lines[len(lines)-1] != "mv -f -- /home/app.log /tmp/app.log" ||
+		!containsLine(lines, "cp -p -- /tmp/app.log /tmp/app.log.chaos-blade-backup-move-4") {
+		t.Errorf("expected the overwritten file is backed up, got %q", lines)
+	}
+
+	// the overwritten file is restored after moving back
+	cl.Reset()
+	if response := execMove(spec.SetDestroyFlag(ctx, "move-4"), cl, "move-4", map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	if lines := cl.CommandLines(); !containsLine(lines, "cp -p -- /tmp/app.log.chaos-blade-backup-move-4 /tmp/app.log") {
+		t.Errorf("expected the overwritten file is restored, got %q", lines)
+	}
+}
+
+func containsLine(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// the policies of restoring the backup of the file rotated or replaced since the backup, such as by logrotate
+const (
+	// RotatedSkip leaves the rotated file and the backup alone
+	RotatedSkip = "skip"
+	// RotatedAside restores the backup next to the rotated file for the manual review
+	RotatedAside = "aside"
+)
+
+// restoredAsideSuffix is appended to the file for the backup restored aside
+const restoredAsideSuffix = ".chaosblade-restored"
+
+// backupStatKey is the detail of the record of the file stat at the backup
+const backupStatKey = "backup-stat"
+
+// fileStat is the identity, the size and the mtime of the file, the identity is the device and the inode
+type fileStat struct {
+	Identity string `json:"identity,omitempty"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"modTime"`
+}
+
+// parseFileStat parses the output of stat formatted as "dev:ino size mtime"
+func parseFileStat(response *spec.Response) (fileStat, error) {
+	if !response.Success {
+		return fileStat{}, errors.New(response.Err)
+	}
+	fields := strings.Fields(fmt.Sprint(response.Result))
+	if len(fields) != 3 {
+		return fileStat{}, fmt.Errorf("unexpected output of stat, %q", response.Result)
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return fileStat{}, fmt.Errorf("parse the size failed, %v", err)
+	}
+	modTime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return fileStat{}, fmt.Errorf("parse the mtime failed, %v", err)
+	}
+	return fileStat{Identity: fields[0], Size: size, ModTime: modTime}, nil
+}
+
+// rotatedReason returns why the file is taken as rotated or replaced since the backup, the file grown by the
+// append keeps the identity, and its size and mtime never decrease. It's empty if the file isn't rotated.
+func rotatedReason(recorded fileStat, current *fileStat) string {
+	switch {
+	case current == nil:
+		return "the file is removed or renamed since the backup"
+	case recorded.Identity != "" && current.Identity != recorded.Identity:
+		return fmt.Sprintf("the file is replaced since the backup, the identity is changed from %s to %s", recorded.Identity, current.Identity)
+	case current.Size < recorded.Size:
+		return fmt.Sprintf("the file is truncated since the backup, the size is decreased from %d to %d bytes", recorded.Size, current.Size)
+	case current.ModTime < recorded.ModTime:
+		return "the file is replaced by an older one since the backup, the mtime is earlier than at the backup"
+	}
+	return ""
+}
+
+// appendDestroyResult is the response of restoring the backup on destroy, with the stats of the file at the
+// backup and now as the evidence of the decision
+type appendDestroyResult struct {
+	File string `json:"file"`
+	// Decision is restored, skipped or restored-aside
+	Decision   string    `json:"decision"`
+	Reason     string    `json:"reason,omitempty"`
+	Backup     string    `json:"backup,omitempty"`
+	RestoredTo string    `json:"restoredTo,omitempty"`
+	Recorded   *fileStat `json:"recorded,omitempty"`
+	Current    *fileStat `json:"current,omitempty"`
+}
+
+// recordBackupStat records the stat of the file at the backup, so the destroy detects the rotated file
+func recordBackupStat(ctx context.Context, cl spec.Channel, uid, filepath string) {
+	stat, err := statFile(ctx, cl, filepath)
+	if err != nil {
+		log.Warnf(ctx, "stat %s failed, the rotated file isn't detected on destroy, %v", filepath, err)
+		return
+	}
+	bytes, _ := json.Marshal(stat)
+	if response := exec.SetBackupDetail(ctx, uid, backupStatKey, string(bytes)); response != nil {
+		log.Warnf(ctx, "record the stat of %s failed, %s", filepath, response.Err)
+	}
+}
+
+// checkRotated compares the file with the stat recorded at the backup, the reason of the result is empty if the
+// file isn't rotated. It returns nil if the backup is restored already or there is no stat recorded, then the
+// backup is restored to the file without the check.
+func (f *FileAppendActionExecutor) checkRotated(ctx context.Context, uid, filepath string) *appendDestroyResult {
+	state, err := exec.LoadState(uid)
+	if err != nil || state.Details[backupStatKey] == "" {
+		return nil
+	}
+	entry, ok := exec.FindBackup(ctx, f.channel, uid, filepath)
+	if !ok || entry.Restored || entry.Backup == "" {
+		return nil
+	}
+	var recorded fileStat
+	if err := json.Unmarshal([]byte(state.Details[backupStatKey]), &recorded); err != nil {
+		log.Warnf(ctx, "parse the stat of %s recorded at the backup failed, %v", filepath, err)
+		return nil
+	}
+	var current *fileStat
+	if fileExists(ctx, f.channel, filepath) {
+		stat, err := statFile(ctx, f.channel, filepath)
+		if err != nil {
+			log.Warnf(ctx, "stat %s failed, it's restored without the rotation check, %v", filepath, err)
+			return nil
+		}
+		current = &stat
+	}
+	return &appendDestroyResult{File: filepath, Reason: rotatedReason(recorded, current), Backup: entry.Backup,
+		Recorded: &recorded, Current: current}
+}
+
+// restoreRotated doesn't copy the backup over the rotated file, the backup is left alone or restored aside by
+// the policy
+func (f *FileAppendActionExecutor) restoreRotated(ctx context.Context, uid string, result appendDestroyResult, policy string) *spec.Response {
+	if policy == RotatedAside {
+		aside := result.File + restoredAsideSuffix
+		if response, _ := exec.RestoreTo(ctx, f.channel, uid, result.File, aside); !response.Success {
+			return response
+		}
+		log.Warnf(ctx, "%s, the backup is restored to %s for the manual review", result.Reason, aside)
+		result.Decision, result.RestoredTo, result.Backup = "restored-aside", aside, ""
+		return spec.ReturnSuccess(result)
+	}
+	// the backup is kept for the manual review, the record is destroyed with the experiment
+	log.Warnf(ctx, "%s, the backup %s isn't restored", result.Reason, result.Backup)
+	result.Decision = "skipped"
+	return spec.ReturnSuccess(result)
+}
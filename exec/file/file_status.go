@@ -0,0 +1,181 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// originSizeKey is the detail of the record of the file size before the append, the appended bytes are the
+// growth since
+const originSizeKey = "origin-size"
+
+// statFileChange returns the change of the file with whether it exists and its checksum, the checksum of the
+// directory is left empty
+func statFileChange(ctx context.Context, cl spec.Channel, filepath, change string) exec.FileChange {
+	fileChange := exec.FileChange{Path: filepath, Change: change}
+	if fileChange.Exists = fileExists(ctx, cl, filepath); fileChange.Exists && !isDir(ctx, cl, filepath) {
+		fileChange.Checksum, _ = exec.FileChecksum(ctx, cl, filepath)
+	}
+	return fileChange
+}
+
+// recordedStatus returns the report with the backups of the experiment not restored yet. It returns false if the
+// experiment is not recorded or destroyed, which is reported as the absent record.
+func recordedStatus(ctx context.Context, cl spec.Channel, uid string, model *spec.ExpModel,
+	state *exec.ExperimentState) (*exec.StatusReport, bool) {
+	report := exec.NewStatusReport(uid, model, state)
+	if state == nil || state.Destroyed {
+		report.Add(exec.Artifact{Kind: "record", Name: exec.GetStateFile(uid), Detail: "the experiment is not recorded or destroyed"})
+		return report, false
+	}
+	for _, change := range exec.BackupChanges(ctx, cl, state) {
+		report.AddFileChange(change)
+	}
+	return report, true
+}
+
+// appendChanges returns the appended file with the bytes grown since the record, and the directories created
+// for it. They are not artifacts, the content in the file is checked by the append status.
+func appendChanges(ctx context.Context, cl spec.Channel, uid, filepath string) []exec.FileChange {
+	change := statFileChange(ctx, cl, filepath, "append")
+	state, err := exec.LoadState(uid)
+	if err != nil || state.Destroyed {
+		return []exec.FileChange{change}
+	}
+	if !change.Exists {
+		change.Divergence = "the file is deleted"
+	} else if origin, err := strconv.ParseInt(state.Details[originSizeKey], 10, 64); err == nil {
+		if size, err := fileSize(ctx, cl, filepath); err == nil {
+			change.Bytes = size - origin
+			if change.Bytes < 0 {
+				change.Divergence = fmt.Sprintf("the file is truncated to %d bytes, smaller than %d bytes before the append", size, origin)
+			}
+		}
+	}
+	changes := []exec.FileChange{change}
+	for _, dir := range getCreatedDirs(uid) {
+		created := exec.FileChange{Path: dir, Change: "create", Exists: fileExists(ctx, cl, dir)}
+		if !created.Exists {
+			created.Divergence = "the directory is removed"
+		}
+		changes = append(changes, created)
+	}
+	return changes
+}
+
+// Status reports the modes changed by the experiment, the file replaced or changed to the other mode since is
+// reported as the divergence
+func (f *FileChmodActionExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report, ok := recordedStatus(ctx, f.channel, uid, model, state)
+	if !ok {
+		return report
+	}
+	mark, _ := strconv.ParseUint(state.Flags["mark"], 8, 32)
+	for _, entry := range state.Backups {
+		if entry.Restored || entry.Mode == "" {
+			continue
+		}
+		change := statFileChange(ctx, f.channel, entry.Path, "mode")
+		change.OriginMode = entry.Mode
+		if !change.Exists {
+			change.Divergence = "the file is deleted"
+			report.AddFileChange(change)
+			continue
+		}
+		if response := getFileMode(ctx, f.channel, entry.Path); response.Success {
+			change.Mode = strings.TrimSpace(fmt.Sprint(response.Result))
+		}
+		if identity, err := exec.FileIdentity(ctx, f.channel, entry.Path); err == nil && entry.Identity != "" && identity != entry.Identity {
+			change.Divergence = "the file is replaced since the experiment"
+		} else if mode, err := strconv.ParseUint(change.Mode, 8, 32); err == nil && state.Flags["mark"] != "" && mode != mark {
+			change.Divergence = fmt.Sprintf("the mode is changed to %s since the experiment", change.Mode)
+		}
+		report.AddFileChange(change)
+	}
+	return report
+}
+
+// Status reports the file created by the experiment
+func (f *FileAddActionExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report, ok := recordedStatus(ctx, f.channel, uid, model, state)
+	if !ok {
+		return report
+	}
+	change := statFileChange(ctx, f.channel, state.Flags["filepath"], "create")
+	if !change.Exists {
+		change.Divergence = "the file is deleted"
+	}
+	report.AddFileChange(change)
+	return report
+}
+
+// Status reports the file deleted by the experiment and its backup, the directory tree is archived by the record,
+// the file is moved aside without the record
+func (f *FileRemoveActionExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	filepath := normalizePath(model.ActionFlags["filepath"])
+	backup := path.Join(path.Dir(filepath), "."+md5Hex(path.Base(filepath)))
+	if state != nil {
+		if state.Destroyed {
+			report.Add(exec.Artifact{Kind: "record", Name: exec.GetStateFile(uid), Detail: "the experiment is destroyed"})
+			return report
+		}
+		filepath = state.Flags["filepath"]
+		backup = exec.GetBackupFile(filepath, uid) + ".tar"
+	} else if model.ActionFlags["force"] == "true" {
+		// the file deleted by force has no backup
+		backup = ""
+	}
+	change := exec.FileChange{Path: filepath, Change: "delete", Backup: backup}
+	if change.Exists = fileExists(ctx, f.channel, filepath); change.Exists {
+		change.Divergence = "the file is created again"
+	}
+	if backup != "" {
+		if change.BackupVerified = fileExists(ctx, f.channel, backup); !change.BackupVerified {
+			change.Divergence = fmt.Sprintf("the backup %s is missing", backup)
+		}
+	}
+	report.AddFileChange(change)
+	return report
+}
+
+// Status reports the file moved by the experiment, the existence and the checksum are of the moved one
+func (f *FileMoveActionExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report, ok := recordedStatus(ctx, f.channel, uid, model, state)
+	if !ok {
+		return report
+	}
+	destination := state.Flags["destination"]
+	change := statFileChange(ctx, f.channel, destination, "move")
+	change.Path, change.Target = state.Flags["filepath"], destination
+	if !change.Exists {
+		change.Divergence = fmt.Sprintf("the moved file %s is deleted", destination)
+	} else if fileExists(ctx, f.channel, change.Path) {
+		change.Divergence = "the file is created again at the original path"
+	}
+	report.AddFileChange(change)
+	return report
+}
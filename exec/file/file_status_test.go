@@ -0,0 +1,143 @@
+//go:build linux
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func sha256File(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func findFileChange(report *exec.StatusReport, change string) (exec.FileChange, bool) {
+	for _, c := range report.Files {
+		if c.Change == change {
+			return c, true
+		}
+	}
+	return exec.FileChange{}, false
+}
+
+func TestFileAppendActionExecutorStatus(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	file := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(file, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), spec.Uid, "append-status")
+	cl := channel.NewLocalChannel()
+	executor := &FileAppendActionExecutor{}
+	executor.SetChannel(cl)
+	model := &spec.ExpModel{Target: "file", ActionName: "append",
+		ActionFlags: map[string]string{"filepath": file, "content": "world", "enable-backup": "true"}}
+	if response := executor.Exec("append-status", ctx, model); response != nil && !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+
+	state, err := exec.LoadState("append-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := executor.Status(ctx, "append-status", model, state)
+	appended, ok := findFileChange(report, "append")
+	if !ok || !appended.Exists || appended.Bytes != int64(len("world\n")) || appended.Checksum != sha256File(t, file) {
+		t.Errorf("unexpected append in the inventory, %+v", appended)
+	}
+	backup, ok := findFileChange(report, "backup")
+	if !ok || !backup.BackupVerified || backup.Backup != exec.GetBackupFile(file, "append-status") || backup.Divergence != "" {
+		t.Errorf("unexpected backup in the inventory, %+v", backup)
+	}
+	if !report.Effective {
+		t.Errorf("expected the effective experiment, %+v", report.Artifacts)
+	}
+
+	// the missing backup diverges
+	if err := os.Remove(backup.Backup); err != nil {
+		t.Fatal(err)
+	}
+	report = executor.Status(ctx, "append-status", model, state)
+	if backup, _ := findFileChange(report, "backup"); !strings.Contains(backup.Divergence, "is missing") || report.Effective {
+		t.Errorf("expected the missing backup reported, %+v", backup)
+	}
+}
+
+func TestFileChmodActionExecutorStatus(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	file := filepath.Join(t.TempDir(), "app.conf")
+	if err := os.WriteFile(file, []byte("key=value\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	executor := &FileChmodActionExecutor{}
+	executor.SetChannel(channel.NewLocalChannel())
+	model := &spec.ExpModel{Target: "file", ActionName: "chmod", ActionFlags: map[string]string{"filepath": file, "mark": "600"}}
+	if response := executor.Exec("chmod-status", ctx, model); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+
+	status := func() exec.FileChange {
+		state, err := exec.LoadState("chmod-status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		change, _ := findFileChange(executor.Status(ctx, "chmod-status", model, state), "mode")
+		return change
+	}
+	if change := status(); change.OriginMode != "644" || change.Mode != "600" || change.Checksum != sha256File(t, file) ||
+		change.Divergence != "" {
+		t.Errorf("unexpected mode in the inventory, %+v", change)
+	}
+
+	// the mode changed by others diverges
+	if err := os.Chmod(file, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if change := status(); change.Mode != "640" || !strings.Contains(change.Divergence, "changed to 640") {
+		t.Errorf("expected the changed mode reported, %+v", change)
+	}
+
+	// the file replaced by a deploy diverges
+	if err := os.WriteFile(file+".new", []byte("key=new\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(file+".new", file); err != nil {
+		t.Fatal(err)
+	}
+	if change := status(); !strings.Contains(change.Divergence, "replaced") {
+		t.Errorf("expected the replaced file reported, %+v", change)
+	}
+}
@@ -0,0 +1,249 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/container"
+)
+
+// the files are changed by the commands run by the channel, so that they work in the namespaces of the containers
+
+func checkFileCommands(ctx context.Context, cl spec.Channel, commands []string) (*spec.Response, bool) {
+	return cl.IsAllCommandsAvailable(ctx, commands)
+}
+
+func normalizePath(filepath string) string {
+	return filepath
+}
+
+func fileExists(ctx context.Context, cl spec.Channel, filepath string) bool {
+	return exec.CheckFilepathExists(ctx, cl, filepath)
+}
+
+func fileContains(ctx context.Context, cl spec.Channel, filepath, content string) bool {
+	return exec.RunReadOnlyArgv(ctx, cl, "grep", "-qF", "--", content, filepath).Success
+}
+
+func moveFile(ctx context.Context, cl spec.Channel, source, target string) *spec.Response {
+	return exec.RunArgv(ctx, cl, "mv", "--", source, target)
+}
+
+func removeFile(ctx context.Context, cl spec.Channel, filepath string) *spec.Response {
+	return exec.RunArgv(ctx, cl, "rm", "--", filepath)
+}
+
+func removeAll(ctx context.Context, cl spec.Channel, filepath string) *spec.Response {
+	return exec.RunArgv(ctx, cl, "rm", "-rf", "--", filepath)
+}
+
+// makeDir creates the directory whose parent exists, the mode is the default of the umask if it's empty
+func makeDir(ctx context.Context, cl spec.Channel, dir, mode string) *spec.Response {
+	args := []string{"--", dir}
+	if mode != "" {
+		args = append([]string{"-m", mode}, args...)
+	}
+	return exec.RunArgv(ctx, cl, "mkdir", args...)
+}
+
+// fileSystemSpace returns the total and the available bytes of the filesystem of the directory by df, which
+// works in the mount namespace of the container too
+func fileSystemSpace(ctx context.Context, cl spec.Channel, dir string) (uint64, uint64, error) {
+	response := exec.RunReadOnlyArgv(ctx, cl, "df", "-Pk", "--", dir)
+	if !response.Success {
+		return 0, 0, errors.New(response.Err)
+	}
+	lines := strings.Split(strings.TrimSpace(fmt.Sprint(response.Result)), "\n")
+	if fields := strings.Fields(lines[len(lines)-1]); len(lines) > 1 && len(fields) >= 4 {
+		total, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parse the output of df failed, %v", err)
+		}
+		available, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parse the output of df failed, %v", err)
+		}
+		return total * 1024, available * 1024, nil
+	}
+	return 0, 0, fmt.Errorf("unexpected output of df, %v", response.Result)
+}
+
+// generateFile creates the file with the generated content. The local channel generates it natively, the other
+// channels, such as the one entering the container, copy it from /dev/urandom or /dev/zero by head, so neither
+// the seed nor the rate is supported.
+func generateFile(ctx context.Context, cl spec.Channel, filepath string, options generateOptions) *spec.Response {
+	if _, ok := cl.(*channel.LocalChannel); ok {
+		return generateFileNatively(ctx, filepath, options)
+	}
+	if options.rate > 0 {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "rate", options.rate, "the rate is supported on the host only")
+	}
+	if options.seeded {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "seed", options.seed, "the seed is supported on the host only")
+	}
+	switch options.fill {
+	case FillZero:
+		return cl.Run(ctx, "head", fmt.Sprintf("-c %d /dev/zero > %s", options.size, exec.ShellQuote(filepath)))
+	case FillText:
+		return cl.Run(ctx, "base64", fmt.Sprintf("/dev/urandom | head -c %d > %s", options.size, exec.ShellQuote(filepath)))
+	}
+	return cl.Run(ctx, "head", fmt.Sprintf("-c %d /dev/urandom > %s", options.size, exec.ShellQuote(filepath)))
+}
+
+func isDir(ctx context.Context, cl spec.Channel, filepath string) bool {
+	return exec.RunReadOnlyArgv(ctx, cl, "test", "-d", filepath).Success
+}
+
+// treeSize returns the estimated bytes of the directory tree by du
+func treeSize(ctx context.Context, cl spec.Channel, dir string) (int64, error) {
+	response := exec.RunReadOnlyArgv(ctx, cl, "du", "-sk", "--", dir)
+	if !response.Success {
+		return 0, errors.New(response.Err)
+	}
+	fields := strings.Fields(fmt.Sprint(response.Result))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("the output of du is empty")
+	}
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse the output of du failed, %v", err)
+	}
+	return size * 1024, nil
+}
+
+// fileSize returns the size of the file in bytes by wc, which works on both the GNU and the BSD hosts
+func fileSize(ctx context.Context, cl spec.Channel, filepath string) (int64, error) {
+	response := exec.RunReadOnlyArgv(ctx, cl, "wc", "-c", "--", filepath)
+	if !response.Success {
+		return 0, errors.New(response.Err)
+	}
+	fields := strings.Fields(fmt.Sprint(response.Result))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("the output of wc is empty")
+	}
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse the output of wc failed, %v", err)
+	}
+	return size, nil
+}
+
+// archiveTree archives the directory tree to the backup by tar, which keeps the owners by the numeric ids, the
+// modes and the symlinks. The xattrs are kept too if tar supports them. It returns the arguments of tar which
+// extracts the backup to the original path.
+func archiveTree(ctx context.Context, cl spec.Channel, dir, backup string) ([]string, *spec.Response) {
+	parent, base := path.Dir(dir), path.Base(dir)
+	archive := []string{"--numeric-owner", "-cpf", backup, "-C", parent, "--", base}
+	extract := []string{"--numeric-owner", "-xpf", backup, "-C", parent}
+	response := exec.RunArgv(ctx, cl, "tar", append([]string{"--xattrs"}, archive...)...)
+	if response.Success {
+		return append([]string{"--xattrs"}, extract...), nil
+	}
+	log.Warnf(ctx, "archive %s with the xattrs failed, they are not kept, %s", dir, response.Err)
+	if response := exec.RunArgv(ctx, cl, "tar", archive...); !response.Success {
+		return nil, response
+	}
+	return extract, nil
+}
+
+// sameFileSystem returns true if the paths are on the same filesystem by the device ids of stat, so the move is
+// a rename. It's true if the devices are unknown, then mv copies the file across the filesystems by itself.
+func sameFileSystem(ctx context.Context, cl spec.Channel, source, target string) bool {
+	devices := make([]string, 0, 2)
+	for _, p := range []string{source, target} {
+		response := exec.RunReadOnlyArgv(ctx, cl, "stat", "-c", "%d", "--", p)
+		if !response.Success {
+			log.Warnf(ctx, "get the device of %s failed, %s", p, response.Err)
+			return true
+		}
+		devices = append(devices, strings.TrimSpace(fmt.Sprint(response.Result)))
+	}
+	return devices[0] == devices[1]
+}
+
+// copyPreserving copies the file or the directory tree with the owners, the modes, the timestamps, the symlinks
+// and the xattrs where possible
+func copyPreserving(ctx context.Context, cl spec.Channel, source, target string) *spec.Response {
+	return exec.RunArgv(ctx, cl, "cp", "-a", "--", source, target)
+}
+
+// removeDir removes the directory if it's empty
+func removeDir(ctx context.Context, cl spec.Channel, dir string) *spec.Response {
+	return exec.RunArgv(ctx, cl, "rmdir", "--", dir)
+}
+
+// appendLine appends the content and a newline to the file, the backslash escapes are interpreted if escape is true
+func appendLine(ctx context.Context, cl spec.Channel, filepath, content string, escape bool) *spec.Response {
+	if escape {
+		content = interpretEscapes(content)
+	}
+	return exec.AppendFile(ctx, cl, filepath, content+"\n")
+}
+
+// syncAppendLines appends the lines by one write and flushes them to the disk, it returns the bytes synced
+func syncAppendLines(ctx context.Context, cl spec.Channel, filepath string, lines []string, escape bool) (int, *spec.Response) {
+	var builder strings.Builder
+	for _, line := range lines {
+		if escape {
+			line = interpretEscapes(line)
+		}
+		builder.WriteString(line + "\n")
+	}
+	if response := exec.SyncAppendFile(ctx, cl, filepath, builder.String()); !response.Success {
+		return 0, response
+	}
+	return builder.Len(), nil
+}
+
+// chownToContainer changes the owner of the file created in the mount namespace of the ns_target to the root of
+// its user namespace, otherwise the file created by the host root is owned by the overflow uid in the container
+func chownToContainer(ctx context.Context, cl spec.Channel, filepath string, recursive bool) *spec.Response {
+	pid, _ := ctx.Value(channel.NSTargetFlagName).(string)
+	p, err := strconv.Atoi(pid)
+	if err != nil {
+		return nil
+	}
+	uid, gid, err := container.RootOwner(p)
+	if err != nil {
+		log.Warnf(ctx, "read the user namespace of the pid %d failed, %v", p, err)
+		return nil
+	}
+	if uid == 0 && gid == 0 {
+		return nil
+	}
+	args := []string{"-h", fmt.Sprintf("%d:%d", uid, gid), "--", filepath}
+	if recursive {
+		args = append([]string{"-R"}, args...)
+	}
+	if response := exec.RunArgv(ctx, cl, "chown", args...); !response.Success {
+		return response
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"golang.org/x/sys/windows"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// there is no shell command to change the files on Windows, they are changed by the native file operations
+
+func checkFileCommands(ctx context.Context, cl spec.Channel, commands []string) (*spec.Response, bool) {
+	return nil, true
+}
+
+// normalizePath converts the backslashes to the slashes, so that the path is parsed by the path package
+func normalizePath(path string) string {
+	return filepath.ToSlash(path)
+}
+
+func fileExists(ctx context.Context, cl spec.Channel, path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func fileContains(ctx context.Context, cl spec.Channel, path, content string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.Contains(string(data), content)
+}
+
+func moveFile(ctx context.Context, cl spec.Channel, source, target string) *spec.Response {
+	if err := os.Rename(source, target); err != nil {
+		return fileOperationFailed(ctx, "move "+source, err)
+	}
+	return spec.ReturnSuccess(target)
+}
+
+func removeFile(ctx context.Context, cl spec.Channel, path string) *spec.Response {
+	if err := os.Remove(path); err != nil {
+		return fileOperationFailed(ctx, "remove "+path, err)
+	}
+	return spec.ReturnSuccess(path)
+}
+
+func removeAll(ctx context.Context, cl spec.Channel, path string) *spec.Response {
+	if err := os.RemoveAll(path); err != nil {
+		return fileOperationFailed(ctx, "remove "+path, err)
+	}
+	return spec.ReturnSuccess(path)
+}
+
+// makeDir creates the directory whose parent exists, the mode is 0755 if it's empty
+func makeDir(ctx context.Context, cl spec.Channel, dir, mode string) *spec.Response {
+	perm := uint64(0755)
+	if mode != "" {
+		var err error
+		if perm, err = strconv.ParseUint(mode, 8, 32); err != nil {
+			return fileOperationFailed(ctx, "mkdir "+dir, err)
+		}
+	}
+	if err := os.Mkdir(dir, os.FileMode(perm)); err != nil {
+		return fileOperationFailed(ctx, "mkdir "+dir, err)
+	}
+	return spec.ReturnSuccess(dir)
+}
+
+// fileSystemSpace returns the total and the available bytes of the drive of the directory
+func fileSystemSpace(ctx context.Context, cl spec.Channel, dir string) (uint64, uint64, error) {
+	var available, total, free uint64
+	name, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := windows.GetDiskFreeSpaceEx(name, &available, &total, &free); err != nil {
+		return 0, 0, err
+	}
+	return total, available, nil
+}
+
+// generateFile creates the file with the generated content natively
+func generateFile(ctx context.Context, cl spec.Channel, path string, options generateOptions) *spec.Response {
+	return generateFileNatively(ctx, path, options)
+}
+
+func isDir(ctx context.Context, cl spec.Channel, path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// treeSize returns the bytes of the regular files in the directory tree
+func treeSize(ctx context.Context, cl spec.Channel, dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func fileSize(ctx context.Context, cl spec.Channel, path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// archiveTree refuses the backup of the directory tree, whose owners and acls can't be restored on Windows
+func archiveTree(ctx context.Context, cl spec.Channel, dir, backup string) ([]string, *spec.Response) {
+	return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "recursive", dir,
+		"the backup of the directory tree is not supported on windows, specify --force to delete it unrecoverable")
+}
+
+// sameFileSystem returns true if the paths are on the same volume
+func sameFileSystem(ctx context.Context, cl spec.Channel, source, target string) bool {
+	return strings.EqualFold(filepath.VolumeName(source), filepath.VolumeName(target))
+}
+
+// copyPreserving refuses the copy across the volumes, whose owners and acls can't be kept natively
+func copyPreserving(ctx context.Context, cl spec.Channel, source, target string) *spec.Response {
+	return spec.ResponseFailWithFlags(spec.ParameterIllegal, "target", target,
+		"moving across the volumes is not supported on windows")
+}
+
+// removeDir removes the directory if it's empty
+func removeDir(ctx context.Context, cl spec.Channel, dir string) *spec.Response {
+	if err := os.Remove(dir); err != nil {
+		return fileOperationFailed(ctx, "rmdir "+dir, err)
+	}
+	return spec.ReturnSuccess(dir)
+}
+
+// appendLine appends the content and CRLF to the file, the backslash escapes are interpreted if escape is true
+func appendLine(ctx context.Context, cl spec.Channel, path, content string, escape bool) *spec.Response {
+	if escape {
+		content = interpretEscapes(content)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fileOperationFailed(ctx, "append "+path, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content + "\r\n"); err != nil {
+		return fileOperationFailed(ctx, "append "+path, err)
+	}
+	return spec.ReturnSuccess(path)
+}
+
+// syncAppendLines appends the lines by one write and flushes them to the disk, it returns the bytes synced
+func syncAppendLines(ctx context.Context, cl spec.Channel, path string, lines []string, escape bool) (int, *spec.Response) {
+	var builder strings.Builder
+	for _, line := range lines {
+		if escape {
+			line = interpretEscapes(line)
+		}
+		builder.WriteString(line + "\r\n")
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fileOperationFailed(ctx, "append "+path, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(builder.String()); err != nil {
+		return 0, fileOperationFailed(ctx, "append "+path, err)
+	}
+	if err := file.Sync(); err != nil {
+		return 0, fileOperationFailed(ctx, "sync "+path, err)
+	}
+	return builder.Len(), nil
+}
+
+func fileOperationFailed(ctx context.Context, operation string, err error) *spec.Response {
+	log.Errorf(ctx, "%s failed, %v", operation, err)
+	return exec.FailWithFlags(exec.CommandFailed, "file", operation, err)
+}
+
+// getFileMode returns the permission bits of the file in octal
+func getFileMode(ctx context.Context, cl spec.Channel, filepath string) *spec.Response {
+	info, err := os.Stat(filepath)
+	if err != nil {
+		return fileOperationFailed(ctx, "stat", err)
+	}
+	return spec.ReturnSuccess(strconv.FormatUint(uint64(info.Mode().Perm()), 8))
+}
+
+// statFile returns the size and the mtime of the file, the file index isn't exposed by os.Stat, so the identity
+// is empty and the replaced file is detected by the size and the mtime only
+func statFile(ctx context.Context, cl spec.Channel, path string) (fileStat, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStat{}, err
+	}
+	return fileStat{Size: info.Size(), ModTime: info.ModTime().Unix()}, nil
+}
+
+// chownToContainer does nothing, there are no containers sharing the kernel on Windows
+func chownToContainer(ctx context.Context, cl spec.Channel, path string, recursive bool) *spec.Response {
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/shirou/gopsutil/process"
+)
+
+// ChaosProcess is a running process started for an experiment
+type ChaosProcess struct {
+	Pid        int32     `json:"pid"`
+	Uid        string    `json:"uid"`
+	Target     string    `json:"target,omitempty"`
+	Action     string    `json:"action,omitempty"`
+	Bin        string    `json:"bin"`
+	Cmdline    string    `json:"cmdline"`
+	CreateTime time.Time `json:"createTime"`
+	Args       []string  `json:"-"`
+}
+
+// IsStale returns true if the uid is not in the allow list, or the process lives longer than ttl.
+// The empty allow list and the zero ttl are not used for the check.
+func (p ChaosProcess) IsStale(allowUids map[string]bool, ttl time.Duration, now time.Time) bool {
+	if len(allowUids) > 0 && !allowUids[p.Uid] {
+		return true
+	}
+	if ttl > 0 && !p.CreateTime.IsZero() && now.Sub(p.CreateTime) > ttl {
+		return true
+	}
+	return false
+}
+
+// ListChaosProcesses returns the running chaos_os processes in create mode and the processes started
+// from the given bins by the old versions, the uid is parsed from the command line.
+func ListChaosProcesses(ctx context.Context, bins []string) ([]ChaosProcess, error) {
+	processes, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	currPid := int32(os.Getpid())
+	chaosProcesses := make([]ChaosProcess, 0)
+	for _, p := range processes {
+		if p.Pid == currPid {
+			continue
+		}
+		args, err := p.CmdlineSlice()
+		if err != nil || len(args) == 0 {
+			continue
+		}
+		bin := matchChaosBin(filepath.Base(args[0]), bins)
+		if bin == "" {
+			continue
+		}
+		chaosProcess := ChaosProcess{
+			Pid:     p.Pid,
+			Uid:     parseUidFromArgs(args),
+			Bin:     bin,
+			Cmdline: strings.Join(args, " "),
+			Args:    args,
+		}
+		if bin == spec.ChaosOsBin {
+			// chaos_os create target action --flags
+			if len(args) < 4 || args[1] != spec.Create {
+				continue
+			}
+			chaosProcess.Target = args[2]
+			chaosProcess.Action = args[3]
+		}
+		if createTime, err := p.CreateTime(); err == nil {
+			chaosProcess.CreateTime = time.UnixMilli(createTime)
+		} else {
+			log.Warnf(ctx, "get create time of process %d failed, %v", p.Pid, err)
+		}
+		chaosProcesses = append(chaosProcesses, chaosProcess)
+	}
+	return chaosProcesses, nil
+}
+
+func matchChaosBin(name string, bins []string) string {
+	if name == spec.ChaosOsBin {
+		return spec.ChaosOsBin
+	}
+	for _, bin := range bins {
+		if name == bin {
+			return bin
+		}
+	}
+	return ""
+}
+
+// parseUidFromArgs supports --uid value, --uid=value and the single dash forms
+func parseUidFromArgs(args []string) string {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if name == "uid" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, "uid=") {
+			return strings.TrimPrefix(name, "uid=")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,345 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/goodhosts/hostsfile"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/network"
+)
+
+const (
+	hostnameFile       = "/etc/hostname"
+	kernelHostnameFile = "/proc/sys/kernel/hostname"
+	// the max length of the kernel hostname
+	maxHostnameLength  = 64
+	HostnameByCtl      = "hostnamectl"
+	HostnameByHostname = "hostname"
+)
+
+var hostnameLabelRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?$`)
+
+// the addresses of the lines in the hosts file which the local hostname is resolved by
+var localHostsAddresses = []string{"127.0.1.1", "127.0.0.1", "::1"}
+
+type HostnameActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewHostnameActionCommandSpec() spec.ExpActionCommandSpec {
+	return &HostnameActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     "hostname",
+					Desc:     "The new hostname",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name:   "update-hosts",
+					Desc:   "Replace the hostname in the 127.0.1.1 and localhost lines of the hosts file too, the hosts file is restored when the experiment is destroyed",
+					NoArgs: true,
+				},
+			},
+			ActionExecutor: &HostnameExecutor{},
+			ActionExample: `
+# Change the hostname to chaos-node
+blade create host hostname --hostname chaos-node
+
+# Change the hostname to chaos-node and resolve it by the hosts file
+blade create host hostname --hostname chaos-node --update-hosts`,
+			ActionCategories: []string{category.SystemHost},
+		},
+	}
+}
+
+func (*HostnameActionCommandSpec) Name() string {
+	return "hostname"
+}
+
+func (*HostnameActionCommandSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*HostnameActionCommandSpec) ShortDesc() string {
+	return "Change hostname"
+}
+
+func (h *HostnameActionCommandSpec) LongDesc() string {
+	if h.ActionLongDesc != "" {
+		return h.ActionLongDesc
+	}
+	return "Change the static and transient hostname by hostnamectl, or by the hostname command and /etc/hostname, " +
+		"the original hostnames are restored when the experiment is destroyed"
+}
+
+func (*HostnameActionCommandSpec) Categories() []string {
+	return []string{category.SystemHost}
+}
+
+type HostnameExecutor struct {
+	channel spec.Channel
+}
+
+func (he *HostnameExecutor) Name() string {
+	return "hostname"
+}
+
+// hostnameState is the original transient hostname and /etc/hostname which is the static hostname
+type hostnameState struct {
+	Method        string `json:"method"`
+	Transient     string `json:"transient"`
+	Static        string `json:"static"`
+	StaticMissing bool   `json:"staticMissing"`
+	StaticFile    []byte `json:"staticFile,omitempty"`
+	HostsUpdated  bool   `json:"hostsUpdated"`
+}
+
+func (he *HostnameExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return he.stop(ctx, uid)
+	}
+	hostname := model.ActionFlags["hostname"]
+	if hostname == "" {
+		log.Errorf(ctx, "hostname is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "hostname")
+	}
+	if err := checkHostname(hostname); err != nil {
+		log.Errorf(ctx, "`%s`: hostname is illegal, %v", hostname, err)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "hostname", hostname, err)
+	}
+	return he.start(ctx, uid, hostname, model.ActionFlags["update-hosts"] == "true")
+}
+
+func (he *HostnameExecutor) SetChannel(channel spec.Channel) {
+	he.channel = channel
+}
+
+func (he *HostnameExecutor) start(ctx context.Context, uid, hostname string, updateHosts bool) *spec.Response {
+	stateFile := getHostnameStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the hostname state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	state, err := snapshotHostname()
+	if err != nil {
+		log.Errorf(ctx, "read the original hostname failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "snapshotHostname", err)
+	}
+	state.Method = HostnameByHostname
+	// hostnamectl requires systemd-hostnamed, which is not running in most containers
+	if he.channel.IsCommandAvailable(ctx, "hostnamectl") && he.channel.Run(ctx, "hostnamectl", "--static").Success {
+		state.Method = HostnameByCtl
+	}
+	state.HostsUpdated = updateHosts
+	log.Infof(ctx, "the original hostname is %s, the static hostname is %s", state.Transient, state.Static)
+	if err := writeHostnameState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write the hostname state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writeHostnameState", err)
+	}
+	if updateHosts {
+		if response := network.BackupHostsFile(ctx, he.channel, uid); !response.Success {
+			log.Errorf(ctx, "backup the hosts file failed, %s", response.Err)
+			os.Remove(stateFile)
+			return response
+		}
+		if err := replaceHostsHostname(state.Transient, hostname); err != nil {
+			log.Errorf(ctx, "update the hosts file failed, %v", err)
+			he.stop(ctx, uid)
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "update hosts", err)
+		}
+	}
+	if response := he.setHostname(ctx, state.Method, hostname); !response.Success {
+		log.Errorf(ctx, "set the hostname failed, %s", response.Err)
+		he.stop(ctx, uid)
+		return response
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (he *HostnameExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	stateFile := getHostnameStateFile(uid)
+	state, err := readHostnameState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warnf(ctx, "`%s`: the hostname state file not found, the experiment may be destroyed", stateFile)
+			return spec.ReturnSuccess(uid)
+		}
+		log.Errorf(ctx, "read the hostname state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "readHostnameState", err)
+	}
+	if state.Method == HostnameByCtl {
+		// let hostnamed know the original hostnames, the exact /etc/hostname is restored then
+		if state.Static != "" {
+			if response := he.channel.Run(ctx, "hostnamectl", fmt.Sprintf(`set-hostname --static "%s"`, state.Static)); !response.Success {
+				log.Warnf(ctx, "restore the static hostname by hostnamectl failed, %s", response.Err)
+			}
+		}
+		if response := he.channel.Run(ctx, "hostnamectl", fmt.Sprintf(`set-hostname --transient "%s"`, state.Transient)); !response.Success {
+			log.Warnf(ctx, "restore the transient hostname by hostnamectl failed, %s", response.Err)
+		}
+	}
+	if err := restoreHostnameFile(state); err != nil {
+		log.Errorf(ctx, "restore %s failed, %v", hostnameFile, err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "restoreHostnameFile", err)
+	}
+	if current, err := os.Hostname(); err != nil || current != state.Transient {
+		if response := he.setTransientHostname(ctx, state.Transient); !response.Success {
+			log.Errorf(ctx, "restore the hostname failed, %s", response.Err)
+			return response
+		}
+	}
+	if state.HostsUpdated {
+		if response := network.RestoreHostsFile(ctx, he.channel, uid); !response.Success {
+			return response
+		}
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+// setHostname sets both the static and the transient hostname
+func (he *HostnameExecutor) setHostname(ctx context.Context, method, hostname string) *spec.Response {
+	if method == HostnameByCtl {
+		return he.channel.Run(ctx, "hostnamectl", fmt.Sprintf(`set-hostname "%s"`, hostname))
+	}
+	if err := os.WriteFile(hostnameFile, []byte(hostname+"\n"), 0644); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "write "+hostnameFile, err)
+	}
+	return he.setTransientHostname(ctx, hostname)
+}
+
+// setTransientHostname sets the kernel hostname by the hostname command, or by writing the kernel parameter directly
+func (he *HostnameExecutor) setTransientHostname(ctx context.Context, hostname string) *spec.Response {
+	if he.channel.IsCommandAvailable(ctx, "hostname") {
+		return he.channel.Run(ctx, "hostname", fmt.Sprintf(`"%s"`, hostname))
+	}
+	if err := os.WriteFile(kernelHostnameFile, []byte(hostname), 0644); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "write "+kernelHostnameFile, err)
+	}
+	return spec.ReturnSuccess(hostname)
+}
+
+// checkHostname checks that the hostname is the dot separated labels of letters, digits and hyphens
+func checkHostname(hostname string) error {
+	if len(hostname) > maxHostnameLength {
+		return fmt.Errorf("it must not be longer than %d characters", maxHostnameLength)
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		if !hostnameLabelRegexp.MatchString(label) {
+			return fmt.Errorf("the label %q must consist of letters, digits and hyphens, and start and end with a letter or digit", label)
+		}
+	}
+	return nil
+}
+
+// snapshotHostname records the kernel hostname and /etc/hostname
+func snapshotHostname() (hostnameState, error) {
+	var state hostnameState
+	var err error
+	if state.Transient, err = os.Hostname(); err != nil {
+		return state, err
+	}
+	data, err := os.ReadFile(hostnameFile)
+	switch {
+	case os.IsNotExist(err):
+		state.StaticMissing = true
+	case err != nil:
+		return state, err
+	default:
+		state.StaticFile = data
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				state.Static = line
+				break
+			}
+		}
+	}
+	return state, nil
+}
+
+// restoreHostnameFile restores /etc/hostname to the original content, or removes it if it was missing
+func restoreHostnameFile(state hostnameState) error {
+	if state.StaticMissing {
+		if err := os.Remove(hostnameFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(hostnameFile, state.StaticFile, 0644)
+}
+
+// replaceHostsHostname replaces the old hostname with the new one in the local lines of the hosts file,
+// the 127.0.1.1 line is added if the old hostname is not found
+func replaceHostsHostname(oldHostname, newHostname string) error {
+	hostsFile, err := hostsfile.NewHosts()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for _, address := range localHostsAddresses {
+		if !hostsFile.Has(address, oldHostname) {
+			continue
+		}
+		if err := hostsFile.Remove(address, oldHostname); err != nil {
+			return err
+		}
+		if err := hostsFile.Add(address, newHostname); err != nil {
+			return err
+		}
+		replaced = true
+	}
+	if !replaced {
+		if err := hostsFile.Add(localHostsAddresses[0], newHostname); err != nil {
+			return err
+		}
+	}
+	return hostsFile.Flush()
+}
+
+func getHostnameStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-host-hostname-%s.json", uid)
+}
+
+func writeHostnameState(stateFile string, state hostnameState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readHostnameState(stateFile string) (hostnameState, error) {
+	var state hostnameState
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
@@ -0,0 +1,337 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const (
+	PowerReboot   = "reboot"
+	PowerShutdown = "shutdown"
+
+	PowerByShutdown   = "shutdown"
+	PowerBySystemdRun = "systemd-run"
+
+	PowerPending   = "pending"
+	PowerHappened  = "happened"
+	PowerCancelled = "cancelled"
+	// the deadline has passed but the host has not been booted again, it is shutting down or cancelled by others
+	PowerExpired = "expired"
+
+	powerConfirm = "yes-really"
+	bootIdFile   = "/proc/sys/kernel/random/boot_id"
+	// the scheduled shutdown of systemd, only one can be scheduled at the same time
+	scheduledShutdownFile = "/run/systemd/shutdown/scheduled"
+	// the state must survive the reboot to tell whether it happened, so that it is not in /tmp which may be cleaned
+	powerStateDir = "/var/lib/chaosblade"
+)
+
+var powerFlags = []spec.ExpFlagSpec{
+	&spec.ExpFlag{
+		Name:    "delay",
+		Desc:    "The delay of the operation in minutes, the experiment can be destroyed to cancel it before the deadline, default value is 1",
+		Default: "1",
+	},
+	&spec.ExpFlag{
+		Name:     "confirm",
+		Desc:     "Confirm the operation, it must be yes-really",
+		Required: true,
+	},
+}
+
+type RebootActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewRebootActionCommandSpec() spec.ExpActionCommandSpec {
+	return &RebootActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags:    powerFlags,
+			ActionExecutor: &PowerExecutor{mode: PowerReboot},
+			ActionExample: `
+# Reboot the host after 5 minutes, destroy the experiment before the deadline to cancel it
+blade create host reboot --delay 5 --confirm yes-really`,
+			ActionCategories: []string{category.SystemHost},
+		},
+	}
+}
+
+func (*RebootActionCommandSpec) Name() string {
+	return PowerReboot
+}
+
+func (*RebootActionCommandSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*RebootActionCommandSpec) ShortDesc() string {
+	return "Reboot host"
+}
+
+func (r *RebootActionCommandSpec) LongDesc() string {
+	if r.ActionLongDesc != "" {
+		return r.ActionLongDesc
+	}
+	return "Schedule the reboot of the host after the delay by shutdown or a systemd timer, it is cancelled if the experiment is destroyed before the deadline"
+}
+
+func (*RebootActionCommandSpec) Categories() []string {
+	return []string{category.SystemHost}
+}
+
+type ShutdownActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewShutdownActionCommandSpec() spec.ExpActionCommandSpec {
+	return &ShutdownActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags:    powerFlags,
+			ActionExecutor: &PowerExecutor{mode: PowerShutdown},
+			ActionExample: `
+# Power off the host after 10 minutes, destroy the experiment before the deadline to cancel it
+blade create host shutdown --delay 10 --confirm yes-really`,
+			ActionCategories: []string{category.SystemHost},
+		},
+	}
+}
+
+func (*ShutdownActionCommandSpec) Name() string {
+	return PowerShutdown
+}
+
+func (*ShutdownActionCommandSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*ShutdownActionCommandSpec) ShortDesc() string {
+	return "Shutdown host"
+}
+
+func (s *ShutdownActionCommandSpec) LongDesc() string {
+	if s.ActionLongDesc != "" {
+		return s.ActionLongDesc
+	}
+	return "Schedule the power off of the host after the delay by shutdown or a systemd timer, it is cancelled if the experiment is destroyed before the deadline"
+}
+
+func (*ShutdownActionCommandSpec) Categories() []string {
+	return []string{category.SystemHost}
+}
+
+type PowerExecutor struct {
+	channel spec.Channel
+	mode    string
+}
+
+func (pe *PowerExecutor) Name() string {
+	return pe.mode
+}
+
+// powerState is the scheduled operation, the boot id tells whether the host has been rebooted
+type powerState struct {
+	Mode     string    `json:"mode"`
+	Method   string    `json:"method"`
+	Deadline time.Time `json:"deadline"`
+	BootId   string    `json:"bootId"`
+	Unit     string    `json:"unit,omitempty"`
+}
+
+// PowerStatus is the status of the scheduled reboot or shutdown of the experiment
+type PowerStatus struct {
+	Uid      string `json:"uid"`
+	Mode     string `json:"mode"`
+	Deadline string `json:"deadline"`
+	Status   string `json:"status"`
+}
+
+func (pe *PowerExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return pe.stop(ctx, uid)
+	}
+	if confirm := model.ActionFlags["confirm"]; confirm != powerConfirm {
+		log.Errorf(ctx, "`%s`: confirm is invalid", confirm)
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "confirm", confirm, "it must be "+powerConfirm)
+	}
+	delayStr := model.ActionFlags["delay"]
+	if delayStr == "" {
+		delayStr = "1"
+	}
+	delay, err := strconv.Atoi(delayStr)
+	if err != nil || delay < 1 {
+		log.Errorf(ctx, "`%s`: delay is illegal", delayStr)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "delay", delayStr, "it must be a positive integer")
+	}
+	return pe.start(ctx, uid, delay)
+}
+
+func (pe *PowerExecutor) SetChannel(channel spec.Channel) {
+	pe.channel = channel
+}
+
+func (pe *PowerExecutor) start(ctx context.Context, uid string, delay int) *spec.Response {
+	stateFile := getPowerStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the power state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	// a new scheduled shutdown replaces the existing one, which cannot be restored
+	if _, err := os.Stat(scheduledShutdownFile); err == nil {
+		log.Errorf(ctx, "a shutdown has been scheduled, %s exists", scheduledShutdownFile)
+		return spec.ReturnFail(spec.OsCmdExecFailed, "a shutdown has been scheduled by others, cancel it first")
+	}
+	bootId, err := readBootId()
+	if err != nil {
+		log.Errorf(ctx, "read the boot id failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "read "+bootIdFile, err)
+	}
+	state := powerState{
+		Mode:     pe.mode,
+		Deadline: time.Now().Add(time.Duration(delay) * time.Minute),
+		BootId:   bootId,
+	}
+	var command, args string
+	switch {
+	case pe.channel.IsCommandAvailable(ctx, "shutdown"):
+		state.Method = PowerByShutdown
+		flag := "-r"
+		if pe.mode == PowerShutdown {
+			flag = "-h"
+		}
+		command, args = "shutdown", fmt.Sprintf(`%s +%d "chaosblade experiment %s"`, flag, delay, uid)
+	case pe.channel.IsCommandAvailable(ctx, "systemd-run"):
+		state.Method = PowerBySystemdRun
+		state.Unit = fmt.Sprintf("chaosblade-%s-%s", pe.mode, uid)
+		operation := "reboot"
+		if pe.mode == PowerShutdown {
+			operation = "poweroff"
+		}
+		command, args = "systemd-run", fmt.Sprintf(`--on-active=%dm --unit "%s" systemctl %s`, delay, state.Unit, operation)
+	default:
+		log.Errorf(ctx, "shutdown and systemd-run not found")
+		return spec.ReturnFail(spec.OsCmdExecFailed, "`shutdown` and `systemd-run`: command not found")
+	}
+	if err := writePowerState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write the power state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writePowerState", err)
+	}
+	log.Warnf(ctx, "the host will %s at %s unless the experiment is destroyed", pe.mode, state.Deadline.Format(time.RFC3339))
+	if response := pe.channel.Run(ctx, command, args); !response.Success {
+		os.Remove(stateFile)
+		return response
+	}
+	return spec.ReturnSuccess(PowerStatus{Uid: uid, Mode: pe.mode, Deadline: state.Deadline.Format(time.RFC3339), Status: PowerPending})
+}
+
+func (pe *PowerExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	stateFile := getPowerStateFile(uid)
+	state, err := readPowerState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warnf(ctx, "`%s`: the power state file not found, the experiment may be destroyed", stateFile)
+			return spec.ReturnSuccess(uid)
+		}
+		log.Errorf(ctx, "read the power state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "readPowerState", err)
+	}
+	status := getPowerStatus(uid, state)
+	if status.Status == PowerPending {
+		var response *spec.Response
+		if state.Method == PowerBySystemdRun {
+			response = pe.channel.Run(ctx, "systemctl", fmt.Sprintf(`stop "%s.timer"`, state.Unit))
+		} else {
+			response = pe.channel.Run(ctx, "shutdown", "-c")
+		}
+		if !response.Success {
+			log.Errorf(ctx, "cancel the %s failed, %s", state.Mode, response.Err)
+			return response
+		}
+		status.Status = PowerCancelled
+	}
+	log.Infof(ctx, "the %s scheduled at %s is %s", state.Mode, status.Deadline, status.Status)
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(status)
+}
+
+// GetPowerStatus returns whether the scheduled reboot or shutdown of the experiment is pending or happened
+func GetPowerStatus(uid string) (*PowerStatus, error) {
+	state, err := readPowerState(getPowerStateFile(uid))
+	if err != nil {
+		return nil, err
+	}
+	status := getPowerStatus(uid, state)
+	return &status, nil
+}
+
+// getPowerStatus regards the operation as happened if the host has been booted again
+func getPowerStatus(uid string, state powerState) PowerStatus {
+	status := PowerStatus{Uid: uid, Mode: state.Mode, Deadline: state.Deadline.Format(time.RFC3339), Status: PowerPending}
+	if bootId, err := readBootId(); err == nil && bootId != state.BootId {
+		status.Status = PowerHappened
+	} else if time.Now().After(state.Deadline) {
+		status.Status = PowerExpired
+	}
+	return status
+}
+
+func readBootId() (string, error) {
+	data, err := os.ReadFile(bootIdFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func getPowerStateFile(uid string) string {
+	return path.Join(powerStateDir, fmt.Sprintf("chaos-host-power-%s.json", uid))
+}
+
+func writePowerState(stateFile string, state powerState) error {
+	if err := os.MkdirAll(path.Dir(stateFile), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readPowerState(stateFile string) (powerState, error) {
+	var state powerState
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+const (
+	// IptablesWaitSeconds is the time iptables waits for the xtables lock held by the others, such as kube-proxy
+	IptablesWaitSeconds = 5
+	// iptablesAttempts is the number of the runs of the iptables command failed by the lock or the transient error
+	iptablesAttempts = 5
+)
+
+// iptablesBackoff returns the jittered delay before the attempt, it's replaced by the tests
+var iptablesBackoff = func(attempt int) time.Duration {
+	backoff := 200 * time.Millisecond << attempt
+	if backoff > 2*time.Second {
+		backoff = 2 * time.Second
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)))
+}
+
+// iptablesTransientErrors are the errors of iptables which are retried, the other ones such as the bad rule
+// are returned at once
+var iptablesTransientErrors = []string{
+	"xtables lock",
+	"Resource temporarily unavailable",
+	// RESOURCE_PROBLEM, iptables exits with it if the lock is not acquired in the wait time
+	"exit status 4",
+}
+
+var iptablesWait = struct {
+	sync.Mutex
+	probed bool
+	args   []string
+}{}
+
+// iptablesWaitArgs returns the wait option supported by the installed iptables, which is probed once. The
+// iptables before 1.4.20 has no wait option, and the one before 1.6.0 waits without the timeout.
+func iptablesWaitArgs(ctx context.Context, cl spec.Channel) []string {
+	iptablesWait.Lock()
+	defer iptablesWait.Unlock()
+	if iptablesWait.probed {
+		return iptablesWait.args
+	}
+	response := RunReadOnly(ctx, cl, "iptables", "--help")
+	if !response.Success {
+		// the probe may fail because of the channel rather than iptables, so it's probed again next time
+		return nil
+	}
+	help := fmt.Sprint(response.Result)
+	switch {
+	case strings.Contains(help, "-w [seconds]"):
+		iptablesWait.args = []string{"-w", fmt.Sprint(IptablesWaitSeconds)}
+	case strings.Contains(help, "--wait"):
+		iptablesWait.args = []string{"-w"}
+	default:
+		iptablesWait.args = nil
+	}
+	iptablesWait.probed = true
+	return iptablesWait.args
+}
+
+// setIptablesWait caches the wait option as if it's probed, the nil one is the iptables without the option
+func setIptablesWait(args []string) {
+	iptablesWait.Lock()
+	defer iptablesWait.Unlock()
+	iptablesWait.probed = true
+	iptablesWait.args = args
+}
+
+// IsIptablesTransient returns true if the iptables command failed by the xtables lock or the transient error
+func IsIptablesTransient(response *spec.Response) bool {
+	if response == nil || response.Success {
+		return false
+	}
+	for _, transient := range iptablesTransientErrors {
+		if strings.Contains(response.Err, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// RunIptables runs the iptables command with the wait option, the command failed by the xtables lock is
+// retried with the jittered backoff. The failure of the lock is returned as LockContention after the
+// attempts, the other failures are returned at once as they are.
+func RunIptables(ctx context.Context, cl spec.Channel, args string) *spec.Response {
+	return retryIptables(ctx, func(wait []string) *spec.Response {
+		if len(wait) > 0 {
+			return cl.Run(ctx, "iptables", fmt.Sprintf("%s %s", strings.Join(wait, " "), args))
+		}
+		return cl.Run(ctx, "iptables", args)
+	}, iptablesWaitArgs(ctx, cl), args)
+}
+
+// RunIptablesArgv is RunIptables with the explicit arguments, see RunArgv
+func RunIptablesArgv(ctx context.Context, cl spec.Channel, args ...string) *spec.Response {
+	return retryIptables(ctx, func(wait []string) *spec.Response {
+		return RunArgv(ctx, cl, "iptables", append(append([]string{}, wait...), args...)...)
+	}, iptablesWaitArgs(ctx, cl), ShellJoin(args...))
+}
+
+func retryIptables(ctx context.Context, run func(wait []string) *spec.Response, wait []string, command string) *spec.Response {
+	var response *spec.Response
+	for attempt := 0; attempt < iptablesAttempts; attempt++ {
+		if attempt > 0 {
+			log.Warnf(ctx, "iptables %s failed by the lock, retry it, %s", command, response.Err)
+			select {
+			case <-ctx.Done():
+				return Fail(LockContention, "iptables", command, fmt.Sprintf("iptables %s is canceled, %s", command, response.Err))
+			case <-time.After(iptablesBackoff(attempt - 1)):
+			}
+		}
+		response = run(wait)
+		if !IsIptablesTransient(response) {
+			return response
+		}
+	}
+	return WithFailure(response, LockContention, "iptables", command)
+}
+
+// IptablesComment returns the comment of the iptables rules of the experiment, which finds them in the listing
+func IptablesComment(uid string) string {
+	return "chaosblade-" + uid
+}
+
+// RuleCounters are the packets and the bytes matched by the iptables rule
+type RuleCounters struct {
+	Chain    string `json:"chain"`
+	Target   string `json:"target"`
+	Protocol string `json:"protocol"`
+	Packets  uint64 `json:"packets"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+// IptablesCounters lists the chains with the exact counters, and returns the counters of the rules with the
+// comment. The iptables of the nft backend lists the rules in the same format.
+func IptablesCounters(ctx context.Context, cl spec.Channel, comment string, chains ...string) ([]RuleCounters, error) {
+	counters := make([]RuleCounters, 0)
+	for _, chain := range chains {
+		args := fmt.Sprintf("-nvxL %s", chain)
+		response := retryIptables(ctx, func(wait []string) *spec.Response {
+			if len(wait) > 0 {
+				return RunReadOnly(ctx, cl, "iptables", fmt.Sprintf("%s %s", strings.Join(wait, " "), args))
+			}
+			return RunReadOnly(ctx, cl, "iptables", args)
+		}, iptablesWaitArgs(ctx, cl), args)
+		if !response.Success {
+			return nil, fmt.Errorf("list the rules of %s failed, %s", chain, response.Err)
+		}
+		counters = append(counters, parseIptablesCounters(chain, fmt.Sprint(response.Result), comment)...)
+	}
+	return counters, nil
+}
+
+// parseIptablesCounters parses the output of iptables -nvxL, such as
+//
+//	Chain OUTPUT (policy ACCEPT 120 packets, 9600 bytes)
+//	    pkts      bytes target     prot opt in     out     source               destination
+//	      12      720 DROP       tcp  --  *      *       0.0.0.0/0            0.0.0.0/0            tcp dpt:80 /* chaosblade-uid */
+func parseIptablesCounters(chain, output, comment string) []RuleCounters {
+	counters := make([]RuleCounters, 0)
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "/* "+comment+" */") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		packets, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		bytes, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		counters = append(counters, RuleCounters{Chain: chain, Target: fields[2], Protocol: fields[3], Packets: packets, Bytes: bytes})
+	}
+	return counters
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+const xtablesLockErr = "Another app is currently holding the xtables lock. Perhaps you want to use the -w option?"
+
+func withoutIptablesBackoff(t *testing.T) {
+	backoff := iptablesBackoff
+	iptablesBackoff = func(int) time.Duration { return 0 }
+	t.Cleanup(func() { iptablesBackoff = backoff })
+}
+
+func TestIptablesWaitArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		help     string
+		expected []string
+	}{
+		{"timeout", "  --wait	-w [seconds]	maximum wait to acquire xtables lock before give up", []string{"-w", "5"}},
+		{"wait", "  --wait	-w	wait for the xtables lock", []string{"-w"}},
+		{"none", "  --verbose	-v		verbose mode", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := NewMockChannel().OnRun("iptables", "^--help$", spec.ReturnSuccess(tt.help))
+			iptablesWait.probed = false
+			if got := iptablesWaitArgs(context.Background(), cl); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+			// the probe is cached
+			iptablesWaitArgs(context.Background(), cl)
+			if lines := cl.CommandLines(); len(lines) != 1 {
+				t.Errorf("expected the probe once, got %v", lines)
+			}
+		})
+	}
+	setIptablesWait(nil)
+}
+
+func TestRunIptablesRetry(t *testing.T) {
+	withoutIptablesBackoff(t)
+	cl := NewMockChannel().OnRunTimes("iptables", "-A INPUT", 2, spec.ReturnFail(spec.OsCmdExecFailed, xtablesLockErr))
+	setIptablesWait([]string{"-w", "5"})
+	defer setIptablesWait(nil)
+	if response := RunIptablesArgv(context.Background(), cl, "-A", "INPUT", "-j", "DROP"); !response.Success {
+		t.Fatalf("expected the success after the lock is released, %s", response.Err)
+	}
+	expected := []string{"iptables -w 5 -A INPUT -j DROP", "iptables -w 5 -A INPUT -j DROP", "iptables -w 5 -A INPUT -j DROP"}
+	if got := cl.CommandLines(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected the commands %v, got %v", expected, got)
+	}
+}
+
+func TestRunIptablesLockContention(t *testing.T) {
+	withoutIptablesBackoff(t)
+	cl := NewMockChannel().OnRun("iptables", "", spec.ReturnFail(spec.OsCmdExecFailed, xtablesLockErr))
+	response := RunIptables(context.Background(), cl, "-D INPUT -j DROP")
+	if failure, ok := GetFailure(response); !ok || failure.Kind != LockContention.Name {
+		t.Errorf("expected the failure of the lock contention, got %+v", response)
+	}
+	if lines := cl.CommandLines(); len(lines) != iptablesAttempts {
+		t.Errorf("expected %d attempts, got %v", iptablesAttempts, lines)
+	}
+}
+
+func TestRunIptablesRuleError(t *testing.T) {
+	withoutIptablesBackoff(t)
+	cl := NewMockChannel().OnRun("iptables", "", spec.ReturnFail(spec.OsCmdExecFailed,
+		"iptables: Bad rule (does a matching rule exist in that chain?). exit status 1"))
+	response := RunIptables(context.Background(), cl, "-D INPUT -j DROP")
+	if response.Success || response.Result != nil {
+		t.Errorf("expected the rule error is returned as it is, got %+v", response)
+	}
+	if lines := cl.CommandLines(); len(lines) != 1 {
+		t.Errorf("expected the rule error isn't retried, got %v", lines)
+	}
+}
+
+func TestDestroyByStateIptablesLock(t *testing.T) {
+	withoutIptablesBackoff(t)
+	StateDir = t.TempDir()
+	ctx := context.Background()
+	state, response := NewExperimentState(ctx, "iptables-1", "network", "drop", nil)
+	if response != nil {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if err := state.AddUndo("iptables", "-D INPUT -j DROP"); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	cl := NewMockChannel().OnRunTimes("iptables", "", 1, spec.ReturnFail(spec.OsCmdExecFailed, xtablesLockErr))
+	if response, ok := DestroyByState(ctx, cl, "iptables-1"); !ok || !response.Success {
+		t.Fatalf("expected the undo is retried, got %+v", response)
+	}
+}
+
+func TestParseIptablesCounters(t *testing.T) {
+	output := `Chain OUTPUT (policy ACCEPT 120 packets, 9600 bytes)
+    pkts      bytes target     prot opt in     out     source               destination
+      12      720 DROP       tcp  --  *      *       0.0.0.0/0            0.0.0.0/0            tcp dpt:80 /* chaosblade-uid */
+       0        0 DROP       udp  --  *      *       0.0.0.0/0            0.0.0.0/0            udp dpt:80 /* chaosblade-uid */
+       5      300 DROP       tcp  --  *      *       0.0.0.0/0            0.0.0.0/0            tcp dpt:80 /* chaosblade-uid1 */
+`
+	expected := []RuleCounters{
+		{Chain: "OUTPUT", Target: "DROP", Protocol: "tcp", Packets: 12, Bytes: 720},
+		{Chain: "OUTPUT", Target: "DROP", Protocol: "udp"},
+	}
+	if got := parseIptablesCounters("OUTPUT", output, IptablesComment("uid")); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kernel
+
+import (
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+type KernelCommandSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewKernelCommandSpec() spec.ExpModelCommandSpec {
+	return &KernelCommandSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				NewSysctlActionSpec(),
+				NewLogFloodActionSpec(),
+				NewSwapActionSpec(),
+				NewSelinuxActionSpec(),
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*KernelCommandSpec) Name() string {
+	return "kernel"
+}
+
+func (*KernelCommandSpec) ShortDesc() string {
+	return "Kernel experiment"
+}
+
+func (*KernelCommandSpec) LongDesc() string {
+	return "Kernel experiment, for example, change the kernel parameters or the SELinux mode, flood the kernel log or disable swap"
+}
@@ -47,10 +47,10 @@ func NewStraceDelayActionSpec() spec.ExpActionCommandSpec {
 				},
 				&spec.ExpFlag{
 					Name:     "cgroup-root",
-					Desc:     "cgroup root path, default value /sys/fs/cgroup",
+					Desc:     "cgroup root path, it's detected from the cgroup mounts if absent",
 					NoArgs:   false,
 					Required: false,
-					Default:  "/sys/fs/cgroup",
+					Default:  "",
 				},
 			},
 			ActionFlags: []spec.ExpFlagSpec{
@@ -201,5 +201,5 @@ func (dae *StraceDelayActionExecutor) start(ctx context.Context, pidList string,
 
 func (dae *StraceDelayActionExecutor) stop(ctx context.Context, pidList string, syscallName string) *spec.Response {
 	ctx = context.WithValue(ctx, "bin", StraceDelayBin)
-	return exec.Destroy(ctx, dae.channel, "strace delay")
+	return exec.Destroy(ctx, dae.channel, "kernel delay")
 }
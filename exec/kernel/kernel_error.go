@@ -47,10 +47,10 @@ func NewStraceErrorActionSpec() spec.ExpActionCommandSpec {
 				},
 				&spec.ExpFlag{
 					Name:     "cgroup-root",
-					Desc:     "cgroup root path, default value /sys/fs/cgroup",
+					Desc:     "cgroup root path, it's detected from the cgroup mounts if absent",
 					NoArgs:   false,
 					Required: false,
-					Default:  "/sys/fs/cgroup",
+					Default:  "",
 				},
 			},
 			ActionFlags: []spec.ExpFlagSpec{
@@ -183,5 +183,5 @@ func (dae *StraceErrorActionExecutor) start(ctx context.Context, pidList string,
 }
 
 func (dae *StraceErrorActionExecutor) stop(ctx context.Context, pidList string, syscallName string) *spec.Response {
-	return exec.Destroy(ctx, dae.channel, "strace delay")
+	return exec.Destroy(ctx, dae.channel, "kernel error")
 }
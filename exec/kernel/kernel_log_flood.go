@@ -0,0 +1,316 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kernel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const LogFloodBin = "chaos_logflood"
+
+const (
+	LogFloodToKmsg   = "kmsg"
+	LogFloodToSyslog = "syslog"
+)
+
+const (
+	kmsgDevice        = "/dev/kmsg"
+	printkDevkmsgFile = "/proc/sys/kernel/printk_devkmsg"
+	// the interval of emitting the messages and recording the count
+	logFloodTick = 100 * time.Millisecond
+)
+
+const logFloodWarning = "the flooded messages may overwrite the kernel ring buffer and rotate the log files, " +
+	"the overwritten messages are lost and nothing is restored when the experiment is destroyed"
+
+// the priorities of syslog(3), which are also the log levels of /dev/kmsg
+var logPriorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+type LogFloodActionSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewLogFloodActionSpec() spec.ExpActionCommandSpec {
+	return &LogFloodActionSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:    "target",
+					Desc:    "Where the messages are written, kmsg or syslog, default value is kmsg",
+					Default: LogFloodToKmsg,
+				},
+				&spec.ExpFlag{
+					Name:    "priority",
+					Desc:    "The priority of the messages, emerg, alert, crit, err, warning, notice, info, debug or 0-7, default value is info",
+					Default: "info",
+				},
+				&spec.ExpFlag{
+					Name:    "rate",
+					Desc:    "The number of the messages per second, default value is 100",
+					Default: "100",
+				},
+				&spec.ExpFlag{
+					Name: "max-total",
+					Desc: "The max number of the messages, the flood stops when it is reached, default value is 0 which means no limit",
+				},
+				&spec.ExpFlag{
+					Name: "message",
+					Desc: "The content of the messages, which is tagged with the experiment uid",
+				},
+			},
+			ActionExecutor: &LogFloodActionExecutor{},
+			ActionExample: `
+# Write 1000 messages per second to the kernel ring buffer
+blade create kernel log-flood --rate 1000
+
+# Write at most 100000 error messages to syslog, 5000 per second
+blade create kernel log-flood --target syslog --priority err --rate 5000 --max-total 100000 --message "disk error"`,
+			ActionPrograms:    []string{LogFloodBin},
+			ActionCategories:  []string{category.SystemKernel},
+			ActionProcessHang: true,
+		},
+	}
+}
+
+func (*LogFloodActionSpec) Name() string {
+	return "log-flood"
+}
+
+func (*LogFloodActionSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*LogFloodActionSpec) ShortDesc() string {
+	return "Flood kernel log or syslog"
+}
+
+func (f *LogFloodActionSpec) LongDesc() string {
+	if f.ActionLongDesc != "" {
+		return f.ActionLongDesc
+	}
+	return "Write the messages tagged with the experiment uid to /dev/kmsg or syslog at the rate until the experiment is destroyed " +
+		"or the max total is reached, the number of the messages is returned when destroyed. " +
+		"Nothing is restored, the messages may overwrite the kernel ring buffer and rotate the log files. " +
+		"The messages written to /dev/kmsg are rate limited by the kernel unless kernel.printk_devkmsg is on."
+}
+
+type LogFloodActionExecutor struct {
+	channel spec.Channel
+}
+
+func (lfe *LogFloodActionExecutor) SetChannel(channel spec.Channel) {
+	lfe.channel = channel
+}
+
+func (*LogFloodActionExecutor) Name() string {
+	return "log-flood"
+}
+
+// logFloodState is the number of the messages written, which is recorded by the flood process
+type logFloodState struct {
+	Target   string `json:"target"`
+	Priority string `json:"priority"`
+	Count    int64  `json:"count"`
+}
+
+// logFloodResult is the response of the log flood, nothing can be restored so that the warning is always returned
+type logFloodResult struct {
+	Uid     string `json:"uid"`
+	Target  string `json:"target"`
+	Count   int64  `json:"count"`
+	Warning string `json:"warning"`
+}
+
+func (lfe *LogFloodActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return lfe.stop(ctx, uid)
+	}
+	if !logFloodSupported {
+		return exec.Fail(exec.UnsupportedPlatform, "kernel", "log-flood", "the kernel log flood is not supported on Windows, there is no /dev/kmsg or syslog")
+	}
+	target := model.ActionFlags["target"]
+	if target == "" {
+		target = LogFloodToKmsg
+	}
+	if target != LogFloodToKmsg && target != LogFloodToSyslog {
+		log.Errorf(ctx, "`%s`: target is illegal", target)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "target", target, "it must be kmsg or syslog")
+	}
+	priorityStr := model.ActionFlags["priority"]
+	if priorityStr == "" {
+		priorityStr = "info"
+	}
+	priority, err := parseLogPriority(priorityStr)
+	if err != nil {
+		log.Errorf(ctx, "`%s`: priority is illegal, %v", priorityStr, err)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "priority", priorityStr, err)
+	}
+	rateStr := model.ActionFlags["rate"]
+	if rateStr == "" {
+		rateStr = "100"
+	}
+	rate, err := strconv.Atoi(rateStr)
+	if err != nil || rate < 1 {
+		log.Errorf(ctx, "`%s`: rate is illegal", rateStr)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "rate", rateStr, "it must be a positive integer")
+	}
+	var maxTotal int64
+	if maxTotalStr := model.ActionFlags["max-total"]; maxTotalStr != "" {
+		maxTotal, err = strconv.ParseInt(maxTotalStr, 10, 64)
+		if err != nil || maxTotal < 0 {
+			log.Errorf(ctx, "`%s`: max-total is illegal", maxTotalStr)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "max-total", maxTotalStr, "it must be a non-negative integer")
+		}
+	}
+	message := model.ActionFlags["message"]
+	if message == "" {
+		message = "log flood"
+	}
+	if strings.ContainsAny(message, "\r\n") {
+		log.Errorf(ctx, "`%s`: message must be a single line", message)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "message", message, "it must be a single line")
+	}
+	return lfe.start(ctx, uid, target, priority, rate, maxTotal, message)
+}
+
+func (lfe *LogFloodActionExecutor) start(ctx context.Context, uid, target string, priority, rate int, maxTotal int64,
+	message string) *spec.Response {
+	stateFile := getLogFloodStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the log flood state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	writeLog, closeLog, err := openLogWriter(uid, target, priority)
+	if err != nil {
+		log.Errorf(ctx, "open %s failed, %v", target, err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "open "+target, err)
+	}
+	defer closeLog()
+	if target == LogFloodToKmsg {
+		if data, err := os.ReadFile(printkDevkmsgFile); err == nil && strings.TrimSpace(string(data)) == "ratelimit" {
+			log.Warnf(ctx, "kernel.printk_devkmsg is ratelimit, most of the messages written to %s are dropped by the kernel", kmsgDevice)
+		}
+	}
+	log.Warnf(ctx, "%s", logFloodWarning)
+
+	state := logFloodState{Target: target, Priority: logPriorities[priority]}
+	if err := writeLogFloodState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write the log flood state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writeLogFloodState", err)
+	}
+	prefix := fmt.Sprintf("chaosblade-%s: %s", uid, message)
+	startTime := time.Now()
+	ticker := time.NewTicker(logFloodTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// the expected count is calculated by the elapsed time, so that the rate is kept even if some ticks are delayed
+			expected := int64(time.Since(startTime).Seconds() * float64(rate))
+			if maxTotal > 0 && expected > maxTotal {
+				expected = maxTotal
+			}
+			for state.Count < expected {
+				if err := writeLog(fmt.Sprintf("%s #%d", prefix, state.Count+1)); err != nil {
+					log.Warnf(ctx, "write the message to %s failed, %v", target, err)
+					break
+				}
+				state.Count++
+			}
+			if err := writeLogFloodState(stateFile, state); err != nil {
+				log.Warnf(ctx, "write the log flood state failed, %v", err)
+			}
+			if maxTotal > 0 && state.Count >= maxTotal {
+				log.Infof(ctx, "the max total %d is reached, stop the log flood", maxTotal)
+				return spec.ReturnSuccess(logFloodResult{Uid: uid, Target: target, Count: state.Count, Warning: logFloodWarning})
+			}
+		case <-ctx.Done():
+			return spec.ReturnSuccess(logFloodResult{Uid: uid, Target: target, Count: state.Count, Warning: logFloodWarning})
+		}
+	}
+}
+
+func (lfe *LogFloodActionExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	ctx = context.WithValue(ctx, "bin", LogFloodBin)
+	if response := exec.Destroy(ctx, lfe.channel, "kernel log-flood"); !response.Success {
+		return response
+	}
+	stateFile := getLogFloodStateFile(uid)
+	state, err := readLogFloodState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warnf(ctx, "`%s`: the log flood state file not found, the experiment may be destroyed", stateFile)
+			return spec.ReturnSuccess(uid)
+		}
+		log.Errorf(ctx, "read the log flood state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "readLogFloodState", err)
+	}
+	os.Remove(stateFile)
+	log.Infof(ctx, "%d messages are written to %s", state.Count, state.Target)
+	return spec.ReturnSuccess(logFloodResult{Uid: uid, Target: state.Target, Count: state.Count, Warning: logFloodWarning})
+}
+
+// parseLogPriority parses the priority name or the number 0-7
+func parseLogPriority(priorityStr string) (int, error) {
+	if priority, err := strconv.Atoi(priorityStr); err == nil {
+		if priority < 0 || priority >= len(logPriorities) {
+			return 0, fmt.Errorf("it must be in 0-7")
+		}
+		return priority, nil
+	}
+	for priority, name := range logPriorities {
+		if strings.EqualFold(priorityStr, name) {
+			return priority, nil
+		}
+	}
+	return 0, fmt.Errorf("it must be one of %s or 0-7", strings.Join(logPriorities, ","))
+}
+
+func getLogFloodStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-kernel-log-flood-%s.json", uid)
+}
+
+func writeLogFloodState(stateFile string, state logFloodState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readLogFloodState(stateFile string) (logFloodState, error) {
+	var state logFloodState
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
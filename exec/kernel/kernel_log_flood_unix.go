@@ -0,0 +1,51 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kernel
+
+import (
+	"fmt"
+	"log/syslog"
+	"os"
+)
+
+const logFloodSupported = true
+
+// openLogWriter returns the function which writes a message to /dev/kmsg or syslog with the priority
+func openLogWriter(uid, target string, priority int) (func(string) error, func() error, error) {
+	if target == LogFloodToSyslog {
+		writer, err := syslog.New(syslog.LOG_USER|syslog.Priority(priority), "chaosblade-"+uid)
+		if err != nil {
+			return nil, nil, err
+		}
+		return func(message string) error {
+			_, err := writer.Write([]byte(message))
+			return err
+		}, writer.Close, nil
+	}
+	kmsg, err := os.OpenFile(kmsgDevice, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	// every write is a record of /dev/kmsg, the prefix is the user facility with the log level
+	header := fmt.Sprintf("<%d>", int(syslog.LOG_USER)|priority)
+	return func(message string) error {
+		_, err := kmsg.WriteString(header + message + "\n")
+		return err
+	}, kmsg.Close, nil
+}
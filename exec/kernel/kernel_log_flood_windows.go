@@ -0,0 +1,28 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kernel
+
+import (
+	"errors"
+)
+
+// logFloodSupported is false, there is no /dev/kmsg or syslog on Windows
+const logFloodSupported = false
+
+func openLogWriter(uid, target string, priority int) (func(string) error, func() error, error) {
+	return nil, nil, errors.New("the kernel log flood is not supported on Windows")
+}
@@ -0,0 +1,301 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kernel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const (
+	SelinuxEnforcing  = "enforcing"
+	SelinuxPermissive = "permissive"
+	SelinuxDisabled   = "disabled"
+)
+
+var selinuxBooleanRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+type SelinuxActionSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewSelinuxActionSpec() spec.ExpActionCommandSpec {
+	return &SelinuxActionSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "mode",
+					Desc: "The SELinux mode, enforcing or permissive",
+				},
+				&spec.ExpFlag{
+					Name: "boolean",
+					Desc: "The SELinux booleans, for example: httpd_can_network_connect=off,ftpd_full_access=on",
+				},
+			},
+			ActionExecutor: &SelinuxActionExecutor{},
+			ActionExample: `
+# Switch SELinux to the enforcing mode
+blade create kernel selinux --mode enforcing
+
+# Forbid httpd to connect the network
+blade create kernel selinux --boolean httpd_can_network_connect=off`,
+			ActionCategories: []string{category.SystemKernel},
+		},
+	}
+}
+
+func (*SelinuxActionSpec) Name() string {
+	return "selinux"
+}
+
+func (*SelinuxActionSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*SelinuxActionSpec) ShortDesc() string {
+	return "Change SELinux mode or booleans"
+}
+
+func (f *SelinuxActionSpec) LongDesc() string {
+	if f.ActionLongDesc != "" {
+		return f.ActionLongDesc
+	}
+	return "Switch the SELinux mode by setenforce or change the SELinux booleans by setsebool until reboot, " +
+		"the original mode and booleans are restored when the experiment is destroyed. It fails if SELinux is disabled at boot"
+}
+
+type SelinuxActionExecutor struct {
+	channel spec.Channel
+}
+
+func (sae *SelinuxActionExecutor) SetChannel(channel spec.Channel) {
+	sae.channel = channel
+}
+
+func (*SelinuxActionExecutor) Name() string {
+	return "selinux"
+}
+
+// selinuxBoolean is a SELinux boolean, the value is on or off
+type selinuxBoolean struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// selinuxState is the original mode and booleans, the mode is empty if it is not changed
+type selinuxState struct {
+	Mode     string           `json:"mode,omitempty"`
+	Booleans []selinuxBoolean `json:"booleans,omitempty"`
+}
+
+func (sae *SelinuxActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if response, ok := sae.channel.IsAllCommandsAvailable(ctx, []string{"getenforce", "setenforce"}); !ok {
+		return response
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return sae.stop(ctx, uid)
+	}
+	mode := strings.ToLower(model.ActionFlags["mode"])
+	booleanStr := model.ActionFlags["boolean"]
+	if mode == "" && booleanStr == "" {
+		log.Errorf(ctx, "less mode and boolean")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "mode|boolean")
+	}
+	if mode != "" && mode != SelinuxEnforcing && mode != SelinuxPermissive {
+		log.Errorf(ctx, "`%s`: mode is illegal", mode)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "mode", mode, "it must be enforcing or permissive")
+	}
+	booleans, err := parseSelinuxBooleans(booleanStr)
+	if err != nil {
+		log.Errorf(ctx, "`%s`: boolean is illegal, %v", booleanStr, err)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "boolean", booleanStr, err)
+	}
+	if len(booleans) > 0 {
+		if response, ok := sae.channel.IsAllCommandsAvailable(ctx, []string{"getsebool", "setsebool"}); !ok {
+			return response
+		}
+	}
+	current, response := sae.getenforce(ctx)
+	if response != nil {
+		return response
+	}
+	if current == SelinuxDisabled {
+		log.Errorf(ctx, "SELinux is disabled at boot, it cannot be changed at runtime")
+		return spec.ReturnFail(spec.OsCmdExecFailed, "SELinux is disabled at boot, it cannot be enabled without changing the kernel parameters and rebooting")
+	}
+	return sae.start(ctx, uid, current, mode, booleans)
+}
+
+func (sae *SelinuxActionExecutor) start(ctx context.Context, uid, current, mode string, booleans []selinuxBoolean) *spec.Response {
+	stateFile := getSelinuxStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the selinux state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	// all the original values are recorded before any change, so that all of them are restored even if only some are applied
+	var state selinuxState
+	if mode != "" {
+		state.Mode = current
+	}
+	for _, boolean := range booleans {
+		value, response := sae.getsebool(ctx, boolean.Name)
+		if response != nil {
+			return response
+		}
+		state.Booleans = append(state.Booleans, selinuxBoolean{Name: boolean.Name, Value: value})
+	}
+	if err := writeSelinuxState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write the selinux state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writeSelinuxState", err)
+	}
+	if mode != "" {
+		log.Infof(ctx, "switch SELinux from %s to %s", current, mode)
+		if response := sae.channel.Run(ctx, "setenforce", mode); !response.Success {
+			sae.stop(ctx, uid)
+			return response
+		}
+	}
+	for _, boolean := range booleans {
+		log.Infof(ctx, "set the SELinux boolean %s to %s", boolean.Name, boolean.Value)
+		if response := sae.channel.Run(ctx, "setsebool", fmt.Sprintf("%s %s", boolean.Name, boolean.Value)); !response.Success {
+			sae.stop(ctx, uid)
+			return response
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (sae *SelinuxActionExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	stateFile := getSelinuxStateFile(uid)
+	state, err := readSelinuxState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warnf(ctx, "`%s`: the selinux state file not found, the experiment may be destroyed", stateFile)
+			return spec.ReturnSuccess(uid)
+		}
+		log.Errorf(ctx, "read the selinux state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "readSelinuxState", err)
+	}
+	failed := make([]string, 0)
+	if state.Mode != "" {
+		if response := sae.channel.Run(ctx, "setenforce", state.Mode); !response.Success {
+			log.Errorf(ctx, "restore the SELinux mode to %s failed, %s", state.Mode, response.Err)
+			failed = append(failed, "mode")
+		}
+	}
+	for _, boolean := range state.Booleans {
+		if response := sae.channel.Run(ctx, "setsebool", fmt.Sprintf("%s %s", boolean.Name, boolean.Value)); !response.Success {
+			log.Errorf(ctx, "restore the SELinux boolean %s to %s failed, %s", boolean.Name, boolean.Value, response.Err)
+			failed = append(failed, boolean.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("restore the SELinux %s failed", strings.Join(failed, ",")))
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+// getenforce returns the current mode in lower case, enforcing, permissive or disabled
+func (sae *SelinuxActionExecutor) getenforce(ctx context.Context) (string, *spec.Response) {
+	response := sae.channel.Run(ctx, "getenforce", "")
+	if !response.Success {
+		log.Errorf(ctx, "get the SELinux mode failed, %s", response.Err)
+		return "", response
+	}
+	return strings.ToLower(strings.TrimSpace(response.Result.(string))), nil
+}
+
+// getsebool returns the current value of the boolean, the output is like `name --> on`
+func (sae *SelinuxActionExecutor) getsebool(ctx context.Context, name string) (string, *spec.Response) {
+	response := sae.channel.Run(ctx, "getsebool", name)
+	if !response.Success {
+		log.Errorf(ctx, "get the SELinux boolean %s failed, %s", name, response.Err)
+		return "", spec.ResponseFailWithFlags(spec.ParameterInvalid, "boolean", name, response.Err)
+	}
+	output := strings.TrimSpace(response.Result.(string))
+	index := strings.LastIndex(output, "-->")
+	if index < 0 {
+		return "", spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("unexpected getsebool output: %s", output))
+	}
+	return strings.TrimSpace(output[index+len("-->"):]), nil
+}
+
+// parseSelinuxBooleans parses name=on[,name2=off], the value 1/true and 0/false are accepted too
+func parseSelinuxBooleans(booleanStr string) ([]selinuxBoolean, error) {
+	booleans := make([]selinuxBoolean, 0)
+	names := make(map[string]bool)
+	for _, item := range strings.Split(booleanStr, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s must be name=on|off", item)
+		}
+		name, value = strings.TrimSpace(name), strings.ToLower(strings.TrimSpace(value))
+		if !selinuxBooleanRegexp.MatchString(name) {
+			return nil, fmt.Errorf("%s is not a valid boolean name", name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("%s is duplicated", name)
+		}
+		switch value {
+		case "on", "1", "true":
+			value = "on"
+		case "off", "0", "false":
+			value = "off"
+		default:
+			return nil, fmt.Errorf("the value of %s must be on or off", name)
+		}
+		names[name] = true
+		booleans = append(booleans, selinuxBoolean{Name: name, Value: value})
+	}
+	return booleans, nil
+}
+
+func getSelinuxStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-kernel-selinux-%s.json", uid)
+}
+
+func writeSelinuxState(stateFile string, state selinuxState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readSelinuxState(stateFile string) (selinuxState, error) {
+	var state selinuxState
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
@@ -0,0 +1,325 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kernel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const (
+	procSwapsFile   = "/proc/swaps"
+	procMeminfoFile = "/proc/meminfo"
+)
+
+type SwapActionSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewSwapActionSpec() spec.ExpActionCommandSpec {
+	return &SwapActionSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "device",
+					Desc: "The swap device or file in /proc/swaps, all the active swap areas are disabled if it is absent",
+				},
+				&spec.ExpFlag{
+					Name:   "force",
+					Desc:   "Disable the swap even if the used swap is more than the available memory",
+					NoArgs: true,
+				},
+			},
+			ActionExecutor: &SwapActionExecutor{},
+			ActionExample: `
+# Disable all the active swap areas
+blade create kernel swap
+
+# Disable the swap file /swapfile
+blade create kernel swap --device /swapfile`,
+			ActionCategories: []string{category.SystemKernel},
+		},
+	}
+}
+
+func (*SwapActionSpec) Name() string {
+	return "swap"
+}
+
+func (*SwapActionSpec) Aliases() []string {
+	return []string{"swapoff"}
+}
+
+func (*SwapActionSpec) ShortDesc() string {
+	return "Disable swap"
+}
+
+func (f *SwapActionSpec) LongDesc() string {
+	if f.ActionLongDesc != "" {
+		return f.ActionLongDesc
+	}
+	return "Disable the active swap areas by swapoff, they are enabled with the original priorities when the experiment is destroyed. " +
+		"The used swap is moved back into the memory, so that it fails if the used swap is more than the available memory unless force is set. " +
+		"The negative priorities are assigned by the kernel, the swap areas are enabled in the original order to keep them"
+}
+
+type SwapActionExecutor struct {
+	channel spec.Channel
+}
+
+func (sae *SwapActionExecutor) SetChannel(channel spec.Channel) {
+	sae.channel = channel
+}
+
+func (*SwapActionExecutor) Name() string {
+	return "swap"
+}
+
+// swapArea is an active swap area in /proc/swaps, the size is in KB
+type swapArea struct {
+	Filename string `json:"filename"`
+	Type     string `json:"type"`
+	Size     int64  `json:"size"`
+	Used     int64  `json:"used"`
+	Priority int    `json:"priority"`
+}
+
+func (sae *SwapActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if response, ok := sae.channel.IsAllCommandsAvailable(ctx, []string{"swapoff", "swapon"}); !ok {
+		return response
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return sae.stop(ctx, uid)
+	}
+	areas, err := readSwapAreas()
+	if err != nil {
+		log.Errorf(ctx, "read %s failed, %v", procSwapsFile, err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "read "+procSwapsFile, err)
+	}
+	device := model.ActionFlags["device"]
+	if device != "" {
+		selected := make([]swapArea, 0, 1)
+		for _, area := range areas {
+			if area.Filename == device {
+				selected = append(selected, area)
+			}
+		}
+		if len(selected) == 0 {
+			log.Errorf(ctx, "`%s`: the swap area is not active", device)
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "device", device, "it is not an active swap area")
+		}
+		areas = selected
+	}
+	if len(areas) == 0 {
+		log.Errorf(ctx, "no active swap area found")
+		return spec.ReturnFail(spec.OsCmdExecFailed, "no active swap area found")
+	}
+	if model.ActionFlags["force"] != "true" {
+		if response := checkSwapUsed(ctx, areas); response != nil {
+			return response
+		}
+	}
+	return sae.start(ctx, uid, areas)
+}
+
+func (sae *SwapActionExecutor) start(ctx context.Context, uid string, areas []swapArea) *spec.Response {
+	stateFile := getSwapStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the swap state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	if err := writeSwapState(stateFile, areas); err != nil {
+		log.Errorf(ctx, "write the swap state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writeSwapState", err)
+	}
+	for i, area := range areas {
+		log.Infof(ctx, "disable the swap area %s, %d KB is used", area.Filename, area.Used)
+		response := sae.channel.Run(ctx, "swapoff", fmt.Sprintf(`"%s"`, area.Filename))
+		if !response.Success {
+			log.Errorf(ctx, "disable the swap area %s failed, %s", area.Filename, response.Err)
+			if err := sae.enableSwapAreas(ctx, areas[:i]); err == nil {
+				os.Remove(stateFile)
+			}
+			return response
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (sae *SwapActionExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	stateFile := getSwapStateFile(uid)
+	areas, err := readSwapState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warnf(ctx, "`%s`: the swap state file not found, the experiment may be destroyed", stateFile)
+			return spec.ReturnSuccess(uid)
+		}
+		log.Errorf(ctx, "read the swap state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "readSwapState", err)
+	}
+	if err := sae.enableSwapAreas(ctx, areas); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "enableSwapAreas", err)
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+// enableSwapAreas enables the swap areas which are not active in order, the failed ones are returned together
+func (sae *SwapActionExecutor) enableSwapAreas(ctx context.Context, areas []swapArea) error {
+	actives, err := readSwapAreas()
+	if err != nil {
+		return err
+	}
+	active := make(map[string]bool, len(actives))
+	for _, area := range actives {
+		active[area.Filename] = true
+	}
+	failed := make([]string, 0)
+	for _, area := range areas {
+		if active[area.Filename] {
+			continue
+		}
+		// the negative priority can only be assigned by the kernel
+		args := fmt.Sprintf(`"%s"`, area.Filename)
+		if area.Priority >= 0 {
+			args = fmt.Sprintf(`-p %d "%s"`, area.Priority, area.Filename)
+		}
+		if response := sae.channel.Run(ctx, "swapon", args); !response.Success {
+			log.Errorf(ctx, "enable the swap area %s failed, %s", area.Filename, response.Err)
+			failed = append(failed, area.Filename)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("enable the swap areas %s failed", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+// checkSwapUsed checks that the used swap can be moved back into the available memory
+func checkSwapUsed(ctx context.Context, areas []swapArea) *spec.Response {
+	var used int64
+	for _, area := range areas {
+		used += area.Used
+	}
+	available, err := readMemAvailable()
+	if err != nil {
+		log.Errorf(ctx, "read the available memory failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "read "+procMeminfoFile, err)
+	}
+	if used > available {
+		message := fmt.Sprintf("the used swap %d KB is more than the available memory %d KB, add the force flag to disable it", used, available)
+		log.Errorf(ctx, "%s", message)
+		return spec.ReturnFail(spec.OsCmdExecFailed, message)
+	}
+	return nil
+}
+
+// readSwapAreas returns the active swap areas in /proc/swaps, the space in the file name is escaped as \040
+func readSwapAreas() ([]swapArea, error) {
+	file, err := os.Open(procSwapsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	areas := make([]swapArea, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] == "Filename" {
+			continue
+		}
+		area := swapArea{Filename: unescapeOctal(fields[0]), Type: fields[1]}
+		if area.Size, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+			return nil, err
+		}
+		if area.Used, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+			return nil, err
+		}
+		if area.Priority, err = strconv.Atoi(fields[4]); err != nil {
+			return nil, err
+		}
+		areas = append(areas, area)
+	}
+	return areas, scanner.Err()
+}
+
+// readMemAvailable returns MemAvailable in /proc/meminfo in KB
+func readMemAvailable() (int64, error) {
+	data, err := os.ReadFile(procMeminfoFile)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("MemAvailable not found")
+}
+
+// unescapeOctal converts the octal escapes, such as \040, of the kernel to the characters
+func unescapeOctal(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func getSwapStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-kernel-swap-%s.json", uid)
+}
+
+func writeSwapState(stateFile string, areas []swapArea) error {
+	data, err := json.Marshal(areas)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readSwapState(stateFile string) ([]swapArea, error) {
+	var areas []swapArea
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &areas)
+	return areas, err
+}
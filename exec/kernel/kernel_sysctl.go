@@ -0,0 +1,323 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kernel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const procSysDir = "/proc/sys"
+
+// the parameters which may crash or hang the host, changing them requires the force flag
+var sysctlDenyList = []string{
+	"kernel.panic*",
+	"vm.panic_on_oom",
+}
+
+type SysctlActionSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewSysctlActionSpec() spec.ExpActionCommandSpec {
+	return &SysctlActionSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     "parameters",
+					Desc:     "Kernel parameters, for example: net.core.somaxconn=16,net.ipv4.tcp_tw_reuse=0",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name:   "force",
+					Desc:   "Change the dangerous parameters, such as kernel.panic* and vm.panic_on_oom",
+					NoArgs: true,
+				},
+			},
+			ActionExecutor: &SysctlActionExecutor{},
+			ActionExample: `
+# Set the listen backlog limit to 16
+blade create kernel sysctl --parameters net.core.somaxconn=16
+
+# Disable tcp_tw_reuse and set the max open files of the system to 65536
+blade create kernel sysctl --parameters net.ipv4.tcp_tw_reuse=0,fs.file-max=65536
+
+# Panic when out of memory, which requires the force flag
+blade create kernel sysctl --parameters vm.panic_on_oom=1 --force`,
+			ActionCategories: []string{category.SystemKernel},
+		},
+	}
+}
+
+func (*SysctlActionSpec) Name() string {
+	return "sysctl"
+}
+
+func (*SysctlActionSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*SysctlActionSpec) ShortDesc() string {
+	return "Change kernel parameters"
+}
+
+func (f *SysctlActionSpec) LongDesc() string {
+	if f.ActionLongDesc != "" {
+		return f.ActionLongDesc
+	}
+	return "Change the kernel parameters under /proc/sys, the original values are restored when the experiment is destroyed"
+}
+
+type SysctlActionExecutor struct {
+	channel spec.Channel
+}
+
+func (sae *SysctlActionExecutor) SetChannel(channel spec.Channel) {
+	sae.channel = channel
+}
+
+func (*SysctlActionExecutor) Name() string {
+	return "sysctl"
+}
+
+// SysctlParameter is a kernel parameter, the value is the original one in the state file
+type SysctlParameter struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (sae *SysctlActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return sae.stop(ctx, uid)
+	}
+	parametersStr := model.ActionFlags["parameters"]
+	if parametersStr == "" {
+		log.Errorf(ctx, "parameters is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "parameters")
+	}
+	parameters, err := parseSysctlParameters(parametersStr)
+	if err != nil {
+		log.Errorf(ctx, "`%s`: parameters is illegal, %v", parametersStr, err)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "parameters", parametersStr, err)
+	}
+	force := model.ActionFlags["force"] == "true"
+	for _, parameter := range parameters {
+		if err := checkSysctlKey(parameter.Key, force); err != nil {
+			log.Errorf(ctx, "`%s`: the parameter is invalid, %v", parameter.Key, err)
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "parameters", parameter.Key, err)
+		}
+	}
+	return sae.start(ctx, uid, parameters)
+}
+
+func (sae *SysctlActionExecutor) start(ctx context.Context, uid string, parameters []SysctlParameter) *spec.Response {
+	if response := SetSysctl(ctx, getSysctlStateFile(uid), parameters); response != nil {
+		return response
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (sae *SysctlActionExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	if response := RestoreSysctl(ctx, getSysctlStateFile(uid)); response != nil {
+		return response
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+// SetSysctl records the original values of the parameters in the state file and then changes them,
+// the changed ones are restored if any write fails. It returns nil on success
+func SetSysctl(ctx context.Context, stateFile string, parameters []SysctlParameter) *spec.Response {
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the sysctl state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	// all the original values are recorded before any write, so that all of them are restored even if only some are applied
+	originals := make([]SysctlParameter, 0, len(parameters))
+	for _, parameter := range parameters {
+		value, err := readSysctl(parameter.Key)
+		if err != nil {
+			log.Errorf(ctx, "read the parameter %s failed, %v", parameter.Key, err)
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "read "+parameter.Key, err)
+		}
+		originals = append(originals, SysctlParameter{Key: parameter.Key, Value: value})
+	}
+	if err := writeSysctlState(stateFile, originals); err != nil {
+		log.Errorf(ctx, "write the sysctl state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writeSysctlState", err)
+	}
+	for _, parameter := range parameters {
+		log.Infof(ctx, "set the parameter %s to %s", parameter.Key, parameter.Value)
+		if err := writeSysctl(parameter.Key, parameter.Value); err != nil {
+			log.Errorf(ctx, "write the parameter %s failed, %v", parameter.Key, err)
+			if restoreSysctl(ctx, originals) == nil {
+				os.Remove(stateFile)
+			}
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "write "+parameter.Key, err)
+		}
+	}
+	return nil
+}
+
+// RestoreSysctl restores the original values recorded in the state file and removes it,
+// it returns nil if the state file is not found, which means the parameters are restored
+func RestoreSysctl(ctx context.Context, stateFile string) *spec.Response {
+	originals, err := readSysctlState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warnf(ctx, "`%s`: the sysctl state file not found, the experiment may be destroyed", stateFile)
+			return nil
+		}
+		log.Errorf(ctx, "read the sysctl state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "readSysctlState", err)
+	}
+	if err := restoreSysctl(ctx, originals); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "restoreSysctl", err)
+	}
+	os.Remove(stateFile)
+	return nil
+}
+
+// restoreSysctl writes all the original values, the failed ones are returned together
+func restoreSysctl(ctx context.Context, originals []SysctlParameter) error {
+	failed := make([]string, 0)
+	for _, original := range originals {
+		if err := writeSysctl(original.Key, original.Value); err != nil {
+			log.Errorf(ctx, "restore the parameter %s to %s failed, %v", original.Key, original.Value, err)
+			failed = append(failed, original.Key)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("restore the parameters %s failed", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+// parseSysctlParameters parses key=value[,key2=value2], the key is kept in the form it was given, see getSysctlFile
+func parseSysctlParameters(parametersStr string) ([]SysctlParameter, error) {
+	parameters := make([]SysctlParameter, 0)
+	keys := make(map[string]bool)
+	for _, item := range strings.Split(parametersStr, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s must be key=value", item)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if key == "" || value == "" {
+			return nil, fmt.Errorf("%s must be key=value", item)
+		}
+		// the dotted key and the one separated by slash may be the same parameter
+		if keys[getSysctlFile(key)] {
+			return nil, fmt.Errorf("%s is duplicated", key)
+		}
+		keys[getSysctlFile(key)] = true
+		parameters = append(parameters, SysctlParameter{Key: key, Value: value})
+	}
+	if len(parameters) == 0 {
+		return nil, fmt.Errorf("no parameter found")
+	}
+	return parameters, nil
+}
+
+// checkSysctlKey checks that the key is a parameter file under /proc/sys and it is not denied
+func checkSysctlKey(key string, force bool) error {
+	if strings.Contains(key, "..") || strings.HasPrefix(key, ".") || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("it is not a kernel parameter")
+	}
+	info, err := os.Stat(getSysctlPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("unknown kernel parameter")
+		}
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("it is not a kernel parameter")
+	}
+	if info.Mode().Perm()&0222 == 0 {
+		return fmt.Errorf("it is read-only")
+	}
+	if !force {
+		for _, pattern := range sysctlDenyList {
+			if matched, _ := path.Match(getSysctlFile(pattern), getSysctlFile(key)); matched {
+				return fmt.Errorf("it is dangerous, add the force flag to change it")
+			}
+		}
+	}
+	return nil
+}
+
+// getSysctlFile returns the file of the key relative to /proc/sys. Like sysctl, the dots of the dotted key are
+// the separators, and the key separated by slash is kept as is, so the names with dots such as the vlan
+// interface eth0.100 can be given.
+func getSysctlFile(key string) string {
+	if strings.Contains(key, "/") {
+		return key
+	}
+	return strings.ReplaceAll(key, ".", "/")
+}
+
+func getSysctlPath(key string) string {
+	return path.Join(procSysDir, getSysctlFile(key))
+}
+
+func readSysctl(key string) (string, error) {
+	data, err := os.ReadFile(getSysctlPath(key))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
+
+func writeSysctl(key, value string) error {
+	return os.WriteFile(getSysctlPath(key), []byte(value), 0644)
+}
+
+func getSysctlStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-kernel-sysctl-%s.json", uid)
+}
+
+func writeSysctlState(stateFile string, parameters []SysctlParameter) error {
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readSysctlState(stateFile string) ([]SysctlParameter, error) {
+	var parameters []SysctlParameter
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &parameters)
+	return parameters, err
+}
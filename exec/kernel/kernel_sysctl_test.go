@@ -0,0 +1,45 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kernel
+
+import (
+	"testing"
+)
+
+func TestGetSysctlFile(t *testing.T) {
+	cases := map[string]string{
+		"net.ipv4.ip_forward":              "net/ipv4/ip_forward",
+		"net/ipv4/ip_forward":              "net/ipv4/ip_forward",
+		"net/ipv4/conf/eth0.100/rp_filter": "net/ipv4/conf/eth0.100/rp_filter",
+	}
+	for key, expected := range cases {
+		if file := getSysctlFile(key); file != expected {
+			t.Errorf("expected the file of %s is %s, got %s", key, expected, file)
+		}
+	}
+}
+
+func TestParseSysctlParameters(t *testing.T) {
+	parameters, err := parseSysctlParameters("net/ipv4/conf/eth0.100/rp_filter=0, vm.swappiness=10")
+	if err != nil || len(parameters) != 2 || parameters[0].Key != "net/ipv4/conf/eth0.100/rp_filter" ||
+		parameters[1].Key != "vm.swappiness" {
+		t.Errorf("expected the keys in the given form, got %+v, %v", parameters, err)
+	}
+	if _, err := parseSysctlParameters("vm.swappiness=10,vm/swappiness=20"); err == nil {
+		t.Errorf("expected the duplicated parameter by the dotted key and the one separated by slash")
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// LogDirKey is the flag of the directory which each experiment writes its own log file to
+	LogDirKey = "log-dir"
+	// LogLevelKey is the flag of the log level, such as debug, info, warn or error
+	LogLevelKey = "log-level"
+)
+
+// The experiment log is rotated by the size, the destroy appends to the same file
+var (
+	ExperimentLogMaxSize    = 10 // megabytes
+	ExperimentLogMaxBackups = 3
+)
+
+// ExperimentLogTailLines is the number of the lines returned by TailExperimentLog in the failed responses
+const ExperimentLogTailLines = 20
+
+// GetExperimentLogFile returns the log file of the experiment, <log-dir>/<bin>-<uid>.log
+func GetExperimentLogFile(logDir, uid string) string {
+	bin := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	return filepath.Join(logDir, fmt.Sprintf("%s-%s.log", bin, uid))
+}
+
+// InitExperimentLog sets the log level, and writes the log to the file of the experiment if the log
+// directory is set. It's called after util.InitLog, so the default log file is used without the directory.
+func InitExperimentLog(logDir, level, uid string) error {
+	if level != "" {
+		logLevel, err := logrus.ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		logrus.SetLevel(logLevel)
+	}
+	if logDir == "" || uid == "" {
+		return nil
+	}
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return err
+	}
+	logrus.SetOutput(&lumberjack.Logger{
+		Filename:   GetExperimentLogFile(logDir, uid),
+		MaxSize:    ExperimentLogMaxSize,
+		MaxBackups: ExperimentLogMaxBackups,
+	})
+	return nil
+}
+
+// TailExperimentLog returns the last lines of the experiment log, the rotated files are not read
+func TailExperimentLog(logDir, uid string, lines int) ([]string, error) {
+	file, err := os.Open(GetExperimentLogFile(logDir, uid))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	tail := make([]string, 0, lines)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(tail) == lines {
+			tail = tail[1:]
+		}
+		tail = append(tail, scanner.Text())
+	}
+	return tail, scanner.Err()
+}
+
+// ExperimentLogTail is the tail of the experiment log in the failed response
+type ExperimentLogTail struct {
+	File  string   `json:"file"`
+	Lines []string `json:"lines"`
+}
+
+// WithExperimentLogTail puts the tail of the experiment log into the result of the failed response, so the
+// cause can be found without the access to the host. It's put into the Failure if the result is, otherwise
+// the response is unchanged if it has a result already.
+func WithExperimentLogTail(response *spec.Response, logDir, uid string) *spec.Response {
+	if response == nil || response.Success || logDir == "" || uid == "" {
+		return response
+	}
+	failure, isFailure := response.Result.(*Failure)
+	if response.Result != nil && !isFailure {
+		return response
+	}
+	lines, err := TailExperimentLog(logDir, uid, ExperimentLogTailLines)
+	if err != nil || len(lines) == 0 {
+		return response
+	}
+	tail := &ExperimentLogTail{File: GetExperimentLogFile(logDir, uid), Lines: lines}
+	if isFailure {
+		failure.LogTail = tail
+	} else {
+		response.Result = tail
+	}
+	return response
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestWithExperimentLogTail(t *testing.T) {
+	logDir := t.TempDir()
+	lines := make([]string, 0)
+	for i := 0; i < ExperimentLogTailLines+5; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	if err := os.WriteFile(GetExperimentLogFile(logDir, "uid"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	response := WithExperimentLogTail(spec.ReturnFail(spec.OsCmdExecFailed, "failed"), logDir, "uid")
+	tail, ok := response.Result.(*ExperimentLogTail)
+	if !ok || len(tail.Lines) != ExperimentLogTailLines || tail.Lines[0] != "line 5" ||
+		tail.Lines[ExperimentLogTailLines-1] != lines[len(lines)-1] {
+		t.Fatalf("unexpected tail, %+v", response.Result)
+	}
+	if response := WithExperimentLogTail(spec.ReturnSuccess("ok"), logDir, "uid"); response.Result != "ok" {
+		t.Errorf("expected the successful response unchanged, %+v", response.Result)
+	}
+	if response := WithExperimentLogTail(spec.ReturnFail(spec.OsCmdExecFailed, "failed"), logDir, "other"); response.Result != nil {
+		t.Errorf("expected no tail without the log file, %+v", response.Result)
+	}
+}
+
+func TestInitExperimentLogWithIllegalLevel(t *testing.T) {
+	if err := InitExperimentLog("", "verbose", "uid"); err == nil {
+		t.Errorf("expected an error of the illegal level")
+	}
+}
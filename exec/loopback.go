@@ -0,0 +1,117 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// AffectLoopbackKey is the action flag which allows the network experiments on the loopback, which breaks the
+// health checks on localhost, the dns stub resolvers and the requests of the blade cli to the blade server
+const AffectLoopbackKey = "affect-loopback"
+
+// LoopbackInterface is the loopback interface of linux
+const LoopbackInterface = "lo"
+
+// BladeServerPort is the default port of the blade server, which is requested by the blade cli on the loopback
+var BladeServerPort = 9526
+
+var loopbackNets = []*net.IPNet{
+	{IP: net.IPv4(127, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+}
+
+// LoopbackIP returns the first ip or cidr of the comma separated list which overlaps the loopback, such as
+// 127.0.0.1, ::1 or 0.0.0.0/0. The illegal ones are skipped, they are rejected by the validation.
+func LoopbackIP(ips string) string {
+	for _, ip := range strings.Split(ips, ",") {
+		ip = strings.TrimSpace(ip)
+		if ip == "" {
+			continue
+		}
+		var ipNet *net.IPNet
+		if strings.Contains(ip, "/") {
+			_, n, err := net.ParseCIDR(ip)
+			if err != nil {
+				continue
+			}
+			ipNet = n
+		} else if parsed := net.ParseIP(ip); parsed != nil {
+			ipNet = &net.IPNet{IP: parsed, Mask: net.CIDRMask(len(parsed)*8, len(parsed)*8)}
+		} else {
+			continue
+		}
+		for _, loopback := range loopbackNets {
+			if loopback.Contains(ipNet.IP) || ipNet.Contains(loopback.IP) {
+				return ip
+			}
+		}
+	}
+	return ""
+}
+
+// IsLoopbackAffected returns true if the interface is the loopback or one of the ip flags overlaps the loopback
+func IsLoopbackAffected(flags map[string]string, netInterface string, ipFlags ...string) bool {
+	return loopbackSelector(flags, netInterface, ipFlags...) != ""
+}
+
+func loopbackSelector(flags map[string]string, netInterface string, ipFlags ...string) string {
+	if netInterface == LoopbackInterface {
+		return fmt.Sprintf("the interface %s", netInterface)
+	}
+	for _, flag := range ipFlags {
+		if ip := LoopbackIP(flags[flag]); ip != "" {
+			return fmt.Sprintf("the %s %s", flag, ip)
+		}
+	}
+	return ""
+}
+
+// CheckLoopback returns the failure if the experiment affects the loopback without the affect-loopback flag,
+// the ports of LoopbackExcludedPorts are excluded by the experiment which affects the loopback anyway
+func CheckLoopback(ctx context.Context, flags map[string]string, netInterface string, ipFlags ...string) *spec.Response {
+	selector := loopbackSelector(flags, netInterface, ipFlags...)
+	if selector == "" || flags[AffectLoopbackKey] == spec.True {
+		return nil
+	}
+	message := fmt.Sprintf("%s affects the loopback, specify --%s to run the experiment anyway", selector, AffectLoopbackKey)
+	log.Errorf(ctx, "%s", message)
+	return Fail(LoopbackRefused, "network", AffectLoopbackKey, message)
+}
+
+// LoopbackExcludedPorts returns the sorted ports which the experiment on the loopback keeps, the port of the
+// blade server and the server port of the ssh session which runs the experiment, see SSH_CONNECTION
+func LoopbackExcludedPorts() []int {
+	ports := []int{BladeServerPort}
+	// SSH_CONNECTION is "client_ip client_port server_ip server_port"
+	if fields := strings.Fields(os.Getenv("SSH_CONNECTION")); len(fields) == 4 {
+		if port, err := strconv.Atoi(fields[3]); err == nil && port > 0 && port != BladeServerPort {
+			ports = append(ports, port)
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
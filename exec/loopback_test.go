@@ -0,0 +1,79 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestLoopbackIP(t *testing.T) {
+	tests := []struct {
+		ips  string
+		want string
+	}{
+		{"", ""},
+		{"10.0.0.1,192.168.1.0/24", ""},
+		{"10.0.0.1,127.0.0.53", "127.0.0.53"},
+		{"127.1.0.0/16", "127.1.0.0/16"},
+		{"0.0.0.0/0", "0.0.0.0/0"},
+		{"::1", "::1"},
+		{"fe80::1,illegal", ""},
+	}
+	for _, tt := range tests {
+		if got := LoopbackIP(tt.ips); got != tt.want {
+			t.Errorf("LoopbackIP(%q) = %q, want %q", tt.ips, got, tt.want)
+		}
+	}
+}
+
+func TestCheckLoopback(t *testing.T) {
+	ctx := context.Background()
+	if response := CheckLoopback(ctx, map[string]string{}, "eth0", "destination-ip"); response != nil {
+		t.Errorf("unexpected failure, %v", response)
+	}
+	for _, tt := range []struct {
+		flags        map[string]string
+		netInterface string
+	}{
+		{map[string]string{}, LoopbackInterface},
+		{map[string]string{"destination-ip": "127.0.0.1"}, "eth0"},
+	} {
+		response := CheckLoopback(ctx, tt.flags, tt.netInterface, "destination-ip")
+		if response == nil || response.Code != spec.Forbidden.Code {
+			t.Errorf("expected the loopback is refused for %v on %s, got %v", tt.flags, tt.netInterface, response)
+		}
+		tt.flags[AffectLoopbackKey] = spec.True
+		if response := CheckLoopback(ctx, tt.flags, tt.netInterface, "destination-ip"); response != nil {
+			t.Errorf("unexpected failure with --%s, %v", AffectLoopbackKey, response)
+		}
+	}
+}
+
+func TestLoopbackExcludedPorts(t *testing.T) {
+	t.Setenv("SSH_CONNECTION", "")
+	if got := LoopbackExcludedPorts(); !reflect.DeepEqual(got, []int{BladeServerPort}) {
+		t.Errorf("unexpected ports %v without the ssh session", got)
+	}
+	t.Setenv("SSH_CONNECTION", "10.0.0.2 52314 127.0.0.1 22")
+	if got := LoopbackExcludedPorts(); !reflect.DeepEqual(got, []int{22, BladeServerPort}) {
+		t.Errorf("unexpected ports %v with the ssh session", got)
+	}
+}
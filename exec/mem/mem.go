@@ -22,8 +22,9 @@ import (
 	"math"
 	"os"
 	"path"
-	"strconv"
+	"runtime/debug"
 	"time"
+	"unsafe"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/channel"
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
@@ -32,6 +33,8 @@ import (
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
 )
 
 const BurnMemBin = "chaos_burnmem"
@@ -47,7 +50,64 @@ func NewMemCommandModelSpec() spec.ExpModelCommandSpec {
 				&MemLoadActionCommand{
 					spec.BaseExpActionCommandSpec{
 						ActionMatchers: []spec.ExpFlagSpec{},
-						ActionFlags:    []spec.ExpFlagSpec{},
+						ActionFlags: append([]spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:     "mem-percent",
+								Desc:     "percent of burn Memory (0-100), must be a positive integer",
+								Required: false,
+							},
+							&spec.ExpFlag{
+								Name:     "reserve",
+								Desc:     "reserve to burn Memory, unit is MB, or with the unit K, M, G or T, for example, 1G. If the mem-percent flag exist, use mem-percent first.",
+								Required: false,
+							},
+							&spec.ExpFlag{
+								Name:     "rate",
+								Desc:     "burn memory rate, unit is M/S, only support for ram mode.",
+								Required: false,
+							},
+							&spec.ExpFlag{
+								Name:     "mode",
+								Desc:     "burn memory mode, cache, ram or mmap. The mmap mode allocates the anonymous mmap regions which can be locked by the lock flag.",
+								Required: false,
+							},
+							&spec.ExpFlag{
+								Name:   lockFlag,
+								Desc:   "lock the burned memory so it can't be swapped away, only support for mmap mode. The RLIMIT_MEMLOCK of the chaos process is raised if it's permitted",
+								NoArgs: true,
+							},
+							&spec.ExpFlag{
+								Name:   "include-buffer-cache",
+								Desc:   "Ram mode mem-percent is include buffer/cache",
+								NoArgs: true,
+							},
+							&spec.ExpFlag{
+								Name:   "avoid-being-killed",
+								Desc:   "Prevent mem-burn process from being killed by oom-killer",
+								NoArgs: true,
+							},
+							&spec.ExpFlag{
+								Name:     "cgroup-root",
+								Desc:     "cgroup root path, such as the mount of the host cgroupfs /host-sys/fs/cgroup in the container, it's detected from the cgroup mounts if absent",
+								NoArgs:   false,
+								Required: false,
+								Default:  "",
+							},
+							&spec.ExpFlag{
+								Name:     cgroups.HostProcKey,
+								Desc:     "the mount point of the host proc filesystem, such as /host/proc, which the cgroup of the host pid is read from when running in the container",
+								NoArgs:   false,
+								Required: false,
+								Default:  "",
+							},
+							&spec.ExpFlag{
+								Name:     cgroups.CGroupScopeKey,
+								Desc:     "the cgroup which the usage of the target is measured in, leaf is the cgroup of the target, limit-owner is its nearest ancestor which sets the limit, such as the service slice of the scope, default limit-owner",
+								NoArgs:   false,
+								Required: false,
+								Default:  "",
+							},
+						}, psiGuardFlags()...),
 						ActionExecutor: &memExecutor{},
 						ActionExample: `
 # The execution memory footprint is 50%
@@ -66,51 +126,20 @@ blade create mem load --mode ram --mem-percent 50 --avoid-being-killed
 blade create mem load --mode ram --mem-percent 50 --timeout 200
 
 # 200M memory is reserved
-blade create mem load --mode ram --reserve 200 --rate 100`,
+blade create mem load --mode ram --reserve 200 --rate 100
+
+# The execution memory footprint is 80%, the burned memory is locked so it can't be swapped away
+blade create mem load --mode mmap --mem-percent 80 --lock
+
+# The execution memory footprint is 90%, release half of the held memory if the full avg10 of the memory pressure stays above 5% for 30 seconds
+blade create mem load --mode ram --mem-percent 90 --psi-threshold 5 --psi-grace 30s --psi-release 50`,
 						ActionPrograms:    []string{BurnMemBin},
 						ActionCategories:  []string{category.SystemMem},
 						ActionProcessHang: true,
 					},
 				},
-			},
-			ExpFlags: []spec.ExpFlagSpec{
-				&spec.ExpFlag{
-					Name:     "mem-percent",
-					Desc:     "percent of burn Memory (0-100), must be a positive integer",
-					Required: false,
-				},
-				&spec.ExpFlag{
-					Name:     "reserve",
-					Desc:     "reserve to burn Memory, unit is MB. If the mem-percent flag exist, use mem-percent first.",
-					Required: false,
-				},
-				&spec.ExpFlag{
-					Name:     "rate",
-					Desc:     "burn memory rate, unit is M/S, only support for ram mode.",
-					Required: false,
-				},
-				&spec.ExpFlag{
-					Name:     "mode",
-					Desc:     "burn memory mode, cache or ram.",
-					Required: false,
-				},
-				&spec.ExpFlag{
-					Name:   "include-buffer-cache",
-					Desc:   "Ram mode mem-percent is include buffer/cache",
-					NoArgs: true,
-				},
-				&spec.ExpFlag{
-					Name:   "avoid-being-killed",
-					Desc:   "Prevent mem-burn process from being killed by oom-killer",
-					NoArgs: true,
-				},
-				&spec.ExpFlag{
-					Name:     "cgroup-root",
-					Desc:     "cgroup root path, default value /sys/fs/cgroup",
-					NoArgs:   false,
-					Required: false,
-					Default:  "/sys/fs/cgroup",
-				},
+				NewCacheDropActionCommandSpec(),
+				NewPageCacheFillActionCommandSpec(),
 			},
 		},
 	}
@@ -125,7 +154,7 @@ func (*MemCommandModelSpec) ShortDesc() string {
 }
 
 func (*MemCommandModelSpec) LongDesc() string {
-	return "Mem experiment, for example load"
+	return "Mem experiment, for example load or drop the page cache"
 }
 
 func (*MemCommandModelSpec) Example() string {
@@ -159,8 +188,8 @@ func (*MemLoadActionCommand) Matchers() []spec.ExpFlagSpec {
 	return []spec.ExpFlagSpec{}
 }
 
-func (*MemLoadActionCommand) Flags() []spec.ExpFlagSpec {
-	return []spec.ExpFlagSpec{}
+func (l *MemLoadActionCommand) Flags() []spec.ExpFlagSpec {
+	return l.ActionFlags
 }
 
 type memExecutor struct {
@@ -193,7 +222,7 @@ func (ce *memExecutor) Exec(uid string, ctx context.Context, model *spec.ExpMode
 		return spec.ResponseFailWithFlags(spec.ChannelNil)
 	}
 	if _, ok := spec.IsDestroy(ctx); ok {
-		return ce.stop(ctx, model.ActionFlags["mode"])
+		return ce.stop(ctx, model.ActionFlags["mode"], model.ActionFlags[lockFlag] == "true")
 	}
 	var memPercent, memReserve, memRate int
 
@@ -203,42 +232,73 @@ func (ce *memExecutor) Exec(uid string, ctx context.Context, model *spec.ExpMode
 	burnMemModeStr := model.ActionFlags["mode"]
 	includeBufferCache := model.ActionFlags["include-buffer-cache"] == "true"
 	avoidBeingKilled := model.ActionFlags["avoid-being-killed"] == "true"
+	lock := model.ActionFlags[lockFlag] == "true"
+	if burnMemModeStr == mmapMode && !mmapSupported {
+		return exec.Fail(exec.UnsupportedPlatform, "mem", "mmap", fmt.Sprintf("the %s mode is not supported on Windows", mmapMode))
+	}
+	if lock && burnMemModeStr != mmapMode {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, lockFlag, "true",
+			fmt.Sprintf("it requires the %s mode, but the mode is %q", mmapMode, burnMemModeStr))
+	}
 
-	var err error
+	var response *spec.Response
 	if memPercentStr != "" {
-		var err error
-		memPercent, err = strconv.Atoi(memPercentStr)
-		if err != nil {
-			log.Errorf(ctx, "`%s`: mem-percent  must be a positive integer", memPercentStr)
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "mem-percent", memPercentStr, "it must be a positive integer")
-		}
-		if memPercent > 100 || memPercent < 0 {
-			log.Errorf(ctx, "`%s`: mem-percent  must be a positive integer and not bigger than 100", memPercentStr)
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "mem-percent", memPercentStr, "it must be a positive integer and not bigger than 100")
+		if memPercent, response = validation.ValidatePercent("mem-percent", memPercentStr); response != nil {
+			log.Errorf(ctx, "`%s`: mem-percent is illegal, %s", memPercentStr, response.Err)
+			return response
 		}
 	} else if memReserveStr != "" {
-		memReserve, err = strconv.Atoi(memReserveStr)
-		if err != nil {
-			log.Errorf(ctx, "`%s`: reserve  must be a positive integer", memReserveStr)
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "reserve", memReserveStr, err)
+		var bytes int64
+		if bytes, response = validation.ValidateSizeBytes("reserve", memReserveStr, validation.MB, 0); response != nil {
+			log.Errorf(ctx, "`%s`: reserve is illegal, %s", memReserveStr, response.Err)
+			return response
 		}
+		memReserve = int(bytes / validation.MB)
 	} else {
 		memPercent = 100
 	}
 	if memRateStr != "" {
-		memRate, err = strconv.Atoi(memRateStr)
-		if err != nil {
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "rate", memRateStr, "it must be a positive integer")
+		if memRate, response = validation.ValidateInt("rate", memRateStr, 0, math.MaxInt); response != nil {
+			return response
 		}
 	}
+	guard, response := newPsiGuard(model.ActionFlags)
+	if response != nil {
+		log.Errorf(ctx, "the flags of the node-safety mode are illegal, %s", response.Err)
+		return response
+	}
 	ctx = context.WithValue(ctx, "cgroup-root", model.ActionFlags["cgroup-root"])
-	ce.start(ctx, memPercent, memReserve, memRate, burnMemModeStr, includeBufferCache, avoidBeingKilled, ce.channel)
+	ctx = cgroups.WithHostProc(ctx, model.ActionFlags[cgroups.HostProcKey])
+	if err := cgroups.CheckCGroupScope(model.ActionFlags[cgroups.CGroupScopeKey]); err != nil {
+		log.Errorf(ctx, "`%s`: %s is illegal, %v", model.ActionFlags[cgroups.CGroupScopeKey], cgroups.CGroupScopeKey, err)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, cgroups.CGroupScopeKey, model.ActionFlags[cgroups.CGroupScopeKey], err.Error())
+	}
+	ctx = cgroups.WithCGroupScope(ctx, model.ActionFlags[cgroups.CGroupScopeKey])
+	var memlock uint64
+	if lock {
+		if memlock, response = checkMemlock(ctx, memPercent, memReserve, includeBufferCache); response != nil {
+			log.Errorf(ctx, "lock the burned memory failed, %s", response.Err)
+			return response
+		}
+	}
+	ctx = context.WithValue(ctx, lockFlag, lock)
+	ctx = context.WithValue(ctx, "memlock", memlock)
+	ce.start(ctx, memPercent, memReserve, memRate, burnMemModeStr, includeBufferCache, avoidBeingKilled, guard, ce.channel)
 	return spec.Success()
 }
 
+const (
+	// mmapMode allocates the memory by the anonymous mmap, the regions can be locked and unmapped precisely
+	mmapMode = "mmap"
+	// lockFlag locks the regions of the mmap mode, so they can't be swapped away
+	lockFlag = "lock"
+)
+
 // 128K
 type Block [32 * 1024]int32
 
+const blockSize = int64(unsafe.Sizeof(Block{}))
+
 const PageCounterMax uint64 = 9223372036854770000
 
 func calculateMemSize(ctx context.Context, burnMemMode string, percent, reserve int, includeBufferCache bool) (int64, int64, error) {
@@ -269,7 +329,7 @@ var fileName = "file"
 
 var fileCount = 1
 
-func burnMemWithCache(ctx context.Context, memPercent, memReserve, memRate int, burnMemMode string, includeBufferCache bool, cl spec.Channel) {
+func burnMemWithCache(ctx context.Context, memPercent, memReserve, memRate int, burnMemMode string, includeBufferCache bool, guard *psiGuard, cl spec.Channel) {
 	tmpfsPath := path.Join(util.GetProgramPath(), dirName)
 	filePath := path.Join(tmpfsPath, fileName)
 	// prepare tmpfs
@@ -279,12 +339,36 @@ func burnMemWithCache(ctx context.Context, memPercent, memReserve, memRate int,
 	if memRate <= 0 {
 		memRate = 100
 	}
+	uid, _ := ctx.Value(spec.Uid).(string)
+	metrics := exec.NewMetricsWriter(ctx, uid, "mem", "load", "")
+	// the files are released from the newest one on the back off
+	var files []string
+	var sizes []int64
+	var held int64
+	release := func(size int64) int64 {
+		var released int64
+		for released < size && len(files) > 0 {
+			last := len(files) - 1
+			if response := cl.Run(ctx, "rm", fmt.Sprintf("-f %s", files[last])); !response.Success {
+				log.Errorf(ctx, "release %s failed, %s", files[last], response.Err)
+				break
+			}
+			released += sizes[last]
+			files, sizes = files[:last], sizes[:last]
+		}
+		held -= released
+		return released
+	}
 	tick := time.Tick(time.Second)
 	for range tick {
 		_, expectMem, err := calculateMemSize(ctx, burnMemMode, memPercent, memReserve, includeBufferCache)
 		if err != nil {
 			log.Fatalf(ctx, "calculate memsize err, %v", err)
 		}
+		setHeldMetrics(ctx, metrics, held, expectMem, guard)
+		if guard.check(ctx, held, release) {
+			continue
+		}
 		fillMem := expectMem
 		if expectMem > 0 {
 			if expectMem > int64(memRate) {
@@ -296,13 +380,26 @@ func burnMemWithCache(ctx context.Context, memPercent, memReserve, memRate int,
 			if !response.Success {
 				log.Fatalf(ctx, "burn mem with cache err, %v", err)
 			}
+			files, sizes = append(files, nFilePath), append(sizes, fillMem*validation.MB)
+			held += fillMem * validation.MB
 			fileCount++
 		}
 	}
 }
 
+// setHeldMetrics reports the held bytes against the target, the expectMem is the MB to fill, which is negative
+// if the usage is above the target
+func setHeldMetrics(ctx context.Context, metrics *exec.MetricsWriter, held, expectMem int64, guard *psiGuard) {
+	metrics.Set("bytes_held", float64(held))
+	metrics.Set("target_bytes", float64(held+expectMem*validation.MB))
+	if guard != nil {
+		metrics.Set("psi_backoffs", float64(guard.backoffs))
+	}
+	metrics.Flush(ctx)
+}
+
 // start burn mem
-func (ce *memExecutor) start(ctx context.Context, memPercent, memReserve, memRate int, burnMemMode string, includeBufferCache bool, avoidBeingKilled bool, cl spec.Channel) {
+func (ce *memExecutor) start(ctx context.Context, memPercent, memReserve, memRate int, burnMemMode string, includeBufferCache bool, avoidBeingKilled bool, guard *psiGuard, cl spec.Channel) {
 	// adjust process oom_score_adj to avoid being killed
 	if avoidBeingKilled {
 		// not works for the channel.NSExecChannel
@@ -321,21 +418,48 @@ func (ce *memExecutor) start(ctx context.Context, memPercent, memReserve, memRat
 	}
 
 	if burnMemMode == "cache" {
-		burnMemWithCache(ctx, memPercent, memReserve, memRate, burnMemMode, includeBufferCache, cl)
+		burnMemWithCache(ctx, memPercent, memReserve, memRate, burnMemMode, includeBufferCache, guard, cl)
+		return
+	}
+	if burnMemMode == mmapMode {
+		lock, _ := ctx.Value(lockFlag).(bool)
+		memlock, _ := ctx.Value("memlock").(uint64)
+		burnMemWithMmap(ctx, memPercent, memReserve, memRate, includeBufferCache, lock, memlock, guard)
 		return
 	}
 	tick := time.Tick(time.Second)
-	cache := make(map[int][]Block, 1)
-	count := 1
-	cache[count] = make([]Block, 0)
+	// the memory is held in the chunks, so a part of it can be released from the newest chunk on the back off
+	chunks := make([][]Block, 0)
 	if memRate <= 0 {
 		memRate = 100
 	}
+	uid, _ := ctx.Value(spec.Uid).(string)
+	metrics := exec.NewMetricsWriter(ctx, uid, "mem", "load", "")
+	var held int64
+	release := func(size int64) int64 {
+		var released int64
+		for released < size && len(chunks) > 0 {
+			last := len(chunks) - 1
+			released += int64(len(chunks[last])) * blockSize
+			chunks[last] = nil
+			chunks = chunks[:last]
+		}
+		held -= released
+		debug.FreeOSMemory()
+		return released
+	}
 	for range tick {
 		_, expectMem, err := calculateMemSize(ctx, burnMemMode, memPercent, memReserve, includeBufferCache)
 		if err != nil {
+			metrics.Set("bytes_held", float64(held))
+			metrics.SetError(err)
+			metrics.Flush(ctx)
 			log.Fatalf(ctx, "calculate memsize err, %v", err.Error())
 		}
+		setHeldMetrics(ctx, metrics, held, expectMem, guard)
+		if guard.check(ctx, held, release) {
+			continue
+		}
 		fillMem := expectMem
 		if expectMem > 0 {
 			if expectMem > int64(memRate) {
@@ -347,22 +471,30 @@ func (ce *memExecutor) start(ctx context.Context, memPercent, memReserve, memRat
 				}
 			}
 			fillSize := int(8 * fillMem)
-			buf := cache[count]
-			if cap(buf)-len(buf) < fillSize &&
-				int(math.Floor(float64(cap(buf))*1.25)) >= int(8*expectMem) {
-				count += 1
-				cache[count] = make([]Block, 0)
-				buf = cache[count]
-			}
-			log.Debugf(ctx, "count: %d, len(buf): %d, cap(buf): %d, expect mem: %d, fill size: %d",
-				count, len(buf), cap(buf), expectMem, fillSize)
-			cache[count] = append(buf, make([]Block, fillSize)...)
+			log.Debugf(ctx, "chunks: %d, held: %d, expect mem: %d, fill size: %d", len(chunks), held, expectMem, fillSize)
+			chunk := make([]Block, fillSize)
+			touch(chunk)
+			chunks = append(chunks, chunk)
+			held += int64(fillSize) * blockSize
+		}
+	}
+}
+
+// touch writes every page of the chunk, the fresh memory from the OS isn't resident until it's written
+func touch(chunk []Block) {
+	step := os.Getpagesize() / int(unsafe.Sizeof(int32(0)))
+	for i := range chunk {
+		for j := 0; j < len(chunk[i]); j += step {
+			chunk[i][j] = 1
 		}
 	}
 }
 
-// stop burn mem
-func (ce *memExecutor) stop(ctx context.Context, burnMemMode string) *spec.Response {
+// stop burn mem, the locked memory is unlocked by the burn process before it's killed
+func (ce *memExecutor) stop(ctx context.Context, burnMemMode string, lock bool) *spec.Response {
+	if uid, ok := ctx.Value(spec.Uid).(string); ok && uid != "" && lock {
+		unlockBurn(ctx, uid)
+	}
 	ctx = context.WithValue(ctx, "bin", BurnMemBin)
 	response := exec.Destroy(ctx, ce.channel, "mem load")
 	// umount tmpfs
@@ -371,3 +503,41 @@ func (ce *memExecutor) stop(ctx context.Context, burnMemMode string) *spec.Respo
 	ce.channel.Run(ctx, "rm", fmt.Sprintf("-rf %s", tmpfsPath))
 	return response
 }
+
+// Status checks the process which holds the memory, and the held bytes against the target
+func (ce *memExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	report.Add(exec.ProcessArtifact(ctx, uid, model.Target, model.ActionName))
+	report.AddMetrics()
+	for _, snapshot := range report.Metrics {
+		if detail := heldDetail(snapshot.Values); detail != "" {
+			report.Add(exec.Artifact{Kind: "memory", Name: "held", Present: true, Detail: detail})
+		}
+		if snapshot.Values["lock_failed"] == 1 {
+			report.Degrade(snapshot.LastError)
+		}
+	}
+	return report
+}
+
+// heldDetail describes the held bytes, the target and the back off events of the metrics
+func heldDetail(values map[string]float64) string {
+	held, ok := values["bytes_held"]
+	if !ok {
+		return ""
+	}
+	detail := fmt.Sprintf("held %.0f bytes", held)
+	if target, ok := values["target_bytes"]; ok {
+		detail = fmt.Sprintf("%s of the target %.0f bytes", detail, target)
+	}
+	if locked, ok := values["bytes_locked"]; ok {
+		detail = fmt.Sprintf("%s, %.0f bytes locked", detail, locked)
+	}
+	if values["lock_failed"] == 1 {
+		detail = fmt.Sprintf("%s, the growth is stopped by the lock failure", detail)
+	}
+	if backoffs, ok := values["psi_backoffs"]; ok {
+		detail = fmt.Sprintf("%s, backed off %.0f times by the memory pressure", detail, backoffs)
+	}
+	return detail
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/kernel"
+)
+
+const CacheDropBin = "chaos_cachedrop"
+
+const dropCachesFile = "/proc/sys/vm/drop_caches"
+
+type CacheDropActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewCacheDropActionCommandSpec() spec.ExpActionCommandSpec {
+	return &CacheDropActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:    "drop",
+					Desc:    "The value written to drop_caches, 1 is the page cache, 2 is the dentries and inodes, 3 is both, default value is 3",
+					Default: "3",
+				},
+				&spec.ExpFlag{
+					Name: "interval",
+					Desc: "Drop the caches every interval seconds until the experiment is destroyed, the caches are dropped once if it's not set",
+				},
+				&spec.ExpFlag{
+					Name: "dirty-ratio",
+					Desc: "Override vm.dirty_ratio (0-100) until the experiment is destroyed",
+				},
+				&spec.ExpFlag{
+					Name: "dirty-background-ratio",
+					Desc: "Override vm.dirty_background_ratio (0-100) until the experiment is destroyed",
+				},
+			},
+			ActionExecutor: &cacheDropExecutor{},
+			ActionExample: `
+# Drop the page cache, dentries and inodes once
+blade create mem cache-drop
+
+# Drop the page cache every 10 seconds
+blade create mem cache-drop --drop 1 --interval 10
+
+# Force the writeback storms by lowering the dirty page ratios
+blade create mem cache-drop --interval 30 --dirty-ratio 5 --dirty-background-ratio 1`,
+			ActionPrograms:    []string{CacheDropBin},
+			ActionCategories:  []string{category.SystemMem},
+			ActionProcessHang: true,
+		},
+	}
+}
+
+func (*CacheDropActionCommandSpec) Name() string {
+	return "cache-drop"
+}
+
+func (*CacheDropActionCommandSpec) Aliases() []string {
+	return []string{"drop-caches"}
+}
+
+func (*CacheDropActionCommandSpec) ShortDesc() string {
+	return "Drop the page cache"
+}
+
+func (c *CacheDropActionCommandSpec) LongDesc() string {
+	if c.ActionLongDesc != "" {
+		return c.ActionLongDesc
+	}
+	return "Write to /proc/sys/vm/drop_caches once or periodically until the experiment is destroyed, " +
+		"vm.dirty_ratio and vm.dirty_background_ratio can be lowered to force the writeback, they are restored when the experiment is destroyed. " +
+		"The dropped caches are not restored, they are reloaded from the disks on demand"
+}
+
+type cacheDropExecutor struct {
+	channel spec.Channel
+}
+
+func (*cacheDropExecutor) Name() string {
+	return "cache-drop"
+}
+
+func (ce *cacheDropExecutor) SetChannel(channel spec.Channel) {
+	ce.channel = channel
+}
+
+func (ce *cacheDropExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return ce.stop(ctx, uid)
+	}
+	drop := model.ActionFlags["drop"]
+	if drop == "" {
+		drop = "3"
+	}
+	if drop != "1" && drop != "2" && drop != "3" {
+		log.Errorf(ctx, "`%s`: drop is illegal", drop)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "drop", drop, "it must be 1, 2 or 3")
+	}
+	var interval int
+	if intervalStr := model.ActionFlags["interval"]; intervalStr != "" {
+		var err error
+		interval, err = strconv.Atoi(intervalStr)
+		if err != nil || interval < 0 {
+			log.Errorf(ctx, "`%s`: interval is illegal", intervalStr)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "interval", intervalStr, "it must be a non-negative integer")
+		}
+	}
+	parameters := make([]kernel.SysctlParameter, 0)
+	for _, ratio := range []struct{ flag, key string }{
+		{"dirty-ratio", "vm.dirty_ratio"},
+		{"dirty-background-ratio", "vm.dirty_background_ratio"},
+	} {
+		flag, key := ratio.flag, ratio.key
+		value := model.ActionFlags[flag]
+		if value == "" {
+			continue
+		}
+		if ratio, err := strconv.Atoi(value); err != nil || ratio < 0 || ratio > 100 {
+			log.Errorf(ctx, "`%s`: %s is illegal", value, flag)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, flag, value, "it must be an integer in 0-100")
+		}
+		parameters = append(parameters, kernel.SysctlParameter{Key: key, Value: value})
+	}
+	return ce.start(ctx, uid, drop, interval, parameters)
+}
+
+func (ce *cacheDropExecutor) start(ctx context.Context, uid, drop string, interval int, parameters []kernel.SysctlParameter) *spec.Response {
+	if len(parameters) > 0 {
+		if response := kernel.SetSysctl(ctx, getCacheDropStateFile(uid), parameters); response != nil {
+			return response
+		}
+	}
+	if err := dropCaches(drop); err != nil {
+		log.Errorf(ctx, "drop the caches failed, %v", err)
+		kernel.RestoreSysctl(ctx, getCacheDropStateFile(uid))
+		return exec.FailWithFlags(exec.SettingApplyFailed, "page-cache", "dropCaches", err)
+	}
+	if interval == 0 {
+		log.Infof(ctx, "the caches are dropped, drop: %s", drop)
+		return spec.ReturnSuccess(uid)
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := dropCaches(drop); err != nil {
+				log.Warnf(ctx, "drop the caches failed, %v", err)
+			}
+		case <-ctx.Done():
+			return spec.ReturnSuccess(uid)
+		}
+	}
+}
+
+func (ce *cacheDropExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	ctx = context.WithValue(ctx, "bin", CacheDropBin)
+	if response := exec.Destroy(ctx, ce.channel, "mem cache-drop"); !response.Success {
+		return response
+	}
+	// the state file exists only if the dirty page ratios are overridden
+	stateFile := getCacheDropStateFile(uid)
+	if _, err := os.Stat(stateFile); err != nil {
+		return spec.ReturnSuccess(uid)
+	}
+	if response := kernel.RestoreSysctl(ctx, stateFile); response != nil {
+		return response
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func dropCaches(drop string) error {
+	return os.WriteFile(dropCachesFile, []byte(drop), 0644)
+}
+
+func getCacheDropStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-mem-cache-drop-%s.json", uid)
+}
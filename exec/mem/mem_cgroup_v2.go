@@ -28,15 +28,13 @@ func getAvailableAndTotalV2(ctx context.Context, burnMemMode string, includeBuff
 			return 0, 0, fmt.Errorf("load cgroup error, %v", err)
 		}
 
-		cgroupRoot := ctx.Value("cgroup-root")
-		if cgroupRoot == "" {
-			cgroupRoot = "/sys/fs/cgroup"
-		}
+		cgroupRoot, _ := ctx.Value("cgroup-root").(string)
+		cgroupRoot = cgroups.ResolveCGroupRoot(ctx, cgroupRoot)
 
 		log.Debugf(ctx, "get mem usage by cgroup v2, root path: %s", cgroupRoot)
 
 		// 查找 cgroup v2 路径
-		cgroupPath, err := cgroups.FindCGroupV2Path(ctx, strconv.Itoa(p), cgroupRoot.(string))
+		cgroupPath, err := cgroups.FindCGroupV2Path(ctx, strconv.Itoa(p), cgroupRoot)
 		if err != nil {
 			log.Errorf(ctx, "failed to find cgroup v2 path: %v", err)
 			return getSystemMemory(burnMemMode, includeBufferCache)
@@ -47,25 +45,30 @@ func getAvailableAndTotalV2(ctx context.Context, burnMemMode string, includeBuff
 			return getSystemMemory(burnMemMode, includeBufferCache)
 		}
 
-		// 创建 CGroupV2Impl 实例并获取内存限制
+		// 按 cgroup-scope 选择度量的 cgroup, v2 的父 cgroup 自动汇总子 cgroup 的用量
+		cgroupPath = cgroups.ScopedCGroupV2Path(ctx, cgroupPath, cgroups.CGroupV2MemoryController)
+
+		// 创建 CGroupV2Impl 实例并获取内存限制, memory.high 低于 memory.max 时以 memory.high 为上限
 		cg := cgroups.NewCGroupV2Impl(cgroupPath)
-		limit, defined, err := cg.MemoryLimit()
+		limits, err := cg.MemoryLimits()
 		if err != nil {
-			log.Errorf(ctx, "failed to get cgroup v2 memory limit: %v", err)
+			log.Errorf(ctx, "failed to get cgroup v2 memory limits: %v", err)
 			return getSystemMemory(burnMemMode, includeBufferCache)
 		}
 
+		limit, source, defined := limits.Ceiling()
 		if !defined || limit == 0 {
 			log.Warnf(ctx, "cgroup v2 memory limit not defined or unlimited, falling back to system memory")
 			return getSystemMemory(burnMemMode, includeBufferCache)
 		}
+		log.Infof(ctx, "use %s of %s as the memory ceiling: %d", source, cgroupPath, limit)
 
 		// 获取当前内存使用情况
-		usage, err := getCGroupV2MemoryUsage(ctx, cgroupPath)
-		if err != nil {
-			log.Errorf(ctx, "failed to get cgroup v2 memory usage: %v", err)
+		if !limits.Current.Defined {
+			log.Errorf(ctx, "failed to get cgroup v2 memory usage, %s is not available", cgroups.CGroupV2MemoryCurrentFile)
 			return getSystemMemory(burnMemMode, includeBufferCache)
 		}
+		usage := limits.Current.Value
 
 		total = limit
 		available = total - usage
@@ -84,23 +87,6 @@ func getAvailableAndTotalV2(ctx context.Context, burnMemMode string, includeBuff
 	return getSystemMemory(burnMemMode, includeBufferCache)
 }
 
-// getCGroupV2MemoryUsage 获取 cgroup v2 的内存使用量
-func getCGroupV2MemoryUsage(ctx context.Context, cgroupPath string) (int64, error) {
-	// 读取 memory.current 文件
-	currentFile := cgroupPath + "/memory.current"
-	content, err := os.ReadFile(currentFile)
-	if err != nil {
-		return 0, err
-	}
-
-	usage, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
-	if err != nil {
-		return 0, err
-	}
-
-	return usage, nil
-}
-
 // getCGroupV2MemoryCache 获取 cgroup v2 的缓存使用量
 func getCGroupV2MemoryCache(ctx context.Context, cgroupPath string) (int64, error) {
 	// 读取 memory.stat 文件并解析缓存信息
@@ -20,6 +20,8 @@ import (
 	"context"
 
 	"github.com/shirou/gopsutil/mem"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
 )
 
 func getAvailableAndTotal(ctx context.Context, burnMemMode string, includeBufferCache bool) (int64, int64, error) {
@@ -35,3 +37,9 @@ func getAvailableAndTotal(ctx context.Context, burnMemMode string, includeBuffer
 	}
 	return total, available, nil
 }
+
+// getMemoryPressure returns NotAvailableError, the pressure stall information is only available on Linux
+func getMemoryPressure(ctx context.Context) (*cgroups.Pressure, string, error) {
+	pressure, err := cgroups.SystemMemoryPressure(ctx)
+	return pressure, "", err
+}
@@ -19,6 +19,7 @@ package mem
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"strconv"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/channel"
@@ -41,15 +42,13 @@ func getAvailableAndTotal(ctx context.Context, burnMemMode string, includeBuffer
 			return 0, 0, fmt.Errorf("load cgroup error, %v", err)
 		}
 
-		cgroupRoot := ctx.Value("cgroup-root")
-		if cgroupRoot == "" {
-			cgroupRoot = "/sys/fs/cgroup/"
-		}
+		cgroupRoot, _ := ctx.Value("cgroup-root").(string)
+		cgroupRoot = cgroupsv2.ResolveCGroupRoot(ctx, cgroupRoot)
 
 		log.Debugf(ctx, "get mem usage by cgroup, root path: %s", cgroupRoot)
 
-		// 检测 cgroup 版本
-		version := cgroupsv2.DetectCGroupVersion(ctx, cgroupRoot.(string))
+		// 检测 memory 所在的 cgroup 层级, hybrid 模式下 memory 通常仍在 v1 上
+		version := cgroupsv2.DetectCGroupHierarchy(ctx, cgroupRoot).ControllerVersion("memory")
 
 		switch version {
 		case cgroupsv2.CGroupV2:
@@ -57,10 +56,10 @@ func getAvailableAndTotal(ctx context.Context, burnMemMode string, includeBuffer
 			return getAvailableAndTotalV2(ctx, burnMemMode, includeBufferCache)
 		case cgroupsv2.CGroupV1:
 			log.Infof(ctx, "detected cgroup v1, using v1 memory implementation")
-			return getAvailableAndTotalV1(ctx, burnMemMode, includeBufferCache, p, cgroupRoot.(string))
+			return getAvailableAndTotalV1(ctx, burnMemMode, includeBufferCache, p, cgroupRoot)
 		default:
 			log.Warnf(ctx, "unknown cgroup version, falling back to v1 implementation")
-			return getAvailableAndTotalV1(ctx, burnMemMode, includeBufferCache, p, cgroupRoot.(string))
+			return getAvailableAndTotalV1(ctx, burnMemMode, includeBufferCache, p, cgroupRoot)
 		}
 	}
 
@@ -78,7 +77,8 @@ func getAvailableAndTotal(ctx context.Context, burnMemMode string, includeBuffer
 
 // getAvailableAndTotalV1 获取 cgroup v1 环境下的可用和总内存
 func getAvailableAndTotalV1(ctx context.Context, burnMemMode string, includeBufferCache bool, p int, cgroupRoot string) (int64, int64, error) {
-	cgroup, err := cgroups.Load(exec.Hierarchy(cgroupRoot), exec.PidPath(p))
+	path := exec.ScopedPidPath(ctx, cgroupRoot, p)
+	cgroup, err := cgroups.Load(exec.Hierarchy(cgroupRoot), path)
 	if err != nil {
 		return 0, 0, fmt.Errorf("load cgroup error, %v", err)
 	}
@@ -88,7 +88,14 @@ func getAvailableAndTotalV1(ctx context.Context, burnMemMode string, includeBuff
 	}
 	if stats != nil && stats.Memory.Usage.Limit < PageCounterMax {
 		total := int64(stats.Memory.Usage.Limit)
-		available := total - int64(stats.Memory.Usage.Usage)
+		usage := int64(stats.Memory.Usage.Usage)
+		// the usage of the limit owner doesn't include the descendants if memory.use_hierarchy is 0
+		if memoryPath, err := path(cgroups.Memory); err == nil && cgroupsv2.GetCGroupScope(ctx) == cgroupsv2.CGroupScopeLimitOwner {
+			if sum, err := cgroupsv2.MemoryUsageV1(filepath.Join(cgroupRoot, string(cgroups.Memory), memoryPath)); err == nil {
+				usage = sum
+			}
+		}
+		available := total - usage
 		if burnMemMode == "ram" && !includeBufferCache {
 			available = available + int64(stats.Memory.Cache)
 		}
@@ -107,3 +114,24 @@ func getAvailableAndTotalV1(ctx context.Context, burnMemMode string, includeBuff
 	}
 	return total, available, nil
 }
+
+// getMemoryPressure returns the memory pressure of the cgroup v2 of the target in the container mode, and the
+// pressure of the whole system otherwise or if the cgroup has no memory.pressure, such as the cgroup v1
+func getMemoryPressure(ctx context.Context) (*cgroupsv2.Pressure, string, error) {
+	if pid, ok := ctx.Value(channel.NSTargetFlagName).(string); ok && pid != "" {
+		cgroupRoot, _ := ctx.Value("cgroup-root").(string)
+		cgroupRoot = cgroupsv2.ResolveCGroupRoot(ctx, cgroupRoot)
+		if cgroupsv2.DetectCGroupHierarchy(ctx, cgroupRoot).ControllerVersion("memory") == cgroupsv2.CGroupV2 {
+			if cgroupPath, err := cgroupsv2.FindCGroupV2Path(ctx, pid, cgroupRoot); err == nil && cgroupPath != "" {
+				cgroupPath = cgroupsv2.ScopedCGroupV2Path(ctx, cgroupPath, cgroupsv2.CGroupV2MemoryController)
+				pressure, err := cgroupsv2.NewCGroupV2Impl(cgroupPath).MemoryPressure()
+				if err == nil {
+					return pressure, filepath.Join(cgroupPath, cgroupsv2.CGroupV2MemoryPressureFile), nil
+				}
+				log.Warnf(ctx, "read the memory pressure of %s failed, use the pressure of the system, %v", cgroupPath, err)
+			}
+		}
+	}
+	pressure, err := cgroupsv2.SystemMemoryPressure(ctx)
+	return pressure, "/proc/pressure/memory", err
+}
@@ -0,0 +1,259 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/shirou/gopsutil/process"
+	"golang.org/x/sys/unix"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
+)
+
+// mmapSupported is true, the anonymous mmap regions are available on the unix platforms
+const mmapSupported = true
+
+// unlockTimeout is the time which the destroy waits for the burn process to unlock and unmap the regions
+const unlockTimeout = 10 * time.Second
+
+// mmapRegions are the regions held by the mmap mode, they are released from the newest one
+type mmapRegions struct {
+	mu      sync.Mutex
+	lock    bool
+	regions [][]byte
+	held    int64
+	// limit is the soft RLIMIT_MEMLOCK of the process, unix.RLIM_INFINITY means unlimited
+	limit uint64
+}
+
+// lockError is the failure of locking the region, the growth stops at the locked bytes
+type lockError struct {
+	locked    int64
+	requested int64
+	limit     uint64
+	err       error
+}
+
+func (e *lockError) Error() string {
+	if e.limit == unix.RLIM_INFINITY {
+		return fmt.Sprintf("lock %d bytes above the locked %d bytes failed, %v", e.requested, e.locked, e.err)
+	}
+	return fmt.Sprintf("lock %d bytes above the locked %d bytes failed, the RLIMIT_MEMLOCK is %d bytes, %v",
+		e.requested, e.locked, e.limit, e.err)
+}
+
+// raiseMemlock raises the RLIMIT_MEMLOCK of the process to unlimited if it's permitted, or the soft limit to
+// the hard one, and returns the soft limit
+func raiseMemlock(ctx context.Context) (uint64, error) {
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &limit); err != nil {
+		return 0, err
+	}
+	if limit.Cur == unix.RLIM_INFINITY {
+		return limit.Cur, nil
+	}
+	unlimited := unix.Rlimit{Cur: unix.RLIM_INFINITY, Max: unix.RLIM_INFINITY}
+	if err := unix.Setrlimit(unix.RLIMIT_MEMLOCK, &unlimited); err == nil {
+		log.Infof(ctx, "raise the RLIMIT_MEMLOCK from %d bytes to unlimited", limit.Cur)
+		return unlimited.Cur, nil
+	}
+	if limit.Cur < limit.Max {
+		raised := unix.Rlimit{Cur: limit.Max, Max: limit.Max}
+		if err := unix.Setrlimit(unix.RLIMIT_MEMLOCK, &raised); err == nil {
+			log.Infof(ctx, "raise the RLIMIT_MEMLOCK from %d bytes to the hard limit %d bytes", limit.Cur, limit.Max)
+			return raised.Cur, nil
+		}
+	}
+	return limit.Cur, nil
+}
+
+// checkMemlock raises the RLIMIT_MEMLOCK and checks it against the bytes to burn, it fails before any memory
+// is allocated if the limit can't hold them
+func checkMemlock(ctx context.Context, memPercent, memReserve int, includeBufferCache bool) (uint64, *spec.Response) {
+	limit, err := raiseMemlock(ctx)
+	if err != nil {
+		return 0, exec.Fail(exec.SettingApplyFailed, "mem", "getrlimit", fmt.Sprintf("get the RLIMIT_MEMLOCK failed, %v", err))
+	}
+	if limit == unix.RLIM_INFINITY {
+		return limit, nil
+	}
+	_, expectMem, err := calculateMemSize(ctx, "ram", memPercent, memReserve, includeBufferCache)
+	if err != nil {
+		return 0, exec.Fail(exec.SettingApplyFailed, "mem", "calculate", fmt.Sprintf("calculate memsize err, %v", err))
+	}
+	if requested := expectMem * validation.MB; requested > int64(limit) {
+		return 0, exec.Fail(exec.SettingApplyFailed, "mem", "setrlimit",
+			fmt.Sprintf("the RLIMIT_MEMLOCK of the chaos process is %d bytes and can't be raised, but %d bytes are requested to lock, "+
+				"raise it by ulimit -l or run with CAP_IPC_LOCK", limit, requested))
+	}
+	return limit, nil
+}
+
+// grow maps and populates the region of the size, the region is locked in the lock mode
+func (m *mmapRegions) grow(size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	region, err := unix.Mmap(-1, 0, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return fmt.Errorf("mmap %d bytes failed, %v", size, err)
+	}
+	if m.lock {
+		// mlock populates the region
+		if err := unix.Mlock(region); err != nil {
+			unix.Munmap(region)
+			return &lockError{locked: m.held, requested: size, limit: m.limit, err: err}
+		}
+	} else {
+		step := os.Getpagesize()
+		for i := 0; i < len(region); i += step {
+			region[i] = 1
+		}
+	}
+	m.regions = append(m.regions, region)
+	m.held += size
+	return nil
+}
+
+// release unlocks and unmaps the regions from the newest one until the size is released
+func (m *mmapRegions) release(size int64) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var released int64
+	for released < size && len(m.regions) > 0 {
+		last := len(m.regions) - 1
+		released += int64(len(m.regions[last]))
+		m.unmap(m.regions[last])
+		m.regions = m.regions[:last]
+	}
+	m.held -= released
+	return released
+}
+
+// unmap unlocks the region before unmapping it
+func (m *mmapRegions) unmap(region []byte) {
+	if m.lock {
+		if err := unix.Munlock(region); err != nil {
+			log.Warnf(context.Background(), "munlock %d bytes failed, %v", len(region), err)
+		}
+	}
+	if err := unix.Munmap(region); err != nil {
+		log.Warnf(context.Background(), "munmap %d bytes failed, %v", len(region), err)
+	}
+}
+
+// releaseOnSignal releases all the regions and exits if the process is terminated by the destroy
+func (m *mmapRegions) releaseOnSignal(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-signals
+		m.mu.Lock()
+		held := m.held
+		m.mu.Unlock()
+		log.Infof(ctx, "release %d bytes held by the mmap mode on %v", m.release(held), sig)
+		os.Exit(0)
+	}()
+}
+
+func burnMemWithMmap(ctx context.Context, memPercent, memReserve, memRate int, includeBufferCache, lock bool, limit uint64, guard *psiGuard) {
+	regions := &mmapRegions{lock: lock, limit: limit}
+	regions.releaseOnSignal(ctx)
+	if memRate <= 0 {
+		memRate = 100
+	}
+	uid, _ := ctx.Value(spec.Uid).(string)
+	metrics := exec.NewMetricsWriter(ctx, uid, "mem", "load", "")
+	// stopped is set by the lock failure, the growth stops at the locked bytes
+	stopped := false
+	tick := time.Tick(time.Second)
+	for range tick {
+		_, expectMem, err := calculateMemSize(ctx, "ram", memPercent, memReserve, includeBufferCache)
+		if err != nil {
+			metrics.Set("bytes_held", float64(regions.held))
+			metrics.SetError(err)
+			metrics.Flush(ctx)
+			log.Fatalf(ctx, "calculate memsize err, %v", err.Error())
+		}
+		if lock {
+			metrics.Set("bytes_locked", float64(regions.held))
+		}
+		setHeldMetrics(ctx, metrics, regions.held, expectMem, guard)
+		if guard.check(ctx, regions.held, regions.release) || stopped {
+			continue
+		}
+		fillMem := expectMem
+		if expectMem <= 0 {
+			continue
+		}
+		if expectMem > int64(memRate) {
+			fillMem = int64(memRate)
+		} else if fillMem = expectMem / 10; fillMem == 0 {
+			continue
+		}
+		if err := regions.grow(fillMem * validation.MB); err != nil {
+			if _, ok := err.(*lockError); !ok {
+				log.Fatalf(ctx, "burn mem with mmap err, %v", err)
+			}
+			stopped = true
+			log.Errorf(ctx, "stop the growth at the locked %d bytes, %v", regions.held, err)
+			metrics.Set("lock_failed", 1)
+			metrics.SetError(err)
+			metrics.Flush(ctx)
+		}
+	}
+}
+
+// unlockBurn terminates the burn process of the mmap mode, so it unlocks and unmaps the regions before the
+// memory is freed, the process which doesn't exit in time is killed by the destroy
+func unlockBurn(ctx context.Context, uid string) {
+	processes, err := exec.ListChaosProcesses(ctx, []string{BurnMemBin})
+	if err != nil {
+		log.Warnf(ctx, "list the chaos processes failed, the locked memory is freed by the kill, %v", err)
+		return
+	}
+	pids := make([]int32, 0)
+	for _, p := range processes {
+		if p.Uid == uid {
+			if err := syscall.Kill(int(p.Pid), syscall.SIGTERM); err != nil {
+				log.Warnf(ctx, "terminate the mem burn %d failed, %v", p.Pid, err)
+				continue
+			}
+			pids = append(pids, p.Pid)
+		}
+	}
+	deadline := time.Now().Add(unlockTimeout)
+	for _, pid := range pids {
+		for time.Now().Before(deadline) {
+			if exists, err := process.PidExists(pid); err != nil || !exists {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mem
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestMmapRegions(t *testing.T) {
+	regions := &mmapRegions{}
+	for i := 0; i < 3; i++ {
+		if err := regions.grow(1 << 20); err != nil {
+			t.Fatalf("grow failed, %v", err)
+		}
+	}
+	if regions.held != 3<<20 || len(regions.regions) != 3 {
+		t.Fatalf("expected 3 regions of 3M, got %d regions of %d bytes", len(regions.regions), regions.held)
+	}
+	// the newest regions are released until the size is reached
+	if released := regions.release(1<<20 + 1); released != 2<<20 {
+		t.Errorf("expected to release 2M, got %d", released)
+	}
+	if regions.held != 1<<20 || len(regions.regions) != 1 {
+		t.Errorf("expected 1 region of 1M left, got %d regions of %d bytes", len(regions.regions), regions.held)
+	}
+	regions.release(regions.held)
+	if regions.held != 0 || len(regions.regions) != 0 {
+		t.Errorf("expected all the regions released, got %d regions of %d bytes", len(regions.regions), regions.held)
+	}
+}
+
+func TestLockError(t *testing.T) {
+	err := &lockError{locked: 1 << 20, requested: 2 << 20, limit: 8 << 20, err: syscall.ENOMEM}
+	if !strings.Contains(err.Error(), "the RLIMIT_MEMLOCK is 8388608 bytes") {
+		t.Errorf("unexpected error %v", err)
+	}
+	err.limit = unix.RLIM_INFINITY
+	if strings.Contains(err.Error(), "RLIMIT_MEMLOCK") {
+		t.Errorf("the unlimited RLIMIT_MEMLOCK is reported, %v", err)
+	}
+}
+
+func TestHeldDetail(t *testing.T) {
+	detail := heldDetail(map[string]float64{"bytes_held": 100, "target_bytes": 200, "bytes_locked": 100, "lock_failed": 1, "psi_backoffs": 2})
+	expect := "held 100 bytes of the target 200 bytes, 100 bytes locked, the growth is stopped by the lock failure, backed off 2 times by the memory pressure"
+	if detail != expect {
+		t.Errorf("expected %q, got %q", expect, detail)
+	}
+	if detail := heldDetail(map[string]float64{}); detail != "" {
+		t.Errorf("expected no detail without the metrics, got %q", detail)
+	}
+}
@@ -0,0 +1,340 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mem
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
+)
+
+const PageCacheFillBin = "chaos_pagecachefill"
+
+// defaultCacheDir is on the disk usually, the files on the tmpfs of /tmp are the shared memory instead of the
+// page cache which can be reclaimed
+const defaultCacheDir = "/var/tmp"
+
+// pageCacheFileSize is the size of a file which is read into the page cache
+const pageCacheFileSize = 1 * validation.GB
+
+// pageCacheReserve is the space of the filesystem which the files never take
+const pageCacheReserve = 1 * validation.GB
+
+type PageCacheFillActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewPageCacheFillActionCommandSpec() spec.ExpActionCommandSpec {
+	return &PageCacheFillActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     "percent",
+					Desc:     "The percent of the page cache in the memory (0-100), it's the Cached and Buffers of /proc/meminfo, or the file pages of the cgroup of the target",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name: "cache-dir",
+					Desc: fmt.Sprintf("The directory where the files read into the page cache are created, it must not be on the tmpfs, default value is %s", defaultCacheDir),
+				},
+				&spec.ExpFlag{
+					Name: "readahead",
+					Desc: "The window of the readahead which the files are read into the page cache by, such as 8M, the files are read by the buffered io in 1M if it's not set",
+				},
+				&spec.ExpFlag{
+					Name:   "drop-cache",
+					Desc:   "Drop the page cache of the files by posix_fadvise DONTNEED when the experiment is destroyed",
+					NoArgs: true,
+				},
+				&spec.ExpFlag{
+					Name:     "cgroup-root",
+					Desc:     "cgroup root path, such as the mount of the host cgroupfs /host-sys/fs/cgroup in the container, it's detected from the cgroup mounts if absent",
+					NoArgs:   false,
+					Required: false,
+					Default:  "",
+				},
+				&spec.ExpFlag{
+					Name:     cgroups.HostProcKey,
+					Desc:     "the mount point of the host proc filesystem, such as /host/proc, which the cgroup of the host pid is read from when running in the container",
+					NoArgs:   false,
+					Required: false,
+					Default:  "",
+				},
+			},
+			ActionExecutor: &pageCacheFillExecutor{},
+			ActionExample: `
+# Fill the page cache to 90% of the memory
+blade create mem page-cache-fill --percent 90
+
+# Fill the page cache by the files under /data with the readahead of 8M, and drop their cache when destroyed
+blade create mem page-cache-fill --percent 80 --cache-dir /data --readahead 8M --drop-cache`,
+			ActionPrograms:    []string{PageCacheFillBin},
+			ActionCategories:  []string{category.SystemMem},
+			ActionProcessHang: true,
+		},
+	}
+}
+
+func (*PageCacheFillActionCommandSpec) Name() string {
+	return "page-cache-fill"
+}
+
+func (*PageCacheFillActionCommandSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*PageCacheFillActionCommandSpec) ShortDesc() string {
+	return "Fill the page cache"
+}
+
+func (p *PageCacheFillActionCommandSpec) LongDesc() string {
+	if p.ActionLongDesc != "" {
+		return p.ActionLongDesc
+	}
+	return "Read the files created under the cache directory by the buffered io until the page cache reaches the percent " +
+		"of the memory, and read them again when the kernel evicts them, the anonymous memory isn't burned. " +
+		"The files are deleted when the experiment is destroyed"
+}
+
+type pageCacheFillExecutor struct {
+	channel spec.Channel
+}
+
+func (*pageCacheFillExecutor) Name() string {
+	return "page-cache-fill"
+}
+
+func (pe *pageCacheFillExecutor) SetChannel(channel spec.Channel) {
+	pe.channel = channel
+}
+
+func (pe *pageCacheFillExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	cacheDir := model.ActionFlags["cache-dir"]
+	if cacheDir == "" {
+		cacheDir = defaultCacheDir
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return pe.stop(ctx, uid, cacheDir, model.ActionFlags["drop-cache"] == "true")
+	}
+	if !pageCacheSupported {
+		return exec.Fail(exec.UnsupportedPlatform, "page-cache", "fill", "the page cache fill is only supported on Linux")
+	}
+	percentStr := model.ActionFlags["percent"]
+	if percentStr == "" {
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "percent")
+	}
+	percent, response := validation.ValidatePercent("percent", percentStr)
+	if response != nil {
+		log.Errorf(ctx, "`%s`: percent is illegal, %s", percentStr, response.Err)
+		return response
+	}
+	var readahead int64
+	if value := model.ActionFlags["readahead"]; value != "" {
+		if readahead, response = validation.ValidateSizeBytes("readahead", value, validation.KB, 4*validation.KB); response != nil {
+			log.Errorf(ctx, "`%s`: readahead is illegal, %s", value, response.Err)
+			return response
+		}
+	}
+	if info, err := os.Stat(cacheDir); err != nil || !info.IsDir() {
+		log.Errorf(ctx, "`%s`: cache-dir is illegal, is not a directory", cacheDir)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "cache-dir", cacheDir, "it must be a directory")
+	}
+	if mount, ok := exec.GetMount(ctx, pe.channel, cacheDir); ok && (mount.FsType == "tmpfs" || mount.FsType == "ramfs") {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "cache-dir", cacheDir,
+			fmt.Sprintf("it's on the %s %s, whose files are the shared memory instead of the page cache", mount.FsType, mount.MountPoint))
+	}
+	ctx = context.WithValue(ctx, "cgroup-root", model.ActionFlags["cgroup-root"])
+	ctx = cgroups.WithHostProc(ctx, model.ActionFlags[cgroups.HostProcKey])
+	return pe.start(ctx, uid, cacheDir, percent, readahead)
+}
+
+// pageCacheFiles returns the files of the experiment under the cache directory
+func pageCacheFiles(uid, cacheDir string) []string {
+	files, _ := filepath.Glob(filepath.Join(cacheDir, fmt.Sprintf("chaos_pagecache.%s.*", uid)))
+	return files
+}
+
+// start creates the files until their size covers the target above the page cache at the start, and reads them
+// every second if the page cache is below the target, so the pages evicted by the kernel are read again
+func (pe *pageCacheFillExecutor) start(ctx context.Context, uid, cacheDir string, percent int, readahead int64) *spec.Response {
+	total, baseline, err := getPageCache(ctx)
+	if err != nil {
+		return exec.Fail(exec.SettingApplyFailed, "page-cache", "meminfo", fmt.Sprintf("get the page cache failed, %v", err))
+	}
+	target := total * int64(percent) / 100
+	log.Infof(ctx, "fill the page cache from %d to %d of %d bytes by the files under %s", baseline, target, total, cacheDir)
+	metrics := exec.NewMetricsWriter(ctx, uid, "mem", "page-cache-fill", "")
+	files := make([]string, 0)
+	var filesSize int64
+	warned := false
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return spec.ReturnSuccess(uid)
+		}
+		_, cached, err := getPageCache(ctx)
+		if err != nil {
+			metrics.SetError(err)
+			metrics.Flush(ctx)
+			log.Fatalf(ctx, "get the page cache failed, %v", err)
+		}
+		metrics.Set("cached_bytes", float64(cached))
+		metrics.Set("target_bytes", float64(target))
+		metrics.Set("files_bytes", float64(filesSize))
+		metrics.Flush(ctx)
+		if cached >= target {
+			continue
+		}
+		// the files cover the target above the page cache which isn't of the experiment
+		if filesSize < target-baseline {
+			size := pageCacheFileSize
+			if remaining := target - baseline - filesSize; remaining < size {
+				size = remaining
+			}
+			if available, err := availableSpace(cacheDir); err != nil || available-size < pageCacheReserve {
+				if !warned {
+					log.Warnf(ctx, "the space of %s isn't enough for the next %d bytes, keep the page cache of the %d bytes files, %v",
+						cacheDir, size, filesSize, err)
+					warned = true
+				}
+			} else {
+				file := filepath.Join(cacheDir, fmt.Sprintf("chaos_pagecache.%s.%d", uid, len(files)))
+				if err := createPageCacheFile(file, size); err != nil {
+					os.Remove(file)
+					metrics.SetError(err)
+					metrics.Flush(ctx)
+					log.Fatalf(ctx, "create %s failed, %v", file, err)
+				}
+				files = append(files, file)
+				filesSize += size
+				continue
+			}
+		}
+		for _, file := range files {
+			if err := readIntoPageCache(file, readahead); err != nil {
+				log.Warnf(ctx, "read %s into the page cache failed, %v", file, err)
+			}
+		}
+	}
+}
+
+// stop kills the fill, drops the page cache of the files if it's required and deletes them
+func (pe *pageCacheFillExecutor) stop(ctx context.Context, uid, cacheDir string, dropCache bool) *spec.Response {
+	ctx = context.WithValue(ctx, "bin", PageCacheFillBin)
+	if response := exec.Destroy(ctx, pe.channel, "mem page-cache-fill"); !response.Success {
+		return response
+	}
+	for _, file := range pageCacheFiles(uid, cacheDir) {
+		if dropCache {
+			if err := dropPageCache(file); err != nil {
+				log.Warnf(ctx, "drop the page cache of %s failed, %v", file, err)
+			}
+		}
+		if err := os.Remove(file); err != nil {
+			log.Errorf(ctx, "delete %s failed, %v", file, err)
+			return exec.FailWithFlags(exec.CommandFailed, "page-cache", "delete", err)
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+// createPageCacheFile writes the file by the buffered io, it's synced, so the pages are clean and can be evicted
+func createPageCacheFile(file string, size int64) error {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := make([]byte, validation.MB)
+	for written := int64(0); written < size; {
+		n := int64(len(buf))
+		if size-written < n {
+			n = size - written
+		}
+		if _, err := f.Write(buf[:n]); err != nil {
+			return err
+		}
+		written += n
+	}
+	return f.Sync()
+}
+
+// readIntoPageCache reads the file by the buffered io, or by the readahead of the window
+func readIntoPageCache(file string, readahead int64) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if readahead > 0 {
+		return readaheadFile(f, readahead)
+	}
+	buf := make([]byte, validation.MB)
+	for {
+		if _, err := f.Read(buf); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// pageCacheDetail describes the page cache against the target of the metrics
+func pageCacheDetail(values map[string]float64) string {
+	cached, ok := values["cached_bytes"]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("page cache %.0f bytes of the target %.0f bytes, the files are %.0f bytes",
+		cached, values["target_bytes"], values["files_bytes"])
+}
+
+// Status checks the fill process and the files, and the page cache against the target
+func (pe *pageCacheFillExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	report.Add(exec.ProcessArtifact(ctx, uid, model.Target, model.ActionName))
+	cacheDir := model.ActionFlags["cache-dir"]
+	if cacheDir == "" {
+		cacheDir = defaultCacheDir
+	}
+	for _, file := range pageCacheFiles(uid, cacheDir) {
+		report.Add(exec.Artifact{Kind: "file", Name: file, Present: true})
+	}
+	report.AddMetrics()
+	for _, snapshot := range report.Metrics {
+		if detail := pageCacheDetail(snapshot.Values); detail != "" {
+			report.Add(exec.Artifact{Kind: "page-cache", Name: "cached", Present: true, Detail: detail})
+		}
+	}
+	return report
+}
@@ -0,0 +1,47 @@
+//go:build darwin
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mem
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+// pageCacheSupported is false, the page cache of darwin is the unified buffer cache which posix_fadvise isn't
+// available for
+const pageCacheSupported = false
+
+var errPageCacheUnsupported = errors.New("the page cache fill is only supported on Linux")
+
+func getPageCache(ctx context.Context) (int64, int64, error) {
+	return 0, 0, errPageCacheUnsupported
+}
+
+func readaheadFile(f *os.File, window int64) error {
+	return errPageCacheUnsupported
+}
+
+func dropPageCache(file string) error {
+	return errPageCacheUnsupported
+}
+
+func availableSpace(dir string) (int64, error) {
+	return 0, errPageCacheUnsupported
+}
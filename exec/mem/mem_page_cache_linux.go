@@ -0,0 +1,137 @@
+//go:build linux
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/containerd/cgroups"
+	"github.com/shirou/gopsutil/mem"
+	"golang.org/x/sys/unix"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	cgroupsv2 "github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
+)
+
+const pageCacheSupported = true
+
+// getPageCache returns the memory and the page cache of the cgroup of the target in the container mode if it's
+// limited, and of the whole system otherwise
+func getPageCache(ctx context.Context) (int64, int64, error) {
+	if pid, ok := ctx.Value(channel.NSTargetFlagName).(string); ok && pid != "" {
+		p, err := strconv.Atoi(pid)
+		if err != nil {
+			return 0, 0, fmt.Errorf("load cgroup error, %v", err)
+		}
+		cgroupRoot, _ := ctx.Value("cgroup-root").(string)
+		cgroupRoot = cgroupsv2.ResolveCGroupRoot(ctx, cgroupRoot)
+		if cgroupsv2.DetectCGroupHierarchy(ctx, cgroupRoot).ControllerVersion("memory") == cgroupsv2.CGroupV2 {
+			if total, cached, ok := getPageCacheV2(ctx, pid, cgroupRoot); ok {
+				return total, cached, nil
+			}
+		} else if total, cached, ok := getPageCacheV1(ctx, p, cgroupRoot); ok {
+			return total, cached, nil
+		}
+		log.Debugf(ctx, "the memory of the cgroup of %s isn't limited, use the page cache of the system", pid)
+	}
+	virtualMemory, err := mem.VirtualMemory()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(virtualMemory.Total), int64(virtualMemory.Buffers + virtualMemory.Cached), nil
+}
+
+// getPageCacheV2 returns the ceiling of the memory and the file pages of memory.stat
+func getPageCacheV2(ctx context.Context, pid, cgroupRoot string) (int64, int64, bool) {
+	cgroupPath, err := cgroupsv2.FindCGroupV2Path(ctx, pid, cgroupRoot)
+	if err != nil || cgroupPath == "" {
+		return 0, 0, false
+	}
+	cgroupPath = cgroupsv2.ScopedCGroupV2Path(ctx, cgroupPath, cgroupsv2.CGroupV2MemoryController)
+	limits, err := cgroupsv2.NewCGroupV2Impl(cgroupPath).MemoryLimits()
+	if err != nil {
+		return 0, 0, false
+	}
+	limit, _, defined := limits.Ceiling()
+	if !defined || limit == 0 {
+		return 0, 0, false
+	}
+	cached, err := getCGroupV2MemoryCache(ctx, cgroupPath)
+	if err != nil {
+		log.Warnf(ctx, "get the page cache of %s failed, %v", cgroupPath, err)
+		return 0, 0, false
+	}
+	return limit, cached, true
+}
+
+// getPageCacheV1 returns the limit of the memory and the cache of memory.stat
+func getPageCacheV1(ctx context.Context, p int, cgroupRoot string) (int64, int64, bool) {
+	cgroup, err := cgroups.Load(exec.Hierarchy(cgroupRoot), exec.ScopedPidPath(ctx, cgroupRoot, p))
+	if err != nil {
+		return 0, 0, false
+	}
+	stats, err := cgroup.Stat(cgroups.IgnoreNotExist)
+	if err != nil || stats == nil || stats.Memory == nil || stats.Memory.Usage == nil || stats.Memory.Usage.Limit >= PageCounterMax {
+		return 0, 0, false
+	}
+	return int64(stats.Memory.Usage.Limit), int64(stats.Memory.Cache), true
+}
+
+// readaheadFile asks the kernel to read the file into the page cache window by window, the file is accessed
+// sequentially, so the readahead isn't shrunk
+func readaheadFile(f *os.File, window int64) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	fd := int(f.Fd())
+	if err := unix.Fadvise(fd, 0, 0, unix.FADV_SEQUENTIAL); err != nil {
+		return err
+	}
+	for offset := int64(0); offset < info.Size(); offset += window {
+		if err := unix.Fadvise(fd, offset, window, unix.FADV_WILLNEED); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropPageCache evicts the clean pages of the file from the page cache
+func dropPageCache(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED)
+}
+
+// availableSpace returns the bytes of the filesystem of the directory which the unprivileged user can write
+func availableSpace(dir string) (int64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,64 @@
+//go:build linux
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPageCacheFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "chaos_pagecache.uid.0")
+	if err := createPageCacheFile(file, 3<<20+5); err != nil {
+		t.Fatalf("create the file failed, %v", err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Size() != 3<<20+5 {
+		t.Fatalf("expected the file of %d bytes, got %v, %v", 3<<20+5, info, err)
+	}
+	// the file of the experiment isn't overwritten
+	if err := createPageCacheFile(file, 1); err == nil {
+		t.Errorf("expected the existing file to be refused")
+	}
+	for _, readahead := range []int64{0, 1 << 20} {
+		if err := readIntoPageCache(file, readahead); err != nil {
+			t.Errorf("read the file with the readahead %d failed, %v", readahead, err)
+		}
+	}
+	if err := dropPageCache(file); err != nil {
+		t.Errorf("drop the page cache failed, %v", err)
+	}
+	if files := pageCacheFiles("uid", dir); len(files) != 1 || files[0] != file {
+		t.Errorf("expected the files [%s], got %v", file, files)
+	}
+	if files := pageCacheFiles("other", dir); len(files) != 0 {
+		t.Errorf("expected no file of the other experiment, got %v", files)
+	}
+}
+
+func TestPageCacheDetail(t *testing.T) {
+	if detail := pageCacheDetail(map[string]float64{}); detail != "" {
+		t.Errorf("expected no detail before the first flush, got %s", detail)
+	}
+	detail := pageCacheDetail(map[string]float64{"cached_bytes": 100, "target_bytes": 200, "files_bytes": 50})
+	if detail != "page cache 100 bytes of the target 200 bytes, the files are 50 bytes" {
+		t.Errorf("unexpected detail %s", detail)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mem
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
+)
+
+// the flags of the node-safety mode which backs off the burn if the memory pressure is too high
+const (
+	noPsiGuardFlag   = "no-psi-guard"
+	psiThresholdFlag = "psi-threshold"
+	psiGraceFlag     = "psi-grace"
+	psiReleaseFlag   = "psi-release"
+)
+
+// the defaults of the node-safety mode, the full avg10 above 10% means all the tasks are stalled on the memory
+// for a second every ten seconds, which is the start of the reclaim death spiral
+const (
+	defaultPsiThreshold = 10
+	defaultPsiGrace     = 10 * time.Second
+	defaultPsiRelease   = 25
+)
+
+func psiGuardFlags() []spec.ExpFlagSpec {
+	return []spec.ExpFlagSpec{
+		&spec.ExpFlag{
+			Name:   noPsiGuardFlag,
+			Desc:   "disable the node-safety mode, which releases a part of the held memory and pauses the growth if the full avg10 of the memory pressure stays above psi-threshold",
+			NoArgs: true,
+		},
+		&spec.ExpFlag{
+			Name:     psiThresholdFlag,
+			Desc:     fmt.Sprintf("the full avg10 percent of the memory pressure which the burn backs off above, default %d", defaultPsiThreshold),
+			Required: false,
+		},
+		&spec.ExpFlag{
+			Name:     psiGraceFlag,
+			Desc:     fmt.Sprintf("the duration which the pressure stays above psi-threshold before the back off, such as 10s, default %v", defaultPsiGrace),
+			Required: false,
+		},
+		&spec.ExpFlag{
+			Name:     psiReleaseFlag,
+			Desc:     fmt.Sprintf("the percent of the held memory which is released on the back off, default %d", defaultPsiRelease),
+			Required: false,
+		},
+	}
+}
+
+// psiGuard backs off the burn if the full avg10 of the memory pressure stays above the threshold for the grace
+// period, a part of the held memory is released and the growth is paused until the pressure subsides. The
+// memory is released again if the pressure stays high for another grace period.
+type psiGuard struct {
+	threshold float64
+	grace     time.Duration
+	release   int
+	// since is the time which the pressure exceeds the threshold from, or since the last back off
+	since    time.Time
+	paused   bool
+	backoffs int
+}
+
+// newPsiGuard parses the flags of the node-safety mode, the guard is nil if it's disabled
+func newPsiGuard(flags map[string]string) (*psiGuard, *spec.Response) {
+	if flags[noPsiGuardFlag] == "true" {
+		return nil, nil
+	}
+	guard := &psiGuard{threshold: defaultPsiThreshold, grace: defaultPsiGrace, release: defaultPsiRelease}
+	var response *spec.Response
+	if value := flags[psiThresholdFlag]; value != "" {
+		if guard.threshold, response = validation.ValidateDecimalPercent(psiThresholdFlag, value); response != nil {
+			return nil, response
+		}
+	}
+	if value := flags[psiGraceFlag]; value != "" {
+		if guard.grace, response = validation.ValidateDuration(psiGraceFlag, value, 0); response != nil {
+			return nil, response
+		}
+	}
+	if value := flags[psiReleaseFlag]; value != "" {
+		if guard.release, response = validation.ValidateInt(psiReleaseFlag, value, 1, 100); response != nil {
+			return nil, response
+		}
+	}
+	return guard, nil
+}
+
+// observe records the full avg10 at now, it returns true if the held memory should be released
+func (g *psiGuard) observe(now time.Time, fullAvg10 float64) bool {
+	if fullAvg10 <= g.threshold {
+		g.since, g.paused = time.Time{}, false
+		return false
+	}
+	if g.since.IsZero() {
+		g.since = now
+	}
+	if now.Sub(g.since) < g.grace {
+		return false
+	}
+	g.since, g.paused = now, true
+	g.backoffs++
+	return true
+}
+
+// releaseSize returns the bytes to release of the held ones
+func (g *psiGuard) releaseSize(held int64) int64 {
+	return held * int64(g.release) / 100
+}
+
+// check samples the memory pressure and backs off the burn by the release function, which releases at least
+// the bytes and returns the released ones. It returns true if the growth is paused. The guard keeps silent if
+// the pressure isn't available, such as the kernels without the pressure stall information.
+func (g *psiGuard) check(ctx context.Context, held int64, release func(int64) int64) bool {
+	if g == nil {
+		return false
+	}
+	pressure, source, err := getMemoryPressure(ctx)
+	if err != nil {
+		if !cgroups.IsNotAvailable(err) {
+			log.Warnf(ctx, "read the memory pressure failed, %v", err)
+		}
+		return g.paused
+	}
+	if !pressure.HasFull {
+		return g.paused
+	}
+	paused := g.paused
+	if g.observe(time.Now(), pressure.Full.Avg10) {
+		released := release(g.releaseSize(held))
+		log.Warnf(ctx, "the full avg10 of %s is %.2f%% above %.2f%% for %v, release %d of %d bytes and pause the burn, back off %d times",
+			source, pressure.Full.Avg10, g.threshold, g.grace, released, held, g.backoffs)
+	} else if paused && !g.paused {
+		log.Infof(ctx, "the full avg10 of %s is %.2f%% not above %.2f%%, resume the burn", source, pressure.Full.Avg10, g.threshold)
+	}
+	return g.paused
+}
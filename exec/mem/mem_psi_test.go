@@ -0,0 +1,72 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mem
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPsiGuardObserve(t *testing.T) {
+	guard, response := newPsiGuard(map[string]string{psiThresholdFlag: "10", psiGraceFlag: "5s", psiReleaseFlag: "50"})
+	if response != nil {
+		t.Fatalf("unexpected response %v", response)
+	}
+	start := time.Now()
+	steps := []struct {
+		offset   time.Duration
+		avg10    float64
+		release  bool
+		paused   bool
+		backoffs int
+	}{
+		{0, 20, false, false, 0},
+		// the pressure subsides in the grace period
+		{3 * time.Second, 5, false, false, 0},
+		{4 * time.Second, 20, false, false, 0},
+		{9 * time.Second, 20, true, true, 1},
+		// the growth is paused until the pressure subsides, and released again after another grace period
+		{10 * time.Second, 20, false, true, 1},
+		{14 * time.Second, 20, true, true, 2},
+		{15 * time.Second, 10, false, false, 2},
+	}
+	for _, step := range steps {
+		if release := guard.observe(start.Add(step.offset), step.avg10); release != step.release ||
+			guard.paused != step.paused || guard.backoffs != step.backoffs {
+			t.Errorf("at %v: expected %v %v %d, got %v %v %d", step.offset, step.release, step.paused, step.backoffs,
+				release, guard.paused, guard.backoffs)
+		}
+	}
+	if size := guard.releaseSize(1000); size != 500 {
+		t.Errorf("expected to release 500 bytes, got %d", size)
+	}
+}
+
+func TestNewPsiGuard(t *testing.T) {
+	if guard, response := newPsiGuard(map[string]string{noPsiGuardFlag: "true"}); guard != nil || response != nil {
+		t.Errorf("expected the guard disabled, got %v %v", guard, response)
+	}
+	guard, response := newPsiGuard(map[string]string{})
+	if response != nil || guard.threshold != defaultPsiThreshold || guard.grace != defaultPsiGrace || guard.release != defaultPsiRelease {
+		t.Errorf("expected the default guard, got %+v %v", guard, response)
+	}
+	for flag, value := range map[string]string{psiThresholdFlag: "101", psiGraceFlag: "-1s", psiReleaseFlag: "0"} {
+		if _, response := newPsiGuard(map[string]string{flag: value}); response == nil {
+			t.Errorf("expected %s=%s illegal", flag, value)
+		}
+	}
+}
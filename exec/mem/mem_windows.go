@@ -0,0 +1,54 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mem
+
+import (
+	"context"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/shirou/gopsutil/mem"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
+)
+
+// mmapSupported is false, the mmap mode is refused before the burn on Windows
+const mmapSupported = false
+
+func getAvailableAndTotal(ctx context.Context, burnMemMode string, includeBufferCache bool) (int64, int64, error) {
+	virtualMemory, err := mem.VirtualMemory()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(virtualMemory.Total), int64(virtualMemory.Available), nil
+}
+
+// getMemoryPressure returns NotAvailableError, the pressure stall information is only available on Linux
+func getMemoryPressure(ctx context.Context) (*cgroups.Pressure, string, error) {
+	pressure, err := cgroups.SystemMemoryPressure(ctx)
+	return pressure, "", err
+}
+
+func checkMemlock(ctx context.Context, memPercent, memReserve int, includeBufferCache bool) (uint64, *spec.Response) {
+	return 0, exec.Fail(exec.UnsupportedPlatform, "mem", "lock", "locking the burned memory is not supported on Windows")
+}
+
+func burnMemWithMmap(ctx context.Context, memPercent, memReserve, memRate int, includeBufferCache, lock bool, limit uint64, guard *psiGuard) {
+}
+
+func unlockBurn(ctx context.Context, uid string) {
+}
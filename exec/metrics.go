@@ -0,0 +1,237 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+)
+
+// MetricsDir is the directory of the metrics snapshots of the running experiments
+var MetricsDir = "/var/run/chaosblade/metrics"
+
+// MetricsDirKey is the context key of the Prometheus textfile directory, the snapshot is written there too
+const MetricsDirKey = "metrics-dir"
+
+// MetricsInterval is the minimum interval between two writes of the snapshot
+var MetricsInterval = 5 * time.Second
+
+// MetricsSnapshot is the latest metrics of a chaos process. The counters are accumulated since the start,
+// and their rates per second in the last interval are in the values with the _per_second suffix.
+type MetricsSnapshot struct {
+	Uid        string             `json:"uid"`
+	Target     string             `json:"target"`
+	Action     string             `json:"action"`
+	Instance   string             `json:"instance,omitempty"`
+	Pid        int                `json:"pid"`
+	UpdateTime time.Time          `json:"updateTime"`
+	Values     map[string]float64 `json:"values"`
+	LastError  string             `json:"lastError,omitempty"`
+	// Textfile is the Prometheus textfile of the snapshot, it's removed with the snapshot
+	Textfile string `json:"textfile,omitempty"`
+}
+
+// MetricsWriter collects the metrics of the long-running experiment and writes the snapshot periodically,
+// the methods of the nil writer do nothing
+type MetricsWriter struct {
+	lock        sync.Mutex
+	snapshot    MetricsSnapshot
+	counters    map[string]float64
+	lastCounted map[string]float64
+	lastWrite   time.Time
+	textfileDir string
+}
+
+// NewMetricsWriter creates the writer of the experiment, the instance distinguishes the processes of
+// the same experiment, such as the burning process of each core, it's empty for the single process.
+func NewMetricsWriter(ctx context.Context, uid, target, action, instance string) *MetricsWriter {
+	textfileDir, _ := ctx.Value(MetricsDirKey).(string)
+	return &MetricsWriter{
+		snapshot: MetricsSnapshot{
+			Uid:      uid,
+			Target:   target,
+			Action:   action,
+			Instance: instance,
+			Pid:      os.Getpid(),
+			Values:   make(map[string]float64),
+		},
+		counters:    make(map[string]float64),
+		lastCounted: make(map[string]float64),
+		textfileDir: textfileDir,
+	}
+}
+
+// Set sets the gauge, such as the achieved cpu percent
+func (w *MetricsWriter) Set(name string, value float64) {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.snapshot.Values[name] = value
+}
+
+// Add increases the counter, such as the appends performed
+func (w *MetricsWriter) Add(name string, delta float64) {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.counters[name] += delta
+}
+
+// SetError records the last error of the experiment
+func (w *MetricsWriter) SetError(err error) {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err != nil {
+		w.snapshot.LastError = err.Error()
+	}
+}
+
+// Flush writes the snapshot if the interval has elapsed since the last write, so it can be invoked in
+// the loop of the experiment directly
+func (w *MetricsWriter) Flush(ctx context.Context) {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	now := time.Now()
+	if !w.lastWrite.IsZero() && now.Sub(w.lastWrite) < MetricsInterval {
+		return
+	}
+	elapsed := now.Sub(w.lastWrite).Seconds()
+	for name, value := range w.counters {
+		w.snapshot.Values[name] = value
+		if !w.lastWrite.IsZero() && elapsed > 0 {
+			w.snapshot.Values[name+"_per_second"] = (value - w.lastCounted[name]) / elapsed
+		}
+		w.lastCounted[name] = value
+	}
+	w.lastWrite = now
+	w.snapshot.UpdateTime = now
+	if w.textfileDir != "" {
+		w.snapshot.Textfile = filepath.Join(w.textfileDir, fmt.Sprintf("chaosblade_%s.prom", w.snapshot.name()))
+		if err := writeFileAtomic(w.snapshot.Textfile, []byte(w.snapshot.prometheus()), 0644); err != nil {
+			log.Warnf(ctx, "write the metrics textfile %s failed, %v", w.snapshot.Textfile, err)
+		}
+	}
+	bytes, err := json.Marshal(w.snapshot)
+	if err != nil {
+		log.Warnf(ctx, "marshal the metrics of %s failed, %v", w.snapshot.Uid, err)
+		return
+	}
+	if err := os.MkdirAll(MetricsDir, 0755); err != nil {
+		log.Warnf(ctx, "create the metrics directory failed, %v", err)
+		return
+	}
+	if err := writeFileAtomic(filepath.Join(MetricsDir, w.snapshot.name()+".json"), bytes, 0644); err != nil {
+		log.Warnf(ctx, "write the metrics of %s failed, %v", w.snapshot.Uid, err)
+	}
+}
+
+func (s MetricsSnapshot) name() string {
+	if s.Instance == "" {
+		return s.Uid
+	}
+	return fmt.Sprintf("%s.%s", s.Uid, s.Instance)
+}
+
+// prometheus returns the snapshot in the Prometheus text format
+func (s MetricsSnapshot) prometheus() string {
+	labels := fmt.Sprintf(`uid=%q,target=%q,action=%q`, s.Uid, s.Target, s.Action)
+	if s.Instance != "" {
+		labels = fmt.Sprintf(`%s,instance=%q`, labels, s.Instance)
+	}
+	names := make([]string, 0, len(s.Values))
+	for name := range s.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var builder strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&builder, "chaosblade_experiment_%s{%s} %v\n", name, labels, s.Values[name])
+	}
+	fmt.Fprintf(&builder, "chaosblade_experiment_update_time_seconds{%s} %d\n", labels, s.UpdateTime.Unix())
+	return builder.String()
+}
+
+// LoadMetrics returns the latest snapshots of all the processes of the experiment
+func LoadMetrics(uid string) []MetricsSnapshot {
+	snapshots := make([]MetricsSnapshot, 0)
+	if uid == "" {
+		return snapshots
+	}
+	files, _ := filepath.Glob(filepath.Join(MetricsDir, uid+".*json"))
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		if name != uid && !strings.HasPrefix(name, uid+".") {
+			continue
+		}
+		bytes, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var snapshot MetricsSnapshot
+		if err := json.Unmarshal(bytes, &snapshot); err != nil || snapshot.Uid != uid {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+// RemoveMetrics removes the snapshots and the textfiles of the experiment, it's invoked by the destroy
+func RemoveMetrics(ctx context.Context, uid string) {
+	for _, snapshot := range LoadMetrics(uid) {
+		if snapshot.Textfile != "" {
+			if err := os.Remove(snapshot.Textfile); err != nil && !os.IsNotExist(err) {
+				log.Warnf(ctx, "remove the metrics textfile %s failed, %v", snapshot.Textfile, err)
+			}
+		}
+		if err := os.Remove(filepath.Join(MetricsDir, snapshot.name()+".json")); err != nil && !os.IsNotExist(err) {
+			log.Warnf(ctx, "remove the metrics of %s failed, %v", uid, err)
+		}
+	}
+}
+
+// writeFileAtomic writes the data to a temp file and renames it, so the readers never see a half written file
+func writeFileAtomic(file string, data []byte, perm os.FileMode) error {
+	tmpFile := file + ".tmp"
+	if err := os.WriteFile(tmpFile, data, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, file); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return nil
+}
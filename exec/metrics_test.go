@@ -0,0 +1,71 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWriter(t *testing.T) {
+	metricsDir, interval := MetricsDir, MetricsInterval
+	defer func() { MetricsDir, MetricsInterval = metricsDir, interval }()
+	MetricsDir = t.TempDir()
+	MetricsInterval = time.Hour
+	textfileDir := t.TempDir()
+	ctx := context.WithValue(context.Background(), MetricsDirKey, textfileDir)
+
+	var nilWriter *MetricsWriter
+	nilWriter.Add("appends", 1)
+	nilWriter.Flush(ctx)
+
+	writer := NewMetricsWriter(ctx, "metrics-uid", "file", "append", "")
+	writer.Add("appends", 2)
+	writer.Set("target", 10)
+	writer.Flush(ctx)
+	writer.Add("appends", 1)
+	writer.Flush(ctx)
+
+	snapshots := LoadMetrics("metrics-uid")
+	if len(snapshots) != 1 {
+		t.Fatalf("LoadMetrics() returns %d snapshots, want 1", len(snapshots))
+	}
+	if got := snapshots[0].Values["appends"]; got != 2 {
+		t.Errorf("appends = %v, want 2 before the interval elapsed", got)
+	}
+	if _, ok := snapshots[0].Values["appends_per_second"]; ok {
+		t.Errorf("the rate is written without the previous snapshot")
+	}
+	bytes, err := os.ReadFile(snapshots[0].Textfile)
+	if err != nil {
+		t.Fatalf("read the textfile failed, %v", err)
+	}
+	if !strings.Contains(string(bytes), `chaosblade_experiment_appends{uid="metrics-uid",target="file",action="append"} 2`) {
+		t.Errorf("unexpected textfile:\n%s", bytes)
+	}
+
+	RemoveMetrics(ctx, "metrics-uid")
+	if snapshots := LoadMetrics("metrics-uid"); len(snapshots) != 0 {
+		t.Errorf("LoadMetrics() returns %d snapshots after RemoveMetrics", len(snapshots))
+	}
+	if _, err := os.Stat(snapshots[0].Textfile); !os.IsNotExist(err) {
+		t.Errorf("the textfile is not removed, %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// MockCommand is the command run by the MockChannel
+type MockCommand struct {
+	Script string
+	Args   string
+}
+
+// String returns the command line, such as `iptables -A INPUT -p tcp -j DROP`
+func (c MockCommand) String() string {
+	if c.Args == "" {
+		return c.Script
+	}
+	return c.Script + " " + c.Args
+}
+
+// mockResponse is the scripted response of the commands whose script is equal and args match the pattern
+type mockResponse struct {
+	script   string
+	pattern  *regexp.Regexp
+	response *spec.Response
+	// times is the number of the runs answered by the response, it's unlimited if it's zero
+	times int
+}
+
+// MockChannel records the commands instead of running them, it's used to test the executors without
+// changing the host. The responses of the commands and the available commands are scripted, the commands
+// without the scripted response succeed with an empty result, and all the commands are available by default.
+type MockChannel struct {
+	lock        sync.Mutex
+	commands    []MockCommand
+	responses   []mockResponse
+	unavailable map[string]bool
+	pids        map[string][]string
+}
+
+// NewMockChannel returns the MockChannel, the iptables of which has no wait option, so the iptables commands
+// are recorded without the probe and the wait option
+func NewMockChannel() *MockChannel {
+	setIptablesWait(nil)
+	return &MockChannel{
+		commands:    make([]MockCommand, 0),
+		unavailable: make(map[string]bool),
+		pids:        make(map[string][]string),
+	}
+}
+
+// OnRun scripts the response of the script whose args match the regular expression, the empty pattern
+// matches any args. The response added later takes precedence, so a specific one can override a general one.
+func (m *MockChannel) OnRun(script, argsPattern string, response *spec.Response) *MockChannel {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.responses = append(m.responses, mockResponse{
+		script:   script,
+		pattern:  regexp.MustCompile(argsPattern),
+		response: response,
+	})
+	return m
+}
+
+// OnRunTimes is OnRun answering the first times of the runs only, then the runs fall back to the responses
+// added before, such as the lock errors followed by the success
+func (m *MockChannel) OnRunTimes(script, argsPattern string, times int, response *spec.Response) *MockChannel {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.responses = append(m.responses, mockResponse{
+		script:   script,
+		pattern:  regexp.MustCompile(argsPattern),
+		response: response,
+		times:    times,
+	})
+	return m
+}
+
+// SetCommandAvailable scripts the answer of IsCommandAvailable
+func (m *MockChannel) SetCommandAvailable(commandName string, available bool) *MockChannel {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.unavailable[commandName] = !available
+	return m
+}
+
+// SetPids scripts the pids of the process lookups by the process name, the command name or the local port
+func (m *MockChannel) SetPids(key string, pids ...string) *MockChannel {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.pids[key] = pids
+	return m
+}
+
+// Commands returns the recorded commands in order
+func (m *MockChannel) Commands() []MockCommand {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]MockCommand{}, m.commands...)
+}
+
+// CommandLines returns the recorded command lines in order, see MockCommand.String
+func (m *MockChannel) CommandLines() []string {
+	commands := m.Commands()
+	lines := make([]string, len(commands))
+	for i, command := range commands {
+		lines[i] = command.String()
+	}
+	return lines
+}
+
+// Reset clears the recorded commands, the scripted answers are kept
+func (m *MockChannel) Reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.commands = m.commands[:0]
+}
+
+func (m *MockChannel) Name() string {
+	return "mock"
+}
+
+// Run records the command and returns the scripted response
+func (m *MockChannel) Run(ctx context.Context, script, args string) *spec.Response {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	script = strings.TrimSpace(script)
+	m.commands = append(m.commands, MockCommand{Script: script, Args: args})
+	for i := len(m.responses) - 1; i >= 0; i-- {
+		if r := m.responses[i]; r.script == script && r.pattern.MatchString(args) {
+			if r.times > 0 {
+				if r.times--; r.times == 0 {
+					m.responses = append(m.responses[:i], m.responses[i+1:]...)
+				} else {
+					m.responses[i] = r
+				}
+			}
+			// the executors may change the result, so a copy is returned
+			response := *r.response
+			return &response
+		}
+	}
+	return spec.ReturnSuccess("")
+}
+
+func (m *MockChannel) GetScriptPath() string {
+	return ""
+}
+
+func (m *MockChannel) GetPidsByProcessCmdName(processName string, ctx context.Context) ([]string, error) {
+	return m.getPids(processName), nil
+}
+
+func (m *MockChannel) GetPidsByProcessName(processName string, ctx context.Context) ([]string, error) {
+	return m.getPids(processName), nil
+}
+
+func (m *MockChannel) GetPsArgs(ctx context.Context) string {
+	return "-eo user,pid,ppid,args"
+}
+
+func (m *MockChannel) IsAlpinePlatform(ctx context.Context) bool {
+	return false
+}
+
+// IsAllCommandsAvailable returns the same responses as the other channels for the unavailable commands
+func (m *MockChannel) IsAllCommandsAvailable(ctx context.Context, commandNames []string) (*spec.Response, bool) {
+	return channel.IsAllCommandsAvailable(ctx, m, commandNames)
+}
+
+func (m *MockChannel) IsCommandAvailable(ctx context.Context, commandName string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return !m.unavailable[commandName]
+}
+
+func (m *MockChannel) ProcessExists(pid string) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, pids := range m.pids {
+		for _, p := range pids {
+			if p == pid {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (m *MockChannel) GetPidUser(pid string) (string, error) {
+	return "root", nil
+}
+
+func (m *MockChannel) GetPidsByLocalPorts(ctx context.Context, localPorts []string) ([]string, error) {
+	pids := make([]string, 0)
+	for _, port := range localPorts {
+		pids = append(pids, m.getPids(port)...)
+	}
+	return pids, nil
+}
+
+func (m *MockChannel) GetPidsByLocalPort(ctx context.Context, localPort string) ([]string, error) {
+	return m.getPids(localPort), nil
+}
+
+func (m *MockChannel) getPids(key string) []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]string{}, m.pids[key]...)
+}
+
+// String returns the recorded command lines, one per line
+func (m *MockChannel) String() string {
+	return strings.Join(m.CommandLines(), "\n")
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	SpecFormatJSON = "json"
+	SpecFormatYAML = "yaml"
+
+	// SpecCatalogVersion is increased when the schema of the catalog is changed incompatibly
+	SpecCatalogVersion = "v1"
+)
+
+// SpecCatalog is the stable schema of all the experiment specs. The targets, actions, flags and
+// the string lists are sorted, so the output of the same specs is always the same.
+type SpecCatalog struct {
+	Version string       `json:"version" yaml:"version"`
+	Kind    string       `json:"kind" yaml:"kind"`
+	Targets []TargetSpec `json:"targets" yaml:"targets"`
+}
+
+type TargetSpec struct {
+	Target    string       `json:"target" yaml:"target"`
+	Scope     string       `json:"scope" yaml:"scope"`
+	ShortDesc string       `json:"shortDesc" yaml:"shortDesc"`
+	LongDesc  string       `json:"longDesc" yaml:"longDesc"`
+	Flags     []FlagSpec   `json:"flags" yaml:"flags"`
+	Actions   []ActionSpec `json:"actions" yaml:"actions"`
+}
+
+type ActionSpec struct {
+	Action      string     `json:"action" yaml:"action"`
+	Aliases     []string   `json:"aliases" yaml:"aliases"`
+	ShortDesc   string     `json:"shortDesc" yaml:"shortDesc"`
+	LongDesc    string     `json:"longDesc" yaml:"longDesc"`
+	Example     string     `json:"example" yaml:"example"`
+	Matchers    []FlagSpec `json:"matchers" yaml:"matchers"`
+	Flags       []FlagSpec `json:"flags" yaml:"flags"`
+	Programs    []string   `json:"programs" yaml:"programs"`
+	Categories  []string   `json:"categories" yaml:"categories"`
+	ProcessHang bool       `json:"processHang" yaml:"processHang"`
+}
+
+type FlagSpec struct {
+	Name                  string `json:"name" yaml:"name"`
+	Desc                  string `json:"desc" yaml:"desc"`
+	Default               string `json:"default" yaml:"default"`
+	NoArgs                bool   `json:"noArgs" yaml:"noArgs"`
+	Required              bool   `json:"required" yaml:"required"`
+	RequiredWhenDestroyed bool   `json:"requiredWhenDestroyed" yaml:"requiredWhenDestroyed"`
+}
+
+// ExportSpecs returns the catalog of the experiment specs returned by GetAllExpModels
+func ExportSpecs() *SpecCatalog {
+	return NewSpecCatalog(GetAllExpModels())
+}
+
+// NewSpecCatalog converts the experiment specs to the catalog
+func NewSpecCatalog(expModels []spec.ExpModelCommandSpec) *SpecCatalog {
+	targets := make([]TargetSpec, 0, len(expModels))
+	for _, expModel := range expModels {
+		actions := make([]ActionSpec, 0, len(expModel.Actions()))
+		for _, action := range expModel.Actions() {
+			actions = append(actions, ActionSpec{
+				Action:      action.Name(),
+				Aliases:     sortedStrings(action.Aliases()),
+				ShortDesc:   action.ShortDesc(),
+				LongDesc:    action.LongDesc(),
+				Example:     action.Example(),
+				Matchers:    convertFlags(action.Matchers()),
+				Flags:       convertFlags(action.Flags()),
+				Programs:    sortedStrings(action.Programs()),
+				Categories:  sortedStrings(action.Categories()),
+				ProcessHang: action.ProcessHang(),
+			})
+		}
+		sort.SliceStable(actions, func(i, j int) bool {
+			return actions[i].Action < actions[j].Action
+		})
+		targets = append(targets, TargetSpec{
+			Target:    expModel.Name(),
+			Scope:     expModel.Scope(),
+			ShortDesc: expModel.ShortDesc(),
+			LongDesc:  expModel.LongDesc(),
+			Flags:     convertFlags(expModel.Flags()),
+			Actions:   actions,
+		})
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targets[i].Target < targets[j].Target
+	})
+	return &SpecCatalog{
+		Version: SpecCatalogVersion,
+		Kind:    "plugin",
+		Targets: targets,
+	}
+}
+
+// Marshal serializes the catalog in the json or the yaml format
+func (c *SpecCatalog) Marshal(format string) ([]byte, error) {
+	switch format {
+	case SpecFormatJSON:
+		return json.MarshalIndent(c, "", "  ")
+	case SpecFormatYAML:
+		return yaml.Marshal(c)
+	default:
+		return nil, fmt.Errorf("unsupported format %s, only %s and %s are supported", format, SpecFormatJSON, SpecFormatYAML)
+	}
+}
+
+// UnmarshalSpecCatalog parses the catalog serialized by SpecCatalog.Marshal
+func UnmarshalSpecCatalog(data []byte, format string) (*SpecCatalog, error) {
+	catalog := &SpecCatalog{}
+	var err error
+	switch format {
+	case SpecFormatJSON:
+		err = json.Unmarshal(data, catalog)
+	case SpecFormatYAML:
+		err = yaml.Unmarshal(data, catalog)
+	default:
+		err = fmt.Errorf("unsupported format %s, only %s and %s are supported", format, SpecFormatJSON, SpecFormatYAML)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+func convertFlags(flagSpecs []spec.ExpFlagSpec) []FlagSpec {
+	flags := make([]FlagSpec, 0, len(flagSpecs))
+	for _, f := range flagSpecs {
+		flags = append(flags, FlagSpec{
+			Name:                  f.FlagName(),
+			Desc:                  f.FlagDesc(),
+			Default:               f.FlagDefault(),
+			NoArgs:                f.FlagNoArgs(),
+			Required:              f.FlagRequired(),
+			RequiredWhenDestroyed: f.FlagRequiredWhenDestroyed(),
+		})
+	}
+	sort.SliceStable(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+	return flags
+}
+
+// sortedStrings returns the sorted copy, the nil is converted to the empty list for the stable output
+func sortedStrings(values []string) []string {
+	result := append(make([]string, 0, len(values)), values...)
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestSpecCatalogRoundTrip(t *testing.T) {
+	for _, format := range []string{SpecFormatJSON, SpecFormatYAML} {
+		data, err := ExportSpecs().Marshal(format)
+		if err != nil {
+			t.Fatalf("marshal %s failed, %v", format, err)
+		}
+		again, err := ExportSpecs().Marshal(format)
+		if err != nil {
+			t.Fatalf("marshal %s failed, %v", format, err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Errorf("the %s output is not deterministic", format)
+		}
+		catalog, err := UnmarshalSpecCatalog(data, format)
+		if err != nil {
+			t.Fatalf("unmarshal %s failed, %v", format, err)
+		}
+		if !reflect.DeepEqual(catalog, ExportSpecs()) {
+			t.Errorf("the %s round trip changes the catalog", format)
+		}
+		roundTrip, err := catalog.Marshal(format)
+		if err != nil {
+			t.Fatalf("marshal the parsed %s failed, %v", format, err)
+		}
+		if !bytes.Equal(data, roundTrip) {
+			t.Errorf("the %s round trip changes the output", format)
+		}
+	}
+	if _, err := ExportSpecs().Marshal("xml"); err == nil {
+		t.Errorf("expect the unsupported format error")
+	}
+}
+
+func TestSpecCatalogSorted(t *testing.T) {
+	flagsSorted := func(flags []FlagSpec) bool {
+		return sort.SliceIsSorted(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	}
+	catalog := ExportSpecs()
+	if len(catalog.Targets) != len(GetAllExpModels()) {
+		t.Fatalf("unexpected targets count, expect: %d, actual: %d", len(GetAllExpModels()), len(catalog.Targets))
+	}
+	if !sort.SliceIsSorted(catalog.Targets, func(i, j int) bool { return catalog.Targets[i].Target < catalog.Targets[j].Target }) {
+		t.Errorf("the targets are not sorted")
+	}
+	for _, target := range catalog.Targets {
+		if !flagsSorted(target.Flags) {
+			t.Errorf("the flags of %s are not sorted", target.Target)
+		}
+		actions := target.Actions
+		if !sort.SliceIsSorted(actions, func(i, j int) bool { return actions[i].Action < actions[j].Action }) {
+			t.Errorf("the actions of %s are not sorted", target.Target)
+		}
+		for _, action := range actions {
+			if !flagsSorted(action.Flags) || !flagsSorted(action.Matchers) {
+				t.Errorf("the flags of %s %s are not sorted", target.Target, action.Action)
+			}
+			if !sort.StringsAreSorted(action.Programs) || !sort.StringsAreSorted(action.Categories) || !sort.StringsAreSorted(action.Aliases) {
+				t.Errorf("the lists of %s %s are not sorted", target.Target, action.Action)
+			}
+		}
+	}
+}
+
+func TestNewSpecCatalog(t *testing.T) {
+	action := &spec.ActionModel{
+		ActionName:       "load",
+		ActionPrograms:   []string{"chaos_os"},
+		ActionCategories: []string{"system_mem"},
+		ActionFlags: []spec.ExpFlag{
+			{Name: "mode", Required: true},
+			{Name: "avoid-being-killed", NoArgs: true},
+		},
+		ActionProcessHang: true,
+	}
+	expModel := &spec.ExpCommandModel{
+		ExpName:    "mem",
+		ExpActions: []spec.ActionModel{*action},
+	}
+	catalog := NewSpecCatalog([]spec.ExpModelCommandSpec{expModel})
+	if len(catalog.Targets) != 1 || len(catalog.Targets[0].Actions) != 1 {
+		t.Fatalf("unexpected catalog, %+v", catalog)
+	}
+	expect := ActionSpec{
+		Action:     "load",
+		Aliases:    []string{},
+		Matchers:   []FlagSpec{},
+		Programs:   []string{"chaos_os"},
+		Categories: []string{"system_mem"},
+		Flags: []FlagSpec{
+			{Name: "avoid-being-killed", NoArgs: true},
+			{Name: "mode", Required: true},
+		},
+		ProcessHang: true,
+	}
+	if actual := catalog.Targets[0].Actions[0]; !reflect.DeepEqual(actual, expect) {
+		t.Errorf("unexpected action, expect: %+v, actual: %+v", expect, actual)
+	}
+}
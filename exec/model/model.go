@@ -21,6 +21,7 @@ import (
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/container"
 )
 
 // Support for other project about chaosblade
@@ -38,6 +39,23 @@ func GetAllOsExecutors() map[string]spec.Executor {
 	return executors
 }
 
+// NewOsExecutor returns a new executor of the target action, its channel isn't shared with the executor
+// which is running the experiment
+func NewOsExecutor(target, action string) spec.Executor {
+	return GetAllOsExecutors()[target+action]
+}
+
+// GetAllOsBins returns the programs of all the actions
+func GetAllOsBins() []string {
+	bins := make([]string, 0)
+	for _, expModel := range GetAllExpModels() {
+		for _, actionModel := range expModel.Actions() {
+			bins = append(bins, actionModel.Programs()...)
+		}
+	}
+	return bins
+}
+
 func GetSHHExecutor() spec.Executor {
 	return exec.NewSSHExecutor()
 }
@@ -45,7 +63,7 @@ func GetSHHExecutor() spec.Executor {
 func ExtractExecutorFromExpModel(expModel spec.ExpModelCommandSpec) map[string]spec.Executor {
 	executors := make(map[string]spec.Executor)
 	for _, actionModel := range expModel.Actions() {
-		executors[expModel.Name()+actionModel.Name()] = actionModel.Executor()
+		executors[expModel.Name()+actionModel.Name()] = exec.WithFailurePayload(exec.WithStatusPhase(actionModel.Executor()))
 	}
 	return executors
 }
@@ -71,6 +89,72 @@ var UidFlag = spec.ExpFlag{
 	Default: "",
 }
 
+var TimeoutFlag = spec.ExpFlag{
+	Name:    "timeout",
+	Desc:    "destroy the experiment automatically after the timeout, such as 60 (seconds) or 5m, only for the actions without the resident process",
+	Default: "",
+}
+
+// DryRunFlag records the commands of the experiment instead of running them, see exec.DryRunChannel
+var DryRunFlag = spec.ExpFlag{
+	Name:    exec.DryRunKey,
+	Desc:    "print the commands which would be executed without running them",
+	Default: "",
+}
+
+var MetricsDirFlag = spec.ExpFlag{
+	Name:    exec.MetricsDirKey,
+	Desc:    "the Prometheus textfile directory which the metrics of the long-running experiment are written to",
+	Default: "",
+}
+
+// LogDirFlag writes the log of the experiment to its own file, see exec.InitExperimentLog
+var LogDirFlag = spec.ExpFlag{
+	Name:    exec.LogDirKey,
+	Desc:    "the directory which the experiment writes its own log file <bin>-<uid>.log to, the destroy appends to the same file",
+	Default: "",
+}
+
+var LogLevelFlag = spec.ExpFlag{
+	Name:    exec.LogLevelKey,
+	Desc:    "the log level, debug, info, warn or error, it takes precedence over the debug flag",
+	Default: "",
+}
+
+// AllowOverlapFlag skips the conflict check of the experiments changing the same resource, see exec.ClaimResources
+var AllowOverlapFlag = spec.ExpFlag{
+	Name:    exec.AllowOverlapKey,
+	Desc:    "stack the experiment on the effective experiment which changes the same interface, filesystem, hosts file or clock",
+	Default: "",
+}
+
+// ProtectedPathsFlag adds the paths which the destructive file and disk experiments refuse, see exec.CheckProtectedPath
+var ProtectedPathsFlag = spec.ExpFlag{
+	Name:    exec.ProtectedPathsKey,
+	Desc:    "the file which lists the protected paths besides the built-in ones, one absolute path per line",
+	Default: "",
+}
+
+// ContainerIdFlag resolves the ns_target and the cgroup-path of the experiment from the container, see container.Resolve
+var ContainerIdFlag = spec.ExpFlag{
+	Name:    container.IdFlagName,
+	Desc:    "the id, the short id or the name of the container which the experiment runs in, it's used instead of ns_target",
+	Default: "",
+}
+
+var ContainerRuntimeFlag = spec.ExpFlag{
+	Name:    container.RuntimeFlagName,
+	Desc:    "the runtime of the container, docker, containerd or crio, all of them are tried if it's absent",
+	Default: "",
+}
+
+// WatchdogFlag marks the process which sleeps the timeout and then destroys the experiment, it's set by chaos_os itself
+var WatchdogFlag = spec.ExpFlag{
+	Name:    "watchdog",
+	Desc:    "watchdog",
+	Default: "",
+}
+
 var DebugFlag = spec.ExpFlag{
 	Name:    "debug",
 	Desc:    "debug",
@@ -106,3 +190,21 @@ var NsNetFlag = spec.ExpFlag{
 	Desc:    "net namespace",
 	Default: "false",
 }
+
+// mountNamespaceTargets change the files by their paths, the paths in the container are used directly because
+// the experiments always enter the mount namespace of the ns_target
+var mountNamespaceTargets = map[string]bool{
+	"file":   true,
+	"script": true,
+}
+
+// NeedsMountNamespace returns whether the nsexec channel enters the mount namespace without the ns_mnt flag
+func NeedsMountNamespace(target string) bool {
+	return mountNamespaceTargets[target]
+}
+
+// GetDryRunSupport returns how the action supports the dry-run, the actions not in dryRunActions
+// change the system without the channel, so they are not supported.
+func GetDryRunSupport(target, action string) exec.DryRunSupport {
+	return dryRunActions[target+" "+action]
+}
@@ -19,6 +19,7 @@ package model
 import (
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/cpu"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/disk"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/file"
@@ -41,3 +42,14 @@ func GetAllExpModels() []spec.ExpModelCommandSpec {
 		file.NewFileCommandSpec(),
 	}
 }
+
+// dryRunActions are the actions which support the dry-run, the network actions are not supported
+// because the dummynet state is written directly.
+var dryRunActions = map[string]exec.DryRunSupport{
+	"process kill": exec.DryRunNative,
+	"process stop": exec.DryRunNative,
+	"file chmod":   exec.DryRunComplete,
+	"file add":     exec.DryRunComplete,
+	"file delete":  exec.DryRunComplete,
+	"file move":    exec.DryRunComplete,
+}
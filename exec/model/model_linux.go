@@ -19,9 +19,11 @@ package model
 import (
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/cpu"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/disk"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/file"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/host"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/kernel"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/mem"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/network"
@@ -43,7 +45,31 @@ func GetAllExpModels() []spec.ExpModelCommandSpec {
 		script.NewScriptCommandModelSpec(),
 		file.NewFileCommandSpec(),
 		kernel.NewKernelInjectCommandSpec(),
+		kernel.NewKernelCommandSpec(),
 		systemd.NewSystemdCommandModelSpec(),
 		time.NewTimeCommandSpec(),
+		host.NewHostCommandSpec(),
 	}
 }
+
+// dryRunActions are the actions which support the dry-run, network dns is incomplete because the
+// pair is assumed not in the hosts file.
+var dryRunActions = map[string]exec.DryRunSupport{
+	"process kill":      exec.DryRunNative,
+	"process stop":      exec.DryRunNative,
+	"network delay":     exec.DryRunComplete,
+	"network loss":      exec.DryRunComplete,
+	"network duplicate": exec.DryRunComplete,
+	"network corrupt":   exec.DryRunComplete,
+	"network reorder":   exec.DryRunComplete,
+	"network rate":      exec.DryRunComplete,
+	"network drop":      exec.DryRunComplete,
+	"network partition": exec.DryRunComplete,
+	"network dns":       exec.DryRunIncomplete,
+	"network dns_down":  exec.DryRunComplete,
+	"disk fill":         exec.DryRunComplete,
+	"file chmod":        exec.DryRunComplete,
+	"file add":          exec.DryRunComplete,
+	"file delete":       exec.DryRunComplete,
+	"file move":         exec.DryRunComplete,
+}
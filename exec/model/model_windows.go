@@ -1,10 +1,54 @@
 package model
 
-import "github.com/chaosblade-io/chaosblade-spec-go/spec"
+import (
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/cpu"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/disk"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/file"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/network"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/process"
+)
 
 // GetAllExpModels returns the experiment model specs in the project.
 // Support for other project about chaosblade
 func GetAllExpModels() []spec.ExpModelCommandSpec {
-	// TODO: implement Windows experiment models
-	return []spec.ExpModelCommandSpec{}
+	return []spec.ExpModelCommandSpec{
+		withActions(cpu.NewCpuCommandModelSpec(), "fullload"),
+		withActions(process.NewProcessCommandModelSpec(), "kill", "stop"),
+		withActions(file.NewFileCommandSpec(), "append", "delete"),
+		withActions(disk.NewDiskCommandSpec(), "fill", "burn"),
+		network.NewNetworkCommandSpec(),
+	}
+}
+
+// windowsModel is the model limited to the actions which are supported on Windows
+type windowsModel struct {
+	spec.ExpModelCommandSpec
+	actions []spec.ExpActionCommandSpec
+}
+
+func (w *windowsModel) Actions() []spec.ExpActionCommandSpec {
+	return w.actions
+}
+
+func withActions(model spec.ExpModelCommandSpec, names ...string) spec.ExpModelCommandSpec {
+	actions := make([]spec.ExpActionCommandSpec, 0, len(names))
+	for _, action := range model.Actions() {
+		for _, name := range names {
+			if action.Name() == name {
+				actions = append(actions, action)
+			}
+		}
+	}
+	return &windowsModel{ExpModelCommandSpec: model, actions: actions}
+}
+
+// dryRunActions are the actions which support the dry-run, the file and disk actions change the
+// files directly, so they are not supported.
+var dryRunActions = map[string]exec.DryRunSupport{
+	"process kill": exec.DryRunNative,
+	"process stop": exec.DryRunNative,
+	"network drop": exec.DryRunComplete,
 }
@@ -0,0 +1,52 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+)
+
+func TestGetAllExpModels(t *testing.T) {
+	expects := map[string][]string{
+		"cpu":     {"fullload"},
+		"process": {"kill", "stop"},
+		"file":    {"append", "delete"},
+		"disk":    {"fill", "burn"},
+		"network": {"delay", "drop", "loss"},
+	}
+	models := GetAllExpModels()
+	if len(models) != len(expects) {
+		t.Fatalf("unexpected models count, expect: %d, actual: %d", len(expects), len(models))
+	}
+	for _, model := range models {
+		names, ok := expects[model.Name()]
+		if !ok {
+			t.Errorf("unexpected model: %s", model.Name())
+			continue
+		}
+		actions := model.Actions()
+		if len(actions) != len(names) {
+			t.Errorf("unexpected actions count of %s, expect: %d, actual: %d", model.Name(), len(names), len(actions))
+			continue
+		}
+		for i, action := range actions {
+			if action.Name() != names[i] {
+				t.Errorf("unexpected action of %s, expect: %s, actual: %s", model.Name(), names[i], action.Name())
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// AllowRemoteFsFlagName allows the destructive experiments on the remote filesystems, see CheckRemoteFilesystem
+const AllowRemoteFsFlagName = "allow-remote-fs"
+
+// remoteFsTypes are the types of the filesystems served over the network, whose locks, attribute caches and
+// traffic differ from the local ones
+var remoteFsTypes = map[string]bool{
+	"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "smbfs": true, "ncpfs": true, "afs": true,
+	"9p": true, "ceph": true, "glusterfs": true, "lustre": true, "gpfs": true, "davfs": true,
+	"fuse.sshfs": true, "fuse.glusterfs": true, "fuse.ceph-fuse": true, "fuse.s3fs": true,
+}
+
+// MountInfo is the filesystem mounted at the mount point
+type MountInfo struct {
+	Source     string `json:"source"`
+	MountPoint string `json:"mountPoint"`
+	FsType     string `json:"fsType"`
+	// Options are the mount options, such as ro and nosuid, they are absent on Windows
+	Options []string `json:"options,omitempty"`
+}
+
+// IsRemote returns true if the filesystem is served over the network
+func (m MountInfo) IsRemote() bool {
+	return IsRemoteFsType(m.FsType)
+}
+
+// ReadOnly returns true if the filesystem is mounted read-only, such as the read-only bind mount
+func (m MountInfo) ReadOnly() bool {
+	for _, option := range m.Options {
+		if option == "ro" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRemoteFsType returns true if the type is of the filesystem served over the network
+func IsRemoteFsType(fsType string) bool {
+	return remoteFsTypes[strings.ToLower(fsType)]
+}
+
+// ParseMounts parses the mount table in the format of /proc/mounts, the spaces in the paths are escaped in octal
+func ParseMounts(content string) []MountInfo {
+	mounts := make([]MountInfo, 0)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		mount := MountInfo{
+			Source:     unescapeMountField(fields[0]),
+			MountPoint: unescapeMountField(fields[1]),
+			FsType:     fields[2],
+		}
+		if len(fields) > 3 {
+			mount.Options = strings.Split(fields[3], ",")
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts
+}
+
+// unescapeMountField replaces the octal escapes of the mount table, such as \040 for the space
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var builder strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if c, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				builder.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		builder.WriteByte(field[i])
+	}
+	return builder.String()
+}
+
+// FindMount returns the mount whose mount point is the longest prefix of the path, the later mount of the same
+// mount point covers the earlier one
+func FindMount(mounts []MountInfo, p string) (MountInfo, bool) {
+	var found MountInfo
+	ok := false
+	for _, mount := range mounts {
+		if !isUnderMountPoint(p, mount.MountPoint) {
+			continue
+		}
+		if !ok || len(mount.MountPoint) >= len(found.MountPoint) {
+			found, ok = mount, true
+		}
+	}
+	return found, ok
+}
+
+func isUnderMountPoint(p, mountPoint string) bool {
+	if mountPoint == "/" {
+		return strings.HasPrefix(p, "/")
+	}
+	return p == mountPoint || strings.HasPrefix(p, mountPoint+"/")
+}
+
+// GetMount returns the filesystem where the path is, the mount table is read by the channel, so the path in the
+// container is found in its mount namespace. It returns false if the mount table can't be read.
+func GetMount(ctx context.Context, cl spec.Channel, p string) (MountInfo, bool) {
+	return getMount(ctx, cl, p)
+}
+
+// hasRemoteMount returns true if any of the filesystems is remote
+func hasRemoteMount(mounts []MountInfo) bool {
+	for _, mount := range mounts {
+		if mount.IsRemote() {
+			return true
+		}
+	}
+	return false
+}
+
+type remoteFilesystemKey struct{}
+
+// WithRemoteFilesystem marks the experiment on the remote filesystem, then CheckFilepathExists stats the file
+func WithRemoteFilesystem(ctx context.Context, mount MountInfo) context.Context {
+	return context.WithValue(ctx, remoteFilesystemKey{}, mount)
+}
+
+// RemoteFilesystem returns the remote filesystem marked by WithRemoteFilesystem
+func RemoteFilesystem(ctx context.Context) (MountInfo, bool) {
+	mount, ok := ctx.Value(remoteFilesystemKey{}).(MountInfo)
+	return mount, ok
+}
+
+// RemoteFilesystemWarning explains how the experiment on the remote filesystem behaves differently
+func RemoteFilesystemWarning(mount MountInfo) string {
+	return fmt.Sprintf("%s is the %s filesystem from %s, the changes are seen by the other clients after their "+
+		"attribute caches expire, and the backups are transferred over the network", mount.MountPoint, mount.FsType, mount.Source)
+}
+
+// CheckRemoteFilesystem returns the context marked by WithRemoteFilesystem if the target of the flag is on the
+// remote filesystem. The destructive experiment is refused unless it's allowed by --allow-remote-fs.
+func CheckRemoteFilesystem(ctx context.Context, cl spec.Channel, flag, target string, destructive, allowed bool) (context.Context, *spec.Response) {
+	mount, ok := GetMount(ctx, cl, target)
+	if !ok || !mount.IsRemote() {
+		return ctx, nil
+	}
+	if destructive && !allowed {
+		log.Errorf(ctx, "`%s`: on the %s filesystem %s", target, mount.FsType, mount.MountPoint)
+		return ctx, spec.ResponseFailWithFlags(spec.ParameterIllegal, flag, target,
+			fmt.Sprintf("it's on the %s filesystem %s, add --%s to run the experiment on the remote filesystem",
+				mount.FsType, mount.MountPoint, AllowRemoteFsFlagName))
+	}
+	log.Warnf(ctx, "%s", RemoteFilesystemWarning(mount))
+	return WithRemoteFilesystem(ctx, mount), nil
+}
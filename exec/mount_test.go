@@ -0,0 +1,95 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+const testMounts = `/dev/vda1 / ext4 rw,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+nas:/export/data /data nfs4 rw,relatime,vers=4.1 0 0
+/dev/vdb1 /data/local xfs rw,relatime 0 0
+/dev/vdb1 /data/local/ro xfs ro,relatime 0 0
+//fs/share /mnt/my\040share cifs rw,relatime 0 0
+`
+
+func TestFindMount(t *testing.T) {
+	mounts := ParseMounts(testMounts)
+	tests := []struct {
+		path       string
+		mountPoint string
+		remote     bool
+		readOnly   bool
+	}{
+		{"/var/log/app.log", "/", false, false},
+		{"/data", "/data", true, false},
+		{"/data/app.log", "/data", true, false},
+		{"/data/local/app.log", "/data/local", false, false},
+		{"/data/local/ro/app.log", "/data/local/ro", false, true},
+		{"/data/local/rox", "/data/local", false, false},
+		{"/datax/app.log", "/", false, false},
+		{"/mnt/my share/app.log", "/mnt/my share", true, false},
+	}
+	for _, tt := range tests {
+		mount, ok := FindMount(mounts, tt.path)
+		if !ok || mount.MountPoint != tt.mountPoint || mount.IsRemote() != tt.remote || mount.ReadOnly() != tt.readOnly {
+			t.Errorf("unexpected mount of %s, %+v", tt.path, mount)
+		}
+	}
+}
+
+func TestCheckRemoteFilesystem(t *testing.T) {
+	ctx := context.Background()
+	cl := NewMockChannel().
+		OnRun("cat", "/proc/mounts", spec.ReturnSuccess(testMounts)).
+		OnRun("readlink", "", spec.ReturnSuccess("/data/app.log\n"))
+
+	if _, response := CheckRemoteFilesystem(ctx, cl, "filepath", "/data/app.log", true, false); response == nil ||
+		response.Code != spec.ParameterIllegal.Code {
+		t.Fatalf("expected the destructive experiment on nfs4 refused, %+v", response)
+	}
+	remoteCtx, response := CheckRemoteFilesystem(ctx, cl, "filepath", "/data/app.log", true, true)
+	if response != nil {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if mount, ok := RemoteFilesystem(remoteCtx); !ok || mount.FsType != "nfs4" {
+		t.Errorf("expected the context marked as nfs4, %+v", mount)
+	}
+
+	// the file is stat directly, the errors other than the missing file are taken as existing
+	cl.Reset()
+	cl.OnRun("stat", "", spec.ReturnFail(spec.OsCmdExecFailed, "stat: cannot stat '/data/app.log': Stale file handle"))
+	if !CheckFilepathExists(remoteCtx, cl, "/data/app.log") {
+		t.Errorf("expected the stale file taken as existing")
+	}
+	cl.OnRun("stat", "", spec.ReturnFail(spec.OsCmdExecFailed, "stat: cannot stat '/data/app.log': No such file or directory"))
+	if CheckFilepathExists(remoteCtx, cl, "/data/app.log") {
+		t.Errorf("expected the missing file")
+	}
+
+	// the local filesystem isn't marked
+	localCtx, response := CheckRemoteFilesystem(ctx, NewMockChannel(), "filepath", "/data/app.log", true, false)
+	if _, ok := RemoteFilesystem(localCtx); ok || response != nil {
+		t.Errorf("expected the local filesystem, %+v", response)
+	}
+}
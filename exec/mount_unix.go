@@ -0,0 +1,44 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// mountTable is read by the channel, there is no /proc/mounts on macOS, whose filesystems are taken as local
+const mountTable = "/proc/mounts"
+
+func getMount(ctx context.Context, cl spec.Channel, p string) (MountInfo, bool) {
+	response := RunReadOnlyArgv(ctx, cl, "cat", "--", mountTable)
+	if !response.Success {
+		log.Debugf(ctx, "read %s failed, %s", mountTable, response.Err)
+		return MountInfo{}, false
+	}
+	mounts := ParseMounts(fmt.Sprint(response.Result))
+	// the symlinks are followed only if the path may lead to the remote filesystem
+	if hasRemoteMount(mounts) {
+		p = ResolvePath(ctx, cl, p)
+	}
+	return FindMount(mounts, p)
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"golang.org/x/sys/windows"
+)
+
+// getMount returns the volume of the path, the UNC paths and the mapped network drives are taken as the SMB shares
+func getMount(ctx context.Context, cl spec.Channel, p string) (MountInfo, bool) {
+	volume := filepath.VolumeName(ResolvePath(ctx, cl, p))
+	if volume == "" {
+		return MountInfo{}, false
+	}
+	if strings.HasPrefix(volume, `\\`) {
+		return MountInfo{Source: volume, MountPoint: volume, FsType: "smbfs"}, true
+	}
+	root, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return MountInfo{}, false
+	}
+	fsType := "local"
+	if windows.GetDriveType(root) == windows.DRIVE_REMOTE {
+		fsType = "smbfs"
+	}
+	return MountInfo{Source: volume, MountPoint: volume, FsType: fsType}, true
+}
@@ -0,0 +1,378 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const ArpBin = "chaos_arp"
+
+const (
+	ArpModeFlush  = "flush"
+	ArpModePoison = "poison"
+)
+
+// the locally administered unicast address which is used if the mac flag is not set
+const bogusMac = "02:00:5e:10:00:01"
+
+type ArpActionSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewArpActionSpec() spec.ExpActionCommandSpec {
+	return &ArpActionSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     "mode",
+					Desc:     "The disruption mode, flush or poison",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name: "destination-ip",
+					Desc: "The neighbor ips separated by comma, for example 10.0.0.1,10.0.0.2. It's required in the poison mode, all neighbors are flushed if it's not set in the flush mode",
+				},
+				&spec.ExpFlag{
+					Name: "interface",
+					Desc: "Network interface, for example, eth0. It's got by the route to the destination ip if it's not set in the poison mode",
+				},
+				&spec.ExpFlag{
+					Name: "mac",
+					Desc: "The mac address of the static neighbor entries in the poison mode, default value is " + bogusMac,
+				},
+				&spec.ExpFlag{
+					Name:    "interval",
+					Desc:    "Flush the neighbor entries every interval seconds in the flush mode, default value is 1",
+					Default: "1",
+				},
+			},
+			ActionExecutor: &NetworkArpExecutor{},
+			ActionExample: `
+# Flush all the neighbor entries every second
+blade create network arp --mode flush
+
+# Flush the neighbor entry of 192.168.1.1 on eth0 every 5 seconds
+blade create network arp --mode flush --destination-ip 192.168.1.1 --interface eth0 --interval 5
+
+# Map 192.168.1.10 to a bogus mac address
+blade create network arp --mode poison --destination-ip 192.168.1.10
+
+# Map 192.168.1.10 to the specified mac address
+blade create network arp --mode poison --destination-ip 192.168.1.10 --mac 00:16:3e:00:00:01`,
+			ActionPrograms:    []string{ArpBin},
+			ActionCategories:  []string{category.SystemNetwork},
+			ActionProcessHang: true,
+		},
+	}
+}
+
+func (*ArpActionSpec) Name() string {
+	return "arp"
+}
+
+func (*ArpActionSpec) Aliases() []string {
+	return []string{"neigh"}
+}
+
+func (*ArpActionSpec) ShortDesc() string {
+	return "Disrupt the ARP/neighbor table"
+}
+
+func (a *ArpActionSpec) LongDesc() string {
+	if a.ActionLongDesc != "" {
+		return a.ActionLongDesc
+	}
+	return "Flush the neighbor entries periodically to make the neighbor resolution thrash, or install the static neighbor entries " +
+		"with the wrong mac address. The flush loop is stopped and the static entries are removed when the experiment is destroyed, " +
+		"the permanent entries which exist before the experiment are restored instead of being removed"
+}
+
+type NetworkArpExecutor struct {
+	channel spec.Channel
+}
+
+func (*NetworkArpExecutor) Name() string {
+	return "arp"
+}
+
+func (ae *NetworkArpExecutor) SetChannel(channel spec.Channel) {
+	ae.channel = channel
+}
+
+// neighborEntry is a static neighbor entry created by the poison mode, the original mac is recorded
+// if a permanent entry exists before the experiment
+type neighborEntry struct {
+	Ip          string `json:"ip"`
+	Device      string `json:"device"`
+	OriginalMac string `json:"originalMac,omitempty"`
+}
+
+func (ae *NetworkArpExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if response, ok := ae.channel.IsAllCommandsAvailable(ctx, []string{"ip"}); !ok {
+		return response
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return ae.stop(ctx, uid)
+	}
+	ips := make([]string, 0)
+	destinationIps := model.ActionFlags["destination-ip"]
+	for _, ip := range strings.Split(destinationIps, ",") {
+		ip = strings.TrimSpace(ip)
+		if ip == "" {
+			continue
+		}
+		if net.ParseIP(ip) == nil {
+			log.Errorf(ctx, "`%s`: destination-ip is illegal", destinationIps)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "destination-ip", destinationIps, ip+" is not an ip address")
+		}
+		ips = append(ips, ip)
+	}
+	device := model.ActionFlags["interface"]
+	if device != "" {
+		if response := ae.channel.Run(ctx, "ip", "link show dev "+device); !response.Success {
+			log.Errorf(ctx, "`%s`: interface is invalid, %s", device, response.Err)
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "interface", device, response.Err)
+		}
+	}
+	mode := model.ActionFlags["mode"]
+	switch mode {
+	case ArpModeFlush:
+		intervalStr := model.ActionFlags["interval"]
+		if intervalStr == "" {
+			intervalStr = "1"
+		}
+		interval, err := strconv.Atoi(intervalStr)
+		if err != nil || interval < 1 {
+			log.Errorf(ctx, "`%s`: interval is illegal", intervalStr)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "interval", intervalStr, "it must be a positive integer")
+		}
+		return ae.flush(ctx, uid, ips, device, interval)
+	case ArpModePoison:
+		if len(ips) == 0 {
+			log.Errorf(ctx, "destination-ip is required in the poison mode")
+			return spec.ResponseFailWithFlags(spec.ParameterLess, "destination-ip")
+		}
+		mac := model.ActionFlags["mac"]
+		if mac == "" {
+			mac = bogusMac
+		}
+		if _, err := net.ParseMAC(mac); err != nil {
+			log.Errorf(ctx, "`%s`: mac is illegal, %v", mac, err)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "mac", mac, err)
+		}
+		return ae.poison(ctx, uid, ips, device, mac)
+	case "":
+		log.Errorf(ctx, "mode is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "mode")
+	default:
+		log.Errorf(ctx, "`%s`: mode is illegal", mode)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "mode", mode, "it must be flush or poison")
+	}
+}
+
+// flush flushes the neighbor entries until the experiment is destroyed, the permanent entries are never flushed
+func (ae *NetworkArpExecutor) flush(ctx context.Context, uid string, ips []string, device string, interval int) *spec.Response {
+	args := make([]string, 0)
+	if len(ips) == 0 {
+		if device == "" {
+			args = append(args, "all")
+		} else {
+			args = append(args, "dev "+device)
+		}
+	} else {
+		for _, ip := range ips {
+			if device == "" {
+				args = append(args, "to "+ip)
+			} else {
+				args = append(args, fmt.Sprintf("to %s dev %s", ip, device))
+			}
+		}
+	}
+	flush := func() *spec.Response {
+		for _, arg := range args {
+			if response := ae.channel.Run(ctx, "ip", "neigh flush "+arg); !response.Success {
+				return response
+			}
+		}
+		return nil
+	}
+	if response := flush(); response != nil {
+		log.Errorf(ctx, "flush the neighbor entries failed, %s", response.Err)
+		return response
+	}
+	log.Infof(ctx, "flush the neighbor entries %v every %d seconds", args, interval)
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if response := flush(); response != nil {
+				log.Warnf(ctx, "flush the neighbor entries failed, %s", response.Err)
+			}
+		case <-ctx.Done():
+			return spec.ReturnSuccess(uid)
+		}
+	}
+}
+
+// poison replaces the neighbor entries with the permanent ones which map the ips to the mac
+func (ae *NetworkArpExecutor) poison(ctx context.Context, uid string, ips []string, device, mac string) *spec.Response {
+	stateFile := getArpStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the arp state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	// the operator's permanent entries are recorded before any change, so that they are restored instead of being removed
+	entries := make([]neighborEntry, 0, len(ips))
+	for _, ip := range ips {
+		entry := neighborEntry{Ip: ip, Device: device}
+		if entry.Device == "" {
+			var response *spec.Response
+			if entry.Device, response = ae.getRouteDevice(ctx, ip); response != nil {
+				return response
+			}
+		}
+		var response *spec.Response
+		if entry.OriginalMac, response = ae.getPermanentMac(ctx, ip, entry.Device); response != nil {
+			return response
+		}
+		entries = append(entries, entry)
+	}
+	if err := writeArpState(stateFile, entries); err != nil {
+		log.Errorf(ctx, "write the arp state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "arp", "writeArpState", err)
+	}
+	for _, entry := range entries {
+		log.Infof(ctx, "map %s on %s to %s", entry.Ip, entry.Device, mac)
+		if response := ae.channel.Run(ctx, "ip",
+			fmt.Sprintf("neigh replace %s lladdr %s dev %s nud permanent", entry.Ip, mac, entry.Device)); !response.Success {
+			log.Errorf(ctx, "replace the neighbor entry of %s failed, %s", entry.Ip, response.Err)
+			ae.stop(ctx, uid)
+			return response
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (ae *NetworkArpExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	ctx = context.WithValue(ctx, "bin", ArpBin)
+	if response := exec.Destroy(ctx, ae.channel, "network arp"); !response.Success {
+		return response
+	}
+	// the state file exists only in the poison mode
+	stateFile := getArpStateFile(uid)
+	entries, err := readArpState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess(uid)
+		}
+		log.Errorf(ctx, "read the arp state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "arp", "readArpState", err)
+	}
+	failed := make([]string, 0)
+	for _, entry := range entries {
+		var response *spec.Response
+		if entry.OriginalMac != "" {
+			response = ae.channel.Run(ctx, "ip",
+				fmt.Sprintf("neigh replace %s lladdr %s dev %s nud permanent", entry.Ip, entry.OriginalMac, entry.Device))
+		} else {
+			response = ae.channel.Run(ctx, "ip", fmt.Sprintf("neigh del %s dev %s", entry.Ip, entry.Device))
+			// the entry may be not created if the experiment failed
+			if !response.Success && strings.Contains(response.Err, "No such file or directory") {
+				continue
+			}
+		}
+		if !response.Success {
+			log.Errorf(ctx, "restore the neighbor entry of %s failed, %s", entry.Ip, response.Err)
+			failed = append(failed, entry.Ip)
+		}
+	}
+	if len(failed) > 0 {
+		return exec.Fail(exec.RestoreFailed, "arp", "restore", fmt.Sprintf("restore the neighbor entries of %s failed", strings.Join(failed, ",")))
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+// getRouteDevice returns the interface of the route to the ip, the output is like `10.0.0.1 via 10.0.0.254 dev eth0 src 10.0.0.2`
+func (ae *NetworkArpExecutor) getRouteDevice(ctx context.Context, ip string) (string, *spec.Response) {
+	response := ae.channel.Run(ctx, "ip", "route get "+ip)
+	if !response.Success {
+		log.Errorf(ctx, "get the route to %s failed, %s", ip, response.Err)
+		return "", spec.ResponseFailWithFlags(spec.ParameterInvalid, "destination-ip", ip, response.Err)
+	}
+	fields := strings.Fields(response.Result.(string))
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "dev" {
+			return fields[i+1], nil
+		}
+	}
+	return "", spec.ResponseFailWithFlags(spec.ParameterInvalid, "destination-ip", ip, "no interface found by the route, please set the interface flag")
+}
+
+// getPermanentMac returns the mac of the permanent entry of the ip, the output is like `10.0.0.1 lladdr 00:16:3e:00:00:01 PERMANENT`
+func (ae *NetworkArpExecutor) getPermanentMac(ctx context.Context, ip, device string) (string, *spec.Response) {
+	response := ae.channel.Run(ctx, "ip", fmt.Sprintf("neigh show to %s dev %s nud permanent", ip, device))
+	if !response.Success {
+		log.Errorf(ctx, "get the neighbor entry of %s failed, %s", ip, response.Err)
+		return "", response
+	}
+	fields := strings.Fields(response.Result.(string))
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "lladdr" {
+			return fields[i+1], nil
+		}
+	}
+	return "", nil
+}
+
+func getArpStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-network-arp-%s.json", uid)
+}
+
+func writeArpState(stateFile string, entries []neighborEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readArpState(stateFile string) ([]neighborEntry, error) {
+	var entries []neighborEntry
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &entries)
+	return entries, err
+}
@@ -18,14 +18,23 @@ package network
 
 import (
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/network/tc"
 )
 
 func NewNetworkCommandSpec() spec.ExpModelCommandSpec {
+	// the flags of the tc actions are kept, but the packets are delayed or dropped by dummynet on darwin
+	delay := tc.NewDelayActionSpec()
+	delay.SetExecutor(&DummynetExecutor{action: "delay"})
+	loss := tc.NewLossActionSpec()
+	loss.SetExecutor(&DummynetExecutor{action: "loss"})
 	return &NetworkCommandSpec{
 		spec.BaseExpModelCommandSpec{
 			ExpActions: []spec.ExpActionCommandSpec{
+				delay,
 				NewDropActionSpec(),
 				NewDnsActionSpec(),
+				loss,
 				NewOccupyActionSpec(),
 			},
 			ExpFlags: []spec.ExpFlagSpec{},
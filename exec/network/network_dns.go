@@ -19,7 +19,9 @@ package network
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -27,6 +29,7 @@ import (
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/goodhosts/hostsfile"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/network/tc"
 )
@@ -36,8 +39,6 @@ var hosts = hostsfile.HostsFilePath
 const (
 	tmpHosts = "/tmp/chaos-hosts.tmp"
 	sep      = ","
-	// backupHostsFileFormat hostsfilePath.back-$uid
-	backupHostsFileFormat = "%s-%s"
 )
 
 type DnsActionSpec struct {
@@ -57,7 +58,7 @@ func NewDnsActionSpec() spec.ExpActionCommandSpec {
 				},
 				&spec.ExpFlag{
 					Name:                  "ip",
-					Desc:                  "Domain ip",
+					Desc:                  "Domain ip, the ipv4 or the ipv6 address",
 					Required:              true,
 					RequiredWhenDestroyed: true,
 				},
@@ -69,11 +70,37 @@ func NewDnsActionSpec() spec.ExpActionCommandSpec {
 					Required: false,
 					Default:  "false",
 				},
+				&spec.ExpFlag{
+					Name: "both-families",
+					Desc: "Also resolve the domain to the ipv6 blackhole, so the dual-stack clients preferring the AAAA " +
+						"record are affected too. The ip must be the ipv4 one",
+					NoArgs: true,
+				},
+				&spec.ExpFlag{
+					Name: "ipv6",
+					Desc: "The ipv6 blackhole of --both-families, default value is derived from the ip in the discard prefix 100::/64, " +
+						"such as 100::a00:1 for 10.0.0.1",
+				},
+				&spec.ExpFlag{
+					Name: "resolved-mode",
+					Desc: "Make systemd-resolved follow the hosts file if it's detected, flush flushes its cache, " +
+						"override also points the dns servers of the links to the blackhole-dns. They are restored on destroy",
+				},
+				&spec.ExpFlag{
+					Name: "blackhole-dns",
+					Desc: "The dns server which the links are pointed to in the override resolved-mode, default value is " + DefaultBlackholeDns,
+				},
 			},
 			ActionExecutor: &NetworkDnsExecutor{},
 			ActionExample: `
 # The domain name www.baidu.com is not accessible
-blade create network dns --domain www.baidu.com --ip 10.0.0.0`,
+blade create network dns --domain www.baidu.com --ip 10.0.0.0
+
+# The domain name www.baidu.com is not accessible by both the ipv4 and the ipv6 addresses
+blade create network dns --domain www.baidu.com --ip 10.0.0.0 --both-families
+
+# The domain name www.baidu.com is not accessible for the processes resolving by systemd-resolved too
+blade create network dns --domain www.baidu.com --ip 10.0.0.0 --resolved-mode override`,
 			ActionPrograms:   []string{tc.TcNetworkBin},
 			ActionCategories: []string{category.SystemNetwork},
 		},
@@ -108,19 +135,30 @@ func (*NetworkDnsExecutor) Name() string {
 }
 
 func (ns *NetworkDnsExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
-	commands := []string{"grep", "cat", "rm", "echo"}
+	commands := []string{"grep", "cat", "cp", "rm"}
 	if response, ok := ns.channel.IsAllCommandsAvailable(ctx, commands); !ok {
 		return response
 	}
 
+	// the hosts file is recovered by the uid, so the destroy doesn't need the domain and ip
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return ns.stop(ctx, uid)
+	}
 	domain := model.ActionFlags["domain"]
 	ip := model.ActionFlags["ip"]
 	if domain == "" || ip == "" {
 		log.Errorf(ctx, "domain|ip is nil")
 		return spec.ResponseFailWithFlags(spec.ParameterLess, "domain|ip")
 	}
-	if _, ok := spec.IsDestroy(ctx); ok {
-		return ns.stop(ctx, uid)
+	ips, response := getDnsIps(model.ActionFlags)
+	if response != nil {
+		return response
+	}
+
+	resolvedMode := model.ActionFlags["resolved-mode"]
+	blackhole := model.ActionFlags["blackhole-dns"]
+	if response := checkResolvedMode(resolvedMode, blackhole); response != nil {
+		return response
 	}
 
 	var (
@@ -133,51 +171,130 @@ func (ns *NetworkDnsExecutor) Exec(uid string, ctx context.Context, model *spec.
 		}
 	}
 
-	// backup hosts file for recover
-	if resp := ns.backupHostFile(ctx, uid); resp != nil && !resp.Success {
-		log.Errorf(ctx, "read hosts file failed, err: %v, uid: %s", resp.Error(), uid)
+	// restoring the backup of one experiment drops the pairs of the others, so they are not stacked
+	state, resp := exec.ClaimResources(ctx, ns.channel, uid, "network", "dns", model.ActionFlags, exec.HostsResource(hosts))
+	if resp != nil {
+		return resp
+	}
+	// backup hosts file for recover, it's restored by DestroyByState
+	if resp := BackupHostsFile(ctx, ns.channel, uid); !resp.Success {
+		log.Errorf(ctx, "backup hosts file failed, err: %v, uid: %s", resp.Error(), uid)
+		exec.DestroyByState(ctx, ns.channel, uid)
 		return resp
 	}
 
 	applier := newDnsApplier(ns.channel, replace)
-	return applier.Start(ctx, uid, domain, ip)
+	response = applier.Start(ctx, uid, domain, ips...)
+	if response.Success && resolvedMode != "" {
+		response = applyResolved(ctx, ns.channel, state, resolvedMode, blackhole)
+	}
+	if !response.Success {
+		ns.stop(ctx, uid)
+	}
+	return response
 }
 
 func (ns *NetworkDnsExecutor) stop(ctx context.Context, uid string) *spec.Response {
-	expHostsFile := fmt.Sprintf(backupHostsFileFormat, hosts, uid)
-	response := ns.channel.Run(ctx, "cat", fmt.Sprintf("%s > %s", expHostsFile, hosts))
-	if !response.Success {
-		if strings.Contains(response.Err, "No such file or directory") {
-			log.Warnf(ctx, "can not find backup hosts file for uid: %s", uid)
-			return spec.ReturnSuccess("The hosts file has been recovered")
+	state, err := exec.LoadState(uid)
+	if response, ok := exec.DestroyByState(ctx, ns.channel, uid); ok {
+		// the cache is flushed after the hosts file is restored, which is the last step of the destroy
+		if err == nil && !state.Destroyed && state.Details[resolvedDetail] == spec.True && response.Success {
+			if flushed := flushResolved(ctx, ns.channel); !flushed.Success {
+				log.Warnf(ctx, "flush the cache of systemd-resolved failed, %s", flushed.Err)
+			}
 		}
-		log.Errorf(ctx, "recover hosts file failed, %v, uid: %s", response.Err, uid)
 		return response
 	}
-	log.Infof(ctx, "recover hosts file successfully, pre: %s", expHostsFile)
-	return response
+	return RestoreHostsFile(ctx, ns.channel, uid)
 }
 
 func (ns *NetworkDnsExecutor) SetChannel(channel spec.Channel) {
 	ns.channel = channel
 }
 
+// getDnsIps validates the ip, and returns the ips which the domains are resolved to. The ipv6 blackhole is
+// added by --both-families, because the dual-stack clients still reach the domain by the AAAA record otherwise.
+func getDnsIps(flags map[string]string) ([]string, *spec.Response) {
+	ip := flags["ip"]
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "ip", ip, "it must be an ipv4 or ipv6 address")
+	}
+	ipv6 := flags["ipv6"]
+	if flags["both-families"] != spec.True {
+		if ipv6 != "" {
+			return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "ipv6", ipv6, "it's only used with --both-families")
+		}
+		return []string{ip}, nil
+	}
+	if parsed.To4() == nil {
+		return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "ip", ip,
+			"it must be the ipv4 address with --both-families, the ipv6 one is specified by --ipv6")
+	}
+	if ipv6 == "" {
+		return []string{ip, blackholeIPv6(parsed)}, nil
+	}
+	if parsed := net.ParseIP(ipv6); parsed == nil || parsed.To4() != nil {
+		return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "ipv6", ipv6, "it must be an ipv6 address")
+	}
+	return []string{ip, ipv6}, nil
+}
+
+// blackholeIPv6 returns the address in the discard prefix 100::/64 of RFC 6666 with the ipv4 in the low 32 bits
+func blackholeIPv6(ipv4 net.IP) string {
+	ip := make(net.IP, net.IPv6len)
+	ip[0] = 0x01
+	copy(ip[12:], ipv4.To4())
+	return ip.String()
+}
+
 func createDnsPair(domain, ip string) string {
 	return fmt.Sprintf("%s %s #chaosblade", ip, domain)
 }
 
-func (ns *NetworkDnsExecutor) backupHostFile(ctx context.Context, uid string) *spec.Response {
-	response := ns.channel.Run(ctx, "cp", fmt.Sprintf(
-		"%s %s", hosts, fmt.Sprintf(backupHostsFileFormat, hosts, uid),
-	))
+// BackupHostsFile backs up the hosts file for the experiment, which is used to recover the hosts file, see exec.Backup
+func BackupHostsFile(ctx context.Context, cl spec.Channel, uid string) *spec.Response {
+	entry, response := exec.Backup(ctx, cl, hosts, uid)
+	if response != nil {
+		return response
+	}
+	return spec.ReturnSuccess(entry.Backup)
+}
+
+// RestoreHostsFile recovers the hosts file from the backup of the experiment, including the backup named by
+// the old versions, it succeeds if the backup is not found
+func RestoreHostsFile(ctx context.Context, cl spec.Channel, uid string) *spec.Response {
+	response, ok := exec.Restore(ctx, cl, uid, hosts)
+	if !ok {
+		log.Warnf(ctx, "can not find backup hosts file for uid: %s", uid)
+		return spec.ReturnSuccess("The hosts file has been recovered")
+	}
 	if !response.Success {
+		log.Errorf(ctx, "recover hosts file failed, %v, uid: %s", response.Err, uid)
 		return response
 	}
+	log.Infof(ctx, "recover hosts file successfully, uid: %s", uid)
 	return response
 }
 
+// restoreFileContent overwrites the file with the content of the backup instead of replacing it,
+// because the hosts file may be mounted by the container
+func restoreFileContent(ctx context.Context, cl spec.Channel, backup, file string) *spec.Response {
+	response := exec.RunArgv(ctx, cl, "cat", "--", backup)
+	if !response.Success {
+		return response
+	}
+	return exec.WriteFile(ctx, cl, file, fmt.Sprint(response.Result))
+}
+
+// GetHostsBackupFile returns the backup of the hosts file of the experiment
+func GetHostsBackupFile(uid string) string {
+	return exec.GetBackupFile(hosts, uid)
+}
+
+// dnsApplier resolves the domains to the ips in the hosts file, an ip of each family at most
 type dnsApplier interface {
-	Start(ctx context.Context, uid, domainArg, ip string) *spec.Response
+	Start(ctx context.Context, uid, domainArg string, ips ...string) *spec.Response
 }
 
 type defaultApplier struct{ ch spec.Channel }
@@ -191,17 +308,24 @@ func newDnsApplier(ch spec.Channel, replace bool) dnsApplier {
 	return &defaultApplier{ch: ch}
 }
 
-func (m *defaultApplier) Start(ctx context.Context, _, domainArg, ip string) *spec.Response {
+func (m *defaultApplier) Start(ctx context.Context, _, domainArg string, ips ...string) *spec.Response {
 	domainArg = strings.ReplaceAll(domainArg, sep, " ")
-	dnsPair := createDnsPair(domainArg, ip)
-	resp := m.ch.Run(ctx, "grep", fmt.Sprintf(`-q "%s" %s`, dnsPair, hosts))
-	if resp.Success {
-		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("%s has been exist", dnsPair))
+	// the pairs are checked before any of them is appended
+	dnsPairs := ""
+	for _, ip := range ips {
+		dnsPair := createDnsPair(domainArg, ip)
+		// the pair is assumed not in the hosts file in dry-run, because the hosts file is not changed
+		exec.AssumeResponse(m.ch, "grep", exec.Fail(exec.CommandFailed, "hosts", "grep", "the pair is not found"))
+		resp := exec.RunArgv(ctx, m.ch, "grep", "-qF", "-e", dnsPair, hosts)
+		if resp.Success {
+			return exec.Fail(exec.RuleInstallFailed, "hosts", "append", fmt.Sprintf("%s has been exist", dnsPair))
+		}
+		dnsPairs += dnsPair + "\n"
 	}
-	return m.ch.Run(ctx, "echo", fmt.Sprintf(`"%s" >> %s`, dnsPair, hosts))
+	return exec.AppendFile(ctx, m.ch, hosts, dnsPairs)
 }
 
-func (m *replaceApplier) Start(ctx context.Context, uid, domainArg, ip string) *spec.Response {
+func (m *replaceApplier) Start(ctx context.Context, uid, domainArg string, ips ...string) *spec.Response {
 	domains := make([]string, 0)
 	for _, v := range strings.Split(domainArg, sep) {
 		if d := strings.TrimSpace(v); len(d) > 0 {
@@ -209,9 +333,9 @@ func (m *replaceApplier) Start(ctx context.Context, uid, domainArg, ip string) *
 		}
 	}
 
-	expHostsFile := fmt.Sprintf(backupHostsFileFormat, hosts, uid)
+	expHostsFile := GetHostsBackupFile(uid)
 	// cat /etc/hosts
-	response := m.ch.Run(ctx, "cat", hosts)
+	response := exec.RunReadOnly(ctx, m.ch, "cat", hosts)
 	if !response.Success {
 		log.Errorf(ctx, "read hosts file failed, %v, uid: %s", response.Err, uid)
 		return response
@@ -228,7 +352,7 @@ func (m *replaceApplier) Start(ctx context.Context, uid, domainArg, ip string) *
 	temp, err := os.CreateTemp("", "chaosblade-dns-")
 	if err != nil {
 		log.Errorf(ctx, "create temp file failed, %v, uid: %s", err, uid)
-		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "create temp file failed for dns infusion")
+		return exec.FailWithFlags(exec.RuleInstallFailed, "hosts", "create temp file for dns infusion", err)
 	}
 	defer func(name string) {
 		_ = os.Remove(name)
@@ -236,27 +360,42 @@ func (m *replaceApplier) Start(ctx context.Context, uid, domainArg, ip string) *
 
 	if err := os.WriteFile(temp.Name(), []byte(content), 0o644); err != nil {
 		log.Errorf(ctx, "write temp file failed, %v, uid: %s", err, uid)
-		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "write temp file failed for dns infusion")
+		return exec.FailWithFlags(exec.RuleInstallFailed, "hosts", "write temp file for dns infusion", err)
 	}
 
 	customHosts, err := hostsfile.NewCustomHosts(temp.Name())
 	if err != nil {
 		log.Errorf(ctx, "create hostsfile entity failed, %v, uid: %s", err, uid)
-		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "create hostsfile entity failed for dns infusion")
+		return exec.FailWithFlags(exec.RuleInstallFailed, "hosts", "create hostsfile entity for dns infusion", err)
 	}
 
-	if err := customHosts.Add(ip, domains...); err != nil {
-		log.Errorf(ctx, "add dns pair failed, %v, uid: %s", err, uid)
-		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "add dns pair failed for dns infusion")
+	// the domains are removed from all the lines first, since Add fails to remove a domain from more than one
+	// other ip, such as the ipv4 and the ipv6 of a dual-stack domain. Add removes the domains from the other
+	// ips, so the ips after the first one are added as the raw lines.
+	for _, domain := range domains {
+		if err := customHosts.RemoveByHostname(domain); err != nil {
+			log.Errorf(ctx, "remove dns pair failed, %v, uid: %s", err, uid)
+			return exec.FailWithFlags(exec.RuleInstallFailed, "hosts", "remove dns pair for dns infusion", err)
+		}
+	}
+	for i, ip := range ips {
+		if i == 0 {
+			err = customHosts.Add(ip, domains...)
+		} else {
+			err = customHosts.AddRaw(fmt.Sprintf("%s %s", ip, strings.Join(domains, " ")))
+		}
+		if err != nil {
+			log.Errorf(ctx, "add dns pair failed, %v, uid: %s", err, uid)
+			return exec.FailWithFlags(exec.RuleInstallFailed, "hosts", "add dns pair for dns infusion", err)
+		}
 	}
 	if err := customHosts.Flush(); err != nil {
 		log.Errorf(ctx, "flush hosts file failed, %v, uid: %s", err, uid)
-		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "flush hosts file failed for dns infusion")
+		return exec.FailWithFlags(exec.RuleInstallFailed, "hosts", "flush hosts file for dns infusion", err)
 	}
 	log.Debugf(ctx, "add dns pair successfully, uid: %s", uid)
 
-	// echo "$(hostsfile content)" > /etc/hosts
-	response = m.ch.Run(ctx, "echo", fmt.Sprintf("\"%s\" > %s", customHosts.String(), hosts))
+	response = exec.WriteFile(ctx, m.ch, hosts, customHosts.String()+"\n")
 	if !response.Success {
 		log.Errorf(ctx, "write hosts file failed, %v, uid: %s", response.Err, uid)
 		return response
@@ -264,3 +403,31 @@ func (m *replaceApplier) Start(ctx context.Context, uid, domainArg, ip string) *
 	log.Infof(ctx, "write hosts file successfully, uid: %s, backup: %s", uid, expHostsFile)
 	return response
 }
+
+// Status checks the backup of the hosts file and the domains resolved to the ip in the hosts file
+func (ns *NetworkDnsExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	if state == nil || state.Destroyed {
+		report.Add(exec.Artifact{Kind: "record", Name: exec.GetStateFile(uid), Detail: "the experiment is not recorded or destroyed"})
+		return report
+	}
+	backup := GetHostsBackupFile(uid)
+	report.Add(exec.Artifact{Kind: "file", Name: backup, Present: exec.CheckFilepathExists(ctx, ns.channel, backup)})
+	ips, response := getDnsIps(state.Flags)
+	if response != nil {
+		report.Add(exec.Artifact{Kind: "hosts", Name: state.Flags["ip"], Detail: response.Err})
+		return report
+	}
+	for _, ip := range ips {
+		for _, domain := range strings.Split(state.Flags["domain"], sep) {
+			if domain = strings.TrimSpace(domain); domain == "" {
+				continue
+			}
+			pattern := fmt.Sprintf(`^%s[[:space:]]+(.*[[:space:]])?%s([[:space:]]|$)`,
+				regexp.QuoteMeta(ip), regexp.QuoteMeta(domain))
+			response := exec.RunArgv(ctx, ns.channel, "grep", "-qE", "-e", pattern, hosts)
+			report.Add(exec.Artifact{Kind: "hosts", Name: fmt.Sprintf("%s %s", ip, domain), Present: response.Success})
+		}
+	}
+	return report
+}
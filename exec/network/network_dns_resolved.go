@@ -0,0 +1,135 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+const (
+	// ResolvedModeFlush flushes the cache of systemd-resolved after the hosts file is changed and restored
+	ResolvedModeFlush = "flush"
+	// ResolvedModeOverride also points the per-link dns servers of systemd-resolved to the blackhole
+	ResolvedModeOverride = "override"
+
+	// DefaultBlackholeDns is in TEST-NET-1, which is never routed, so the queries time out
+	DefaultBlackholeDns = "192.0.2.1"
+
+	// resolvedStubIp is the stub resolver of systemd-resolved in resolv.conf
+	resolvedStubIp = "127.0.0.53"
+	// resolvedDetail is the detail of the record, it's true if systemd-resolved is detected at the create
+	resolvedDetail = "resolved"
+)
+
+var resolvConf = "/etc/resolv.conf"
+
+// resolvedLinkPattern matches the line of `resolvectl dns`, such as "Link 2 (eth0): 10.0.0.2 10.0.0.3"
+var resolvedLinkPattern = regexp.MustCompile(`^Link\s+\d+\s+\(([^)]+)\):(.*)$`)
+
+// resolvedLink is the link of systemd-resolved with its dns servers
+type resolvedLink struct {
+	Name    string
+	Servers []string
+}
+
+// checkResolvedMode validates the resolved-mode and blackhole-dns flags
+func checkResolvedMode(mode, blackhole string) *spec.Response {
+	if mode != "" && mode != ResolvedModeFlush && mode != ResolvedModeOverride {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "resolved-mode", mode,
+			fmt.Sprintf("it must be %s or %s", ResolvedModeFlush, ResolvedModeOverride))
+	}
+	if blackhole != "" && net.ParseIP(blackhole) == nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "blackhole-dns", blackhole, "it must be an ip")
+	}
+	return nil
+}
+
+// detectResolved returns true if resolvectl is available and resolv.conf points to the stub resolver
+func detectResolved(ctx context.Context, cl spec.Channel) bool {
+	if !cl.IsCommandAvailable(ctx, "resolvectl") {
+		return false
+	}
+	return exec.RunReadOnlyArgv(ctx, cl, "grep", "-qF", "-e", resolvedStubIp, resolvConf).Success
+}
+
+// applyResolved makes systemd-resolved follow the experiment by the mode, nothing is changed if it's not
+// detected. The detection is recorded for the destroy, and the overridden dns servers of the links are
+// restored by the undo commands.
+func applyResolved(ctx context.Context, cl spec.Channel, state *exec.ExperimentState, mode, blackhole string) *spec.Response {
+	if !detectResolved(ctx, cl) {
+		log.Infof(ctx, "systemd-resolved is not detected, the resolved-mode %s is skipped", mode)
+		return spec.ReturnSuccess(state.Uid)
+	}
+	// the backup of the hosts file is recorded since the record is created
+	if err := state.Reload(); err != nil {
+		return exec.Fail(exec.StateRecordFailed, "resolved", "Reload", fmt.Sprintf("read the record of the experiment failed, %v", err))
+	}
+	if err := state.SetDetail(resolvedDetail, spec.True); err != nil {
+		return exec.Fail(exec.StateRecordFailed, "resolved", "SetDetail", fmt.Sprintf("record the detection of systemd-resolved failed, %v", err))
+	}
+	if mode == ResolvedModeOverride {
+		response := exec.RunReadOnlyArgv(ctx, cl, "resolvectl", "dns")
+		if !response.Success {
+			return response
+		}
+		if blackhole == "" {
+			blackhole = DefaultBlackholeDns
+		}
+		for _, link := range parseResolvedLinks(fmt.Sprint(response.Result)) {
+			if len(link.Servers) == 0 {
+				continue
+			}
+			// the original servers are recorded before the change, setting them again is harmless
+			if err := state.AddUndo("resolvectl", exec.ShellJoin(append([]string{"dns", link.Name}, link.Servers...)...)); err != nil {
+				return exec.Fail(exec.StateRecordFailed, "resolved", "AddUndo", fmt.Sprintf("record the dns servers of %s failed, %v", link.Name, err))
+			}
+			log.Infof(ctx, "point the dns servers of %s to %s, the original ones are %v", link.Name, blackhole, link.Servers)
+			if response := exec.RunArgv(ctx, cl, "resolvectl", "dns", link.Name, blackhole); !response.Success {
+				return response
+			}
+		}
+	}
+	return flushResolved(ctx, cl)
+}
+
+// flushResolved drops the cached answers of systemd-resolved
+func flushResolved(ctx context.Context, cl spec.Channel) *spec.Response {
+	return exec.RunArgv(ctx, cl, "resolvectl", "flush-caches")
+}
+
+// parseResolvedLinks parses the output of `resolvectl dns`, the global servers are skipped because they are
+// configured by resolved.conf
+func parseResolvedLinks(output string) []resolvedLink {
+	links := make([]resolvedLink, 0)
+	for _, line := range strings.Split(output, "\n") {
+		matches := resolvedLinkPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			continue
+		}
+		links = append(links, resolvedLink{Name: matches[1], Servers: strings.Fields(matches[2])})
+	}
+	return links
+}
@@ -2,12 +2,18 @@ package network
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/goodhosts/hostsfile"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 )
 
 func Test_replaceApplier_e2e(t *testing.T) {
@@ -304,3 +310,314 @@ func Test_defaultApplier_e2e(t *testing.T) {
 		})
 	}
 }
+
+func assertCommands(t *testing.T, cl *exec.MockChannel, expected []string) {
+	t.Helper()
+	if actual := cl.CommandLines(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected commands\nactual:   %q\nexpected: %q", actual, expected)
+	}
+}
+
+func execDns(ctx context.Context, cl spec.Channel, uid string, flags map[string]string) *spec.Response {
+	executor := &NetworkDnsExecutor{}
+	executor.SetChannel(cl)
+	return executor.Exec(uid, ctx, &spec.ExpModel{Target: "network", ActionName: "dns", ActionFlags: flags})
+}
+
+func TestNetworkDnsExecutor(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	original := hosts
+	hosts = "/etc/hosts"
+	defer func() { hosts = original }()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().OnRun("grep", "", spec.ReturnFail(spec.OsCmdExecFailed, "not found")).
+		OnRun("sha256sum", "", spec.ReturnSuccess("9f86d081  /etc/hosts.chaos-blade-backup-dns-1\n"))
+	flags := map[string]string{"domain": "foo.bar,bar.baz", "ip": "10.0.0.1"}
+
+	if response := execDns(ctx, cl, "dns-1", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"cp -p -- /etc/hosts /etc/hosts.chaos-blade-backup-dns-1",
+		"sha256sum -- /etc/hosts.chaos-blade-backup-dns-1",
+		"grep -qF -e '10.0.0.1 foo.bar bar.baz #chaosblade' /etc/hosts",
+		"printf %s '10.0.0.1 foo.bar bar.baz #chaosblade\n' >> /etc/hosts",
+	})
+
+	// the hosts file is overwritten by cp in place after verifying the checksum of the backup
+	cl.Reset()
+	if response := execDns(spec.SetDestroyFlag(ctx, "dns-1"), cl, "dns-1", map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"test -e /etc/hosts.chaos-blade-backup-dns-1",
+		"sha256sum -- /etc/hosts.chaos-blade-backup-dns-1",
+		"cp -p -- /etc/hosts.chaos-blade-backup-dns-1 /etc/hosts",
+		"rm -f -- /etc/hosts.chaos-blade-backup-dns-1",
+	})
+}
+
+func TestNetworkDnsExecutorTamperedBackup(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	original := hosts
+	hosts = "/etc/hosts"
+	defer func() { hosts = original }()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().OnRun("grep", "", spec.ReturnFail(spec.OsCmdExecFailed, "not found")).
+		OnRun("sha256sum", "", spec.ReturnSuccess("9f86d081  /etc/hosts.chaos-blade-backup-dns-5\n"))
+	if response := execDns(ctx, cl, "dns-5", map[string]string{"domain": "foo.bar", "ip": "10.0.0.1"}); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+
+	// the hosts file is kept if the backup is changed, and the destroy can be retried
+	cl.OnRun("sha256sum", "", spec.ReturnSuccess("e3b0c442  /etc/hosts.chaos-blade-backup-dns-5\n"))
+	cl.Reset()
+	response := execDns(spec.SetDestroyFlag(ctx, "dns-5"), cl, "dns-5", map[string]string{})
+	if failure, ok := exec.GetFailure(response); response.Success || !ok || failure.Kind != exec.RestoreFailed.Name {
+		t.Fatalf("expected the restore failure, %+v", response)
+	}
+	for _, command := range cl.CommandLines() {
+		if strings.HasPrefix(command, "cp ") || strings.HasPrefix(command, "rm ") {
+			t.Errorf("unexpected command with the tampered backup, %s", command)
+		}
+	}
+	if state, err := exec.LoadState("dns-5"); err != nil || state.Destroyed {
+		t.Errorf("expected the record kept for the retry, %+v, %v", state, err)
+	}
+}
+
+func TestRestoreHostsFileLegacyBackup(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	original := hosts
+	hosts = "/etc/hosts"
+	defer func() { hosts = original }()
+	// the old versions backed up the hosts file to <hosts>-<uid> without the record
+	cl := exec.NewMockChannel().OnRun("test", "chaos-blade-backup", spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+	if response := RestoreHostsFile(context.Background(), cl, "dns-6"); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"test -e /etc/hosts.chaos-blade-backup-dns-6",
+		"test -e /etc/hosts-dns-6",
+		"test -e /etc/hosts-dns-6",
+		"cp -p -- /etc/hosts-dns-6 /etc/hosts",
+		"rm -f -- /etc/hosts-dns-6",
+	})
+}
+
+func TestNetworkDnsExecutorReplace(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	original := hosts
+	hosts = "/etc/hosts"
+	defer func() { hosts = original }()
+	cl := exec.NewMockChannel().
+		OnRun("cat", "^/etc/hosts$", spec.ReturnSuccess("127.0.0.1 localhost\n192.168.1.1 foo.bar\n"))
+	flags := map[string]string{"domain": "foo.bar", "ip": "10.0.0.1", "replace": "true"}
+
+	if response := execDns(context.Background(), cl, "dns-2", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	commands := cl.CommandLines()
+	if len(commands) != 4 || commands[0] != "cp -p -- /etc/hosts /etc/hosts.chaos-blade-backup-dns-2" ||
+		commands[2] != "cat /etc/hosts" || !strings.HasPrefix(commands[3], "printf %s ") ||
+		!strings.HasSuffix(commands[3], " > /etc/hosts") {
+		t.Fatalf("unexpected commands, %q", commands)
+	}
+	if strings.Contains(commands[3], "192.168.1.1") || !strings.Contains(commands[3], "10.0.0.1") {
+		t.Errorf("expected the pair replaced, %s", commands[3])
+	}
+}
+
+func TestNetworkDnsExecutorConflict(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().OnRun("grep", "", spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+	flags := map[string]string{"domain": "foo.bar", "ip": "10.0.0.1"}
+	if response := execDns(ctx, cl, "dns-3", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	// the backup of the running experiment is effective, so the second one is refused before any change
+	cl.Reset()
+	if response := execDns(ctx, cl, "dns-4", flags); response.Success {
+		t.Fatalf("expected the conflict with dns-3")
+	}
+	for _, command := range cl.CommandLines() {
+		if !strings.HasPrefix(command, "test -e ") {
+			t.Errorf("unexpected command of the refused experiment, %s", command)
+		}
+	}
+}
+
+func TestNetworkDnsExecutorResolved(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	original := hosts
+	hosts = "/etc/hosts"
+	defer func() { hosts = original }()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().OnRun("grep", "", spec.ReturnFail(spec.OsCmdExecFailed, "not found")).
+		OnRun("grep", "127.0.0.53", spec.ReturnSuccess("")).
+		OnRun("sha256sum", "", spec.ReturnSuccess("9f86d081  /etc/hosts.chaos-blade-backup-dns-7\n")).
+		OnRun("resolvectl", "^dns$", spec.ReturnSuccess("Global: 1.1.1.1\nLink 2 (eth0): 10.0.0.2 10.0.0.3\nLink 3 (docker0):\n"))
+	flags := map[string]string{"domain": "foo.bar", "ip": "10.0.0.1", "resolved-mode": ResolvedModeOverride}
+
+	if response := execDns(ctx, cl, "dns-7", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"cp -p -- /etc/hosts /etc/hosts.chaos-blade-backup-dns-7",
+		"sha256sum -- /etc/hosts.chaos-blade-backup-dns-7",
+		"grep -qF -e '10.0.0.1 foo.bar #chaosblade' /etc/hosts",
+		"printf %s '10.0.0.1 foo.bar #chaosblade\n' >> /etc/hosts",
+		"grep -qF -e 127.0.0.53 /etc/resolv.conf",
+		"resolvectl dns",
+		"resolvectl dns eth0 192.0.2.1",
+		"resolvectl flush-caches",
+	})
+	if state, err := exec.LoadState("dns-7"); err != nil || state.Details[resolvedDetail] != spec.True {
+		t.Errorf("expected the detection recorded, %+v, %v", state, err)
+	}
+
+	// the servers are restored before the hosts file, and the cache is flushed at last
+	cl.Reset()
+	if response := execDns(spec.SetDestroyFlag(ctx, "dns-7"), cl, "dns-7", map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"resolvectl dns eth0 10.0.0.2 10.0.0.3",
+		"test -e /etc/hosts.chaos-blade-backup-dns-7",
+		"sha256sum -- /etc/hosts.chaos-blade-backup-dns-7",
+		"cp -p -- /etc/hosts.chaos-blade-backup-dns-7 /etc/hosts",
+		"rm -f -- /etc/hosts.chaos-blade-backup-dns-7",
+		"resolvectl flush-caches",
+	})
+}
+
+func TestNetworkDnsExecutorWithoutResolved(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	original := hosts
+	hosts = "/etc/hosts"
+	defer func() { hosts = original }()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().OnRun("grep", "", spec.ReturnFail(spec.OsCmdExecFailed, "not found")).
+		OnRun("sha256sum", "", spec.ReturnSuccess("9f86d081  /etc/hosts.chaos-blade-backup-dns-8\n")).
+		SetCommandAvailable("resolvectl", false)
+	flags := map[string]string{"domain": "foo.bar", "ip": "10.0.0.1", "resolved-mode": ResolvedModeFlush}
+
+	// nothing but the hosts file is changed
+	if response := execDns(ctx, cl, "dns-8", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"cp -p -- /etc/hosts /etc/hosts.chaos-blade-backup-dns-8",
+		"sha256sum -- /etc/hosts.chaos-blade-backup-dns-8",
+		"grep -qF -e '10.0.0.1 foo.bar #chaosblade' /etc/hosts",
+		"printf %s '10.0.0.1 foo.bar #chaosblade\n' >> /etc/hosts",
+	})
+	cl.Reset()
+	if response := execDns(spec.SetDestroyFlag(ctx, "dns-8"), cl, "dns-8", map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	for _, command := range cl.CommandLines() {
+		if strings.HasPrefix(command, "resolvectl") {
+			t.Errorf("unexpected command without systemd-resolved, %s", command)
+		}
+	}
+
+	if response := execDns(ctx, cl, "dns-9", map[string]string{"domain": "foo.bar", "ip": "10.0.0.1",
+		"resolved-mode": "restart"}); response.Success || response.Code != spec.ParameterIllegal.Code {
+		t.Errorf("expected the illegal resolved-mode, %+v", response)
+	}
+}
+
+func TestGetDnsIps(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    map[string]string
+		expected []string
+		illegal  bool
+	}{
+		{"ipv4", map[string]string{"ip": "10.0.0.1"}, []string{"10.0.0.1"}, false},
+		{"ipv6", map[string]string{"ip": "2001:db8::1"}, []string{"2001:db8::1"}, false},
+		{"invalid", map[string]string{"ip": "10.0.0.256"}, nil, true},
+		{"derived", map[string]string{"ip": "10.0.0.1", "both-families": "true"}, []string{"10.0.0.1", "100::a00:1"}, false},
+		{"specified", map[string]string{"ip": "10.0.0.1", "both-families": "true", "ipv6": "100::1"}, []string{"10.0.0.1", "100::1"}, false},
+		{"ipv6 with both families", map[string]string{"ip": "100::1", "both-families": "true"}, nil, true},
+		{"ipv4 as ipv6", map[string]string{"ip": "10.0.0.1", "both-families": "true", "ipv6": "10.0.0.2"}, nil, true},
+		{"ipv6 without both families", map[string]string{"ip": "10.0.0.1", "ipv6": "100::1"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ips, response := getDnsIps(tt.flags)
+			if tt.illegal {
+				if response == nil || response.Code != spec.ParameterIllegal.Code {
+					t.Errorf("expected the illegal parameter, got %v, %+v", ips, response)
+				}
+				return
+			}
+			if response != nil || !reflect.DeepEqual(ips, tt.expected) {
+				t.Errorf("expected %v, got %v, %+v", tt.expected, ips, response)
+			}
+		})
+	}
+}
+
+func Test_dnsApplier_families_e2e(t *testing.T) {
+	original := hosts
+	defer func() { hosts = original }()
+	originHosts := `
+127.0.0.1	localhost
+::1	localhost ip6-localhost
+192.168.1.1 foo.bar
+2001:db8::1 foo.bar
+`
+	tests := []struct {
+		name string
+		ips  []string
+	}{
+		{"ipv4 only", []string{"10.0.0.1"}},
+		{"ipv6 only", []string{"100::1"}},
+		{"dual", []string{"10.0.0.1", "100::a00:1"}},
+	}
+	for _, replace := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s replace=%t", tt.name, replace), func(t *testing.T) {
+				exec.StateDir = t.TempDir()
+				hosts = filepath.Join(t.TempDir(), "hosts")
+				if err := os.WriteFile(hosts, []byte(originHosts), 0o644); err != nil {
+					t.Fatalf("write hosts file error: %v", err)
+				}
+				cl := channel.NewLocalChannel()
+				if response := BackupHostsFile(context.Background(), cl, "families"); !response.Success {
+					t.Fatalf("backup hosts file error: %s", response.Err)
+				}
+				if got := newDnsApplier(cl, replace).Start(context.Background(), "families", "foo.bar,bar.baz", tt.ips...); !got.Success {
+					t.Fatalf("start() = %v", got)
+				}
+
+				h, err := hostsfile.NewCustomHosts(hosts)
+				if err != nil {
+					t.Fatalf("create hosts file error: %v", err)
+				}
+				for _, ip := range tt.ips {
+					for _, domain := range []string{"foo.bar", "bar.baz"} {
+						if !h.Has(ip, domain) {
+							t.Errorf("expected %s %s in the hosts file:\n%s", ip, domain, h.String())
+						}
+					}
+				}
+				// the original pairs of both the families are removed by the replace
+				if replace && (h.Has("192.168.1.1", "foo.bar") || h.Has("2001:db8::1", "foo.bar")) {
+					t.Errorf("expected the original pairs are replaced:\n%s", h.String())
+				}
+
+				e := NetworkDnsExecutor{channel: cl}
+				if got := e.stop(context.Background(), "families"); !got.Success {
+					t.Fatalf("stop() = %v", got)
+				}
+				if content, err := os.ReadFile(hosts); err != nil || string(content) != originHosts {
+					t.Errorf("expected the hosts file is restored, got %q, %v", content, err)
+				}
+			})
+		}
+	}
+}
@@ -19,10 +19,12 @@ package network
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
 )
 
@@ -82,7 +84,7 @@ func (*NetworkDnsDownExecutor) Name() string {
 }
 
 func (ns *NetworkDnsDownExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
-	commands := []string{"grep", "cat", "rm", "echo", "iptables", "iptables-save", "iptables-restore", "nslookup", "awk", "ping", "tee", "tail"}
+	commands := []string{"cat", "cp", "rm", "iptables", "iptables-save", "iptables-restore", "nslookup", "ping"}
 	if response, ok := ns.channel.IsAllCommandsAvailable(ctx, commands); !ok {
 		return response
 	}
@@ -95,45 +97,76 @@ func (ns *NetworkDnsDownExecutor) Exec(uid string, ctx context.Context, model *s
 
 const iptablesBackup = "/tmp/iptables-backup.txt"
 
+var ipv4Pattern = regexp.MustCompile(`([0-9]{1,3}\.){3}[0-9]{1,3}`)
+
 func (ns *NetworkDnsDownExecutor) start(ctx context.Context, allowDomains []string) *spec.Response {
 	if len(allowDomains) > 0 {
-		backHosts := ns.channel.Run(ctx, "cat", fmt.Sprintf("%s > %s", hosts, tmpHosts))
+		backHosts := exec.RunArgv(ctx, ns.channel, "cp", "--", hosts, tmpHosts)
 		if !backHosts.Success {
-			return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("Backup hosts file failed. Error: %s", backHosts.Err))
+			return exec.Fail(exec.BackupFailed, "hosts", "backup", fmt.Sprintf("Backup hosts file failed. Error: %s", backHosts.Err))
 		}
 		for _, domain := range allowDomains {
-			resp := ns.channel.Run(ctx, "", fmt.Sprintf(`domain="%s" && if ping -c 1 $domain > /dev/null; then echo "$(nslookup $domain | grep 'Address:' | grep -oE '([0-9]{1,3}\.){3}[0-9]{1,3}' | grep -v ':' | tail -1) $domain" | sudo tee -a %s; fi`, domain, hosts))
+			if domain = strings.TrimSpace(domain); domain == "" {
+				continue
+			}
+			if !exec.RunArgv(ctx, ns.channel, "ping", "-c", "1", domain).Success {
+				continue
+			}
+			resp := exec.RunArgv(ctx, ns.channel, "nslookup", domain)
+			if resp.Success {
+				resp = exec.AppendFile(ctx, ns.channel, hosts, fmt.Sprintf("%s %s\n", lastResolvedIPv4(fmt.Sprint(resp.Result)), domain))
+			}
 			if !resp.Success {
-				_ = ns.channel.Run(ctx, "cat", fmt.Sprintf("%s > %s", tmpHosts, hosts)) // recover
-				return spec.ReturnFail(spec.OsCmdExecFailed, resp.Err)
+				_ = restoreFileContent(ctx, ns.channel, tmpHosts, hosts) // recover
+				return exec.Fail(exec.RuleInstallFailed, "hosts", "allow "+domain, resp.Err)
 			}
 		}
 	}
 	// backup iptables rules
-	bkIptables := ns.channel.Run(ctx, "iptables-save", fmt.Sprintf("> %s", iptablesBackup))
+	bkIptables := exec.RunArgv(ctx, ns.channel, "iptables-save")
+	if bkIptables.Success {
+		bkIptables = exec.WriteFile(ctx, ns.channel, iptablesBackup, fmt.Sprint(bkIptables.Result))
+	}
 	if !bkIptables.Success {
-		return spec.ReturnFail(spec.OsCmdExecFailed, bkIptables.Error())
+		return exec.Fail(exec.BackupFailed, "iptables", "iptables-save", bkIptables.Error())
 	}
 	// dns_down
-	dnsDown := ns.channel.Run(ctx, "iptables", "-A OUTPUT -p udp --dport 53 -j DROP; iptables -A OUTPUT -p tcp --dport 53 -j DROP")
+	dnsDown := exec.RunIptablesArgv(ctx, ns.channel, "-A", "OUTPUT", "-p", "udp", "--dport", "53", "-j", "DROP")
+	if dnsDown.Success {
+		dnsDown = exec.RunIptablesArgv(ctx, ns.channel, "-A", "OUTPUT", "-p", "tcp", "--dport", "53", "-j", "DROP")
+	}
 	if !dnsDown.Success {
-		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf(`DNS dwon fialed for %s, you can use "iptables-restore < %s" to restore your iptable rules if needed.`, dnsDown.Err, iptablesBackup))
+		return exec.Fail(exec.RuleInstallFailed, "iptables", "drop dns", fmt.Sprintf(`DNS dwon fialed for %s, you can use "iptables-restore < %s" to restore your iptable rules if needed.`, dnsDown.Err, iptablesBackup))
 	}
 	return dnsDown
 }
 
+// lastResolvedIPv4 returns the last ipv4 address of the nslookup output, the first one is the address of the dns server
+func lastResolvedIPv4(output string) string {
+	var ip string
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "Address:") {
+			continue
+		}
+		if matches := ipv4Pattern.FindAllString(line, -1); len(matches) > 0 {
+			ip = matches[len(matches)-1]
+		}
+	}
+	return ip
+}
+
 func (ns *NetworkDnsDownExecutor) stop(ctx context.Context, allowDomains []string) *spec.Response {
-	recoverDns := ns.channel.Run(ctx, "iptables-restore", fmt.Sprintf("< %s", iptablesBackup))
+	recoverDns := ns.channel.Run(ctx, "iptables-restore", "< "+exec.ShellQuote(iptablesBackup))
 	if !recoverDns.Success {
-		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf(`DNS recover fialed for %s, you can use "iptables-restore < %s" to restore your iptables rules if needed.`, recoverDns.Err, iptablesBackup))
+		return exec.Fail(exec.RuleRemoveFailed, "iptables", "iptables-restore", fmt.Sprintf(`DNS recover fialed for %s, you can use "iptables-restore < %s" to restore your iptables rules if needed.`, recoverDns.Err, iptablesBackup))
 	}
 	if len(allowDomains) > 0 {
-		recoverHosts := ns.channel.Run(ctx, "cat", fmt.Sprintf("%s > %s", tmpHosts, hosts))
+		recoverHosts := restoreFileContent(ctx, ns.channel, tmpHosts, hosts)
 		if !recoverHosts.Success {
-			return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("Restore hosts file failed. Error: %s, a backup of hosts is in %s", recoverHosts.Err, tmpHosts))
+			return exec.Fail(exec.RestoreFailed, "hosts", "restore", fmt.Sprintf("Restore hosts file failed. Error: %s, a backup of hosts is in %s", recoverHosts.Err, tmpHosts))
 		}
 	}
-	return ns.channel.Run(ctx, "rm", fmt.Sprintf("-rf %s %s", tmpHosts, iptablesBackup))
+	return exec.RunArgv(ctx, ns.channel, "rm", "-rf", "--", tmpHosts, iptablesBackup)
 }
 
 func (ns *NetworkDnsDownExecutor) SetChannel(channel spec.Channel) {
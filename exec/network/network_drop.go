@@ -18,12 +18,14 @@ package network
 
 import (
 	"context"
-	"fmt"
 	"strings"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
 )
 
 const DropNetworkBin = "chaos_dropnetwork"
@@ -60,6 +62,18 @@ func NewDropActionSpec() spec.ExpActionCommandSpec {
 					Name: "network-traffic",
 					Desc: "The direction of network traffic",
 				},
+				&spec.ExpFlag{
+					Name:   exec.AffectLoopbackKey,
+					Desc:   "Allow the experiment on the loopback source or destination ip, the port of the blade server and the ssh session are excluded anyway",
+					NoArgs: true,
+				},
+				&spec.ExpFlag{
+					Name:   exec.NoProtectSSHKey,
+					Desc:   "Drop the ssh sessions too, by default the established sessions of the local port 22 and the one running the experiment are accepted on linux",
+					NoArgs: true,
+				},
+				container.VethFlags[0],
+				container.VethFlags[1],
 			},
 			ActionFlags:    []spec.ExpFlagSpec{},
 			ActionExecutor: &NetworkDropExecutor{},
@@ -84,6 +98,12 @@ blade create network drop --string-pattern baidu.com --network-traffic out
 
 # Block outgoing connection to the specific domain on port 80
 blade create network drop --destination-port 80 --string-pattern baidu.com --network-traffic out
+
+# Block outgoing connection to the local port 8080, the port of the blade server is kept
+blade create network drop --destination-ip 127.0.0.1 --destination-port 8080 --network-traffic out --affect-loopback
+
+# Block the outgoing connection of the container to the port 3306 on its host side veth, on linux only
+blade create network drop --destination-port 3306 --network-traffic out --veth-container 4ee9e4a1e2ea
 `,
 			ActionPrograms:   []string{DropNetworkBin},
 			ActionCategories: []string{category.SystemNetwork},
@@ -119,8 +139,7 @@ func (*NetworkDropExecutor) Name() string {
 }
 
 func (ne *NetworkDropExecutor) Exec(suid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
-	commands := []string{"iptables"}
-	if response, ok := ne.channel.IsAllCommandsAvailable(ctx, commands); !ok {
+	if response, ok := checkDropCommands(ctx, ne.channel); !ok {
 		return response
 	}
 
@@ -130,128 +149,51 @@ func (ne *NetworkDropExecutor) Exec(suid string, ctx context.Context, model *spe
 	destinationPort := model.ActionFlags["destination-port"]
 	stringPattern := model.ActionFlags["string-pattern"]
 	networkTraffic := model.ActionFlags["network-traffic"]
-	if _, ok := spec.IsDestroy(ctx); ok {
-		return ne.stop(sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
-	}
-
-	return ne.start(sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
-}
-
-func (ne *NetworkDropExecutor) start(sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic string, ctx context.Context) *spec.Response {
-	if destinationIp == "" && sourceIp == "" && destinationPort == "" && sourcePort == "" && stringPattern == "" {
-		return spec.ReturnFail(spec.OsCmdExecFailed, "must specify ip or port or string flag")
-	}
-
-	var response *spec.Response
-	netFlows := []string{"INPUT", "OUTPUT"}
-	if networkTraffic == "in" {
-		netFlows = []string{"INPUT"}
-	}
-	if networkTraffic == "out" {
-		netFlows = []string{"OUTPUT"}
-	}
-	for _, netFlow := range netFlows {
-		tcpArgs := fmt.Sprintf("-A %s -p tcp", netFlow)
-		udpArgs := fmt.Sprintf("-A %s -p udp", netFlow)
-		if sourceIp != "" {
-			tcpArgs = fmt.Sprintf("%s -s %s", tcpArgs, sourceIp)
-			udpArgs = fmt.Sprintf("%s -s %s", udpArgs, sourceIp)
-		}
-		if destinationIp != "" {
-			tcpArgs = fmt.Sprintf("%s -d %s", tcpArgs, destinationIp)
-			udpArgs = fmt.Sprintf("%s -d %s", udpArgs, destinationIp)
-		}
-		if sourcePort != "" {
-			if strings.Contains(sourcePort, ",") {
-				tcpArgs = fmt.Sprintf("%s -m multiport --sports %s", tcpArgs, sourcePort)
-				udpArgs = fmt.Sprintf("%s -m multiport --sports %s", udpArgs, sourcePort)
-			} else {
-				tcpArgs = fmt.Sprintf("%s --sport %s", tcpArgs, sourcePort)
-				udpArgs = fmt.Sprintf("%s --sport %s", udpArgs, sourcePort)
-			}
-		}
-		if destinationPort != "" {
-			if strings.Contains(destinationPort, ",") {
-				tcpArgs = fmt.Sprintf("%s -m multiport --dports %s", tcpArgs, destinationPort)
-				udpArgs = fmt.Sprintf("%s -m multiport --dports %s", udpArgs, destinationPort)
-			} else {
-				tcpArgs = fmt.Sprintf("%s --dport %s", tcpArgs, destinationPort)
-				udpArgs = fmt.Sprintf("%s --dport %s", udpArgs, destinationPort)
-			}
-		}
-		if stringPattern != "" {
-			tcpArgs = fmt.Sprintf("%s -m string --string %s --algo bm", tcpArgs, stringPattern)
-			udpArgs = fmt.Sprintf("%s -m string --string %s --algo bm", udpArgs, stringPattern)
-		}
-		tcpArgs = fmt.Sprintf("%s -j DROP", tcpArgs)
-		udpArgs = fmt.Sprintf("%s -j DROP", udpArgs)
-		response = ne.channel.Run(ctx, "iptables", fmt.Sprintf(`%s`, tcpArgs))
-		if !response.Success {
-			ne.stop(sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
+	if sourceIp != "" {
+		ips, response := validateDropIps("source-ip", sourceIp)
+		if response != nil {
 			return response
 		}
-		response = ne.channel.Run(ctx, "iptables", fmt.Sprintf(`%s`, udpArgs))
-		if !response.Success {
-			ne.stop(sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
-		}
-	}
-	return response
-}
-
-func (ne *NetworkDropExecutor) stop(sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic string, ctx context.Context) *spec.Response {
-	var response *spec.Response
-	netFlows := []string{"INPUT", "OUTPUT"}
-	if networkTraffic == "in" {
-		netFlows = []string{"INPUT"}
-	}
-	if networkTraffic == "out" {
-		netFlows = []string{"OUTPUT"}
+		sourceIp = strings.Join(ips, ",")
 	}
-	for _, netFlow := range netFlows {
-		tcpArgs := fmt.Sprintf("-D %s -p tcp", netFlow)
-		udpArgs := fmt.Sprintf("-D %s -p udp", netFlow)
-		if sourceIp != "" {
-			tcpArgs = fmt.Sprintf("%s -s %s", tcpArgs, sourceIp)
-			udpArgs = fmt.Sprintf("%s -s %s", udpArgs, sourceIp)
-		}
-		if destinationIp != "" {
-			tcpArgs = fmt.Sprintf("%s -d %s", tcpArgs, destinationIp)
-			udpArgs = fmt.Sprintf("%s -d %s", udpArgs, destinationIp)
-		}
-		if sourcePort != "" {
-			if strings.Contains(sourcePort, ",") {
-				tcpArgs = fmt.Sprintf("%s -m multiport --sports %s", tcpArgs, sourcePort)
-				udpArgs = fmt.Sprintf("%s -m multiport --sports %s", udpArgs, sourcePort)
-			} else {
-				tcpArgs = fmt.Sprintf("%s --sport %s", tcpArgs, sourcePort)
-				udpArgs = fmt.Sprintf("%s --sport %s", udpArgs, sourcePort)
-			}
-		}
-		if destinationPort != "" {
-			if strings.Contains(destinationPort, ",") {
-				tcpArgs = fmt.Sprintf("%s -m multiport --dports %s", tcpArgs, destinationPort)
-				udpArgs = fmt.Sprintf("%s -m multiport --dports %s", udpArgs, destinationPort)
-			} else {
-				tcpArgs = fmt.Sprintf("%s --dport %s", tcpArgs, destinationPort)
-				udpArgs = fmt.Sprintf("%s --dport %s", udpArgs, destinationPort)
-			}
-		}
-		if stringPattern != "" {
-			tcpArgs = fmt.Sprintf("%s -m string --string %s --algo bm", tcpArgs, stringPattern)
-			udpArgs = fmt.Sprintf("%s -m string --string %s --algo bm", udpArgs, stringPattern)
+	if destinationIp != "" {
+		ips, response := validateDropIps("destination-ip", destinationIp)
+		if response != nil {
+			return response
 		}
-		tcpArgs = fmt.Sprintf("%s -j DROP", tcpArgs)
-		udpArgs = fmt.Sprintf("%s -j DROP", udpArgs)
-		response = ne.channel.Run(ctx, "iptables", fmt.Sprintf(`%s`, tcpArgs))
-		if !response.Success {
+		destinationIp = strings.Join(ips, ",")
+	}
+	if sourcePort != "" {
+		ranges, response := validation.ValidatePortList("source-port", sourcePort)
+		if response != nil {
 			return response
 		}
-		response = ne.channel.Run(ctx, "iptables", fmt.Sprintf(`%s`, udpArgs))
-		if !response.Success {
+		sourcePort = validation.JoinPortRanges(ranges, "-")
+	}
+	if destinationPort != "" {
+		ranges, response := validation.ValidatePortList("destination-port", destinationPort)
+		if response != nil {
 			return response
 		}
+		destinationPort = validation.JoinPortRanges(ranges, "-")
+	}
+	if networkTraffic != "" && networkTraffic != "in" && networkTraffic != "out" {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "network-traffic", networkTraffic, "it must be in or out")
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return ne.destroy(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
+	}
+	if response := exec.CheckLoopback(ctx, model.ActionFlags, "", "source-ip", "destination-ip"); response != nil {
+		return response
+	}
+
+	veth, response := resolveDropVeth(ctx, ne.channel, model.ActionFlags)
+	if response != nil {
+		return response
 	}
-	return response
+	// the ssh sessions of the host don't pass the veth of the container
+	protectSSH := model.ActionFlags[exec.NoProtectSSHKey] != spec.True && veth == ""
+	return ne.start(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, veth, protectSSH, ctx)
 }
 
 func (ne *NetworkDropExecutor) SetChannel(channel spec.Channel) {
@@ -0,0 +1,324 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
+)
+
+// iptables doesn't support the ipv6, which is handled by ip6tables
+var validateDropIps = validation.ValidateIPv4OrCIDRList
+
+// maxMultiports is the limit of the iptables multiport match, the port range counts as two ports
+const maxMultiports = 15
+
+func checkDropCommands(ctx context.Context, cl spec.Channel) (*spec.Response, bool) {
+	commands := []string{"iptables"}
+	return cl.IsAllCommandsAvailable(ctx, commands)
+}
+
+// resolveDropVeth returns the host side veth of the container interface eth0, it's empty without the veth flags
+func resolveDropVeth(ctx context.Context, cl spec.Channel, flags map[string]string) (string, *spec.Response) {
+	return container.ResolveHostVeth(ctx, cl, flags, container.DefaultVethInterface)
+}
+
+func (ne *NetworkDropExecutor) start(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, veth string,
+	protectSSH bool, ctx context.Context) *spec.Response {
+	if destinationIp == "" && sourceIp == "" && destinationPort == "" && sourcePort == "" && stringPattern == "" {
+		return spec.ReturnFail(spec.ParameterLess, "must specify ip or port or string flag")
+	}
+	for flag, ports := range map[string]string{"source-port": sourcePort, "destination-port": destinationPort} {
+		if count := len(strings.Split(ports, ",")) + strings.Count(ports, "-"); ports != "" && count > maxMultiports {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, flag, ports,
+				fmt.Sprintf("iptables supports at most %d ports, the range counts as two", maxMultiports))
+		}
+	}
+	sourcePort, destinationPort = iptablesPorts(sourcePort), iptablesPorts(destinationPort)
+
+	flags := map[string]string{
+		"source-ip": sourceIp, "destination-ip": destinationIp, "source-port": sourcePort,
+		"destination-port": destinationPort, "string-pattern": stringPattern, "network-traffic": networkTraffic,
+	}
+	bridged := false
+	if veth != "" {
+		bridged = container.IsBridgePort(ctx, ne.channel, veth)
+		flags["veth"], flags["bridged"] = veth, strconv.FormatBool(bridged)
+	}
+	state, resp := exec.NewExperimentState(ctx, suid, "network", "drop", flags)
+	if resp != nil {
+		return resp
+	}
+	// the ssh sessions are accepted ahead of the drop rules
+	sessions := make([]exec.SSHSession, 0)
+	if protectSSH {
+		sessions = exec.ProtectedSSHSessions(ctx, ne.channel)
+	}
+	flows := dropFlows(networkTraffic, veth, bridged)
+	for _, session := range sessions {
+		for _, flow := range flows {
+			response := exec.RunIptablesArgv(ctx, ne.channel, sshAcceptRuleArgs("-I", flow.chain, session)...)
+			if !response.Success {
+				ne.stop(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
+				return response
+			}
+			undoArgs := sshAcceptRuleArgs("-D", flow.chain, session)
+			if err := state.AddUndo("iptables", exec.ShellJoin(undoArgs...)); err != nil {
+				log.Errorf(ctx, "record the iptables rule failed, %v", err)
+				exec.RunIptablesArgv(ctx, ne.channel, undoArgs...)
+				ne.stop(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
+				return exec.Fail(exec.StateRecordFailed, "iptables", "AddUndo", fmt.Sprintf("record the iptables rule failed, %v", err))
+			}
+		}
+	}
+	var response *spec.Response
+	for _, flow := range flows {
+		for _, protocol := range []string{"tcp", "udp"} {
+			args := dropRuleArgs("-A", flow, protocol, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern,
+				exec.IptablesComment(suid))
+			response = exec.RunIptablesArgv(ctx, ne.channel, args...)
+			if !response.Success {
+				ne.stop(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
+				return response
+			}
+			undoArgs := dropRuleArgs("-D", flow, protocol, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern,
+				exec.IptablesComment(suid))
+			if err := state.AddUndo("iptables", exec.ShellJoin(undoArgs...)); err != nil {
+				log.Errorf(ctx, "record the iptables rule failed, %v", err)
+				exec.RunIptablesArgv(ctx, ne.channel, undoArgs...)
+				ne.stop(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
+				return exec.Fail(exec.StateRecordFailed, "iptables", "AddUndo", fmt.Sprintf("record the iptables rule failed, %v", err))
+			}
+		}
+	}
+	return response
+}
+
+// dropDestroyResult is the response of the destroy with the counters of the drop rules before the removal
+type dropDestroyResult struct {
+	Uid      string              `json:"uid"`
+	Counters []exec.RuleCounters `json:"counters"`
+	Note     string              `json:"note,omitempty"`
+}
+
+// destroy removes the rules of the experiment, and returns the counters of the drop rules taken before the
+// removal. The rules which never matched any packet are noted, since the selectors are likely wrong.
+func (ne *NetworkDropExecutor) destroy(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic string, ctx context.Context) *spec.Response {
+	counters := dropCounters(ctx, ne.channel, suid)
+	response := ne.stop(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
+	if !response.Success || len(counters) == 0 {
+		return response
+	}
+	result := dropDestroyResult{Uid: suid, Counters: counters}
+	if matchedPackets(counters) == 0 {
+		result.Note = "the drop rules never matched any packet, check the ips, the ports and the string pattern of the experiment"
+		log.Warnf(ctx, "%s", result.Note)
+	}
+	return spec.ReturnSuccess(result)
+}
+
+func (ne *NetworkDropExecutor) stop(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic string, ctx context.Context) *spec.Response {
+	if response, ok := exec.DestroyByState(ctx, ne.channel, suid); ok {
+		return response
+	}
+	sourcePort, destinationPort = iptablesPorts(sourcePort), iptablesPorts(destinationPort)
+	var response *spec.Response
+	for _, flow := range dropFlows(networkTraffic, "", false) {
+		for _, protocol := range []string{"tcp", "udp"} {
+			response = exec.RunIptablesArgv(ctx, ne.channel,
+				dropRuleArgs("-D", flow, protocol, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, "")...)
+			if !response.Success {
+				return response
+			}
+		}
+	}
+	return response
+}
+
+// dropFlow is the iptables chain and the interface match of the network traffic
+type dropFlow struct {
+	chain string
+	match []string
+}
+
+// dropFlows returns the flows of the network traffic. With the host side veth of the container, in is the
+// traffic into the container and out is the traffic from it, which are forwarded or from and to the host.
+// The veth on a bridge is matched by the physdev, which can't match the traffic of the host to the container
+// in the OUTPUT chain.
+func dropFlows(networkTraffic, veth string, bridged bool) []dropFlow {
+	var in, out []dropFlow
+	switch {
+	case veth == "":
+		in = []dropFlow{{chain: "INPUT"}}
+		out = []dropFlow{{chain: "OUTPUT"}}
+	case bridged:
+		in = []dropFlow{{"FORWARD", []string{"-m", "physdev", "--physdev-out", veth, "--physdev-is-bridged"}}}
+		out = []dropFlow{
+			{"FORWARD", []string{"-m", "physdev", "--physdev-in", veth}},
+			{"INPUT", []string{"-m", "physdev", "--physdev-in", veth}},
+		}
+	default:
+		in = []dropFlow{{"FORWARD", []string{"-o", veth}}, {"OUTPUT", []string{"-o", veth}}}
+		out = []dropFlow{{"FORWARD", []string{"-i", veth}}, {"INPUT", []string{"-i", veth}}}
+	}
+	switch networkTraffic {
+	case "in":
+		return in
+	case "out":
+		return out
+	}
+	return append(in, out...)
+}
+
+// dropChains returns the distinct chains of the flows
+func dropChains(flows []dropFlow) []string {
+	chains, seen := make([]string, 0, len(flows)), make(map[string]bool)
+	for _, flow := range flows {
+		if !seen[flow.chain] {
+			seen[flow.chain] = true
+			chains = append(chains, flow.chain)
+		}
+	}
+	return chains
+}
+
+// sshAcceptRuleArgs returns the arguments of the iptables rule which accepts the ssh session, the operation
+// is -I or -D
+func sshAcceptRuleArgs(operation, netFlow string, session exec.SSHSession) []string {
+	localIp, localPort := "-d", "--dport"
+	peerIp, peerPort := "-s", "--sport"
+	if netFlow == "OUTPUT" {
+		localIp, localPort, peerIp, peerPort = peerIp, peerPort, localIp, localPort
+	}
+	args := []string{operation, netFlow, "-p", "tcp", peerIp, session.PeerIP}
+	if session.LocalIP != "" {
+		args = append(args, localIp, session.LocalIP)
+	}
+	return append(args, peerPort, strconv.Itoa(session.PeerPort), localPort, strconv.Itoa(session.LocalPort), "-j", "ACCEPT")
+}
+
+// dropRuleArgs returns the arguments of the iptables drop rule, the operation is -A, -D or -C. The comment finds
+// the counters of the rule, it's empty for the rules added without the record, which have no comment.
+func dropRuleArgs(operation string, flow dropFlow, protocol, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern,
+	comment string) []string {
+	args := append([]string{operation, flow.chain}, flow.match...)
+	args = append(args, "-p", protocol)
+	if sourceIp != "" {
+		args = append(args, "-s", sourceIp)
+	}
+	if destinationIp != "" {
+		args = append(args, "-d", destinationIp)
+	}
+	if sourcePort != "" {
+		if strings.Contains(sourcePort, ",") {
+			args = append(args, "-m", "multiport", "--sports", sourcePort)
+		} else {
+			args = append(args, "--sport", sourcePort)
+		}
+	}
+	if destinationPort != "" {
+		if strings.Contains(destinationPort, ",") {
+			args = append(args, "-m", "multiport", "--dports", destinationPort)
+		} else {
+			args = append(args, "--dport", destinationPort)
+		}
+	}
+	// the blade server and the ssh session running the experiment are kept on the loopback
+	if exec.LoopbackIP(sourceIp) != "" || exec.LoopbackIP(destinationIp) != "" {
+		ports := make([]string, 0)
+		for _, port := range exec.LoopbackExcludedPorts() {
+			ports = append(ports, strconv.Itoa(port))
+		}
+		args = append(args, "-m", "multiport", "!", "--ports", strings.Join(ports, ","))
+	}
+	if stringPattern != "" {
+		args = append(args, "-m", "string", "--string", stringPattern, "--algo", "bm")
+	}
+	if comment != "" {
+		args = append(args, "-m", "comment", "--comment", comment)
+	}
+	return append(args, "-j", "DROP")
+}
+
+func iptablesPorts(ports string) string {
+	return strings.ReplaceAll(ports, "-", ":")
+}
+
+// Status checks the recorded iptables rules with iptables -C, the counters of the drop rules are reported
+func (ne *NetworkDropExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report := iptablesStatus(ctx, ne.channel, uid, model, state)
+	if state != nil && !state.Destroyed {
+		report.Counters = dropCounters(ctx, ne.channel, uid)
+	}
+	return report
+}
+
+// dropCounters returns the counters of the drop rules of the recorded experiment, the rules are found by the
+// comment of the uid. It's empty if there is no record or the rules have no comment.
+func dropCounters(ctx context.Context, cl spec.Channel, uid string) []exec.RuleCounters {
+	state, err := exec.LoadState(uid)
+	if err != nil || state.Destroyed {
+		return nil
+	}
+	flows := dropFlows(state.Flags["network-traffic"], state.Flags["veth"], state.Flags["bridged"] == spec.True)
+	counters, err := exec.IptablesCounters(ctx, cl, exec.IptablesComment(uid), dropChains(flows)...)
+	if err != nil {
+		log.Warnf(ctx, "get the counters of the drop rules failed, %v", err)
+		return nil
+	}
+	return counters
+}
+
+// matchedPackets returns the packets matched by all the rules
+func matchedPackets(counters []exec.RuleCounters) uint64 {
+	var packets uint64
+	for _, counter := range counters {
+		packets += counter.Packets
+	}
+	return packets
+}
+
+// iptablesStatus checks the iptables rules whose deletions are recorded as the undo commands
+func iptablesStatus(ctx context.Context, cl spec.Channel, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	if state == nil || state.Destroyed {
+		report.Add(exec.Artifact{Kind: "record", Name: exec.GetStateFile(uid), Detail: "the experiment is not recorded or destroyed"})
+		return report
+	}
+	for _, undo := range state.Undo {
+		rule := strings.Replace(undo.Args, "-D ", "-A ", 1)
+		artifact := exec.Artifact{Kind: "rule", Name: fmt.Sprintf("iptables %s", rule)}
+		response := exec.RunIptables(ctx, cl, strings.Replace(undo.Args, "-D ", "-C ", 1))
+		artifact.Present = response.Success
+		if !response.Success {
+			artifact.Detail = response.Err
+		}
+		report.Add(artifact)
+	}
+	return report
+}
@@ -0,0 +1,325 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func execDrop(ctx context.Context, cl spec.Channel, uid string, flags map[string]string) *spec.Response {
+	executor := &NetworkDropExecutor{}
+	executor.SetChannel(cl)
+	return executor.Exec(uid, ctx, &spec.ExpModel{Target: "network", ActionName: "drop", ActionFlags: flags})
+}
+
+// withoutSSHSessions hides the ssh sessions of the test process, see exec.ProtectedSSHSessions
+func withoutSSHSessions(t *testing.T, cl *exec.MockChannel) *exec.MockChannel {
+	t.Setenv("SSH_CONNECTION", "")
+	t.Setenv("SSH_CLIENT", "")
+	return cl.SetCommandAvailable("ss", false)
+}
+
+func TestNetworkDropExecutor(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := withoutSSHSessions(t, exec.NewMockChannel())
+	flags := map[string]string{"destination-port": "80", "network-traffic": "out"}
+
+	if response := execDrop(ctx, cl, "drop-1", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"iptables -A OUTPUT -p tcp --dport 80 -m comment --comment chaosblade-drop-1 -j DROP",
+		"iptables -A OUTPUT -p udp --dport 80 -m comment --comment chaosblade-drop-1 -j DROP",
+	})
+
+	// the rules are removed by the record in the reverse order
+	cl.Reset()
+	if response := execDrop(spec.SetDestroyFlag(ctx, "drop-1"), cl, "drop-1", flags); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"iptables -nvxL OUTPUT",
+		"iptables -D OUTPUT -p udp --dport 80 -m comment --comment chaosblade-drop-1 -j DROP",
+		"iptables -D OUTPUT -p tcp --dport 80 -m comment --comment chaosblade-drop-1 -j DROP",
+	})
+}
+
+func TestNetworkDropExecutorMultiport(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	cl := withoutSSHSessions(t, exec.NewMockChannel())
+	flags := map[string]string{"source-ip": "10.0.0.1", "source-port": "8080-8090,80", "string-pattern": "GET /"}
+
+	if response := execDrop(context.Background(), cl, "drop-2", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	// the ports are sorted
+	rule := "-s 10.0.0.1 -m multiport --sports 80,8080:8090 -m string --string 'GET /' --algo bm -m comment --comment chaosblade-drop-2 -j DROP"
+	assertCommands(t, cl, []string{
+		"iptables -A INPUT -p tcp " + rule,
+		"iptables -A INPUT -p udp " + rule,
+		"iptables -A OUTPUT -p tcp " + rule,
+		"iptables -A OUTPUT -p udp " + rule,
+	})
+}
+
+func TestNetworkDropExecutorRollback(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := withoutSSHSessions(t, exec.NewMockChannel()).
+		OnRun("iptables", "^-A OUTPUT -p udp", spec.ReturnFail(spec.OsCmdExecFailed, "iptables failed"))
+	flags := map[string]string{"destination-ip": "10.0.0.2", "network-traffic": "out"}
+
+	if response := execDrop(ctx, cl, "drop-3", flags); response.Success {
+		t.Fatalf("expected the failure of the second rule")
+	}
+	// the applied rule is removed at once
+	assertCommands(t, cl, []string{
+		"iptables -A OUTPUT -p tcp -d 10.0.0.2 -m comment --comment chaosblade-drop-3 -j DROP",
+		"iptables -A OUTPUT -p udp -d 10.0.0.2 -m comment --comment chaosblade-drop-3 -j DROP",
+		"iptables -D OUTPUT -p tcp -d 10.0.0.2 -m comment --comment chaosblade-drop-3 -j DROP",
+	})
+}
+
+func TestNetworkDropExecutorLockContention(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	lockErr := spec.ReturnFail(spec.OsCmdExecFailed, "Another app is currently holding the xtables lock. exit status 4")
+	cl := withoutSSHSessions(t, exec.NewMockChannel()).
+		OnRun("iptables", "^-A OUTPUT -p udp", spec.ReturnFail(spec.OsCmdExecFailed, "iptables failed")).
+		OnRunTimes("iptables", "^-A OUTPUT -p tcp", 1, lockErr).
+		OnRunTimes("iptables", "^-D OUTPUT -p tcp", 1, lockErr)
+	flags := map[string]string{"destination-ip": "10.0.0.2", "network-traffic": "out"}
+
+	if response := execDrop(ctx, cl, "drop-lock", flags); response.Success {
+		t.Fatalf("expected the failure of the second rule")
+	}
+	// the rule failed by the lock is retried, so is the removal of the rollback
+	assertCommands(t, cl, []string{
+		"iptables -A OUTPUT -p tcp -d 10.0.0.2 -m comment --comment chaosblade-drop-lock -j DROP",
+		"iptables -A OUTPUT -p tcp -d 10.0.0.2 -m comment --comment chaosblade-drop-lock -j DROP",
+		"iptables -A OUTPUT -p udp -d 10.0.0.2 -m comment --comment chaosblade-drop-lock -j DROP",
+		"iptables -D OUTPUT -p tcp -d 10.0.0.2 -m comment --comment chaosblade-drop-lock -j DROP",
+		"iptables -D OUTPUT -p tcp -d 10.0.0.2 -m comment --comment chaosblade-drop-lock -j DROP",
+	})
+	if state, err := exec.LoadState("drop-lock"); err != nil || !state.Destroyed {
+		t.Errorf("expected the rollback is complete, %+v, %v", state, err)
+	}
+}
+
+func TestNetworkDropExecutorLoopback(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	cl := withoutSSHSessions(t, exec.NewMockChannel())
+	t.Setenv("SSH_CONNECTION", "10.0.0.2 52314 127.0.0.1 22")
+	flags := map[string]string{"destination-ip": "127.0.0.1", "destination-port": "8080", "network-traffic": "out"}
+
+	response := execDrop(context.Background(), cl, "drop-5", flags)
+	if response.Success || response.Code != spec.Forbidden.Code {
+		t.Fatalf("expected the loopback is refused without --%s, %+v", exec.AffectLoopbackKey, response)
+	}
+	assertCommands(t, cl, []string{})
+
+	// the blade server and the ssh session are excluded
+	flags[exec.AffectLoopbackKey] = spec.True
+	if response := execDrop(context.Background(), cl, "drop-5", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	rule := "-d 127.0.0.1 --dport 8080 -m multiport '!' --ports 22,9526 -m comment --comment chaosblade-drop-5 -j DROP"
+	assertCommands(t, cl, []string{
+		"iptables -I OUTPUT -p tcp -d 10.0.0.2 -s 127.0.0.1 --dport 52314 --sport 22 -j ACCEPT",
+		"iptables -A OUTPUT -p tcp " + rule,
+		"iptables -A OUTPUT -p udp " + rule,
+	})
+}
+
+func TestNetworkDropExecutorSSHSessions(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := withoutSSHSessions(t, exec.NewMockChannel()).SetCommandAvailable("ss", true).
+		OnRun("ss", "sport = :22", spec.ReturnSuccess("0      0      10.0.0.1:22      10.0.0.2:52314\n"+
+			"0      0      [::ffff:10.0.0.1]:22      [::ffff:10.0.0.3]:40022\n0      0      [fe80::1]:22      [fe80::2]:40023\n"))
+	flags := map[string]string{"network-traffic": "in", "destination-port": "22"}
+
+	// the sessions are accepted ahead of the drop rules, the ipv6 one is skipped
+	if response := execDrop(ctx, cl, "drop-6", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"ss -Htn state established '( sport = :22 )'",
+		"iptables -I INPUT -p tcp -s 10.0.0.2 -d 10.0.0.1 --sport 52314 --dport 22 -j ACCEPT",
+		"iptables -I INPUT -p tcp -s 10.0.0.3 -d 10.0.0.1 --sport 40022 --dport 22 -j ACCEPT",
+		"iptables -A INPUT -p tcp --dport 22 -m comment --comment chaosblade-drop-6 -j DROP",
+		"iptables -A INPUT -p udp --dport 22 -m comment --comment chaosblade-drop-6 -j DROP",
+	})
+
+	// the accept rules are removed after the drop rules
+	cl.Reset()
+	if response := execDrop(spec.SetDestroyFlag(ctx, "drop-6"), cl, "drop-6", map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"iptables -nvxL INPUT",
+		"iptables -D INPUT -p udp --dport 22 -m comment --comment chaosblade-drop-6 -j DROP",
+		"iptables -D INPUT -p tcp --dport 22 -m comment --comment chaosblade-drop-6 -j DROP",
+		"iptables -D INPUT -p tcp -s 10.0.0.3 -d 10.0.0.1 --sport 40022 --dport 22 -j ACCEPT",
+		"iptables -D INPUT -p tcp -s 10.0.0.2 -d 10.0.0.1 --sport 52314 --dport 22 -j ACCEPT",
+	})
+
+	// the sessions are dropped too with --no-protect-ssh
+	cl.Reset()
+	flags[exec.NoProtectSSHKey] = spec.True
+	if response := execDrop(ctx, cl, "drop-7", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"iptables -A INPUT -p tcp --dport 22 -m comment --comment chaosblade-drop-7 -j DROP",
+		"iptables -A INPUT -p udp --dport 22 -m comment --comment chaosblade-drop-7 -j DROP",
+	})
+}
+
+func TestNetworkDropExecutorWithoutIptables(t *testing.T) {
+	cl := exec.NewMockChannel().SetCommandAvailable("iptables", false)
+	response := execDrop(context.Background(), cl, "drop-4", map[string]string{"destination-port": "80"})
+	if response.Success || response.Code != spec.CommandIptablesNotFound.Code {
+		t.Errorf("expected iptables not found, %+v", response)
+	}
+	assertCommands(t, cl, []string{})
+}
+
+func TestNetworkDropExecutorCounters(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := withoutSSHSessions(t, exec.NewMockChannel())
+	flags := map[string]string{"destination-port": "80", "network-traffic": "out"}
+	if response := execDrop(ctx, cl, "drop-8", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	cl.OnRun("iptables", "^-nvxL OUTPUT$", spec.ReturnSuccess(`Chain OUTPUT (policy ACCEPT 0 packets, 0 bytes)
+    pkts      bytes target     prot opt in     out     source               destination
+      12      720 DROP       tcp  --  *      *       0.0.0.0/0            0.0.0.0/0            tcp dpt:80 /* chaosblade-drop-8 */
+       0        0 DROP       udp  --  *      *       0.0.0.0/0            0.0.0.0/0            udp dpt:80 /* chaosblade-drop-8 */
+`))
+
+	state, err := exec.LoadState("drop-8")
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	executor := &NetworkDropExecutor{channel: cl}
+	report := executor.Status(ctx, "drop-8", &spec.ExpModel{Target: "network", ActionName: "drop"}, state)
+	if len(report.Counters) != 2 || report.Counters[0].Packets != 12 || report.Counters[1].Packets != 0 {
+		t.Errorf("expected the counters of the rules, got %+v", report.Counters)
+	}
+
+	response := execDrop(spec.SetDestroyFlag(ctx, "drop-8"), cl, "drop-8", flags)
+	result, ok := response.Result.(dropDestroyResult)
+	if !response.Success || !ok || len(result.Counters) != 2 || result.Note != "" {
+		t.Errorf("expected the counters without the note, got %+v", response)
+	}
+}
+
+func TestNetworkDropExecutorNeverMatched(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := withoutSSHSessions(t, exec.NewMockChannel()).OnRun("iptables", "^-nvxL INPUT$", spec.ReturnSuccess(
+		"       0        0 DROP       tcp  --  *      *       10.0.0.1             0.0.0.0/0            /* chaosblade-drop-9 */\n"))
+	flags := map[string]string{"source-ip": "10.0.0.1", "network-traffic": "in"}
+	if response := execDrop(ctx, cl, "drop-9", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	response := execDrop(spec.SetDestroyFlag(ctx, "drop-9"), cl, "drop-9", flags)
+	result, ok := response.Result.(dropDestroyResult)
+	if !response.Success || !ok || result.Note == "" {
+		t.Errorf("expected the note of the rules never matched, got %+v", response)
+	}
+}
+
+func TestNetworkDropExecutorVeth(t *testing.T) {
+	tests := []struct {
+		name     string
+		link     string
+		traffic  string
+		expected []string
+		chains   []string
+	}{
+		{
+			name:    "routed",
+			traffic: "out",
+			link:    "7: veth1a2b@if2: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue state UP",
+			expected: []string{
+				"iptables -A FORWARD -i veth1a2b -p tcp --dport 3306 -m comment --comment chaosblade-drop-veth -j DROP",
+				"iptables -A FORWARD -i veth1a2b -p udp --dport 3306 -m comment --comment chaosblade-drop-veth -j DROP",
+				"iptables -A INPUT -i veth1a2b -p tcp --dport 3306 -m comment --comment chaosblade-drop-veth -j DROP",
+				"iptables -A INPUT -i veth1a2b -p udp --dport 3306 -m comment --comment chaosblade-drop-veth -j DROP",
+			},
+			chains: []string{"iptables -nvxL FORWARD", "iptables -nvxL INPUT"},
+		},
+		{
+			name:    "bridged",
+			traffic: "in",
+			link:    "7: veth1a2b@if2: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue master docker0 state UP",
+			expected: []string{
+				"iptables -A FORWARD -m physdev --physdev-out veth1a2b --physdev-is-bridged -p tcp --dport 3306 -m comment --comment chaosblade-drop-veth -j DROP",
+				"iptables -A FORWARD -m physdev --physdev-out veth1a2b --physdev-is-bridged -p udp --dport 3306 -m comment --comment chaosblade-drop-veth -j DROP",
+			},
+			chains: []string{"iptables -nvxL FORWARD"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec.StateDir = t.TempDir()
+			ctx := context.Background()
+			// the ssh sessions are not protected, since they don't pass the veth
+			t.Setenv("SSH_CONNECTION", "10.0.0.2 52314 10.0.0.1 22")
+			cl := exec.NewMockChannel().
+				OnRun("nsenter", "-t 1234 -n ip -o link show dev eth0", spec.ReturnSuccess("2: eth0@if7: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500")).
+				OnRun("ip", "^-o link show$", spec.ReturnSuccess(tt.link)).
+				OnRun("ip", "^-o link show dev veth1a2b$", spec.ReturnSuccess(tt.link))
+			flags := map[string]string{"destination-port": "3306", "network-traffic": tt.traffic, "container-pid": "1234"}
+			if response := execDrop(ctx, cl, "drop-veth", flags); !response.Success {
+				t.Fatalf("unexpected failure, %s", response.Err)
+			}
+			rules := make([]string, 0)
+			for _, line := range cl.CommandLines() {
+				if strings.HasPrefix(line, "iptables") {
+					rules = append(rules, line)
+				}
+			}
+			if !reflect.DeepEqual(rules, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, rules)
+			}
+
+			// the veth is recorded, the destroy doesn't resolve it again
+			cl.Reset()
+			if response := execDrop(spec.SetDestroyFlag(ctx, "drop-veth"), cl, "drop-veth", map[string]string{}); !response.Success {
+				t.Fatalf("unexpected failure of destroy, %s", response.Err)
+			}
+			if lines := cl.CommandLines(); len(lines) != len(tt.chains)+len(tt.expected) ||
+				!reflect.DeepEqual(lines[:len(tt.chains)], tt.chains) {
+				t.Errorf("expected the counters of %v and the removal of the rules, got %v", tt.chains, lines)
+			}
+		})
+	}
+}
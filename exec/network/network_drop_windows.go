@@ -0,0 +1,152 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"fmt"
+	osexec "os/exec"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
+)
+
+// the firewall rules support both the ipv4 and the ipv6
+var validateDropIps = validation.ValidateIPOrCIDRList
+
+// firewallRulePrefix is the name prefix of the firewall rules, the rules of an experiment share the same name,
+// so that they are deleted together by the name
+const firewallRulePrefix = "chaosblade-network-drop-"
+
+// the message printed by netsh when deleting the rules which don't exist
+const noRulesMatchMessage = "No rules match"
+
+func checkDropCommands(ctx context.Context, cl spec.Channel) (*spec.Response, bool) {
+	if _, err := osexec.LookPath("netsh"); err != nil {
+		return exec.FailWithFlags(exec.CommandFailed, "firewall", "netsh", err), false
+	}
+	return nil, true
+}
+
+// resolveDropVeth refuses the veth of the container, which doesn't exist on windows
+func resolveDropVeth(ctx context.Context, cl spec.Channel, flags map[string]string) (string, *spec.Response) {
+	for _, name := range []string{container.PidFlagName, container.VethFlagName} {
+		if flags[name] != "" {
+			return "", spec.ResponseFailWithFlags(spec.ParameterIllegal, name, flags[name],
+				"the host side veth of the container is not supported on windows")
+		}
+	}
+	return "", nil
+}
+
+func getFirewallRuleName(uid string) string {
+	return firewallRulePrefix + uid
+}
+
+// start adds the block rules of the windows firewall, which take precedence over the allow rules, so the ssh
+// sessions can't be protected
+func (ne *NetworkDropExecutor) start(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, _ string,
+	_ bool, ctx context.Context) *spec.Response {
+	if destinationIp == "" && sourceIp == "" && destinationPort == "" && sourcePort == "" && stringPattern == "" {
+		return spec.ReturnFail(spec.ParameterLess, "must specify ip or port or string flag")
+	}
+	if stringPattern != "" {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "string-pattern", stringPattern,
+			"the content of the packets can't be matched by the windows firewall")
+	}
+
+	var response *spec.Response
+	directions := []string{"in", "out"}
+	if networkTraffic == "in" {
+		directions = []string{"in"}
+	}
+	if networkTraffic == "out" {
+		directions = []string{"out"}
+	}
+	for _, direction := range directions {
+		for _, protocol := range []string{"TCP", "UDP"} {
+			args := getFirewallRuleArgs(getFirewallRuleName(suid), direction, protocol,
+				sourceIp, destinationIp, sourcePort, destinationPort)
+			response = ne.channel.Run(ctx, "netsh", args)
+			if !response.Success {
+				ne.stop(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
+				return response
+			}
+		}
+	}
+	return response
+}
+
+// getFirewallRuleArgs maps the flags to the local and remote selectors of the rule, the source is the remote
+// for the incoming traffic and the local for the outgoing traffic
+func getFirewallRuleArgs(name, direction, protocol, sourceIp, destinationIp, sourcePort, destinationPort string) string {
+	localIp, remoteIp, localPort, remotePort := destinationIp, sourceIp, destinationPort, sourcePort
+	if direction == "out" {
+		localIp, remoteIp, localPort, remotePort = sourceIp, destinationIp, sourcePort, destinationPort
+	}
+	args := fmt.Sprintf(`advfirewall firewall add rule name="%s" dir=%s action=block protocol=%s`,
+		name, direction, protocol)
+	if localIp != "" {
+		args = fmt.Sprintf("%s localip=%s", args, localIp)
+	}
+	if remoteIp != "" {
+		args = fmt.Sprintf("%s remoteip=%s", args, remoteIp)
+	}
+	// the port range is 80:81 in iptables and 80-81 in netsh
+	if localPort != "" {
+		args = fmt.Sprintf("%s localport=%s", args, strings.ReplaceAll(localPort, ":", "-"))
+	}
+	if remotePort != "" {
+		args = fmt.Sprintf("%s remoteport=%s", args, strings.ReplaceAll(remotePort, ":", "-"))
+	}
+	return args
+}
+
+// destroy deletes the firewall rules, netsh has no counters of the rules
+func (ne *NetworkDropExecutor) destroy(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic string, ctx context.Context) *spec.Response {
+	return ne.stop(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic, ctx)
+}
+
+// stop deletes all the rules of the experiment by the name, whatever the direction is
+func (ne *NetworkDropExecutor) stop(suid, sourceIp, destinationIp, sourcePort, destinationPort, stringPattern, networkTraffic string, ctx context.Context) *spec.Response {
+	response := ne.channel.Run(ctx, "netsh",
+		fmt.Sprintf(`advfirewall firewall delete rule name="%s"`, getFirewallRuleName(suid)))
+	if !response.Success && strings.Contains(response.Err, noRulesMatchMessage) {
+		log.Infof(ctx, "the firewall rules of %s don't exist", suid)
+		return spec.ReturnSuccess("no firewall rules found to delete")
+	}
+	return response
+}
+
+// Status checks the firewall rules of the experiment by the name
+func (ne *NetworkDropExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	name := getFirewallRuleName(uid)
+	artifact := exec.Artifact{Kind: "rule", Name: name}
+	response := ne.channel.Run(ctx, "netsh", fmt.Sprintf(`advfirewall firewall show rule name="%s"`, name))
+	artifact.Present = response.Success
+	if !response.Success {
+		artifact.Detail = response.Err
+	}
+	report.Add(artifact)
+	return report
+}
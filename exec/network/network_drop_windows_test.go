@@ -0,0 +1,76 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestGetFirewallRuleArgs(t *testing.T) {
+	prefix := `advfirewall firewall add rule name="chaosblade-network-drop-abc" `
+	tests := []struct {
+		direction                                            string
+		sourceIp, destinationIp, sourcePort, destinationPort string
+		expect                                               string
+	}{
+		{"in", "10.0.0.1", "", "", "80,81", "dir=in action=block protocol=TCP remoteip=10.0.0.1 localport=80,81"},
+		{"out", "", "10.0.0.2", "", "8000:8080", "dir=out action=block protocol=TCP remoteip=10.0.0.2 remoteport=8000-8080"},
+		{"out", "10.0.0.3", "", "53", "", "dir=out action=block protocol=TCP localip=10.0.0.3 localport=53"},
+	}
+	for _, tt := range tests {
+		got := getFirewallRuleArgs(getFirewallRuleName("abc"), tt.direction, "TCP",
+			tt.sourceIp, tt.destinationIp, tt.sourcePort, tt.destinationPort)
+		if got != prefix+tt.expect {
+			t.Errorf("unexpected rule args: %s, expected: %s", got, prefix+tt.expect)
+		}
+	}
+}
+
+func TestDropByFirewall(t *testing.T) {
+	rules := make([]string, 0)
+	cl := channel.NewMockLocalChannel().(*channel.MockLocalChannel)
+	cl.RunFunc = func(ctx context.Context, script, args string) *spec.Response {
+		if script != "netsh" {
+			t.Errorf("unexpected command: %s", script)
+		}
+		rules = append(rules, args)
+		return spec.ReturnSuccess("Ok.")
+	}
+	executor := &NetworkDropExecutor{channel: cl}
+	ctx := context.Background()
+	if response := executor.start("abc", "", "", "", "80", "", "", "", true, ctx); !response.Success {
+		t.Fatalf("drop failed, %s", response.Err)
+	}
+	// tcp and udp rules for both directions
+	if len(rules) != 4 {
+		t.Errorf("unexpected rules count: %d, %v", len(rules), rules)
+	}
+	if response := executor.start("abc", "", "", "", "", "baidu.com", "out", "", true, ctx); response.Success {
+		t.Errorf("string pattern is expected to be unsupported")
+	}
+
+	cl.RunFunc = func(ctx context.Context, script, args string) *spec.Response {
+		return spec.ReturnFail(spec.OsCmdExecFailed, "No rules match the specified criteria.")
+	}
+	if response := executor.stop("abc", "", "", "", "80", "", "", ctx); !response.Success {
+		t.Errorf("destroy is expected to be idempotent, %s", response.Err)
+	}
+}
@@ -0,0 +1,320 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+const (
+	// the anchors of the experiments are under com.apple, which is referenced by the dummynet-anchor of the
+	// default /etc/pf.conf, so that the main ruleset is not changed
+	dummynetAnchorPrefix = "com.apple/chaosblade-"
+	// the pipes of the experiments are allocated from it to avoid the pipes created by the other tools
+	dummynetPipeBase = 20000
+)
+
+var (
+	pipeNumberRegexp = regexp.MustCompile(`(?m)^0*(\d+):`)
+	pfTokenRegexp    = regexp.MustCompile(`Token : (\d+)`)
+)
+
+// dummynetState is recorded before any change, so that the pipe, the anchor and the pf enabled by the experiment
+// are cleaned up when destroying
+type dummynetState struct {
+	Pipe   int    `json:"pipe"`
+	Anchor string `json:"anchor"`
+	// Token is the reference of pf returned by `pfctl -E`, it's empty if pf was enabled before the experiment
+	Token string `json:"token,omitempty"`
+}
+
+// DummynetExecutor delays or drops the packets by the dnctl pipe and the pfctl dummynet rules on darwin,
+// the flags are the same with the tc actions
+type DummynetExecutor struct {
+	action  string
+	channel spec.Channel
+}
+
+func (de *DummynetExecutor) Name() string {
+	return de.action
+}
+
+func (de *DummynetExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	commands := []string{"dnctl", "pfctl"}
+	if response, ok := de.channel.IsAllCommandsAvailable(ctx, commands); !ok {
+		return response
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return de.stop(ctx, uid)
+	}
+	netInterface := model.ActionFlags["interface"]
+	if netInterface == "" {
+		log.Errorf(ctx, "interface is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "interface")
+	}
+	pipeConfig, response := de.getPipeConfig(ctx, model)
+	if response != nil {
+		return response
+	}
+	for _, name := range []string{"exclude-port", "ignore-peer-port"} {
+		if value := model.ActionFlags[name]; value != "" && value != "false" {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, name, value, "it is not supported on darwin")
+		}
+	}
+	for _, name := range []string{"local-port", "remote-port"} {
+		if _, err := getPfPorts(model.ActionFlags[name]); err != nil {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, name, model.ActionFlags[name], err)
+		}
+	}
+	return de.start(ctx, uid, pipeConfig, netInterface, model.ActionFlags["local-port"], model.ActionFlags["remote-port"],
+		model.ActionFlags["destination-ip"], model.ActionFlags["exclude-ip"], model.ActionFlags["protocol"])
+}
+
+// getPipeConfig returns the config of the dnctl pipe by the flags of the action
+func (de *DummynetExecutor) getPipeConfig(ctx context.Context, model *spec.ExpModel) (string, *spec.Response) {
+	switch de.action {
+	case "delay":
+		time := model.ActionFlags["time"]
+		if time == "" {
+			log.Errorf(ctx, "time is nil")
+			return "", spec.ResponseFailWithFlags(spec.ParameterLess, "time")
+		}
+		if _, err := strconv.Atoi(time); err != nil {
+			return "", spec.ResponseFailWithFlags(spec.ParameterIllegal, "time", time, "it must be a positive integer")
+		}
+		if offset := model.ActionFlags["offset"]; offset != "" && offset != "0" {
+			log.Warnf(ctx, "the offset %s is ignored, dummynet doesn't support the jitter", offset)
+		}
+		return fmt.Sprintf("delay %sms", time), nil
+	default:
+		percent := model.ActionFlags["percent"]
+		if percent == "" {
+			log.Errorf(ctx, "percent is nil")
+			return "", spec.ResponseFailWithFlags(spec.ParameterLess, "percent")
+		}
+		p, err := strconv.Atoi(percent)
+		if err != nil || p < 0 || p > 100 {
+			return "", spec.ResponseFailWithFlags(spec.ParameterIllegal, "percent", percent, "it must be an integer in [0, 100]")
+		}
+		// the packet loss rate of dummynet is in [0, 1]
+		return fmt.Sprintf("plr %.2f", float64(p)/100), nil
+	}
+}
+
+func (de *DummynetExecutor) start(ctx context.Context, uid, pipeConfig, netInterface, localPort, remotePort, destIp, excludeIp, protocol string) *spec.Response {
+	stateFile := getDummynetStateFile(de.action, uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the dummynet state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	response := de.channel.Run(ctx, "pfctl", "-s info")
+	if !response.Success {
+		log.Errorf(ctx, "get the status of pf failed, %s", response.Err)
+		return response
+	}
+	pfEnabled := strings.Contains(response.Result.(string), "Status: Enabled")
+	pipe, response := de.allocatePipe(ctx)
+	if response != nil {
+		return response
+	}
+	state := &dummynetState{Pipe: pipe, Anchor: dummynetAnchorPrefix + uid}
+	if err := writeDummynetState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write the dummynet state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "dummynet", "writeDummynetState", err)
+	}
+
+	if response := de.channel.Run(ctx, "dnctl", fmt.Sprintf("pipe %d config %s", pipe, pipeConfig)); !response.Success {
+		log.Errorf(ctx, "config the pipe %d failed, %s", pipe, response.Err)
+		de.stop(ctx, uid)
+		return response
+	}
+	rules := buildDummynetRules(netInterface, localPort, remotePort, destIp, excludeIp, protocol, pipe)
+	log.Infof(ctx, "load the dummynet rules to %s: %s", state.Anchor, rules)
+	if response := de.channel.Run(ctx, "echo", fmt.Sprintf(`'%s' | pfctl -a %s -f -`, rules, state.Anchor)); !response.Success {
+		log.Errorf(ctx, "load the dummynet rules failed, %s", response.Err)
+		de.stop(ctx, uid)
+		return response
+	}
+	if !pfEnabled {
+		// the token is released when destroying, pf is disabled only if no one else holds it
+		response := de.channel.Run(ctx, "pfctl", "-E")
+		if !response.Success {
+			log.Errorf(ctx, "enable pf failed, %s", response.Err)
+			de.stop(ctx, uid)
+			return response
+		}
+		if match := pfTokenRegexp.FindStringSubmatch(response.Result.(string)); match != nil {
+			state.Token = match[1]
+		}
+		if err := writeDummynetState(stateFile, state); err != nil {
+			log.Errorf(ctx, "write the dummynet state failed, %v", err)
+			de.stop(ctx, uid)
+			return exec.FailWithFlags(exec.StateRecordFailed, "dummynet", "writeDummynetState", err)
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (de *DummynetExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	stateFile := getDummynetStateFile(de.action, uid)
+	state, err := readDummynetState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warnf(ctx, "`%s`: the dummynet state file does not exist, skip to destroy", stateFile)
+			return spec.ReturnSuccess(uid)
+		}
+		log.Errorf(ctx, "read the dummynet state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "dummynet", "readDummynetState", err)
+	}
+	failed := make([]string, 0)
+	// only the rules of the experiment anchor are flushed
+	if response := de.channel.Run(ctx, "pfctl", fmt.Sprintf("-a %s -F all", state.Anchor)); !response.Success {
+		log.Errorf(ctx, "flush the anchor %s failed, %s", state.Anchor, response.Err)
+		failed = append(failed, "anchor "+state.Anchor)
+	}
+	if response := de.channel.Run(ctx, "dnctl", fmt.Sprintf("pipe %d delete", state.Pipe)); !response.Success {
+		log.Errorf(ctx, "delete the pipe %d failed, %s", state.Pipe, response.Err)
+		failed = append(failed, fmt.Sprintf("pipe %d", state.Pipe))
+	}
+	if state.Token != "" {
+		if response := de.channel.Run(ctx, "pfctl", "-X "+state.Token); !response.Success {
+			log.Errorf(ctx, "release the pf token %s failed, %s", state.Token, response.Err)
+			failed = append(failed, "token "+state.Token)
+		}
+	}
+	if len(failed) > 0 {
+		return exec.Fail(exec.RuleRemoveFailed, "dummynet", "clean", fmt.Sprintf("clean %s failed", strings.Join(failed, ", ")))
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+// allocatePipe returns the first pipe number from dummynetPipeBase which is not used, the output of `dnctl list`
+// is like `00001:   unlimited    0 ms   50 sl. 0 queues (1 buckets) droptail`
+func (de *DummynetExecutor) allocatePipe(ctx context.Context) (int, *spec.Response) {
+	response := de.channel.Run(ctx, "dnctl", "list")
+	if !response.Success {
+		log.Errorf(ctx, "list the pipes failed, %s", response.Err)
+		return 0, response
+	}
+	used := make(map[int]bool)
+	for _, match := range pipeNumberRegexp.FindAllStringSubmatch(response.Result.(string), -1) {
+		if pipe, err := strconv.Atoi(match[1]); err == nil {
+			used[pipe] = true
+		}
+	}
+	pipe := dummynetPipeBase
+	for used[pipe] {
+		pipe++
+	}
+	return pipe, nil
+}
+
+func (de *DummynetExecutor) SetChannel(channel spec.Channel) {
+	de.channel = channel
+}
+
+// buildDummynetRules maps the flags to the dummynet rules of the outgoing packets like the tc actions, the local
+// and the remote ports are matched separately. The last matching rule wins, so the excluded ips are the last
+func buildDummynetRules(netInterface, localPort, remotePort, destIp, excludeIp, protocol string, pipe int) string {
+	to := "any"
+	if destIp != "" {
+		to = getPfList(destIp)
+	}
+	proto := ""
+	if protocol != "" {
+		proto = " proto " + protocol
+	} else if localPort != "" || remotePort != "" {
+		// the ports are only matched with tcp or udp
+		proto = " proto { tcp udp }"
+	}
+	rules := make([]string, 0)
+	if localPort == "" && remotePort == "" {
+		rules = append(rules, fmt.Sprintf("dummynet out on %s%s from any to %s pipe %d", netInterface, proto, to, pipe))
+	}
+	if localPort != "" {
+		ports, _ := getPfPorts(localPort)
+		rules = append(rules, fmt.Sprintf("dummynet out on %s%s from any port %s to %s pipe %d", netInterface, proto, ports, to, pipe))
+	}
+	if remotePort != "" {
+		ports, _ := getPfPorts(remotePort)
+		rules = append(rules, fmt.Sprintf("dummynet out on %s%s from any to %s port %s pipe %d", netInterface, proto, to, ports, pipe))
+	}
+	if excludeIp != "" {
+		rules = append(rules, fmt.Sprintf("no dummynet out on %s from any to %s", netInterface, getPfList(excludeIp)))
+	}
+	return strings.Join(rules, "\n")
+}
+
+// getPfList converts the values separated by comma to the list of pf, for example, { 10.0.0.1 10.0.0.2 }
+func getPfList(values string) string {
+	return fmt.Sprintf("{ %s }", strings.Join(strings.Split(values, ","), " "))
+}
+
+// getPfPorts converts the ports like 80,8000-8080 to the list of pf like { 80 8000:8080 }
+func getPfPorts(ports string) (string, error) {
+	if ports == "" {
+		return "", nil
+	}
+	items := strings.Split(ports, ",")
+	for i, item := range items {
+		bounds := strings.Split(item, "-")
+		if len(bounds) > 2 {
+			return "", fmt.Errorf("illegal port range %s", item)
+		}
+		for _, bound := range bounds {
+			if port, err := strconv.Atoi(bound); err != nil || port < 0 || port > 65535 {
+				return "", fmt.Errorf("illegal port %s", bound)
+			}
+		}
+		items[i] = strings.Join(bounds, ":")
+	}
+	return getPfList(strings.Join(items, ",")), nil
+}
+
+func getDummynetStateFile(action, uid string) string {
+	return fmt.Sprintf("/tmp/chaos-network-%s-%s.json", action, uid)
+}
+
+func writeDummynetState(stateFile string, state *dummynetState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readDummynetState(stateFile string) (*dummynetState, error) {
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	state := &dummynetState{}
+	err = json.Unmarshal(data, state)
+	return state, err
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestBuildDummynetRules(t *testing.T) {
+	tests := []struct {
+		localPort, remotePort, destIp, excludeIp, protocol string
+		expect                                             string
+	}{
+		{"", "", "", "", "", "dummynet out on en0 from any to any pipe 20000"},
+		{"8080,8081", "", "", "", "", "dummynet out on en0 proto { tcp udp } from any port { 8080 8081 } to any pipe 20000"},
+		{"", "8000-8080", "10.0.0.1", "", "tcp",
+			"dummynet out on en0 proto tcp from any to { 10.0.0.1 } port { 8000:8080 } pipe 20000"},
+		{"", "", "", "10.0.0.2,10.0.0.3", "",
+			"dummynet out on en0 from any to any pipe 20000\nno dummynet out on en0 from any to { 10.0.0.2 10.0.0.3 }"},
+	}
+	for _, tt := range tests {
+		got := buildDummynetRules("en0", tt.localPort, tt.remotePort, tt.destIp, tt.excludeIp, tt.protocol, 20000)
+		if got != tt.expect {
+			t.Errorf("unexpected rules: %s, expected: %s", got, tt.expect)
+		}
+	}
+}
+
+func TestGetPfPorts(t *testing.T) {
+	for _, ports := range []string{"a", "1-2-3", "70000"} {
+		if _, err := getPfPorts(ports); err == nil {
+			t.Errorf("the ports %s is expected to be illegal", ports)
+		}
+	}
+}
+
+func TestDummynetReleasesOnlyOwnedToken(t *testing.T) {
+	for _, pfEnabled := range []bool{false, true} {
+		commands := make([]string, 0)
+		cl := channel.NewMockLocalChannel().(*channel.MockLocalChannel)
+		cl.RunFunc = func(ctx context.Context, script, args string) *spec.Response {
+			commands = append(commands, script+" "+args)
+			switch {
+			case args == "-s info" && pfEnabled:
+				return spec.ReturnSuccess("Status: Enabled for 0 days 00:10:00")
+			case args == "-s info":
+				return spec.ReturnSuccess("Status: Disabled")
+			case args == "list":
+				return spec.ReturnSuccess("20000:   unlimited    0 ms   50 sl. 0 queues (1 buckets) droptail\n")
+			case args == "-E":
+				return spec.ReturnSuccess("pf enabled\nToken : 12345\n")
+			}
+			return spec.ReturnSuccess("")
+		}
+		executor := &DummynetExecutor{action: "delay", channel: cl}
+		ctx := context.Background()
+		uid := "dummynet-test"
+		if response := executor.start(ctx, uid, "delay 100ms", "en0", "", "", "", "", ""); !response.Success {
+			t.Fatalf("start failed, %s", response.Err)
+		}
+		if response := executor.stop(ctx, uid); !response.Success {
+			t.Fatalf("stop failed, %s", response.Err)
+		}
+		all := strings.Join(commands, "\n")
+		if !strings.Contains(all, "dnctl pipe 20001 config delay 100ms") || !strings.Contains(all, "dnctl pipe 20001 delete") {
+			t.Errorf("the pipe 20001 is not used, %s", all)
+		}
+		if !strings.Contains(all, "pfctl -a com.apple/chaosblade-dummynet-test -F all") {
+			t.Errorf("the anchor is not flushed, %s", all)
+		}
+		if released := strings.Contains(all, "pfctl -X 12345"); released == pfEnabled {
+			t.Errorf("unexpected token release when pf enabled is %v, %s", pfEnabled, all)
+		}
+		if _, err := os.Stat(getDummynetStateFile("delay", uid)); !os.IsNotExist(err) {
+			t.Errorf("the state file is not removed")
+		}
+	}
+}
@@ -28,13 +28,16 @@ func NewNetworkCommandSpec() spec.ExpModelCommandSpec {
 			ExpActions: []spec.ExpActionCommandSpec{
 				tc.NewDelayActionSpec(),
 				NewDropActionSpec(),
+				NewPartitionActionSpec(),
 				NewDnsActionSpec(),
 				NewDnsDownActionSpec(),
 				tc.NewLossActionSpec(),
 				tc.NewDuplicateActionSpec(),
 				tc.NewCorruptActionSpec(),
 				tc.NewReorderActionSpec(),
+				tc.NewRateActionSpec(),
 				NewOccupyActionSpec(),
+				NewArpActionSpec(),
 			},
 			ExpFlags: []spec.ExpFlagSpec{},
 		},
@@ -18,16 +18,21 @@ package network
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	osutil "os"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
 )
 
 var OccupyNetworkBin = "chaos_occupynetwork"
@@ -54,18 +59,27 @@ func NewOccupyActionSpec() spec.ExpActionCommandSpec {
 				},
 				&spec.ExpFlag{
 					Name:     "cgroup-root",
-					Desc:     "cgroup root path, default value /sys/fs/cgroup",
+					Desc:     "cgroup root path, it's detected from the cgroup mounts if absent",
 					NoArgs:   false,
 					Required: false,
-					Default:  "/sys/fs/cgroup",
+					Default:  "",
+				},
+			},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:   "restore-service",
+					Desc:   "Start the systemd services killed by --force again when the experiment is destroyed",
+					NoArgs: true,
 				},
 			},
-			ActionFlags:    []spec.ExpFlagSpec{},
 			ActionExecutor: &OccupyActionExecutor{},
 			ActionExample: `
 #Specify port 8080 occupancy
 blade c network occupy --port 8080 --force
 
+# Occupy the port 80 of the nginx service, and start the service again after the experiment is destroyed
+blade c network occupy --port 80 --force --restore-service
+
 # The machine accesses external 14.215.177.39 machine (ping www.baidu.com) 80 port packet loss rate 100%
 blade create network loss --percent 100 --interface eth0 --remote-port 80 --destination-ip 14.215.177.39`,
 			ActionPrograms:    []string{OccupyNetworkBin},
@@ -102,59 +116,168 @@ func (*OccupyActionExecutor) Name() string {
 	return "occupy"
 }
 
+// occupyUnitsDetail is the detail of the record, which is the systemd services of the processes killed by --force
+const occupyUnitsDetail = "units"
+
+// holderExitTimeout is the time waiting for the killed processes to release the port, SIGKILL is sent if
+// the port is still held after it
+var holderExitTimeout = 10 * time.Second
+
 func (oae *OccupyActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
 	// check reboot permission
 	if osutil.Geteuid() != 0 {
 		// not root
 		return spec.ResponseFailWithFlags(spec.Forbidden)
 	}
-	port := model.ActionFlags["port"]
-	if port == "" {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return oae.stop(ctx, uid, model.ActionFlags["restore-service"] == "true")
+	}
+	if model.ActionFlags["port"] == "" {
 		log.Errorf(ctx, "port is nil")
 		return spec.ResponseFailWithFlags(spec.ParameterLess, "port")
 	}
-	if _, ok := spec.IsDestroy(ctx); ok {
-		return oae.stop(port, ctx)
-	}
-	force := model.ActionFlags["force"]
-	if force == "true" {
-		// search the process which is using the port and kill it
-		// netstat -tuanp | awk '{print $4,$7}'| grep ":8182"|head -n 1
-		response := oae.channel.Run(ctx, "netstat",
-			fmt.Sprintf(`-tuanp | awk '{print $4,$7}' | grep ":%s" | head -n 1 | awk '{print $NF}'`, port))
-		// 127.0.0.1:8182 2814/hblog
-		if !response.Success {
-			return response
+	port, response := validation.ValidateInt("port", model.ActionFlags["port"], 1, 65535)
+	if response != nil {
+		return response
+	}
+	listener, response := oae.occupy(ctx, uid, port, model.ActionFlags["force"] == "true", model.ActionFlags)
+	if response != nil {
+		return response
+	}
+	// start occupy process
+	return oae.start(listener, ctx)
+}
+
+// occupy listens on the port. If it's listened by other processes, it fails with them, or kills them by
+// SIGTERM and SIGKILL with force and listens after they exit. The systemd services of the killed processes
+// are recorded, so the destroy can start them again.
+func (oae *OccupyActionExecutor) occupy(ctx context.Context, uid string, port int, force bool,
+	flags map[string]string) (net.Listener, *spec.Response) {
+	listener, err := listenPort(port)
+	if err == nil {
+		return listener, nil
+	}
+	if !errors.Is(err, syscall.EADDRINUSE) {
+		return nil, exec.Fail(exec.CommandFailed, "port", "listen", fmt.Sprintf("listen on the port %d failed, %v", port, err))
+	}
+	holders, err := exec.FindPortHolders(ctx, oae.channel, port)
+	if err != nil {
+		log.Warnf(ctx, "%v", err)
+	}
+	if len(holders) == 0 {
+		return nil, exec.Fail(exec.PortInUse, "port", "listen",
+			fmt.Sprintf("the port %d is in use, but the process listening on it is not found", port))
+	}
+	names := make([]string, 0, len(holders))
+	for _, holder := range holders {
+		names = append(names, holder.String())
+	}
+	if !force {
+		return nil, exec.Fail(exec.PortInUse, "port", "listen",
+			fmt.Sprintf("the port %d is listened by %s", port, strings.Join(names, ", ")))
+	}
+	if response := recordHolderUnits(ctx, uid, holders, flags); response != nil {
+		return nil, response
+	}
+	log.Warnf(ctx, "kill %s listening on the port %d", strings.Join(names, ", "), port)
+	for _, sig := range []syscall.Signal{syscall.SIGTERM, syscall.SIGKILL} {
+		for _, holder := range holders {
+			if err := signalProcess(holder.Pid, sig); err != nil {
+				return nil, exec.Fail(exec.ProcessControlFailed, "port", "kill",
+					fmt.Sprintf("send %s to %s failed, %v", sig, holder, err))
+			}
 		}
-		processMsg := strings.TrimSpace(response.Result.(string))
-		if processMsg != "" {
-			idx := strings.Index(processMsg, "/")
-			if idx > 0 {
-				pid := processMsg[:idx]
-				if pid != "" {
-					response := oae.channel.Run(ctx, "kill", fmt.Sprintf("-9 %s", pid))
-					if !response.Success {
-						return response
-					}
-				}
+		deadline := time.Now().Add(holderExitTimeout)
+		for {
+			if listener, err = listenPort(port); err == nil {
+				return listener, nil
+			}
+			if !errors.Is(err, syscall.EADDRINUSE) || time.Now().After(deadline) {
+				break
 			}
+			time.Sleep(100 * time.Millisecond)
 		}
 	}
-	// start occupy process
-	return oae.start(port, ctx)
+	return nil, exec.Fail(exec.PortInUse, "port", "listen",
+		fmt.Sprintf("the port %d is still in use after %s are killed, %v", port, strings.Join(names, ", "), err))
+}
+
+// listenPort listens on the tcp port of all the addresses. The listener of go sets SO_REUSEADDR on unix, so
+// the connections of the port in TIME_WAIT don't fail it, only the sockets bound to the port do.
+func listenPort(port int) (net.Listener, error) {
+	return net.Listen("tcp", fmt.Sprintf(":%d", port))
+}
+
+// signalProcess sends the signal to the process, it's not an error if the process has exited
+func signalProcess(pid int, sig syscall.Signal) error {
+	process, err := osutil.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+	if err := process.Signal(sig); err != nil && !errors.Is(err, osutil.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+// recordHolderUnits records the systemd services of the processes to be killed, the record is skipped if
+// none of them is in a service
+func recordHolderUnits(ctx context.Context, uid string, holders []exec.PortHolder, flags map[string]string) *spec.Response {
+	units := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, holder := range holders {
+		if holder.Unit != "" && !seen[holder.Unit] {
+			seen[holder.Unit] = true
+			units = append(units, holder.Unit)
+		}
+	}
+	if len(units) == 0 {
+		return nil
+	}
+	state, response := exec.NewExperimentState(ctx, uid, "network", "occupy", flags)
+	if response != nil {
+		return response
+	}
+	if err := state.SetDetail(occupyUnitsDetail, strings.Join(units, ",")); err != nil {
+		return exec.Fail(exec.StateRecordFailed, "port", "record", fmt.Sprintf("record the services %s failed, %v",
+			strings.Join(units, ","), err))
+	}
+	return nil
 }
 
-func (oae *OccupyActionExecutor) start(port string, ctx context.Context) *spec.Response {
-	err := http.ListenAndServe(fmt.Sprintf(":%s", port), nil)
+func (oae *OccupyActionExecutor) start(listener net.Listener, ctx context.Context) *spec.Response {
+	err := http.Serve(listener, nil)
 	if err != nil {
-		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("listen and serve fail %v", err))
+		return exec.Fail(exec.CommandFailed, "port", "listen", fmt.Sprintf("listen and serve fail %v", err))
 	}
 	return spec.Success()
 }
 
-func (oae *OccupyActionExecutor) stop(port string, ctx context.Context) *spec.Response {
+// stop kills the occupy process, and starts the services killed by --force again if restoreService
+func (oae *OccupyActionExecutor) stop(ctx context.Context, uid string, restoreService bool) *spec.Response {
 	ctx = context.WithValue(ctx, "bin", OccupyNetworkBin)
-	return exec.Destroy(ctx, oae.channel, "network occupy")
+	response := exec.Destroy(ctx, oae.channel, "network occupy")
+	if !response.Success {
+		return response
+	}
+	state, err := exec.LoadState(uid)
+	if err != nil || state.Destroyed || state.Details[occupyUnitsDetail] == "" {
+		return response
+	}
+	units := strings.Split(state.Details[occupyUnitsDetail], ",")
+	if !restoreService {
+		log.Warnf(ctx, "the services %s killed by the experiment are not started, specify --restore-service to start them",
+			strings.Join(units, ","))
+		exec.ReleaseResources(ctx, uid)
+		return response
+	}
+	for _, unit := range units {
+		if restored := exec.RunArgv(ctx, oae.channel, "systemctl", "start", unit); !restored.Success {
+			return exec.WithFailure(restored, exec.RestoreFailed, "port", "start "+unit)
+		}
+	}
+	exec.ReleaseResources(ctx, uid)
+	return response
 }
 
 func (oae *OccupyActionExecutor) SetChannel(channel spec.Channel) {
@@ -0,0 +1,96 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	osexec "os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// holdPort listens on a free port as the process holding it
+func holdPort(t *testing.T) (net.Listener, int) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen failed, %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return listener, listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestOccupyPortInUse(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	_, port := holdPort(t)
+	cl := exec.NewMockChannel().OnRun("ss", fmt.Sprintf("sport = :%d ", port), spec.ReturnSuccess(
+		fmt.Sprintf(`LISTEN 0 511 0.0.0.0:%d 0.0.0.0:* users:(("nginx",pid=99999999,fd=6))`, port)))
+	executor := &OccupyActionExecutor{channel: cl}
+	_, response := executor.occupy(context.Background(), "occupy-in-use", port, false, nil)
+	failure, ok := exec.GetFailure(response)
+	if !ok || failure.Kind != exec.PortInUse.Name {
+		t.Fatalf("expected the port in use, got %+v", response)
+	}
+	if !strings.Contains(response.Err, "nginx(99999999)") {
+		t.Errorf("expected the holder in the err, got %s", response.Err)
+	}
+
+	// the port is held by the process not found
+	cl.OnRun("ss", "", spec.ReturnSuccess(""))
+	if _, response = executor.occupy(context.Background(), "occupy-in-use", port, true, nil); response == nil ||
+		!strings.Contains(response.Err, "not found") {
+		t.Errorf("expected the holder not found, got %+v", response)
+	}
+}
+
+func TestOccupyForce(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	listener, port := holdPort(t)
+	holder := osexec.Command("sleep", "60")
+	if err := holder.Start(); err != nil {
+		t.Skipf("start the holder failed, %v", err)
+	}
+	// the port is released when the holder exits
+	go func() {
+		holder.Wait()
+		listener.Close()
+	}()
+	cl := exec.NewMockChannel().OnRun("ss", "", spec.ReturnSuccess(
+		fmt.Sprintf(`LISTEN 0 511 0.0.0.0:%d 0.0.0.0:* users:(("sleep",pid=%d,fd=6))`, port, holder.Process.Pid)))
+	executor := &OccupyActionExecutor{channel: cl}
+	start := time.Now()
+	occupied, response := executor.occupy(context.Background(), "occupy-force", port, true, nil)
+	if response != nil {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	defer occupied.Close()
+	if occupied.Addr().(*net.TCPAddr).Port != port {
+		t.Errorf("expected the port %d occupied, got %s", port, occupied.Addr())
+	}
+	// the holder exits by SIGTERM without SIGKILL
+	if elapsed := time.Since(start); elapsed >= holderExitTimeout {
+		t.Errorf("expected the holder killed by SIGTERM, waited %s", elapsed)
+	}
+}
@@ -0,0 +1,288 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
+)
+
+// The modes of the partition, the inbound mode drops the packets from the peers and the outbound mode drops
+// the packets to them
+const (
+	PartitionSymmetric = "symmetric"
+	PartitionInbound   = "inbound"
+	PartitionOutbound  = "outbound"
+)
+
+type PartitionActionSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewPartitionActionSpec() spec.ExpActionCommandSpec {
+	return &PartitionActionSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     "peer-ips",
+					Desc:     "The peers partitioned from the host, separated by commas, the cidr such as 10.0.5.0/24 is supported",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name:    "mode",
+					Desc:    "The direction of the partition, symmetric drops the packets from and to the peers, inbound only drops the packets from them, and outbound only drops the packets to them",
+					Default: PartitionSymmetric,
+				},
+				&spec.ExpFlag{
+					Name: "exclude-port",
+					Desc: "The local or remote ports kept reachable between the host and the peers, separated by commas or connector representing ranges, for example 22,8000-8080",
+				},
+				&spec.ExpFlag{
+					Name:   exec.AffectLoopbackKey,
+					Desc:   "Allow the loopback peer ips, the port of the blade server and the ssh session are excluded anyway",
+					NoArgs: true,
+				},
+				&spec.ExpFlag{
+					Name:   exec.NoProtectSSHKey,
+					Desc:   "Partition the ssh sessions too, by default the established sessions of the local port 22 and the one running the experiment are accepted",
+					NoArgs: true,
+				},
+			},
+			ActionFlags:    []spec.ExpFlagSpec{},
+			ActionExecutor: &NetworkPartitionExecutor{},
+			ActionExample: `
+# Partition the host from the peers 10.0.0.2 and 10.0.5.0/24 in both directions
+blade create network partition --peer-ips 10.0.0.2,10.0.5.0/24
+
+# Drop the packets from the peer 10.0.0.2 only, the packets sent to it are not affected
+blade create network partition --peer-ips 10.0.0.2 --mode inbound
+
+# Partition the host from the peer 10.0.0.2, but keep the port 9100 of the metrics reachable
+blade create network partition --peer-ips 10.0.0.2 --exclude-port 9100`,
+			ActionPrograms:   []string{DropNetworkBin},
+			ActionCategories: []string{category.SystemNetwork},
+		},
+	}
+}
+
+func (*PartitionActionSpec) Name() string {
+	return "partition"
+}
+
+func (*PartitionActionSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*PartitionActionSpec) ShortDesc() string {
+	return "Partition the host from the peers"
+}
+
+func (p *PartitionActionSpec) LongDesc() string {
+	if p.ActionLongDesc != "" {
+		return p.ActionLongDesc
+	}
+	return "Partition the host from the peers by the iptables rules dropping the packets from and to them. " +
+		"The rules are installed in one experiment, and the installed ones are removed if any of them fails"
+}
+
+type NetworkPartitionExecutor struct {
+	channel spec.Channel
+}
+
+func (*NetworkPartitionExecutor) Name() string {
+	return "partition"
+}
+
+func (pe *NetworkPartitionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if response, ok := checkDropCommands(ctx, pe.channel); !ok {
+		return response
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return pe.stop(ctx, uid, model.ActionFlags)
+	}
+	peers, mode, excludePorts, response := getPartitionFlags(model.ActionFlags)
+	if response != nil {
+		return response
+	}
+	if response := exec.CheckLoopback(ctx, model.ActionFlags, "", "peer-ips"); response != nil {
+		return response
+	}
+	var sessions []exec.SSHSession
+	if model.ActionFlags[exec.NoProtectSSHKey] != spec.True {
+		sessions = exec.ProtectedSSHSessions(ctx, pe.channel)
+	}
+	return pe.start(ctx, uid, model.ActionFlags, buildPartitionRules(peers, mode, excludePorts, sessions))
+}
+
+// getPartitionFlags validates the flags, the excluded ports are joined for the iptables multiport match
+func getPartitionFlags(flags map[string]string) ([]string, string, string, *spec.Response) {
+	if flags["peer-ips"] == "" {
+		return nil, "", "", spec.ResponseFailWithFlags(spec.ParameterLess, "peer-ips")
+	}
+	peers, response := validateDropIps("peer-ips", flags["peer-ips"])
+	if response != nil {
+		return nil, "", "", response
+	}
+	mode := flags["mode"]
+	switch mode {
+	case "":
+		mode = PartitionSymmetric
+	case PartitionSymmetric, PartitionInbound, PartitionOutbound:
+	default:
+		return nil, "", "", spec.ResponseFailWithFlags(spec.ParameterIllegal, "mode", mode,
+			fmt.Sprintf("it must be %s, %s or %s", PartitionSymmetric, PartitionInbound, PartitionOutbound))
+	}
+	excludePort := flags["exclude-port"]
+	var ranges [][]int
+	if excludePort != "" {
+		if ranges, response = validation.ValidatePortList("exclude-port", excludePort); response != nil {
+			return nil, "", "", response
+		}
+	}
+	// the blade server and the ssh session running the experiment are kept on the loopback
+	if exec.LoopbackIP(strings.Join(peers, ",")) != "" {
+		for _, port := range exec.LoopbackExcludedPorts() {
+			ranges = append(ranges, []int{port, port})
+		}
+	}
+	if len(ranges) == 0 {
+		return peers, mode, "", nil
+	}
+	ports := iptablesPorts(validation.JoinPortRanges(ranges, "-"))
+	if count := len(strings.Split(ports, ",")) + strings.Count(ports, ":"); count > maxMultiports {
+		return nil, "", "", spec.ResponseFailWithFlags(spec.ParameterIllegal, "exclude-port", excludePort,
+			fmt.Sprintf("iptables supports at most %d ports, the range counts as two", maxMultiports))
+	}
+	return peers, mode, ports, nil
+}
+
+// partitionRule is an iptables rule of the partition without the operation, the accept rules are inserted
+// ahead of the drop rules which are appended
+type partitionRule struct {
+	operation string
+	rule      []string
+}
+
+func (r partitionRule) args(operation string) []string {
+	return append([]string{operation}, r.rule...)
+}
+
+// buildPartitionRules returns the rules in the order of the installation, the accept rules of the ssh sessions
+// and the excluded ports of the peers, then the drop rules of the peers
+func buildPartitionRules(peers []string, mode, excludePorts string, sessions []exec.SSHSession) []partitionRule {
+	netFlows := []string{"INPUT", "OUTPUT"}
+	switch mode {
+	case PartitionInbound:
+		netFlows = []string{"INPUT"}
+	case PartitionOutbound:
+		netFlows = []string{"OUTPUT"}
+	}
+	peerFlag := func(netFlow string) string {
+		if netFlow == "INPUT" {
+			return "-s"
+		}
+		return "-d"
+	}
+	rules := make([]partitionRule, 0)
+	for _, netFlow := range netFlows {
+		for _, session := range sessions {
+			rules = append(rules, partitionRule{"-I", sshAcceptRuleArgs("", netFlow, session)[1:]})
+		}
+	}
+	if excludePorts != "" {
+		for _, netFlow := range netFlows {
+			for _, peer := range peers {
+				for _, protocol := range []string{"tcp", "udp"} {
+					rules = append(rules, partitionRule{"-I", []string{netFlow, "-p", protocol, peerFlag(netFlow), peer,
+						"-m", "multiport", "--ports", excludePorts, "-j", "ACCEPT"}})
+				}
+			}
+		}
+	}
+	for _, netFlow := range netFlows {
+		for _, peer := range peers {
+			rules = append(rules, partitionRule{"-A", []string{netFlow, peerFlag(netFlow), peer, "-j", "DROP"}})
+		}
+	}
+	return rules
+}
+
+// start installs the rules one by one and records the deletion of each, the installed rules are removed by the
+// record if any of them fails, so the partition is never left half installed
+func (pe *NetworkPartitionExecutor) start(ctx context.Context, uid string, flags map[string]string, rules []partitionRule) *spec.Response {
+	state, response := exec.NewExperimentState(ctx, uid, "network", "partition", flags)
+	if response != nil {
+		return response
+	}
+	for _, rule := range rules {
+		response = exec.RunIptablesArgv(ctx, pe.channel, rule.args(rule.operation)...)
+		if !response.Success {
+			pe.rollback(ctx, uid)
+			return exec.WithFailure(response, exec.RuleInstallFailed, "iptables", "partition")
+		}
+		undoArgs := rule.args("-D")
+		if err := state.AddUndo("iptables", exec.ShellJoin(undoArgs...)); err != nil {
+			log.Errorf(ctx, "record the iptables rule failed, %v", err)
+			exec.RunIptablesArgv(ctx, pe.channel, undoArgs...)
+			pe.rollback(ctx, uid)
+			return exec.Fail(exec.StateRecordFailed, "iptables", "AddUndo", fmt.Sprintf("record the iptables rule failed, %v", err))
+		}
+	}
+	return response
+}
+
+func (pe *NetworkPartitionExecutor) rollback(ctx context.Context, uid string) {
+	if response, _ := exec.DestroyByState(ctx, pe.channel, uid); !response.Success {
+		log.Errorf(ctx, "remove the installed rules of the partition failed, %s", response.Err)
+	}
+}
+
+// stop removes the rules by the record. The experiment without the record removes the drop rules and the accept
+// rules of the excluded ports built from the flags, the accept rules of the ssh sessions are unknown then.
+func (pe *NetworkPartitionExecutor) stop(ctx context.Context, uid string, flags map[string]string) *spec.Response {
+	if response, ok := exec.DestroyByState(ctx, pe.channel, uid); ok {
+		return response
+	}
+	peers, mode, excludePorts, response := getPartitionFlags(flags)
+	if response != nil {
+		return response
+	}
+	rules := buildPartitionRules(peers, mode, excludePorts, nil)
+	for i := len(rules) - 1; i >= 0; i-- {
+		if response = exec.RunIptablesArgv(ctx, pe.channel, rules[i].args("-D")...); !response.Success {
+			return exec.WithFailure(response, exec.RuleRemoveFailed, "iptables", "partition")
+		}
+	}
+	return response
+}
+
+func (pe *NetworkPartitionExecutor) SetChannel(channel spec.Channel) {
+	pe.channel = channel
+}
+
+// Status checks the recorded iptables rules with iptables -C
+func (pe *NetworkPartitionExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	return iptablesStatus(ctx, pe.channel, uid, model, state)
+}
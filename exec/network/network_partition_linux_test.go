@@ -0,0 +1,119 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func execPartition(ctx context.Context, cl spec.Channel, uid string, flags map[string]string) *spec.Response {
+	executor := &NetworkPartitionExecutor{}
+	executor.SetChannel(cl)
+	return executor.Exec(uid, ctx, &spec.ExpModel{Target: "network", ActionName: "partition", ActionFlags: flags})
+}
+
+func TestNetworkPartitionExecutor(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := withoutSSHSessions(t, exec.NewMockChannel())
+	t.Setenv("SSH_CONNECTION", "10.0.0.9 52314 10.0.0.1 22")
+	flags := map[string]string{"peer-ips": "10.0.0.2,10.0.5.0/24", "exclude-port": "9100"}
+
+	if response := execPartition(ctx, cl, "partition-1", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"iptables -I INPUT -p tcp -s 10.0.0.9 -d 10.0.0.1 --sport 52314 --dport 22 -j ACCEPT",
+		"iptables -I OUTPUT -p tcp -d 10.0.0.9 -s 10.0.0.1 --dport 52314 --sport 22 -j ACCEPT",
+		"iptables -I INPUT -p tcp -s 10.0.0.2 -m multiport --ports 9100 -j ACCEPT",
+		"iptables -I INPUT -p udp -s 10.0.0.2 -m multiport --ports 9100 -j ACCEPT",
+		"iptables -I INPUT -p tcp -s 10.0.5.0/24 -m multiport --ports 9100 -j ACCEPT",
+		"iptables -I INPUT -p udp -s 10.0.5.0/24 -m multiport --ports 9100 -j ACCEPT",
+		"iptables -I OUTPUT -p tcp -d 10.0.0.2 -m multiport --ports 9100 -j ACCEPT",
+		"iptables -I OUTPUT -p udp -d 10.0.0.2 -m multiport --ports 9100 -j ACCEPT",
+		"iptables -I OUTPUT -p tcp -d 10.0.5.0/24 -m multiport --ports 9100 -j ACCEPT",
+		"iptables -I OUTPUT -p udp -d 10.0.5.0/24 -m multiport --ports 9100 -j ACCEPT",
+		"iptables -A INPUT -s 10.0.0.2 -j DROP",
+		"iptables -A INPUT -s 10.0.5.0/24 -j DROP",
+		"iptables -A OUTPUT -d 10.0.0.2 -j DROP",
+		"iptables -A OUTPUT -d 10.0.5.0/24 -j DROP",
+	})
+
+	// the whole set is removed by the record without the flags
+	cl.Reset()
+	if response := execPartition(spec.SetDestroyFlag(ctx, "partition-1"), cl, "partition-1", map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	if lines := cl.CommandLines(); len(lines) != 14 || lines[0] != "iptables -D OUTPUT -d 10.0.5.0/24 -j DROP" ||
+		lines[13] != "iptables -D INPUT -p tcp -s 10.0.0.9 -d 10.0.0.1 --sport 52314 --dport 22 -j ACCEPT" {
+		t.Errorf("expected the rules removed in the reverse order, got %v", lines)
+	}
+}
+
+func TestNetworkPartitionExecutorRollback(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := withoutSSHSessions(t, exec.NewMockChannel()).
+		OnRun("iptables", "^-A OUTPUT", spec.ReturnFail(spec.OsCmdExecFailed, "iptables failed"))
+	flags := map[string]string{"peer-ips": "10.0.0.2", "mode": PartitionSymmetric}
+
+	response := execPartition(ctx, cl, "partition-2", flags)
+	if failure, ok := exec.GetFailure(response); !ok || failure.Kind != exec.RuleInstallFailed.Name {
+		t.Fatalf("expected the failure of the outbound rule, got %+v", response)
+	}
+	// the inbound rule is removed, no half partition is left
+	assertCommands(t, cl, []string{
+		"iptables -A INPUT -s 10.0.0.2 -j DROP",
+		"iptables -A OUTPUT -d 10.0.0.2 -j DROP",
+		"iptables -D INPUT -s 10.0.0.2 -j DROP",
+	})
+}
+
+func TestNetworkPartitionExecutorFlags(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	cl := withoutSSHSessions(t, exec.NewMockChannel())
+	for _, tt := range []struct {
+		flags map[string]string
+		code  int32
+	}{
+		{flags: map[string]string{}, code: spec.ParameterLess.Code},
+		{flags: map[string]string{"peer-ips": "10.0.0.256"}, code: spec.ParameterIllegal.Code},
+		{flags: map[string]string{"peer-ips": "10.0.0.2", "mode": "both"}, code: spec.ParameterIllegal.Code},
+		{flags: map[string]string{"peer-ips": "127.0.0.1"}, code: spec.Forbidden.Code},
+	} {
+		if response := execPartition(context.Background(), cl, "partition-3", tt.flags); response.Success || response.Code != tt.code {
+			t.Errorf("expected the code %d of %v, got %+v", tt.code, tt.flags, response)
+		}
+	}
+	assertCommands(t, cl, []string{})
+
+	// only the packets from the peer are dropped inbound, the loopback keeps the port of the blade server
+	flags := map[string]string{"peer-ips": "127.0.0.2", "mode": PartitionInbound, exec.AffectLoopbackKey: spec.True}
+	if response := execPartition(context.Background(), cl, "partition-4", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	assertCommands(t, cl, []string{
+		"iptables -I INPUT -p tcp -s 127.0.0.2 -m multiport --ports 9526 -j ACCEPT",
+		"iptables -I INPUT -p udp -s 127.0.0.2 -m multiport --ports 9526 -j ACCEPT",
+		"iptables -A INPUT -s 127.0.0.2 -j DROP",
+	})
+}
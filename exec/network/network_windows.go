@@ -0,0 +1,66 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/network/tc"
+)
+
+func NewNetworkCommandSpec() spec.ExpModelCommandSpec {
+	delay := tc.NewDelayActionSpec()
+	delay.SetExecutor(&shaperUnavailableExecutor{name: "delay"})
+	loss := tc.NewLossActionSpec()
+	loss.SetExecutor(&shaperUnavailableExecutor{name: "loss"})
+	return &NetworkCommandSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				delay,
+				NewDropActionSpec(),
+				loss,
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+// shaperUnavailableExecutor keeps the flags of the tc actions, but returns the capability error, because the
+// packets can only be delayed or lost by a user space shaper based on the WinDivert driver, which is not bundled
+type shaperUnavailableExecutor struct {
+	name    string
+	channel spec.Channel
+}
+
+func (s *shaperUnavailableExecutor) Name() string {
+	return s.name
+}
+
+func (s *shaperUnavailableExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return spec.ReturnSuccess("nothing to destroy")
+	}
+	return spec.ResponseFailWithFlags(spec.ActionNotSupport,
+		fmt.Sprintf("network %s on windows, the WinDivert driver is unavailable", s.name))
+}
+
+func (s *shaperUnavailableExecutor) SetChannel(channel spec.Channel) {
+	s.channel = channel
+}
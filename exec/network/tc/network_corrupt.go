@@ -23,6 +23,7 @@ import (
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
 )
 
@@ -84,29 +85,34 @@ func (ce *NetworkCorruptExecutor) Exec(uid string, ctx context.Context, model *s
 		return response
 	}
 
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return ce.stop(ctx, uid, model.ActionFlags["interface"])
+	}
 	netInterface := model.ActionFlags["interface"]
 	if netInterface == "" {
 		log.Errorf(ctx, "interface is nil")
 		return spec.ResponseFailWithFlags(spec.ParameterLess, "interface")
 	}
-	if _, ok := spec.IsDestroy(ctx); ok {
-		return ce.stop(netInterface, ctx)
-	} else {
-		percent := model.ActionFlags["percent"]
-		if percent == "" {
-			log.Errorf(ctx, "percent is nil")
-			return spec.ResponseFailWithFlags(spec.ParameterLess, "percent")
-		}
-		localPort := model.ActionFlags["local-port"]
-		remotePort := model.ActionFlags["remote-port"]
-		excludePort := model.ActionFlags["exclude-port"]
-		destIp := model.ActionFlags["destination-ip"]
-		excludeIp := model.ActionFlags["exclude-ip"]
-		ignorePeerPort := model.ActionFlags["ignore-peer-port"] == "true"
-		protocol := model.ActionFlags["protocol"]
-		force := model.ActionFlags["force"] == "true"
-		return ce.start(netInterface, localPort, remotePort, excludePort, destIp, excludeIp, percent, ignorePeerPort, force, protocol, ctx)
+	percent := model.ActionFlags["percent"]
+	if percent == "" {
+		log.Errorf(ctx, "percent is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "percent")
 	}
+	percent, response := validatePercent("percent", percent)
+	if response != nil {
+		return response
+	}
+	localPort := model.ActionFlags["local-port"]
+	remotePort := model.ActionFlags["remote-port"]
+	excludePort := model.ActionFlags["exclude-port"]
+	destIp := model.ActionFlags["destination-ip"]
+	excludeIp := model.ActionFlags["exclude-ip"]
+	ignorePeerPort := model.ActionFlags["ignore-peer-port"] == "true"
+	protocol := model.ActionFlags["protocol"]
+	force := model.ActionFlags["force"] == "true"
+	return claimInterface(ctx, ce.channel, uid, model, netInterface, force, func(ctx context.Context, netInterface string) *spec.Response {
+		return ce.start(netInterface, localPort, remotePort, excludePort, destIp, excludeIp, percent, ignorePeerPort, force, protocol, ctx)
+	})
 }
 
 func (ce *NetworkCorruptExecutor) start(netInterface, localPort, remotePort, excludePort, destIp, excludeIp, percent string,
@@ -117,10 +123,15 @@ func (ce *NetworkCorruptExecutor) start(netInterface, localPort, remotePort, exc
 	return startNet(ctx, netInterface, classRule, localPort, remotePort, excludePort, destIp, excludeIp, force, ignorePeerPort, protocol, ce.channel)
 }
 
-func (ce *NetworkCorruptExecutor) stop(netInterface string, ctx context.Context) *spec.Response {
-	return stopNet(ctx, netInterface, ce.channel)
+func (ce *NetworkCorruptExecutor) stop(ctx context.Context, uid, netInterface string) *spec.Response {
+	return destroyNet(ctx, ce.channel, uid, netInterface)
 }
 
 func (ce *NetworkCorruptExecutor) SetChannel(channel spec.Channel) {
 	ce.channel = channel
 }
+
+// Status checks the netem qdisc on the interface
+func (ce *NetworkCorruptExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	return tcStatus(ctx, ce.channel, uid, model, state)
+}
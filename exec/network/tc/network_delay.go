@@ -19,11 +19,14 @@ package tc
 import (
 	"context"
 	"fmt"
+	"math"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
 )
 
 type DelayActionSpec struct {
@@ -44,6 +47,23 @@ func NewDelayActionSpec() spec.ExpActionCommandSpec {
 					Name: "offset",
 					Desc: "Delay offset time, ms",
 				},
+				&spec.ExpFlag{
+					Name: "slot-min",
+					Desc: "The minimum time between the bursts of the packets, ms. The packets are held and sent in a burst at the end of the slot, which requires the kernel 4.8 or later. It's off by default",
+				},
+				&spec.ExpFlag{
+					Name: "slot-max",
+					Desc: "The maximum time between the bursts of the packets, ms, the slot is random between slot-min and it. The default value is slot-min",
+				},
+				&spec.ExpFlag{
+					Name: "slot-packets",
+					Desc: "The maximum packets sent in a burst of the slot",
+				},
+				&spec.ExpFlag{
+					Name: "slot-bytes",
+					Desc: "The maximum bytes sent in a burst of the slot",
+				},
+				icmpFlag,
 			},
 			ActionExecutor: &NetworkDelayExecutor{},
 			ActionExample: `
@@ -54,7 +74,16 @@ blade create network delay --time 3000 --offset 1000 --interface eth0 --local-po
 blade create network delay --time 3000 --interface eth0 --remote-port 80 --destination-ip 14.215.177.39
 
 # Do a 5 second delay for the entire network card eth0, excluding ports 22 and 8000 to 8080
-blade create network delay --time 5000 --interface eth0 --exclude-port 22,8000-8080`,
+blade create network delay --time 5000 --interface eth0 --exclude-port 22,8000-8080
+
+# Only the ping to 14.215.177.39 is delayed by 3 seconds, the tcp and udp traffic is not affected
+blade create network delay --time 3000 --interface eth0 --icmp --destination-ip 14.215.177.39
+
+# Delay the local port 8080 on the lo interface by 100 milliseconds, the port of the blade server is excluded
+blade create network delay --time 100 --interface lo --local-port 8080 --affect-loopback
+
+# Stall the traffic of eth0 for 800 to 1000 milliseconds, then send at most 64 packets in a burst
+blade create network delay --time 10 --interface eth0 --slot-min 800 --slot-max 1000 --slot-packets 64`,
 			ActionPrograms:   []string{TcNetworkBin},
 			ActionCategories: []string{category.SystemNetwork},
 		},
@@ -94,46 +123,113 @@ func (de *NetworkDelayExecutor) Exec(uid string, ctx context.Context, model *spe
 		return response
 	}
 
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return de.stop(ctx, uid, model.ActionFlags["interface"])
+	}
 	netInterface := model.ActionFlags["interface"]
 	if netInterface == "" {
 		log.Errorf(ctx, "interface is nil")
 		return spec.ResponseFailWithFlags(spec.ParameterLess, "interface")
 	}
-	if _, ok := spec.IsDestroy(ctx); ok {
-		return de.stop(netInterface, ctx)
-	} else {
-		time := model.ActionFlags["time"]
-		if time == "" {
-			log.Errorf(ctx, "time is nil")
-			return spec.ResponseFailWithFlags(spec.ParameterLess, "time")
-		}
-		offset := model.ActionFlags["offset"]
-		if offset == "" {
-			offset = "0"
+	time := model.ActionFlags["time"]
+	if time == "" {
+		log.Errorf(ctx, "time is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "time")
+	}
+	offset := model.ActionFlags["offset"]
+	if offset == "" {
+		offset = "0"
+	}
+	if _, response := validation.ValidateInt("time", time, 0, math.MaxInt); response != nil {
+		return response
+	}
+	if _, response := validation.ValidateInt("offset", offset, 0, math.MaxInt); response != nil {
+		return response
+	}
+	slot, response := getSlotRule(model.ActionFlags)
+	if response != nil {
+		return response
+	}
+	if slot != "" {
+		if err := exec.CheckKernelVersion("the slot of netem", 4, 8); err != nil {
+			log.Errorf(ctx, "%v", err)
+			return exec.Fail(exec.UnsupportedPlatform, "network", "delay", err.Error())
 		}
-		localPort := model.ActionFlags["local-port"]
-		remotePort := model.ActionFlags["remote-port"]
-		excludePort := model.ActionFlags["exclude-port"]
-		destIp := model.ActionFlags["destination-ip"]
-		excludeIp := model.ActionFlags["exclude-ip"]
-		ignorePeerPort := model.ActionFlags["ignore-peer-port"] == "true"
-		protocol := model.ActionFlags["protocol"]
-		force := model.ActionFlags["force"] == "true"
-		return de.start(localPort, remotePort, excludePort, destIp, excludeIp, time, offset, netInterface, ignorePeerPort, force, protocol, ctx)
 	}
+	localPort := model.ActionFlags["local-port"]
+	remotePort := model.ActionFlags["remote-port"]
+	excludePort := model.ActionFlags["exclude-port"]
+	destIp := model.ActionFlags["destination-ip"]
+	excludeIp := model.ActionFlags["exclude-ip"]
+	ignorePeerPort := model.ActionFlags["ignore-peer-port"] == "true"
+	protocol, response := getProtocol(model.ActionFlags)
+	if response != nil {
+		return response
+	}
+	force := model.ActionFlags["force"] == "true"
+	return claimInterface(ctx, de.channel, uid, model, netInterface, force, func(ctx context.Context, netInterface string) *spec.Response {
+		return de.start(localPort, remotePort, excludePort, destIp, excludeIp, time, offset, slot, netInterface, ignorePeerPort, force, protocol, ctx)
+	})
 }
 
-func (de *NetworkDelayExecutor) start(localPort, remotePort, excludePort, destIp, excludeIp, time, offset, netInterface string,
+func (de *NetworkDelayExecutor) start(localPort, remotePort, excludePort, destIp, excludeIp, time, offset, slot, netInterface string,
 	ignorePeerPort, force bool, protocol string, ctx context.Context,
 ) *spec.Response {
 	classRule := fmt.Sprintf("netem delay %sms %sms", time, offset)
+	if slot != "" {
+		classRule = fmt.Sprintf("%s %s", classRule, slot)
+	}
 	return startNet(ctx, netInterface, classRule, localPort, remotePort, excludePort, destIp, excludeIp, force, ignorePeerPort, protocol, de.channel)
 }
 
-func (de *NetworkDelayExecutor) stop(netInterface string, ctx context.Context) *spec.Response {
-	return stopNet(ctx, netInterface, de.channel)
+// getSlotRule returns the slot option of netem, such as slot 800ms 1000ms packets 64, it's empty if none of
+// the slot flags is specified
+func getSlotRule(flags map[string]string) (string, *spec.Response) {
+	slotMin, slotMax := flags["slot-min"], flags["slot-max"]
+	slotPackets, slotBytes := flags["slot-packets"], flags["slot-bytes"]
+	if slotMin == "" {
+		if slotMax != "" || slotPackets != "" || slotBytes != "" {
+			return "", spec.ResponseFailWithFlags(spec.ParameterLess, "slot-min")
+		}
+		return "", nil
+	}
+	min, response := validation.ValidateInt("slot-min", slotMin, 1, math.MaxInt)
+	if response != nil {
+		return "", response
+	}
+	max := min
+	if slotMax != "" {
+		if max, response = validation.ValidateInt("slot-max", slotMax, min, math.MaxInt); response != nil {
+			return "", response
+		}
+	}
+	rule := fmt.Sprintf("slot %dms %dms", min, max)
+	if slotPackets != "" {
+		packets, response := validation.ValidateInt("slot-packets", slotPackets, 1, math.MaxInt32)
+		if response != nil {
+			return "", response
+		}
+		rule = fmt.Sprintf("%s packets %d", rule, packets)
+	}
+	if slotBytes != "" {
+		bytes, response := validation.ValidateInt("slot-bytes", slotBytes, 1, math.MaxInt32)
+		if response != nil {
+			return "", response
+		}
+		rule = fmt.Sprintf("%s bytes %d", rule, bytes)
+	}
+	return rule, nil
+}
+
+func (de *NetworkDelayExecutor) stop(ctx context.Context, uid, netInterface string) *spec.Response {
+	return destroyNet(ctx, de.channel, uid, netInterface)
 }
 
 func (de *NetworkDelayExecutor) SetChannel(channel spec.Channel) {
 	de.channel = channel
 }
+
+// Status checks the netem qdisc on the interface
+func (de *NetworkDelayExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	return tcStatus(ctx, de.channel, uid, model, state)
+}
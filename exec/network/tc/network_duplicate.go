@@ -23,6 +23,7 @@ import (
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
 )
 
@@ -84,29 +85,34 @@ func (de *NetworkDuplicateExecutor) Exec(uid string, ctx context.Context, model
 		return response
 	}
 
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return de.stop(ctx, uid, model.ActionFlags["interface"])
+	}
 	netInterface := model.ActionFlags["interface"]
 	if netInterface == "" {
 		log.Errorf(ctx, "interface is nil")
 		return spec.ResponseFailWithFlags(spec.ParameterLess, "interface")
 	}
-	if _, ok := spec.IsDestroy(ctx); ok {
-		return de.stop(netInterface, ctx)
-	} else {
-		percent := model.ActionFlags["percent"]
-		if percent == "" {
-			log.Errorf(ctx, "percent is nil")
-			return spec.ResponseFailWithFlags(spec.ParameterLess, "interface")
-		}
-		localPort := model.ActionFlags["local-port"]
-		remotePort := model.ActionFlags["remote-port"]
-		excludePort := model.ActionFlags["exclude-port"]
-		destIp := model.ActionFlags["destination-ip"]
-		excludeIp := model.ActionFlags["exclude-ip"]
-		ignorePeerPort := model.ActionFlags["ignore-peer-port"] == "true"
-		protocol := model.ActionFlags["protocol"]
-		force := model.ActionFlags["force"] == "true"
-		return de.start(netInterface, localPort, remotePort, excludePort, destIp, excludeIp, percent, ignorePeerPort, force, protocol, ctx)
+	percent := model.ActionFlags["percent"]
+	if percent == "" {
+		log.Errorf(ctx, "percent is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "interface")
 	}
+	percent, response := validatePercent("percent", percent)
+	if response != nil {
+		return response
+	}
+	localPort := model.ActionFlags["local-port"]
+	remotePort := model.ActionFlags["remote-port"]
+	excludePort := model.ActionFlags["exclude-port"]
+	destIp := model.ActionFlags["destination-ip"]
+	excludeIp := model.ActionFlags["exclude-ip"]
+	ignorePeerPort := model.ActionFlags["ignore-peer-port"] == "true"
+	protocol := model.ActionFlags["protocol"]
+	force := model.ActionFlags["force"] == "true"
+	return claimInterface(ctx, de.channel, uid, model, netInterface, force, func(ctx context.Context, netInterface string) *spec.Response {
+		return de.start(netInterface, localPort, remotePort, excludePort, destIp, excludeIp, percent, ignorePeerPort, force, protocol, ctx)
+	})
 }
 
 func (de *NetworkDuplicateExecutor) start(netInterface, localPort, remotePort, excludePort, destIp, excludeIp, percent string,
@@ -117,10 +123,15 @@ func (de *NetworkDuplicateExecutor) start(netInterface, localPort, remotePort, e
 	return startNet(ctx, netInterface, classRule, localPort, remotePort, excludePort, destIp, excludeIp, force, ignorePeerPort, protocol, de.channel)
 }
 
-func (de *NetworkDuplicateExecutor) stop(netInterface string, ctx context.Context) *spec.Response {
-	return stopNet(ctx, netInterface, de.channel)
+func (de *NetworkDuplicateExecutor) stop(ctx context.Context, uid, netInterface string) *spec.Response {
+	return destroyNet(ctx, de.channel, uid, netInterface)
 }
 
 func (de *NetworkDuplicateExecutor) SetChannel(channel spec.Channel) {
 	de.channel = channel
 }
+
+// Status checks the netem qdisc on the interface
+func (de *NetworkDuplicateExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	return tcStatus(ctx, de.channel, uid, model, state)
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
 )
 
@@ -40,6 +41,7 @@ func NewLossActionSpec() spec.ExpActionCommandSpec {
 					Desc:     "loss percent, [0, 100]",
 					Required: true,
 				},
+				icmpFlag,
 			},
 			ActionExecutor: &NetworkLossExecutor{},
 			ActionExample: `
@@ -52,6 +54,9 @@ blade create network loss --percent 100 --interface eth0 --remote-port 80 --dest
 # Do 60% packet loss for the entire network card Eth0, excluding ports 22 and 8000 to 8080
 blade create network loss --percent 60 --interface eth0 --exclude-port 22,8000-8080
 
+# Lose 50% of the icmp packets on eth0, the tcp and udp traffic is not affected
+blade create network loss --percent 50 --interface eth0 --icmp
+
 # Realize the whole network card is not accessible, not accessible time 20 seconds. After executing the following command, the current network is disconnected and restored in 20 seconds. Remember!! Don't forget -timeout parameter
 blade create network loss --percent 100 --interface eth0 --timeout 20`,
 			ActionPrograms:   []string{TcNetworkBin},
@@ -93,6 +98,9 @@ func (nle *NetworkLossExecutor) Exec(uid string, ctx context.Context, model *spe
 		return response
 	}
 
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return nle.stop(ctx, uid, model.ActionFlags["interface"])
+	}
 	dev := ""
 	if netInterface, ok := model.ActionFlags["interface"]; ok {
 		if netInterface == "" {
@@ -101,23 +109,29 @@ func (nle *NetworkLossExecutor) Exec(uid string, ctx context.Context, model *spe
 		}
 		dev = netInterface
 	}
-	if _, ok := spec.IsDestroy(ctx); ok {
-		return nle.stop(dev, ctx)
-	}
 	percent := model.ActionFlags["percent"]
 	if percent == "" {
 		log.Errorf(ctx, "percent is nil")
 		return spec.ResponseFailWithFlags(spec.ParameterLess, "percent")
 	}
+	percent, response := validatePercent("percent", percent)
+	if response != nil {
+		return response
+	}
 	localPort := model.ActionFlags["local-port"]
 	remotePort := model.ActionFlags["remote-port"]
 	excludePort := model.ActionFlags["exclude-port"]
 	destIp := model.ActionFlags["destination-ip"]
 	excludeIp := model.ActionFlags["exclude-ip"]
 	ignorePeerPort := model.ActionFlags["ignore-peer-port"] == "true"
-	protocol := model.ActionFlags["protocol"]
+	protocol, response := getProtocol(model.ActionFlags)
+	if response != nil {
+		return response
+	}
 	force := model.ActionFlags["force"] == "true"
-	return nle.start(dev, localPort, remotePort, excludePort, destIp, excludeIp, percent, ignorePeerPort, force, protocol, ctx)
+	return claimInterface(ctx, nle.channel, uid, model, dev, force, func(ctx context.Context, dev string) *spec.Response {
+		return nle.start(dev, localPort, remotePort, excludePort, destIp, excludeIp, percent, ignorePeerPort, force, protocol, ctx)
+	})
 }
 
 func (nle *NetworkLossExecutor) start(netInterface, localPort, remotePort, excludePort, destIp, excludeIp, percent string,
@@ -127,10 +141,15 @@ func (nle *NetworkLossExecutor) start(netInterface, localPort, remotePort, exclu
 	return startNet(ctx, netInterface, classRule, localPort, remotePort, excludePort, destIp, excludeIp, force, ignorePeerPort, protocol, nle.channel)
 }
 
-func (nle *NetworkLossExecutor) stop(netInterface string, ctx context.Context) *spec.Response {
-	return stopNet(ctx, netInterface, nle.channel)
+func (nle *NetworkLossExecutor) stop(ctx context.Context, uid, netInterface string) *spec.Response {
+	return destroyNet(ctx, nle.channel, uid, netInterface)
 }
 
 func (nle *NetworkLossExecutor) SetChannel(channel spec.Channel) {
 	nle.channel = channel
 }
+
+// Status checks the netem qdisc on the interface
+func (nle *NetworkLossExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	return tcStatus(ctx, nle.channel, uid, model, state)
+}
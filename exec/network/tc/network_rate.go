@@ -0,0 +1,399 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tc
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
+)
+
+const rateAction = "rate"
+
+type RateActionSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewRateActionSpec() spec.ExpActionCommandSpec {
+	return &RateActionSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: selectFlags("local-port", "remote-port", "destination-ip", "interface", container.PidFlagName, container.VethFlagName, "force",
+				exec.NoProtectSSHKey, exec.AffectLoopbackKey),
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "rate",
+					Desc: "The bandwidth of the traffic selected by the port and ip flags, or the whole interface without them, for example 5mbit, 100kbit or 1mbps. It's required unless the limit flag is specified",
+				},
+				&spec.ExpFlag{
+					Name: "ceil",
+					Desc: "The bandwidth which the traffic can borrow up to, it must not be less than the rate. The default value is the rate",
+				},
+				&spec.ExpFlag{
+					Name: "limit",
+					Desc: "The groups of the traffic limited separately, the groups are separated by semicolons, and the fields of a group, destination-ip, local-port, remote-port, rate and ceil, are separated by spaces, for example \"destination-ip=10.0.5.0/24 rate=5mbit;remote-port=443 rate=1mbit ceil=2mbit\"",
+				},
+			},
+			ActionExecutor: &NetworkRateExecutor{},
+			ActionExample: `
+# Limit the bandwidth of the whole network card eth0 to 10mbit
+blade create network rate --rate 10mbit --interface eth0
+
+# Limit the traffic to 10.0.5.0/24 to 5mbit, the other traffic is not affected
+blade create network rate --rate 5mbit --interface eth0 --destination-ip 10.0.5.0/24
+
+# Limit the traffic to 10.0.5.0/24 to 5mbit and the traffic to the remote port 443 to 1mbit which can borrow up to 2mbit
+blade create network rate --interface eth0 --limit "destination-ip=10.0.5.0/24 rate=5mbit;remote-port=443 rate=1mbit ceil=2mbit"`,
+			ActionPrograms:   []string{TcNetworkBin},
+			ActionCategories: []string{category.SystemNetwork},
+		},
+	}
+}
+
+func (*RateActionSpec) Name() string {
+	return rateAction
+}
+
+func (*RateActionSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*RateActionSpec) ShortDesc() string {
+	return "Bandwidth limit experiment"
+}
+
+func (r *RateActionSpec) LongDesc() string {
+	if r.ActionLongDesc != "" {
+		return r.ActionLongDesc
+	}
+	return "Limit the bandwidth of the interface, or the traffic selected by the destination ip and the ports, by the classes of htb. " +
+		"The traffic not selected is sent without the limit"
+}
+
+type NetworkRateExecutor struct {
+	channel spec.Channel
+}
+
+func (re *NetworkRateExecutor) Name() string {
+	return rateAction
+}
+
+// rateGroup is the traffic limited by a class of htb, the traffic of the group without the selectors is the
+// default class of the interface
+type rateGroup struct {
+	destIps          []string
+	localPortRanges  [][]int
+	remotePortRanges [][]int
+	rate             string
+	ceil             string
+}
+
+func (g *rateGroup) isDefault() bool {
+	return len(g.destIps) == 0 && len(g.localPortRanges) == 0 && len(g.remotePortRanges) == 0
+}
+
+func (re *NetworkRateExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	commands := []string{"tc", "head"}
+	if response, ok := re.channel.IsAllCommandsAvailable(ctx, commands); !ok {
+		return response
+	}
+
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return re.stop(ctx, uid, model.ActionFlags["interface"])
+	}
+	netInterface := model.ActionFlags["interface"]
+	if netInterface == "" {
+		log.Errorf(ctx, "interface is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "interface")
+	}
+	groups, response := getRateGroups(model.ActionFlags)
+	if response != nil {
+		return response
+	}
+	limitIps := make([]string, 0)
+	for _, group := range groups {
+		limitIps = append(limitIps, group.destIps...)
+	}
+	if response := exec.CheckLoopback(ctx, map[string]string{
+		exec.AffectLoopbackKey: model.ActionFlags[exec.AffectLoopbackKey],
+		"limit":                strings.Join(limitIps, delimiter),
+	}, "", "limit"); response != nil {
+		return response
+	}
+	force := model.ActionFlags["force"] == "true"
+	return claimInterface(ctx, re.channel, uid, model, netInterface, force, func(ctx context.Context, netInterface string) *spec.Response {
+		return re.start(ctx, netInterface, groups, force, exec.LoopbackIP(strings.Join(limitIps, delimiter)) != "")
+	})
+}
+
+// start adds the htb root qdisc whose unclassified traffic is sent directly, and a class with the filters for
+// each group. The group without the selectors is the default class. The ssh sessions and the ports kept on the
+// loopback are sent directly by the filters ahead of the ones of the groups.
+func (re *NetworkRateExecutor) start(ctx context.Context, netInterface string, groups []*rateGroup, force, loopback bool) *spec.Response {
+	// the traffic sent directly is queued by the txqueuelen of the interface
+	response := preHandleTxqueue(ctx, netInterface, re.channel)
+	if !response.Success {
+		return response
+	}
+	if force {
+		stopNet(ctx, netInterface, re.channel)
+	}
+	sessions, _ := ctx.Value(sshSessionsKey{}).([]exec.SSHSession)
+	var excludedPorts []int
+	if netInterface == exec.LoopbackInterface || loopback {
+		excludedPorts = exec.LoopbackExcludedPorts()
+	}
+	iface, _ := ctx.Value(tcInterfaceKey{}).(*tcInterface)
+	response = re.channel.Run(ctx, "tc", buildRateArgs(netInterface, groups, sessions, excludedPorts, iface.needsLeafQueue()))
+	if !response.Success {
+		stopNet(ctx, netInterface, re.channel)
+	}
+	return response
+}
+
+// rateClassId returns the class of the group, the minor of the class is hexadecimal for tc
+func rateClassId(index int) string {
+	return fmt.Sprintf("1:%x", 0x10+index)
+}
+
+// buildRateArgs returns the htb qdisc, the classes and the filters of the groups. The packets sent directly and
+// the leaf of each class are queued by the txqueuelen of the interface, so the leaf queues are explicit on the
+// interface without the tx queue, such as the vlan and the veth.
+func buildRateArgs(netInterface string, groups []*rateGroup, sessions []exec.SSHSession, excludedPorts []int, leafQueue bool) string {
+	defaultClass := "0"
+	for i, group := range groups {
+		if group.isDefault() {
+			defaultClass = fmt.Sprintf("%x", 0x10+i)
+		}
+	}
+	root := fmt.Sprintf("qdisc add dev %s root handle 1: htb default %s", netInterface, defaultClass)
+	if leafQueue {
+		root = fmt.Sprintf("%s direct_qlen %d", root, leafQueueLimit)
+	}
+	args := []string{root}
+	for i, group := range groups {
+		args = append(args, fmt.Sprintf("class add dev %s parent 1: classid %s htb rate %s ceil %s",
+			netInterface, rateClassId(i), group.rate, group.ceil))
+		if leafQueue {
+			args = append(args, fmt.Sprintf("qdisc add dev %s parent %s pfifo limit %d", netInterface, rateClassId(i), leafQueueLimit))
+		}
+	}
+	// the flowid of the htb qdisc itself sends the packets directly
+	if len(sessions) > 0 {
+		args = append(args, buildSSHSessionFilters(netInterface, sessions, "1:"))
+	}
+	for _, port := range excludedPorts {
+		args = append(args, fmt.Sprintf(`filter add dev %s parent 1: prio 1 protocol ip u32 match ip dport %d 0xffff flowid 1:`, netInterface, port),
+			fmt.Sprintf(`filter add dev %s parent 1: prio 1 protocol ip u32 match ip sport %d 0xffff flowid 1:`, netInterface, port))
+	}
+	for i, group := range groups {
+		// the default class takes the traffic without the filter
+		if group.isDefault() {
+			continue
+		}
+		for _, rule := range buildRateGroupRules(group) {
+			args = append(args, fmt.Sprintf("filter add dev %s parent 1: prio 2 protocol ip u32 %s flowid %s",
+				netInterface, rule, rateClassId(i)))
+		}
+	}
+	return strings.Join(args, ` && \
+			tc `)
+}
+
+// buildRateGroupRules returns the u32 matches of the group, one for each combination of the destination ip,
+// the local port and the remote port
+func buildRateGroupRules(group *rateGroup) []string {
+	rules := []string{""}
+	combine := func(matches []string) {
+		if len(matches) == 0 {
+			return
+		}
+		combined := make([]string, 0, len(rules)*len(matches))
+		for _, rule := range rules {
+			for _, match := range matches {
+				combined = append(combined, strings.TrimSpace(rule+" "+match))
+			}
+		}
+		rules = combined
+	}
+	ipMatches := make([]string, 0, len(group.destIps))
+	for _, ip := range group.destIps {
+		ipMatches = append(ipMatches, fmt.Sprintf("match ip dst %s", ip))
+	}
+	combine(ipMatches)
+	combine(portMatches("sport", group.localPortRanges))
+	combine(portMatches("dport", group.remotePortRanges))
+	return rules
+}
+
+func portMatches(field string, portRanges [][]int) []string {
+	matches := make([]string, 0)
+	for _, portRange := range portRanges {
+		for _, mask := range buildMaskForRange(portRange[0], portRange[1]) {
+			matches = append(matches, fmt.Sprintf("match ip %s %d %#x", field, mask[0], mask[1]))
+		}
+	}
+	return matches
+}
+
+// getRateGroups returns the group of the flags and the groups of the limit flag, at most one of them is
+// without the selectors
+func getRateGroups(flags map[string]string) ([]*rateGroup, *spec.Response) {
+	groups := make([]*rateGroup, 0)
+	if flags["rate"] != "" {
+		group, response := newRateGroup(flags, "")
+		if response != nil {
+			return nil, response
+		}
+		groups = append(groups, group)
+	} else if flags["ceil"] != "" || flags["destination-ip"] != "" || flags["local-port"] != "" || flags["remote-port"] != "" {
+		// the ceil and the selectors of the flags belong to the group of the rate flag
+		return nil, spec.ResponseFailWithFlags(spec.ParameterLess, "rate")
+	}
+	if limit := flags["limit"]; limit != "" {
+		for _, text := range strings.Split(limit, ";") {
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			fields := make(map[string]string)
+			for _, field := range strings.Fields(text) {
+				key, value, found := strings.Cut(field, "=")
+				switch key {
+				case "destination-ip", "local-port", "remote-port", "rate", "ceil":
+				default:
+					found = false
+				}
+				if !found || value == "" {
+					return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "limit", limit,
+						fmt.Sprintf("the field %s is illegal, it must be destination-ip, local-port, remote-port, rate or ceil with the value", field))
+				}
+				fields[key] = value
+			}
+			if fields["rate"] == "" {
+				return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "limit", limit,
+					fmt.Sprintf("the rate of the group %s is required", strings.TrimSpace(text)))
+			}
+			group, response := newRateGroup(fields, "limit")
+			if response != nil {
+				return nil, response
+			}
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) == 0 {
+		return nil, spec.ResponseFailWithFlags(spec.ParameterLess, "rate")
+	}
+	defaults := 0
+	for _, group := range groups {
+		if group.isDefault() {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "limit", flags["limit"],
+			"only one group without the destination ip and the ports is allowed, which limits the whole interface")
+	}
+	return groups, nil
+}
+
+// newRateGroup validates the fields of the group, the prefix is the flag of the group, such as limit, which is
+// added to the names of the illegal fields
+func newRateGroup(fields map[string]string, prefix string) (*rateGroup, *spec.Response) {
+	name := func(field string) string {
+		if prefix == "" {
+			return field
+		}
+		return prefix + " " + field
+	}
+	group := &rateGroup{rate: fields["rate"], ceil: fields["ceil"]}
+	rate, response := parseRate(name("rate"), group.rate)
+	if response != nil {
+		return nil, response
+	}
+	if group.ceil == "" {
+		group.ceil = group.rate
+	} else {
+		ceil, response := parseRate(name("ceil"), group.ceil)
+		if response != nil {
+			return nil, response
+		}
+		if ceil < rate {
+			return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, name("ceil"), group.ceil,
+				fmt.Sprintf("it must not be less than the rate %s", group.rate))
+		}
+	}
+	if value := fields["destination-ip"]; value != "" {
+		if group.destIps, response = validation.ValidateIPv4OrCIDRList(name("destination-ip"), value); response != nil {
+			return nil, response
+		}
+	}
+	if value := fields["local-port"]; value != "" {
+		if group.localPortRanges, response = validation.ValidatePortList(name("local-port"), value); response != nil {
+			return nil, response
+		}
+	}
+	if value := fields["remote-port"]; value != "" {
+		if group.remotePortRanges, response = validation.ValidatePortList(name("remote-port"), value); response != nil {
+			return nil, response
+		}
+	}
+	return group, nil
+}
+
+var ratePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)([kmgt]?)(bit|bps)$`)
+
+var rateMultiples = map[string]float64{"": 1, "k": 1e3, "m": 1e6, "g": 1e9, "t": 1e12}
+
+// parseRate returns the bits per second of the rate of tc, such as 5mbit, the bps unit is the bytes per second
+func parseRate(flagName, value string) (float64, *spec.Response) {
+	matches := ratePattern.FindStringSubmatch(strings.ToLower(value))
+	if matches == nil {
+		return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, flagName, value,
+			"it must be a positive number with the unit bit, kbit, mbit, gbit, tbit, bps, kbps, mbps, gbps or tbps")
+	}
+	number, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil || number <= 0 {
+		return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, flagName, value, "it must be a positive number")
+	}
+	bits := number * rateMultiples[matches[2]]
+	if matches[3] == "bps" {
+		bits *= 8
+	}
+	return bits, nil
+}
+
+func (re *NetworkRateExecutor) stop(ctx context.Context, uid, netInterface string) *spec.Response {
+	return destroyNet(ctx, re.channel, uid, netInterface)
+}
+
+func (re *NetworkRateExecutor) SetChannel(channel spec.Channel) {
+	re.channel = channel
+}
+
+// Status checks the htb qdisc on the interface
+func (re *NetworkRateExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	return qdiscStatus(ctx, re.channel, uid, model, state, "htb")
+}
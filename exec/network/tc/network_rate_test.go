@@ -0,0 +1,144 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func TestGetRateGroups(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+		want  []*rateGroup
+		code  int32
+	}{
+		{name: "whole interface", flags: map[string]string{"rate": "10mbit"},
+			want: []*rateGroup{{rate: "10mbit", ceil: "10mbit"}}},
+		{name: "destination", flags: map[string]string{"rate": "5mbit", "ceil": "8mbit", "destination-ip": "10.0.5.0/24"},
+			want: []*rateGroup{{destIps: []string{"10.0.5.0/24"}, rate: "5mbit", ceil: "8mbit"}}},
+		{name: "limit groups", flags: map[string]string{"rate": "100mbit",
+			"limit": "destination-ip=10.0.5.0/24 rate=5mbit; remote-port=443 rate=1mbit ceil=2mbit;"},
+			want: []*rateGroup{
+				{rate: "100mbit", ceil: "100mbit"},
+				{destIps: []string{"10.0.5.0/24"}, rate: "5mbit", ceil: "5mbit"},
+				{remotePortRanges: [][]int{{443, 443}}, rate: "1mbit", ceil: "2mbit"},
+			}},
+		{name: "without rate", flags: map[string]string{"destination-ip": "10.0.5.0/24"}, code: spec.ParameterLess.Code},
+		{name: "nothing", flags: map[string]string{}, code: spec.ParameterLess.Code},
+		{name: "illegal rate", flags: map[string]string{"rate": "5m"}, code: spec.ParameterIllegal.Code},
+		{name: "ceil less than rate", flags: map[string]string{"rate": "1mbps", "ceil": "4mbit"}, code: spec.ParameterIllegal.Code},
+		{name: "illegal field", flags: map[string]string{"limit": "dst=10.0.5.0/24 rate=5mbit"}, code: spec.ParameterIllegal.Code},
+		{name: "group without rate", flags: map[string]string{"limit": "destination-ip=10.0.5.0/24"}, code: spec.ParameterIllegal.Code},
+		{name: "two default groups", flags: map[string]string{"rate": "5mbit", "limit": "rate=1mbit"}, code: spec.ParameterIllegal.Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, response := getRateGroups(tt.flags)
+			if tt.code != 0 {
+				if response == nil || response.Code != tt.code {
+					t.Errorf("getRateGroups() response = %v, want code %d", response, tt.code)
+				}
+				return
+			}
+			if response != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRateGroups() = %+v, %v, want %+v", got, response, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkRateExecutor(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	t.Setenv("SSH_CONNECTION", "10.0.0.2 52314 10.0.0.1 22")
+	ctx := context.Background()
+	cl := exec.NewMockChannel().SetCommandAvailable("ss", false)
+	flags := map[string]string{"interface": "eth0", "limit": "destination-ip=10.0.5.0/24 remote-port=80,443 rate=5mbit;local-port=8080 rate=1mbit ceil=2mbit"}
+	if response := execTc(ctx, cl, &NetworkRateExecutor{}, "rate-1", "rate", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	lines := cl.CommandLines()
+	args := strings.Split(lines[len(lines)-1], " && \\\n\t\t\ttc ")
+	expected := []string{
+		"tc qdisc add dev eth0 root handle 1: htb default 0",
+		"class add dev eth0 parent 1: classid 1:10 htb rate 5mbit ceil 5mbit",
+		"class add dev eth0 parent 1: classid 1:11 htb rate 1mbit ceil 2mbit",
+		"filter add dev eth0 parent 1: prio 1 protocol ip u32 match ip dst 10.0.0.2/32 match ip src 10.0.0.1/32 match ip sport 22 0xffff match ip dport 52314 0xffff flowid 1:",
+		"filter add dev eth0 parent 1: prio 2 protocol ip u32 match ip dst 10.0.5.0/24 match ip dport 80 0xffff flowid 1:10",
+		"filter add dev eth0 parent 1: prio 2 protocol ip u32 match ip dst 10.0.5.0/24 match ip dport 443 0xffff flowid 1:10",
+		"filter add dev eth0 parent 1: prio 2 protocol ip u32 match ip sport 8080 0xffff flowid 1:11",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected the commands %v, got %v", expected, args)
+	}
+
+	// the classes and the filters are removed with the htb
+	cl.Reset()
+	if response := execTc(spec.SetDestroyFlag(ctx, "rate-1"), cl, &NetworkRateExecutor{}, "rate-1", "rate",
+		map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	if got := cl.CommandLines(); !reflect.DeepEqual(got, []string{"tc qdisc del dev eth0 root"}) {
+		t.Errorf("expected the htb is removed, got %v", got)
+	}
+}
+
+func TestNetworkRateExecutorRestoreRootQdisc(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().OnRun("tc", "^qdisc show dev eth0$",
+		spec.ReturnSuccess("qdisc tbf 8001: root refcnt 2 rate 1Mbit burst 32Kb lat 50ms\n"))
+	flags := map[string]string{"interface": "eth0", "rate": "10mbit", "force": "true", exec.NoProtectSSHKey: spec.True}
+	if response := execTc(ctx, cl, &NetworkRateExecutor{}, "rate-2", "rate", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if lines := cl.CommandLines(); lines[len(lines)-1] != "tc qdisc add dev eth0 root handle 1: htb default 10 && \\\n\t\t\t"+
+		"tc class add dev eth0 parent 1: classid 1:10 htb rate 10mbit ceil 10mbit" {
+		t.Errorf("expected the whole interface limited by the default class, got %v", lines)
+	}
+
+	// the tbf replaced by the force is added again
+	cl.Reset()
+	if response := execTc(spec.SetDestroyFlag(ctx, "rate-2"), cl, &NetworkRateExecutor{}, "rate-2", "rate",
+		map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	expected := []string{"tc qdisc del dev eth0 root", "tc qdisc add dev eth0 root handle 8001: tbf rate 1Mbit burst 32Kb lat 50ms"}
+	if got := cl.CommandLines(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected the commands %v, got %v", expected, got)
+	}
+}
+
+func TestParseRootQdisc(t *testing.T) {
+	for output, expected := range map[string]string{
+		"qdisc fq_codel 0: root refcnt 2 limit 10240p flows 1024\n": "",
+		"qdisc noqueue 0: dev lo root refcnt 2\n":                   "",
+		"qdisc htb 1: root refcnt 2 r2q 10 default 0x10 direct_packets_stat 3 direct_qlen 1000\nqdisc pfifo 8002: parent 1:10 limit 1000p\n": "qdisc add dev eth0 root handle 1: htb r2q 10 default 0x10 direct_qlen 1000",
+		"": "",
+	} {
+		if got := parseRootQdisc("eth0", output); got != expected {
+			t.Errorf("expected %q of %q, got %q", expected, output, got)
+		}
+	}
+}
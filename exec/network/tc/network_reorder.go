@@ -19,11 +19,14 @@ package tc
 import (
 	"context"
 	"fmt"
+	"math"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
 )
 
 type ReorderActionSpec struct {
@@ -98,39 +101,55 @@ func (ce *NetworkReorderExecutor) Exec(uid string, ctx context.Context, model *s
 		return response
 	}
 
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return ce.stop(ctx, uid, model.ActionFlags["interface"])
+	}
 	netInterface := model.ActionFlags["interface"]
 	if netInterface == "" {
 		log.Errorf(ctx, "interface is nil")
 		return spec.ResponseFailWithFlags(spec.ParameterLess, "interface")
 	}
-	if _, ok := spec.IsDestroy(ctx); ok {
-		return ce.stop(netInterface, ctx)
-	} else {
-		percent := model.ActionFlags["percent"]
-		if percent == "" {
-			log.Errorf(ctx, "percent i nil")
-			return spec.ResponseFailWithFlags(spec.ParameterLess, "percent")
-		}
-		gap := model.ActionFlags["gap"]
-		time := model.ActionFlags["time"]
-		if time == "" {
-			time = "10"
-		}
-		correlation := model.ActionFlags["correlation"]
-		if correlation == "" {
-			correlation = "0"
+	percent := model.ActionFlags["percent"]
+	if percent == "" {
+		log.Errorf(ctx, "percent i nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "percent")
+	}
+	gap := model.ActionFlags["gap"]
+	time := model.ActionFlags["time"]
+	if time == "" {
+		time = "10"
+	}
+	correlation := model.ActionFlags["correlation"]
+	if correlation == "" {
+		correlation = "0"
+	}
+	var response *spec.Response
+	if percent, response = validatePercent("percent", percent); response != nil {
+		return response
+	}
+	if correlation, response = validatePercent("correlation", correlation); response != nil {
+		return response
+	}
+	if gap != "" {
+		if _, response = validation.ValidateInt("gap", gap, 1, math.MaxInt); response != nil {
+			return response
 		}
-		localPort := model.ActionFlags["local-port"]
-		remotePort := model.ActionFlags["remote-port"]
-		excludePort := model.ActionFlags["exclude-port"]
-		destIp := model.ActionFlags["destination-ip"]
-		excludeIp := model.ActionFlags["exclude-ip"]
-		ignorePeerPort := model.ActionFlags["ignore-peer-port"] == "true"
-		protocol := model.ActionFlags["protocol"]
-		force := model.ActionFlags["force"] == "true"
+	}
+	if _, response = validation.ValidateInt("time", time, 0, math.MaxInt); response != nil {
+		return response
+	}
+	localPort := model.ActionFlags["local-port"]
+	remotePort := model.ActionFlags["remote-port"]
+	excludePort := model.ActionFlags["exclude-port"]
+	destIp := model.ActionFlags["destination-ip"]
+	excludeIp := model.ActionFlags["exclude-ip"]
+	ignorePeerPort := model.ActionFlags["ignore-peer-port"] == "true"
+	protocol := model.ActionFlags["protocol"]
+	force := model.ActionFlags["force"] == "true"
+	return claimInterface(ctx, ce.channel, uid, model, netInterface, force, func(ctx context.Context, netInterface string) *spec.Response {
 		return ce.start(netInterface, localPort, remotePort, excludePort, destIp, excludeIp, percent,
 			ignorePeerPort, gap, time, correlation, force, protocol, ctx)
-	}
+	})
 }
 
 func (ce *NetworkReorderExecutor) start(netInterface, localPort, remotePort, excludePort, destIp, excludeIp, percent string,
@@ -145,10 +164,15 @@ func (ce *NetworkReorderExecutor) start(netInterface, localPort, remotePort, exc
 	return startNet(ctx, netInterface, classRule, localPort, remotePort, excludePort, destIp, excludeIp, force, ignorePeerPort, protocol, ce.channel)
 }
 
-func (ce *NetworkReorderExecutor) stop(netInterface string, ctx context.Context) *spec.Response {
-	return stopNet(ctx, netInterface, ce.channel)
+func (ce *NetworkReorderExecutor) stop(ctx context.Context, uid, netInterface string) *spec.Response {
+	return destroyNet(ctx, ce.channel, uid, netInterface)
 }
 
 func (ce *NetworkReorderExecutor) SetChannel(channel spec.Channel) {
 	ce.channel = channel
 }
+
+// Status checks the netem qdisc on the interface
+func (ce *NetworkReorderExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	return tcStatus(ctx, ce.channel, uid, model, state)
+}
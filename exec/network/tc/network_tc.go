@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math"
 	"math/bits"
 	"os"
 	"sort"
@@ -16,6 +15,8 @@ import (
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
 )
 
 // TcNetworkBin for network delay, loss, duplicate, reorder and corrupt experiments
@@ -44,15 +45,16 @@ var commFlags = []spec.ExpFlagSpec{
 		NoArgs: true,
 	},
 	&spec.ExpFlag{
-		Name:                  "interface",
-		Desc:                  "Network interface, for example, eth0",
-		Required:              true,
-		RequiredWhenDestroyed: true,
+		Name:     "interface",
+		Desc:     "Network interface, for example, eth0. It's only required by the destroy of the experiment without the record",
+		Required: true,
 	},
 	&spec.ExpFlag{
 		Name: "exclude-ip",
 		Desc: "Exclude ips. Support for using mask to specify the ip range such as 92.168.1.0/24 or comma separated multiple ips, for example 10.0.0.1,11.0.0.1",
 	},
+	container.VethFlags[0],
+	container.VethFlags[1],
 	&spec.ExpFlag{
 		Name: "protocol",
 		Desc: "specify protocol for example tcp udp icmp ",
@@ -62,10 +64,55 @@ var commFlags = []spec.ExpFlagSpec{
 		Desc:   "Forcibly overwrites the original rules",
 		NoArgs: true,
 	},
+	&spec.ExpFlag{
+		Name:   exec.NoProtectSSHKey,
+		Desc:   "Include the ssh sessions in the experiment, by default the established sessions of the local port 22 and the one running the experiment are excluded",
+		NoArgs: true,
+	},
+	&spec.ExpFlag{
+		Name:   exec.AffectLoopbackKey,
+		Desc:   "Allow the experiment on the lo interface or the loopback destination ip, the port of the blade server and the ssh session are excluded anyway",
+		NoArgs: true,
+	},
+}
+
+// icmpFlag is shared by the actions which support selecting the icmp packets only
+var icmpFlag = &spec.ExpFlag{
+	Name:   "icmp",
+	Desc:   "Only match the icmp packets, for example the ping traffic, the tcp and udp traffic is not affected. It cannot be used with the port flags",
+	NoArgs: true,
 }
 
 const delimiter = ","
 
+// selectFlags returns the flags of commFlags with the names, in the order of commFlags
+func selectFlags(names ...string) []spec.ExpFlagSpec {
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = true
+	}
+	flags := make([]spec.ExpFlagSpec, 0, len(names))
+	for _, flag := range commFlags {
+		if selected[flag.FlagName()] {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
+
+// getProtocol returns the protocol selected by the protocol and icmp flags
+func getProtocol(flags map[string]string) (string, *spec.Response) {
+	protocol := flags["protocol"]
+	if flags[icmpFlag.Name] != "true" {
+		return protocol, nil
+	}
+	if protocol != "" && protocol != "icmp" {
+		return "", spec.ResponseFailWithFlags(spec.ParameterIllegal, "protocol", protocol,
+			"only the icmp protocol can be specified with the --icmp flag")
+	}
+	return "icmp", nil
+}
+
 func startNet(ctx context.Context, netInterface, classRule, localPort, remotePort, excludePort, destIp, excludeIp string, force, ignorePeerPorts bool, protocol string, cl spec.Channel) *spec.Response {
 	if protocol != "" {
 		switch protocol {
@@ -79,33 +126,66 @@ func startNet(ctx context.Context, netInterface, classRule, localPort, remotePor
 			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "protocol", protocol, "unsupport protocol")
 		}
 	}
+	// the icmp packets have no port, the u32 port matches would hit the type and code fields
+	if protocol == "1" {
+		for _, portFlag := range []struct {
+			name  string
+			value string
+		}{{"local-port", localPort}, {"remote-port", remotePort}, {"exclude-port", excludePort}} {
+			if portFlag.value != "" {
+				return spec.ResponseFailWithFlags(spec.ParameterIllegal, portFlag.name, portFlag.value,
+					"the port flags cannot be used with the icmp protocol")
+			}
+		}
+	}
 	var localPortRanges, remotePortRanges, excludePortRanges [][]int
-	var err error
+	var response *spec.Response
 	if localPort != "" {
-		localPortRanges, err = parseIntegerListToPortRanges("local-port", localPort)
-		if err != nil {
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "local-port", localPort, err)
+		if localPortRanges, response = validation.ValidatePortList("local-port", localPort); response != nil {
+			return response
 		}
 	}
 	if remotePort != "" {
-		remotePortRanges, err = parseIntegerListToPortRanges("remote-port", remotePort)
-		if err != nil {
-			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "remote-port", remotePort, err)
+		if remotePortRanges, response = validation.ValidatePortList("remote-port", remotePort); response != nil {
+			return response
+		}
+	}
+	// the u32 filters only match the ipv4 header
+	for _, ipFlag := range []struct {
+		name  string
+		value *string
+	}{{"destination-ip", &destIp}, {"exclude-ip", &excludeIp}} {
+		if *ipFlag.value == "" {
+			continue
+		}
+		ips, response := validation.ValidateIPv4OrCIDRList(ipFlag.name, *ipFlag.value)
+		if response != nil {
+			return response
 		}
+		*ipFlag.value = strings.Join(ips, delimiter)
 	}
 	if excludePort != "" {
-		excludePortRanges, err = getExcludePortRanges(ctx, excludePort, ignorePeerPorts, cl)
+		if excludePortRanges, response = validation.ValidatePortList("exclude-port", excludePort); response != nil {
+			return response
+		}
+		var err error
+		excludePortRanges, err = getExcludePortRanges(ctx, excludePortRanges, ignorePeerPorts, cl)
 		if err != nil {
 			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "exclude-port", excludePort, err)
 		}
 	}
 
+	// the blade server and the ssh session running the experiment are kept on the loopback
+	if netInterface == exec.LoopbackInterface || exec.LoopbackIP(destIp) != "" {
+		excludePortRanges = addPortsToRanges(excludePortRanges, exec.LoopbackExcludedPorts())
+	}
+
 	// check device txqueuelen size, if the size is zero, then set the value to 1000
-	response := preHandleTxqueue(ctx, netInterface, cl)
+	response = preHandleTxqueue(ctx, netInterface, cl)
 	if !response.Success {
 		return response
 	}
-	ips, err := readServerIps()
+	ips, _ := readServerIps()
 	if len(ips) > 0 {
 		channelIps := strings.Join(ips, ",")
 		if excludeIp != "" {
@@ -117,17 +197,32 @@ func startNet(ctx context.Context, netInterface, classRule, localPort, remotePor
 	if force {
 		stopNet(ctx, netInterface, cl)
 	}
+	sessions, _ := ctx.Value(sshSessionsKey{}).([]exec.SSHSession)
 	// Only interface flag
-	if localPort == "" && remotePort == "" && excludePort == "" && destIp == "" && excludeIp == "" && protocol == "" {
+	if localPort == "" && remotePort == "" && len(excludePortRanges) == 0 && destIp == "" && excludeIp == "" && protocol == "" &&
+		len(sessions) == 0 {
 		return cl.Run(ctx, "tc", fmt.Sprintf(`qdisc add dev %s root %s`, netInterface, classRule))
 	}
 
 	response = addQdiscForDL(cl, ctx, netInterface)
 
+	excludeOnly := localPort == "" && remotePort == "" && destIp == "" && protocol == ""
+	// the ssh sessions are moved to the band without netem ahead of the netem qdisc
+	if len(sessions) > 0 {
+		flowid := "1:3"
+		if excludeOnly {
+			flowid = "1:4"
+		}
+		if response := cl.Run(ctx, "tc", buildSSHSessionFilters(netInterface, sessions, flowid)); !response.Success {
+			stopNet(ctx, netInterface, cl)
+			return response
+		}
+	}
+
 	// only contains excludePort or excludeIP
-	if localPort == "" && remotePort == "" && destIp == "" && protocol == "" {
+	if excludeOnly {
 		// Add class rule to 1,2,3 band, exclude port and exclude ip are added to 4 band
-		args := buildNetemToDefaultBandsArgs(netInterface, classRule)
+		args := appendLeafQueues(ctx, buildNetemToDefaultBandsArgs(netInterface, classRule), netInterface, "40:1", "40:2", "40:3")
 		excludeFilters := buildExcludeFilterToNewBand(netInterface, excludePortRanges, excludeIp)
 		response := cl.Run(ctx, "tc", args+excludeFilters)
 		if !response.Success {
@@ -142,12 +237,17 @@ func startNet(ctx context.Context, netInterface, classRule, localPort, remotePor
 		excludePortRanges, excludeIpRules, protocol)
 }
 
-func getExcludePortRanges(ctx context.Context, excludePort string, ignorePeerPorts bool, cl spec.Channel) ([][]int, error) {
-	excludePortRanges, err := parseIntegerListToPortRanges("exclude-port", excludePort)
-	if err != nil {
-		return [][]int{}, err
+// validatePercent returns the netem percent without the % suffix, the decimal such as 0.5 is supported
+func validatePercent(flagName, value string) (string, *spec.Response) {
+	percent, response := validation.ValidateDecimalPercent(flagName, value)
+	if response != nil {
+		return "", response
 	}
-	// add peer ports
+	return strconv.FormatFloat(percent, 'f', -1, 64), nil
+}
+
+// getExcludePortRanges adds the peer ports of the excluded ports
+func getExcludePortRanges(ctx context.Context, excludePortRanges [][]int, ignorePeerPorts bool, cl spec.Channel) ([][]int, error) {
 	portSet := make(map[int]interface{}, 0)
 	for _, exexcludePortRange := range excludePortRanges {
 		startPort := exexcludePortRange[0]
@@ -199,6 +299,22 @@ func buildExcludeFilterToNewBand(netInterface string, excludePortRanges [][]int,
 	return args
 }
 
+// buildSSHSessionFilters returns the filters which move the outgoing packets of the ssh sessions to the band
+func buildSSHSessionFilters(netInterface string, sessions []exec.SSHSession, flowid string) string {
+	filters := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		rule := fmt.Sprintf("match ip dst %s/32", session.PeerIP)
+		if session.LocalIP != "" {
+			rule = fmt.Sprintf("%s match ip src %s/32", rule, session.LocalIP)
+		}
+		filters = append(filters, fmt.Sprintf(
+			`filter add dev %s parent 1: prio 1 protocol ip u32 %s match ip sport %d 0xffff match ip dport %d 0xffff flowid %s`,
+			netInterface, rule, session.LocalPort, session.PeerPort, flowid))
+	}
+	return strings.Join(filters, ` && \
+			tc `)
+}
+
 func buildNetemToDefaultBandsArgs(netInterface, classRule string) string {
 	args := fmt.Sprintf(
 		`qdisc add dev %s parent 1:1 %s && \
@@ -220,7 +336,7 @@ func preHandleTxqueue(ctx context.Context, netInterface string, cl spec.Channel)
 	isExist := exec.CheckFilepathExists(ctx, cl, txFile)
 	if isExist {
 		// check the value
-		response := cl.Run(ctx, "head", fmt.Sprintf("-1 %s", txFile))
+		response := exec.RunReadOnly(ctx, cl, "head", fmt.Sprintf("-1 %s", txFile))
 		if response.Success {
 			txlen := strings.TrimSpace(response.Result.(string))
 			len, err := strconv.Atoi(txlen)
@@ -284,6 +400,7 @@ func executeTargetPortAndIpWithExclude(ctx context.Context, channel spec.Channel
 	netInterface, classRule string, localPortRanges, remotePortRanges [][]int, destIpRules []string, excludePorts [][]int, excludeIpRules []string, protocol string,
 ) *spec.Response {
 	args := fmt.Sprintf(`qdisc add dev %s parent 1:4 handle 40: %s`, netInterface, classRule)
+	args = appendLeafQueues(ctx, args, netInterface, "1:1", "1:2", "1:3")
 	args = buildTargetFilterPortAndIp(localPortRanges, remotePortRanges, destIpRules, excludePorts, excludeIpRules, args, netInterface, protocol)
 	response := channel.Run(ctx, "tc", args)
 	if !response.Success {
@@ -394,7 +511,7 @@ func stopNet(ctx context.Context, netInterface string, cl spec.Channel) *spec.Re
 	if os.Getuid() != 0 {
 		return spec.ReturnFail(spec.Forbidden, fmt.Sprintf("tc no permission"))
 	}
-	response := cl.Run(ctx, "tc", fmt.Sprintf(`filter show dev %s parent 1: prio 4`, netInterface))
+	response := exec.RunReadOnly(ctx, cl, "tc", fmt.Sprintf(`filter show dev %s parent 1: prio 4`, netInterface))
 	if response.Success && response.Result != "" {
 		response = cl.Run(ctx, "tc", fmt.Sprintf(`filter del dev %s parent 1: prio 4`, netInterface))
 		if !response.Success {
@@ -404,12 +521,225 @@ func stopNet(ctx context.Context, netInterface string, cl spec.Channel) *spec.Re
 	return cl.Run(ctx, "tc", fmt.Sprintf(`qdisc del dev %s root`, netInterface))
 }
 
+// sshSessionsKey is the context key of the ssh sessions which startNet keeps out of the experiment
+type sshSessionsKey struct{}
+
+// claimInterface records the experiment on the interface and starts it, the record is released if the start
+// fails. The force flag replaces the qdisc of the interface instead of stacking on it, so the conflict check
+// is skipped. The commands removing the qdisc and the filters are recorded after the start, see destroyNet.
+// The ssh sessions to protect and the detected interface are passed to the start by the context. The container
+// flags shape the host side veth of the container interface, which is recorded as the interface of the experiment.
+// The root qdisc always takes the handle 1: of the interface, the qdiscs of the other interfaces such as the
+// vlan on it or the bond over it are separate, so the handle never collides.
+func claimInterface(ctx context.Context, cl spec.Channel, uid string, model *spec.ExpModel, netInterface string,
+	force bool, start func(ctx context.Context, netInterface string) *spec.Response) *spec.Response {
+	if response := exec.CheckLoopback(ctx, model.ActionFlags, netInterface, "destination-ip"); response != nil {
+		return response
+	}
+	veth, response := container.ResolveHostVeth(ctx, cl, model.ActionFlags, netInterface)
+	if response != nil {
+		return response
+	}
+	if veth != "" {
+		flags := make(map[string]string, len(model.ActionFlags))
+		for key, value := range model.ActionFlags {
+			flags[key] = value
+		}
+		flags["interface"] = veth
+		netInterface = veth
+		peerModel := *model
+		peerModel.ActionFlags = flags
+		model = &peerModel
+	}
+	iface, response := checkInterface(ctx, cl, netInterface)
+	if response != nil {
+		return response
+	}
+	ctx = context.WithValue(ctx, tcInterfaceKey{}, iface)
+	if force {
+		ctx = exec.WithAllowOverlap(ctx)
+	}
+	state, response := exec.ClaimResources(ctx, cl, uid, model.Target, model.ActionName, model.ActionFlags,
+		exec.InterfaceResource(netInterface))
+	if response != nil {
+		return response
+	}
+	// the root qdisc replaced by the force is added again after the qdisc of the experiment is removed
+	restore := ""
+	if force {
+		var err error
+		if restore, err = snapshotRootQdisc(ctx, cl, netInterface); err != nil {
+			log.Warnf(ctx, "snapshot the root qdisc of %s failed, it's not restored by the destroy, %v", netInterface, err)
+		} else if restore != "" {
+			if err := state.AddUndo("tc", restore); err != nil {
+				exec.ReleaseResources(ctx, uid)
+				return exec.Fail(exec.StateRecordFailed, "tc", "AddUndo", fmt.Sprintf("record the root qdisc of %s failed, %v", netInterface, err))
+			}
+		}
+	}
+	if model.ActionFlags[exec.NoProtectSSHKey] != spec.True {
+		ctx = context.WithValue(ctx, sshSessionsKey{}, exec.ProtectedSSHSessions(ctx, cl))
+	}
+	response = start(ctx, netInterface)
+	if !response.Success {
+		if restore != "" {
+			if restored := cl.Run(ctx, "tc", restore); !restored.Success {
+				log.Warnf(ctx, "restore the root qdisc of %s failed, %s", netInterface, restored.Err)
+			}
+		}
+		exec.ReleaseResources(ctx, uid)
+		return response
+	}
+	for _, args := range tcUndoArgs(netInterface, model.ActionName, model.ActionFlags) {
+		if err := state.AddUndo("tc", args); err != nil {
+			log.Errorf(ctx, "record the qdisc of %s failed, %v", netInterface, err)
+			stopNet(ctx, netInterface, cl)
+			exec.ReleaseResources(ctx, uid)
+			return exec.Fail(exec.StateRecordFailed, "tc", "AddUndo", fmt.Sprintf("record the qdisc of %s failed, %v", netInterface, err))
+		}
+	}
+	return response
+}
+
+// tcUndoArgs returns the tc commands which remove the qdisc and the filters added by startNet, in the order of
+// the changes. The filters of the prio 4 are only added for the local port, the remote port, the destination ip
+// or the protocol, the other filters are removed with the root qdisc, so are the classes and the filters of
+// the htb added by the rate action.
+func tcUndoArgs(netInterface, action string, flags map[string]string) []string {
+	args := []string{fmt.Sprintf("qdisc del dev %s root", netInterface)}
+	if action == rateAction {
+		return args
+	}
+	if flags["local-port"] != "" || flags["remote-port"] != "" || flags["destination-ip"] != "" ||
+		flags["protocol"] != "" || flags[icmpFlag.Name] == "true" {
+		args = append(args, fmt.Sprintf("filter del dev %s parent 1: prio 4", netInterface))
+	}
+	return args
+}
+
+// tcDestroyResult is the response of the destroy without the record, the qdisc of the interface passed to
+// the destroy is removed, which may not be the one changed by the experiment. It's also the response of the
+// destroy whose recorded interface is gone.
+type tcDestroyResult struct {
+	Uid       string `json:"uid"`
+	Interface string `json:"interface"`
+	Warning   string `json:"warning"`
+}
+
+// destroyNet removes the qdisc and the filters recorded by claimInterface. The record of the experiment is used
+// first, so the tc executors dispatch the destroy here without requiring its flags, even the interface flag.
+// The experiments created before the commands are recorded fall back to the recorded interface, and the
+// experiments without the record fall back to the interface flag.
+func destroyNet(ctx context.Context, cl spec.Channel, uid, netInterface string) *spec.Response {
+	state, err := exec.LoadState(uid)
+	if err == nil && (state.Destroyed || len(state.Undo) > 0) {
+		response, _ := exec.DestroyByState(ctx, cl, uid)
+		// the qdisc is removed with the interface, such as the host side veth of the restarted container
+		if recorded := state.Flags["interface"]; !response.Success && recorded != "" && !container.LinkExists(ctx, cl, recorded) {
+			warning := fmt.Sprintf("the target interface %s is gone, so is the qdisc of the experiment", recorded)
+			log.Warnf(ctx, "%s", warning)
+			exec.ReleaseResources(ctx, uid)
+			return spec.ReturnSuccess(tcDestroyResult{Uid: uid, Interface: recorded, Warning: warning})
+		}
+		return response
+	}
+	if err == nil && state.Flags["interface"] != "" {
+		netInterface = state.Flags["interface"]
+	}
+	if netInterface == "" {
+		log.Errorf(ctx, "interface is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "interface")
+	}
+	response := stopNet(ctx, netInterface, cl)
+	if !response.Success {
+		return response
+	}
+	if err == nil {
+		exec.ReleaseResources(ctx, uid)
+		return response
+	}
+	warning := fmt.Sprintf("the record of the experiment %s is not found, the qdisc of %s is removed by the flags", uid, netInterface)
+	log.Warnf(ctx, "%s", warning)
+	return spec.ReturnSuccess(tcDestroyResult{Uid: uid, Interface: netInterface, Warning: warning})
+}
+
+// snapshotRootQdisc returns the tc arguments which add the root qdisc of the interface again, it's empty if the
+// root qdisc is the default one attached by the kernel, whose handle is 0:. The parameters shown by tc are
+// reused except the statistics, and the child qdiscs and the classes are not restored.
+func snapshotRootQdisc(ctx context.Context, cl spec.Channel, netInterface string) (string, error) {
+	response := exec.RunReadOnly(ctx, cl, "tc", fmt.Sprintf("qdisc show dev %s", netInterface))
+	if !response.Success {
+		return "", errors.New(response.Err)
+	}
+	return parseRootQdisc(netInterface, fmt.Sprint(response.Result)), nil
+}
+
+// qdiscShowOnlyParams are the fields of tc qdisc show which are not the parameters of tc qdisc add, the value
+// follows each of them
+var qdiscShowOnlyParams = map[string]bool{"refcnt": true, "direct_packets_stat": true, "ver": true}
+
+// parseRootQdisc parses the root qdisc of tc qdisc show, such as
+// qdisc tbf 8001: root refcnt 2 rate 1Mbit burst 32Kb lat 50ms
+func parseRootQdisc(netInterface, output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "qdisc" || fields[3] != "root" {
+			continue
+		}
+		if fields[2] == "0:" {
+			return ""
+		}
+		params := make([]string, 0, len(fields)-4)
+		for i := 4; i < len(fields); i++ {
+			if qdiscShowOnlyParams[fields[i]] {
+				i++
+				continue
+			}
+			params = append(params, fields[i])
+		}
+		args := fmt.Sprintf("qdisc add dev %s root handle %s %s", netInterface, fields[2], fields[1])
+		if len(params) > 0 {
+			args = fmt.Sprintf("%s %s", args, strings.Join(params, " "))
+		}
+		return args
+	}
+	return ""
+}
+
+// tcStatus checks the netem qdisc on the interface of the experiment
+func tcStatus(ctx context.Context, cl spec.Channel, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	return qdiscStatus(ctx, cl, uid, model, state, "netem")
+}
+
+// qdiscStatus checks the qdisc of the kind on the interface of the experiment
+func qdiscStatus(ctx context.Context, cl spec.Channel, uid string, model *spec.ExpModel, state *exec.ExperimentState,
+	kind string) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	if state != nil && state.Destroyed {
+		report.Add(exec.Artifact{Kind: "record", Name: exec.GetStateFile(uid), Detail: "the experiment is destroyed"})
+		return report
+	}
+	netInterface := model.ActionFlags["interface"]
+	if state != nil && state.Flags["interface"] != "" {
+		netInterface = state.Flags["interface"]
+	}
+	artifact := exec.Artifact{Kind: "qdisc", Name: netInterface}
+	response := exec.RunReadOnlyArgv(ctx, cl, "tc", "qdisc", "show", "dev", netInterface)
+	if !response.Success {
+		artifact.Detail = response.Err
+	} else if artifact.Present = strings.Contains(fmt.Sprint(response.Result), kind); !artifact.Present {
+		artifact.Detail = fmt.Sprintf("the %s qdisc is not found", kind)
+	}
+	report.Add(artifact)
+	return report
+}
+
 // getPeerPorts returns all ports communicating with the port
 func getPeerPorts(ctx context.Context, port string, cl spec.Channel) ([]int, error) {
 	if !cl.IsCommandAvailable(ctx, "ss") {
 		return nil, errors.New(spec.CommandSsNotFound.Msg)
 	}
-	response := cl.Run(ctx, "ss", fmt.Sprintf("-n sport = %s or dport = %s", port, port))
+	response := exec.RunReadOnly(ctx, cl, "ss", fmt.Sprintf("-n sport = %s or dport = %s", port, port))
 	if !response.Success {
 		return nil, errors.New(response.Err)
 	}
@@ -453,45 +783,6 @@ func getPeerPorts(ctx context.Context, port string, cl spec.Channel) ([]int, err
 	return mappingPorts, nil
 }
 
-func parseIntegerListToPortRanges(flagName string, flagValue string) ([][]int, error) {
-	dedup := make(map[int]interface{})
-	commaParts := strings.Split(flagValue, ",")
-	for _, part := range commaParts {
-		value := strings.TrimSpace(part)
-		if value == "" {
-			continue
-		}
-		if !strings.Contains(value, "-") {
-			intValue, err := strconv.Atoi(value)
-			if err != nil {
-				return nil, errors.New(spec.ParameterIllegal.Sprintf(flagName, flagValue, err))
-			}
-			dedup[intValue] = struct{}{}
-			continue
-		}
-		ranges := strings.Split(value, "-")
-		if len(ranges) != 2 {
-			return nil, errors.New(spec.ParameterIllegal.Sprintf(flagName, flagValue,
-				"Does not conform to the data format, a connector is required"))
-		}
-		startIndex, err := strconv.Atoi(strings.TrimSpace(ranges[0]))
-		if err != nil {
-			return nil, errors.New(spec.ParameterIllegal.Sprintf(flagName, flagValue, err))
-		}
-		endIndex, err := strconv.Atoi(strings.TrimSpace(ranges[1]))
-		if err != nil {
-			return nil, errors.New(spec.ParameterIllegal.Sprintf(flagName, flagValue, err))
-		}
-		if startIndex <= 0 || startIndex > math.MaxUint16 || endIndex <= 0 || endIndex >= math.MaxUint16 || endIndex < startIndex {
-			return nil, errors.New(spec.ParameterIllegal.Sprintf(flagName, flagValue, "Illegal port range"))
-		}
-		for i := startIndex; i <= endIndex; i++ {
-			dedup[i] = struct{}{}
-		}
-	}
-	return portSetToPortRanges(dedup), nil
-}
-
 func portSetToPortRanges(portSet map[int]interface{}) [][]int {
 	list := make([]int, 0)
 	for k := range portSet {
@@ -512,6 +803,20 @@ func portSetToPortRanges(portSet map[int]interface{}) [][]int {
 	return ranges
 }
 
+// addPortsToRanges returns the sorted ranges which cover the ranges and the ports
+func addPortsToRanges(ranges [][]int, ports []int) [][]int {
+	portSet := make(map[int]interface{}, 0)
+	for _, portRange := range ranges {
+		for p := portRange[0]; p <= portRange[1]; p++ {
+			portSet[p] = struct{}{}
+		}
+	}
+	for _, p := range ports {
+		portSet[p] = struct{}{}
+	}
+	return portSetToPortRanges(portSet)
+}
+
 func buildMaskForRange(start, end int) [][]uint16 {
 	cur := start
 	masks := make([][]uint16, 0)
@@ -0,0 +1,137 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// tcInterface is the interface detected by ip -d link show, the kind is empty for the physical interface
+type tcInterface struct {
+	Name string
+	// Kind is the link type, such as vlan, veth, bond or bond_slave
+	Kind string
+	// Master is the bond or the bridge which the interface is enslaved to
+	Master string
+	// TxQueueLen is zero for the virtual interfaces with the noqueue qdisc, such as vlan and veth, and -1 if
+	// it's unknown
+	TxQueueLen int
+}
+
+// linkKinds are the kinds of the interfaces shown by ip -d link show, the bond_slave one is shown besides the
+// kind of the slave
+var linkKinds = map[string]bool{
+	"vlan": true, "veth": true, "bond": true, "bond_slave": true, "bridge": true, "bridge_slave": true,
+	"macvlan": true, "ipvlan": true, "vxlan": true, "tun": true, "dummy": true, "team": true,
+}
+
+// tcInterfaceKey is the context key of the interface detected by claimInterface
+type tcInterfaceKey struct{}
+
+// leafQueueLimit is the limit of the qdiscs added to the bands and the classes of the interface without the
+// tx queue, whose default fifo only queues one packet then
+const leafQueueLimit = 1000
+
+// inspectInterface detects the interface by ip, the failure is returned if it does not exist. The interface
+// without ip is regarded as the physical one with the unknown tx queue.
+func inspectInterface(ctx context.Context, cl spec.Channel, netInterface string) (*tcInterface, *spec.Response) {
+	iface := &tcInterface{Name: netInterface, TxQueueLen: -1}
+	if !cl.IsCommandAvailable(ctx, "ip") {
+		return iface, nil
+	}
+	response := exec.RunReadOnlyArgv(ctx, cl, "ip", "-d", "link", "show", "dev", netInterface)
+	if !response.Success {
+		return nil, exec.Fail(exec.TargetNotFound, "tc", "inspect",
+			fmt.Sprintf("the interface %s is not found, %s", netInterface, response.Err))
+	}
+	parseLinkDetail(iface, fmt.Sprint(response.Result))
+	return iface, nil
+}
+
+// parseLinkDetail parses the output of ip -d link show, such as
+//
+//	4: eth0.100@eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue master bond0 state UP mode DEFAULT
+//	    link/ether 52:54:00:12:34:56 brd ff:ff:ff:ff:ff:ff promiscuity 0
+//	    vlan protocol 802.1Q id 100 <REORDER_HDR> addrgenmode eui64
+func parseLinkDetail(iface *tcInterface, output string) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return
+	}
+	fields := strings.Fields(lines[0])
+	iface.TxQueueLen = 0
+	for i := 0; i+1 < len(fields); i++ {
+		switch fields[i] {
+		case "master":
+			iface.Master = fields[i+1]
+		case "qlen":
+			if qlen, err := strconv.Atoi(fields[i+1]); err == nil {
+				iface.TxQueueLen = qlen
+			}
+		}
+	}
+	for _, line := range lines[1:] {
+		if fields := strings.Fields(line); len(fields) > 0 && linkKinds[fields[0]] {
+			// the slave shows its own kind first, such as veth, then bond_slave
+			if iface.Kind == "" || fields[0] == "bond_slave" {
+				iface.Kind = fields[0]
+			}
+		}
+	}
+}
+
+// checkInterface inspects the interface to shape, and warns the slave of the bond, whose qdisc only affects the
+// packets which the bond transmits by the slave
+func checkInterface(ctx context.Context, cl spec.Channel, netInterface string) (*tcInterface, *spec.Response) {
+	iface, response := inspectInterface(ctx, cl, netInterface)
+	if response != nil {
+		return nil, response
+	}
+	if iface.Kind == "bond_slave" {
+		log.Warnf(ctx, "the interface %s is a slave of the bond %s, only the packets sent by the slave are affected, shape %s instead to affect all of them",
+			netInterface, iface.Master, iface.Master)
+	}
+	return iface, nil
+}
+
+// needsLeafQueue returns true if the default fifo of the bands and the classes only queues one packet, which
+// is the case of the interface without the tx queue
+func (i *tcInterface) needsLeafQueue() bool {
+	return i != nil && i.TxQueueLen == 0
+}
+
+// appendLeafQueues appends the pfifo qdiscs to the parents if the interface detected by claimInterface has no
+// tx queue, the args are returned as they are otherwise
+func appendLeafQueues(ctx context.Context, args, netInterface string, parents ...string) string {
+	iface, _ := ctx.Value(tcInterfaceKey{}).(*tcInterface)
+	if !iface.needsLeafQueue() {
+		return args
+	}
+	for _, parent := range parents {
+		args = fmt.Sprintf(`%s && \
+			tc qdisc add dev %s parent %s pfifo limit %d`, args, netInterface, parent, leafQueueLimit)
+	}
+	return args
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func TestParseLinkDetail(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected tcInterface
+	}{
+		{
+			name: "physical",
+			output: `2: eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc mq state UP mode DEFAULT group default qlen 1000
+    link/ether 52:54:00:12:34:56 brd ff:ff:ff:ff:ff:ff promiscuity 0 minmtu 68 maxmtu 65535 addrgenmode eui64`,
+			expected: tcInterface{Name: "eth0", TxQueueLen: 1000},
+		},
+		{
+			name: "vlan",
+			output: `4: eth0.100@eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue state UP mode DEFAULT group default
+    link/ether 52:54:00:12:34:56 brd ff:ff:ff:ff:ff:ff promiscuity 0
+    vlan protocol 802.1Q id 100 <REORDER_HDR> addrgenmode eui64`,
+			expected: tcInterface{Name: "eth0", Kind: "vlan"},
+		},
+		{
+			name: "bond slave",
+			output: `3: eth1: <BROADCAST,MULTICAST,SLAVE,UP,LOWER_UP> mtu 1500 qdisc fq_codel master bond0 state UP mode DEFAULT group default qlen 1000
+    link/ether 52:54:00:12:34:57 brd ff:ff:ff:ff:ff:ff promiscuity 0
+    bond_slave state ACTIVE mii_status UP link_failure_count 0 perm_hwaddr 52:54:00:12:34:57 queue_id 0`,
+			expected: tcInterface{Name: "eth0", Kind: "bond_slave", Master: "bond0", TxQueueLen: 1000},
+		},
+		{
+			name: "veth on the bridge",
+			output: `7: veth1a2b@if2: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue master docker0 state UP mode DEFAULT group default
+    link/ether 9a:3c:11:22:33:44 brd ff:ff:ff:ff:ff:ff link-netnsid 0 promiscuity 1
+    veth
+    bridge_slave state forwarding priority 32 cost 2`,
+			expected: tcInterface{Name: "eth0", Kind: "veth", Master: "docker0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iface := &tcInterface{Name: "eth0", TxQueueLen: -1}
+			parseLinkDetail(iface, tt.output)
+			if *iface != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, *iface)
+			}
+		})
+	}
+}
+
+func TestInspectInterfaceNotFound(t *testing.T) {
+	cl := exec.NewMockChannel().OnRun("ip", "link show dev eth9",
+		spec.ReturnFail(spec.OsCmdExecFailed, `Device "eth9" does not exist.`))
+	_, response := inspectInterface(context.Background(), cl, "eth9")
+	if failure, ok := exec.GetFailure(response); !ok || failure.Kind != exec.TargetNotFound.Name {
+		t.Errorf("expected the interface is not found, got %+v", response)
+	}
+}
+
+func TestStartNetWithoutTxQueue(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().OnRun("ip", "link show dev eth0.100",
+		spec.ReturnSuccess("4: eth0.100@eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue state UP\n    vlan protocol 802.1Q id 100"))
+	flags := map[string]string{"interface": "eth0.100", "remote-port": "8080", "time": "100", exec.NoProtectSSHKey: spec.True}
+	if response := execTc(ctx, cl, &NetworkDelayExecutor{}, "tc-iface-1", "delay", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	lines := cl.CommandLines()
+	for _, parent := range []string{"1:1", "1:2", "1:3"} {
+		leaf := "tc qdisc add dev eth0.100 parent " + parent + " pfifo limit 1000"
+		if !strings.Contains(lines[len(lines)-1], leaf) {
+			t.Errorf("expected the leaf queue %q, got %v", leaf, lines)
+		}
+	}
+}
+
+func TestNetworkRateExecutorWithPeer(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().
+		OnRun("nsenter", "link show dev eth0", spec.ReturnSuccess("2: eth0@if7: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500")).
+		OnRun("ip", "^-o link show$", spec.ReturnSuccess("7: veth1a2b@if2: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500")).
+		OnRun("ip", "link show dev veth1a2b", spec.ReturnSuccess("7: veth1a2b@if2: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue\n    veth"))
+	flags := map[string]string{"interface": "eth0", "container-pid": "1234", "rate": "10mbit", exec.NoProtectSSHKey: spec.True}
+	if response := execTc(ctx, cl, &NetworkRateExecutor{}, "rate-peer", "rate", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	expected := "tc qdisc add dev veth1a2b root handle 1: htb default 10 direct_qlen 1000 && \\\n\t\t\t" +
+		"tc class add dev veth1a2b parent 1: classid 1:10 htb rate 10mbit ceil 10mbit && \\\n\t\t\t" +
+		"tc qdisc add dev veth1a2b parent 1:10 pfifo limit 1000"
+	if lines := cl.CommandLines(); lines[len(lines)-1] != expected {
+		t.Errorf("expected %q, got %v", expected, lines)
+	}
+
+	// the host side veth is recorded, the destroy removes its qdisc
+	cl.Reset()
+	if response := execTc(spec.SetDestroyFlag(ctx, "rate-peer"), cl, &NetworkRateExecutor{}, "rate-peer", "rate",
+		map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	if lines := cl.CommandLines(); len(lines) != 1 || lines[0] != "tc qdisc del dev veth1a2b root" {
+		t.Errorf("expected the qdisc of the peer is removed, got %v", lines)
+	}
+}
+
+func TestDestroyNetInterfaceGone(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := exec.NewMockChannel().
+		OnRun("nsenter", "link show dev eth0", spec.ReturnSuccess("2: eth0@if7: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500")).
+		OnRun("ip", "^-o link show$", spec.ReturnSuccess("7: veth1a2b@if2: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500")).
+		OnRun("ip", "link show dev veth1a2b", spec.ReturnSuccess("7: veth1a2b@if2: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue\n    veth"))
+	flags := map[string]string{"interface": "eth0", "container-pid": "1234", "rate": "10mbit", exec.NoProtectSSHKey: spec.True}
+	if response := execTc(ctx, cl, &NetworkRateExecutor{}, "rate-gone", "rate", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+
+	// the container is restarted, the veth and its qdisc are removed
+	cl.OnRun("tc", "qdisc del dev veth1a2b", spec.ReturnFail(spec.OsCmdExecFailed, "Cannot find device \"veth1a2b\""))
+	cl.OnRun("ip", "link show dev veth1a2b", spec.ReturnFail(spec.OsCmdExecFailed, `Device "veth1a2b" does not exist.`))
+	response := execTc(spec.SetDestroyFlag(ctx, "rate-gone"), cl, &NetworkRateExecutor{}, "rate-gone", "rate", map[string]string{})
+	if !response.Success {
+		t.Fatalf("expected the destroy succeeds, got %s", response.Err)
+	}
+	result, ok := response.Result.(tcDestroyResult)
+	if !ok || !strings.Contains(result.Warning, "veth1a2b is gone") {
+		t.Errorf("expected the warning of the gone interface, got %+v", response.Result)
+	}
+	if state, err := exec.LoadState("rate-gone"); err != nil || !state.Destroyed {
+		t.Errorf("expected the record is released, %v", err)
+	}
+}
@@ -1,9 +1,16 @@
 package tc
 
 import (
+	"context"
 	"math/rand"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 )
 
 type buildtargetfilterparam = struct {
@@ -50,6 +57,103 @@ func TestBuildTargetFilterPortAndIp(t *testing.T) {
 	}
 }
 
+func TestBuildTargetFilterIcmp(t *testing.T) {
+	args := "qdisc add dev eth0 parent 1:4 handle 40: netem delay 3000ms 0ms"
+	got := buildTargetFilterPortAndIp(nil, nil, nil, nil, nil, args, "eth0", "1")
+	want := args + ` && \
+                tc filter add dev eth0 parent 1: prio 4 protocol ip u32 match ip protocol 1 0xff flowid 1:4`
+	if got != want {
+		t.Errorf("unexpected result: %s, expected: %s", got, want)
+	}
+
+	got = buildTargetFilterPortAndIp(nil, nil, getIpRules("14.215.177.39,10.0.0.0/8"), nil, nil, args, "eth0", "1")
+	filters := strings.Split(got, "&&")[1:]
+	if len(filters) != 2 {
+		t.Fatalf("unexpected filters: %s", got)
+	}
+	for i, dst := range []string{"14.215.177.39", "10.0.0.0/8"} {
+		if !strings.Contains(filters[i], "match ip dst "+dst) || !strings.Contains(filters[i], "match ip protocol 1 0xff flowid 1:4") {
+			t.Errorf("unexpected filter: %s", filters[i])
+		}
+		if strings.Contains(filters[i], "port") {
+			t.Errorf("unexpected port match in the icmp filter: %s", filters[i])
+		}
+	}
+}
+
+func TestGetProtocol(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+		want  string
+		code  int32
+	}{
+		{name: "none", flags: map[string]string{}, want: ""},
+		{name: "protocol", flags: map[string]string{"protocol": "tcp"}, want: "tcp"},
+		{name: "icmp", flags: map[string]string{"icmp": "true"}, want: "icmp"},
+		{name: "icmp with protocol icmp", flags: map[string]string{"icmp": "true", "protocol": "icmp"}, want: "icmp"},
+		{name: "icmp with protocol tcp", flags: map[string]string{"icmp": "true", "protocol": "tcp"}, code: spec.ParameterIllegal.Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, response := getProtocol(tt.flags)
+			if tt.code != 0 {
+				if response == nil || response.Code != tt.code {
+					t.Errorf("getProtocol() response = %v, want code %d", response, tt.code)
+				}
+				return
+			}
+			if response != nil || got != tt.want {
+				t.Errorf("getProtocol() = %q, %v, want %q", got, response, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSlotRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+		want  string
+		code  int32
+	}{
+		{name: "none", flags: map[string]string{}, want: ""},
+		{name: "min", flags: map[string]string{"slot-min": "800"}, want: "slot 800ms 800ms"},
+		{name: "min and max", flags: map[string]string{"slot-min": "800", "slot-max": "1000", "slot-packets": "64"},
+			want: "slot 800ms 1000ms packets 64"},
+		{name: "packets and bytes", flags: map[string]string{"slot-min": "10", "slot-packets": "8", "slot-bytes": "1500"},
+			want: "slot 10ms 10ms packets 8 bytes 1500"},
+		{name: "without min", flags: map[string]string{"slot-packets": "64"}, code: spec.ParameterLess.Code},
+		{name: "max less than min", flags: map[string]string{"slot-min": "800", "slot-max": "500"}, code: spec.ParameterIllegal.Code},
+		{name: "zero packets", flags: map[string]string{"slot-min": "800", "slot-packets": "0"}, code: spec.ParameterIllegal.Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, response := getSlotRule(tt.flags)
+			if tt.code != 0 {
+				if response == nil || response.Code != tt.code {
+					t.Errorf("getSlotRule() response = %v, want code %d", response, tt.code)
+				}
+				return
+			}
+			if response != nil || got != tt.want {
+				t.Errorf("getSlotRule() = %q, %v, want %q", got, response, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartNetIcmpWithPorts(t *testing.T) {
+	for _, flag := range []string{"local-port", "remote-port", "exclude-port"} {
+		ports := map[string]string{flag: "80"}
+		response := startNet(context.Background(), "eth0", "netem delay 3000ms 0ms", ports["local-port"], ports["remote-port"],
+			ports["exclude-port"], "", "", false, true, "icmp", nil)
+		if response.Success || response.Code != spec.ParameterIllegal.Code || !strings.Contains(response.Err, flag) {
+			t.Errorf("startNet() with --%s and the icmp protocol = %v, want %d", flag, response, spec.ParameterIllegal.Code)
+		}
+	}
+}
+
 func TestBuildMaskForRange(t *testing.T) {
 	start := rand.Int31n(65535)
 	end := rand.Int31n(65535)
@@ -132,3 +236,136 @@ func isMatch(masks [][]uint16, target uint16) bool {
 	}
 	return false
 }
+
+func execTc(ctx context.Context, cl spec.Channel, executor spec.Executor, uid, action string, flags map[string]string) *spec.Response {
+	executor.SetChannel(cl)
+	return executor.Exec(uid, ctx, &spec.ExpModel{Target: "network", ActionName: action, ActionFlags: flags})
+}
+
+func TestDestroyNetByRecord(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := exec.NewMockChannel()
+	flags := map[string]string{"interface": "eth0", "remote-port": "8080", "time": "3000"}
+	if response := execTc(ctx, cl, &NetworkDelayExecutor{}, "tc-1", "delay", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+
+	// the selector flags of the create are not passed to the destroy
+	cl.Reset()
+	if response := execTc(spec.SetDestroyFlag(ctx, "tc-1"), cl, &NetworkDelayExecutor{}, "tc-1", "delay",
+		map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	expected := []string{"tc filter del dev eth0 parent 1: prio 4", "tc qdisc del dev eth0 root"}
+	if got := cl.CommandLines(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected the commands %v, got %v", expected, got)
+	}
+
+	// the interface passed to the destroy is ignored too
+	cl.Reset()
+	if response := execTc(spec.SetDestroyFlag(ctx, "tc-1"), cl, &NetworkLossExecutor{}, "tc-1", "loss",
+		map[string]string{"interface": "eth1"}); !response.Success || len(cl.Commands()) > 0 {
+		t.Errorf("destroying again should be a no-op, got %v and %v", response, cl.CommandLines())
+	}
+}
+
+func TestDestroyNetWithoutRecord(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("tc requires the root user")
+	}
+	exec.StateDir = t.TempDir()
+	ctx := spec.SetDestroyFlag(context.Background(), "tc-2")
+	cl := exec.NewMockChannel()
+	if response := execTc(ctx, cl, &NetworkLossExecutor{}, "tc-2", "loss", map[string]string{}); response.Success ||
+		response.Code != spec.ParameterLess.Code {
+		t.Errorf("expected the interface is required without the record, got %v", response)
+	}
+
+	response := execTc(ctx, cl, &NetworkLossExecutor{}, "tc-2", "loss", map[string]string{"interface": "eth0"})
+	result, ok := response.Result.(tcDestroyResult)
+	if !response.Success || !ok || result.Interface != "eth0" || result.Warning == "" {
+		t.Errorf("expected the warning of the destroy without the record, got %+v", response)
+	}
+	if lines := cl.CommandLines(); len(lines) == 0 || lines[len(lines)-1] != "tc qdisc del dev eth0 root" {
+		t.Errorf("expected the qdisc of eth0 is removed, got %v", lines)
+	}
+}
+
+func TestStartNetLoopback(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	t.Setenv("SSH_CONNECTION", "")
+	t.Setenv("SSH_CLIENT", "")
+	ctx := context.Background()
+	cl := exec.NewMockChannel()
+	flags := map[string]string{"interface": "lo", "local-port": "8080", "time": "100"}
+	response := execTc(ctx, cl, &NetworkDelayExecutor{}, "tc-3", "delay", flags)
+	if response.Success || response.Code != spec.Forbidden.Code {
+		t.Fatalf("expected the loopback is refused without --%s, %+v", exec.AffectLoopbackKey, response)
+	}
+	if lines := cl.CommandLines(); len(lines) > 0 {
+		t.Errorf("unexpected commands %v", lines)
+	}
+
+	flags[exec.AffectLoopbackKey] = spec.True
+	if response := execTc(ctx, cl, &NetworkDelayExecutor{}, "tc-3", "delay", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	lines := cl.CommandLines()
+	filters := lines[len(lines)-1]
+	for _, filter := range []string{
+		"prio 4 protocol ip u32 match ip sport 8080 0xffff  flowid 1:4",
+		"prio 1 protocol ip u32 match ip dport 9526 0xffff  flowid 1:3",
+		"prio 1 protocol ip u32 match ip sport 9526 0xffff  flowid 1:3",
+	} {
+		if !strings.Contains(filters, filter) {
+			t.Errorf("expected the filter %q in %s", filter, filters)
+		}
+	}
+
+	if response := execTc(spec.SetDestroyFlag(ctx, "tc-3"), cl, &NetworkDelayExecutor{}, "tc-3", "delay",
+		map[string]string{}); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+
+	// the whole lo interface is delayed in the bands besides the excluded ports
+	cl.Reset()
+	flags = map[string]string{"interface": "lo", "time": "100", exec.AffectLoopbackKey: spec.True}
+	if response := execTc(ctx, cl, &NetworkDelayExecutor{}, "tc-4", "delay", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	lines = cl.CommandLines()
+	if filters := lines[len(lines)-1]; !strings.Contains(filters, "parent 1:1 netem delay 100ms 0ms") ||
+		!strings.Contains(filters, "prio 1 protocol ip u32 match ip dport 9526 0xffff flowid 1:4") {
+		t.Errorf("expected the blade server port is excluded, got %s", filters)
+	}
+}
+
+func TestStartNetSSHSessions(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	t.Setenv("SSH_CONNECTION", "10.0.0.2 52314 10.0.0.1 22")
+	ctx := context.Background()
+	cl := exec.NewMockChannel().SetCommandAvailable("ss", false)
+	flags := map[string]string{"interface": "eth0", "time": "3000"}
+	if response := execTc(ctx, cl, &NetworkDelayExecutor{}, "tc-5", "delay", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	// the session is moved to the band without netem before the netem is added
+	filter := "tc filter add dev eth0 parent 1: prio 1 protocol ip u32 match ip dst 10.0.0.2/32 match ip src 10.0.0.1/32 " +
+		"match ip sport 22 0xffff match ip dport 52314 0xffff flowid 1:4"
+	lines := cl.CommandLines()
+	if len(lines) < 2 || lines[len(lines)-2] != filter || !strings.Contains(lines[len(lines)-1], "parent 1:1 netem delay 3000ms 0ms") {
+		t.Errorf("expected the filter of the ssh session ahead of the netem, got %v", lines)
+	}
+
+	exec.StateDir = t.TempDir()
+	cl.Reset()
+	flags[exec.NoProtectSSHKey] = spec.True
+	if response := execTc(ctx, cl, &NetworkDelayExecutor{}, "tc-6", "delay", flags); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	lines = cl.CommandLines()
+	if last := lines[len(lines)-1]; last != "tc qdisc add dev eth0 root netem delay 3000ms 0ms" {
+		t.Errorf("expected the netem on the root with --%s, got %v", exec.NoProtectSSHKey, lines)
+	}
+}
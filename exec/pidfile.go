@@ -0,0 +1,99 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/shirou/gopsutil/process"
+)
+
+// pidRecord is the chaos process of an experiment, the create time is used to skip the reused pid
+type pidRecord struct {
+	Pid        int32 `json:"pid"`
+	CreateTime int64 `json:"createTime"`
+}
+
+// GetPidFile returns the file which records the chaos process of the experiment
+func GetPidFile(uid string) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("chaos-%s.pid", uid))
+}
+
+// RecordPid records the current process as the chaos process of the experiment, so that it can be destroyed
+// without searching the processes by the command line and killing them by the kill command, which are not
+// available on Windows
+func RecordPid(uid string) error {
+	pid := int32(os.Getpid())
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return err
+	}
+	createTime, err := p.CreateTime()
+	if err != nil {
+		return err
+	}
+	return writePidRecord(GetPidFile(uid), pidRecord{Pid: pid, CreateTime: createTime})
+}
+
+// DestroyByPidFile kills the chaos process recorded by RecordPid and removes the pid file
+func DestroyByPidFile(ctx context.Context, uid string) *spec.Response {
+	// the metrics are removed after the process is killed, so they are not written again
+	defer RemoveMetrics(ctx, uid)
+	pidFile := GetPidFile(uid)
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess("no processes found to destroy")
+		}
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, pidFile)
+	}
+	var record pidRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "readPidFile", err)
+	}
+	p, err := process.NewProcess(record.Pid)
+	if err != nil {
+		log.Infof(ctx, "the chaos process %d of %s exited", record.Pid, uid)
+		os.Remove(pidFile)
+		return spec.ReturnSuccess("no processes found to destroy")
+	}
+	if createTime, err := p.CreateTime(); err == nil && createTime != record.CreateTime {
+		log.Warnf(ctx, "the pid %d is reused by another process, skip it", record.Pid)
+		os.Remove(pidFile)
+		return spec.ReturnSuccess("no processes found to destroy")
+	}
+	if err := p.Kill(); err != nil {
+		log.Errorf(ctx, "kill the chaos process %d failed, %v", record.Pid, err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, fmt.Sprintf("kill %d", record.Pid), err)
+	}
+	os.Remove(pidFile)
+	return spec.ReturnSuccess(fmt.Sprintf("%d", record.Pid))
+}
+
+func writePidRecord(pidFile string, record pidRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(pidFile, data, 0600)
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// PortHolder is the process which listens on the tcp port, the unit is the systemd service of the process,
+// it is empty if the process is not in a system service
+type PortHolder struct {
+	Pid  int
+	Name string
+	Unit string
+}
+
+// String returns the holder such as nginx(1234)
+func (h PortHolder) String() string {
+	return fmt.Sprintf("%s(%d)", h.Name, h.Pid)
+}
+
+// FindPortHolders returns the processes listening on the tcp port by ss, or lsof if ss is absent. Only the
+// listeners are returned, the connections of the port, such as the ones in TIME_WAIT, don't hold it.
+func FindPortHolders(ctx context.Context, cl spec.Channel, port int) ([]PortHolder, error) {
+	var holders []PortHolder
+	if cl.IsCommandAvailable(ctx, "ss") {
+		response := RunReadOnly(ctx, cl, "ss", fmt.Sprintf("-Htlpn '( sport = :%d )'", port))
+		if !response.Success {
+			return nil, fmt.Errorf("list the listeners of the port %d failed, %s", port, response.Err)
+		}
+		holders = parseSsListeners(fmt.Sprint(response.Result), port)
+	} else if cl.IsCommandAvailable(ctx, "lsof") {
+		response := RunReadOnly(ctx, cl, "lsof", fmt.Sprintf("-nP -iTCP:%d -sTCP:LISTEN -Fpc", port))
+		// lsof exits with 1 if no file is found, which isn't told from the other failures, so no holder is
+		// returned then
+		if response.Success {
+			holders = parseLsofListeners(fmt.Sprint(response.Result))
+		}
+	} else {
+		return nil, fmt.Errorf("neither ss nor lsof is found to list the listeners of the port %d", port)
+	}
+	for i := range holders {
+		holders[i].Unit = SystemdUnit(holders[i].Pid)
+	}
+	return holders, nil
+}
+
+var ssUserPattern = regexp.MustCompile(`\("((?:[^"\\]|\\.)*)",pid=(\d+),fd=\d+\)`)
+
+// parseSsListeners parses the output of ss -Htlpn, whose columns are State, Recv-Q, Send-Q, the local address,
+// the peer address and the users of the socket, such as users:(("nginx",pid=1234,fd=6)). The listener of other
+// ports is skipped, and the process listening on both ipv4 and ipv6 is returned once.
+func parseSsListeners(output string, port int) []PortHolder {
+	holders := make([]PortHolder, 0)
+	seen := make(map[int]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[0] != "LISTEN" {
+			continue
+		}
+		_, localPort, err := net.SplitHostPort(fields[3])
+		if err != nil || localPort != strconv.Itoa(port) {
+			continue
+		}
+		for _, match := range ssUserPattern.FindAllStringSubmatch(strings.Join(fields[5:], " "), -1) {
+			pid, err := strconv.Atoi(match[2])
+			if err != nil || seen[pid] {
+				continue
+			}
+			seen[pid] = true
+			holders = append(holders, PortHolder{Pid: pid, Name: match[1]})
+		}
+	}
+	sort.Slice(holders, func(i, j int) bool { return holders[i].Pid < holders[j].Pid })
+	return holders
+}
+
+// parseLsofListeners parses the output of lsof -Fpc, the line of the process starts with p and is followed by
+// the line of the command which starts with c
+func parseLsofListeners(output string) []PortHolder {
+	holders := make([]PortHolder, 0)
+	seen := make(map[int]bool)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case 'p':
+			pid, err := strconv.Atoi(line[1:])
+			if err != nil || seen[pid] {
+				continue
+			}
+			seen[pid] = true
+			holders = append(holders, PortHolder{Pid: pid})
+		case 'c':
+			if len(holders) > 0 && holders[len(holders)-1].Name == "" {
+				holders[len(holders)-1].Name = line[1:]
+			}
+		}
+	}
+	sort.Slice(holders, func(i, j int) bool { return holders[i].Pid < holders[j].Pid })
+	return holders
+}
+
+// SystemdUnit returns the systemd service of the process by its cgroup, it is empty if the process is not in a
+// system service or the cgroup is unknown on the platform
+func SystemdUnit(pid int) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 || (fields[0] != "0" && fields[1] != "name=systemd") {
+			continue
+		}
+		parts := strings.Split(fields[2], "/")
+		for i := len(parts) - 1; i >= 0; i-- {
+			if !strings.HasSuffix(parts[i], ".service") {
+				continue
+			}
+			// the user service is managed by the user manager, which is not supported
+			if strings.HasPrefix(parts[i], "user@") {
+				return ""
+			}
+			return parts[i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestParseSsListeners(t *testing.T) {
+	output := `LISTEN 0      511          0.0.0.0:8080       0.0.0.0:*    users:(("nginx",pid=1235,fd=6),("nginx",pid=1234,fd=6))
+LISTEN 0      511             [::]:8080          [::]:*    users:(("nginx",pid=1234,fd=7))
+LISTEN 0      4096       127.0.0.1:18080     0.0.0.0:*    users:(("java",pid=2000,fd=100))
+LISTEN 0      128        127.0.0.1:8080      0.0.0.0:*    users:(("my \"app\"",pid=3000,fd=3))
+LISTEN 0      128        10.0.0.1:8080       0.0.0.0:*
+illegal
+`
+	holders := parseSsListeners(output, 8080)
+	expected := []PortHolder{{Pid: 1234, Name: "nginx"}, {Pid: 1235, Name: "nginx"}, {Pid: 3000, Name: `my \"app\"`}}
+	if !reflect.DeepEqual(holders, expected) {
+		t.Errorf("expected the holders %v, got %v", expected, holders)
+	}
+	if holders := parseSsListeners("", 8080); len(holders) != 0 {
+		t.Errorf("expected no holder, got %v", holders)
+	}
+}
+
+func TestParseLsofListeners(t *testing.T) {
+	holders := parseLsofListeners("p1235\ncnginx\nf6\np1234\ncnginx\nf6\np1234\ncnginx\n")
+	expected := []PortHolder{{Pid: 1234, Name: "nginx"}, {Pid: 1235, Name: "nginx"}}
+	if !reflect.DeepEqual(holders, expected) {
+		t.Errorf("expected the holders %v, got %v", expected, holders)
+	}
+}
+
+func TestFindPortHolders(t *testing.T) {
+	cl := NewMockChannel().
+		OnRun("ss", "sport = :80 ", spec.ReturnSuccess(
+			`LISTEN 0 511 0.0.0.0:80 0.0.0.0:* users:(("nginx",pid=-1,fd=6),("nginx",pid=99999999,fd=6))`+"\n")).
+		OnRun("lsof", "", spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "lsof", " exit status 1"))
+	holders, err := FindPortHolders(context.Background(), cl, 80)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []PortHolder{{Pid: 99999999, Name: "nginx"}}
+	if !reflect.DeepEqual(holders, expected) {
+		t.Errorf("expected the holders %v, got %v", expected, holders)
+	}
+	if s := holders[0].String(); s != "nginx(99999999)" {
+		t.Errorf("unexpected holder %s", s)
+	}
+
+	// lsof exits with 1 if the port is not listened
+	holders, err = FindPortHolders(context.Background(), cl.SetCommandAvailable("ss", false), 80)
+	if err != nil || len(holders) != 0 {
+		t.Errorf("expected no holder, got %v, %v", holders, err)
+	}
+
+	if _, err := FindPortHolders(context.Background(), cl.SetCommandAvailable("lsof", false), 80); err == nil {
+		t.Error("expected the error without ss and lsof")
+	}
+}
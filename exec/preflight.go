@@ -0,0 +1,149 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// Capability is a linux capability, the bit is the index in the capability sets of /proc/<pid>/status
+type Capability struct {
+	Name string
+	Bit  uint
+}
+
+var (
+	CapDacOverride = Capability{"CAP_DAC_OVERRIDE", 1}
+	CapKill        = Capability{"CAP_KILL", 5}
+	CapNetAdmin    = Capability{"CAP_NET_ADMIN", 12}
+	CapSysPtrace   = Capability{"CAP_SYS_PTRACE", 19}
+	CapSysAdmin    = Capability{"CAP_SYS_ADMIN", 21}
+	CapSysBoot     = Capability{"CAP_SYS_BOOT", 22}
+	CapSysNice     = Capability{"CAP_SYS_NICE", 23}
+	CapSysResource = Capability{"CAP_SYS_RESOURCE", 24}
+	CapSysTime     = Capability{"CAP_SYS_TIME", 25}
+	CapMacAdmin    = Capability{"CAP_MAC_ADMIN", 33}
+)
+
+// Requirement is what the action needs before it changes anything, the commands are looked up by the channel
+type Requirement struct {
+	Capabilities []Capability
+	Commands     []string
+	// OptionalCommands are replaced by the fallbacks if they are missing, such as hwclock of time travel
+	OptionalCommands []string
+	// KernelFeatures are probed by ProbeKernelFeatures, they are only checked by CheckReadiness because
+	// the probe may be wrong in the container without /lib/modules
+	KernelFeatures []string
+	// CGroup is true if the action limits or measures itself by the cgroups
+	CGroup bool
+}
+
+// nsexecRequirement is added for the nsexec channel, entering the namespaces of another process needs them
+var nsexecRequirement = Requirement{Capabilities: []Capability{CapSysAdmin, CapSysPtrace}}
+
+// GetRequirement returns the requirement of the action, it's empty if the action needs nothing special
+func GetRequirement(target, action string) Requirement {
+	return preflightRequirements[target+" "+action]
+}
+
+// PreflightResult is the result of the preflight of an action
+type PreflightResult struct {
+	Target              string   `json:"target"`
+	Action              string   `json:"action"`
+	Passed              bool     `json:"passed"`
+	MissingCapabilities []string `json:"missingCapabilities,omitempty"`
+	MissingCommands     []string `json:"missingCommands,omitempty"`
+	Error               string   `json:"error,omitempty"`
+}
+
+// CheckRequirement checks the capabilities of the current process and the commands in the channel
+func CheckRequirement(ctx context.Context, cl spec.Channel, target, action string) *PreflightResult {
+	requirement := GetRequirement(target, action)
+	if cl != nil && cl.Name() == spec.NSExecBin {
+		requirement.Capabilities = append(append([]Capability{}, requirement.Capabilities...),
+			nsexecRequirement.Capabilities...)
+	}
+	result := &PreflightResult{Target: target, Action: action}
+	if len(requirement.Capabilities) > 0 {
+		effective, err := effectiveCapabilities()
+		if err != nil {
+			result.Error = fmt.Sprintf("read the effective capabilities failed, %v", err)
+			return result
+		}
+		result.MissingCapabilities = missingCapabilities(effective, requirement.Capabilities)
+	}
+	if cl != nil {
+		for _, command := range requirement.Commands {
+			if !cl.IsCommandAvailable(ctx, command) {
+				result.MissingCommands = append(result.MissingCommands, command)
+			}
+		}
+	}
+	result.Passed = len(result.MissingCapabilities) == 0 && len(result.MissingCommands) == 0
+	return result
+}
+
+// Preflight fails fast if the action can't run with the capabilities of the current process or the
+// commands in the channel, the response is nil if the action can run.
+func Preflight(ctx context.Context, cl spec.Channel, target, action string) *spec.Response {
+	result := CheckRequirement(ctx, cl, target, action)
+	if result.Passed {
+		return nil
+	}
+	if result.Error != "" {
+		return spec.ReturnFail(spec.OsCmdExecFailed, result.Error)
+	}
+	if len(result.MissingCapabilities) > 0 {
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("`%s %s`: missing capability %s, run as root or grant it",
+			target, action, strings.Join(result.MissingCapabilities, ",")))
+	}
+	return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("`%s %s`: command %s not found",
+		target, action, strings.Join(result.MissingCommands, ",")))
+}
+
+// PreflightAll checks all the actions of the models, the results are sorted by the target and the action
+func PreflightAll(ctx context.Context, cl spec.Channel, models []spec.ExpModelCommandSpec) []*PreflightResult {
+	results := make([]*PreflightResult, 0)
+	for _, model := range models {
+		for _, action := range model.Actions() {
+			results = append(results, CheckRequirement(ctx, cl, model.Name(), action.Name()))
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Target != results[j].Target {
+			return results[i].Target < results[j].Target
+		}
+		return results[i].Action < results[j].Action
+	})
+	return results
+}
+
+// missingCapabilities returns the names of the capabilities which are not in the effective set
+func missingCapabilities(effective uint64, capabilities []Capability) []string {
+	var missing []string
+	for _, capability := range capabilities {
+		if effective&(1<<capability.Bit) == 0 {
+			missing = append(missing, capability.Name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,122 @@
+//go:build linux
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// procSelfStatus is replaced in the tests
+var procSelfStatus = "/proc/self/status"
+
+var (
+	tcRequirement = Requirement{Capabilities: []Capability{CapNetAdmin}, Commands: []string{"tc", "head"},
+		KernelFeatures: []string{FeatureNetem}}
+	rateRequirement = Requirement{Capabilities: []Capability{CapNetAdmin}, Commands: []string{"tc", "head"},
+		KernelFeatures: []string{FeatureHtb}}
+	scriptRequirement  = Requirement{Commands: []string{"cat", "cp", "rm", "sed", "awk", "grep", "sha256sum"}}
+	systemdRequirement = Requirement{Commands: []string{"systemctl"}}
+)
+
+// preflightRequirements are the requirements of the actions which fail halfway without them, such as the
+// iptables rules which are added after the state is recorded. The commands are the ones which the
+// executors check by IsAllCommandsAvailable.
+var preflightRequirements = map[string]Requirement{
+	"cpu frequency":     {Capabilities: []Capability{CapDacOverride}},
+	"cpu fullload":      {OptionalCommands: []string{"taskset"}, CGroup: true},
+	"disk burn":         {Commands: []string{"rm", "dd"}, CGroup: true},
+	"disk fill":         {OptionalCommands: []string{"fallocate"}},
+	"file add":          {Commands: []string{"touch", "mkdir", "printf", "rm"}},
+	"file append":       {Commands: []string{"printf", "kill", "mkdir"}, CGroup: true},
+	"file chmod":        {Commands: []string{"chmod", "rm", "cat", "stat"}},
+	"file delete":       {Commands: []string{"rm", "mv"}},
+	"file move":         {Commands: []string{"mv", "mkdir"}},
+	"host hostname":     {Capabilities: []Capability{CapSysAdmin}},
+	"host reboot":       {Capabilities: []Capability{CapSysBoot}},
+	"host shutdown":     {Capabilities: []Capability{CapSysBoot}},
+	"kernel selinux":    {Capabilities: []Capability{CapMacAdmin}, Commands: []string{"getenforce", "setenforce"}},
+	"kernel swap":       {Capabilities: []Capability{CapSysAdmin}, Commands: []string{"swapoff", "swapon"}},
+	"kernel sysctl":     {Capabilities: []Capability{CapSysAdmin, CapDacOverride}},
+	"mem cache-drop":    {Capabilities: []Capability{CapSysAdmin}},
+	"mem load":          {Commands: []string{"dd", "mount", "umount"}, CGroup: true},
+	"network arp":       {Capabilities: []Capability{CapNetAdmin}, Commands: []string{"ip"}},
+	"network corrupt":   tcRequirement,
+	"network delay":     tcRequirement,
+	"network duplicate": tcRequirement,
+	"network loss":      tcRequirement,
+	"network reorder":   tcRequirement,
+	"network rate":      rateRequirement,
+	"network dns":       {Commands: []string{"grep", "cat", "cp", "rm"}},
+	"network drop":      {Capabilities: []Capability{CapNetAdmin}, Commands: []string{"iptables"}},
+	"network partition": {Capabilities: []Capability{CapNetAdmin}, Commands: []string{"iptables"}},
+	"network dns_down": {Capabilities: []Capability{CapNetAdmin},
+		Commands: []string{"cat", "cp", "rm", "iptables", "iptables-save", "iptables-restore", "nslookup", "ping"}},
+	"network occupy":       {CGroup: true},
+	"process affinity":     {Capabilities: []Capability{CapSysNice}},
+	"process kill":         {Capabilities: []Capability{CapKill}},
+	"process limit":        {Capabilities: []Capability{CapSysResource}},
+	"process load":         {Commands: []string{"ping", "ulimit"}},
+	"process stop":         {Capabilities: []Capability{CapKill}},
+	"process task-exhaust": {CGroup: true},
+	"script cron":          {Commands: append([]string{"crontab"}, scriptRequirement.Commands...)},
+	"script delay":         scriptRequirement,
+	"script exit":          scriptRequirement,
+	"script hang":          scriptRequirement,
+	"strace delay":         {Capabilities: []Capability{CapSysPtrace}, CGroup: true},
+	"strace error":         {Capabilities: []Capability{CapSysPtrace}, CGroup: true},
+	"systemd env":          systemdRequirement,
+	"systemd kill":         systemdRequirement,
+	"systemd restart-loop": systemdRequirement,
+	"systemd stop":         systemdRequirement,
+	"time sync-stop":       {OptionalCommands: []string{"systemctl"}},
+	"time timezone":        {OptionalCommands: []string{"timedatectl"}},
+	"time travel": {Capabilities: []Capability{CapSysTime}, Commands: []string{"date"},
+		OptionalCommands: []string{"timedatectl", "hwclock"}},
+}
+
+// effectiveCapabilities returns the effective capability set of the current process
+func effectiveCapabilities() (uint64, error) {
+	file, err := os.Open(procSelfStatus)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return parseCapEff(file)
+}
+
+// parseCapEff parses the CapEff line of /proc/<pid>/status, such as `CapEff:	000001ffffffffff`
+func parseCapEff(reader io.Reader) (uint64, error) {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found || key != "CapEff" {
+			continue
+		}
+		return strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("CapEff not found")
+}
@@ -0,0 +1,93 @@
+//go:build linux
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// commandsChannel has only the commands in the set
+type commandsChannel struct {
+	spec.Channel
+	name     string
+	commands map[string]bool
+}
+
+func (c *commandsChannel) Name() string { return c.name }
+
+func (c *commandsChannel) IsCommandAvailable(ctx context.Context, commandName string) bool {
+	return c.commands[commandName]
+}
+
+func setupProcSelfStatus(t *testing.T, capEff string) {
+	file := path.Join(t.TempDir(), "status")
+	content := "Name:\tchaos_os\nCapInh:\t0000000000000000\nCapPrm:\t" + capEff + "\nCapEff:\t" + capEff + "\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	original := procSelfStatus
+	procSelfStatus = file
+	t.Cleanup(func() { procSelfStatus = original })
+}
+
+func TestParseCapEff(t *testing.T) {
+	effective, err := parseCapEff(strings.NewReader("CapPrm:\t0000000000000000\nCapEff:\t000001ffffffffff\n"))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if effective != 0x1ffffffffff {
+		t.Errorf("unexpected capabilities, %x", effective)
+	}
+	if _, err := parseCapEff(strings.NewReader("Name:\tchaos_os\n")); err == nil {
+		t.Errorf("expected an error without CapEff")
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	// CAP_NET_ADMIN only
+	setupProcSelfStatus(t, "0000000000001000")
+	cl := &commandsChannel{name: spec.LocalChannel, commands: map[string]bool{"iptables": true}}
+	ctx := context.Background()
+
+	if response := Preflight(ctx, cl, "network", "drop"); response != nil {
+		t.Errorf("unexpected failure, %s", response.Err)
+	}
+	response := Preflight(ctx, cl, "time", "travel")
+	if response == nil || !strings.Contains(response.Err, "CAP_SYS_TIME") {
+		t.Errorf("expected the missing CAP_SYS_TIME, %+v", response)
+	}
+	response = Preflight(ctx, cl, "network", "delay")
+	if response == nil || !strings.Contains(response.Err, "tc") {
+		t.Errorf("expected the missing tc, %+v", response)
+	}
+	if response := Preflight(ctx, cl, "network", "occupy"); response != nil {
+		t.Errorf("unexpected failure of the action without requirement, %s", response.Err)
+	}
+	cl.name = spec.NSExecBin
+	result := CheckRequirement(ctx, cl, "network", "drop")
+	if result.Passed || strings.Join(result.MissingCapabilities, ",") != "CAP_SYS_ADMIN,CAP_SYS_PTRACE" {
+		t.Errorf("expected the capabilities of the nsexec channel, %+v", result)
+	}
+}
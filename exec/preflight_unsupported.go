@@ -0,0 +1,27 @@
+//go:build !linux
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+// preflightRequirements is empty because the capabilities are linux only, the actions check their commands themselves
+var preflightRequirements = map[string]Requirement{}
+
+// effectiveCapabilities returns all the capabilities, they are not checked on the platform
+func effectiveCapabilities() (uint64, error) {
+	return ^uint64(0), nil
+}
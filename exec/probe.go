@@ -0,0 +1,97 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The kernel features which the experiments depend on
+const (
+	FeatureNetem        = "netem"
+	FeatureHtb          = "htb"
+	FeatureIfb          = "ifb"
+	FeatureDeviceMapper = "device-mapper"
+	FeatureConntrack    = "conntrack"
+)
+
+// FeatureState is the state of the kernel feature
+type FeatureState string
+
+const (
+	// FeatureAvailable means the feature is built in or the module is loaded
+	FeatureAvailable FeatureState = "available"
+	// FeatureLoadable means the module is installed but not loaded, it's loaded on demand by the kernel
+	FeatureLoadable FeatureState = "loadable"
+	// FeatureUnavailable means the feature is neither built in nor installed
+	FeatureUnavailable FeatureState = "unavailable"
+	// FeatureUnknown means the modules of the kernel can't be read, for example in the container
+	FeatureUnknown FeatureState = "unknown"
+)
+
+// KernelFeature is the probe result of the kernel feature
+type KernelFeature struct {
+	Name   string       `json:"name"`
+	State  FeatureState `json:"state"`
+	Detail string       `json:"detail,omitempty"`
+}
+
+// Usable returns true if the experiment can use the feature
+func (f *KernelFeature) Usable() bool {
+	return f.State == FeatureAvailable || f.State == FeatureLoadable
+}
+
+// CheckKernelVersion returns the error if the running kernel is older than major.minor, which the feature of
+// the experiment needs, such as the slot of netem
+func CheckKernelVersion(feature string, major, minor int) error {
+	release, err := KernelRelease()
+	if err != nil {
+		return fmt.Errorf("%s requires the kernel %d.%d or later, but the kernel release is unknown, %v", feature, major, minor, err)
+	}
+	currentMajor, currentMinor, err := parseKernelVersion(release)
+	if err != nil {
+		return fmt.Errorf("%s requires the kernel %d.%d or later, %v", feature, major, minor, err)
+	}
+	if currentMajor < major || (currentMajor == major && currentMinor < minor) {
+		return fmt.Errorf("%s requires the kernel %d.%d or later, the running kernel is %s", feature, major, minor, release)
+	}
+	return nil
+}
+
+// parseKernelVersion parses the major and the minor version of the kernel release, such as 5.10.0-21-amd64
+func parseKernelVersion(release string) (int, int, error) {
+	parts := strings.SplitN(release, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("illegal kernel release %s", release)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("illegal kernel release %s", release)
+	}
+	// the minor may be followed by the suffix without the patch version, such as 4.8-rc1
+	digits := strings.IndexFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' })
+	if digits < 0 {
+		digits = len(parts[1])
+	}
+	minor, err := strconv.Atoi(parts[1][:digits])
+	if err != nil {
+		return 0, 0, fmt.Errorf("illegal kernel release %s", release)
+	}
+	return major, minor, nil
+}
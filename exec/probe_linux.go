@@ -0,0 +1,113 @@
+//go:build linux
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+)
+
+// procRoot, sysRoot and modulesRoot are replaced in the tests
+var (
+	procRoot    = "/proc"
+	sysRoot     = "/sys"
+	modulesRoot = "/lib/modules"
+)
+
+// kernelFeatureModules are the kernel modules of the features
+var kernelFeatureModules = map[string]string{
+	FeatureNetem:        "sch_netem",
+	FeatureHtb:          "sch_htb",
+	FeatureIfb:          "ifb",
+	FeatureDeviceMapper: "dm_mod",
+	FeatureConntrack:    "nf_conntrack",
+}
+
+// ProbeKernelFeatures probes the kernel features from the loaded modules, the built-in modules and the
+// installed modules of the running kernel
+func ProbeKernelFeatures() map[string]*KernelFeature {
+	release, err := os.ReadFile(path.Join(procRoot, "sys/kernel/osrelease"))
+	modulesDir := path.Join(modulesRoot, strings.TrimSpace(string(release)))
+	loaded, loadedErr := readModuleNames(path.Join(procRoot, "modules"), false)
+	builtin, builtinErr := readModuleNames(path.Join(modulesDir, "modules.builtin"), true)
+	installed, installedErr := readModuleNames(path.Join(modulesDir, "modules.dep"), true)
+
+	features := make(map[string]*KernelFeature, len(kernelFeatureModules))
+	for name, module := range kernelFeatureModules {
+		feature := &KernelFeature{Name: name}
+		features[name] = feature
+		switch {
+		// the built-in modules with parameters are not in modules.builtin of the old kernels, but in /sys/module
+		case loaded[module] || util.IsExist(path.Join(sysRoot, "module", module)):
+			feature.State, feature.Detail = FeatureAvailable, fmt.Sprintf("module %s is loaded or built in", module)
+		case builtin[module]:
+			feature.State, feature.Detail = FeatureAvailable, fmt.Sprintf("module %s is built in", module)
+		case installed[module]:
+			feature.State, feature.Detail = FeatureLoadable, fmt.Sprintf("module %s is installed", module)
+		case err != nil || loadedErr != nil || builtinErr != nil || installedErr != nil:
+			feature.State = FeatureUnknown
+			feature.Detail = fmt.Sprintf("module %s is not found, the modules of the kernel can't be read", module)
+		default:
+			feature.State, feature.Detail = FeatureUnavailable, fmt.Sprintf("module %s is not found", module)
+		}
+	}
+	return features
+}
+
+// KernelRelease returns the release of the running kernel, such as 5.10.0-21-amd64
+func KernelRelease() (string, error) {
+	release, err := os.ReadFile(path.Join(procRoot, "sys/kernel/osrelease"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(release)), nil
+}
+
+// readModuleNames reads the module names of /proc/modules, or the module paths of modules.builtin and
+// modules.dep, such as kernel/net/sched/sch_netem.ko.xz. The dashes are replaced with the underscores as
+// the kernel does.
+func readModuleNames(file string, isPath bool) (map[string]bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[0]
+		if isPath {
+			name = strings.TrimSuffix(path.Base(name), ":")
+			if index := strings.Index(name, ".ko"); index >= 0 {
+				name = name[:index]
+			}
+		}
+		names[strings.ReplaceAll(name, "-", "_")] = true
+	}
+	return names, scanner.Err()
+}
@@ -0,0 +1,132 @@
+//go:build linux
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		file := path.Join(root, name)
+		if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func setupKernelRoots(t *testing.T, files map[string]string) {
+	root := t.TempDir()
+	writeFiles(t, root, files)
+	originalProc, originalSys, originalModules := procRoot, sysRoot, modulesRoot
+	procRoot, sysRoot, modulesRoot = path.Join(root, "proc"), path.Join(root, "sys"), path.Join(root, "modules")
+	t.Cleanup(func() { procRoot, sysRoot, modulesRoot = originalProc, originalSys, originalModules })
+}
+
+func TestProbeKernelFeatures(t *testing.T) {
+	setupKernelRoots(t, map[string]string{
+		"proc/sys/kernel/osrelease":      "5.10.0\n",
+		"proc/modules":                   "nf_conntrack 172032 1 nf_nat, Live 0x0000000000000000\n",
+		"sys/module/dm_mod/uevent":       "",
+		"modules/5.10.0/modules.builtin": "kernel/drivers/net/ifb.ko\n",
+		"modules/5.10.0/modules.dep": "kernel/net/sched/sch_netem.ko.xz:\n" +
+			"kernel/net/netfilter/nf_conntrack.ko.xz: kernel/lib/libcrc32c.ko.xz\n",
+	})
+	features := ProbeKernelFeatures()
+	expected := map[string]FeatureState{
+		FeatureConntrack:    FeatureAvailable,
+		FeatureDeviceMapper: FeatureAvailable,
+		FeatureIfb:          FeatureAvailable,
+		FeatureNetem:        FeatureLoadable,
+	}
+	for name, state := range expected {
+		if feature := features[name]; feature == nil || feature.State != state {
+			t.Errorf("expected %s %s, %+v", name, state, feature)
+		}
+	}
+}
+
+func TestProbeKernelFeaturesWithoutModules(t *testing.T) {
+	setupKernelRoots(t, map[string]string{
+		"proc/sys/kernel/osrelease": "5.10.0\n",
+		"proc/modules":              "",
+	})
+	if feature := ProbeKernelFeatures()[FeatureNetem]; feature.State != FeatureUnknown {
+		t.Errorf("expected netem unknown, %+v", feature)
+	}
+}
+
+func TestCheckActionReadiness(t *testing.T) {
+	// CAP_NET_ADMIN only
+	setupProcSelfStatus(t, "0000000000001000")
+	cl := &commandsChannel{name: spec.LocalChannel, commands: map[string]bool{"tc": true, "head": true, "date": true}}
+	ctx := context.Background()
+	root := &cgroups.CGroupRoot{Version: cgroups.CGroupV2}
+	features := map[string]*KernelFeature{
+		FeatureNetem: {Name: FeatureNetem, State: FeatureUnavailable},
+	}
+
+	readiness := checkActionReadiness(ctx, cl, CheckRequirement(ctx, cl, "network", "delay"), root, features)
+	if readiness.Readiness != ReadinessUnavailable || len(readiness.Reasons) != 1 {
+		t.Errorf("expected unavailable without netem, %+v", readiness)
+	}
+	features[FeatureNetem].State = FeatureLoadable
+	readiness = checkActionReadiness(ctx, cl, CheckRequirement(ctx, cl, "network", "delay"), root, features)
+	if readiness.Readiness != ReadinessReady {
+		t.Errorf("expected ready with the loadable netem, %+v", readiness)
+	}
+	readiness = checkActionReadiness(ctx, cl, CheckRequirement(ctx, cl, "time", "travel"), root, features)
+	if readiness.Readiness != ReadinessUnavailable || len(readiness.Reasons) != 3 {
+		t.Errorf("expected unavailable without CAP_SYS_TIME and degraded by the optional commands, %+v", readiness)
+	}
+	root.Version = cgroups.CGroupUnknown
+	readiness = checkActionReadiness(ctx, cl, CheckRequirement(ctx, cl, "cpu", "fullload"), root, features)
+	if readiness.Readiness != ReadinessDegraded || len(readiness.Reasons) != 2 {
+		t.Errorf("expected degraded without taskset and cgroup, %+v", readiness)
+	}
+}
+
+func TestCheckKernelVersion(t *testing.T) {
+	for release, passed := range map[string]bool{
+		"4.8.0":                  true,
+		"5.10.0-21-amd64":        true,
+		"4.8-rc1":                true,
+		"4.4.0-210-generic":      false,
+		"3.10.0-1160.el7.x86_64": false,
+	} {
+		setupKernelRoots(t, map[string]string{"proc/sys/kernel/osrelease": release + "\n"})
+		if err := CheckKernelVersion("netem slot", 4, 8); (err == nil) != passed {
+			t.Errorf("expected the kernel %s passed %t, got %v", release, passed, err)
+		}
+	}
+	setupKernelRoots(t, map[string]string{"proc/sys/kernel/osrelease": "illegal\n"})
+	if err := CheckKernelVersion("netem slot", 4, 8); err == nil {
+		t.Error("expected the error of the illegal release")
+	}
+}
@@ -19,6 +19,8 @@ package process
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/user"
 	"strconv"
 	"strings"
 
@@ -26,6 +28,7 @@ import (
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	gopsProcess "github.com/shirou/gopsutil/process"
 )
 
 type ProcessCommandModelSpec struct {
@@ -46,6 +49,10 @@ func NewProcessCommandModelSpec() spec.ExpModelCommandSpec {
 				NewKillProcessActionCommandSpec(),
 				NewStopProcessActionCommandSpec(),
 				NewProcessLoadActionCommandSpec(),
+				NewLimitProcessActionCommandSpec(),
+				NewUlimitProcessActionCommandSpec(),
+				NewTaskExhaustActionCommandSpec(),
+				NewAffinityProcessActionCommandSpec(),
 			},
 		},
 	}
@@ -69,17 +76,32 @@ func getPids(ctx context.Context, cl spec.Channel, model *spec.ExpModel, uid str
 	processCmd := model.ActionFlags["process-cmd"]
 	localPorts := model.ActionFlags["local-port"]
 	pid := model.ActionFlags["pid"]
+	userName := model.ActionFlags["user"]
 
 	excludeProcess := model.ActionFlags["exclude-process"]
 	ignoreProcessNotFound := model.ActionFlags["ignore-not-found"] == "true"
-	if process == "" && processCmd == "" && localPorts == "" && pid == "" {
-		log.Errorf(ctx, "%s", "pid、less process、process-cmd and local-port, less process matcher")
-		return spec.ResponseFailWithFlags(spec.ParameterLess, "pid|process|process-cmd|local-port")
+	if process == "" && processCmd == "" && localPorts == "" && pid == "" && userName == "" {
+		log.Errorf(ctx, "%s", "pid、less process、process-cmd、local-port and user, less process matcher")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "pid|process|process-cmd|local-port|user")
+	}
+	euid := -1
+	if userName != "" {
+		var err error
+		euid, err = lookupUserId(userName)
+		if err != nil {
+			log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("user", userName, err))
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "user", userName, err)
+		}
+		if euid == 0 && model.ActionFlags["allow-root-processes"] != "true" {
+			log.Errorf(ctx, "matching the root processes requires the allow-root-processes flag")
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "user", userName,
+				"matching the root processes requires the allow-root-processes flag")
+		}
 	}
 
 	excludeProcessValue := fmt.Sprintf("blade,%s", excludeProcess)
 	ctx = context.WithValue(ctx, channel.ExcludeProcessKey, excludeProcessValue)
-	if !ignoreProcessNotFound {
+	if !ignoreProcessNotFound && (process != "" || processCmd != "" || localPorts != "" || pid != "") {
 		if response := checkProcessInvalid(ctx, process, processCmd, localPorts, pid, cl); response != nil {
 			return response
 		}
@@ -113,13 +135,13 @@ func getPids(ctx context.Context, cl spec.Channel, model *spec.ExpModel, uid str
 	var killProcessName string
 	ctx = context.WithValue(ctx, channel.ExcludeProcessKey, excludeProcessValue)
 	if process != "" {
-		pids, err = cl.GetPidsByProcessName(process, ctx)
+		pids, err = getPidsByProcessName(ctx, cl, process)
 		if err != nil {
 			return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("get pids by processname err, %v", err))
 		}
 		killProcessName = process
 	} else if processCmd != "" {
-		pids, err = cl.GetPidsByProcessCmdName(processCmd, ctx)
+		pids, err = getPidsByProcessCmdName(ctx, cl, processCmd)
 		if err != nil {
 			return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("get pids by processcmdname err, %v", err))
 		}
@@ -129,13 +151,22 @@ func getPids(ctx context.Context, cl spec.Channel, model *spec.ExpModel, uid str
 		if err != nil {
 			return spec.ReturnFail(spec.ParameterIllegal, fmt.Sprintf("illegal parameter local-port, %v", err))
 		}
-		pids, err = cl.GetPidsByLocalPorts(ctx, ports)
+		pids, err = getPidsByLocalPorts(ctx, cl, ports)
 		if err != nil {
 			return spec.ReturnFail(spec.ParameterIllegal, fmt.Sprintf("illegal parameter ports, %v", err))
 		}
 	} else if pid != "" {
 		tempPidList := strings.Split(pid, ",")
 		pids = append(pids, tempPidList...)
+	} else if userName != "" {
+		pids, err = getPidsByUser(euid, excludeProcessValue)
+		if err != nil {
+			return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("get pids by user err, %v", err))
+		}
+		killProcessName = userName
+	}
+	if euid >= 0 {
+		pids = filterPidsByUser(pids, euid)
 	}
 	if pids == nil || len(pids) == 0 {
 		if ignoreProcessNotFound {
@@ -152,13 +183,157 @@ func getPids(ctx context.Context, cl spec.Channel, model *spec.ExpModel, uid str
 	return spec.ReturnSuccess(strings.Join(pids, " "))
 }
 
+// ProcessPreview is a process which would be affected by the experiment
+type ProcessPreview struct {
+	Pid     int    `json:"pid"`
+	User    string `json:"user"`
+	Cmdline string `json:"cmdline"`
+}
+
+// previewPids returns the processes of the matched pids without changing anything
+func previewPids(ctx context.Context, cl spec.Channel, pids []string) *spec.Response {
+	previews := make([]ProcessPreview, 0, len(pids))
+	for _, p := range pids {
+		pid, err := strconv.Atoi(p)
+		if err != nil {
+			log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("pid", p, err))
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "pid", p, err)
+		}
+		preview := ProcessPreview{Pid: pid}
+		if user, err := getProcessUser(int32(pid)); err == nil {
+			preview.User = user
+		} else if user, err := cl.GetPidUser(p); err == nil {
+			preview.User = user
+		} else {
+			log.Warnf(ctx, "get user of process %s failed, %v", p, err)
+		}
+		if proc, err := gopsProcess.NewProcess(int32(pid)); err == nil {
+			preview.Cmdline, _ = proc.Cmdline()
+		}
+		previews = append(previews, preview)
+	}
+	return spec.ReturnSuccess(previews)
+}
+
+// lookupUserId resolves the user name, the numeric uid is accepted too
+func lookupUserId(userName string) (int, error) {
+	if uid, err := strconv.Atoi(userName); err == nil {
+		return uid, nil
+	}
+	u, err := user.Lookup(userName)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// getProcessEffectiveUid returns the effective uid of the process
+func getProcessEffectiveUid(pid int32) (int, error) {
+	proc, err := gopsProcess.NewProcess(pid)
+	if err != nil {
+		return -1, err
+	}
+	uids, err := proc.Uids()
+	if err != nil {
+		return -1, err
+	}
+	euid, ok := effectiveUid(uids)
+	if !ok {
+		return -1, fmt.Errorf("effective uid of process %d not found", pid)
+	}
+	return euid, nil
+}
+
+// effectiveUid returns the effective one of the uids, which are the real, effective, saved and filesystem uids
+// on linux, but only the effective uid on darwin
+func effectiveUid(uids []int32) (int, bool) {
+	switch len(uids) {
+	case 0:
+		return -1, false
+	case 1:
+		return int(uids[0]), true
+	default:
+		return int(uids[1]), true
+	}
+}
+
+// getProcessUser returns the name of the effective user of the process, or the uid if the user cannot be found
+func getProcessUser(pid int32) (string, error) {
+	euid, err := getProcessEffectiveUid(pid)
+	if err != nil {
+		return "", err
+	}
+	if u, err := user.LookupId(strconv.Itoa(euid)); err == nil {
+		return u.Username, nil
+	}
+	return strconv.Itoa(euid), nil
+}
+
+// getPidsByUser returns all the processes of the effective uid, excluding the current process
+// and the processes containing the exclude keywords
+func getPidsByUser(euid int, excludeProcessValue string) ([]string, error) {
+	processes, err := gopsProcess.Processes()
+	if err != nil {
+		return nil, err
+	}
+	excludes := make([]string, 0)
+	for _, exclude := range strings.Split(excludeProcessValue, ",") {
+		if exclude = strings.TrimSpace(exclude); exclude != "" {
+			excludes = append(excludes, exclude)
+		}
+	}
+	currPid := int32(os.Getpid())
+	pids := make([]string, 0)
+	for _, p := range processes {
+		if p.Pid == currPid {
+			continue
+		}
+		uids, err := p.Uids()
+		if err != nil {
+			continue
+		}
+		if uid, ok := effectiveUid(uids); !ok || uid != euid {
+			continue
+		}
+		cmdline, err := p.Cmdline()
+		if err != nil || cmdline == "" {
+			continue
+		}
+		excluded := false
+		for _, exclude := range excludes {
+			if strings.Contains(cmdline, exclude) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			pids = append(pids, strconv.Itoa(int(p.Pid)))
+		}
+	}
+	return pids, nil
+}
+
+func filterPidsByUser(pids []string, euid int) []string {
+	filtered := make([]string, 0, len(pids))
+	for _, p := range pids {
+		pid, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			continue
+		}
+		if uid, err := getProcessEffectiveUid(int32(pid)); err == nil && uid == euid {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
 func checkProcessInvalid(ctx context.Context, process, processCmd, localPorts, pid string, cl spec.Channel) *spec.Response {
 	var pids []string
 	var killProcessName string
 	var err error
 	var processParameter string
 	if process != "" {
-		pids, err = cl.GetPidsByProcessName(process, ctx)
+		pids, err = getPidsByProcessName(ctx, cl, process)
 		if err != nil {
 			log.Errorf(ctx, "%s", spec.ProcessIdByNameFailed.Sprintf(process, err))
 			return spec.ResponseFailWithFlags(spec.ProcessIdByNameFailed, process, err)
@@ -166,7 +341,7 @@ func checkProcessInvalid(ctx context.Context, process, processCmd, localPorts, p
 		killProcessName = process
 		processParameter = "process"
 	} else if processCmd != "" {
-		pids, err = cl.GetPidsByProcessCmdName(processCmd, ctx)
+		pids, err = getPidsByProcessCmdName(ctx, cl, processCmd)
 		if err != nil {
 			log.Errorf(ctx, "%s", spec.ProcessIdByNameFailed.Sprintf(processCmd, err))
 			return spec.ResponseFailWithFlags(spec.ProcessIdByNameFailed, processCmd, err)
@@ -178,7 +353,7 @@ func checkProcessInvalid(ctx context.Context, process, processCmd, localPorts, p
 		if err != nil {
 			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "local-port", localPorts, err)
 		}
-		pids, err = cl.GetPidsByLocalPorts(ctx, ports)
+		pids, err = getPidsByLocalPorts(ctx, cl, ports)
 		killProcessName = localPorts
 		processParameter = "local-port"
 	} else if pid != "" {
@@ -0,0 +1,407 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const AffinityProcessBin = "chaos_affinityprocess"
+
+type AffinityProcessActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewAffinityProcessActionCommandSpec() spec.ExpActionCommandSpec {
+	return &AffinityProcessActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "process",
+					Desc: "Process name",
+				},
+				&spec.ExpFlag{
+					Name: "process-cmd",
+					Desc: "Process name in command",
+				},
+				&spec.ExpFlag{
+					Name: "count",
+					Desc: "Limit count, 0 means unlimited",
+				},
+				&spec.ExpFlag{
+					Name: "local-port",
+					Desc: "Local service ports. Separate multiple ports with commas (,) or connector representing ranges, for example: 80,8000-8080",
+				},
+				&spec.ExpFlag{
+					Name: "exclude-process",
+					Desc: "Exclude process",
+				},
+				&spec.ExpFlag{
+					Name: "pid",
+					Desc: "pid",
+				},
+			},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "cpu-list",
+					Desc: "CPUs the process is pinned to, for example: 0 or 0,3 or 1-3",
+				},
+				&spec.ExpFlag{
+					Name: "nice",
+					Desc: "Nice value of the process, an integer value from -20 to 19",
+				},
+				&spec.ExpFlag{
+					Name: "interval",
+					Desc: "Re-apply interval in seconds to cover the threads created after the experiment started, must be a positive integer",
+				},
+			},
+			ActionExecutor: &AffinityProcessExecutor{},
+			ActionExample: `
+# Pin the nginx process to the cpu 0
+blade create process affinity --process nginx --cpu-list 0
+
+# Drop the scheduling priority of the process 1234 and pin it to the cpu 1 and 2
+blade create process affinity --pid 1234 --nice 19 --cpu-list 1-2
+
+# Pin the java process to the cpu 0, and re-apply to the new threads every 5 seconds
+blade create process affinity --process-cmd java --cpu-list 0 --interval 5`,
+			ActionPrograms:    []string{AffinityProcessBin},
+			ActionCategories:  []string{category.SystemProcess},
+			ActionProcessHang: true, // only with the interval flag, see IsProcessHang
+		},
+	}
+}
+
+func (*AffinityProcessActionCommandSpec) Name() string {
+	return "affinity"
+}
+
+func (*AffinityProcessActionCommandSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*AffinityProcessActionCommandSpec) ShortDesc() string {
+	return "Change cpu affinity and priority of process"
+}
+
+func (a *AffinityProcessActionCommandSpec) LongDesc() string {
+	if a.ActionLongDesc != "" {
+		return a.ActionLongDesc
+	}
+	return "Change the cpu affinity and the nice value of all the threads of the process. " +
+		"The original values are recorded and restored when the experiment is destroyed"
+}
+
+func (*AffinityProcessActionCommandSpec) Categories() []string {
+	return []string{category.SystemProcess}
+}
+
+// IsProcessHang returns true only if the values are re-applied periodically, otherwise the values are
+// changed once and the action returns at once
+func (*AffinityProcessActionCommandSpec) IsProcessHang(flags map[string]string) bool {
+	return flags["interval"] != ""
+}
+
+type AffinityProcessExecutor struct {
+	channel spec.Channel
+}
+
+func (ape *AffinityProcessExecutor) Name() string {
+	return "affinity"
+}
+
+// taskSchedule is the original cpu affinity and nice value of one thread
+type taskSchedule struct {
+	Pid  int   `json:"pid"`
+	Tid  int   `json:"tid"`
+	Cpus []int `json:"cpus"`
+	Nice int   `json:"nice"`
+}
+
+// affinityDetail is the detail of the record which keeps the affinity state
+const affinityDetail = "affinity"
+
+type affinityState struct {
+	Cpus  []int          `json:"cpus,omitempty"`
+	Nice  *int           `json:"nice,omitempty"`
+	Tasks []taskSchedule `json:"tasks"`
+}
+
+func (ape *AffinityProcessExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return ape.stop(ctx, uid)
+	}
+
+	state := &affinityState{Tasks: make([]taskSchedule, 0)}
+	cpuListStr := model.ActionFlags["cpu-list"]
+	if cpuListStr != "" {
+		cores, err := util.ParseIntegerListToStringSlice("cpu-list", cpuListStr)
+		if err != nil {
+			log.Errorf(ctx, "`%s`: cpu-list is illegal, %s", cpuListStr, err.Error())
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "cpu-list", cpuListStr, err.Error())
+		}
+		for _, core := range cores {
+			cpu, _ := strconv.Atoi(core)
+			state.Cpus = append(state.Cpus, cpu)
+		}
+	}
+	niceStr := model.ActionFlags["nice"]
+	if niceStr != "" {
+		nice, err := strconv.Atoi(niceStr)
+		if err != nil || nice < -20 || nice > 19 {
+			log.Errorf(ctx, "`%s`: nice is illegal, it must be an integer value from -20 to 19", niceStr)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "nice", niceStr, "it must be an integer value from -20 to 19")
+		}
+		state.Nice = &nice
+	}
+	if len(state.Cpus) == 0 && state.Nice == nil {
+		log.Errorf(ctx, "less cpu-list or nice flag value")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "cpu-list|nice")
+	}
+	interval := 0
+	intervalStr := model.ActionFlags["interval"]
+	if intervalStr != "" {
+		var err error
+		interval, err = strconv.Atoi(intervalStr)
+		if err != nil || interval < 1 {
+			log.Errorf(ctx, "`%s` value must be a positive integer", "interval")
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "interval", intervalStr, "it must be a positive integer")
+		}
+	}
+
+	resp := getPids(ctx, ape.channel, model, uid)
+	if !resp.Success {
+		return resp
+	}
+	pidsValue, ok := resp.Result.(string)
+	if !ok || pidsValue == "" {
+		return spec.ReturnSuccess(uid)
+	}
+	pids := make([]int, 0)
+	for _, p := range strings.Fields(pidsValue) {
+		pid, err := strconv.Atoi(p)
+		if err != nil {
+			log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("pid", p, err))
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "pid", p, err)
+		}
+		pids = append(pids, pid)
+	}
+	return ape.start(ctx, uid, pids, state, interval, model.ActionFlags)
+}
+
+func (ape *AffinityProcessExecutor) start(ctx context.Context, uid string, pids []int, state *affinityState, interval int,
+	flags map[string]string) *spec.Response {
+	record, response := exec.NewExperimentState(ctx, uid, "process", "affinity", flags)
+	if response != nil {
+		return response
+	}
+	if err := applyAffinity(ctx, record, pids, state, true); err != nil {
+		log.Errorf(ctx, "change affinity of %v failed, %v", pids, err)
+		restoreAffinity(ctx, uid)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("change affinity of %v failed, %v", pids, err))
+	}
+	// Without interval, it will not be executed regularly.
+	if interval < 1 {
+		return spec.ReturnSuccess(uid)
+	}
+
+	ticker := time.NewTicker(time.Second * time.Duration(interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := applyAffinity(ctx, record, pids, state, false); err != nil {
+				log.Warnf(ctx, "re-apply affinity of %v failed, %v", pids, err)
+			}
+		case <-ctx.Done():
+			return spec.ReturnSuccess(uid)
+		}
+	}
+}
+
+func (ape *AffinityProcessExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	// stop the re-apply loop first, otherwise it may change the restored values again
+	ctx = context.WithValue(ctx, "bin", AffinityProcessBin)
+	response := exec.Destroy(ctx, ape.channel, "process affinity")
+	if !response.Success {
+		return response
+	}
+	return restoreAffinity(ctx, uid)
+}
+
+func restoreAffinity(ctx context.Context, uid string) *spec.Response {
+	state, err := loadAffinityState(uid)
+	if err != nil {
+		log.Errorf(ctx, "read the original affinity of %s failed, %v", uid, err)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("read the original affinity of %s failed, %v", uid, err))
+	}
+	if state == nil {
+		exec.ReleaseResources(ctx, uid)
+		return spec.ReturnSuccess(uid)
+	}
+	originals := make(map[int]taskSchedule)
+	for _, task := range state.Tasks {
+		originals[task.Tid] = task
+	}
+	for _, task := range state.Tasks {
+		if task.Pid != task.Tid {
+			continue
+		}
+		tids, err := listTasks(task.Pid)
+		if err != nil {
+			log.Warnf(ctx, "list threads of %d failed, %v", task.Pid, err)
+			continue
+		}
+		for _, tid := range tids {
+			// the threads created during the experiment are restored to the values of the main thread
+			original, ok := originals[tid]
+			if !ok {
+				original = task
+			}
+			restoreTask(ctx, tid, original, state)
+		}
+	}
+	exec.ReleaseResources(ctx, uid)
+	return spec.ReturnSuccess(uid)
+}
+
+// applyAffinity applies the affinity and the nice value to all the threads of the pids, the original values
+// of the threads not seen before are recorded into the record of the experiment before changing them.
+func applyAffinity(ctx context.Context, record *exec.ExperimentState, pids []int, state *affinityState, first bool) error {
+	recorded := make(map[int]taskSchedule)
+	for _, task := range state.Tasks {
+		recorded[task.Tid] = task
+	}
+	type pending struct {
+		tid int
+		pid int
+	}
+	targets := make([]pending, 0)
+	changed := false
+	for _, pid := range pids {
+		tids, err := listTasks(pid)
+		if err != nil {
+			if first {
+				return err
+			}
+			continue
+		}
+		for _, tid := range tids {
+			targets = append(targets, pending{tid: tid, pid: pid})
+			if _, ok := recorded[tid]; ok {
+				continue
+			}
+			original, ok := recorded[pid]
+			if first || !ok {
+				cpus, err := getTaskAffinity(tid)
+				if err != nil {
+					return err
+				}
+				nice, err := getTaskNice(tid)
+				if err != nil {
+					return err
+				}
+				original = taskSchedule{Cpus: cpus, Nice: nice}
+			}
+			original.Pid = pid
+			original.Tid = tid
+			recorded[tid] = original
+			state.Tasks = append(state.Tasks, original)
+			changed = true
+		}
+	}
+	if changed {
+		if err := recordAffinityState(record, state); err != nil {
+			return err
+		}
+	}
+	for _, target := range targets {
+		if len(state.Cpus) > 0 {
+			if err := setTaskAffinity(target.tid, state.Cpus); err != nil {
+				if first {
+					return err
+				}
+				log.Warnf(ctx, "set affinity of thread %d of %d failed, %v", target.tid, target.pid, err)
+			}
+		}
+		if state.Nice != nil {
+			if err := setTaskNice(target.tid, *state.Nice); err != nil {
+				if first {
+					return err
+				}
+				log.Warnf(ctx, "set nice of thread %d of %d failed, %v", target.tid, target.pid, err)
+			}
+		}
+	}
+	return nil
+}
+
+func restoreTask(ctx context.Context, tid int, original taskSchedule, state *affinityState) {
+	if len(state.Cpus) > 0 && len(original.Cpus) > 0 {
+		if err := setTaskAffinity(tid, original.Cpus); err != nil {
+			log.Warnf(ctx, "restore affinity of thread %d failed, %v", tid, err)
+		}
+	}
+	if state.Nice != nil {
+		if err := setTaskNice(tid, original.Nice); err != nil {
+			log.Warnf(ctx, "restore nice of thread %d failed, %v", tid, err)
+		}
+	}
+}
+
+func recordAffinityState(record *exec.ExperimentState, state *affinityState) error {
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return record.SetDetail(affinityDetail, string(bytes))
+}
+
+// loadAffinityState returns the original values to restore, it's nil if there is no record or the experiment
+// is destroyed already
+func loadAffinityState(uid string) (*affinityState, error) {
+	record, err := exec.LoadState(uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if record.Destroyed || record.Details[affinityDetail] == "" {
+		return nil, nil
+	}
+	state := &affinityState{}
+	err = json.Unmarshal([]byte(record.Details[affinityDetail]), state)
+	return state, err
+}
+
+func (ape *AffinityProcessExecutor) SetChannel(channel spec.Channel) {
+	ape.channel = channel
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"errors"
+)
+
+// darwin does not support setting the cpu affinity or the priority of a thread of other process
+var errAffinityNotSupported = errors.New("changing the affinity of other process is not supported on darwin")
+
+func listTasks(pid int) ([]int, error) {
+	return nil, errAffinityNotSupported
+}
+
+func getTaskAffinity(tid int) ([]int, error) {
+	return nil, errAffinityNotSupported
+}
+
+func setTaskAffinity(tid int, cpus []int) error {
+	return errAffinityNotSupported
+}
+
+func getTaskNice(tid int) (int, error) {
+	return 0, errAffinityNotSupported
+}
+
+func setTaskNice(tid int, nice int) error {
+	return errAffinityNotSupported
+}
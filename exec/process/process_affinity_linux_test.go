@@ -0,0 +1,139 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	osExec "os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func execAffinity(flags map[string]string) *spec.Response {
+	executor := &AffinityProcessExecutor{}
+	executor.SetChannel(exec.NewMockChannel())
+	return executor.Exec("affinity-flags", context.Background(),
+		&spec.ExpModel{Target: "process", ActionName: "affinity", ActionFlags: flags})
+}
+
+func TestAffinityProcessExecutorFlags(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	cases := []struct {
+		flags map[string]string
+		code  int32
+	}{
+		{flags: map[string]string{"pid": "100", "cpu-list": "a-b"}, code: spec.ParameterIllegal.Code},
+		{flags: map[string]string{"pid": "100", "nice": "20"}, code: spec.ParameterIllegal.Code},
+		{flags: map[string]string{"pid": "100", "nice": "x"}, code: spec.ParameterIllegal.Code},
+		{flags: map[string]string{"pid": "100", "nice": "5", "interval": "0"}, code: spec.ParameterIllegal.Code},
+		{flags: map[string]string{"pid": "100"}, code: spec.ParameterLess.Code},
+	}
+	for _, c := range cases {
+		if response := execAffinity(c.flags); response.Success || response.Code != c.code {
+			t.Errorf("expected the code %d of %v, got %+v", c.code, c.flags, response)
+		}
+	}
+}
+
+func TestLoadAffinityState(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	nice := 5
+	state := &affinityState{Cpus: []int{0}, Nice: &nice, Tasks: []taskSchedule{{Pid: 100, Tid: 100, Cpus: []int{0, 1}}}}
+	record, response := exec.NewExperimentState(ctx, "affinity-1", "process", "affinity", nil)
+	if response != nil {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if err := recordAffinityState(record, state); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadAffinityState("affinity-1")
+	if err != nil || !reflect.DeepEqual(loaded, state) {
+		t.Errorf("expected the recorded state, got %+v, %v", loaded, err)
+	}
+
+	// the destroyed experiment has nothing to restore
+	exec.ReleaseResources(ctx, "affinity-1")
+	if loaded, err := loadAffinityState("affinity-1"); err != nil || loaded != nil {
+		t.Errorf("expected nothing to restore after the destroy, got %+v, %v", loaded, err)
+	}
+	if loaded, err := loadAffinityState("affinity-none"); err != nil || loaded != nil {
+		t.Errorf("expected nothing to restore without the record, got %+v, %v", loaded, err)
+	}
+}
+
+func TestAffinityProcessExecutor(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	cmd := osExec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("start sleep failed, %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	pid := cmd.Process.Pid
+	cpus, err := getTaskAffinity(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// raising the nice value is always permitted, it's kept since the nice flag isn't specified
+	if err := setTaskNice(pid, 3); err != nil {
+		t.Skipf("set nice of %d failed, %v", pid, err)
+	}
+
+	ctx := context.WithValue(context.Background(), spec.Uid, "affinity-2")
+	executor := &AffinityProcessExecutor{}
+	executor.SetChannel(exec.NewMockChannel())
+	state := &affinityState{Cpus: []int{cpus[0]}, Tasks: make([]taskSchedule, 0)}
+	if response := executor.start(ctx, "affinity-2", []int{pid}, state, 0, nil); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if changed, err := getTaskAffinity(pid); err != nil || !reflect.DeepEqual(changed, []int{cpus[0]}) {
+		t.Errorf("expected the affinity %d, got %v, %v", cpus[0], changed, err)
+	}
+
+	if response := executor.stop(ctx, "affinity-2"); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	if restored, err := getTaskAffinity(pid); err != nil || !reflect.DeepEqual(restored, cpus) {
+		t.Errorf("expected the affinity %v is restored, got %v, %v", cpus, restored, err)
+	}
+	if nice, err := getTaskNice(pid); err != nil || nice != 3 {
+		t.Errorf("expected the nice value 3 is kept, got %d, %v", nice, err)
+	}
+	if record, err := exec.LoadState("affinity-2"); err != nil || !record.Destroyed {
+		t.Errorf("expected the record is destroyed, got %+v, %v", record, err)
+	}
+}
+
+func TestAffinityIsProcessHang(t *testing.T) {
+	checker, ok := NewAffinityProcessActionCommandSpec().(exec.ProcessHangChecker)
+	if !ok {
+		t.Fatal("expected the affinity decides the hanging mode by the flags")
+	}
+	if checker.IsProcessHang(map[string]string{"cpu-list": "0"}) {
+		t.Errorf("expected the one-shot change returns at once")
+	}
+	if !checker.IsProcessHang(map[string]string{"cpu-list": "0", "interval": "5"}) {
+		t.Errorf("expected the periodic re-apply keeps running")
+	}
+}
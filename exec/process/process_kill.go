@@ -18,7 +18,7 @@ package process
 
 import (
 	"context"
-	"fmt"
+	"strings"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
@@ -64,8 +64,42 @@ func NewKillProcessActionCommandSpec() spec.ExpActionCommandSpec {
 					Name: "pid",
 					Desc: "pid",
 				},
+				&spec.ExpFlag{
+					Name: "user",
+					Desc: "Only match the processes of the effective user, the user name or the numeric uid",
+				},
+				&spec.ExpFlag{
+					Name:   "allow-root-processes",
+					Desc:   "Confirm matching the processes of root by the user flag",
+					NoArgs: true,
+				},
+				&spec.ExpFlag{
+					Name: "pids",
+					Desc: "Pid list separated by commas (,), kill these processes exactly without process matching, cannot be used with other matchers",
+				},
+			},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:   "kill-tree",
+					Desc:   "Kill all the descendants of the matched processes too",
+					NoArgs: true,
+				},
+				&spec.ExpFlag{
+					Name:   "use-pgid",
+					Desc:   "Kill the process group of the matched processes instead of the descendants, used with kill-tree",
+					NoArgs: true,
+				},
+				&spec.ExpFlag{
+					Name:    "signal-order",
+					Desc:    "Signal order of the process tree, bottom-up signals the children before the parent, top-down is the opposite",
+					Default: SignalOrderBottomUp,
+				},
+				&spec.ExpFlag{
+					Name:   "dry-run",
+					Desc:   "Only return the processes which would be affected, change nothing",
+					NoArgs: true,
+				},
 			},
-			ActionFlags:    []spec.ExpFlagSpec{},
 			ActionExecutor: &KillProcessExecutor{},
 			ActionExample: `
 # Kill the process that contains the SimpleHTTPServer keyword
@@ -77,6 +111,18 @@ blade create process kill --process-cmd java
 # Specifies the semaphore and local port to kill the process
 blade c process kill --local-port 8080 --signal 15
 
+# Kill the exact processes and report the result of each pid
+blade c process kill --pids 1234,5678 --signal 9
+
+# Kill the java processes of the user etl
+blade c process kill --process-cmd java --user etl
+
+# Kill the gunicorn master and all of its workers, the workers are killed first
+blade c process kill --process gunicorn --signal 15 --kill-tree
+
+# Preview the processes which would be killed
+blade c process kill --process-cmd java --dry-run
+
 # Return success even if the process not found
 blade c process kill --process demo --ignore-not-found`,
 			ActionPrograms:   []string{KillProcessBin},
@@ -121,17 +167,37 @@ func (kpe *KillProcessExecutor) Exec(uid string, ctx context.Context, model *spe
 		return spec.ReturnSuccess(uid)
 	}
 
+	signal := model.ActionFlags["signal"]
+	if signal == "" {
+		log.Errorf(ctx, "less signal flag value")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "signal")
+	}
+	if pidsValue := model.ActionFlags["pids"]; pidsValue != "" {
+		return kpe.killPids(ctx, pidsValue, signal, model)
+	}
+
 	resp := getPids(ctx, kpe.channel, model, uid)
 	if !resp.Success {
 		return resp
 	}
 	pids := resp.Result.(string)
-	signal := model.ActionFlags["signal"]
-	if signal == "" {
-		log.Errorf(ctx, "less signal flag value")
-		return spec.ResponseFailWithFlags(spec.ParameterLess, "signal")
+	if model.ActionFlags["kill-tree"] == "true" {
+		sig, err := parseSignal(signal)
+		if err != nil {
+			log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("signal", signal, err))
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "signal", signal, err)
+		}
+		roots, err := parsePidList(strings.Join(strings.Fields(pids), ","))
+		if err != nil {
+			log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("pid", pids, err))
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "pid", pids, err)
+		}
+		return killTrees(ctx, kpe.channel, roots, sig, model)
+	}
+	if model.ActionFlags["dry-run"] == "true" {
+		return previewPids(ctx, kpe.channel, strings.Fields(pids))
 	}
-	return kpe.channel.Run(ctx, "kill", fmt.Sprintf("-%s %s", signal, pids))
+	return killProcesses(ctx, kpe.channel, signal, pids)
 }
 
 func (kpe *KillProcessExecutor) SetChannel(channel spec.Channel) {
@@ -0,0 +1,185 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	gopsProcess "github.com/shirou/gopsutil/process"
+)
+
+const (
+	PidKilled           = "killed"
+	PidNoSuchProcess    = "no-such-process"
+	PidPermissionDenied = "permission-denied"
+	PidKillFailed       = "failed"
+	PidReused           = "skipped-pid-reused"
+)
+
+// PidKillResult is the kill result of one pid in the pids flag
+type PidKillResult struct {
+	Pid    int    `json:"pid"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// killPids sends the signal to the explicit pid list without any process matching
+func (kpe *KillProcessExecutor) killPids(ctx context.Context, pidsValue, signal string, model *spec.ExpModel) *spec.Response {
+	for _, flag := range []string{"process", "process-cmd", "local-port", "pid", "user"} {
+		if model.ActionFlags[flag] != "" {
+			log.Errorf(ctx, "the pids flag cannot be used together with the %s flag", flag)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "pids", pidsValue,
+				fmt.Sprintf("cannot be used together with the %s flag", flag))
+		}
+	}
+	sig, err := parseSignal(signal)
+	if err != nil {
+		log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("signal", signal, err))
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "signal", signal, err)
+	}
+	pids, err := parsePidList(pidsValue)
+	if err != nil {
+		log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("pids", pidsValue, err))
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "pids", pidsValue, err)
+	}
+	protected := getProtectedPids(ctx)
+	for _, pid := range pids {
+		if protected[pid] {
+			log.Errorf(ctx, "pid %d is the init process, the chaos process or an ancestor of it", pid)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "pids", pidsValue,
+				fmt.Sprintf("pid %d is the init process, the chaos process or an ancestor of it", pid))
+		}
+	}
+	if model.ActionFlags["kill-tree"] == "true" {
+		return killTrees(ctx, kpe.channel, pids, sig, model)
+	}
+	if model.ActionFlags["dry-run"] == "true" {
+		values := make([]string, 0, len(pids))
+		for _, pid := range pids {
+			values = append(values, strconv.Itoa(pid))
+		}
+		return previewPids(ctx, kpe.channel, values)
+	}
+
+	results := make([]PidKillResult, 0, len(pids))
+	killed := 0
+	notFound := 0
+	for _, pid := range pids {
+		result := signalPid(ctx, pid, sig)
+		switch result.Status {
+		case PidKilled:
+			killed++
+		case PidNoSuchProcess:
+			notFound++
+		}
+		results = append(results, result)
+	}
+	ignoreProcessNotFound := model.ActionFlags["ignore-not-found"] == "true"
+	if killed == 0 && !(ignoreProcessNotFound && notFound == len(pids)) {
+		bytes, _ := json.Marshal(results)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("no process is killed, %s", string(bytes)))
+	}
+	return spec.ReturnSuccess(results)
+}
+
+func signalPid(ctx context.Context, pid int, sig syscall.Signal) PidKillResult {
+	result := PidKillResult{Pid: pid, Status: PidKilled}
+	if err := killPid(pid, sig); err != nil {
+		result.Error = err.Error()
+		switch err {
+		case syscall.ESRCH:
+			result.Status = PidNoSuchProcess
+		case syscall.EPERM:
+			result.Status = PidPermissionDenied
+		default:
+			result.Status = PidKillFailed
+		}
+		log.Warnf(ctx, "kill -%d %d failed, %v", sig, pid, err)
+	}
+	return result
+}
+
+// parseSignal accepts the signal number, or the name with or without the SIG prefix
+func parseSignal(signal string) (syscall.Signal, error) {
+	if number, err := strconv.Atoi(signal); err == nil {
+		if number <= 0 {
+			return 0, fmt.Errorf("signal must be a positive integer")
+		}
+		return syscall.Signal(number), nil
+	}
+	name := strings.ToUpper(signal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig := signalNum(name)
+	if sig == 0 {
+		return 0, fmt.Errorf("unknown signal")
+	}
+	return sig, nil
+}
+
+func parsePidList(pidsValue string) ([]int, error) {
+	pids := make([]int, 0)
+	seen := make(map[int]bool)
+	for _, value := range strings.Split(pidsValue, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		pid, err := strconv.Atoi(value)
+		if err != nil || pid <= 0 {
+			return nil, fmt.Errorf("`%s` is not a valid pid", value)
+		}
+		if !seen[pid] {
+			seen[pid] = true
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("empty pid list")
+	}
+	return pids, nil
+}
+
+// getProtectedPids returns the init process, the chaos process and all of its ancestors
+func getProtectedPids(ctx context.Context) map[int]bool {
+	protected := map[int]bool{1: true}
+	pid := os.Getpid()
+	for pid > 1 && !protected[pid] {
+		protected[pid] = true
+		p, err := gopsProcess.NewProcess(int32(pid))
+		if err != nil {
+			log.Warnf(ctx, "get process %d failed, %v", pid, err)
+			break
+		}
+		ppid, err := p.Ppid()
+		if err != nil {
+			log.Warnf(ctx, "get parent of process %d failed, %v", pid, err)
+			break
+		}
+		pid = int(ppid)
+	}
+	return protected
+}
@@ -0,0 +1,140 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"os"
+	osExec "os/exec"
+	"reflect"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func execKillPids(pids string, flags map[string]string) *spec.Response {
+	executor := &KillProcessExecutor{}
+	executor.SetChannel(exec.NewMockChannel())
+	actionFlags := map[string]string{"pids": pids, "signal": "9"}
+	for key, value := range flags {
+		actionFlags[key] = value
+	}
+	return executor.Exec("kill-pids", context.Background(),
+		&spec.ExpModel{Target: "process", ActionName: "kill", ActionFlags: actionFlags})
+}
+
+// exitedPid returns the pid of a process which has exited
+func exitedPid(t *testing.T) int {
+	cmd := osExec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("run true failed, %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestParseSignal(t *testing.T) {
+	cases := map[string]syscall.Signal{"9": syscall.SIGKILL, "TERM": syscall.SIGTERM, "sigint": syscall.SIGINT}
+	for signal, expected := range cases {
+		if sig, err := parseSignal(signal); err != nil || sig != expected {
+			t.Errorf("expected %s is %d, got %d, %v", signal, expected, sig, err)
+		}
+	}
+	for _, signal := range []string{"0", "-9", "SIGFOO"} {
+		if _, err := parseSignal(signal); err == nil {
+			t.Errorf("expected the failure of the signal %s", signal)
+		}
+	}
+}
+
+func TestParsePidList(t *testing.T) {
+	pids, err := parsePidList(" 100, 200,,100 ")
+	if err != nil || !reflect.DeepEqual(pids, []int{100, 200}) {
+		t.Errorf("expected the distinct pids, got %v, %v", pids, err)
+	}
+	for _, value := range []string{"100,abc", "0", "-1", " , "} {
+		if _, err := parsePidList(value); err == nil {
+			t.Errorf("expected the failure of the pids %q", value)
+		}
+	}
+}
+
+func TestGetProtectedPids(t *testing.T) {
+	protected := getProtectedPids(context.Background())
+	for _, pid := range []int{1, os.Getpid(), os.Getppid()} {
+		if !protected[pid] {
+			t.Errorf("expected the pid %d is protected, got %v", pid, protected)
+		}
+	}
+}
+
+func TestKillPids(t *testing.T) {
+	cmd := osExec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("start sleep failed, %v", err)
+	}
+	defer cmd.Process.Kill()
+	exited := exitedPid(t)
+
+	response := execKillPids(strconv.Itoa(cmd.Process.Pid)+","+strconv.Itoa(exited), nil)
+	if !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	expected := []PidKillResult{
+		{Pid: cmd.Process.Pid, Status: PidKilled},
+		{Pid: exited, Status: PidNoSuchProcess, Error: syscall.ESRCH.Error()},
+	}
+	if !reflect.DeepEqual(response.Result, expected) {
+		t.Errorf("unexpected results: %+v", response.Result)
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Errorf("expected the sleep is killed")
+	}
+}
+
+func TestKillPidsNotKilled(t *testing.T) {
+	exited := strconv.Itoa(exitedPid(t))
+	if response := execKillPids(exited, nil); response.Success {
+		t.Errorf("expected the failure without any process killed")
+	}
+	if response := execKillPids(exited, map[string]string{"ignore-not-found": "true"}); !response.Success {
+		t.Errorf("expected the success with ignore-not-found, %s", response.Err)
+	}
+}
+
+func TestKillPidsIllegal(t *testing.T) {
+	cases := []struct {
+		pids  string
+		flags map[string]string
+	}{
+		{pids: "100", flags: map[string]string{"process": "nginx"}},
+		{pids: "100", flags: map[string]string{"signal": "SIGFOO"}},
+		{pids: "abc"},
+		{pids: "1"},
+		{pids: strconv.Itoa(os.Getpid())},
+	}
+	for _, c := range cases {
+		if response := execKillPids(c.pids, c.flags); response.Success || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the illegal pids %s with %v, got %+v", c.pids, c.flags, response)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	gopsProcess "github.com/shirou/gopsutil/process"
+)
+
+const (
+	SignalOrderBottomUp = "bottom-up"
+	SignalOrderTopDown  = "top-down"
+)
+
+// TreeKillResult is the kill result of all the processes in the tree of the matched root
+type TreeKillResult struct {
+	Root int             `json:"root"`
+	Pids []PidKillResult `json:"pids"`
+}
+
+// treeNode is the snapshot of one process, used to detect the pid reuse before signaling
+type treeNode struct {
+	pid        int
+	ppid       int
+	pgid       int
+	createTime int64
+}
+
+// killTrees signals the process tree, or the process group, of every root
+func killTrees(ctx context.Context, cl spec.Channel, roots []int, sig syscall.Signal, model *spec.ExpModel) *spec.Response {
+	order := model.ActionFlags["signal-order"]
+	if order == "" {
+		order = SignalOrderBottomUp
+	}
+	if order != SignalOrderBottomUp && order != SignalOrderTopDown {
+		log.Errorf(ctx, "`%s`: signal-order is illegal", order)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "signal-order", order,
+			fmt.Sprintf("it must be %s or %s", SignalOrderBottomUp, SignalOrderTopDown))
+	}
+	usePgid := model.ActionFlags["use-pgid"] == "true"
+
+	nodes, children, err := snapshotProcessTree()
+	if err != nil {
+		log.Errorf(ctx, "get process tree failed, %v", err)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("get process tree failed, %v", err))
+	}
+	protected := getProtectedPids(ctx)
+	trees := make([][]treeNode, 0, len(roots))
+	for _, root := range roots {
+		var tree []treeNode
+		if usePgid {
+			tree = collectProcessGroup(root, nodes, children)
+		} else {
+			tree = collectDescendants(root, nodes, children)
+		}
+		// never signal the chaos process or its ancestors through the tree
+		filtered := make([]treeNode, 0, len(tree))
+		for _, node := range tree {
+			if !protected[node.pid] || node.pid == root {
+				filtered = append(filtered, node)
+			}
+		}
+		if order == SignalOrderBottomUp {
+			for i, j := 0, len(filtered)-1; i < j; i, j = i+1, j-1 {
+				filtered[i], filtered[j] = filtered[j], filtered[i]
+			}
+		}
+		trees = append(trees, filtered)
+	}
+
+	if model.ActionFlags["dry-run"] == "true" {
+		pids := make([]string, 0)
+		for _, tree := range trees {
+			for _, node := range tree {
+				pids = append(pids, strconv.Itoa(node.pid))
+			}
+		}
+		return previewPids(ctx, cl, pids)
+	}
+
+	results := make([]TreeKillResult, 0, len(roots))
+	killed := 0
+	for i, tree := range trees {
+		result := TreeKillResult{Root: roots[i], Pids: make([]PidKillResult, 0, len(tree))}
+		signaled := make(map[int]bool)
+		for _, node := range tree {
+			if !isSameProcess(node, usePgid, signaled[node.ppid]) {
+				result.Pids = append(result.Pids, PidKillResult{Pid: node.pid, Status: PidReused})
+				continue
+			}
+			pidResult := signalPid(ctx, node.pid, sig)
+			signaled[node.pid] = true
+			if pidResult.Status == PidKilled {
+				killed++
+			}
+			result.Pids = append(result.Pids, pidResult)
+		}
+		results = append(results, result)
+	}
+	if killed == 0 && model.ActionFlags["ignore-not-found"] != "true" {
+		bytes, _ := json.Marshal(results)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("no process is killed, %s", string(bytes)))
+	}
+	return spec.ReturnSuccess(results)
+}
+
+// snapshotProcessTree returns all the processes and the children of each pid
+func snapshotProcessTree() (map[int]treeNode, map[int][]int, error) {
+	processes, err := gopsProcess.Processes()
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes := make(map[int]treeNode, len(processes))
+	children := make(map[int][]int)
+	for _, p := range processes {
+		node, err := getTreeNode(p)
+		if err != nil {
+			continue
+		}
+		nodes[node.pid] = node
+		children[node.ppid] = append(children[node.ppid], node.pid)
+	}
+	return nodes, children, nil
+}
+
+func getTreeNode(p *gopsProcess.Process) (treeNode, error) {
+	ppid, err := p.Ppid()
+	if err != nil {
+		return treeNode{}, err
+	}
+	createTime, err := p.CreateTime()
+	if err != nil {
+		return treeNode{}, err
+	}
+	pgid, err := getpgid(int(p.Pid))
+	if err != nil {
+		return treeNode{}, err
+	}
+	return treeNode{pid: int(p.Pid), ppid: int(ppid), pgid: pgid, createTime: createTime}, nil
+}
+
+// collectDescendants returns the root and its descendants from top to bottom
+func collectDescendants(root int, nodes map[int]treeNode, children map[int][]int) []treeNode {
+	rootNode, ok := nodes[root]
+	if !ok {
+		return []treeNode{{pid: root, ppid: -1}}
+	}
+	tree := []treeNode{rootNode}
+	visited := map[int]bool{root: true}
+	for i := 0; i < len(tree); i++ {
+		for _, child := range children[tree[i].pid] {
+			if visited[child] {
+				continue
+			}
+			visited[child] = true
+			tree = append(tree, nodes[child])
+		}
+	}
+	return tree
+}
+
+// collectProcessGroup returns the members of the process group of the root, the members in the tree
+// of the root are ordered from top to bottom, and the others are placed after the root
+func collectProcessGroup(root int, nodes map[int]treeNode, children map[int][]int) []treeNode {
+	rootNode, ok := nodes[root]
+	if !ok {
+		return []treeNode{{pid: root, ppid: -1}}
+	}
+	group := make([]treeNode, 0)
+	inGroup := make(map[int]bool)
+	for _, node := range collectDescendants(root, nodes, children) {
+		if node.pgid == rootNode.pgid {
+			group = append(group, node)
+			inGroup[node.pid] = true
+		}
+	}
+	others := make([]treeNode, 0)
+	for _, node := range nodes {
+		if node.pgid == rootNode.pgid && !inGroup[node.pid] {
+			others = append(others, node)
+		}
+	}
+	members := make([]treeNode, 0, len(group)+len(others))
+	members = append(members, group[0])
+	members = append(members, others...)
+	return append(members, group[1:]...)
+}
+
+// isSameProcess re-validates the process before signaling, the pid may be reused by a new process
+// since the snapshot was taken, which has another create time or parent. The parent link is not
+// checked if the parent has been signaled, the process may have been reparented when it exited.
+func isSameProcess(node treeNode, usePgid bool, parentSignaled bool) bool {
+	if node.ppid < 0 {
+		// the root not found in the snapshot, signal it as is
+		return true
+	}
+	p, err := gopsProcess.NewProcess(int32(node.pid))
+	if err != nil {
+		// let the signal report no such process
+		return true
+	}
+	current, err := getTreeNode(p)
+	if err != nil {
+		return true
+	}
+	if current.createTime != node.createTime {
+		return false
+	}
+	if usePgid {
+		return current.pgid == node.pgid
+	}
+	return current.ppid == node.ppid || parentSignaled
+}
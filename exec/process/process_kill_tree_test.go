@@ -0,0 +1,153 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"os"
+	osExec "os/exec"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	gopsProcess "github.com/shirou/gopsutil/process"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+// the tree of 10 is 10 -> 11, 12 -> 13, the process 20 is in the group of 10 out of the tree,
+// and the process 12 has its own group
+var (
+	testTreeNodes = map[int]treeNode{
+		10: {pid: 10, ppid: 1, pgid: 10},
+		11: {pid: 11, ppid: 10, pgid: 10},
+		12: {pid: 12, ppid: 10, pgid: 12},
+		13: {pid: 13, ppid: 12, pgid: 10},
+		20: {pid: 20, ppid: 1, pgid: 10},
+	}
+	testTreeChildren = map[int][]int{1: {10, 20}, 10: {11, 12}, 12: {13}}
+)
+
+func treePids(tree []treeNode) []int {
+	pids := make([]int, 0, len(tree))
+	for _, node := range tree {
+		pids = append(pids, node.pid)
+	}
+	return pids
+}
+
+func TestCollectDescendants(t *testing.T) {
+	if pids := treePids(collectDescendants(10, testTreeNodes, testTreeChildren)); !reflect.DeepEqual(pids, []int{10, 11, 12, 13}) {
+		t.Errorf("expected the tree from top to bottom, got %v", pids)
+	}
+	tree := collectDescendants(99, testTreeNodes, testTreeChildren)
+	if len(tree) != 1 || tree[0].pid != 99 || tree[0].ppid != -1 {
+		t.Errorf("expected the root not found only, got %+v", tree)
+	}
+}
+
+func TestCollectProcessGroup(t *testing.T) {
+	if pids := treePids(collectProcessGroup(10, testTreeNodes, testTreeChildren)); !reflect.DeepEqual(pids, []int{10, 20, 11, 13}) {
+		t.Errorf("expected the group members, got %v", pids)
+	}
+}
+
+func TestIsSameProcess(t *testing.T) {
+	p, err := gopsProcess.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		t.Skipf("get the current process failed, %v", err)
+	}
+	node, err := getTreeNode(p)
+	if err != nil {
+		t.Skipf("get the tree node of the current process failed, %v", err)
+	}
+	if !isSameProcess(node, false, false) || !isSameProcess(node, true, false) {
+		t.Errorf("expected the same process of the snapshot %+v", node)
+	}
+	reused := node
+	reused.createTime++
+	if isSameProcess(reused, false, true) {
+		t.Errorf("expected the reused pid by the create time")
+	}
+	reparented := node
+	reparented.ppid++
+	if isSameProcess(reparented, false, false) {
+		t.Errorf("expected the reused pid by the parent")
+	}
+	if !isSameProcess(reparented, false, true) {
+		t.Errorf("expected the process reparented after the parent is signaled")
+	}
+	regrouped := node
+	regrouped.pgid++
+	if isSameProcess(regrouped, true, false) {
+		t.Errorf("expected the reused pid by the process group")
+	}
+	if !isSameProcess(treeNode{pid: node.pid, ppid: -1}, false, false) {
+		t.Errorf("expected the root not found is signaled as is")
+	}
+}
+
+func TestKillTrees(t *testing.T) {
+	cmd := osExec.Command("sh", "-c", "sleep 30 & wait")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("start sh failed, %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	root := cmd.Process.Pid
+	var child int
+	for i := 0; i < 50 && child == 0; i++ {
+		_, children, err := snapshotProcessTree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(children[root]) > 0 {
+			child = children[root][0]
+		} else {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	if child == 0 {
+		t.Skip("the sleep of the tree is not started")
+	}
+
+	cl := exec.NewMockChannel()
+	model := &spec.ExpModel{ActionFlags: map[string]string{"signal-order": "random"}}
+	if response := killTrees(context.Background(), cl, []int{root}, syscall.SIGKILL, model); response.Success ||
+		response.Code != spec.ParameterIllegal.Code {
+		t.Errorf("expected the illegal signal order, got %+v", response)
+	}
+
+	model = &spec.ExpModel{ActionFlags: map[string]string{}}
+	response := killTrees(context.Background(), cl, []int{root}, syscall.SIGKILL, model)
+	if !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	expected := []TreeKillResult{{Root: root, Pids: []PidKillResult{
+		{Pid: child, Status: PidKilled},
+		{Pid: root, Status: PidKilled},
+	}}}
+	if !reflect.DeepEqual(response.Result, expected) {
+		t.Errorf("expected the tree is killed from bottom to top, got %+v", response.Result)
+	}
+}
@@ -0,0 +1,269 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const LimitProcessBin = "chaos_limitprocess"
+
+// limitsDetail is the detail of the record which keeps the original limits
+const limitsDetail = "limits"
+
+// limitResources lists the supported flags in the order they are applied
+var limitResources = []string{"nofile", "nproc", "fsize"}
+
+type LimitProcessActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewLimitProcessActionCommandSpec() spec.ExpActionCommandSpec {
+	return &LimitProcessActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "process",
+					Desc: "Process name",
+				},
+				&spec.ExpFlag{
+					Name: "process-cmd",
+					Desc: "Process name in command",
+				},
+				&spec.ExpFlag{
+					Name: "count",
+					Desc: "Limit count, 0 means unlimited",
+				},
+				&spec.ExpFlag{
+					Name: "local-port",
+					Desc: "Local service ports. Separate multiple ports with commas (,) or connector representing ranges, for example: 80,8000-8080",
+				},
+				&spec.ExpFlag{
+					Name: "exclude-process",
+					Desc: "Exclude process",
+				},
+				&spec.ExpFlag{
+					Name: "pid",
+					Desc: "pid",
+				},
+			},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "nofile",
+					Desc: "Max number of open file descriptors (RLIMIT_NOFILE), sets both soft and hard limit",
+				},
+				&spec.ExpFlag{
+					Name: "nproc",
+					Desc: "Max number of processes of the process owner (RLIMIT_NPROC), sets both soft and hard limit",
+				},
+				&spec.ExpFlag{
+					Name: "fsize",
+					Desc: "Max file size in bytes the process may create (RLIMIT_FSIZE), sets both soft and hard limit",
+				},
+			},
+			ActionExecutor: &LimitProcessExecutor{},
+			ActionExample: `
+# Limit the open file descriptors of the nginx process to 64
+blade create process limit --process nginx --nofile 64
+
+# Limit the processes and the file size of the process 1234
+blade create process limit --pid 1234 --nproc 10 --fsize 1048576`,
+			ActionPrograms:   []string{LimitProcessBin},
+			ActionCategories: []string{category.SystemProcess},
+		},
+	}
+}
+
+func (*LimitProcessActionCommandSpec) Name() string {
+	return "limit"
+}
+
+func (*LimitProcessActionCommandSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*LimitProcessActionCommandSpec) ShortDesc() string {
+	return "Lower resource limits of process"
+}
+
+func (l *LimitProcessActionCommandSpec) LongDesc() string {
+	if l.ActionLongDesc != "" {
+		return l.ActionLongDesc
+	}
+	return "Lower the resource limits, such as nofile, nproc and fsize, of the running process by prlimit. " +
+		"The original limits are recorded and restored when the experiment is destroyed"
+}
+
+func (*LimitProcessActionCommandSpec) Categories() []string {
+	return []string{category.SystemProcess}
+}
+
+type LimitProcessExecutor struct {
+	channel spec.Channel
+}
+
+func (lpe *LimitProcessExecutor) Name() string {
+	return "limit"
+}
+
+// processLimit is the original limit of one resource of one process
+type processLimit struct {
+	Pid      int    `json:"pid"`
+	Resource string `json:"resource"`
+	Soft     uint64 `json:"soft"`
+	Hard     uint64 `json:"hard"`
+}
+
+func (lpe *LimitProcessExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return lpe.stop(ctx, uid)
+	}
+
+	limits := make(map[string]uint64)
+	for _, resource := range limitResources {
+		value := model.ActionFlags[resource]
+		if value == "" {
+			continue
+		}
+		limit, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf(resource, value, err))
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, resource, value, err)
+		}
+		limits[resource] = limit
+	}
+	if len(limits) == 0 {
+		log.Errorf(ctx, "less nofile, nproc or fsize flag value")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, strings.Join(limitResources, "|"))
+	}
+
+	resp := getPids(ctx, lpe.channel, model, uid)
+	if !resp.Success {
+		return resp
+	}
+	pidsValue, ok := resp.Result.(string)
+	if !ok || pidsValue == "" {
+		return spec.ReturnSuccess(uid)
+	}
+	return lpe.start(ctx, uid, strings.Fields(pidsValue), limits, model.ActionFlags)
+}
+
+func (lpe *LimitProcessExecutor) start(ctx context.Context, uid string, pids []string, limits map[string]uint64,
+	flags map[string]string) *spec.Response {
+	originals := make([]processLimit, 0)
+	for _, p := range pids {
+		pid, err := strconv.Atoi(p)
+		if err != nil {
+			log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("pid", p, err))
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "pid", p, err)
+		}
+		for _, resource := range limitResources {
+			if _, ok := limits[resource]; !ok {
+				continue
+			}
+			soft, hard, err := getProcessLimit(pid, resource)
+			if err != nil {
+				log.Errorf(ctx, "get %s limit of %d failed, %v", resource, pid, err)
+				return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("get %s limit of %d failed, %v", resource, pid, err))
+			}
+			originals = append(originals, processLimit{Pid: pid, Resource: resource, Soft: soft, Hard: hard})
+		}
+	}
+	// record the original limits before changing anything, so that destroy can always recover
+	state, response := exec.NewExperimentState(ctx, uid, "process", "limit", flags)
+	if response != nil {
+		return response
+	}
+	bytes, _ := json.Marshal(originals)
+	if err := state.SetDetail(limitsDetail, string(bytes)); err != nil {
+		return exec.Fail(exec.StateRecordFailed, "limit", "record", fmt.Sprintf("record the original limits failed, %v", err))
+	}
+	for _, original := range originals {
+		limit := limits[original.Resource]
+		if original.Resource == "nofile" {
+			warnIfBelowOpenFds(ctx, original.Pid, limit)
+		}
+		if err := setProcessLimit(original.Pid, original.Resource, limit, limit); err != nil {
+			log.Errorf(ctx, "set %s limit of %d to %d failed, %v", original.Resource, original.Pid, limit, err)
+			lpe.stop(ctx, uid)
+			return spec.ReturnFail(spec.OsCmdExecFailed,
+				fmt.Sprintf("set %s limit of %d to %d failed, %v", original.Resource, original.Pid, limit, err))
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (lpe *LimitProcessExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	originals, err := loadLimits(uid)
+	if err != nil {
+		log.Errorf(ctx, "read the original limits of %s failed, %v", uid, err)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("read the original limits of %s failed, %v", uid, err))
+	}
+	for _, original := range originals {
+		if err := setProcessLimit(original.Pid, original.Resource, original.Soft, original.Hard); err != nil {
+			// the process may be gone, which is nothing to recover
+			log.Warnf(ctx, "restore %s limit of %d failed, %v", original.Resource, original.Pid, err)
+		}
+	}
+	exec.ReleaseResources(ctx, uid)
+	return spec.ReturnSuccess(uid)
+}
+
+// loadLimits returns the original limits to restore, which are empty if there is no record or the experiment
+// is destroyed already
+func loadLimits(uid string) ([]processLimit, error) {
+	state, err := exec.LoadState(uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if state.Destroyed || state.Details[limitsDetail] == "" {
+		return nil, nil
+	}
+	var limits []processLimit
+	err = json.Unmarshal([]byte(state.Details[limitsDetail]), &limits)
+	return limits, err
+}
+
+// warnIfBelowOpenFds warns when the new limit is lower than the fd count the process currently holds.
+// Lowering it is allowed, the process just cannot open new files until it closes enough.
+func warnIfBelowOpenFds(ctx context.Context, pid int, limit uint64) {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return
+	}
+	if uint64(len(entries)) > limit {
+		log.Warnf(ctx, "the nofile limit %d is below the %d open fds of process %d", limit, len(entries), pid)
+	}
+}
+
+func (lpe *LimitProcessExecutor) SetChannel(channel spec.Channel) {
+	lpe.channel = channel
+}
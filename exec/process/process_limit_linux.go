@@ -0,0 +1,61 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+var rlimitResources = map[string]int{
+	"nofile":     unix.RLIMIT_NOFILE,
+	"nproc":      unix.RLIMIT_NPROC,
+	"fsize":      unix.RLIMIT_FSIZE,
+	"core":       unix.RLIMIT_CORE,
+	"data":       unix.RLIMIT_DATA,
+	"stack":      unix.RLIMIT_STACK,
+	"rss":        unix.RLIMIT_RSS,
+	"memlock":    unix.RLIMIT_MEMLOCK,
+	"as":         unix.RLIMIT_AS,
+	"cpu":        unix.RLIMIT_CPU,
+	"locks":      unix.RLIMIT_LOCKS,
+	"sigpending": unix.RLIMIT_SIGPENDING,
+	"msgqueue":   unix.RLIMIT_MSGQUEUE,
+	"nice":       unix.RLIMIT_NICE,
+	"rtprio":     unix.RLIMIT_RTPRIO,
+}
+
+func getProcessLimit(pid int, resource string) (uint64, uint64, error) {
+	r, ok := rlimitResources[resource]
+	if !ok {
+		return 0, 0, fmt.Errorf("unsupported resource %s", resource)
+	}
+	var old unix.Rlimit
+	if err := unix.Prlimit(pid, r, nil, &old); err != nil {
+		return 0, 0, err
+	}
+	return old.Cur, old.Max, nil
+}
+
+func setProcessLimit(pid int, resource string, soft, hard uint64) error {
+	r, ok := rlimitResources[resource]
+	if !ok {
+		return fmt.Errorf("unsupported resource %s", resource)
+	}
+	return unix.Prlimit(pid, r, &unix.Rlimit{Cur: soft, Max: hard}, nil)
+}
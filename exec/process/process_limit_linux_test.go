@@ -0,0 +1,156 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	osExec "os/exec"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func execLimit(ctx context.Context, uid string, flags map[string]string) *spec.Response {
+	executor := &LimitProcessExecutor{}
+	executor.SetChannel(exec.NewMockChannel())
+	return executor.Exec(uid, ctx, &spec.ExpModel{Target: "process", ActionName: "limit", ActionFlags: flags})
+}
+
+func TestLimitProcessExecutorFlags(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	if response := execLimit(ctx, "limit-1", map[string]string{"pid": "1", "nofile": "-1"}); response.Success ||
+		response.Code != spec.ParameterIllegal.Code {
+		t.Errorf("expected the illegal nofile, got %+v", response)
+	}
+	if response := execLimit(ctx, "limit-1", map[string]string{"pid": "1"}); response.Success ||
+		response.Code != spec.ParameterLess.Code {
+		t.Errorf("expected the less limit flags, got %+v", response)
+	}
+	if _, err := exec.LoadState("limit-1"); !os.IsNotExist(err) {
+		t.Errorf("expected no record of the illegal experiment, %v", err)
+	}
+}
+
+func TestLoadLimits(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	limits := []processLimit{{Pid: 100, Resource: "nofile", Soft: 1024, Hard: 4096}}
+	bytes, _ := json.Marshal(limits)
+	record, response := exec.NewExperimentState(ctx, "limit-2", "process", "limit", nil)
+	if response != nil {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if err := record.SetDetail(limitsDetail, string(bytes)); err != nil {
+		t.Fatal(err)
+	}
+	originals, err := loadLimits("limit-2")
+	if err != nil || !reflect.DeepEqual(originals, limits) {
+		t.Errorf("expected the recorded limits, got %+v, %v", originals, err)
+	}
+
+	// the destroyed experiment has nothing to restore
+	exec.ReleaseResources(ctx, "limit-2")
+	if originals, err := loadLimits("limit-2"); err != nil || len(originals) != 0 {
+		t.Errorf("expected nothing to restore after the destroy, got %+v, %v", originals, err)
+	}
+	if originals, err := loadLimits("limit-none"); err != nil || len(originals) != 0 {
+		t.Errorf("expected nothing to restore without the record, got %+v, %v", originals, err)
+	}
+}
+
+func TestLimitProcessExecutor(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	cmd := osExec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("start sleep failed, %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	pid := cmd.Process.Pid
+	soft, hard, err := getProcessLimit(pid, "nofile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the hard limit is lowered by the experiment, raising it back requires CAP_SYS_RESOURCE
+	if err := setProcessLimit(pid, "nofile", soft-1, hard-1); err != nil {
+		t.Skipf("change the nofile limit failed, %v", err)
+	}
+	if err := setProcessLimit(pid, "nofile", soft, hard); err != nil {
+		t.Skipf("raising the hard limit back is not permitted, %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), spec.Uid, "limit-3")
+	executor := &LimitProcessExecutor{}
+	executor.SetChannel(exec.NewMockChannel())
+	if response := executor.start(ctx, "limit-3", []string{strconv.Itoa(pid)}, map[string]uint64{"nofile": 64},
+		nil); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	if s, h, err := getProcessLimit(pid, "nofile"); err != nil || s != 64 || h != 64 {
+		t.Errorf("expected the nofile limit 64, got %d:%d, %v", s, h, err)
+	}
+	// the uid is refused until the experiment is destroyed
+	if response := executor.start(ctx, "limit-3", []string{strconv.Itoa(pid)}, map[string]uint64{"nofile": 32},
+		nil); response.Success {
+		t.Errorf("expected the failure of the running uid")
+	}
+
+	if response := executor.stop(ctx, "limit-3"); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	if s, h, err := getProcessLimit(pid, "nofile"); err != nil || s != soft || h != hard {
+		t.Errorf("expected the nofile limit %d:%d is restored, got %d:%d, %v", soft, hard, s, h, err)
+	}
+	if record, err := exec.LoadState("limit-3"); err != nil || !record.Destroyed {
+		t.Errorf("expected the record is destroyed, got %+v, %v", record, err)
+	}
+}
+
+func TestLimitProcessStopExited(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	cmd := osExec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("run true failed, %v", err)
+	}
+	ctx := context.WithValue(context.Background(), spec.Uid, "limit-4")
+	record, response := exec.NewExperimentState(ctx, "limit-4", "process", "limit", nil)
+	if response != nil {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	bytes, _ := json.Marshal([]processLimit{{Pid: cmd.Process.Pid, Resource: "nofile", Soft: 1024, Hard: 4096}})
+	if err := record.SetDetail(limitsDetail, string(bytes)); err != nil {
+		t.Fatal(err)
+	}
+	// the exited process has nothing to recover, the destroy succeeds
+	executor := &LimitProcessExecutor{}
+	executor.SetChannel(exec.NewMockChannel())
+	if response := executor.stop(ctx, "limit-4"); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	if record, err := exec.LoadState("limit-4"); err != nil || !record.Destroyed {
+		t.Errorf("expected the record is destroyed, got %+v, %v", record, err)
+	}
+}
@@ -0,0 +1,33 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"fmt"
+)
+
+// the limits of other process cannot be changed on windows, so that no resource is applied to the running processes
+var rlimitResources = map[string]int{}
+
+// windows has no prlimit, the limits of another process cannot be changed
+func getProcessLimit(pid int, resource string) (uint64, uint64, error) {
+	return 0, 0, fmt.Errorf("changing the %s limit of other process is not supported on windows", resource)
+}
+
+func setProcessLimit(pid int, resource string, soft, hard uint64) error {
+	return fmt.Errorf("changing the %s limit of other process is not supported on windows", resource)
+}
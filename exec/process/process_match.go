@@ -0,0 +1,39 @@
+//go:build !darwin
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// the processes are matched by the channel, so that the processes in the namespaces of the containers are found
+
+func getPidsByProcessName(ctx context.Context, cl spec.Channel, processName string) ([]string, error) {
+	return cl.GetPidsByProcessName(processName, ctx)
+}
+
+func getPidsByProcessCmdName(ctx context.Context, cl spec.Channel, processCmd string) ([]string, error) {
+	return cl.GetPidsByProcessCmdName(processCmd, ctx)
+}
+
+func getPidsByLocalPorts(ctx context.Context, cl spec.Channel, ports []string) ([]string, error) {
+	return cl.GetPidsByLocalPorts(ctx, ports)
+}
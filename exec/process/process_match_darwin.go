@@ -0,0 +1,204 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"golang.org/x/sys/unix"
+)
+
+// darwinProcess is the process listed by the sysctl kern.proc.all
+type darwinProcess struct {
+	pid     int32
+	name    string
+	cmdline string
+}
+
+// listProcesses lists the processes by sysctl instead of gopsutil, which runs ps for the command line of each
+// process on darwin. It's replaced in the tests
+var listProcesses = func() ([]darwinProcess, error) {
+	kprocs, err := unix.SysctlKinfoProcSlice("kern.proc.all")
+	if err != nil {
+		return nil, err
+	}
+	processes := make([]darwinProcess, 0, len(kprocs))
+	for _, kproc := range kprocs {
+		p := darwinProcess{
+			pid:  kproc.Proc.P_pid,
+			name: unix.ByteSliceToString(kproc.Proc.P_comm[:]),
+		}
+		// the arguments of the processes of the other users can't be read without root
+		if data, err := unix.SysctlRaw("kern.procargs2", int(p.pid)); err == nil {
+			execPath, args := parseProcargs(data)
+			p.cmdline = strings.Join(args, " ")
+			// the command name is truncated to MAXCOMLEN(16) by the kernel
+			if base := execPath[strings.LastIndex(execPath, "/")+1:]; len(p.name) >= 15 && strings.HasPrefix(base, p.name) {
+				p.name = base
+			}
+		}
+		if p.cmdline == "" {
+			p.cmdline = p.name
+		}
+		processes = append(processes, p)
+	}
+	return processes, nil
+}
+
+// parseProcargs parses the kern.procargs2 which is the argc, the executable path, the padding and the arguments
+// separated by the null bytes
+func parseProcargs(data []byte) (string, []string) {
+	if len(data) < 4 {
+		return "", nil
+	}
+	argc := int(binary.LittleEndian.Uint32(data[:4]))
+	data = data[4:]
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return string(data), nil
+	}
+	execPath := string(data[:end])
+	data = bytes.TrimLeft(data[end:], "\x00")
+	args := make([]string, 0, argc)
+	for len(args) < argc && len(data) > 0 {
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			args = append(args, string(data))
+			break
+		}
+		args = append(args, string(data[:end]))
+		data = data[end+1:]
+	}
+	return execPath, args
+}
+
+// getPidsByProcessName returns the processes whose command line contains the process name like the local channel
+func getPidsByProcessName(ctx context.Context, cl spec.Channel, processName string) ([]string, error) {
+	processName = strings.TrimSpace(processName)
+	if processName == "" {
+		return []string{}, fmt.Errorf("process keyword is blank")
+	}
+	processes, err := listProcesses()
+	if err != nil {
+		return []string{}, err
+	}
+	otherConditionProcessName, _ := ctx.Value(channel.ProcessKey).(string)
+	processCommandName, _ := ctx.Value(channel.ProcessCommandKey).(string)
+	excludeProcesses := getExcludeProcesses(ctx)
+	currPid := int32(os.Getpid())
+	pids := make([]string, 0)
+	for _, p := range processes {
+		if p.pid == currPid {
+			continue
+		}
+		if processCommandName != "" && !strings.Contains(p.name, processCommandName) {
+			continue
+		}
+		if !strings.Contains(p.cmdline, processName) {
+			continue
+		}
+		if otherConditionProcessName != "" && !strings.Contains(p.cmdline, otherConditionProcessName) {
+			continue
+		}
+		if containsAny(p.cmdline, excludeProcesses) {
+			continue
+		}
+		pids = append(pids, fmt.Sprintf("%d", p.pid))
+	}
+	return pids, nil
+}
+
+// getPidsByProcessCmdName returns the processes whose command name equals the process command
+func getPidsByProcessCmdName(ctx context.Context, cl spec.Channel, processCmd string) ([]string, error) {
+	processCmd = strings.TrimSpace(processCmd)
+	if processCmd == "" {
+		return []string{}, fmt.Errorf("processName is blank")
+	}
+	processes, err := listProcesses()
+	if err != nil {
+		return []string{}, err
+	}
+	excludeProcesses := getExcludeProcesses(ctx)
+	currPid := int32(os.Getpid())
+	pids := make([]string, 0)
+	for _, p := range processes {
+		if p.pid == currPid || p.name != processCmd {
+			continue
+		}
+		if containsAny(p.cmdline, excludeProcesses) {
+			continue
+		}
+		pids = append(pids, fmt.Sprintf("%d", p.pid))
+	}
+	return pids, nil
+}
+
+// getPidsByLocalPorts returns the processes listening on the ports by lsof, there is no ss on darwin
+func getPidsByLocalPorts(ctx context.Context, cl spec.Channel, ports []string) ([]string, error) {
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("the local port parameter is empty")
+	}
+	pids := make([]string, 0)
+	for _, port := range ports {
+		for _, args := range []string{
+			fmt.Sprintf("-nP -t -iTCP:%s -sTCP:LISTEN", port),
+			fmt.Sprintf("-nP -t -iUDP:%s", port),
+		} {
+			response := cl.Run(ctx, "lsof", args)
+			if !response.Success {
+				// lsof exits with 1 if no files are found
+				if strings.HasSuffix(strings.TrimSpace(response.Err), "exit status 1") {
+					continue
+				}
+				return nil, fmt.Errorf("failed to get pid by %s, %s", port, response.Err)
+			}
+			pids = append(pids, strings.Fields(response.Result.(string))...)
+		}
+		log.Infof(ctx, "get pids by %s port returns %v", port, pids)
+	}
+	return pids, nil
+}
+
+// getExcludeProcesses returns the keywords of the processes which are not matched, the same as the local channel
+func getExcludeProcesses(ctx context.Context) []string {
+	excludeProcesses := make([]string, 0)
+	if value, ok := ctx.Value(channel.ExcludeProcessKey).(string); ok {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				excludeProcesses = append(excludeProcesses, name)
+			}
+		}
+	}
+	return append(excludeProcesses, "chaos_killprocess", "chaos_stopprocess")
+}
+
+func containsAny(cmdline string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(cmdline, keyword) {
+			return true
+		}
+	}
+	return false
+}
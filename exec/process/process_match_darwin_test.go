@@ -0,0 +1,81 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestParseProcargs(t *testing.T) {
+	data := append([]byte{2, 0, 0, 0}, []byte("/usr/bin/python3\x00\x00\x00\x00python3\x00app.py\x00PATH=/usr/bin\x00")...)
+	execPath, args := parseProcargs(data)
+	if execPath != "/usr/bin/python3" {
+		t.Errorf("unexpected exec path: %s", execPath)
+	}
+	if !reflect.DeepEqual(args, []string{"python3", "app.py"}) {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+func TestGetPidsByProcessName(t *testing.T) {
+	original := listProcesses
+	defer func() {
+		listProcesses = original
+	}()
+	listProcesses = func() ([]darwinProcess, error) {
+		return []darwinProcess{
+			{pid: 100, name: "java", cmdline: "java -jar app.jar"},
+			{pid: 200, name: "java", cmdline: "java -jar blade-agent.jar"},
+			{pid: 300, name: "tail", cmdline: "tail -f app.jar.log"},
+			{pid: int32(os.Getpid()), name: "chaos_os", cmdline: "chaos_os create process kill --process app.jar"},
+		}, nil
+	}
+	ctx := context.WithValue(context.Background(), channel.ExcludeProcessKey, "blade,tail")
+	pids, err := getPidsByProcessName(ctx, nil, "app")
+	if err != nil || !reflect.DeepEqual(pids, []string{"100"}) {
+		t.Errorf("unexpected pids by process name: %v, %v", pids, err)
+	}
+	ctx = context.WithValue(context.Background(), channel.ProcessCommandKey, "tail")
+	pids, err = getPidsByProcessName(ctx, nil, "app")
+	if err != nil || !reflect.DeepEqual(pids, []string{"300"}) {
+		t.Errorf("unexpected pids by process name and command: %v, %v", pids, err)
+	}
+	pids, err = getPidsByProcessCmdName(context.WithValue(context.Background(), channel.ExcludeProcessKey, "blade"), nil, "java")
+	if err != nil || !reflect.DeepEqual(pids, []string{"100"}) {
+		t.Errorf("unexpected pids by process command: %v, %v", pids, err)
+	}
+}
+
+func TestGetPidsByLocalPorts(t *testing.T) {
+	cl := channel.NewMockLocalChannel().(*channel.MockLocalChannel)
+	cl.RunFunc = func(ctx context.Context, script, args string) *spec.Response {
+		if args == "-nP -t -iTCP:8080 -sTCP:LISTEN" {
+			return spec.ReturnSuccess("100\n200\n")
+		}
+		return spec.ReturnFail(spec.OsCmdExecFailed, " exit status 1")
+	}
+	pids, err := getPidsByLocalPorts(context.Background(), cl, []string{"8080", "8081"})
+	if err != nil || !reflect.DeepEqual(pids, []string{"100", "200"}) {
+		t.Errorf("unexpected pids by local ports: %v, %v", pids, err)
+	}
+}
@@ -0,0 +1,53 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"golang.org/x/sys/unix"
+)
+
+func killPid(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
+
+func signalNum(name string) syscall.Signal {
+	return unix.SignalNum(name)
+}
+
+func getpgid(pid int) (int, error) {
+	return syscall.Getpgid(pid)
+}
+
+// killProcesses sends the signal to the pids separated by space
+func killProcesses(ctx context.Context, cl spec.Channel, signal, pids string) *spec.Response {
+	return cl.Run(ctx, "kill", fmt.Sprintf("-%s %s", signal, pids))
+}
+
+func stopProcesses(ctx context.Context, cl spec.Channel, pids string) *spec.Response {
+	return cl.Run(ctx, "kill", fmt.Sprintf("-STOP %s", pids))
+}
+
+func continueProcesses(ctx context.Context, cl spec.Channel, pids string) *spec.Response {
+	return cl.Run(ctx, "kill", fmt.Sprintf("-CONT %s", pids))
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	ntdll            = windows.NewLazySystemDLL("ntdll.dll")
+	procNtSuspend    = ntdll.NewProc("NtSuspendProcess")
+	procNtResume     = ntdll.NewProc("NtResumeProcess")
+	windowsSignalMap = map[string]syscall.Signal{
+		"SIGHUP":  syscall.SIGHUP,
+		"SIGINT":  syscall.SIGINT,
+		"SIGQUIT": syscall.SIGQUIT,
+		"SIGABRT": syscall.SIGABRT,
+		"SIGKILL": syscall.SIGKILL,
+		"SIGTERM": syscall.SIGTERM,
+	}
+)
+
+// terminateProcess terminates the process by TerminateProcess, it's replaced in the tests
+var terminateProcess = func(pid int) error {
+	handle, err := windows.OpenProcess(windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(handle)
+	return windows.TerminateProcess(handle, 1)
+}
+
+// suspendProcess suspends or resumes all the threads of the process by NtSuspendProcess or NtResumeProcess,
+// it's replaced in the tests
+var suspendProcess = func(pid int, resume bool) error {
+	handle, err := windows.OpenProcess(windows.PROCESS_SUSPEND_RESUME, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(handle)
+	proc := procNtSuspend
+	if resume {
+		proc = procNtResume
+	}
+	// the NTSTATUS is returned, 0 means success
+	if status, _, _ := proc.Call(uintptr(handle)); status != 0 {
+		return fmt.Errorf("%s returns NTSTATUS 0x%x", proc.Name, status)
+	}
+	return nil
+}
+
+// killPid terminates the process whatever the signal is, Windows has no signal for the other processes
+func killPid(pid int, sig syscall.Signal) error {
+	err := terminateProcess(pid)
+	switch err {
+	case windows.ERROR_INVALID_PARAMETER:
+		return syscall.ESRCH
+	case windows.ERROR_ACCESS_DENIED:
+		return syscall.EPERM
+	}
+	return err
+}
+
+func signalNum(name string) syscall.Signal {
+	return windowsSignalMap[name]
+}
+
+// getpgid returns the pid itself, there is no process group on Windows
+func getpgid(pid int) (int, error) {
+	return pid, nil
+}
+
+// killProcesses kills the pids separated by space by taskkill, the processes are killed forcibly by SIGKILL,
+// otherwise they are asked to close
+func killProcesses(ctx context.Context, cl spec.Channel, signal, pids string) *spec.Response {
+	args := make([]string, 0)
+	if sig, err := parseSignal(signal); err == nil && sig == syscall.SIGKILL {
+		args = append(args, "/F")
+	}
+	for _, pid := range strings.Fields(pids) {
+		args = append(args, "/PID", pid)
+	}
+	return cl.Run(ctx, "taskkill", strings.Join(args, " "))
+}
+
+func stopProcesses(ctx context.Context, cl spec.Channel, pids string) *spec.Response {
+	return suspendProcesses(ctx, pids, false)
+}
+
+func continueProcesses(ctx context.Context, cl spec.Channel, pids string) *spec.Response {
+	return suspendProcesses(ctx, pids, true)
+}
+
+func suspendProcesses(ctx context.Context, pids string, resume bool) *spec.Response {
+	failed := make([]string, 0)
+	for _, pidStr := range strings.Fields(pids) {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "pid", pidStr, err)
+		}
+		if err := suspendProcess(pid, resume); err != nil {
+			log.Errorf(ctx, "suspend or resume the process %d failed, %v", pid, err)
+			failed = append(failed, pidStr)
+		}
+	}
+	if len(failed) > 0 {
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("suspend or resume the processes %s failed", strings.Join(failed, ",")))
+	}
+	return spec.ReturnSuccess(pids)
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"golang.org/x/sys/windows"
+)
+
+func TestKillProcessesByTaskkill(t *testing.T) {
+	tests := []struct {
+		signal string
+		pids   string
+		expect string
+	}{
+		{"9", "100 200", "/F /PID 100 /PID 200"},
+		{"KILL", "100", "/F /PID 100"},
+		{"15", "100", "/PID 100"},
+	}
+	for _, tt := range tests {
+		var script, args string
+		cl := channel.NewMockLocalChannel().(*channel.MockLocalChannel)
+		cl.RunFunc = func(ctx context.Context, s, a string) *spec.Response {
+			script, args = s, a
+			return spec.ReturnSuccess("")
+		}
+		killProcesses(context.Background(), cl, tt.signal, tt.pids)
+		if script != "taskkill" || args != tt.expect {
+			t.Errorf("unexpected command: %s %s, expected: taskkill %s", script, args, tt.expect)
+		}
+	}
+}
+
+func TestStopAndContinueProcesses(t *testing.T) {
+	original := suspendProcess
+	defer func() {
+		suspendProcess = original
+	}()
+	suspended := make(map[int]bool)
+	suspendProcess = func(pid int, resume bool) error {
+		if pid == 300 {
+			return windows.ERROR_ACCESS_DENIED
+		}
+		suspended[pid] = !resume
+		return nil
+	}
+	ctx := context.Background()
+	if response := stopProcesses(ctx, nil, "100 200"); !response.Success {
+		t.Errorf("stop processes failed, %s", response.Err)
+	}
+	if !suspended[100] || !suspended[200] {
+		t.Errorf("the processes are not suspended, %v", suspended)
+	}
+	if response := continueProcesses(ctx, nil, "100 200"); !response.Success {
+		t.Errorf("continue processes failed, %s", response.Err)
+	}
+	if suspended[100] || suspended[200] {
+		t.Errorf("the processes are not resumed, %v", suspended)
+	}
+	if response := stopProcesses(ctx, nil, "300"); response.Success {
+		t.Errorf("stop the process 300 is expected to fail")
+	}
+}
+
+func TestKillPidErrors(t *testing.T) {
+	original := terminateProcess
+	defer func() {
+		terminateProcess = original
+	}()
+	terminateProcess = func(pid int) error {
+		if pid == 1 {
+			return windows.ERROR_INVALID_PARAMETER
+		}
+		return windows.ERROR_ACCESS_DENIED
+	}
+	if err := killPid(1, syscall.SIGKILL); err != syscall.ESRCH {
+		t.Errorf("unexpected error: %v, expected: %v", err, syscall.ESRCH)
+	}
+	if err := killPid(4, syscall.SIGKILL); err != syscall.EPERM {
+		t.Errorf("unexpected error: %v, expected: %v", err, syscall.EPERM)
+	}
+}
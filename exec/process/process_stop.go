@@ -18,7 +18,7 @@ package process
 
 import (
 	"context"
-	"fmt"
+	"strings"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
@@ -63,8 +63,23 @@ func NewStopProcessActionCommandSpec() spec.ExpActionCommandSpec {
 					Name: "pid",
 					Desc: "pid",
 				},
+				&spec.ExpFlag{
+					Name: "user",
+					Desc: "Only match the processes of the effective user, the user name or the numeric uid",
+				},
+				&spec.ExpFlag{
+					Name:   "allow-root-processes",
+					Desc:   "Confirm matching the processes of root by the user flag",
+					NoArgs: true,
+				},
+			},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:   "dry-run",
+					Desc:   "Only return the processes which would be affected, change nothing",
+					NoArgs: true,
+				},
 			},
-			ActionFlags:    []spec.ExpFlagSpec{},
 			ActionExecutor: &StopProcessExecutor{},
 			ActionExample: `
 # Pause the process that contains the "SimpleHTTPServer" keyword
@@ -73,6 +88,12 @@ blade create process stop --process SimpleHTTPServer
 # Pause the Java process
 blade create process stop --process-cmd java
 
+# Pause all the processes of the user etl
+blade create process stop --user etl
+
+# Preview the processes which would be paused
+blade create process stop --process-cmd java --dry-run
+
 # Return success even if the process not found
 blade create process stop --process demo --ignore-not-found`,
 			ActionPrograms:   []string{StopProcessBin},
@@ -115,9 +136,11 @@ func (spe *StopProcessExecutor) Exec(uid string, ctx context.Context, model *spe
 	}
 	pids := resp.Result.(string)
 	if _, ok := spec.IsDestroy(ctx); ok {
-		return spe.channel.Run(ctx, "kill", fmt.Sprintf("-CONT %s", pids))
+		return continueProcesses(ctx, spe.channel, pids)
+	} else if model.ActionFlags["dry-run"] == "true" {
+		return previewPids(ctx, spe.channel, strings.Fields(pids))
 	} else {
-		return spe.channel.Run(ctx, "kill", fmt.Sprintf("-STOP %s", pids))
+		return stopProcesses(ctx, spe.channel, pids)
 	}
 }
 
@@ -0,0 +1,134 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
+)
+
+const TaskExhaustBin = "chaos_taskexhaust"
+
+type TaskExhaustActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewTaskExhaustActionCommandSpec() spec.ExpActionCommandSpec {
+	return &TaskExhaustActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "pid",
+					Desc: "The process whose pids cgroup will be exhausted, the host is exhausted if pid and cgroup-path are both absent",
+				},
+				&spec.ExpFlag{
+					Name: "cgroup-path",
+					Desc: "The pids cgroup path relative to the cgroup root, such as /kubepods/pod1/container1",
+				},
+			},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     "percent",
+					Desc:     "Percent of the pids limit to reach, an integer value from 1 to 100",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name:    "cgroup-root",
+					Desc:    "cgroup root path, such as the mount of the host cgroupfs /host-sys/fs/cgroup in the container, it's detected from the cgroup mounts if absent",
+					Default: "",
+				},
+				&spec.ExpFlag{
+					Name:    cgroups.HostProcKey,
+					Desc:    "the mount point of the host proc filesystem, such as /host/proc, which the cgroup of the host pid is read from when running in the container",
+					Default: "",
+				},
+			},
+			ActionExecutor: &TaskExhaustExecutor{},
+			ActionExample: `
+# Spawn tasks in the pids cgroup of process 1234 until 90% of its pids.max is used
+blade create process task-exhaust --pid 1234 --percent 90
+
+# Spawn tasks in the given pids cgroup until it is full
+blade create process task-exhaust --cgroup-path /kubepods/pod1/container1 --percent 100
+
+# Spawn tasks on the host until 80% of kernel.pid_max is used
+blade create process task-exhaust --percent 80`,
+			ActionPrograms:    []string{TaskExhaustBin},
+			ActionCategories:  []string{category.SystemProcess},
+			ActionProcessHang: true,
+		},
+	}
+}
+
+func (*TaskExhaustActionCommandSpec) Name() string {
+	return "task-exhaust"
+}
+
+func (*TaskExhaustActionCommandSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*TaskExhaustActionCommandSpec) ShortDesc() string {
+	return "Exhaust pids of cgroup or host"
+}
+
+func (t *TaskExhaustActionCommandSpec) LongDesc() string {
+	if t.ActionLongDesc != "" {
+		return t.ActionLongDesc
+	}
+	return "Spawn sleeping tasks in a dedicated child cgroup of the target pids cgroup until the percent of pids.max, " +
+		"or kernel.pid_max for the host, is reached. All the tasks are killed through the child cgroup when destroyed"
+}
+
+func (*TaskExhaustActionCommandSpec) Categories() []string {
+	return []string{category.SystemProcess}
+}
+
+type TaskExhaustExecutor struct {
+	channel spec.Channel
+}
+
+func (te *TaskExhaustExecutor) Name() string {
+	return "task-exhaust"
+}
+
+func (te *TaskExhaustExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return te.stop(ctx, uid)
+	}
+
+	percentStr := model.ActionFlags["percent"]
+	percent, err := strconv.Atoi(percentStr)
+	if err != nil || percent <= 0 || percent > 100 {
+		log.Errorf(ctx, "`%s`: percent is illegal, it must be an integer value from 1 to 100", percentStr)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "percent", percentStr, "it must be an integer value from 1 to 100")
+	}
+	cgroupRoot := cgroups.ResolveCGroupRoot(ctx, model.ActionFlags["cgroup-root"])
+	ctx = cgroups.WithHostProc(ctx, model.ActionFlags[cgroups.HostProcKey])
+	return te.start(ctx, uid, model.ActionFlags["pid"], model.ActionFlags["cgroup-path"], cgroupRoot, percent,
+		model.ActionFlags)
+}
+
+func (te *TaskExhaustExecutor) SetChannel(channel spec.Channel) {
+	te.channel = channel
+}
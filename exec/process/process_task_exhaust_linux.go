@@ -0,0 +1,298 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	osExec "os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
+)
+
+const taskExhaustCgroupPrefix = "chaos-taskexhaust-"
+
+// taskExhaustCgroupDetail is the detail of the record which keeps the cgroups of the spawned tasks
+const taskExhaustCgroupDetail = "cgroup"
+
+// taskExhaustCgroup records the child cgroups which hold the spawned tasks
+type taskExhaustCgroup struct {
+	V2      bool   `json:"v2"`
+	Pids    string `json:"pids"`
+	Freezer string `json:"freezer,omitempty"`
+}
+
+func (te *TaskExhaustExecutor) start(ctx context.Context, uid, pid, cgroupPath, cgroupRoot string, percent int,
+	flags map[string]string) *spec.Response {
+	cgroupHierarchy := cgroups.DetectCGroupHierarchy(ctx, cgroupRoot)
+	v2 := cgroupHierarchy.ControllerVersion("pids") == cgroups.CGroupV2
+	hierarchy := cgroupHierarchy.UnifiedMount
+	if !v2 {
+		hierarchy = filepath.Join(cgroupRoot, "pids")
+	}
+	target, err := getTaskExhaustTarget(ctx, pid, cgroupPath, hierarchy, v2)
+	if err != nil {
+		log.Errorf(ctx, "get the pids cgroup failed, %v", err)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("get the pids cgroup failed, %v", err))
+	}
+	limit, current, err := getPidsLimitAndCurrent(target, hierarchy)
+	if err != nil {
+		log.Errorf(ctx, "get the pids limit of %s failed, %v", target, err)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("get the pids limit of %s failed, %v", target, err))
+	}
+	count := limit*int64(percent)/100 - current
+	if count <= 0 {
+		log.Errorf(ctx, "the %d tasks of %s already reach %d%% of the limit %d", current, target, percent, limit)
+		return spec.ReturnFail(spec.OsCmdExecFailed,
+			fmt.Sprintf("the %d tasks of %s already reach %d%% of the limit %d", current, target, percent, limit))
+	}
+
+	record, response := exec.NewExperimentState(ctx, uid, "process", "task-exhaust", flags)
+	if response != nil {
+		return response
+	}
+	state := taskExhaustCgroup{V2: v2, Pids: filepath.Join(target, taskExhaustCgroupPrefix+uid)}
+	if !v2 && util.IsDir(filepath.Join(cgroupRoot, "freezer")) {
+		state.Freezer = filepath.Join(cgroupRoot, "freezer", taskExhaustCgroupPrefix+uid)
+	}
+	bytes, _ := json.Marshal(state)
+	if err := record.SetDetail(taskExhaustCgroupDetail, string(bytes)); err != nil {
+		return exec.Fail(exec.StateRecordFailed, "task-exhaust", "record", fmt.Sprintf("record the cgroup %s failed, %v", state.Pids, err))
+	}
+	if err := spawnTasks(ctx, state, count); err != nil {
+		log.Errorf(ctx, "spawn tasks in %s failed, %v", state.Pids, err)
+		killTaskExhaustCgroup(ctx, state)
+		exec.ReleaseResources(ctx, uid)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("spawn tasks in %s failed, %v", state.Pids, err))
+	}
+	log.Infof(ctx, "spawned %d tasks in %s, limit: %d, current: %d", count, state.Pids, limit, current)
+	select {}
+}
+
+func (te *TaskExhaustExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	state, err := loadTaskExhaustCgroup(uid)
+	if err != nil {
+		log.Warnf(ctx, "read the cgroup of the task exhaust %s failed, %v", uid, err)
+	} else if state != nil {
+		killTaskExhaustCgroup(ctx, *state)
+	}
+	ctx = context.WithValue(ctx, "bin", TaskExhaustBin)
+	response := exec.Destroy(ctx, te.channel, "process task-exhaust")
+	if response.Success {
+		exec.ReleaseResources(ctx, uid)
+	}
+	return response
+}
+
+// loadTaskExhaustCgroup returns the recorded cgroups which hold the spawned tasks, it's nil if there is no
+// record or the experiment is destroyed already
+func loadTaskExhaustCgroup(uid string) (*taskExhaustCgroup, error) {
+	record, err := exec.LoadState(uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if record.Destroyed || record.Details[taskExhaustCgroupDetail] == "" {
+		return nil, nil
+	}
+	state := &taskExhaustCgroup{}
+	err = json.Unmarshal([]byte(record.Details[taskExhaustCgroupDetail]), state)
+	return state, err
+}
+
+// getTaskExhaustTarget returns the pids cgroup directory which the tasks are charged to
+func getTaskExhaustTarget(ctx context.Context, pid, cgroupPath, hierarchy string, v2 bool) (string, error) {
+	if cgroupPath != "" {
+		return filepath.Join(hierarchy, cgroupPath), nil
+	}
+	if pid == "" {
+		return hierarchy, nil
+	}
+	if v2 {
+		path, err := cgroups.FindCGroupV2Path(ctx, pid, hierarchy)
+		if err != nil {
+			return "", err
+		}
+		if path == "" {
+			return "", fmt.Errorf("cgroup v2 path of process %s not found", pid)
+		}
+		return path, nil
+	}
+	p, err := strconv.Atoi(pid)
+	if err != nil {
+		return "", fmt.Errorf("illegal pid %s", pid)
+	}
+	path, err := exec.PidPath(p)("pids")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(hierarchy, path), nil
+}
+
+// getPidsLimitAndCurrent returns the effective pids limit of the target and the task count charged to it.
+// pids.max is enforced hierarchically, so the smallest one on the path up to the hierarchy root wins,
+// and kernel.pid_max is the upper bound of all.
+func getPidsLimitAndCurrent(target, hierarchy string) (int64, int64, error) {
+	limit, err := readInt64File("/proc/sys/kernel/pid_max")
+	if err != nil {
+		return 0, 0, err
+	}
+	limitDir := ""
+	for dir := filepath.Clean(target); strings.HasPrefix(dir, hierarchy); dir = filepath.Dir(dir) {
+		if max, err := readInt64File(filepath.Join(dir, "pids.max")); err == nil && max < limit {
+			limit = max
+			limitDir = dir
+		}
+		if dir == hierarchy {
+			break
+		}
+	}
+	if limitDir != "" {
+		current, err := readInt64File(filepath.Join(limitDir, "pids.current"))
+		return limit, current, err
+	}
+	// the fourth field of loadavg is running/total scheduling entities of the host
+	bytes, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(string(bytes))
+	if len(fields) < 4 || !strings.Contains(fields[3], "/") {
+		return 0, 0, fmt.Errorf("unexpected /proc/loadavg content: %s", string(bytes))
+	}
+	current, err := strconv.ParseInt(strings.SplitN(fields[3], "/", 2)[1], 10, 64)
+	return limit, current, err
+}
+
+// spawnTasks starts a shell in the child cgroups which forks the sleeping tasks, so that
+// the chaos process itself stays outside of the exhausted cgroup and can still fork to clean up.
+func spawnTasks(ctx context.Context, state taskExhaustCgroup, count int64) error {
+	dirs := []string{state.Pids}
+	if state.Freezer != "" {
+		dirs = append(dirs, state.Freezer)
+	}
+	for _, dir := range dirs {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			return err
+		}
+	}
+	// the shell waits on stdin until it has been moved into the child cgroups
+	cmd := osExec.Command("/bin/sh", "-c",
+		fmt.Sprintf(`read _; i=1; while [ $i -lt %d ]; do sleep 2147483647 & i=$((i+1)); done; wait`, count))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	for _, dir := range dirs {
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(cmd.Process.Pid)), 0644); err != nil {
+			cmd.Process.Kill()
+			return err
+		}
+	}
+	if _, err := stdin.Write([]byte("\n")); err != nil {
+		return err
+	}
+	return stdin.Close()
+}
+
+// killTaskExhaustCgroup kills the whole task tree through the child cgroup and removes it,
+// cgroup.kill is used if the kernel supports it, otherwise the tasks are frozen before killing
+// so that none of them can fork any more.
+func killTaskExhaustCgroup(ctx context.Context, state taskExhaustCgroup) {
+	if !util.IsDir(state.Pids) {
+		return
+	}
+	if state.V2 {
+		killFile := filepath.Join(state.Pids, "cgroup.kill")
+		if util.IsExist(killFile) {
+			if err := os.WriteFile(killFile, []byte("1"), 0644); err != nil {
+				log.Warnf(ctx, "write %s failed, %v", killFile, err)
+			}
+		} else {
+			freezeAndKill(ctx, filepath.Join(state.Pids, "cgroup.freeze"), "1", "0", state.Pids)
+		}
+	} else if state.Freezer != "" {
+		freezeAndKill(ctx, filepath.Join(state.Freezer, "freezer.state"), "FROZEN", "THAWED", state.Pids)
+	} else {
+		killCgroupProcs(state.Pids)
+	}
+	dirs := []string{state.Pids}
+	if state.Freezer != "" {
+		dirs = append(dirs, state.Freezer)
+	}
+	for _, dir := range dirs {
+		// the cgroup can be removed only after all the killed tasks exit
+		var err error
+		for i := 0; i < 50; i++ {
+			if err = os.Remove(dir); err == nil || os.IsNotExist(err) {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			log.Warnf(ctx, "remove cgroup %s failed, %v", dir, err)
+		}
+	}
+}
+
+func freezeAndKill(ctx context.Context, freezeFile, frozen, thawed, procsDir string) {
+	if err := os.WriteFile(freezeFile, []byte(frozen), 0644); err != nil {
+		log.Warnf(ctx, "freeze %s failed, %v", freezeFile, err)
+	}
+	killCgroupProcs(procsDir)
+	if err := os.WriteFile(freezeFile, []byte(thawed), 0644); err != nil {
+		log.Warnf(ctx, "thaw %s failed, %v", freezeFile, err)
+	}
+}
+
+func killCgroupProcs(dir string) {
+	bytes, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return
+	}
+	for _, p := range strings.Fields(string(bytes)) {
+		if pid, err := strconv.Atoi(p); err == nil {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+}
+
+func readInt64File(file string) (int64, error) {
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(bytes)), 10, 64)
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func TestGetPidsLimitAndCurrent(t *testing.T) {
+	hierarchy := t.TempDir()
+	parent := filepath.Join(hierarchy, "kubepods")
+	target := filepath.Join(parent, "pod1")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the smallest pids.max on the path wins, and the task count is read from the same cgroup
+	files := map[string]string{
+		filepath.Join(parent, "pids.max"):     "200\n",
+		filepath.Join(parent, "pids.current"): "50\n",
+		filepath.Join(target, "pids.max"):     "max\n",
+		filepath.Join(target, "pids.current"): "10\n",
+	}
+	for file, content := range files {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	limit, current, err := getPidsLimitAndCurrent(target, hierarchy)
+	if err != nil || limit != 200 || current != 50 {
+		t.Errorf("expected the limit 200 and 50 tasks of the parent, got %d, %d, %v", limit, current, err)
+	}
+
+	// without pids.max, the limit is kernel.pid_max and the tasks are counted on the host
+	pidMax, err := readInt64File("/proc/sys/kernel/pid_max")
+	if err != nil {
+		t.Skipf("kernel.pid_max is not readable, %v", err)
+	}
+	limit, current, err = getPidsLimitAndCurrent(t.TempDir(), hierarchy)
+	if err != nil || limit != pidMax || current <= 0 {
+		t.Errorf("expected the limit %d and the tasks of the host, got %d, %d, %v", pidMax, limit, current, err)
+	}
+}
+
+func TestGetTaskExhaustTarget(t *testing.T) {
+	ctx := context.Background()
+	if target, err := getTaskExhaustTarget(ctx, "", "kubepods/pod1", "/sys/fs/cgroup", true); err != nil ||
+		target != "/sys/fs/cgroup/kubepods/pod1" {
+		t.Errorf("expected the cgroup path under the hierarchy, got %s, %v", target, err)
+	}
+	if target, err := getTaskExhaustTarget(ctx, "", "", "/sys/fs/cgroup/pids", false); err != nil ||
+		target != "/sys/fs/cgroup/pids" {
+		t.Errorf("expected the hierarchy root without the pid, got %s, %v", target, err)
+	}
+	if _, err := getTaskExhaustTarget(ctx, "abc", "", "/sys/fs/cgroup/pids", false); err == nil {
+		t.Errorf("expected the failure of the illegal pid")
+	}
+}
+
+func TestLoadTaskExhaustCgroup(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.Background()
+	cgroup := taskExhaustCgroup{Pids: "/sys/fs/cgroup/pids/chaos-taskexhaust-exhaust-1"}
+	record, response := exec.NewExperimentState(ctx, "exhaust-1", "process", "task-exhaust", nil)
+	if response != nil {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	bytes, _ := json.Marshal(cgroup)
+	if err := record.SetDetail(taskExhaustCgroupDetail, string(bytes)); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadTaskExhaustCgroup("exhaust-1")
+	if err != nil || state == nil || *state != cgroup {
+		t.Errorf("expected the recorded cgroup, got %+v, %v", state, err)
+	}
+
+	// the destroyed experiment has nothing to kill
+	exec.ReleaseResources(ctx, "exhaust-1")
+	if state, err := loadTaskExhaustCgroup("exhaust-1"); err != nil || state != nil {
+		t.Errorf("expected nothing to kill after the destroy, got %+v, %v", state, err)
+	}
+	if state, err := loadTaskExhaustCgroup("exhaust-none"); err != nil || state != nil {
+		t.Errorf("expected nothing to kill without the record, got %+v, %v", state, err)
+	}
+}
+
+func TestTaskExhaustStop(t *testing.T) {
+	exec.StateDir = t.TempDir()
+	ctx := context.WithValue(context.Background(), spec.Uid, "exhaust-2")
+	cgroup := taskExhaustCgroup{Pids: filepath.Join(t.TempDir(), taskExhaustCgroupPrefix+"exhaust-2")}
+	if err := os.Mkdir(cgroup.Pids, 0755); err != nil {
+		t.Fatal(err)
+	}
+	record, response := exec.NewExperimentState(ctx, "exhaust-2", "process", "task-exhaust", nil)
+	if response != nil {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	bytes, _ := json.Marshal(cgroup)
+	if err := record.SetDetail(taskExhaustCgroupDetail, string(bytes)); err != nil {
+		t.Fatal(err)
+	}
+	executor := &TaskExhaustExecutor{}
+	executor.SetChannel(exec.NewMockChannel())
+	if response := executor.stop(ctx, "exhaust-2"); !response.Success {
+		t.Fatalf("unexpected failure of destroy, %s", response.Err)
+	}
+	if _, err := os.Stat(cgroup.Pids); !os.IsNotExist(err) {
+		t.Errorf("expected the cgroup %s is removed, %v", cgroup.Pids, err)
+	}
+	if record, err := exec.LoadState("exhaust-2"); err != nil || !record.Destroyed {
+		t.Errorf("expected the record is destroyed, got %+v, %v", record, err)
+	}
+}
@@ -0,0 +1,32 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func (te *TaskExhaustExecutor) start(ctx context.Context, uid, pid, cgroupPath, cgroupRoot string, percent int,
+	flags map[string]string) *spec.Response {
+	return spec.ResponseFailWithFlags(spec.ActionNotSupport, "task-exhaust on windows")
+}
+
+func (te *TaskExhaustExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	return spec.ReturnSuccess(uid)
+}
@@ -0,0 +1,486 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const UlimitProcessBin = "chaos_ulimitprocess"
+
+const limitsDropInDir = "/etc/security/limits.d"
+
+const ulimitWarning = "the limits are applied to the new sessions by pam_limits, the running sessions and processes keep the old limits " +
+	"unless they are changed by apply-now"
+
+// ulimitItem is an item of limits.conf, the scale converts the value to the rlimit unit
+type ulimitItem struct {
+	// the value is a priority but not a limit, it can be negative and unlimited is not allowed
+	priority bool
+	min      int64
+	max      int64
+	scale    uint64
+}
+
+// the items of limits.conf, the ones which are not rlimits, such as maxlogins, are only applied by pam_limits
+var ulimitItems = map[string]ulimitItem{
+	"core":         {scale: 1024},
+	"data":         {scale: 1024},
+	"fsize":        {scale: 1024},
+	"memlock":      {scale: 1024},
+	"nofile":       {scale: 1},
+	"rss":          {scale: 1024},
+	"stack":        {scale: 1024},
+	"cpu":          {scale: 60},
+	"nproc":        {scale: 1},
+	"as":           {scale: 1024},
+	"maxlogins":    {scale: 1},
+	"maxsyslogins": {scale: 1},
+	"locks":        {scale: 1},
+	"sigpending":   {scale: 1},
+	"msgqueue":     {scale: 1},
+	"priority":     {priority: true, min: -20, max: 19},
+	"nice":         {priority: true, min: -20, max: 19},
+	"rtprio":       {priority: true, min: 0, max: 99},
+}
+
+const (
+	UlimitTypeSoft = "soft"
+	UlimitTypeHard = "hard"
+	UlimitTypeBoth = "both"
+)
+
+type UlimitProcessActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewUlimitProcessActionCommandSpec() spec.ExpActionCommandSpec {
+	return &UlimitProcessActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     "user",
+					Desc:     "The user name, @group or * of the limits.conf entries",
+					Required: true,
+				},
+			},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     "limits",
+					Desc:     "The limits.conf items and values, for example: nofile=256,nproc=64. The value can be unlimited",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name:    "type",
+					Desc:    "The type of the limits, soft, hard or both, default value is soft",
+					Default: UlimitTypeSoft,
+				},
+				&spec.ExpFlag{
+					Name:   "apply-now",
+					Desc:   "Change the limits of the running processes of the user by prlimit too, which are restored when the experiment is destroyed",
+					NoArgs: true,
+				},
+			},
+			ActionExecutor: &UlimitProcessExecutor{},
+			ActionExample: `
+# Limit the soft nofile of the new sessions of the user app to 256
+blade create process ulimit --user app --limits nofile=256
+
+# Limit the nofile and nproc of the user app to 256 and 64, including the running processes of the user
+blade create process ulimit --user app --limits nofile=256,nproc=64 --type both --apply-now`,
+			ActionPrograms:   []string{UlimitProcessBin},
+			ActionCategories: []string{category.SystemProcess},
+		},
+	}
+}
+
+func (*UlimitProcessActionCommandSpec) Name() string {
+	return "ulimit"
+}
+
+func (*UlimitProcessActionCommandSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*UlimitProcessActionCommandSpec) ShortDesc() string {
+	return "Lower resource limits of user sessions"
+}
+
+func (u *UlimitProcessActionCommandSpec) LongDesc() string {
+	if u.ActionLongDesc != "" {
+		return u.ActionLongDesc
+	}
+	return "Write the limits of the user to a drop-in file in /etc/security/limits.d, which is applied to the new sessions by pam_limits. " +
+		"The running processes of the user keep the old limits unless apply-now is set, which changes them by prlimit. " +
+		"The drop-in file is deleted and the changed limits of the running processes are restored when the experiment is destroyed"
+}
+
+func (*UlimitProcessActionCommandSpec) Categories() []string {
+	return []string{category.SystemProcess}
+}
+
+type UlimitProcessExecutor struct {
+	channel spec.Channel
+}
+
+func (upe *UlimitProcessExecutor) Name() string {
+	return "ulimit"
+}
+
+// ulimitEntry is an entry of the drop-in file
+type ulimitEntry struct {
+	Item  string
+	Value string
+}
+
+// ulimitState is the drop-in file and the original limits of the running processes
+type ulimitState struct {
+	DropIn string         `json:"dropIn"`
+	Limits []processLimit `json:"limits,omitempty"`
+}
+
+// ulimitResult is the response of the ulimit, which shows how many processes are changed now
+type ulimitResult struct {
+	Uid     string `json:"uid"`
+	DropIn  string `json:"dropIn"`
+	Pids    []int  `json:"pids,omitempty"`
+	Warning string `json:"warning"`
+}
+
+func (upe *UlimitProcessExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return upe.stop(ctx, uid)
+	}
+	domain := model.ActionFlags["user"]
+	if domain == "" {
+		log.Errorf(ctx, "user is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "user")
+	}
+	if err := checkUlimitDomain(domain); err != nil {
+		log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("user", domain, err))
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "user", domain, err)
+	}
+	limitType := model.ActionFlags["type"]
+	if limitType == "" {
+		limitType = UlimitTypeSoft
+	}
+	if limitType != UlimitTypeSoft && limitType != UlimitTypeHard && limitType != UlimitTypeBoth {
+		log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("type", limitType, "it must be soft, hard or both"))
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "type", limitType, "it must be soft, hard or both")
+	}
+	limitsStr := model.ActionFlags["limits"]
+	if limitsStr == "" {
+		log.Errorf(ctx, "limits is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "limits")
+	}
+	entries, err := parseUlimitEntries(limitsStr)
+	if err != nil {
+		log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("limits", limitsStr, err))
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "limits", limitsStr, err)
+	}
+	applyNow := model.ActionFlags["apply-now"] == "true"
+	if applyNow && (domain == "*" || strings.HasPrefix(domain, "@")) {
+		log.Errorf(ctx, "apply-now only supports the user name")
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "user", domain, "apply-now only supports the user name")
+	}
+	return upe.start(ctx, uid, domain, limitType, entries, applyNow)
+}
+
+func (upe *UlimitProcessExecutor) start(ctx context.Context, uid, domain, limitType string, entries []ulimitEntry,
+	applyNow bool) *spec.Response {
+	stateFile := getUlimitStateFile(uid)
+	if util.IsExist(stateFile) {
+		log.Errorf(ctx, "%s", spec.BackfileExists.Sprintf(stateFile))
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	state := ulimitState{DropIn: getUlimitDropInFile(uid)}
+	var pids []int
+	if applyNow {
+		var response *spec.Response
+		if pids, state.Limits, response = snapshotUserLimits(ctx, domain, entries); response != nil {
+			return response
+		}
+	}
+	// record the original limits before changing anything, so that destroy can always recover
+	if err := writeUlimitState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write ulimit state file %s failed, %v", stateFile, err)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("write ulimit state file %s failed, %v", stateFile, err))
+	}
+	if err := os.MkdirAll(limitsDropInDir, 0755); err != nil {
+		log.Errorf(ctx, "create %s failed, %v", limitsDropInDir, err)
+		os.Remove(stateFile)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("create %s failed, %v", limitsDropInDir, err))
+	}
+	if err := os.WriteFile(state.DropIn, []byte(buildUlimitDropIn(uid, domain, limitType, entries)), 0644); err != nil {
+		log.Errorf(ctx, "write %s failed, %v", state.DropIn, err)
+		os.Remove(stateFile)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("write %s failed, %v", state.DropIn, err))
+	}
+	values := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		values[entry.Item] = entry.Value
+	}
+	for _, original := range state.Limits {
+		soft, hard := getUlimitValues(original, limitType, values[original.Resource])
+		if err := setProcessLimit(original.Pid, original.Resource, soft, hard); err != nil {
+			log.Errorf(ctx, "set %s limit of %d failed, %v", original.Resource, original.Pid, err)
+			upe.stop(ctx, uid)
+			return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("set %s limit of %d failed, %v", original.Resource, original.Pid, err))
+		}
+	}
+	log.Warnf(ctx, "%s", ulimitWarning)
+	return spec.ReturnSuccess(ulimitResult{Uid: uid, DropIn: state.DropIn, Pids: pids, Warning: ulimitWarning})
+}
+
+func (upe *UlimitProcessExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	stateFile := getUlimitStateFile(uid)
+	if !util.IsExist(stateFile) {
+		return spec.ReturnSuccess(uid)
+	}
+	state, err := readUlimitState(stateFile)
+	if err != nil {
+		log.Errorf(ctx, "read ulimit state file %s failed, %v", stateFile, err)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("read ulimit state file %s failed, %v", stateFile, err))
+	}
+	if err := os.Remove(state.DropIn); err != nil && !os.IsNotExist(err) {
+		log.Errorf(ctx, "remove %s failed, %v", state.DropIn, err)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("remove %s failed, %v", state.DropIn, err))
+	}
+	for _, original := range state.Limits {
+		if err := setProcessLimit(original.Pid, original.Resource, original.Soft, original.Hard); err != nil {
+			// the process may be gone, which is nothing to recover
+			log.Warnf(ctx, "restore %s limit of %d failed, %v", original.Resource, original.Pid, err)
+		}
+	}
+	if err := os.Remove(stateFile); err != nil {
+		log.Warnf(ctx, "remove ulimit state file %s failed, %v", stateFile, err)
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+// snapshotUserLimits returns the running processes of the user and their original limits of the items which are rlimits
+func snapshotUserLimits(ctx context.Context, userName string, entries []ulimitEntry) ([]int, []processLimit, *spec.Response) {
+	euid, err := lookupUserId(userName)
+	if err != nil {
+		log.Errorf(ctx, "%s", spec.ParameterIllegal.Sprintf("user", userName, err))
+		return nil, nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "user", userName, err)
+	}
+	pidStrs, err := getPidsByUser(euid, "")
+	if err != nil {
+		return nil, nil, spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("get pids by user err, %v", err))
+	}
+	pids := make([]int, 0, len(pidStrs))
+	originals := make([]processLimit, 0)
+	for _, p := range pidStrs {
+		pid, err := strconv.Atoi(p)
+		if err != nil {
+			continue
+		}
+		limits := make([]processLimit, 0, len(entries))
+		var soft, hard uint64
+		for _, entry := range entries {
+			if _, ok := rlimitResources[entry.Item]; !ok {
+				continue
+			}
+			if soft, hard, err = getProcessLimit(pid, entry.Item); err != nil {
+				break
+			}
+			limits = append(limits, processLimit{Pid: pid, Resource: entry.Item, Soft: soft, Hard: hard})
+		}
+		// the process may be gone or not permitted to change, which is skipped
+		if err != nil {
+			log.Warnf(ctx, "get the limits of %d failed, skip it, %v", pid, err)
+			continue
+		}
+		pids = append(pids, pid)
+		originals = append(originals, limits...)
+	}
+	for _, entry := range entries {
+		if _, ok := rlimitResources[entry.Item]; !ok {
+			log.Warnf(ctx, "%s is not a resource limit, it is not applied to the running processes", entry.Item)
+		}
+	}
+	return pids, originals, nil
+}
+
+// getUlimitValues returns the new soft and hard limits of the process, the soft limit never exceeds the hard one
+func getUlimitValues(original processLimit, limitType, value string) (uint64, uint64) {
+	limit := toRlimit(original.Resource, value)
+	soft, hard := original.Soft, original.Hard
+	switch limitType {
+	case UlimitTypeSoft:
+		soft = limit
+		if soft > hard {
+			soft = hard
+		}
+	case UlimitTypeHard:
+		hard = limit
+		if soft > hard {
+			soft = hard
+		}
+	default:
+		soft, hard = limit, limit
+	}
+	return soft, hard
+}
+
+// toRlimit converts the value of limits.conf to the rlimit, the value has been validated
+func toRlimit(item, value string) uint64 {
+	if isUnlimited(value) {
+		return math.MaxUint64
+	}
+	v, _ := strconv.ParseInt(value, 10, 64)
+	if item == "nice" {
+		// the nice rlimit is 20 - nice, so that it is in 1-40
+		return uint64(20 - v)
+	}
+	return uint64(v) * ulimitItems[item].scale
+}
+
+// checkUlimitDomain checks the user name, @group or the wildcard exists
+func checkUlimitDomain(domain string) error {
+	if domain == "*" {
+		return nil
+	}
+	if strings.ContainsAny(domain, " \t\r\n") {
+		return fmt.Errorf("it must not contain the whitespace")
+	}
+	if strings.HasPrefix(domain, "@") {
+		_, err := user.LookupGroup(domain[1:])
+		return err
+	}
+	_, err := user.Lookup(domain)
+	return err
+}
+
+// parseUlimitEntries parses item=value[,item2=value2] of the limits.conf items
+func parseUlimitEntries(limitsStr string) ([]ulimitEntry, error) {
+	entries := make([]ulimitEntry, 0)
+	items := make(map[string]bool)
+	for _, field := range strings.Split(limitsStr, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		item, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s must be item=value", field)
+		}
+		item, value = strings.TrimSpace(item), strings.TrimSpace(value)
+		limitItem, ok := ulimitItems[item]
+		if !ok {
+			return nil, fmt.Errorf("%s is not a limits.conf item", item)
+		}
+		if items[item] {
+			return nil, fmt.Errorf("%s is duplicated", item)
+		}
+		if err := checkUlimitValue(limitItem, value); err != nil {
+			return nil, fmt.Errorf("the value of %s is invalid, %v", item, err)
+		}
+		items[item] = true
+		entries = append(entries, ulimitEntry{Item: item, Value: value})
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no limit found")
+	}
+	return entries, nil
+}
+
+func checkUlimitValue(item ulimitItem, value string) error {
+	if isUnlimited(value) {
+		if item.priority {
+			return fmt.Errorf("it must be an integer")
+		}
+		return nil
+	}
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("it must be an integer or unlimited")
+	}
+	if item.priority {
+		if v < item.min || v > item.max {
+			return fmt.Errorf("it must be in %d-%d", item.min, item.max)
+		}
+		return nil
+	}
+	if v < 0 {
+		return fmt.Errorf("it must be a non-negative integer")
+	}
+	if uint64(v) > math.MaxUint64/item.scale {
+		return fmt.Errorf("it is too large")
+	}
+	return nil
+}
+
+func isUnlimited(value string) bool {
+	return value == "unlimited" || value == "infinity" || value == "-1"
+}
+
+// buildUlimitDropIn returns the limits.conf entries, both is the dash type of limits.conf
+func buildUlimitDropIn(uid, domain, limitType string, entries []ulimitEntry) string {
+	if limitType == UlimitTypeBoth {
+		limitType = "-"
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# created by chaosblade experiment %s\n", uid))
+	for _, entry := range entries {
+		sb.WriteString(fmt.Sprintf("%s %s %s %s\n", domain, limitType, entry.Item, entry.Value))
+	}
+	return sb.String()
+}
+
+func getUlimitDropInFile(uid string) string {
+	return fmt.Sprintf("%s/chaosblade-%s.conf", limitsDropInDir, uid)
+}
+
+func getUlimitStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-process-ulimit-%s.json", uid)
+}
+
+func writeUlimitState(stateFile string, state ulimitState) error {
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, bytes, 0600)
+}
+
+func readUlimitState(stateFile string) (ulimitState, error) {
+	var state ulimitState
+	bytes, err := os.ReadFile(stateFile)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(bytes, &state)
+	return state, err
+}
+
+func (upe *UlimitProcessExecutor) SetChannel(channel spec.Channel) {
+	upe.channel = channel
+}
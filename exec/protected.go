@@ -0,0 +1,239 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+)
+
+// ProtectedPathsKey is the flag of the file which lists the protected paths besides the built-in ones,
+// one path per line, the empty lines and the lines starting with # are ignored
+const ProtectedPathsKey = "protected-paths"
+
+// ForceFlagName is the action flag which allows the destructive file and disk experiments on a protected path
+const ForceFlagName = "force"
+
+// protectedPathsCtxKey is the context key of the paths loaded from the file of ProtectedPathsKey
+type protectedPathsCtxKey struct{}
+
+// WithProtectedPaths returns the context with the protected paths listed in the file, the context is
+// returned as is if the file is empty
+func WithProtectedPaths(ctx context.Context, file string) (context.Context, error) {
+	if file == "" {
+		return ctx, nil
+	}
+	paths, err := loadProtectedPaths(file)
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, protectedPathsCtxKey{}, paths), nil
+}
+
+func loadProtectedPaths(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("read the protected paths failed, %v", err)
+	}
+	defer f.Close()
+	paths := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !isAbsProtectedPath(line) {
+			return nil, fmt.Errorf("the protected path %s in %s isn't absolute", line, file)
+		}
+		paths = append(paths, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read the protected paths failed, %v", err)
+	}
+	return paths, nil
+}
+
+// ProtectedPaths returns the built-in protected paths, the installation of the blade and the paths loaded
+// by WithProtectedPaths
+func ProtectedPaths(ctx context.Context) []string {
+	paths := append([]string{}, defaultProtectedPaths...)
+	// the installation of the blade is the parent of its bin directory, the standalone binary is protected alone
+	installation := util.GetProgramPath()
+	if filepath.Base(installation) == spec.BinPath {
+		installation = filepath.Dir(installation)
+	} else if executable, err := os.Executable(); err == nil {
+		installation = executable
+	}
+	if isAbsProtectedPath(installation) && len(splitProtectedPath(installation)) > 0 {
+		paths = append(paths, installation)
+	}
+	if loaded, ok := ctx.Value(protectedPathsCtxKey{}).([]string); ok {
+		paths = append(paths, loaded...)
+	}
+	return paths
+}
+
+// CheckProtectedPath refuses the target of the flag if it resolves to a protected path or a path under it.
+// The target is resolved by following the symlinks, and a glob is matched against the protected paths as
+// it would be expanded. If recursive is true, the target which contains a protected path, such as /var
+// for /var/lib/etcd, is refused too. The callers skip the check if the force flag is specified.
+func CheckProtectedPath(ctx context.Context, cl spec.Channel, flag, target string, recursive bool) *spec.Response {
+	if target == "" {
+		return nil
+	}
+	candidates := []string{target}
+	if !hasGlobMeta(target) {
+		if resolved := resolveProtectedPath(ctx, cl, target); resolved != "" && resolved != target {
+			candidates = append(candidates, resolved)
+		}
+	}
+	protected := ProtectedPaths(ctx)
+	for _, candidate := range candidates {
+		if !isAbsProtectedPath(candidate) {
+			continue
+		}
+		prefix, contains := matchProtectedPath(candidate, protected, recursive)
+		if prefix == "" {
+			continue
+		}
+		var message string
+		if contains {
+			message = fmt.Sprintf("the %s %s contains the protected path %s, specify --%s to run the experiment anyway",
+				flag, target, prefix, ForceFlagName)
+		} else {
+			message = fmt.Sprintf("the %s %s is under the protected path %s, specify --%s to run the experiment anyway",
+				flag, target, prefix, ForceFlagName)
+		}
+		if candidate != target {
+			message = fmt.Sprintf("%s (resolved to %s)", message, candidate)
+		}
+		log.Errorf(ctx, "%s", message)
+		return Fail(ProtectedPathRefused, "protection", flag, message)
+	}
+	return nil
+}
+
+// CheckAllowedRoot refuses the target of the flag unless it's under the root, the root itself is refused too.
+// The target and the root are resolved by following the symlinks, so a symlink under the root can't lead out.
+func CheckAllowedRoot(ctx context.Context, cl spec.Channel, flag, target, root string) *spec.Response {
+	if root == "" {
+		return nil
+	}
+	roots := []string{root}
+	if resolved := resolveProtectedPath(ctx, cl, root); resolved != "" && resolved != root {
+		roots = append(roots, resolved)
+	}
+	candidates := []string{target}
+	if resolved := resolveProtectedPath(ctx, cl, target); resolved != "" && resolved != target {
+		candidates = append(candidates, resolved)
+	}
+	for _, candidate := range candidates {
+		if !isUnderPaths(candidate, roots) {
+			message := fmt.Sprintf("the %s %s is not under the allowed root %s", flag, target, root)
+			if candidate != target {
+				message = fmt.Sprintf("%s (resolved to %s)", message, candidate)
+			}
+			log.Errorf(ctx, "%s", message)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, flag, target, message)
+		}
+	}
+	return nil
+}
+
+// ResolvePath returns the path following the symlinks by the channel, or the path itself if it can't be resolved
+func ResolvePath(ctx context.Context, cl spec.Channel, p string) string {
+	if resolved := resolveProtectedPath(ctx, cl, p); resolved != "" {
+		return resolved
+	}
+	return p
+}
+
+// isUnderPaths returns true if the target is under one of the paths, but not equal to it
+func isUnderPaths(target string, paths []string) bool {
+	targetParts := splitProtectedPath(target)
+	for _, p := range paths {
+		parts := splitProtectedPath(p)
+		if len(targetParts) > len(parts) && slices.Equal(targetParts[:len(parts)], parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchProtectedPath returns the protected path which the target is equal to or under, or which the target
+// contains if recursive is true. The components of the target are matched as the glob patterns, so that
+// /e*/passwd matches /etc and /* contains all the protected paths.
+func matchProtectedPath(target string, protected []string, recursive bool) (string, bool) {
+	targetParts := splitProtectedPath(target)
+	for _, p := range protected {
+		protectedParts := splitProtectedPath(p)
+		n := len(protectedParts)
+		if len(targetParts) < n {
+			n = len(targetParts)
+		}
+		matched := true
+		for i := 0; i < n; i++ {
+			if !matchProtectedPart(targetParts[i], protectedParts[i]) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if len(targetParts) >= len(protectedParts) {
+			return p, false
+		}
+		if recursive {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+func matchProtectedPart(pattern, name string) bool {
+	if matched, err := path.Match(pattern, name); err == nil && matched {
+		return true
+	}
+	return pattern == name
+}
+
+func splitProtectedPath(p string) []string {
+	p = path.Clean(normalizeProtectedPath(p))
+	parts := make([]string, 0)
+	for _, part := range strings.Split(p, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func hasGlobMeta(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
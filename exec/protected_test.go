@@ -0,0 +1,135 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestMatchProtectedPath(t *testing.T) {
+	protected := []string{"/etc", "/var/lib/etcd"}
+	cases := []struct {
+		target    string
+		recursive bool
+		expected  string
+		contains  bool
+	}{
+		{"/etc", false, "/etc", false},
+		{"/etc/", false, "/etc", false},
+		{"/etc/kubernetes/admin.conf", false, "/etc", false},
+		{"/etc/../tmp/app.log", false, "", false},
+		{"/etcd/app.log", false, "", false},
+		{"/var/lib", false, "", false},
+		{"/var/lib", true, "/var/lib/etcd", true},
+		{"/", false, "", false},
+		{"/", true, "/etc", true},
+		{"/e*/passwd", false, "/etc", false},
+		{"/var/*/etcd", false, "/var/lib/etcd", false},
+		{"/var/lib/*", false, "/var/lib/etcd", false},
+		{"/var/l?b", true, "/var/lib/etcd", true},
+		{"/tmp/*", true, "", false},
+	}
+	for _, c := range cases {
+		prefix, contains := matchProtectedPath(c.target, protected, c.recursive)
+		if prefix != c.expected || contains != c.contains {
+			t.Errorf("%s (recursive %t): expected %q %t, actual %q %t",
+				c.target, c.recursive, c.expected, c.contains, prefix, contains)
+		}
+	}
+}
+
+func TestCheckProtectedPath(t *testing.T) {
+	cl := NewMockChannel().OnRun("readlink", "/tmp/etc-link", spec.ReturnSuccess("/etc\n"))
+	ctx := context.Background()
+
+	if response := CheckProtectedPath(ctx, cl, "filepath", "/tmp/app.log", true); response != nil {
+		t.Errorf("unexpected refusal, %s", response.Err)
+	}
+	response := CheckProtectedPath(ctx, cl, "filepath", "/tmp/etc-link/hosts", false)
+	if response == nil || response.Code != spec.Forbidden.Code {
+		t.Fatalf("expected the refusal of the symlink to the protected path, %+v", response)
+	}
+	if !strings.Contains(response.Err, "/etc") || !strings.Contains(response.Err, "--force") {
+		t.Errorf("the protected path isn't named, %s", response.Err)
+	}
+	if failure, ok := GetFailure(response); !ok || failure.Kind != ProtectedPathRefused.Name {
+		t.Errorf("unexpected failure, %+v", response.Result)
+	}
+	// the glob isn't resolved, it's matched as it would be expanded
+	cl.Reset()
+	if response := CheckProtectedPath(ctx, cl, "filepath", "/*", true); response == nil {
+		t.Errorf("expected the refusal of the glob containing the protected paths")
+	}
+	if commands := cl.CommandLines(); len(commands) != 0 {
+		t.Errorf("unexpected commands, %q", commands)
+	}
+}
+
+func TestCheckAllowedRoot(t *testing.T) {
+	cl := NewMockChannel().OnRun("readlink", "/data/app/link", spec.ReturnSuccess("/var/lib/mysql\n"))
+	ctx := context.Background()
+	for _, tt := range []struct {
+		target, root string
+		refused      bool
+	}{
+		{target: "/data/app/cache", root: ""},
+		{target: "/data/app/cache", root: "/data/app"},
+		{target: "/data/app/cache", root: "/data/app/"},
+		{target: "/data/app", root: "/data/app", refused: true},
+		{target: "/data/application", root: "/data/app", refused: true},
+		{target: "/data/app/../etc", root: "/data/app", refused: true},
+		{target: "/data/app/link", root: "/data/app", refused: true},
+	} {
+		response := CheckAllowedRoot(ctx, cl, "filepath", tt.target, tt.root)
+		if (response != nil) != tt.refused {
+			t.Errorf("CheckAllowedRoot(%s, %s) = %+v, refused %v", tt.target, tt.root, response, tt.refused)
+		}
+	}
+}
+
+func TestWithProtectedPaths(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "protected")
+	if err := os.WriteFile(file, []byte("# the data of the database\n/data/mysql\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := WithProtectedPaths(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl := NewMockChannel()
+	if response := CheckProtectedPath(ctx, cl, "path", "/data/mysql/ibdata1", false); response == nil {
+		t.Errorf("expected the refusal of the path loaded from the file")
+	}
+	if response := CheckProtectedPath(context.Background(), cl, "path", "/data/mysql/ibdata1", false); response != nil {
+		t.Errorf("unexpected refusal without the file, %s", response.Err)
+	}
+
+	if err := os.WriteFile(file, []byte("data/mysql\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WithProtectedPaths(context.Background(), file); err == nil {
+		t.Errorf("expected the error of the relative path")
+	}
+}
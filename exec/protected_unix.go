@@ -0,0 +1,51 @@
+//go:build !windows
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+var defaultProtectedPaths = []string{
+	"/bin", "/boot", "/dev", "/etc", "/lib", "/lib64", "/proc", "/sbin", "/sys", "/usr",
+	"/var/lib/etcd", "/var/lib/kubelet", "/var/lib/docker", "/var/lib/containerd",
+}
+
+// resolveProtectedPath follows the symlinks of the path by the channel, so that the path in the container is
+// resolved in its mount namespace. The empty path is returned if it can't be resolved.
+func resolveProtectedPath(ctx context.Context, cl spec.Channel, p string) string {
+	response := RunReadOnlyArgv(ctx, cl, "readlink", "-f", "--", p)
+	if !response.Success {
+		return ""
+	}
+	resolved, _ := response.Result.(string)
+	return strings.TrimSpace(resolved)
+}
+
+func normalizeProtectedPath(p string) string {
+	return p
+}
+
+func isAbsProtectedPath(p string) bool {
+	return path.IsAbs(p)
+}
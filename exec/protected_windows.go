@@ -0,0 +1,46 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+var defaultProtectedPaths = []string{
+	`C:\Windows`, `C:\Program Files`, `C:\Program Files (x86)`,
+}
+
+func resolveProtectedPath(ctx context.Context, cl spec.Channel, p string) string {
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return ""
+	}
+	return resolved
+}
+
+// normalizeProtectedPath compares the paths case-insensitively by the slashes
+func normalizeProtectedPath(p string) string {
+	return strings.ToLower(filepath.ToSlash(p))
+}
+
+func isAbsProtectedPath(p string) bool {
+	return filepath.IsAbs(p)
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
+)
+
+// Readiness is whether the action works on the host
+type Readiness string
+
+const (
+	ReadinessReady Readiness = "ready"
+	// ReadinessDegraded means the action works, but some options or fallbacks don't
+	ReadinessDegraded Readiness = "degraded"
+	// ReadinessUnavailable means the action fails on the host
+	ReadinessUnavailable Readiness = "unavailable"
+)
+
+// ActionReadiness is the readiness of an action with the reasons why it's not ready
+type ActionReadiness struct {
+	Target    string    `json:"target"`
+	Action    string    `json:"action"`
+	Readiness Readiness `json:"readiness"`
+	Reasons   []string  `json:"reasons,omitempty"`
+}
+
+// ReadinessReport is the result of the environment self-check
+type ReadinessReport struct {
+	Platform       string           `json:"platform"`
+	CGroupVersion  string           `json:"cgroupVersion"`
+	CGroupRoot     string           `json:"cgroupRoot"`
+	KernelFeatures []*KernelFeature `json:"kernelFeatures"`
+	// Ready, Degraded and Unavailable are the counts of the actions
+	Ready       int                `json:"ready"`
+	Degraded    int                `json:"degraded"`
+	Unavailable int                `json:"unavailable"`
+	Actions     []*ActionReadiness `json:"actions"`
+}
+
+// CheckReadiness checks all the actions of the models on the host by the requirements of the preflight,
+// the cgroup version and the kernel features, the results are sorted by the target and the action
+func CheckReadiness(ctx context.Context, cl spec.Channel, models []spec.ExpModelCommandSpec) *ReadinessReport {
+	cgroupRoot := cgroups.DetectCGroupRoot(ctx)
+	features := ProbeKernelFeatures()
+	report := &ReadinessReport{
+		Platform:       runtime.GOOS,
+		CGroupVersion:  cgroupVersionName(cgroupRoot.Version),
+		CGroupRoot:     cgroupRoot.Path,
+		KernelFeatures: make([]*KernelFeature, 0, len(features)),
+		Actions:        make([]*ActionReadiness, 0),
+	}
+	for _, feature := range features {
+		report.KernelFeatures = append(report.KernelFeatures, feature)
+	}
+	sort.Slice(report.KernelFeatures, func(i, j int) bool {
+		return report.KernelFeatures[i].Name < report.KernelFeatures[j].Name
+	})
+
+	// the actions share the commands, such as rm, so they are looked up once
+	cl = &cachedCommandChannel{Channel: cl, commands: make(map[string]bool)}
+	for _, result := range PreflightAll(ctx, cl, models) {
+		readiness := checkActionReadiness(ctx, cl, result, cgroupRoot, features)
+		switch readiness.Readiness {
+		case ReadinessReady:
+			report.Ready++
+		case ReadinessDegraded:
+			report.Degraded++
+		default:
+			report.Unavailable++
+		}
+		report.Actions = append(report.Actions, readiness)
+	}
+	return report
+}
+
+// checkActionReadiness degrades the preflight result by the optional requirements
+func checkActionReadiness(ctx context.Context, cl spec.Channel, result *PreflightResult,
+	cgroupRoot *cgroups.CGroupRoot, features map[string]*KernelFeature) *ActionReadiness {
+	readiness := &ActionReadiness{Target: result.Target, Action: result.Action, Readiness: ReadinessReady}
+	unavailable := func(reason string) {
+		readiness.Readiness = ReadinessUnavailable
+		readiness.Reasons = append(readiness.Reasons, reason)
+	}
+	degraded := func(reason string) {
+		if readiness.Readiness == ReadinessReady {
+			readiness.Readiness = ReadinessDegraded
+		}
+		readiness.Reasons = append(readiness.Reasons, reason)
+	}
+
+	if result.Error != "" {
+		degraded(result.Error)
+	}
+	for _, capability := range result.MissingCapabilities {
+		unavailable(fmt.Sprintf("missing capability %s", capability))
+	}
+	for _, command := range result.MissingCommands {
+		unavailable(fmt.Sprintf("command %s not found", command))
+	}
+	requirement := GetRequirement(result.Target, result.Action)
+	for _, name := range requirement.KernelFeatures {
+		feature := features[name]
+		switch {
+		case feature == nil:
+		case feature.State == FeatureUnavailable:
+			unavailable(fmt.Sprintf("kernel feature %s is unavailable, %s", name, feature.Detail))
+		case feature.State == FeatureUnknown:
+			degraded(fmt.Sprintf("kernel feature %s is unknown, %s", name, feature.Detail))
+		}
+	}
+	for _, command := range requirement.OptionalCommands {
+		if !cl.IsCommandAvailable(ctx, command) {
+			degraded(fmt.Sprintf("optional command %s not found, the fallback is used", command))
+		}
+	}
+	if requirement.CGroup && cgroupRoot.Version == cgroups.CGroupUnknown {
+		degraded(fmt.Sprintf("cgroup is not found, the cgroup options are unavailable, %s", cgroupRoot.Evidence))
+	}
+	return readiness
+}
+
+func cgroupVersionName(version cgroups.CGroupVersion) string {
+	switch version {
+	case cgroups.CGroupV1:
+		return "v1"
+	case cgroups.CGroupV2:
+		return "v2"
+	case cgroups.CGroupHybrid:
+		return "hybrid"
+	}
+	return "unknown"
+}
+
+// cachedCommandChannel caches the results of IsCommandAvailable
+type cachedCommandChannel struct {
+	spec.Channel
+	lock     sync.Mutex
+	commands map[string]bool
+}
+
+func (c *cachedCommandChannel) IsCommandAvailable(ctx context.Context, commandName string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	available, ok := c.commands[commandName]
+	if !ok {
+		available = c.Channel.IsCommandAvailable(ctx, commandName)
+		c.commands[commandName] = available
+	}
+	return available
+}
@@ -18,11 +18,15 @@ package script
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 )
 
@@ -33,27 +37,35 @@ type ScriptCommandModelSpec struct {
 func NewScriptCommandModelSpec() spec.ExpModelCommandSpec {
 	return &ScriptCommandModelSpec{
 		spec.BaseExpModelCommandSpec{
-			ExpFlags: []spec.ExpFlagSpec{
-				&spec.ExpFlag{
-					Name:                  "file",
-					Desc:                  "Script file full path",
-					Required:              true,
-					RequiredWhenDestroyed: true,
-				},
-				&spec.ExpFlag{
-					Name:     "function-name",
-					Desc:     "function name in shell",
-					Required: true,
-				},
-			},
+			ExpFlags: []spec.ExpFlagSpec{},
 			ExpActions: []spec.ExpActionCommandSpec{
 				NewScriptDelayActionCommand(),
 				NewScriptExitActionCommand(),
+				NewScriptHangActionCommand(),
+				NewScriptCronActionCommand(),
 			},
 		},
 	}
 }
 
+var scriptCommFlags = []spec.ExpFlagSpec{
+	&spec.ExpFlag{
+		Name:                  "file",
+		Desc:                  "Script file full path",
+		Required:              true,
+		RequiredWhenDestroyed: true,
+	},
+	&spec.ExpFlag{
+		Name: "function-name",
+		Desc: "function name in shell or python, it is required for shell script. The content is injected at the top of the python module if it is absent",
+	},
+	&spec.ExpFlag{
+		Name:    "script-type",
+		Desc:    "script type, support shell, python and executable. The executable is wrapped by a shim script",
+		Default: ScriptTypeShell,
+	},
+}
+
 func (*ScriptCommandModelSpec) Name() string {
 	return "script"
 }
@@ -66,38 +78,217 @@ func (*ScriptCommandModelSpec) LongDesc() string {
 	return "Script chaos experiment"
 }
 
+const (
+	ScriptTypeShell      = "shell"
+	ScriptTypePython     = "python"
+	ScriptTypeExecutable = "executable"
+)
+
 const bakFileSuffix = "_chaosblade.bak"
+const checksumFileSuffix = ".sha256"
+
+// the markers around the injected content, which are used to find the injection of any experiment
+const (
+	injectBeginMarker = "# chaosblade-inject-begin"
+	injectEndMarker   = "# chaosblade-inject-end"
+	shimMarker        = "# chaosblade-shim"
+)
+
+var scriptCommands = []string{"cat", "cp", "rm", "sed", "awk", "grep", "sha256sum"}
+
+// scriptSnippet is the injected content for each script type, the shell one is used by the executable shim too
+type scriptSnippet struct {
+	shell  string
+	python string
+}
+
+// getScriptFile checks the commands and the script file which all the script actions require
+func getScriptFile(ctx context.Context, channel spec.Channel, model *spec.ExpModel) (string, *spec.Response) {
+	if response, ok := channel.IsAllCommandsAvailable(ctx, scriptCommands); !ok {
+		return "", response
+	}
+	scriptFile := model.ActionFlags["file"]
+	if scriptFile == "" {
+		log.Errorf(ctx, "file is nil")
+		return "", spec.ResponseFailWithFlags(spec.ParameterLess, "file")
+	}
+	if !exec.CheckFilepathExists(ctx, channel, scriptFile) {
+		log.Errorf(ctx, "`%s`, file is invalid. it not found", scriptFile)
+		return "", spec.ResponseFailWithFlags(spec.ParameterInvalid, "file", scriptFile, "it is not found")
+	}
+	return scriptFile, nil
+}
 
-// backScript
-func backScript(ctx context.Context, channel spec.Channel, scriptFile string) *spec.Response {
-	bakFile := getBackFile(scriptFile)
+// getScriptType returns the script type and the function name to inject
+func getScriptType(ctx context.Context, model *spec.ExpModel) (string, string, *spec.Response) {
+	functionName := model.ActionFlags["function-name"]
+	scriptType := model.ActionFlags["script-type"]
+	if scriptType == "" {
+		scriptType = ScriptTypeShell
+	}
+	return scriptType, functionName, checkScriptType(ctx, scriptType, functionName)
+}
+
+// checkScriptType checks the script type and the function name it requires
+func checkScriptType(ctx context.Context, scriptType, functionName string) *spec.Response {
+	switch scriptType {
+	case ScriptTypeShell:
+		if functionName == "" {
+			log.Errorf(ctx, "function-name is nil")
+			return spec.ResponseFailWithFlags(spec.ParameterLess, "function-name")
+		}
+	case ScriptTypePython, ScriptTypeExecutable:
+	default:
+		log.Errorf(ctx, "`%s`: script-type is illegal", scriptType)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "script-type", scriptType, "only support shell, python and executable")
+	}
+	return nil
+}
+
+// injectScript backs up the script file and injects the snippet by the script type. The script which has been
+// injected by any experiment is refused, the injection of which would be resurrected or corrupted by the restore.
+func injectScript(ctx context.Context, channel spec.Channel, uid, scriptType, scriptFile, functionName string, snippet scriptSnippet) *spec.Response {
+	response := channel.Run(ctx, "grep", fmt.Sprintf(`-oE '(%s|%s) [^ ]*' %s | head -1`, injectBeginMarker, shimMarker,
+		exec.ShellQuote(scriptFile)))
+	if response.Success {
+		if marker := strings.TrimSpace(response.Result.(string)); marker != "" {
+			log.Errorf(ctx, "`%s`: the script has been injected, %s", scriptFile, marker)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "file", scriptFile,
+				fmt.Sprintf("the script has been injected by the experiment %s", marker[strings.LastIndex(marker, " ")+1:]))
+		}
+	}
+	response = backScript(ctx, channel, scriptFile, uid)
+	if !response.Success {
+		return response
+	}
+	switch scriptType {
+	case ScriptTypePython:
+		response = insertContentToPythonBy(ctx, channel, functionName, snippet.python, scriptFile, uid)
+	case ScriptTypeExecutable:
+		response = wrapExecutableBy(ctx, channel, snippet.shell, scriptFile, uid)
+	default:
+		response = insertContentToScriptBy(ctx, channel, functionName, markInjection(snippet.shell, "", uid), scriptFile)
+	}
+	if !response.Success {
+		recoverScript(ctx, channel, scriptFile, uid)
+	}
+	return response
+}
+
+// markInjection wraps the content with the markers of the experiment, every line is indented
+func markInjection(content, indent, uid string) string {
+	return fmt.Sprintf("%s%s %s\n%s%s\n%s%s %s", indent, injectBeginMarker, uid, indent, content, indent, injectEndMarker, uid)
+}
+
+// backScript copies the script with its mode and records the checksum of the backup.
+// The existing backup means the experiment is running on the script, so it is refused.
+func backScript(ctx context.Context, channel spec.Channel, scriptFile, uid string) *spec.Response {
+	bakFile := getBackFile(scriptFile, uid)
 	if exec.CheckFilepathExists(ctx, channel, bakFile) {
 		return spec.ResponseFailWithFlags(spec.BackfileExists, bakFile)
 	}
-	return channel.Run(ctx, "cat", fmt.Sprintf("%s > %s", scriptFile, bakFile))
+	response := exec.RunArgv(ctx, channel, "cp", "-p", "--", scriptFile, bakFile)
+	if !response.Success {
+		return response
+	}
+	response = channel.Run(ctx, "sha256sum", fmt.Sprintf("%s | awk '{print $1}' > %s", exec.ShellQuote(bakFile),
+		exec.ShellQuote(getChecksumFile(scriptFile, uid))))
+	if !response.Success {
+		exec.RunArgv(ctx, channel, "rm", "-rf", "--", bakFile)
+	}
+	return response
 }
 
-func recoverScript(ctx context.Context, channel spec.Channel, scriptFile string) *spec.Response {
-	bakFile := getBackFile(scriptFile)
+// recoverScript restores the script from the backup after verifying its checksum. If the script was changed
+// during the experiment, such as a deployment, the new one is kept and only the injected content is removed.
+// The experiment created by the old versions left the backup without uid and checksum, it's restored directly.
+func recoverScript(ctx context.Context, channel spec.Channel, scriptFile, uid string) *spec.Response {
+	bakFile := getBackFile(scriptFile, uid)
 	if !exec.CheckFilepathExists(ctx, channel, bakFile) {
-		return spec.ResponseFailWithFlags(spec.FileNotExist, bakFile)
+		legacyBakFile := getBackFile(scriptFile, "")
+		if uid == "" || !exec.CheckFilepathExists(ctx, channel, legacyBakFile) {
+			return spec.ResponseFailWithFlags(spec.FileNotExist, bakFile)
+		}
+		log.Infof(ctx, "the backup %s is not found, use the backup %s of the old version", bakFile, legacyBakFile)
+		bakFile, uid = legacyBakFile, ""
+	}
+	checksumFile := getChecksumFile(scriptFile, uid)
+	if !exec.CheckFilepathExists(ctx, channel, checksumFile) {
+		if uid == "" {
+			log.Warnf(ctx, "the checksum file %s of the backup of the old version is not found, restore it directly", checksumFile)
+			response := channel.Run(ctx, "cat", fmt.Sprintf("%s > %s", exec.ShellQuote(bakFile), exec.ShellQuote(scriptFile)))
+			if !response.Success {
+				return response
+			}
+			return exec.RunArgv(ctx, channel, "rm", "-rf", "--", bakFile)
+		}
+		log.Errorf(ctx, "the checksum file %s of the backup is not found", checksumFile)
+		return spec.ResponseFailWithFlags(spec.FileNotExist, checksumFile)
 	}
-	response := channel.Run(ctx, "cat", fmt.Sprintf("%s > %s", bakFile, scriptFile))
+	expected := exec.RunReadOnlyArgv(ctx, channel, "cat", "--", checksumFile)
+	if !expected.Success {
+		return expected
+	}
+	checksum := strings.TrimSpace(expected.Result.(string))
+	actual := channel.Run(ctx, "sha256sum", fmt.Sprintf("%s | awk '{print $1}'", exec.ShellQuote(bakFile)))
+	if !actual.Success {
+		return actual
+	}
+	if checksum != strings.TrimSpace(actual.Result.(string)) {
+		log.Errorf(ctx, "the checksum of the backup file %s mismatches, it may be tampered or truncated", bakFile)
+		return spec.ReturnFail(spec.OsCmdExecFailed,
+			fmt.Sprintf("the checksum of the backup file %s mismatches, it may be tampered or truncated", bakFile))
+	}
+
+	response := restoreOrStrip(ctx, channel, scriptFile, bakFile, checksum, uid)
 	if !response.Success {
 		return response
 	}
-	return channel.Run(ctx, "rm", fmt.Sprintf("-rf %s", bakFile))
+	return exec.RunArgv(ctx, channel, "rm", "-rf", "--", bakFile, checksumFile)
 }
 
-func getBackFile(scriptFile string) string {
-	return scriptFile + bakFileSuffix
+func restoreOrStrip(ctx context.Context, channel spec.Channel, scriptFile, bakFile, checksum, uid string) *spec.Response {
+	restore := func() *spec.Response {
+		return channel.Run(ctx, "cat", fmt.Sprintf("%s > %s", exec.ShellQuote(bakFile), exec.ShellQuote(scriptFile)))
+	}
+	if !exec.CheckFilepathExists(ctx, channel, scriptFile) {
+		return restore()
+	}
+	// the shim of the executable is generated, it is restored as a whole
+	response := channel.Run(ctx, "grep", fmt.Sprintf(`-c '^%s %s$' %s`, shimMarker, uid, exec.ShellQuote(scriptFile)))
+	if response.Success && strings.TrimSpace(response.Result.(string)) != "0" {
+		return restore()
+	}
+	deleteInjection := fmt.Sprintf(`'/%s %s$/,/%s %s$/d'`, injectBeginMarker, uid, injectEndMarker, uid)
+	stripped := channel.Run(ctx, "sed", fmt.Sprintf(`%s %s | sha256sum | awk '{print $1}'`, deleteInjection,
+		exec.ShellQuote(scriptFile)))
+	if !stripped.Success {
+		return stripped
+	}
+	if strings.TrimSpace(stripped.Result.(string)) == checksum {
+		return restore()
+	}
+	log.Warnf(ctx, "the script %s was changed during the experiment, keep it and remove the injected content only", scriptFile)
+	return channel.Run(ctx, "sed", fmt.Sprintf(`-i %s %s`, deleteInjection, exec.ShellQuote(scriptFile)))
+}
+
+// getBackFile returns the backup file of the experiment, the one without uid is used by the old versions
+func getBackFile(scriptFile, uid string) string {
+	if uid == "" {
+		return scriptFile + bakFileSuffix
+	}
+	return fmt.Sprintf("%s_chaosblade_%s.bak", scriptFile, uid)
+}
+
+func getChecksumFile(scriptFile, uid string) string {
+	return getBackFile(scriptFile, uid) + checksumFileSuffix
 }
 
 // awk '/offline\s?\(\)\s*\{/{print NR}' tt.sh
 // sed -i '416 a sleep 100' tt.sh
 func insertContentToScriptBy(ctx context.Context, channel spec.Channel, functionName string, newContent, scriptFile string) *spec.Response {
 	// search line number by function name
-	response := channel.Run(ctx, "awk", fmt.Sprintf(`'/%s *\(\) *\{/{print NR}' %s`, functionName, scriptFile))
+	response := channel.Run(ctx, "awk", fmt.Sprintf(`'/%s *\(\) *\{/{print NR}' %s`, functionName, exec.ShellQuote(scriptFile)))
 	if !response.Success {
 		return response
 	}
@@ -113,5 +304,151 @@ func insertContentToScriptBy(ctx context.Context, channel spec.Channel, function
 	}
 	lineNum := lineNums[0]
 	// insert content to the line below
-	return channel.Run(ctx, "sed", fmt.Sprintf(`-i '%s a %s' %s`, lineNum, newContent, scriptFile))
+	return channel.Run(ctx, "sed", fmt.Sprintf(`-i '%s a %s' %s`, lineNum, escapeSedText(newContent), exec.ShellQuote(scriptFile)))
+}
+
+// insertContentToPythonBy inserts the content at the top of the body of the python function, or below
+// the shebang, coding, docstring and __future__ lines of the module if the function name is empty
+func insertContentToPythonBy(ctx context.Context, channel spec.Channel, functionName string, newContent, scriptFile, uid string) *spec.Response {
+	response := exec.RunReadOnlyArgv(ctx, channel, "cat", "--", scriptFile)
+	if !response.Success {
+		return response
+	}
+	lines := strings.Split(response.Result.(string), "\n")
+	lineNum, indent, err := findPythonInsertPoint(lines, functionName)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "function-name", functionName, err.Error())
+	}
+	content := escapeSedText(markInjection(newContent, indent, uid))
+	if lineNum == 0 {
+		return channel.Run(ctx, "sed", fmt.Sprintf(`-i '1 i\\%s' %s`, content, exec.ShellQuote(scriptFile)))
+	}
+	return channel.Run(ctx, "sed", fmt.Sprintf(`-i '%d a\\%s' %s`, lineNum, content, exec.ShellQuote(scriptFile)))
+}
+
+var pythonCodingRegex = regexp.MustCompile(`^[ \t\f]*#.*?coding[:=]`)
+
+// findPythonInsertPoint returns the number of the line which the content is inserted below, and the indent
+func findPythonInsertPoint(lines []string, functionName string) (int, string, error) {
+	if functionName == "" {
+		pos := 0
+		for pos < len(lines) && pos < 2 && (strings.HasPrefix(lines[pos], "#!") || pythonCodingRegex.MatchString(lines[pos])) {
+			pos++
+		}
+		next := skipPythonBlankLines(lines, pos)
+		if end, ok := findPythonDocstringEnd(lines, next); ok {
+			pos = end + 1
+			next = skipPythonBlankLines(lines, pos)
+		}
+		for next < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[next]), "from __future__ import") {
+			end := next
+			if strings.Contains(lines[next], "(") {
+				for end < len(lines) && !strings.Contains(lines[end], ")") {
+					end++
+				}
+			}
+			pos = end + 1
+			next = skipPythonBlankLines(lines, pos)
+		}
+		return pos, "", nil
+	}
+
+	defRegex, err := regexp.Compile(fmt.Sprintf(`^(\s*)(async\s+)?def\s+%s\s*\(`, regexp.QuoteMeta(functionName)))
+	if err != nil {
+		return 0, "", err
+	}
+	defLine := -1
+	defIndent := ""
+	for i, line := range lines {
+		if matches := defRegex.FindStringSubmatch(line); matches != nil {
+			if defLine >= 0 {
+				return 0, "", errors.New("the function name must be unique in the script")
+			}
+			defLine = i
+			defIndent = matches[1]
+		}
+	}
+	if defLine < 0 {
+		return 0, "", errors.New("cannot find the function name in the script")
+	}
+	// the signature may span multiple lines, it ends with the colon
+	headerEnd := -1
+	for i := defLine; i < len(lines); i++ {
+		code := strings.TrimSpace(strings.SplitN(lines[i], "#", 2)[0])
+		if strings.HasSuffix(code, ":") {
+			headerEnd = i
+			break
+		}
+	}
+	if headerEnd < 0 {
+		return 0, "", errors.New("cannot find the body of the function, the one-line function is not supported")
+	}
+	body := skipPythonBlankLines(lines, headerEnd+1)
+	if body >= len(lines) {
+		return 0, "", errors.New("cannot find the body of the function")
+	}
+	indent := lines[body][:len(lines[body])-len(strings.TrimLeft(lines[body], " \t"))]
+	if len(indent) <= len(defIndent) {
+		return 0, "", errors.New("cannot find the body of the function")
+	}
+	if end, ok := findPythonDocstringEnd(lines, body); ok {
+		return end + 1, indent, nil
+	}
+	return headerEnd + 1, indent, nil
+}
+
+// skipPythonBlankLines returns the index of the first line which is not blank or comment
+func skipPythonBlankLines(lines []string, from int) int {
+	for from < len(lines) {
+		line := strings.TrimSpace(lines[from])
+		if line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+		from++
+	}
+	return from
+}
+
+// findPythonDocstringEnd returns the index of the last line of the docstring starting at the line
+func findPythonDocstringEnd(lines []string, start int) (int, bool) {
+	if start >= len(lines) {
+		return 0, false
+	}
+	line := strings.TrimLeft(strings.TrimSpace(lines[start]), "rRuUbB")
+	for _, quote := range []string{`"""`, `'''`} {
+		if !strings.HasPrefix(line, quote) {
+			continue
+		}
+		if strings.Count(line, quote) >= 2 {
+			return start, true
+		}
+		for i := start + 1; i < len(lines); i++ {
+			if strings.Contains(lines[i], quote) {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// wrapExecutableBy replaces the executable with a shim script, which runs the content
+// and then executes the original one preserved as the backup file
+func wrapExecutableBy(ctx context.Context, channel spec.Channel, newContent, scriptFile, uid string) *spec.Response {
+	shim := fmt.Sprintf("#!/bin/sh\n%s %s\n%s\nexec %s \"$@\"\n", shimMarker, uid, newContent,
+		exec.ShellQuote(getBackFile(scriptFile, uid)))
+	return channel.Run(ctx, "printf", fmt.Sprintf("%%s %s > %s", exec.ShellQuote(shim), exec.ShellQuote(scriptFile)))
+}
+
+// pythonQuote returns the python string literal of the value
+func pythonQuote(value string) string {
+	bytes, _ := json.Marshal(value)
+	return string(bytes)
+}
+
+// escapeSedText escapes the text of the sed a and i commands in the single quoted script,
+// the newline is kept by the backslash continuation
+func escapeSedText(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, "\n", "\\\n")
+	return strings.ReplaceAll(text, "'", `'\''`)
 }
@@ -0,0 +1,447 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const cronSystemDir = "/etc/cron.d"
+
+type ScriptCronActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewScriptCronActionCommand() spec.ExpActionCommandSpec {
+	return &ScriptCronActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     "schedule",
+					Desc:     "Cron schedule expression with five fields, or one of @reboot, @hourly, @daily, @weekly, @monthly, @yearly",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name:     "command",
+					Desc:     "The command which the cron entry runs",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name: "user",
+					Desc: "The user who runs the command. It is the owner of the crontab, or the user field of the system cron file, default is the current user, root for the system cron file",
+				},
+				&spec.ExpFlag{
+					Name:   "system",
+					Desc:   "Install the entry into a file under /etc/cron.d instead of the user crontab",
+					NoArgs: true,
+				},
+			},
+			ActionExecutor: &ScriptCronExecutor{},
+			ActionExample: `
+# Run the command every minute by the crontab of the current user
+blade create script cron --schedule "* * * * *" --command "/opt/app/bin/cleanup.sh"
+
+# Run the command every 5 minutes by the crontab of the user admin
+blade create script cron --schedule "*/5 * * * *" --command "rm -rf /tmp/app-cache" --user admin
+
+# Install the entry into /etc/cron.d, which runs the command by root at 3 o'clock every day
+blade create script cron --schedule "0 3 * * *" --command "systemctl restart nginx" --system`,
+			ActionCategories: []string{category.SystemScript},
+		},
+	}
+}
+
+func (*ScriptCronActionCommand) Name() string {
+	return "cron"
+}
+
+func (*ScriptCronActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*ScriptCronActionCommand) ShortDesc() string {
+	return "Install cron entry"
+}
+
+func (s *ScriptCronActionCommand) LongDesc() string {
+	if s.ActionLongDesc != "" {
+		return s.ActionLongDesc
+	}
+	return "Install a cron entry which runs the command on the schedule. The entry is tagged with the experiment uid, and only the tagged entries are removed when the experiment is destroyed"
+}
+
+type ScriptCronExecutor struct {
+	channel spec.Channel
+}
+
+func (*ScriptCronExecutor) Name() string {
+	return "cron"
+}
+
+// cronState is the crontab captured before the experiment, which is used to restore it
+type cronState struct {
+	User      string `json:"user"`
+	System    bool   `json:"system"`
+	NoCrontab bool   `json:"noCrontab"`
+	Original  string `json:"original"`
+}
+
+func (sce *ScriptCronExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	user := model.ActionFlags["user"]
+	system := model.ActionFlags["system"] == "true"
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return sce.stop(ctx, uid, user, system)
+	}
+	schedule := strings.TrimSpace(model.ActionFlags["schedule"])
+	if schedule == "" {
+		log.Errorf(ctx, "schedule is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "schedule")
+	}
+	if err := validateCronSchedule(schedule); err != nil {
+		log.Errorf(ctx, "`%s`: schedule is illegal, %v", schedule, err)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "schedule", schedule, err)
+	}
+	command := strings.TrimSpace(model.ActionFlags["command"])
+	if command == "" {
+		log.Errorf(ctx, "command is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "command")
+	}
+	if strings.ContainsAny(command, "\r\n") {
+		log.Errorf(ctx, "`%s`: command must be a single line", command)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "command", command, "it must be a single line")
+	}
+	if system {
+		return sce.startSystem(ctx, uid, user, schedule, command)
+	}
+	return sce.start(ctx, uid, user, schedule, command)
+}
+
+// start appends the tagged entry to the user crontab, the original one is saved per uid
+func (sce *ScriptCronExecutor) start(ctx context.Context, uid, user, schedule, command string) *spec.Response {
+	if !sce.channel.IsCommandAvailable(ctx, "crontab") {
+		log.Errorf(ctx, "`crontab`: command not found")
+		return spec.ResponseFailWithFlags(spec.CommandIllegal, "`crontab`: command not found")
+	}
+	stateFile := getCronStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the cron state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	original, noCrontab, response := readCrontab(ctx, sce.channel, user)
+	if response != nil {
+		return response
+	}
+	if hasCronTag(original, uid) {
+		log.Errorf(ctx, "the crontab already contains the entry of %s", uid)
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "uid", uid, "the crontab already contains the entry of the experiment")
+	}
+	state := cronState{User: user, NoCrontab: noCrontab, Original: original}
+	if err := writeCronState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write cron state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writeCronState", err)
+	}
+	content := original
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += fmt.Sprintf("%s %s %s\n", schedule, escapeCronCommand(command), getCronTag(uid))
+	if response := writeCrontab(ctx, sce.channel, user, content); !response.Success {
+		os.Remove(stateFile)
+		return response
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+// startSystem writes the tagged entry into a file under /etc/cron.d, which is removed when destroyed
+func (sce *ScriptCronExecutor) startSystem(ctx context.Context, uid, user, schedule, command string) *spec.Response {
+	if user == "" {
+		user = "root"
+	}
+	cronFile := getCronSystemFile(uid)
+	if response := sce.channel.Run(ctx, "test", fmt.Sprintf("-e %s", cronFile)); response.Success {
+		log.Errorf(ctx, "`%s`: the cron file exists", cronFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, cronFile)
+	}
+	stateFile := getCronStateFile(uid)
+	if err := writeCronState(stateFile, cronState{User: user, System: true}); err != nil {
+		log.Errorf(ctx, "write cron state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writeCronState", err)
+	}
+	entry := fmt.Sprintf("%s %s %s %s", schedule, user, escapeCronCommand(command), getCronTag(uid))
+	content := fmt.Sprintf("SHELL=/bin/sh\nPATH=/usr/local/sbin:/usr/local/bin:/sbin:/bin:/usr/sbin:/usr/bin\n%s\n", entry)
+	response := sce.channel.Run(ctx, "printf", fmt.Sprintf("%%s %s > %s && chmod 0644 %s", exec.ShellQuote(content),
+		exec.ShellQuote(cronFile), exec.ShellQuote(cronFile)))
+	if !response.Success {
+		sce.channel.Run(ctx, "rm", fmt.Sprintf("-f %s", cronFile))
+		os.Remove(stateFile)
+		return response
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+// stop removes exactly the entries tagged with the uid, and removes the crontab if the user had none
+func (sce *ScriptCronExecutor) stop(ctx context.Context, uid, user string, system bool) *spec.Response {
+	stateFile := getCronStateFile(uid)
+	state, err := readCronState(stateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf(ctx, "read cron state failed, %v", err)
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "readCronState", err)
+		}
+		log.Warnf(ctx, "`%s`: the cron state file not found, remove the tagged entries only", stateFile)
+		state = cronState{User: user, System: system}
+	}
+	if state.System {
+		if response := sce.channel.Run(ctx, "rm", fmt.Sprintf("-f %s", getCronSystemFile(uid))); !response.Success {
+			return response
+		}
+	} else if response := sce.removeCronEntries(ctx, uid, state); response != nil {
+		return response
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+func (sce *ScriptCronExecutor) removeCronEntries(ctx context.Context, uid string, state cronState) *spec.Response {
+	if !sce.channel.IsCommandAvailable(ctx, "crontab") {
+		log.Errorf(ctx, "`crontab`: command not found")
+		return spec.ResponseFailWithFlags(spec.CommandIllegal, "`crontab`: command not found")
+	}
+	current, noCrontab, response := readCrontab(ctx, sce.channel, state.User)
+	if response != nil {
+		return response
+	}
+	if noCrontab || !hasCronTag(current, uid) {
+		log.Infof(ctx, "the crontab does not contain the entry of %s", uid)
+		return nil
+	}
+	content := removeCronTag(current, uid)
+	// the crontab is restored to the original one byte-for-byte if it is not changed by others during the experiment
+	if strings.TrimSpace(content) == strings.TrimSpace(state.Original) {
+		content = state.Original
+		if state.NoCrontab {
+			args := "-r"
+			if state.User != "" {
+				args = fmt.Sprintf("-u %s -r", state.User)
+			}
+			if response := sce.channel.Run(ctx, "crontab", args); !response.Success {
+				return response
+			}
+			return nil
+		}
+	}
+	if response := writeCrontab(ctx, sce.channel, state.User, content); !response.Success {
+		return response
+	}
+	return nil
+}
+
+func (sce *ScriptCronExecutor) SetChannel(channel spec.Channel) {
+	sce.channel = channel
+}
+
+// readCrontab returns the crontab of the user, and whether the user has no crontab
+func readCrontab(ctx context.Context, channel spec.Channel, user string) (string, bool, *spec.Response) {
+	args := "-l"
+	if user != "" {
+		args = fmt.Sprintf("-u %s -l", user)
+	}
+	response := channel.Run(ctx, "crontab", args)
+	if !response.Success {
+		if strings.Contains(strings.ToLower(response.Err), "no crontab") {
+			return "", true, nil
+		}
+		log.Errorf(ctx, "read the crontab failed, %s", response.Err)
+		return "", false, response
+	}
+	return response.Result.(string), false, nil
+}
+
+func writeCrontab(ctx context.Context, channel spec.Channel, user, content string) *spec.Response {
+	target := "-"
+	if user != "" {
+		target = fmt.Sprintf("-u %s -", user)
+	}
+	response := channel.Run(ctx, "printf", fmt.Sprintf("%%s %s | crontab %s", exec.ShellQuote(content), target))
+	if !response.Success {
+		log.Errorf(ctx, "write the crontab failed, %s", response.Err)
+	}
+	return response
+}
+
+func getCronTag(uid string) string {
+	return fmt.Sprintf("# chaosblade-%s", uid)
+}
+
+func hasCronTag(content, uid string) bool {
+	tag := getCronTag(uid)
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasSuffix(strings.TrimSpace(line), tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeCronTag removes the lines which end with the tag of the uid
+func removeCronTag(content, uid string) string {
+	tag := getCronTag(uid)
+	lines := strings.Split(content, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.HasSuffix(strings.TrimSpace(line), tag) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// escapeCronCommand escapes the percent sign, which is the newline of the command in cron
+func escapeCronCommand(command string) string {
+	return strings.ReplaceAll(command, "%", `\%`)
+}
+
+func getCronStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-script-cron-%s.json", uid)
+}
+
+func getCronSystemFile(uid string) string {
+	return fmt.Sprintf("%s/chaosblade-%s", cronSystemDir, uid)
+}
+
+func writeCronState(stateFile string, state cronState) error {
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, bytes, 0600)
+}
+
+func readCronState(stateFile string) (cronState, error) {
+	var state cronState
+	bytes, err := os.ReadFile(stateFile)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(bytes, &state)
+	return state, err
+}
+
+var cronSpecialSchedules = map[string]bool{
+	"@reboot": true, "@yearly": true, "@annually": true, "@monthly": true,
+	"@weekly": true, "@daily": true, "@midnight": true, "@hourly": true,
+}
+
+var cronMonthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+var cronWeekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// cronField is the name, the value range and the value names of a field in the schedule
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: cronMonthNames},
+	{name: "day of week", min: 0, max: 7, names: cronWeekdayNames},
+}
+
+// validateCronSchedule checks the five fields schedule or the special string
+func validateCronSchedule(schedule string) error {
+	if strings.HasPrefix(schedule, "@") {
+		if !cronSpecialSchedules[strings.ToLower(schedule)] {
+			return fmt.Errorf("unknown special schedule %s", schedule)
+		}
+		return nil
+	}
+	fields := strings.Fields(schedule)
+	if len(fields) != len(cronFields) {
+		return fmt.Errorf("it must have %d fields, but got %d", len(cronFields), len(fields))
+	}
+	for i, field := range fields {
+		if err := cronFields[i].validate(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cf cronField) validate(field string) error {
+	for _, item := range strings.Split(field, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(item, "/")
+		if hasStep {
+			step, err := strconv.Atoi(stepExpr)
+			if err != nil || step < 1 || step > cf.max {
+				return fmt.Errorf("illegal step %s in %s field", stepExpr, cf.name)
+			}
+		}
+		if rangeExpr == "*" {
+			continue
+		}
+		start, end, isRange := strings.Cut(rangeExpr, "-")
+		from, err := cf.parseValue(start)
+		if err != nil {
+			return err
+		}
+		if !isRange {
+			continue
+		}
+		to, err := cf.parseValue(end)
+		if err != nil {
+			return err
+		}
+		if from > to {
+			return fmt.Errorf("illegal range %s in %s field", rangeExpr, cf.name)
+		}
+	}
+	return nil
+}
+
+func (cf cronField) parseValue(value string) (int, error) {
+	for i, name := range cf.names {
+		if strings.EqualFold(value, name) {
+			if cf.min == 1 {
+				return i + 1, nil
+			}
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < cf.min || v > cf.max {
+		return 0, fmt.Errorf("illegal value %s in %s field, it must be in [%d, %d]", value, cf.name, cf.min, cf.max)
+	}
+	return v, nil
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package script
+
+import (
+	"testing"
+)
+
+func TestValidateCronSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		valid    bool
+	}{
+		{"* * * * *", true},
+		{"*/5 0-23/2 1,15 jan-jun mon-fri", true},
+		{"0 3 * * 7", true},
+		{"@daily", true},
+		{"@reboot", true},
+		{"* * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"*/0 * * * *", false},
+		{"5-1 * * * *", false},
+		{"@every", false},
+		{"a * * * *", false},
+	}
+	for _, tt := range tests {
+		err := validateCronSchedule(tt.schedule)
+		if (err == nil) != tt.valid {
+			t.Errorf("unexpected result of %q, expected valid: %v, got: %v", tt.schedule, tt.valid, err)
+		}
+	}
+}
+
+func TestRemoveCronTag(t *testing.T) {
+	content := "0 * * * * /bin/true\n* * * * * echo a # chaosblade-abc\n* * * * * echo b # chaosblade-abcd\n"
+	expect := "0 * * * * /bin/true\n* * * * * echo b # chaosblade-abcd\n"
+	if got := removeCronTag(content, "abc"); got != expect {
+		t.Errorf("unexpected result: %q, expected: %q", got, expect)
+	}
+}
@@ -24,7 +24,6 @@ import (
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
-	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
 )
 
@@ -35,7 +34,7 @@ type ScriptDelayActionCommand struct {
 func NewScriptDelayActionCommand() spec.ExpActionCommandSpec {
 	return &ScriptDelayActionCommand{
 		spec.BaseExpActionCommandSpec{
-			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionMatchers: scriptCommFlags,
 			ActionFlags: []spec.ExpFlagSpec{
 				&spec.ExpFlag{
 					Name:     "time",
@@ -46,7 +45,13 @@ func NewScriptDelayActionCommand() spec.ExpActionCommandSpec {
 			ActionExecutor: &ScriptDelayExecutor{},
 			ActionExample: `
 # Add commands to the script "start0() { sleep 10.000000 ...}"
-blade create script delay --time 10000 --file test.sh --function-name start0`,
+blade create script delay --time 10000 --file test.sh --function-name start0
+
+# Add "import time; time.sleep(10.000000)" to the top of the python function "handle"
+blade create script delay --time 10000 --file test.py --function-name handle --script-type python
+
+# Wrap the executable to sleep 10 seconds before it runs
+blade create script delay --time 10000 --file /usr/local/bin/tool --script-type executable`,
 			ActionCategories: []string{category.SystemScript},
 		},
 	}
@@ -80,27 +85,16 @@ func (*ScriptDelayExecutor) Name() string {
 }
 
 func (sde *ScriptDelayExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
-	commands := []string{"cat", "rm", "sed", "awk", "rm"}
-	if response, ok := sde.channel.IsAllCommandsAvailable(ctx, commands); !ok {
+	scriptFile, response := getScriptFile(ctx, sde.channel, model)
+	if response != nil {
 		return response
 	}
-
-	scriptFile := model.ActionFlags["file"]
-	if scriptFile == "" {
-		log.Errorf(ctx, "file is nil")
-		return spec.ResponseFailWithFlags(spec.ParameterLess, "file")
-	}
-	if !exec.CheckFilepathExists(ctx, sde.channel, scriptFile) {
-		log.Errorf(ctx, "`%s`, file is invalid. it not found", scriptFile)
-		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "file", scriptFile, "it is not found")
-	}
 	if _, ok := spec.IsDestroy(ctx); ok {
-		return sde.stop(ctx, scriptFile)
+		return sde.stop(ctx, uid, scriptFile)
 	}
-	functionName := model.ActionFlags["function-name"]
-	if functionName == "" {
-		log.Errorf(ctx, "function-name")
-		return spec.ResponseFailWithFlags(spec.ParameterLess, "function-name")
+	scriptType, functionName, response := getScriptType(ctx, model)
+	if response != nil {
+		return response
 	}
 	time := model.ActionFlags["time"]
 	if time == "" {
@@ -112,25 +106,19 @@ func (sde *ScriptDelayExecutor) Exec(uid string, ctx context.Context, model *spe
 		log.Errorf(ctx, "time %v it must be a positive integer", time)
 		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "time", time, "ti must be a positive integer")
 	}
-	return sde.start(ctx, scriptFile, functionName, t)
+	return sde.start(ctx, uid, scriptType, scriptFile, functionName, t)
 }
 
-func (sde *ScriptDelayExecutor) start(ctx context.Context, scriptFile, functionName string, timt int) *spec.Response {
+func (sde *ScriptDelayExecutor) start(ctx context.Context, uid, scriptType, scriptFile, functionName string, timt int) *spec.Response {
 	timeInSecond := float32(timt) / 1000.0
-	// backup file
-	response := backScript(ctx, sde.channel, scriptFile)
-	if !response.Success {
-		return response
-	}
-	response = insertContentToScriptBy(ctx, sde.channel, functionName, fmt.Sprintf("sleep %f", timeInSecond), scriptFile)
-	if !response.Success {
-		sde.stop(ctx, scriptFile)
-	}
-	return response
+	return injectScript(ctx, sde.channel, uid, scriptType, scriptFile, functionName, scriptSnippet{
+		shell:  fmt.Sprintf("sleep %f", timeInSecond),
+		python: fmt.Sprintf("import time; time.sleep(%f)", timeInSecond),
+	})
 }
 
-func (sde *ScriptDelayExecutor) stop(ctx context.Context, scriptFile string) *spec.Response {
-	return recoverScript(ctx, sde.channel, scriptFile)
+func (sde *ScriptDelayExecutor) stop(ctx context.Context, uid, scriptFile string) *spec.Response {
+	return recoverScript(ctx, sde.channel, scriptFile, uid)
 }
 
 func (sde *ScriptDelayExecutor) SetChannel(channel spec.Channel) {
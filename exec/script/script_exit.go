@@ -19,6 +19,7 @@ package script
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
@@ -34,23 +35,36 @@ type ScriptExitActionCommand struct {
 func NewScriptExitActionCommand() spec.ExpActionCommandSpec {
 	return &ScriptExitActionCommand{
 		spec.BaseExpActionCommandSpec{
-			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionMatchers: scriptCommFlags,
 			ActionFlags: []spec.ExpFlagSpec{
 				&spec.ExpFlag{
 					Name:     "exit-code",
-					Desc:     "Exit code",
+					Desc:     "Exit code, an integer value from 0 to 255, default value is 1",
 					Required: false,
+					Default:  "1",
 				},
 				&spec.ExpFlag{
 					Name:     "exit-message",
-					Desc:     "Exit message",
+					Desc:     "Exit message, it is printed to stderr",
 					Required: false,
 				},
+				&spec.ExpFlag{
+					Name:     "probability",
+					Desc:     "Percent of the invocations which exit, an integer value from 1 to 100, default value is 100",
+					Required: false,
+					Default:  "100",
+				},
 			},
 			ActionExecutor: &ScriptExitExecutor{},
 			ActionExample: `
-# Add commands to the script "start0() { echo this-is-error-message; exit 1; ... }"
-blade create script exit --exit-code 1 --exit-message this-is-error-message --file test.sh --function-name start0`,
+# Add commands to the script "start0() { echo 'this-is-error-message' >&2; exit 1; ... }"
+blade create script exit --exit-code 1 --exit-message this-is-error-message --file test.sh --function-name start0
+
+# Exit the python function "handle" with the code 2
+blade create script exit --exit-code 2 --file test.py --function-name handle --script-type python
+
+# Only 30 percent of the invocations of the function start0 exit
+blade create script exit --exit-code 1 --probability 30 --file test.sh --function-name start0`,
 			ActionCategories: []string{category.SystemScript},
 		},
 	}
@@ -84,56 +98,64 @@ func (*ScriptExitExecutor) Name() string {
 }
 
 func (see *ScriptExitExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
-	commands := []string{"cat", "rm", "sed", "awk", "rm"}
-	if response, ok := see.channel.IsAllCommandsAvailable(ctx, commands); !ok {
+	scriptFile, response := getScriptFile(ctx, see.channel, model)
+	if response != nil {
 		return response
 	}
-
-	scriptFile := model.ActionFlags["file"]
-	if scriptFile == "" {
-		log.Errorf(ctx, "file is nil")
-		return spec.ResponseFailWithFlags(spec.ParameterLess, "file")
-	}
-	if !exec.CheckFilepathExists(ctx, see.channel, scriptFile) {
-		log.Errorf(ctx, "`%s`, file is invalid. it not found", scriptFile)
-		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "file", scriptFile, "the file is not found")
-	}
 	if _, ok := spec.IsDestroy(ctx); ok {
-		return see.stop(ctx, scriptFile)
+		return see.stop(ctx, uid, scriptFile)
 	}
-	functionName := model.ActionFlags["function-name"]
-	if functionName == "" {
-		log.Errorf(ctx, "function-name is nil")
-		return spec.ResponseFailWithFlags(spec.ParameterLess, "function-name")
+	scriptType, functionName, response := getScriptType(ctx, model)
+	if response != nil {
+		return response
 	}
 	exitMessage := model.ActionFlags["exit-message"]
 	exitCode := model.ActionFlags["exit-code"]
-	return see.start(ctx, scriptFile, functionName, exitMessage, exitCode)
+	if exitCode == "" {
+		exitCode = "1"
+	}
+	if code, err := strconv.Atoi(exitCode); err != nil || code < 0 || code > 255 {
+		log.Errorf(ctx, "`%s`: exit-code must be an integer value from 0 to 255", exitCode)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "exit-code", exitCode, "it must be an integer value from 0 to 255")
+	}
+	probability := 100
+	if probabilityStr := model.ActionFlags["probability"]; probabilityStr != "" {
+		var err error
+		probability, err = strconv.Atoi(probabilityStr)
+		if err != nil || probability < 1 || probability > 100 {
+			log.Errorf(ctx, "`%s`: probability must be an integer value from 1 to 100", probabilityStr)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "probability", probabilityStr, "it must be an integer value from 1 to 100")
+		}
+	}
+	return see.start(ctx, uid, scriptType, scriptFile, functionName, exitMessage, exitCode, probability)
 }
 
-func (see *ScriptExitExecutor) start(ctx context.Context, scriptFile, functionName, exitMessage, exitCode string) *spec.Response {
+func (see *ScriptExitExecutor) start(ctx context.Context, uid, scriptType, scriptFile, functionName, exitMessage, exitCode string, probability int) *spec.Response {
+	// the message is quoted, so any character in it is safe
 	var content string
+	pythonContent := ""
 	if exitMessage != "" {
-		content = fmt.Sprintf(`echo "%s";`, exitMessage)
-	}
-	if exitCode == "" {
-		exitCode = "1"
+		content = fmt.Sprintf(`echo %s >&2; `, exec.ShellQuote(exitMessage))
+		pythonContent = fmt.Sprintf(`sys.stderr.write(%s + "\n"), `, pythonQuote(exitMessage))
 	}
 	content = fmt.Sprintf("%sexit %s", content, exitCode)
-	// backup file
-	response := backScript(ctx, see.channel, scriptFile)
-	if !response.Success {
-		return response
-	}
-	response = insertContentToScriptBy(ctx, see.channel, functionName, content, scriptFile)
-	if !response.Success {
-		see.stop(ctx, scriptFile)
+	pythonContent = fmt.Sprintf("(%ssys.exit(%s))", pythonContent, exitCode)
+	if probability < 100 {
+		// $RANDOM is not supported by all the shells, fall back to /dev/urandom
+		content = fmt.Sprintf(`if [ $(( ${RANDOM:-$(od -An -N2 -tu2 /dev/urandom)} %% 100 )) -lt %d ]; then %s; fi`,
+			probability, content)
+		pythonContent = fmt.Sprintf("import random, sys; %s if random.randrange(100) < %d else None", pythonContent, probability)
+	} else {
+		pythonContent = fmt.Sprintf("import sys; %s", pythonContent)
 	}
-	return response
+	return injectScript(ctx, see.channel, uid, scriptType, scriptFile, functionName, scriptSnippet{
+		shell:  content,
+		python: pythonContent,
+	})
 }
 
-func (see *ScriptExitExecutor) stop(ctx context.Context, scriptFile string) *spec.Response {
-	return recoverScript(ctx, see.channel, scriptFile)
+func (see *ScriptExitExecutor) stop(ctx context.Context, uid, scriptFile string) *spec.Response {
+	return recoverScript(ctx, see.channel, scriptFile, uid)
 }
 
 func (see *ScriptExitExecutor) SetChannel(channel spec.Channel) {
@@ -0,0 +1,131 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package script
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+type ScriptHangActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewScriptHangActionCommand() spec.ExpActionCommandSpec {
+	return &ScriptHangActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: scriptCommFlags,
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "max-hang",
+					Desc: "Max hang time, unit is second. The script proceeds normally after it, hang forever if it is absent",
+				},
+			},
+			ActionExecutor: &ScriptHangExecutor{},
+			ActionExample: `
+# Add commands to the script "start0() { tail -f /dev/null ...}", the function never returns
+blade create script hang --file test.sh --function-name start0
+
+# The function start0 hangs 600 seconds and then proceeds
+blade create script hang --file test.sh --function-name start0 --max-hang 600
+
+# The python function "handle" never returns
+blade create script hang --file test.py --function-name handle --script-type python`,
+			ActionCategories: []string{category.SystemScript},
+		},
+	}
+}
+
+func (*ScriptHangActionCommand) Name() string {
+	return "hang"
+}
+
+func (*ScriptHangActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*ScriptHangActionCommand) ShortDesc() string {
+	return "Script hang"
+}
+
+func (s *ScriptHangActionCommand) LongDesc() string {
+	if s.ActionLongDesc != "" {
+		return s.ActionLongDesc
+	}
+	return "Block the script at the entry of the function or the file, so that it never returns"
+}
+
+type ScriptHangExecutor struct {
+	channel spec.Channel
+}
+
+func (*ScriptHangExecutor) Name() string {
+	return "hang"
+}
+
+func (she *ScriptHangExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	scriptFile, response := getScriptFile(ctx, she.channel, model)
+	if response != nil {
+		return response
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return she.stop(ctx, uid, scriptFile)
+	}
+	scriptType, functionName, response := getScriptType(ctx, model)
+	if response != nil {
+		return response
+	}
+	maxHang := 0
+	maxHangStr := model.ActionFlags["max-hang"]
+	if maxHangStr != "" {
+		var err error
+		maxHang, err = strconv.Atoi(maxHangStr)
+		if err != nil || maxHang < 1 {
+			log.Errorf(ctx, "`%s`: max-hang must be a positive integer", maxHangStr)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "max-hang", maxHangStr, "it must be a positive integer")
+		}
+	}
+	return she.start(ctx, uid, scriptType, scriptFile, functionName, maxHang)
+}
+
+func (she *ScriptHangExecutor) start(ctx context.Context, uid, scriptType, scriptFile, functionName string, maxHang int) *spec.Response {
+	snippet := scriptSnippet{
+		shell:  "tail -f /dev/null",
+		python: "import threading; threading.Event().wait()",
+	}
+	if maxHang > 0 {
+		snippet = scriptSnippet{
+			shell:  fmt.Sprintf("sleep %d", maxHang),
+			python: fmt.Sprintf("import threading; threading.Event().wait(%d)", maxHang),
+		}
+	}
+	return injectScript(ctx, she.channel, uid, scriptType, scriptFile, functionName, snippet)
+}
+
+func (she *ScriptHangExecutor) stop(ctx context.Context, uid, scriptFile string) *spec.Response {
+	return recoverScript(ctx, she.channel, scriptFile, uid)
+}
+
+func (she *ScriptHangExecutor) SetChannel(channel spec.Channel) {
+	she.channel = channel
+}
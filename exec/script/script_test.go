@@ -0,0 +1,60 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package script
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+func TestRecoverLegacyScript(t *testing.T) {
+	// the experiment of the old version left the backup without uid and checksum
+	cl := exec.NewMockChannel().
+		OnRun("test", `_chaosblade_uid-1\.bak`, spec.ReturnFail(spec.OsCmdExecFailed, "not found")).
+		OnRun("test", `\.sha256`, spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+	if response := recoverScript(context.Background(), cl, "/opt/app.sh", "uid-1"); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	lines := cl.CommandLines()
+	expect := []string{"cat /opt/app.sh_chaosblade.bak > /opt/app.sh", "rm -rf -- /opt/app.sh_chaosblade.bak"}
+	if len(lines) < 2 || lines[len(lines)-2] != expect[0] || lines[len(lines)-1] != expect[1] {
+		t.Errorf("expected the legacy backup is restored directly, got %q", lines)
+	}
+
+	// the paths are quoted, so the script with the space in the path is restored as is
+	cl = exec.NewMockChannel().
+		OnRun("test", `_chaosblade_uid-1\.bak`, spec.ReturnFail(spec.OsCmdExecFailed, "not found")).
+		OnRun("test", `\.sha256`, spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+	if response := recoverScript(context.Background(), cl, "/opt/my app.sh", "uid-1"); !response.Success {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	lines = cl.CommandLines()
+	expect = []string{"cat '/opt/my app.sh_chaosblade.bak' > '/opt/my app.sh'", "rm -rf -- '/opt/my app.sh_chaosblade.bak'"}
+	if len(lines) < 2 || lines[len(lines)-2] != expect[0] || lines[len(lines)-1] != expect[1] {
+		t.Errorf("expected the quoted paths, got %q", lines)
+	}
+
+	// neither the backup of the experiment nor the legacy one exists
+	cl = exec.NewMockChannel().OnRun("test", `\.bak`, spec.ReturnFail(spec.OsCmdExecFailed, "not found"))
+	if response := recoverScript(context.Background(), cl, "/opt/app.sh", "uid-1"); response.Success || response.Code != spec.FileNotExist.Code {
+		t.Errorf("expected the backup not found, got %+v", response)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// NoProtectSSHKey is the action flag which includes the ssh sessions in the network experiment, by default
+// they are kept, so the host stays reachable and the experiment can be destroyed
+const NoProtectSSHKey = "no-protect-ssh"
+
+// SSHPort is the local port of the sshd whose established sessions are kept
+var SSHPort = 22
+
+// SSHSession is the tcp connection of the ssh session on the host, the local ip is empty if it's unknown
+type SSHSession struct {
+	PeerIP    string
+	PeerPort  int
+	LocalIP   string
+	LocalPort int
+}
+
+// String returns the session such as 10.0.0.2:52314 -> 10.0.0.1:22
+func (s SSHSession) String() string {
+	local := ":" + strconv.Itoa(s.LocalPort)
+	if s.LocalIP != "" {
+		local = net.JoinHostPort(s.LocalIP, strconv.Itoa(s.LocalPort))
+	}
+	return fmt.Sprintf("%s -> %s", net.JoinHostPort(s.PeerIP, strconv.Itoa(s.PeerPort)), local)
+}
+
+// ProtectedSSHSessions returns the ssh sessions which the network experiment keeps, the one running the
+// experiment by SSH_CONNECTION or SSH_CLIENT and the established ones of the local SSHPort found by ss. The
+// ipv6 sessions are skipped since the iptables and tc experiments only change the ipv4 traffic. The caller
+// skips it if the no-protect-ssh flag is specified.
+func ProtectedSSHSessions(ctx context.Context, cl spec.Channel) []SSHSession {
+	sessions := make([]SSHSession, 0)
+	seen := make(map[string]bool)
+	add := func(session SSHSession) {
+		key := fmt.Sprintf("%s:%d:%d", session.PeerIP, session.PeerPort, session.LocalPort)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		sessions = append(sessions, session)
+	}
+	if session, ok := sshSessionFromEnv(); ok {
+		add(session)
+	}
+	if cl.IsCommandAvailable(ctx, "ss") {
+		response := RunReadOnly(ctx, cl, "ss", fmt.Sprintf("-Htn state established '( sport = :%d )'", SSHPort))
+		if response.Success {
+			for _, session := range parseSSHSessions(fmt.Sprint(response.Result)) {
+				add(session)
+			}
+		} else {
+			log.Warnf(ctx, "list the ssh sessions failed, %s", response.Err)
+		}
+	}
+	for _, session := range sessions {
+		log.Warnf(ctx, "the ssh session %s is kept out of the experiment, specify --%s to include it", session, NoProtectSSHKey)
+	}
+	return sessions
+}
+
+// sshSessionFromEnv parses SSH_CONNECTION, "client_ip client_port server_ip server_port", or SSH_CLIENT,
+// "client_ip client_port server_port"
+func sshSessionFromEnv() (SSHSession, bool) {
+	if fields := strings.Fields(os.Getenv("SSH_CONNECTION")); len(fields) == 4 {
+		return newSSHSession(fields[0], fields[1], fields[2], fields[3])
+	}
+	if fields := strings.Fields(os.Getenv("SSH_CLIENT")); len(fields) == 3 {
+		return newSSHSession(fields[0], fields[1], "", fields[2])
+	}
+	return SSHSession{}, false
+}
+
+// parseSSHSessions parses the output of ss -Htn state established, whose columns are Recv-Q, Send-Q, the local
+// address and the peer address
+func parseSSHSessions(output string) []SSHSession {
+	sessions := make([]SSHSession, 0)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		localIP, localPort, err := net.SplitHostPort(fields[2])
+		if err != nil {
+			continue
+		}
+		peerIP, peerPort, err := net.SplitHostPort(fields[3])
+		if err != nil {
+			continue
+		}
+		if session, ok := newSSHSession(peerIP, peerPort, localIP, localPort); ok {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions
+}
+
+func newSSHSession(peerIP, peerPort, localIP, localPort string) (SSHSession, bool) {
+	peer := net.ParseIP(peerIP).To4()
+	if peer == nil {
+		return SSHSession{}, false
+	}
+	session := SSHSession{PeerIP: peer.String()}
+	if local := net.ParseIP(localIP).To4(); local != nil {
+		session.LocalIP = local.String()
+	}
+	var err error
+	if session.PeerPort, err = strconv.Atoi(peerPort); err != nil {
+		return SSHSession{}, false
+	}
+	if session.LocalPort, err = strconv.Atoi(localPort); err != nil {
+		return SSHSession{}, false
+	}
+	return session, true
+}
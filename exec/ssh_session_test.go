@@ -0,0 +1,48 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestProtectedSSHSessions(t *testing.T) {
+	t.Setenv("SSH_CONNECTION", "")
+	t.Setenv("SSH_CLIENT", "10.0.0.2 52314 22")
+	cl := NewMockChannel().OnRun("ss", "", spec.ReturnSuccess(
+		"0      0      10.0.0.1:22      10.0.0.2:52314\n0      0      [::1]:22      [::1]:40000\nillegal\n"))
+	sessions := ProtectedSSHSessions(context.Background(), cl)
+	// the session of SSH_CLIENT is listed by ss too, the ipv6 one is skipped
+	expected := []SSHSession{{PeerIP: "10.0.0.2", PeerPort: 52314, LocalPort: 22}}
+	if !reflect.DeepEqual(sessions, expected) {
+		t.Errorf("expected the sessions %v, got %v", expected, sessions)
+	}
+	if s := sessions[0].String(); s != "10.0.0.2:52314 -> :22" {
+		t.Errorf("unexpected session %s", s)
+	}
+
+	t.Setenv("SSH_CONNECTION", "10.0.0.3 40022 10.0.0.1 2222")
+	sessions = ProtectedSSHSessions(context.Background(), cl.SetCommandAvailable("ss", false))
+	expected = []SSHSession{{PeerIP: "10.0.0.3", PeerPort: 40022, LocalIP: "10.0.0.1", LocalPort: 2222}}
+	if !reflect.DeepEqual(sessions, expected) {
+		t.Errorf("expected the sessions %v, got %v", expected, sessions)
+	}
+}
@@ -0,0 +1,314 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// StateDir is the directory of the experiment records, the records are lost after reboot like the
+// changes of the most experiments
+var StateDir = "/var/run/chaosblade/state"
+
+// UndoCommand is the command which reverts a change of the experiment
+type UndoCommand struct {
+	Script string `json:"script"`
+	Args   string `json:"args"`
+}
+
+// ExperimentState records what the experiment changed, so that the destroy doesn't depend on the flags.
+// The record is kept with Destroyed after the destroy, so destroying it again is a no-op, and it's
+// removed by PruneDestroyedStates after DestroyedStateTTL.
+type ExperimentState struct {
+	Uid    string            `json:"uid"`
+	Target string            `json:"target"`
+	Action string            `json:"action"`
+	Flags  map[string]string `json:"flags,omitempty"`
+	Undo   []UndoCommand     `json:"undo"`
+	// Resources are changed by the experiment exclusively, see ClaimResources
+	Resources []string `json:"resources,omitempty"`
+	// Backups are restored after the undo commands, see Backup
+	Backups []BackupEntry `json:"backups,omitempty"`
+	// Details are the data of the executor which the undo commands don't cover, such as what is detected
+	// on the host at the create, see SetDetail
+	Details    map[string]string `json:"details,omitempty"`
+	CreateTime time.Time         `json:"createTime"`
+	Destroyed  bool              `json:"destroyed,omitempty"`
+	// DestroyTime is when the experiment is destroyed, see PruneDestroyedStates
+	DestroyTime time.Time `json:"destroyTime,omitempty"`
+	// Watchdog is how the experiment is destroyed after the timeout, see RecordWatchdog
+	Watchdog *WatchdogRecord `json:"watchdog,omitempty"`
+	// dryRun skips writing the record, see DryRunChannel
+	dryRun bool
+}
+
+// WatchdogRecord is what the watchdog destroys the experiment by after the timeout
+type WatchdogRecord struct {
+	Deadline time.Time `json:"deadline"`
+	// Flags are all the flags of the create, the record of the executor may keep a part of them only
+	Flags map[string]string `json:"flags"`
+}
+
+// DestroyedStateTTL is how long the record of the destroyed experiment is kept
+var DestroyedStateTTL = 24 * time.Hour
+
+// GetStateFile returns the record file of the experiment
+func GetStateFile(uid string) string {
+	return filepath.Join(StateDir, fmt.Sprintf("%s.json", uid))
+}
+
+// NewExperimentState creates the record of the experiment, it fails if the experiment with the uid is running
+func NewExperimentState(ctx context.Context, uid, target, action string, flags map[string]string) (*ExperimentState, *spec.Response) {
+	return newExperimentState(ctx, uid, target, action, flags, nil)
+}
+
+func newExperimentState(ctx context.Context, uid, target, action string, flags map[string]string, resources []string) (*ExperimentState, *spec.Response) {
+	if state, err := LoadState(uid); err == nil && !state.Destroyed {
+		stateFile := GetStateFile(uid)
+		log.Errorf(ctx, "%s", spec.BackfileExists.Sprintf(stateFile))
+		return nil, spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	state := &ExperimentState{
+		Uid:        uid,
+		Target:     target,
+		Action:     action,
+		Flags:      flags,
+		Undo:       make([]UndoCommand, 0),
+		Resources:  resources,
+		CreateTime: time.Now(),
+		dryRun:     IsDryRun(ctx),
+	}
+	if err := state.Save(); err != nil {
+		log.Errorf(ctx, "save the record of the experiment failed, %v", err)
+		return nil, spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("save the record of the experiment failed, %v", err))
+	}
+	return state, nil
+}
+
+// AddUndo records the command which reverts the change just applied, the record is saved at once,
+// so the change is covered even if the following steps fail.
+func (s *ExperimentState) AddUndo(script, args string) error {
+	s.Undo = append(s.Undo, UndoCommand{Script: script, Args: args})
+	return s.Save()
+}
+
+// Reload reads the record again, it's called before changing the record which others have changed since
+// it's created, such as the backups recorded by Backup
+func (s *ExperimentState) Reload() error {
+	if s.dryRun {
+		return nil
+	}
+	state, err := LoadState(s.Uid)
+	if err != nil {
+		return err
+	}
+	*s = *state
+	return nil
+}
+
+// SetDetail records the data of the executor, the record is saved at once
+func (s *ExperimentState) SetDetail(key, value string) error {
+	if s.Details == nil {
+		s.Details = make(map[string]string)
+	}
+	s.Details[key] = value
+	return s.Save()
+}
+
+// Save writes the record to a temp file and renames it, so the record is never half written
+func (s *ExperimentState) Save() error {
+	if s.dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(StateDir, 0700); err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(GetStateFile(s.Uid), bytes, 0600)
+}
+
+// LoadState reads the record of the experiment, the error is os.ErrNotExist if there is no record
+func LoadState(uid string) (*ExperimentState, error) {
+	if uid == "" {
+		return nil, os.ErrNotExist
+	}
+	bytes, err := os.ReadFile(GetStateFile(uid))
+	if err != nil {
+		return nil, err
+	}
+	state := &ExperimentState{}
+	if err := json.Unmarshal(bytes, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// ListStates returns the records of all the experiments, the unreadable records are skipped
+func ListStates() ([]*ExperimentState, error) {
+	entries, err := os.ReadDir(StateDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	states := make([]*ExperimentState, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		if state, err := LoadState(strings.TrimSuffix(name, ".json")); err == nil {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+// RecordWatchdog records the deadline and the flags of the experiment for the watchdog, the record of the
+// executor is reused, and a record only for the watchdog is created for the executor without its record
+func RecordWatchdog(ctx context.Context, uid, target, action string, flags map[string]string, deadline time.Time) error {
+	state, err := LoadState(uid)
+	if err != nil || state.Destroyed {
+		state = &ExperimentState{
+			Uid:        uid,
+			Target:     target,
+			Action:     action,
+			Undo:       make([]UndoCommand, 0),
+			CreateTime: time.Now(),
+		}
+	}
+	state.dryRun = IsDryRun(ctx)
+	state.Watchdog = &WatchdogRecord{Deadline: deadline, Flags: flags}
+	return state.Save()
+}
+
+// DestroyWatchdogRecord completes the record of the experiment with the watchdog after the executor destroyed
+// it, the record only for the watchdog has no undo commands, so it's marked destroyed. It returns nil if there
+// is no such record.
+func DestroyWatchdogRecord(ctx context.Context, cl spec.Channel, uid string) *spec.Response {
+	state, err := LoadState(uid)
+	if err != nil || state.Watchdog == nil || state.Destroyed {
+		return nil
+	}
+	response, _ := DestroyByState(ctx, cl, uid)
+	return response
+}
+
+// setDestroyed marks the record destroyed with the time, the time of the record destroyed before is kept
+func (s *ExperimentState) setDestroyed(destroyed bool) {
+	if destroyed && !s.Destroyed {
+		s.DestroyTime = time.Now()
+	}
+	s.Destroyed = destroyed
+}
+
+// PruneDestroyedStates removes the records of the experiments destroyed longer than ttl ago, and returns
+// their uids. The record destroyed by the old versions has no destroy time, the time of the file is used.
+func PruneDestroyedStates(ctx context.Context, ttl time.Duration, now time.Time) ([]string, error) {
+	states, err := ListStates()
+	if err != nil {
+		return nil, err
+	}
+	pruned := make([]string, 0)
+	for _, state := range states {
+		if !state.Destroyed {
+			continue
+		}
+		destroyTime := state.DestroyTime
+		if destroyTime.IsZero() {
+			info, err := os.Stat(GetStateFile(state.Uid))
+			if err != nil {
+				continue
+			}
+			destroyTime = info.ModTime()
+		}
+		if now.Sub(destroyTime) <= ttl {
+			continue
+		}
+		if err := os.Remove(GetStateFile(state.Uid)); err != nil && !os.IsNotExist(err) {
+			log.Warnf(ctx, "remove the record of the destroyed experiment %s failed, %v", state.Uid, err)
+			continue
+		}
+		pruned = append(pruned, state.Uid)
+	}
+	return pruned, nil
+}
+
+// runUndo runs the undo command, the iptables one is retried if the xtables lock is held by the others
+func runUndo(ctx context.Context, cl spec.Channel, undo UndoCommand) *spec.Response {
+	if undo.Script == "iptables" {
+		return RunIptables(ctx, cl, undo.Args)
+	}
+	return cl.Run(ctx, undo.Script, undo.Args)
+}
+
+// DestroyByState runs the undo commands of the record in the reverse order. It returns false if there
+// is no record, then the caller falls back to reconstructing the changes from the flags. The failed
+// commands are kept in the record, so the destroy can be retried.
+func DestroyByState(ctx context.Context, cl spec.Channel, uid string) (*spec.Response, bool) {
+	state, err := LoadState(uid)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf(ctx, "read the record of the experiment %s failed, %v", uid, err)
+		}
+		return nil, false
+	}
+	state.dryRun = IsDryRun(ctx)
+	if state.Destroyed {
+		log.Infof(ctx, "the experiment %s has been destroyed", uid)
+		return spec.ReturnSuccess("the experiment has been destroyed"), true
+	}
+	failed := make([]UndoCommand, 0)
+	errs := make([]string, 0)
+	for i := len(state.Undo) - 1; i >= 0; i-- {
+		undo := state.Undo[i]
+		if response := runUndo(ctx, cl, undo); !response.Success {
+			log.Errorf(ctx, "undo `%s %s` failed, %s", undo.Script, undo.Args, response.Err)
+			// keep the original order for the retry
+			failed = append([]UndoCommand{undo}, failed...)
+			errs = append(errs, response.Err)
+		}
+	}
+	// the backups are taken before the changes, so they are restored at last
+	for i := len(state.Backups) - 1; i >= 0; i-- {
+		if response := restoreBackup(ctx, cl, &state.Backups[i]); !response.Success {
+			errs = append(errs, response.Err)
+		}
+	}
+	state.Undo = failed
+	state.setDestroyed(len(failed) == 0 && state.allBackupsRestored())
+	if err := state.Save(); err != nil {
+		log.Warnf(ctx, "save the record of the experiment %s failed, %v", uid, err)
+	}
+	if !state.Destroyed {
+		return Fail(RestoreFailed, state.Target, "destroy", fmt.Sprintf("destroy the experiment %s failed, %v", uid, errs)), true
+	}
+	return spec.ReturnSuccess(uid), true
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestDestroyByState(t *testing.T) {
+	StateDir = t.TempDir()
+	ctx := context.Background()
+	mock := channel.NewMockLocalChannel().(*channel.MockLocalChannel)
+	executed := make([]string, 0)
+	failed := "rule-2"
+	mock.RunFunc = func(ctx context.Context, script, args string) *spec.Response {
+		executed = append(executed, args)
+		if args == failed {
+			return spec.ReturnFail(spec.OsCmdExecFailed, "failed")
+		}
+		return spec.ReturnSuccess("")
+	}
+
+	if _, ok := DestroyByState(ctx, mock, "no-record"); ok {
+		t.Errorf("the destroy should fall back without the record")
+	}
+
+	state, resp := NewExperimentState(ctx, "uid", "network", "drop", nil)
+	if resp != nil {
+		t.Fatalf("create the record failed, %v", resp.Err)
+	}
+	for _, args := range []string{"rule-1", "rule-2", "rule-3"} {
+		if err := state.AddUndo("iptables", args); err != nil {
+			t.Fatalf("add undo failed, %v", err)
+		}
+	}
+	if _, resp := NewExperimentState(ctx, "uid", "network", "drop", nil); resp == nil || resp.Code != spec.BackfileExists.Code {
+		t.Errorf("the running experiment with the same uid should be rejected, got %v", resp)
+	}
+
+	// the failed undo is kept for the retry
+	if response, ok := DestroyByState(ctx, mock, "uid"); !ok || response.Success {
+		t.Errorf("unexpected response %v of the failed destroy", response)
+	}
+	if expected := []string{"rule-3", "rule-2", "rule-1"}; !equalStrings(executed, expected) {
+		t.Errorf("expected the undo commands %v, got %v", expected, executed)
+	}
+
+	failed = ""
+	executed = executed[:0]
+	if response, ok := DestroyByState(ctx, mock, "uid"); !ok || !response.Success {
+		t.Errorf("unexpected response %v of the retry", response)
+	}
+	if expected := []string{"rule-2"}; !equalStrings(executed, expected) {
+		t.Errorf("expected the undo commands %v, got %v", expected, executed)
+	}
+
+	executed = executed[:0]
+	if response, ok := DestroyByState(ctx, mock, "uid"); !ok || !response.Success || len(executed) > 0 {
+		t.Errorf("destroying again should be a no-op, got %v and %v", response, executed)
+	}
+	if _, resp := NewExperimentState(ctx, "uid", "network", "drop", nil); resp != nil {
+		t.Errorf("the uid of the destroyed experiment should be reusable, got %v", resp.Err)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPruneDestroyedStates(t *testing.T) {
+	StateDir = t.TempDir()
+	ctx := context.Background()
+	now := time.Now()
+	records := []struct {
+		uid         string
+		destroyed   bool
+		destroyTime time.Time
+	}{
+		{"running", false, time.Time{}},
+		{"destroyed-recently", true, now.Add(-time.Hour)},
+		{"destroyed-long-ago", true, now.Add(-48 * time.Hour)},
+	}
+	for _, record := range records {
+		state := &ExperimentState{Uid: record.uid, Destroyed: record.destroyed, DestroyTime: record.destroyTime}
+		if err := state.Save(); err != nil {
+			t.Fatalf("save the record failed, %v", err)
+		}
+	}
+	// the record destroyed by the old version has no destroy time, the time of the file is used
+	legacy := &ExperimentState{Uid: "legacy", Destroyed: true}
+	if err := legacy.Save(); err != nil {
+		t.Fatalf("save the record failed, %v", err)
+	}
+	if err := os.Chtimes(GetStateFile("legacy"), now.Add(-48*time.Hour), now.Add(-48*time.Hour)); err != nil {
+		t.Fatalf("change the time of the record failed, %v", err)
+	}
+
+	pruned, err := PruneDestroyedStates(ctx, DestroyedStateTTL, now)
+	if err != nil {
+		t.Fatalf("prune the records failed, %v", err)
+	}
+	sort.Strings(pruned)
+	if expected := []string{"destroyed-long-ago", "legacy"}; !equalStrings(pruned, expected) {
+		t.Errorf("expected the pruned records %v, got %v", expected, pruned)
+	}
+	for _, uid := range []string{"running", "destroyed-recently"} {
+		if _, err := LoadState(uid); err != nil {
+			t.Errorf("the record %s should be kept, %v", uid, err)
+		}
+	}
+}
+
+func TestDestroyTime(t *testing.T) {
+	state := &ExperimentState{}
+	state.setDestroyed(true)
+	destroyTime := state.DestroyTime
+	if destroyTime.IsZero() {
+		t.Fatalf("expected the destroy time is set")
+	}
+	// destroying again keeps the time of the first destroy
+	state.setDestroyed(true)
+	if !state.DestroyTime.Equal(destroyTime) {
+		t.Errorf("expected the destroy time %v, got %v", destroyTime, state.DestroyTime)
+	}
+
+	// the record released by the executor which destroys by itself has the destroy time too
+	StateDir = t.TempDir()
+	if _, response := NewExperimentState(context.Background(), "released", "process", "limit", nil); response != nil {
+		t.Fatalf("unexpected failure, %s", response.Err)
+	}
+	ReleaseResources(context.Background(), "released")
+	if released, err := LoadState("released"); err != nil || !released.Destroyed || released.DestroyTime.IsZero() {
+		t.Errorf("expected the destroy time of the released record, got %+v, %v", released, err)
+	}
+}
+
+func TestRecordWatchdog(t *testing.T) {
+	StateDir = t.TempDir()
+	ctx := context.Background()
+	cl := NewMockChannel()
+	deadline := time.Now().Add(time.Minute).Truncate(time.Second)
+	flags := map[string]string{"filepath": "/data/app.log", "timeout": "60"}
+
+	// the executor without its record gets a record only for the watchdog
+	if err := RecordWatchdog(ctx, "no-record", "file", "append", flags, deadline); err != nil {
+		t.Fatalf("record the watchdog failed, %v", err)
+	}
+	state, err := LoadState("no-record")
+	if err != nil || state.Target != "file" || state.Watchdog == nil || !state.Watchdog.Deadline.Equal(deadline) ||
+		state.Watchdog.Flags["filepath"] != "/data/app.log" {
+		t.Fatalf("unexpected record %+v, %v", state, err)
+	}
+	if response := DestroyWatchdogRecord(ctx, cl, "no-record"); response == nil || !response.Success {
+		t.Errorf("unexpected response %v of destroying the record", response)
+	}
+	if state, _ := LoadState("no-record"); !state.Destroyed {
+		t.Errorf("expected the record of the watchdog destroyed")
+	}
+
+	// the record of the executor keeps its flags and undo commands
+	state, resp := NewExperimentState(ctx, "with-record", "file", "delete", map[string]string{"filepath": "/data"})
+	if resp != nil {
+		t.Fatalf("create the record failed, %v", resp.Err)
+	}
+	if err := state.AddUndo("mv", "/data.bak /data"); err != nil {
+		t.Fatalf("add undo failed, %v", err)
+	}
+	if err := RecordWatchdog(ctx, "with-record", "file", "delete", flags, deadline); err != nil {
+		t.Fatalf("record the watchdog failed, %v", err)
+	}
+	if state, _ := LoadState("with-record"); len(state.Undo) != 1 || state.Flags["timeout"] != "" || state.Watchdog.Flags["timeout"] != "60" {
+		t.Errorf("unexpected record %+v", state)
+	}
+
+	if response := DestroyWatchdogRecord(ctx, cl, "unknown"); response != nil {
+		t.Errorf("expected nothing to destroy without the record, got %v", response)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// StatusKey is the context key of the status phase, the executor verifies the experiment instead of
+// creating or destroying it
+const StatusKey = "status"
+
+// WithStatus returns the context of the status phase
+func WithStatus(ctx context.Context) context.Context {
+	return context.WithValue(ctx, StatusKey, spec.True)
+}
+
+// IsStatus returns true if the context is in the status phase
+func IsStatus(ctx context.Context) bool {
+	value, ok := ctx.Value(StatusKey).(string)
+	return ok && value == spec.True
+}
+
+// StatusChecker is implemented by the executors which can verify whether the fault is still effective
+type StatusChecker interface {
+	// Status verifies the artifacts of the experiment, the state is nil if there is no record
+	Status(ctx context.Context, uid string, model *spec.ExpModel, state *ExperimentState) *StatusReport
+}
+
+// Artifact is a change of the experiment on the host, such as a process, a rule or a file
+type Artifact struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Present bool   `json:"present"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// StatusReport is the health of the experiment, it's effective if all the artifacts are present
+type StatusReport struct {
+	Uid       string `json:"uid"`
+	Target    string `json:"target"`
+	Action    string `json:"action"`
+	Recorded  bool   `json:"recorded"`
+	Effective bool   `json:"effective"`
+	// Degraded is set if the experiment is effective but weaker than requested, such as the cpu burn throttled
+	// by the quota of the container, see Degrade
+	Degraded       bool       `json:"degraded,omitempty"`
+	DegradedReason string     `json:"degradedReason,omitempty"`
+	Artifacts      []Artifact `json:"artifacts"`
+	// Metrics are the latest snapshots of the chaos processes, see MetricsWriter
+	Metrics []MetricsSnapshot `json:"metrics,omitempty"`
+	// Counters are the packets and the bytes matched by the iptables rules of the experiment
+	Counters []RuleCounters `json:"counters,omitempty"`
+	// Files are the changes of the experiment on the files, see AddFileChange
+	Files []FileChange `json:"files,omitempty"`
+}
+
+// FileChange is a change of the experiment on a file, with its current state on the host. Divergence explains
+// how the host differs from what the experiment changed, such as the file deleted by others.
+type FileChange struct {
+	Path string `json:"path"`
+	// Change is one of backup, append, create, delete, move and mode
+	Change   string `json:"change"`
+	Exists   bool   `json:"exists"`
+	Checksum string `json:"checksum,omitempty"`
+	// Bytes are the bytes appended to the file
+	Bytes          int64  `json:"bytes,omitempty"`
+	Backup         string `json:"backup,omitempty"`
+	BackupVerified bool   `json:"backupVerified,omitempty"`
+	// OriginMode and Mode are the permission bits before the experiment and now
+	OriginMode string `json:"originMode,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	// Target is where the file is moved to, Exists and Checksum are of the moved file then
+	Target     string `json:"target,omitempty"`
+	Divergence string `json:"divergence,omitempty"`
+}
+
+// AddFileChange appends the change to the inventory of the files, the diverged change is an absent artifact
+func (r *StatusReport) AddFileChange(change FileChange) {
+	r.Files = append(r.Files, change)
+	r.Add(Artifact{Kind: "file", Name: change.Path, Present: change.Divergence == "", Detail: change.Divergence})
+}
+
+// NewStatusReport creates an empty report of the experiment
+func NewStatusReport(uid string, model *spec.ExpModel, state *ExperimentState) *StatusReport {
+	return &StatusReport{
+		Uid:       uid,
+		Target:    model.Target,
+		Action:    model.ActionName,
+		Recorded:  state != nil,
+		Artifacts: make([]Artifact, 0),
+	}
+}
+
+// Add appends the artifact, the report is effective until an artifact is absent
+func (r *StatusReport) Add(artifact Artifact) {
+	r.Artifacts = append(r.Artifacts, artifact)
+	r.Effective = true
+	for _, a := range r.Artifacts {
+		if !a.Present {
+			r.Effective = false
+			return
+		}
+	}
+}
+
+// Degrade marks the effective experiment as degraded for the reason
+func (r *StatusReport) Degrade(reason string) {
+	r.Degraded = true
+	r.DegradedReason = reason
+}
+
+// AddMetrics attaches the latest metrics snapshots of the experiment to the report
+func (r *StatusReport) AddMetrics() {
+	r.Metrics = LoadMetrics(r.Uid)
+}
+
+// ProcessArtifact returns whether the chaos process of the experiment is running
+func ProcessArtifact(ctx context.Context, uid, target, action string) Artifact {
+	artifact := Artifact{Kind: "process", Name: fmt.Sprintf("%s %s %s", spec.ChaosOsBin, target, action)}
+	processes, err := ListChaosProcesses(ctx, nil)
+	if err != nil {
+		artifact.Detail = fmt.Sprintf("list chaos processes failed, %v", err)
+		return artifact
+	}
+	for _, p := range processes {
+		if p.Uid == uid && p.Target == target && p.Action == action {
+			artifact.Present = true
+			artifact.Detail = fmt.Sprintf("pid %d", p.Pid)
+			return artifact
+		}
+	}
+	artifact.Detail = "the process is not found"
+	return artifact
+}
+
+// statusExecutor verifies the experiment in the status phase, and delegates the other phases
+type statusExecutor struct {
+	spec.Executor
+}
+
+// WithStatusPhase wraps the executor, so that the status phase in the context never creates or destroys
+// the experiment, the executors without StatusChecker return not supported in the phase.
+func WithStatusPhase(executor spec.Executor) spec.Executor {
+	if executor == nil {
+		return nil
+	}
+	if _, ok := executor.(*statusExecutor); ok {
+		return executor
+	}
+	return &statusExecutor{Executor: executor}
+}
+
+func (s *statusExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if !IsStatus(ctx) {
+		return s.Executor.Exec(uid, ctx, model)
+	}
+	checker, ok := s.Executor.(StatusChecker)
+	if !ok {
+		return spec.ResponseFailWithFlags(spec.ActionNotSupport, "status of "+model.Target+" "+model.ActionName)
+	}
+	state, err := LoadState(uid)
+	if err != nil {
+		state = nil
+	}
+	return spec.ReturnSuccess(checker.Status(ctx, uid, model, state))
+}
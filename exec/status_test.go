@@ -0,0 +1,73 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+type fakeExecutor struct {
+	executed bool
+}
+
+func (f *fakeExecutor) Name() string { return "fake" }
+
+func (f *fakeExecutor) SetChannel(channel spec.Channel) {}
+
+func (f *fakeExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	f.executed = true
+	return spec.ReturnSuccess(uid)
+}
+
+type fakeStatusExecutor struct {
+	fakeExecutor
+}
+
+func (f *fakeStatusExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *ExperimentState) *StatusReport {
+	report := NewStatusReport(uid, model, state)
+	report.Add(Artifact{Kind: "rule", Name: "present", Present: true})
+	report.Add(Artifact{Kind: "rule", Name: "absent"})
+	return report
+}
+
+func TestStatusPhase(t *testing.T) {
+	StateDir = t.TempDir()
+	model := &spec.ExpModel{Target: "network", ActionName: "drop"}
+	ctx := WithStatus(context.Background())
+
+	plain := &fakeExecutor{}
+	if response := WithStatusPhase(plain).Exec("uid", ctx, model); response.Success || plain.executed {
+		t.Errorf("the executor without StatusChecker should not be executed in the status phase, got %v", response)
+	}
+
+	checker := &fakeStatusExecutor{}
+	response := WithStatusPhase(checker).Exec("uid", ctx, model)
+	if !response.Success || checker.executed {
+		t.Fatalf("unexpected response %v in the status phase", response)
+	}
+	report := response.Result.(*StatusReport)
+	if report.Effective || report.Recorded || len(report.Artifacts) != 2 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if response := WithStatusPhase(checker).Exec("uid", context.Background(), model); !response.Success || !checker.executed {
+		t.Errorf("the executor should be executed out of the status phase, got %v", response)
+	}
+}
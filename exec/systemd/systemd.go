@@ -36,6 +36,9 @@ func NewSystemdCommandModelSpec() spec.ExpModelCommandSpec {
 			},
 			ExpActions: []spec.ExpActionCommandSpec{
 				NewStopSystemdActionCommandSpec(),
+				NewKillSystemdActionCommandSpec(),
+				NewRestartLoopSystemdActionCommandSpec(),
+				NewEnvSystemdActionCommandSpec(),
 			},
 		},
 	}
@@ -50,5 +53,5 @@ func (*SystemdCommandModelSpec) ShortDesc() string {
 }
 
 func (*SystemdCommandModelSpec) LongDesc() string {
-	return "Systemd experiment, for example, stop systemd"
+	return "Systemd experiment, for example, stop, kill or restart the service in loop, or change the environment variables of the service"
 }
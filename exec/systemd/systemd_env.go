@@ -0,0 +1,252 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const EnvSystemdBin = "chaos_envsystemd"
+
+const systemdUnitDir = "/etc/systemd/system"
+
+var envKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type EnvSystemdActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewEnvSystemdActionCommandSpec() spec.ExpActionCommandSpec {
+	return &EnvSystemdActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:                  "service",
+					Desc:                  "Service name",
+					Required:              true,
+					RequiredWhenDestroyed: true,
+				},
+			},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     "env",
+					Desc:     "Environment variables, for example KEY=VALUE,KEY2=. The variable is unset if the value is empty",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name:   "restart",
+					Desc:   "Restart the service to apply the environment variables immediately, and restart it again when the experiment is destroyed",
+					NoArgs: true,
+				},
+			},
+			ActionExecutor: &EnvSystemdExecutor{},
+			ActionExample: `
+# Set DB_HOST of the service test, which is applied when the service is started next time
+blade create systemd env --service test --env DB_HOST=127.0.0.2
+
+# Set DB_HOST, unset DB_PASSWORD and restart the service test to apply them immediately
+blade create systemd env --service test --env DB_HOST=127.0.0.2,DB_PASSWORD= --restart`,
+			ActionPrograms:   []string{EnvSystemdBin},
+			ActionCategories: []string{category.SystemSystemd},
+		},
+	}
+}
+
+func (*EnvSystemdActionCommandSpec) Name() string {
+	return "env"
+}
+
+func (*EnvSystemdActionCommandSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*EnvSystemdActionCommandSpec) ShortDesc() string {
+	return "Change the environment variables of systemd service"
+}
+
+func (k *EnvSystemdActionCommandSpec) LongDesc() string {
+	if k.ActionLongDesc != "" {
+		return k.ActionLongDesc
+	}
+	return "Set or unset the environment variables of the service by a systemd drop-in, which is deleted when the experiment is destroyed"
+}
+
+func (*EnvSystemdActionCommandSpec) Categories() []string {
+	return []string{category.SystemSystemd}
+}
+
+type EnvSystemdExecutor struct {
+	channel spec.Channel
+}
+
+func (ese *EnvSystemdExecutor) Name() string {
+	return "env"
+}
+
+// envVar is a variable of the env flag, which is unset if the value is empty
+type envVar struct {
+	key   string
+	value string
+}
+
+func (ese *EnvSystemdExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	service := model.ActionFlags["service"]
+	if service == "" {
+		log.Errorf(ctx, "%s", "less service name")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "service")
+	}
+	unit := getUnitName(service)
+	restart := model.ActionFlags["restart"] == "true"
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return ese.stop(ctx, uid, unit, restart)
+	}
+	envStr := model.ActionFlags["env"]
+	if envStr == "" {
+		log.Errorf(ctx, "%s", "less env")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "env")
+	}
+	envs, err := parseEnvVars(envStr)
+	if err != nil {
+		log.Errorf(ctx, "`%s`: env is illegal, %v", envStr, err)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "env", envStr, err)
+	}
+	if response := checkUnitExists(ctx, ese.channel, unit); response != nil {
+		return response
+	}
+	return ese.start(ctx, uid, unit, envs, restart)
+}
+
+func (ese *EnvSystemdExecutor) start(ctx context.Context, uid, unit string, envs []envVar, restart bool) *spec.Response {
+	dropInFile := getDropInFile(unit, uid)
+	if _, err := os.Stat(dropInFile); err == nil {
+		log.Errorf(ctx, "`%s`: the drop-in file exists", dropInFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, dropInFile)
+	}
+	if err := os.MkdirAll(path.Dir(dropInFile), 0755); err != nil {
+		log.Errorf(ctx, "create the drop-in directory failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "mkdir", err)
+	}
+	if err := os.WriteFile(dropInFile, []byte(buildEnvDropIn(uid, envs)), 0644); err != nil {
+		log.Errorf(ctx, "write the drop-in file failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "write", err)
+	}
+	if response := ese.channel.Run(ctx, "systemctl", "daemon-reload"); !response.Success {
+		os.Remove(dropInFile)
+		return response
+	}
+	if restart {
+		if response := ese.channel.Run(ctx, "systemctl", fmt.Sprintf(`restart "%s"`, unit)); !response.Success {
+			log.Errorf(ctx, "restart %s failed, %s", unit, response.Err)
+			ese.stop(ctx, uid, unit, false)
+			return response
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (ese *EnvSystemdExecutor) stop(ctx context.Context, uid, unit string, restart bool) *spec.Response {
+	dropInFile := getDropInFile(unit, uid)
+	if err := os.Remove(dropInFile); err != nil && !os.IsNotExist(err) {
+		log.Errorf(ctx, "remove the drop-in file failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove", err)
+	}
+	// the drop-in directory is created by the experiment if it is empty now
+	os.Remove(path.Dir(dropInFile))
+	if response := ese.channel.Run(ctx, "systemctl", "daemon-reload"); !response.Success {
+		return response
+	}
+	if restart {
+		if response := ese.channel.Run(ctx, "systemctl", fmt.Sprintf(`restart "%s"`, unit)); !response.Success {
+			return response
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (ese *EnvSystemdExecutor) SetChannel(channel spec.Channel) {
+	ese.channel = channel
+}
+
+// checkUnitExists checks that systemd is pid 1 and the unit is loaded
+func checkUnitExists(ctx context.Context, cl spec.Channel, unit string) *spec.Response {
+	if !cl.IsCommandAvailable(ctx, "systemctl") {
+		log.Errorf(ctx, "%s", spec.CommandSystemctlNotFound.Msg)
+		return spec.ResponseFailWithFlags(spec.CommandSystemctlNotFound)
+	}
+	comm, err := os.ReadFile("/proc/1/comm")
+	if err != nil || strings.TrimSpace(string(comm)) != "systemd" {
+		log.Errorf(ctx, "systemd is not pid 1, comm: %s, err: %v", strings.TrimSpace(string(comm)), err)
+		return spec.ResponseFailWithFlags(spec.SystemdNotFound, "pid 1", "systemd is not pid 1")
+	}
+	_, response := getUnitState(ctx, cl, unit)
+	return response
+}
+
+// parseEnvVars parses KEY=VALUE[,KEY2=], the variable with empty value is unset
+func parseEnvVars(envStr string) ([]envVar, error) {
+	envs := make([]envVar, 0)
+	for _, item := range strings.Split(envStr, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s must be KEY=VALUE", item)
+		}
+		if !envKeyRegexp.MatchString(key) {
+			return nil, fmt.Errorf("%s is not a valid variable name", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("the value of %s must be a single line", key)
+		}
+		envs = append(envs, envVar{key: key, value: value})
+	}
+	if len(envs) == 0 {
+		return nil, fmt.Errorf("no variable found")
+	}
+	return envs, nil
+}
+
+// buildEnvDropIn returns the drop-in content, the value is quoted and the specifier is escaped
+func buildEnvDropIn(uid string, envs []envVar) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# created by chaosblade experiment %s\n[Service]\n", uid))
+	for _, env := range envs {
+		if env.value == "" {
+			sb.WriteString(fmt.Sprintf("UnsetEnvironment=%s\n", env.key))
+			continue
+		}
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(env.value)
+		sb.WriteString(fmt.Sprintf("Environment=\"%s=%s\"\n", env.key, value))
+	}
+	return sb.String()
+}
+
+func getDropInFile(unit, uid string) string {
+	return fmt.Sprintf("%s/%s.d/chaosblade-%s.conf", systemdUnitDir, unit, uid)
+}
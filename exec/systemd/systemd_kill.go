@@ -0,0 +1,140 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const KillSystemdBin = "chaos_killsystemd"
+
+type KillSystemdActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewKillSystemdActionCommandSpec() spec.ExpActionCommandSpec {
+	return &KillSystemdActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "service",
+					Desc: "Service name",
+				},
+			},
+			ActionFlags: []spec.ExpFlagSpec{
+				forceFlag,
+			},
+			ActionExecutor: &KillSystemdExecutor{},
+			ActionExample: `
+ # Kill the main process of the service test by SIGKILL, the Restart= policy of the service decides whether it is restarted
+ blade create systemd kill --service test`,
+			ActionPrograms:   []string{KillSystemdBin},
+			ActionCategories: []string{category.SystemSystemd},
+		},
+	}
+}
+
+func (*KillSystemdActionCommandSpec) Name() string {
+	return "kill"
+}
+
+func (*KillSystemdActionCommandSpec) Aliases() []string {
+	return []string{"k"}
+}
+
+func (*KillSystemdActionCommandSpec) ShortDesc() string {
+	return "Kill systemd"
+}
+
+func (k *KillSystemdActionCommandSpec) LongDesc() string {
+	if k.ActionLongDesc != "" {
+		return k.ActionLongDesc
+	}
+	return "Kill the main process of the service by SIGKILL, the service is returned to the original state when the experiment is destroyed"
+}
+
+func (*KillSystemdActionCommandSpec) Categories() []string {
+	return []string{category.SystemSystemd}
+}
+
+type KillSystemdExecutor struct {
+	channel spec.Channel
+}
+
+func (kse *KillSystemdExecutor) Name() string {
+	return "kill"
+}
+
+func (kse *KillSystemdExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	service := model.ActionFlags["service"]
+	if service == "" {
+		log.Errorf(ctx, "%s", "less service name")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "service")
+	}
+
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return kse.stop(ctx, uid)
+	}
+	state, response := checkUnit(ctx, kse.channel, service, model.ActionFlags["force"] == "true")
+	if response != nil {
+		return response
+	}
+	if state.MainPid == 0 {
+		log.Errorf(ctx, "`%s`: the unit has no main process", state.Unit)
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "service", service, "the unit has no main process")
+	}
+	return kse.start(ctx, uid, state)
+}
+
+func (kse *KillSystemdExecutor) start(ctx context.Context, uid string, state *unitState) *spec.Response {
+	stateFile := getUnitStateFile(uid)
+	if response := saveUnitState(ctx, stateFile, state); response != nil {
+		return response
+	}
+	log.Infof(ctx, "kill the main process %d of %s", state.MainPid, state.Unit)
+	response := kse.channel.Run(ctx, "kill", fmt.Sprintf("-9 %d", state.MainPid))
+	if !response.Success {
+		os.Remove(stateFile)
+	}
+	return response
+}
+
+func (kse *KillSystemdExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	stateFile := getUnitStateFile(uid)
+	state, err := readUnitState(stateFile)
+	if err != nil {
+		log.Warnf(ctx, "read the unit state failed, the experiment may be destroyed, %v", err)
+		return spec.ReturnSuccess(uid)
+	}
+	response := restoreUnitState(ctx, kse.channel, state)
+	if !response.Success {
+		return response
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+func (kse *KillSystemdExecutor) SetChannel(channel spec.Channel) {
+	kse.channel = channel
+}
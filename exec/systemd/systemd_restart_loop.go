@@ -0,0 +1,176 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const RestartLoopSystemdBin = "chaos_restartloopsystemd"
+
+type RestartLoopSystemdActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewRestartLoopSystemdActionCommandSpec() spec.ExpActionCommandSpec {
+	return &RestartLoopSystemdActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "service",
+					Desc: "Service name",
+				},
+			},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:    "interval",
+					Desc:    "The interval of the restart, unit is second",
+					Default: "10",
+				},
+				forceFlag,
+			},
+			ActionExecutor: &RestartLoopSystemdExecutor{},
+			ActionExample: `
+ # Restart the service test every 30 seconds until the experiment is destroyed
+ blade create systemd restart-loop --service test --interval 30`,
+			ActionPrograms:    []string{RestartLoopSystemdBin},
+			ActionCategories:  []string{category.SystemSystemd},
+			ActionProcessHang: true,
+		},
+	}
+}
+
+func (*RestartLoopSystemdActionCommandSpec) Name() string {
+	return "restart-loop"
+}
+
+func (*RestartLoopSystemdActionCommandSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*RestartLoopSystemdActionCommandSpec) ShortDesc() string {
+	return "Restart systemd in loop"
+}
+
+func (k *RestartLoopSystemdActionCommandSpec) LongDesc() string {
+	if k.ActionLongDesc != "" {
+		return k.ActionLongDesc
+	}
+	return "Restart the service every interval until the experiment is destroyed, the service is returned to the original state then"
+}
+
+func (*RestartLoopSystemdActionCommandSpec) Categories() []string {
+	return []string{category.SystemSystemd}
+}
+
+type RestartLoopSystemdExecutor struct {
+	channel spec.Channel
+}
+
+func (rse *RestartLoopSystemdExecutor) Name() string {
+	return "restart-loop"
+}
+
+func (rse *RestartLoopSystemdExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	service := model.ActionFlags["service"]
+	if service == "" {
+		log.Errorf(ctx, "%s", "less service name")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "service")
+	}
+
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return rse.stop(ctx, uid)
+	}
+	intervalStr := model.ActionFlags["interval"]
+	if intervalStr == "" {
+		intervalStr = "10"
+	}
+	interval, err := strconv.Atoi(intervalStr)
+	if err != nil || interval < 1 {
+		log.Errorf(ctx, "`%s`: interval must be a positive integer", intervalStr)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "interval", intervalStr, "it must be a positive integer")
+	}
+	state, response := checkUnit(ctx, rse.channel, service, model.ActionFlags["force"] == "true")
+	if response != nil {
+		return response
+	}
+	return rse.start(ctx, uid, state, interval)
+}
+
+func (rse *RestartLoopSystemdExecutor) start(ctx context.Context, uid string, state *unitState, interval int) *spec.Response {
+	stateFile := getUnitStateFile(uid)
+	if response := saveUnitState(ctx, stateFile, state); response != nil {
+		return response
+	}
+	if response := rse.restart(ctx, state.Unit); !response.Success {
+		os.Remove(stateFile)
+		return response
+	}
+
+	ticker := time.NewTicker(time.Second * time.Duration(interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if response := rse.restart(ctx, state.Unit); !response.Success {
+				log.Warnf(ctx, "restart %s failed, %s", state.Unit, response.Err)
+			}
+		case <-ctx.Done():
+			return spec.ReturnSuccess(uid)
+		}
+	}
+}
+
+func (rse *RestartLoopSystemdExecutor) restart(ctx context.Context, unit string) *spec.Response {
+	log.Infof(ctx, "restart %s", unit)
+	return rse.channel.Run(ctx, "systemctl", fmt.Sprintf(`restart "%s"`, unit))
+}
+
+func (rse *RestartLoopSystemdExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	// stop the restart loop first, otherwise it may restart the service again
+	ctx = context.WithValue(ctx, "bin", RestartLoopSystemdBin)
+	response := exec.Destroy(ctx, rse.channel, "systemd restart-loop")
+	if !response.Success {
+		return response
+	}
+	stateFile := getUnitStateFile(uid)
+	state, err := readUnitState(stateFile)
+	if err != nil {
+		log.Warnf(ctx, "read the unit state failed, the experiment may be destroyed, %v", err)
+		return spec.ReturnSuccess(uid)
+	}
+	response = restoreUnitState(ctx, rse.channel, state)
+	if !response.Success {
+		return response
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+func (rse *RestartLoopSystemdExecutor) SetChannel(channel spec.Channel) {
+	rse.channel = channel
+}
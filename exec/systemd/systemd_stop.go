@@ -19,6 +19,7 @@ package systemd
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
@@ -41,10 +42,12 @@ func NewStopSystemdActionCommandSpec() spec.ExpActionCommandSpec {
 					Desc: "Service name",
 				},
 			},
-			ActionFlags:    []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				forceFlag,
+			},
 			ActionExecutor: &StopSystemdExecutor{},
 			ActionExample: `
- # Stop the service test
+ # Stop the service test, it is started when the experiment is destroyed if it was active
  blade create systemd stop --service test`,
 			ActionPrograms:   []string{StopSystemdBin},
 			ActionCategories: []string{category.SystemSystemd},
@@ -68,7 +71,7 @@ func (k *StopSystemdActionCommandSpec) LongDesc() string {
 	if k.ActionLongDesc != "" {
 		return k.ActionLongDesc
 	}
-	return "Stop system by service name"
+	return "Stop system by service name, the service is returned to the original state when the experiment is destroyed"
 }
 
 func (*StopSystemdActionCommandSpec) Categories() []string {
@@ -91,30 +94,41 @@ func (sse *StopSystemdExecutor) Exec(uid string, ctx context.Context, model *spe
 	}
 
 	if _, ok := spec.IsDestroy(ctx); ok {
-		return sse.startService(service, ctx)
-	} else {
-		if response := checkServiceInvalid(uid, service, ctx, sse.channel); response != nil {
-			return response
-		}
-		return sse.channel.Run(ctx, "systemctl", fmt.Sprintf("stop %s", service))
+		return sse.stop(ctx, uid, service)
+	}
+	state, response := checkUnit(ctx, sse.channel, service, model.ActionFlags["force"] == "true")
+	if response != nil {
+		return response
 	}
+	return sse.start(ctx, uid, state)
 }
 
-func checkServiceInvalid(uid, service string, ctx context.Context, cl spec.Channel) *spec.Response {
-	if !cl.IsCommandAvailable(ctx, "systemctl") {
-		log.Errorf(ctx, "%s", spec.CommandSystemctlNotFound.Msg)
-		return spec.ResponseFailWithFlags(spec.CommandSystemctlNotFound)
+func (sse *StopSystemdExecutor) start(ctx context.Context, uid string, state *unitState) *spec.Response {
+	stateFile := getUnitStateFile(uid)
+	if response := saveUnitState(ctx, stateFile, state); response != nil {
+		return response
 	}
-	response := cl.Run(ctx, "systemctl", fmt.Sprintf(`status "%s" | grep 'Active' | grep 'running'`, service))
+	response := sse.channel.Run(ctx, "systemctl", fmt.Sprintf(`stop "%s"`, state.Unit))
 	if !response.Success {
-		log.Errorf(ctx, "%s", spec.SystemdNotFound.Sprintf("service", response.Err))
-		return spec.ResponseFailWithFlags(spec.SystemdNotFound, service, response.Err)
+		os.Remove(stateFile)
 	}
-	return nil
+	return response
 }
 
-func (sse *StopSystemdExecutor) startService(service string, ctx context.Context) *spec.Response {
-	return sse.channel.Run(ctx, "systemctl", fmt.Sprintf("start %s", service))
+func (sse *StopSystemdExecutor) stop(ctx context.Context, uid, service string) *spec.Response {
+	stateFile := getUnitStateFile(uid)
+	state, err := readUnitState(stateFile)
+	if err != nil {
+		// the experiment created by the old version has no state, the service is started directly
+		log.Warnf(ctx, "read the unit state failed, start the service directly, %v", err)
+		return sse.channel.Run(ctx, "systemctl", fmt.Sprintf(`start "%s"`, getUnitName(service)))
+	}
+	response := restoreUnitState(ctx, sse.channel, state)
+	if !response.Success {
+		return response
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
 }
 
 func (sse *StopSystemdExecutor) SetChannel(channel spec.Channel) {
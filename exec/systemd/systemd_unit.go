@@ -0,0 +1,164 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package systemd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// the units which the remote access and the logs rely on, touching them requires the force flag
+var protectedUnits = []string{
+	"ssh.service",
+	"sshd.service",
+	"systemd-journald.service",
+}
+
+var forceFlag = &spec.ExpFlag{
+	Name:   "force",
+	Desc:   "Touch the protected units, such as ssh and systemd-journald",
+	NoArgs: true,
+}
+
+// unitState is the state of the unit before the experiment, which the unit is returned to when destroyed
+type unitState struct {
+	Unit          string `json:"unit"`
+	ActiveState   string `json:"activeState"`
+	UnitFileState string `json:"unitFileState"`
+	MainPid       int    `json:"mainPid"`
+}
+
+func (us *unitState) isActive() bool {
+	return us.ActiveState == "active" || us.ActiveState == "activating" || us.ActiveState == "reloading"
+}
+
+// checkUnit checks the unit is loaded and not protected, it returns the state of the unit
+func checkUnit(ctx context.Context, cl spec.Channel, service string, force bool) (*unitState, *spec.Response) {
+	if !cl.IsCommandAvailable(ctx, "systemctl") {
+		log.Errorf(ctx, "%s", spec.CommandSystemctlNotFound.Msg)
+		return nil, spec.ResponseFailWithFlags(spec.CommandSystemctlNotFound)
+	}
+	unit := getUnitName(service)
+	if !force {
+		for _, protected := range protectedUnits {
+			if unit == protected {
+				log.Errorf(ctx, "`%s`: the unit is protected", unit)
+				return nil, spec.ResponseFailWithFlags(spec.ParameterInvalid, "service", service,
+					"the unit is protected, add the force flag to touch it")
+			}
+		}
+	}
+	return getUnitState(ctx, cl, unit)
+}
+
+// getUnitState returns the state of the unit by systemctl show, the unit which is not loaded is regarded as not found
+func getUnitState(ctx context.Context, cl spec.Channel, unit string) (*unitState, *spec.Response) {
+	response := cl.Run(ctx, "systemctl", fmt.Sprintf(`show -p LoadState -p ActiveState -p UnitFileState -p MainPID "%s"`, unit))
+	if !response.Success {
+		log.Errorf(ctx, "%s", spec.SystemdNotFound.Sprintf(unit, response.Err))
+		return nil, spec.ResponseFailWithFlags(spec.SystemdNotFound, unit, response.Err)
+	}
+	properties := make(map[string]string)
+	for _, line := range strings.Split(response.Result.(string), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			properties[key] = value
+		}
+	}
+	if properties["LoadState"] != "loaded" {
+		log.Errorf(ctx, "%s", spec.SystemdNotFound.Sprintf(unit, properties["LoadState"]))
+		return nil, spec.ResponseFailWithFlags(spec.SystemdNotFound, unit, "LoadState="+properties["LoadState"])
+	}
+	mainPid, _ := strconv.Atoi(properties["MainPID"])
+	return &unitState{
+		Unit:          unit,
+		ActiveState:   properties["ActiveState"],
+		UnitFileState: properties["UnitFileState"],
+		MainPid:       mainPid,
+	}, nil
+}
+
+// restoreUnitState returns the unit to the original state, the unit which was stopped is not started
+func restoreUnitState(ctx context.Context, cl spec.Channel, original *unitState) *spec.Response {
+	current, response := getUnitState(ctx, cl, original.Unit)
+	if response != nil {
+		return response
+	}
+	if original.isActive() && !current.isActive() {
+		if response := cl.Run(ctx, "systemctl", fmt.Sprintf(`start "%s"`, original.Unit)); !response.Success {
+			return response
+		}
+	} else if !original.isActive() && current.isActive() {
+		if response := cl.Run(ctx, "systemctl", fmt.Sprintf(`stop "%s"`, original.Unit)); !response.Success {
+			return response
+		}
+	}
+	if original.UnitFileState == "enabled" && current.UnitFileState == "disabled" {
+		return cl.Run(ctx, "systemctl", fmt.Sprintf(`enable "%s"`, original.Unit))
+	} else if original.UnitFileState == "disabled" && current.UnitFileState == "enabled" {
+		return cl.Run(ctx, "systemctl", fmt.Sprintf(`disable "%s"`, original.Unit))
+	}
+	return spec.ReturnSuccess(original.Unit)
+}
+
+func getUnitName(service string) string {
+	if strings.Contains(service, ".") {
+		return service
+	}
+	return service + ".service"
+}
+
+func getUnitStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-systemd-%s.json", uid)
+}
+
+// saveUnitState writes the state of the unit before the experiment, the existing state file is not overwritten
+func saveUnitState(ctx context.Context, stateFile string, state *unitState) *spec.Response {
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the unit state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	if err := writeUnitState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write the unit state failed, %v", err)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "writeUnitState", err)
+	}
+	return nil
+}
+
+func writeUnitState(stateFile string, state *unitState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readUnitState(stateFile string) (*unitState, error) {
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	var state unitState
+	err = json.Unmarshal(data, &state)
+	return &state, err
+}
@@ -17,7 +17,12 @@
 package time
 
 import (
+	"context"
+	"os"
+
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 )
 
 type TimeCommandSpec struct {
@@ -30,6 +35,9 @@ func NewTimeCommandSpec() spec.ExpModelCommandSpec {
 			ExpFlags: []spec.ExpFlagSpec{},
 			ExpActions: []spec.ExpActionCommandSpec{
 				NewTravelTimeActionCommandSpec(),
+				NewFakeTimeActionCommandSpec(),
+				NewTimezoneActionCommandSpec(),
+				NewStopSyncActionCommandSpec(),
 			},
 		},
 	}
@@ -46,3 +54,33 @@ func (*TimeCommandSpec) ShortDesc() string {
 func (*TimeCommandSpec) LongDesc() string {
 	return "Time experiment"
 }
+
+// claimClock records the experiment which changes the system clock and starts it, the record is released if
+// the start fails
+func claimClock(ctx context.Context, cl spec.Channel, uid string, model *spec.ExpModel, start func() *spec.Response) *spec.Response {
+	if _, response := exec.ClaimResources(ctx, cl, uid, model.Target, model.ActionName, model.ActionFlags,
+		exec.ClockResource); response != nil {
+		return response
+	}
+	response := start()
+	if !response.Success {
+		exec.ReleaseResources(ctx, uid)
+	}
+	return response
+}
+
+// releaseClock releases the record of the experiment if the clock is restored
+func releaseClock(ctx context.Context, uid string, response *spec.Response) *spec.Response {
+	if response.Success {
+		exec.ReleaseResources(ctx, uid)
+	}
+	return response
+}
+
+// stateFileStatus checks the state file which is removed when the experiment is destroyed
+func stateFileStatus(uid string, model *spec.ExpModel, state *exec.ExperimentState, stateFile string) *exec.StatusReport {
+	report := exec.NewStatusReport(uid, model, state)
+	_, err := os.Stat(stateFile)
+	report.Add(exec.Artifact{Kind: "file", Name: stateFile, Present: err == nil})
+	return report
+}
@@ -0,0 +1,267 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package time
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const FakeTimeBin = "chaos_faketime"
+
+const (
+	FakeModeSystemd  = "systemd"
+	FakeModeRelaunch = "relaunch"
+)
+
+// the libfaketime locations of the common distributions, the bundled one is searched first
+var libFaketimePaths = []string{
+	"/usr/lib/x86_64-linux-gnu/faketime/libfaketime.so.1",
+	"/usr/lib/aarch64-linux-gnu/faketime/libfaketime.so.1",
+	"/usr/lib64/faketime/libfaketime.so.1",
+	"/usr/lib/faketime/libfaketime.so.1",
+	"/usr/local/lib/faketime/libfaketime.so.1",
+}
+
+type FakeTimeActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewFakeTimeActionCommandSpec() spec.ExpActionCommandSpec {
+	return &FakeTimeActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "pid",
+					Desc: "The process id",
+				},
+				&spec.ExpFlag{
+					Name: "process",
+					Desc: "The process name",
+				},
+			},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: "offset",
+					Desc: "Fake time offset, for example: -2h3m50s",
+				},
+				&spec.ExpFlag{
+					Name: "libfaketime",
+					Desc: "The path of libfaketime.so.1, the bundled one and the one installed by the package manager are used if it is absent",
+				},
+			},
+			ActionExecutor: &FakeTimeExecutor{},
+			ActionExample: `
+# The process nginx sees the time 2 hours later, it is restarted with libfaketime
+blade create time fake --process nginx --offset 2h
+
+# The process 1234 sees the time 30 minutes earlier, with the libfaketime in the custom path
+blade create time fake --pid 1234 --offset -30m --libfaketime /opt/faketime/libfaketime.so.1
+`,
+			ActionPrograms:   []string{FakeTimeBin},
+			ActionCategories: []string{category.SystemTime},
+		},
+	}
+}
+
+func (*FakeTimeActionCommandSpec) Name() string {
+	return "fake"
+}
+
+func (*FakeTimeActionCommandSpec) Aliases() []string {
+	return []string{}
+}
+
+func (*FakeTimeActionCommandSpec) ShortDesc() string {
+	return "Fake time of process"
+}
+
+func (k *FakeTimeActionCommandSpec) LongDesc() string {
+	if k.ActionLongDesc != "" {
+		return k.ActionLongDesc
+	}
+	return "Restart the process with libfaketime, so that only the process sees the fake time and the system clock is not changed. " +
+		"The systemd service is restarted by a drop-in, other process is killed and relaunched with the same command line, environment variables and working directory. " +
+		"libfaketime works by LD_PRELOAD, so it only supports the dynamically linked glibc programs, the static, musl and go programs are refused."
+}
+
+func (*FakeTimeActionCommandSpec) Categories() []string {
+	return []string{category.SystemTime}
+}
+
+type FakeTimeExecutor struct {
+	channel spec.Channel
+}
+
+func (fte *FakeTimeExecutor) Name() string {
+	return "fake"
+}
+
+// fakeTimeState is the way how the process is restarted, which is used to restart it back to the real time
+type fakeTimeState struct {
+	Mode    string   `json:"mode"`
+	Unit    string   `json:"unit,omitempty"`
+	DropIn  string   `json:"dropIn,omitempty"`
+	Pid     int      `json:"pid,omitempty"`
+	Exe     string   `json:"exe,omitempty"`
+	Cmdline []string `json:"cmdline,omitempty"`
+	Cwd     string   `json:"cwd,omitempty"`
+	Environ []string `json:"environ,omitempty"`
+	Uid     uint32   `json:"uid,omitempty"`
+	Gid     uint32   `json:"gid,omitempty"`
+}
+
+func (fte *FakeTimeExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return fte.stop(ctx, uid)
+	}
+	offsetStr := model.ActionFlags["offset"]
+	if offsetStr == "" {
+		log.Errorf(ctx, "offset is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "offset")
+	}
+	offset, err := time.ParseDuration(offsetStr)
+	if err != nil {
+		log.Errorf(ctx, "offset is invalid")
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "offset", offsetStr, err)
+	}
+	pids, response := fte.getTargetPids(ctx, model)
+	if response != nil {
+		return response
+	}
+	libFaketime, response := findLibFaketime(ctx, model.ActionFlags["libfaketime"])
+	if response != nil {
+		return response
+	}
+	stateFile := getFakeTimeStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the fake time state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	return fte.start(ctx, uid, pids, libFaketime, getFaketimeOffset(offset))
+}
+
+func (fte *FakeTimeExecutor) SetChannel(channel spec.Channel) {
+	fte.channel = channel
+}
+
+func (fte *FakeTimeExecutor) getTargetPids(ctx context.Context, model *spec.ExpModel) ([]int, *spec.Response) {
+	pidStr := model.ActionFlags["pid"]
+	process := model.ActionFlags["process"]
+	if pidStr == "" && process == "" {
+		log.Errorf(ctx, "less pid and process")
+		return nil, spec.ResponseFailWithFlags(spec.ParameterLess, "pid|process")
+	}
+	if pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil || pid < 1 {
+			log.Errorf(ctx, "`%s`: pid is illegal", pidStr)
+			return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, "pid", pidStr, "it must be a positive integer")
+		}
+		return []int{pid}, nil
+	}
+	pidStrs, err := fte.channel.GetPidsByProcessName(process, ctx)
+	if err != nil {
+		log.Errorf(ctx, "get pids by process name failed, %v", err)
+		return nil, spec.ResponseFailWithFlags(spec.ProcessIdByNameFailed, process, err)
+	}
+	if len(pidStrs) == 0 {
+		log.Errorf(ctx, "`%s`: process not found", process)
+		return nil, spec.ResponseFailWithFlags(spec.ParameterInvalidProName, "process", process)
+	}
+	pids := make([]int, 0, len(pidStrs))
+	for _, p := range pidStrs {
+		pid, err := strconv.Atoi(p)
+		if err != nil {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// findLibFaketime returns the specified libfaketime, or the bundled one, or the one installed by the package manager
+func findLibFaketime(ctx context.Context, specified string) (string, *spec.Response) {
+	if specified != "" {
+		if !util.IsExist(specified) {
+			log.Errorf(ctx, "`%s`: libfaketime not found", specified)
+			return "", spec.ResponseFailWithFlags(spec.ParameterInvalid, "libfaketime", specified, "it is not found")
+		}
+		return specified, nil
+	}
+	candidates := append([]string{path.Join(util.GetProgramPath(), "lib", "faketime", "libfaketime.so.1")}, libFaketimePaths...)
+	for _, candidate := range candidates {
+		if util.IsExist(candidate) {
+			return candidate, nil
+		}
+	}
+	log.Errorf(ctx, "libfaketime not found in %v", candidates)
+	return "", spec.ResponseFailWithFlags(spec.ParameterLess, "libfaketime, it is not found, please install libfaketime or specify the path")
+}
+
+// getFaketimeOffset returns the FAKETIME value of the relative offset in seconds
+func getFaketimeOffset(offset time.Duration) string {
+	seconds := strconv.FormatFloat(offset.Seconds(), 'f', -1, 64)
+	if !strings.HasPrefix(seconds, "-") {
+		seconds = "+" + seconds
+	}
+	return seconds
+}
+
+// getFaketimeEnv returns the environment variables which inject libfaketime, the monotonic clock is not faked
+// to keep the timers and timeouts of the process working
+func getFaketimeEnv(libFaketime, offset string) []string {
+	return []string{
+		fmt.Sprintf("LD_PRELOAD=%s", libFaketime),
+		fmt.Sprintf("FAKETIME=%s", offset),
+		"FAKETIME_DONT_FAKE_MONOTONIC=1",
+	}
+}
+
+func getFakeTimeStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-time-fake-%s.json", uid)
+}
+
+func writeFakeTimeState(stateFile string, state fakeTimeState) error {
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, bytes, 0600)
+}
+
+func readFakeTimeState(stateFile string) (fakeTimeState, error) {
+	var state fakeTimeState
+	bytes, err := os.ReadFile(stateFile)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(bytes, &state)
+	return state, err
+}
@@ -0,0 +1,326 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package time
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+const systemdUnitDir = "/etc/systemd/system"
+
+// the time to wait for the process to exit after SIGTERM, it is killed by SIGKILL then
+const processExitTimeout = 10 * time.Second
+
+func (fte *FakeTimeExecutor) start(ctx context.Context, uid string, pids []int, libFaketime, offset string) *spec.Response {
+	for _, pid := range pids {
+		if err := checkFakeTimeTarget(pid); err != nil {
+			log.Errorf(ctx, "the process %d cannot fake time by libfaketime, %v", pid, err)
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "pid", strconv.Itoa(pid), err)
+		}
+	}
+	unit := exec.SystemdUnit(pids[0])
+	for _, pid := range pids[1:] {
+		if exec.SystemdUnit(pid) != unit || unit == "" {
+			log.Errorf(ctx, "the processes %v are not in the same systemd service", pids)
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "process", fmt.Sprint(pids),
+				"matched more than one process which are not in the same systemd service, please specify the pid")
+		}
+	}
+	stateFile := getFakeTimeStateFile(uid)
+	if unit != "" && isSystemdBooted() && fte.channel.IsCommandAvailable(ctx, "systemctl") {
+		return fte.startSystemd(ctx, uid, stateFile, unit, libFaketime, offset)
+	}
+	return fte.startRelaunch(ctx, uid, stateFile, pids[0], libFaketime, offset)
+}
+
+// startSystemd restarts the service with a drop-in which injects libfaketime
+func (fte *FakeTimeExecutor) startSystemd(ctx context.Context, uid, stateFile, unit, libFaketime, offset string) *spec.Response {
+	dropIn := fmt.Sprintf("%s/%s.d/chaosblade-faketime-%s.conf", systemdUnitDir, unit, uid)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# created by chaosblade experiment %s\n[Service]\n", uid))
+	for _, env := range getFaketimeEnv(libFaketime, offset) {
+		sb.WriteString(fmt.Sprintf("Environment=\"%s\"\n", strings.ReplaceAll(env, "%", "%%")))
+	}
+	if err := os.MkdirAll(fmt.Sprintf("%s/%s.d", systemdUnitDir, unit), 0755); err != nil {
+		log.Errorf(ctx, "create the drop-in directory failed, %v", err)
+		return exec.FailWithFlags(exec.RuleInstallFailed, "systemd", "mkdir", err)
+	}
+	if err := os.WriteFile(dropIn, []byte(sb.String()), 0644); err != nil {
+		log.Errorf(ctx, "write the drop-in file failed, %v", err)
+		return exec.FailWithFlags(exec.RuleInstallFailed, "systemd", "write", err)
+	}
+	state := fakeTimeState{Mode: FakeModeSystemd, Unit: unit, DropIn: dropIn}
+	if err := writeFakeTimeState(stateFile, state); err != nil {
+		os.Remove(dropIn)
+		log.Errorf(ctx, "write the fake time state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "faketime", "writeFakeTimeState", err)
+	}
+	if response := fte.restartUnit(ctx, unit); !response.Success {
+		fte.stopSystemd(ctx, state)
+		os.Remove(stateFile)
+		return response
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+// startRelaunch kills the process and relaunches it with libfaketime
+func (fte *FakeTimeExecutor) startRelaunch(ctx context.Context, uid, stateFile string, pid int, libFaketime, offset string) *spec.Response {
+	state, err := snapshotProcess(pid)
+	if err != nil {
+		log.Errorf(ctx, "read the process %d failed, %v", pid, err)
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "pid", strconv.Itoa(pid), err)
+	}
+	state.Mode = FakeModeRelaunch
+	if err := killAndWait(pid); err != nil {
+		log.Errorf(ctx, "kill the process %d failed, %v", pid, err)
+		return exec.FailWithFlags(exec.ProcessControlFailed, "faketime", "kill", err)
+	}
+	newPid, err := relaunchProcess(state, getFaketimeEnv(libFaketime, offset))
+	if err != nil {
+		log.Errorf(ctx, "relaunch the process %d with libfaketime failed, %v", pid, err)
+		// bring the process back without libfaketime
+		relaunchProcess(state, nil)
+		return exec.FailWithFlags(exec.ProcessControlFailed, "faketime", "relaunch", err)
+	}
+	state.Pid = newPid
+	if err := writeFakeTimeState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write the fake time state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "faketime", "writeFakeTimeState", err)
+	}
+	log.Infof(ctx, "the process %d is relaunched as %d with libfaketime", pid, newPid)
+	return spec.ReturnSuccess(uid)
+}
+
+func (fte *FakeTimeExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	stateFile := getFakeTimeStateFile(uid)
+	state, err := readFakeTimeState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warnf(ctx, "`%s`: the fake time state file not found, the experiment may be destroyed", stateFile)
+			return spec.ReturnSuccess(uid)
+		}
+		log.Errorf(ctx, "read the fake time state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "faketime", "readFakeTimeState", err)
+	}
+	var response *spec.Response
+	if state.Mode == FakeModeSystemd {
+		response = fte.stopSystemd(ctx, state)
+	} else {
+		response = fte.stopRelaunch(ctx, state)
+	}
+	if !response.Success {
+		return response
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+func (fte *FakeTimeExecutor) stopSystemd(ctx context.Context, state fakeTimeState) *spec.Response {
+	if err := os.Remove(state.DropIn); err != nil && !os.IsNotExist(err) {
+		log.Errorf(ctx, "remove the drop-in file failed, %v", err)
+		return exec.FailWithFlags(exec.RuleRemoveFailed, "systemd", "remove", err)
+	}
+	return fte.restartUnit(ctx, state.Unit)
+}
+
+func (fte *FakeTimeExecutor) stopRelaunch(ctx context.Context, state fakeTimeState) *spec.Response {
+	// the pid may be reused by another process if the faked one exited, which has no FAKETIME
+	if environ, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", state.Pid)); err == nil &&
+		bytes.Contains(environ, []byte("\x00FAKETIME=")) {
+		if err := killAndWait(state.Pid); err != nil {
+			log.Errorf(ctx, "kill the process %d failed, %v", state.Pid, err)
+			return exec.FailWithFlags(exec.ProcessControlFailed, "faketime", "kill", err)
+		}
+	} else {
+		log.Warnf(ctx, "the faked process %d has exited", state.Pid)
+	}
+	newPid, err := relaunchProcess(state, nil)
+	if err != nil {
+		log.Errorf(ctx, "relaunch the process failed, %v", err)
+		return exec.FailWithFlags(exec.ProcessControlFailed, "faketime", "relaunch", err)
+	}
+	log.Infof(ctx, "the process is relaunched as %d with the real time", newPid)
+	return spec.ReturnSuccess(newPid)
+}
+
+func (fte *FakeTimeExecutor) restartUnit(ctx context.Context, unit string) *spec.Response {
+	if response := fte.channel.Run(ctx, "systemctl", "daemon-reload"); !response.Success {
+		return response
+	}
+	return fte.channel.Run(ctx, "systemctl", fmt.Sprintf(`restart "%s"`, unit))
+}
+
+// checkFakeTimeTarget checks that the program is dynamically linked with glibc, which libfaketime requires
+func checkFakeTimeTarget(pid int) error {
+	file, err := elf.Open(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return fmt.Errorf("read the executable failed, %v", err)
+	}
+	defer file.Close()
+	var interp string
+	for _, prog := range file.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		data := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(data, 0); err != nil {
+			return fmt.Errorf("read the program interpreter failed, %v", err)
+		}
+		interp = string(bytes.TrimRight(data, "\x00"))
+	}
+	if interp == "" {
+		return fmt.Errorf("the executable is statically linked, LD_PRELOAD does not work")
+	}
+	if strings.Contains(interp, "musl") {
+		return fmt.Errorf("the executable is linked with musl by %s, libfaketime only supports glibc", interp)
+	}
+	if file.Section(".go.buildinfo") != nil || file.Section(".note.go.buildid") != nil {
+		return fmt.Errorf("the executable is a go program, which reads the time by vdso without libc")
+	}
+	return nil
+}
+
+func isSystemdBooted() bool {
+	comm, err := os.ReadFile("/proc/1/comm")
+	return err == nil && strings.TrimSpace(string(comm)) == "systemd"
+}
+
+// snapshotProcess records what is required to relaunch the process
+func snapshotProcess(pid int) (fakeTimeState, error) {
+	var state fakeTimeState
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return state, err
+	}
+	if strings.HasSuffix(exe, " (deleted)") {
+		return state, fmt.Errorf("the executable %s is deleted", exe)
+	}
+	cmdline, err := readNulSeparated(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return state, err
+	}
+	if len(cmdline) == 0 {
+		return state, fmt.Errorf("the process is a kernel thread")
+	}
+	environ, err := readNulSeparated(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return state, err
+	}
+	cwd, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+	if err != nil {
+		return state, err
+	}
+	info, err := os.Stat(fmt.Sprintf("/proc/%d", pid))
+	if err != nil {
+		return state, err
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	return fakeTimeState{
+		Exe:     exe,
+		Cmdline: cmdline,
+		Cwd:     cwd,
+		Environ: environ,
+		Uid:     stat.Uid,
+		Gid:     stat.Gid,
+	}, nil
+}
+
+func readNulSeparated(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimRight(data, "\x00")
+	if len(data) == 0 {
+		return []string{}, nil
+	}
+	return strings.Split(string(data), "\x00"), nil
+}
+
+// relaunchProcess starts the process in a new session with the extra environment variables, it returns the new pid
+func relaunchProcess(state fakeTimeState, extraEnv []string) (int, error) {
+	env := make([]string, 0, len(state.Environ)+len(extraEnv))
+	for _, e := range state.Environ {
+		if strings.HasPrefix(e, "LD_PRELOAD=") || strings.HasPrefix(e, "FAKETIME") {
+			continue
+		}
+		env = append(env, e)
+	}
+	env = append(env, extraEnv...)
+	cmd := &osexec.Cmd{
+		Path: state.Exe,
+		Args: state.Cmdline,
+		Env:  env,
+		Dir:  state.Cwd,
+		SysProcAttr: &syscall.SysProcAttr{
+			Setsid: true,
+		},
+	}
+	if state.Uid != uint32(os.Geteuid()) || state.Gid != uint32(os.Getegid()) {
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: state.Uid, Gid: state.Gid}
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+	return pid, cmd.Process.Release()
+}
+
+// killAndWait sends SIGTERM to the process and waits it to exit, SIGKILL is sent if it does not exit in time
+func killAndWait(pid int) error {
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		if err == syscall.ESRCH {
+			return nil
+		}
+		return err
+	}
+	deadline := time.Now().Add(processExitTimeout)
+	for time.Now().Before(deadline) {
+		if !isProcessAlive(pid) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
+
+// isProcessAlive returns false if the process exited, the zombie is regarded as exited
+func isProcessAlive(pid int) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(data[bytes.LastIndexByte(data, ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
@@ -0,0 +1,312 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package time
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+// the max skew, the experiment simulates the drift after the time sync is down but not the clock step
+const maxSyncSkew = time.Hour
+
+type StopSyncActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewStopSyncActionCommandSpec() spec.ExpActionCommandSpec {
+	return &StopSyncActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:   "mask",
+					Desc:   "Mask the time sync services until reboot, so that they cannot be started by others during the experiment",
+					NoArgs: true,
+				},
+				&spec.ExpFlag{
+					Name: "skew",
+					Desc: "Step the clock by the skew after the time sync is down, for example: 30s, -2m. It must be within 1h",
+				},
+			},
+			ActionExecutor: &StopSyncExecutor{},
+			ActionExample: `
+# Stop the active time sync service, such as chronyd, ntpd or systemd-timesyncd
+blade create time sync-stop
+
+# Stop and mask the active time sync service, then step the clock 30 seconds forward
+blade create time sync-stop --mask --skew 30s
+`,
+			ActionCategories: []string{category.SystemTime},
+		},
+	}
+}
+
+func (*StopSyncActionCommandSpec) Name() string {
+	return "sync-stop"
+}
+
+func (*StopSyncActionCommandSpec) Aliases() []string {
+	return []string{"ntp-stop"}
+}
+
+func (*StopSyncActionCommandSpec) ShortDesc() string {
+	return "Stop time sync service"
+}
+
+func (k *StopSyncActionCommandSpec) LongDesc() string {
+	if k.ActionLongDesc != "" {
+		return k.ActionLongDesc
+	}
+	return "Stop and optionally mask the active time sync services, such as chronyd, ntpd and systemd-timesyncd, and optionally step the clock by a skew. " +
+		"The services are returned to the original active and enabled state and the skew is stepped back when the experiment is destroyed"
+}
+
+func (*StopSyncActionCommandSpec) Categories() []string {
+	return []string{category.SystemTime}
+}
+
+type StopSyncExecutor struct {
+	channel spec.Channel
+}
+
+func (sse *StopSyncExecutor) Name() string {
+	return "sync-stop"
+}
+
+// syncService is the original state of the time sync service
+type syncService struct {
+	Unit          string `json:"unit"`
+	ActiveState   string `json:"activeState"`
+	UnitFileState string `json:"unitFileState"`
+	Masked        bool   `json:"masked"`
+}
+
+// stopSyncState is the stopped services and the skew which is stepped back when destroyed
+type stopSyncState struct {
+	Services []syncService `json:"services"`
+	Skew     time.Duration `json:"skew"`
+}
+
+func (sse *StopSyncExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if !sse.channel.IsCommandAvailable(ctx, "systemctl") {
+		log.Errorf(ctx, "%s", spec.CommandSystemctlNotFound.Msg)
+		return spec.ResponseFailWithFlags(spec.CommandSystemctlNotFound)
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return releaseClock(ctx, uid, sse.stop(ctx, uid))
+	}
+	var skew time.Duration
+	if skewStr := model.ActionFlags["skew"]; skewStr != "" {
+		var err error
+		skew, err = time.ParseDuration(skewStr)
+		if err != nil {
+			log.Errorf(ctx, "`%s`: skew is illegal, %v", skewStr, err)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "skew", skewStr, err)
+		}
+		if skew > maxSyncSkew || skew < -maxSyncSkew {
+			log.Errorf(ctx, "`%s`: skew is out of range", skewStr)
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "skew", skewStr, "it must be within 1h")
+		}
+	}
+	return claimClock(ctx, sse.channel, uid, model, func() *spec.Response {
+		return sse.start(ctx, uid, model.ActionFlags["mask"] == "true", skew)
+	})
+}
+
+func (sse *StopSyncExecutor) SetChannel(channel spec.Channel) {
+	sse.channel = channel
+}
+
+// Status checks the state file of the stopped services
+func (sse *StopSyncExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	return stateFileStatus(uid, model, state, getStopSyncStateFile(uid))
+}
+
+func (sse *StopSyncExecutor) start(ctx context.Context, uid string, mask bool, skew time.Duration) *spec.Response {
+	stateFile := getStopSyncStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the sync stop state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	services := sse.getActiveSyncServices(ctx)
+	if len(services) == 0 {
+		log.Errorf(ctx, "no active time sync service found")
+		return exec.Fail(exec.TargetNotFound, "timesync", "list services", "no active time sync service found")
+	}
+	state := stopSyncState{Services: services}
+	if err := writeStopSyncState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write the sync stop state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "timesync", "writeStopSyncState", err)
+	}
+	for i := range state.Services {
+		service := &state.Services[i]
+		log.Infof(ctx, "stop the time sync service %s", service.Unit)
+		if mask {
+			// the runtime mask is removed by reboot, so that the service is not left masked if the experiment is not destroyed
+			if response := sse.channel.Run(ctx, "systemctl", fmt.Sprintf(`mask --runtime "%s"`, service.Unit)); !response.Success {
+				sse.stop(ctx, uid)
+				return response
+			}
+			service.Masked = true
+			if err := writeStopSyncState(stateFile, state); err != nil {
+				log.Warnf(ctx, "write the sync stop state failed, %v", err)
+			}
+		}
+		if response := sse.channel.Run(ctx, "systemctl", fmt.Sprintf(`stop "%s"`, service.Unit)); !response.Success {
+			sse.stop(ctx, uid)
+			return response
+		}
+	}
+	if skew != 0 {
+		log.Infof(ctx, "step the clock by %s", skew)
+		tte := &TravelTimeExecutor{channel: sse.channel}
+		if response := tte.setSystemTime(ctx, time.Now().Add(skew)); !response.Success {
+			sse.stop(ctx, uid)
+			return response
+		}
+		state.Skew = skew
+		if err := writeStopSyncState(stateFile, state); err != nil {
+			log.Warnf(ctx, "write the sync stop state failed, %v", err)
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (sse *StopSyncExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	stateFile := getStopSyncStateFile(uid)
+	state, err := readStopSyncState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warnf(ctx, "`%s`: the sync stop state file not found, the experiment may be destroyed", stateFile)
+			return spec.ReturnSuccess(uid)
+		}
+		log.Errorf(ctx, "read the sync stop state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "timesync", "readStopSyncState", err)
+	}
+	// step back before the services are started, they may slew the clock slowly
+	if state.Skew != 0 {
+		tte := &TravelTimeExecutor{channel: sse.channel}
+		if response := tte.setSystemTime(ctx, time.Now().Add(-state.Skew)); !response.Success {
+			log.Warnf(ctx, "step back the clock by %s failed, %s", state.Skew, response.Err)
+		}
+	}
+	failed := make([]string, 0)
+	for _, service := range state.Services {
+		if err := sse.restoreSyncService(ctx, service); err != nil {
+			log.Errorf(ctx, "restore the time sync service %s failed, %v", service.Unit, err)
+			failed = append(failed, service.Unit)
+		}
+	}
+	if len(failed) > 0 {
+		return exec.Fail(exec.RestoreFailed, "timesync", "restore", fmt.Sprintf("restore the time sync services %s failed", strings.Join(failed, ",")))
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+// restoreSyncService unmasks the service and returns it to the original enabled and active state
+func (sse *StopSyncExecutor) restoreSyncService(ctx context.Context, original syncService) error {
+	if original.Masked {
+		if response := sse.channel.Run(ctx, "systemctl", fmt.Sprintf(`unmask --runtime "%s"`, original.Unit)); !response.Success {
+			return fmt.Errorf("unmask failed, %s", response.Err)
+		}
+	}
+	current, err := sse.getSyncService(ctx, original.Unit)
+	if err != nil {
+		return err
+	}
+	if original.UnitFileState == "enabled" && current.UnitFileState == "disabled" {
+		if response := sse.channel.Run(ctx, "systemctl", fmt.Sprintf(`enable "%s"`, original.Unit)); !response.Success {
+			return fmt.Errorf("enable failed, %s", response.Err)
+		}
+	}
+	if original.ActiveState == "active" && current.ActiveState != "active" {
+		if response := sse.channel.Run(ctx, "systemctl", fmt.Sprintf(`start "%s"`, original.Unit)); !response.Success {
+			return fmt.Errorf("start failed, %s", response.Err)
+		}
+	}
+	return nil
+}
+
+// getActiveSyncServices returns the active time sync services, the aliases, such as chronyd and chrony, are the same unit
+func (sse *StopSyncExecutor) getActiveSyncServices(ctx context.Context) []syncService {
+	services := make([]syncService, 0)
+	units := make(map[string]bool)
+	for _, name := range append(append([]string{}, ntpServices...), timesyncdService) {
+		service, err := sse.getSyncService(ctx, name)
+		if err != nil || service.ActiveState != "active" || units[service.Unit] {
+			continue
+		}
+		units[service.Unit] = true
+		services = append(services, service)
+	}
+	return services
+}
+
+func (sse *StopSyncExecutor) getSyncService(ctx context.Context, name string) (syncService, error) {
+	var service syncService
+	response := sse.channel.Run(ctx, "systemctl", fmt.Sprintf(`show -p Id -p LoadState -p ActiveState -p UnitFileState "%s"`, name))
+	if !response.Success {
+		return service, fmt.Errorf("%s", response.Err)
+	}
+	properties := make(map[string]string)
+	for _, line := range strings.Split(response.Result.(string), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			properties[key] = value
+		}
+	}
+	if properties["LoadState"] != "loaded" && properties["LoadState"] != "masked" {
+		return service, fmt.Errorf("%s is not loaded", name)
+	}
+	service.Unit = properties["Id"]
+	service.ActiveState = properties["ActiveState"]
+	service.UnitFileState = properties["UnitFileState"]
+	return service, nil
+}
+
+func getStopSyncStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-time-sync-stop-%s.json", uid)
+}
+
+func writeStopSyncState(stateFile string, state stopSyncState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readStopSyncState(stateFile string) (stopSyncState, error) {
+	var state stopSyncState
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
@@ -0,0 +1,328 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package time
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
+)
+
+const (
+	zoneInfoDir      = "/usr/share/zoneinfo"
+	localtimeFile    = "/etc/localtime"
+	timezoneFile     = "/etc/timezone"
+	tzFileMagic      = "TZif"
+	TimezoneByCtl    = "timedatectl"
+	TimezoneByRelink = "relink"
+)
+
+type TimezoneActionCommandSpec struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewTimezoneActionCommandSpec() spec.ExpActionCommandSpec {
+	return &TimezoneActionCommandSpec{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     "zone",
+					Desc:     "The timezone in the tzdata directory, for example: Asia/Shanghai",
+					Required: true,
+				},
+			},
+			ActionExecutor: &TimezoneExecutor{},
+			ActionExample: `
+# Change the system timezone to Asia/Shanghai
+blade create time timezone --zone Asia/Shanghai
+
+# Change the system timezone to UTC
+blade create time timezone --zone UTC
+`,
+			ActionCategories: []string{category.SystemTime},
+		},
+	}
+}
+
+func (*TimezoneActionCommandSpec) Name() string {
+	return "timezone"
+}
+
+func (*TimezoneActionCommandSpec) Aliases() []string {
+	return []string{"tz"}
+}
+
+func (*TimezoneActionCommandSpec) ShortDesc() string {
+	return "Change timezone"
+}
+
+func (k *TimezoneActionCommandSpec) LongDesc() string {
+	if k.ActionLongDesc != "" {
+		return k.ActionLongDesc
+	}
+	return "Change the system timezone by timedatectl or by re-linking /etc/localtime, the original timezone is restored when the experiment is destroyed"
+}
+
+func (*TimezoneActionCommandSpec) Categories() []string {
+	return []string{category.SystemTime}
+}
+
+type TimezoneExecutor struct {
+	channel spec.Channel
+}
+
+func (tze *TimezoneExecutor) Name() string {
+	return "timezone"
+}
+
+// timezoneState is the original timezone, /etc/localtime is a symlink, a regular file or missing
+type timezoneState struct {
+	Method        string `json:"method"`
+	Zone          string `json:"zone"`
+	Missing       bool   `json:"missing"`
+	LinkTarget    string `json:"linkTarget,omitempty"`
+	Localtime     []byte `json:"localtime,omitempty"`
+	TimezoneFile  bool   `json:"timezoneFile"`
+	TimezoneValue []byte `json:"timezoneValue,omitempty"`
+}
+
+func (tze *TimezoneExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return tze.stop(ctx, uid)
+	}
+	zone := model.ActionFlags["zone"]
+	if zone == "" {
+		log.Errorf(ctx, "zone is nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "zone")
+	}
+	if err := checkZone(zone); err != nil {
+		log.Errorf(ctx, "`%s`: zone is invalid, %v", zone, err)
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "zone", zone, err)
+	}
+	return tze.start(ctx, uid, zone)
+}
+
+func (tze *TimezoneExecutor) SetChannel(channel spec.Channel) {
+	tze.channel = channel
+}
+
+func (tze *TimezoneExecutor) start(ctx context.Context, uid, zone string) *spec.Response {
+	stateFile := getTimezoneStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the timezone state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	state, err := snapshotTimezone()
+	if err != nil {
+		log.Errorf(ctx, "read the original timezone failed, %v", err)
+		return exec.FailWithFlags(exec.BackupFailed, "timezone", "snapshotTimezone", err)
+	}
+	timedatectlAvailable := tze.channel.IsCommandAvailable(ctx, "timedatectl")
+	if timedatectlAvailable {
+		response := tze.channel.Run(ctx, "timedatectl", "show -p Timezone --value")
+		if response.Success {
+			state.Zone = strings.TrimSpace(response.Result.(string))
+		}
+	}
+	log.Infof(ctx, "the original timezone is %s", state.Zone)
+
+	state.Method = TimezoneByRelink
+	if timedatectlAvailable {
+		state.Method = TimezoneByCtl
+	}
+	if err := writeTimezoneState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write the timezone state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "timezone", "writeTimezoneState", err)
+	}
+	if timedatectlAvailable {
+		response := tze.channel.Run(ctx, "timedatectl", fmt.Sprintf(`set-timezone "%s"`, zone))
+		if response.Success {
+			return spec.ReturnSuccess(uid)
+		}
+		log.Warnf(ctx, "set the timezone by timedatectl failed, re-link %s instead, %s", localtimeFile, response.Err)
+		state.Method = TimezoneByRelink
+		if err := writeTimezoneState(stateFile, state); err != nil {
+			log.Errorf(ctx, "write the timezone state failed, %v", err)
+			return exec.FailWithFlags(exec.StateRecordFailed, "timezone", "writeTimezoneState", err)
+		}
+	}
+	if err := relinkLocaltime(uid, path.Join(zoneInfoDir, zone)); err != nil {
+		log.Errorf(ctx, "re-link %s failed, %v", localtimeFile, err)
+		os.Remove(stateFile)
+		return exec.FailWithFlags(exec.SettingApplyFailed, "timezone", "relink", err)
+	}
+	if state.TimezoneFile {
+		if err := os.WriteFile(timezoneFile, []byte(zone+"\n"), 0644); err != nil {
+			log.Warnf(ctx, "update %s failed, %v", timezoneFile, err)
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func (tze *TimezoneExecutor) stop(ctx context.Context, uid string) *spec.Response {
+	stateFile := getTimezoneStateFile(uid)
+	state, err := readTimezoneState(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warnf(ctx, "`%s`: the timezone state file not found, the experiment may be destroyed", stateFile)
+			return spec.ReturnSuccess(uid)
+		}
+		log.Errorf(ctx, "read the timezone state failed, %v", err)
+		return exec.FailWithFlags(exec.StateRecordFailed, "timezone", "readTimezoneState", err)
+	}
+	// let timedated know the original timezone, the exact /etc/localtime is restored then
+	if state.Method == TimezoneByCtl && state.Zone != "" {
+		response := tze.channel.Run(ctx, "timedatectl", fmt.Sprintf(`set-timezone "%s"`, state.Zone))
+		if !response.Success {
+			log.Warnf(ctx, "restore the timezone by timedatectl failed, %s", response.Err)
+		}
+	}
+	if err := restoreLocaltime(uid, state); err != nil {
+		log.Errorf(ctx, "restore %s failed, %v", localtimeFile, err)
+		return exec.FailWithFlags(exec.RestoreFailed, "timezone", "restoreLocaltime", err)
+	}
+	os.Remove(stateFile)
+	return spec.ReturnSuccess(uid)
+}
+
+// checkZone checks that the zone is a tzdata file in the zoneinfo directory
+func checkZone(zone string) error {
+	if path.IsAbs(zone) || strings.Contains(zone, "..") {
+		return fmt.Errorf("it must be a relative name in %s", zoneInfoDir)
+	}
+	file := path.Join(zoneInfoDir, zone)
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(data, []byte(tzFileMagic)) {
+		return fmt.Errorf("%s is not a tzdata file", file)
+	}
+	return nil
+}
+
+// snapshotTimezone records /etc/localtime, which is a symlink or a regular file, and /etc/timezone
+func snapshotTimezone() (timezoneState, error) {
+	var state timezoneState
+	info, err := os.Lstat(localtimeFile)
+	switch {
+	case os.IsNotExist(err):
+		state.Missing = true
+	case err != nil:
+		return state, err
+	case info.Mode()&os.ModeSymlink != 0:
+		if state.LinkTarget, err = os.Readlink(localtimeFile); err != nil {
+			return state, err
+		}
+		if index := strings.Index(state.LinkTarget, "zoneinfo/"); index >= 0 {
+			state.Zone = state.LinkTarget[index+len("zoneinfo/"):]
+		}
+	default:
+		if state.Localtime, err = os.ReadFile(localtimeFile); err != nil {
+			return state, err
+		}
+	}
+	if data, err := os.ReadFile(timezoneFile); err == nil {
+		state.TimezoneFile = true
+		state.TimezoneValue = data
+		if state.Zone == "" {
+			state.Zone = strings.TrimSpace(string(data))
+		}
+	}
+	return state, nil
+}
+
+// relinkLocaltime replaces /etc/localtime with the symlink to the zone file atomically
+func relinkLocaltime(uid, target string) error {
+	tmpLink := fmt.Sprintf("%s.chaosblade-%s", localtimeFile, uid)
+	os.Remove(tmpLink)
+	if err := os.Symlink(target, tmpLink); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpLink, localtimeFile); err != nil {
+		os.Remove(tmpLink)
+		return err
+	}
+	return nil
+}
+
+// restoreLocaltime restores /etc/localtime to the original symlink or regular file, and /etc/timezone
+func restoreLocaltime(uid string, state timezoneState) error {
+	switch {
+	case state.Missing:
+		if err := os.Remove(localtimeFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	case state.LinkTarget != "":
+		if err := relinkLocaltime(uid, state.LinkTarget); err != nil {
+			return err
+		}
+	default:
+		tmpFile := fmt.Sprintf("%s.chaosblade-%s", localtimeFile, uid)
+		if err := os.WriteFile(tmpFile, state.Localtime, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmpFile, localtimeFile); err != nil {
+			os.Remove(tmpFile)
+			return err
+		}
+	}
+	if state.TimezoneFile {
+		return os.WriteFile(timezoneFile, state.TimezoneValue, 0644)
+	}
+	return nil
+}
+
+func getTimezoneStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-time-timezone-%s.json", uid)
+}
+
+func writeTimezoneState(stateFile string, state timezoneState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readTimezoneState(stateFile string) (timezoneState, error) {
+	var state timezoneState
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
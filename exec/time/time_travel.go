@@ -18,18 +18,27 @@ package time
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/category"
 )
 
 const TravelTimeBin = "chaos_timetravel"
 
+const (
+	SyncHwclockAuto   = "auto"
+	SyncHwclockAlways = "always"
+	SyncHwclockNever  = "never"
+)
+
 type TravelTimeActionCommandSpec struct {
 	spec.BaseExpActionCommandSpec
 }
@@ -43,10 +52,33 @@ func NewTravelTimeActionCommandSpec() spec.ExpActionCommandSpec {
 					Name: "offset",
 					Desc: "Travel time offset, for example: -2h3m50s",
 				},
+				&spec.ExpFlag{
+					Name: "to",
+					Desc: "Travel to the absolute time, support RFC3339 and 2006-01-02 15:04:05, for example: 2038-01-19 03:14:07. It is exclusive with offset",
+				},
+				&spec.ExpFlag{
+					Name: "timezone",
+					Desc: "The timezone of the to flag which has no zone offset, for example: Asia/Shanghai, default is the local timezone",
+				},
 				&spec.ExpFlag{
 					Name: "disableNtp",
 					Desc: "Whether to disable Network Time Protocol to synchronize time (default: true, set to false if NTP is not supported)",
 				},
+				&spec.ExpFlag{
+					Name:    "ntp-block-mode",
+					Desc:    "The way to disable NTP, support auto, timedatectl, service (stop chronyd, ntpd and so on) and firewall (drop udp/123 egress). The auto mode chooses it by the active NTP daemon",
+					Default: NtpBlockModeAuto,
+				},
+				&spec.ExpFlag{
+					Name:    "sync-hwclock",
+					Desc:    "Sync the hardware clock, support auto, always and never. The auto mode syncs the system time from it when destroyed and ignores the failure, the always mode writes the travelled time into it to survive a reboot and fails if it is not available",
+					Default: SyncHwclockAuto,
+				},
+				&spec.ExpFlag{
+					Name:   "precise-restore",
+					Desc:   "Restore the time computed by the original time and the elapsed monotonic time when destroyed, it is used when NTP is not available too",
+					NoArgs: true,
+				},
 			},
 			ActionExecutor: &TravelTimeExecutor{},
 			ActionExample: `
@@ -58,6 +90,18 @@ blade create time travel --offset 5m30s --disableNtp false
 
 # Time travel backward 2 hours and 30 minutes
 blade create time travel --offset -2h30m
+
+# Time travel and restore the exact original time when destroyed, without relying on NTP
+blade create time travel --offset 1h --precise-restore
+
+# Time travel to the absolute time in UTC
+blade create time travel --to "2038-01-19 03:14:07" --timezone UTC
+
+# Time travel and write the travelled time into the hardware clock, so that it survives a reboot
+blade create time travel --offset 24h --sync-hwclock always
+
+# Time travel on the host running chronyd, which is stopped during the experiment
+blade create time travel --offset 1h --ntp-block-mode service
 `,
 			ActionPrograms:   []string{TravelTimeBin},
 			ActionCategories: []string{category.SystemTime},
@@ -92,6 +136,28 @@ type TravelTimeExecutor struct {
 	channel spec.Channel
 }
 
+// travelState is the time and the monotonic time before the travel, which is used to restore the time
+type travelState struct {
+	Offset       string   `json:"offset"`
+	To           string   `json:"to,omitempty"`
+	OriginalTime int64    `json:"originalTime"`
+	BootTime     int64    `json:"bootTime"`
+	NtpBlockMode string   `json:"ntpBlockMode"`
+	NtpServices  []string `json:"ntpServices,omitempty"`
+	NtpFirewall  bool     `json:"ntpFirewall,omitempty"`
+	NtpFirewall6 bool     `json:"ntpFirewall6,omitempty"`
+	// HwclockSynced is true if the travelled time is written into the hardware clock
+	HwclockSynced bool `json:"hwclockSynced,omitempty"`
+}
+
+// travelResult is the response of the time travel, which shows how NTP is blocked
+type travelResult struct {
+	Uid          string   `json:"uid"`
+	Time         string   `json:"time"`
+	NtpBlockMode string   `json:"ntpBlockMode"`
+	NtpServices  []string `json:"ntpServices,omitempty"`
+}
+
 func (tte *TravelTimeExecutor) Name() string {
 	return "travel"
 }
@@ -109,70 +175,213 @@ func (tte *TravelTimeExecutor) Exec(uid string, ctx context.Context, model *spec
 
 	var disableNtp bool
 	timeOffsetStr := model.ActionFlags["offset"]
+	toStr := model.ActionFlags["to"]
 	disableNtpStr := model.ActionFlags["disableNtp"]
 
-	if timeOffsetStr == "" {
-		log.Errorf(ctx, "offset is nil")
-		return spec.ResponseFailWithFlags(spec.ParameterLess, "offset")
-	}
 	disableNtp = disableNtpStr == "true" || disableNtpStr == ""
+	preciseRestore := model.ActionFlags["precise-restore"] == "true"
+	syncHwclock := model.ActionFlags["sync-hwclock"]
+	switch syncHwclock {
+	case "":
+		syncHwclock = SyncHwclockAuto
+	case SyncHwclockAuto, SyncHwclockAlways, SyncHwclockNever:
+	default:
+		log.Errorf(ctx, "`%s`: sync-hwclock is illegal", syncHwclock)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "sync-hwclock", syncHwclock, "only support auto, always and never")
+	}
 
 	if _, ok := spec.IsDestroy(ctx); ok {
-		return tte.stop(ctx, timedatectlAvailable)
+		return releaseClock(ctx, uid, tte.stop(ctx, uid, timedatectlAvailable, preciseRestore, syncHwclock))
 	}
 
-	return tte.start(ctx, timeOffsetStr, disableNtp, timedatectlAvailable)
+	if timeOffsetStr == "" && toStr == "" {
+		log.Errorf(ctx, "offset and to are nil")
+		return spec.ResponseFailWithFlags(spec.ParameterLess, "offset|to")
+	}
+	if timeOffsetStr != "" && toStr != "" {
+		log.Errorf(ctx, "offset and to are exclusive")
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "to", toStr, "it is exclusive with offset")
+	}
+	var offset time.Duration
+	var to time.Time
+	var err error
+	if timeOffsetStr != "" {
+		offset, err = time.ParseDuration(timeOffsetStr)
+		if err != nil {
+			log.Errorf(ctx, "offset is invalid")
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "offset", timeOffsetStr, err)
+		}
+	} else {
+		to, err = parseTargetTime(toStr, model.ActionFlags["timezone"])
+		if err != nil {
+			log.Errorf(ctx, "`%s`: to is invalid, %v", toStr, err)
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "to", toStr, err)
+		}
+	}
+
+	ntpBlockMode := model.ActionFlags["ntp-block-mode"]
+	if !disableNtp {
+		ntpBlockMode = NtpBlockModeNone
+	} else if ntpBlockMode == "" || ntpBlockMode == NtpBlockModeAuto {
+		ntpBlockMode = tte.detectNtpBlockMode(ctx, timedatectlAvailable)
+	}
+	log.Infof(ctx, "ntp block mode: %s", ntpBlockMode)
+
+	return claimClock(ctx, tte.channel, uid, model, func() *spec.Response {
+		return tte.start(ctx, uid, timeOffsetStr, offset, to, ntpBlockMode, timedatectlAvailable, syncHwclock)
+	})
 }
 
 func (tte *TravelTimeExecutor) SetChannel(channel spec.Channel) {
 	tte.channel = channel
 }
 
-func (tte *TravelTimeExecutor) stop(ctx context.Context, timedatectlAvailable bool) *spec.Response {
-	// Try to re-enable NTP if timedatectl is available
-	if timedatectlAvailable {
-		response := tte.channel.Run(ctx, "timedatectl", `set-ntp true`)
-		if !response.Success {
-			// Check if the error is due to NTP not being supported
-			if strings.Contains(response.Err, "NTP not supported") {
-				log.Warnf(ctx, "NTP is not supported on this system, skipping NTP re-enable")
-			} else {
-				// For other errors, still return the error
+// Status checks the state file of the time travel
+func (tte *TravelTimeExecutor) Status(ctx context.Context, uid string, model *spec.ExpModel, state *exec.ExperimentState) *exec.StatusReport {
+	return stateFileStatus(uid, model, state, getTravelStateFile(uid))
+}
+
+func (tte *TravelTimeExecutor) stop(ctx context.Context, uid string, timedatectlAvailable, preciseRestore bool, syncHwclock string) *spec.Response {
+	stateFile := getTravelStateFile(uid)
+	state, err := readTravelState(stateFile)
+	stateFound := err == nil
+	if !stateFound {
+		// the experiment created by the old version disables NTP by timedatectl
+		log.Warnf(ctx, "read the time travel state failed, %v", err)
+		state.NtpBlockMode = NtpBlockModeTimedatectl
+	}
+
+	// Undo the NTP block, and restore the exact original time if NTP cannot re-sync it
+	// the absolute jump may be too large for NTP to step back, so it is always restored precisely,
+	// and the hardware clock holding the travelled time cannot be used to restore the system time
+	ntpAvailable, ntpResponse := tte.unblockNtp(ctx, uid, state, timedatectlAvailable)
+	if (!ntpAvailable || preciseRestore || state.To != "" || state.HwclockSynced) && stateFound {
+		if response := tte.restoreOriginalTime(ctx, state); response != nil {
+			return response
+		}
+		if state.HwclockSynced {
+			if syncHwclock == SyncHwclockNever {
+				syncHwclock = SyncHwclockAuto
+			}
+			if response := tte.syncHwclock(ctx, syncHwclock, "--systohc"); response != nil {
 				return response
 			}
 		}
+		os.Remove(stateFile)
+		return spec.ReturnSuccess(uid)
+	}
+	if ntpResponse != nil {
+		return ntpResponse
+	}
+	os.Remove(stateFile)
+
+	// Sync system time from hardware clock
+	if response := tte.syncHwclock(ctx, syncHwclock, "--hctosys"); response != nil {
+		return response
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+// syncHwclock runs hwclock by the mode, the failure is a warning in the auto mode because many VMs and containers
+// have no RTC or a read-only one
+func (tte *TravelTimeExecutor) syncHwclock(ctx context.Context, mode, direction string) *spec.Response {
+	if mode == SyncHwclockNever {
+		return nil
+	}
+	if !tte.channel.IsCommandAvailable(ctx, "hwclock") {
+		if mode == SyncHwclockAlways {
+			log.Errorf(ctx, "hwclock is not available on this system")
+			return exec.Fail(exec.UnsupportedPlatform, "hwclock", "hwclock "+direction, "hwclock is not available on this system")
+		}
+		log.Warnf(ctx, "hwclock is not available on this system, skipping hwclock %s", direction)
+		return nil
+	}
+	response := tte.channel.Run(ctx, "hwclock", direction)
+	if !response.Success {
+		if mode == SyncHwclockAlways {
+			return response
+		}
+		log.Warnf(ctx, "hwclock %s failed, the hardware clock may be absent or read-only, %s", direction, response.Err)
 	}
+	return nil
+}
 
-	// Sync hardware clock with system time
-	return tte.channel.Run(ctx, "hwclock", `--hctosys`)
+// restoreOriginalTime sets the time to the original time plus the elapsed monotonic time
+func (tte *TravelTimeExecutor) restoreOriginalTime(ctx context.Context, state travelState) *spec.Response {
+	bootTime, err := getBootTime()
+	if err != nil {
+		log.Errorf(ctx, "get the monotonic time failed, %v", err)
+		return exec.FailWithFlags(exec.CommandFailed, "clock", "getBootTime", err)
+	}
+	elapsed := bootTime - time.Duration(state.BootTime)
+	originalTime := time.Unix(0, state.OriginalTime).Add(elapsed)
+	log.Infof(ctx, "restore the time to %s, the elapsed time is %s", originalTime, elapsed)
+	if response := tte.setSystemTime(ctx, originalTime); !response.Success {
+		return response
+	}
+	return nil
 }
 
-func (tte *TravelTimeExecutor) start(ctx context.Context, timeOffsetStr string, disableNtp bool, timedatectlAvailable bool) *spec.Response {
-	duration, err := time.ParseDuration(timeOffsetStr)
+// start travels to the absolute time if the to is not zero, otherwise by the offset
+func (tte *TravelTimeExecutor) start(ctx context.Context, uid, timeOffsetStr string, offset time.Duration, to time.Time,
+	ntpBlockMode string, timedatectlAvailable bool, syncHwclock string) *spec.Response {
+	// Record the original time and the monotonic start point for the precise restore
+	stateFile := getTravelStateFile(uid)
+	if _, err := os.Stat(stateFile); err == nil {
+		log.Errorf(ctx, "`%s`: the time travel state file exists", stateFile)
+		return spec.ResponseFailWithFlags(spec.BackfileExists, stateFile)
+	}
+	bootTime, err := getBootTime()
 	if err != nil {
-		log.Errorf(ctx, "offset is invalid")
-		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "offset", timeOffsetStr, err)
+		log.Errorf(ctx, "get the monotonic time failed, %v", err)
+		return exec.FailWithFlags(exec.CommandFailed, "clock", "getBootTime", err)
 	}
+	now := time.Now()
+	state := travelState{Offset: timeOffsetStr, OriginalTime: now.UnixNano(), BootTime: int64(bootTime)}
 
 	// Calculate target time
-	targetTime := time.Now().Add(duration)
-
-	// Try to disable NTP if requested and timedatectl is available
-	if disableNtp && timedatectlAvailable {
-		response := tte.channel.Run(ctx, "timedatectl", `set-ntp false`)
-		if !response.Success {
-			// Check if the error is due to NTP not being supported
-			if strings.Contains(response.Err, "NTP not supported") {
-				log.Warnf(ctx, "NTP is not supported on this system, continuing without disabling NTP")
-			} else {
-				// For other errors, still return the error
-				return response
-			}
-		}
+	targetTime := now.Add(offset)
+	if !to.IsZero() {
+		targetTime = to
+		state.To = to.Format(time.RFC3339Nano)
+	}
+
+	// Block NTP, what is done is recorded so that the stop undoes exactly it
+	if response := tte.blockNtp(ctx, uid, ntpBlockMode, timedatectlAvailable, &state); response != nil {
+		return response
+	}
+	if err := writeTravelState(stateFile, state); err != nil {
+		log.Errorf(ctx, "write the time travel state failed, %v", err)
+		tte.unblockNtp(ctx, uid, state, timedatectlAvailable)
+		return exec.FailWithFlags(exec.StateRecordFailed, "clock", "writeTravelState", err)
 	}
 
 	// Set system time using multiple format attempts for better compatibility
-	return tte.setSystemTime(ctx, targetTime)
+	response := tte.setSystemTime(ctx, targetTime)
+	if !response.Success {
+		tte.unblockNtp(ctx, uid, state, timedatectlAvailable)
+		os.Remove(stateFile)
+		return response
+	}
+
+	// Write the travelled time into the hardware clock only if it is required explicitly
+	if syncHwclock == SyncHwclockAlways {
+		if hwResponse := tte.syncHwclock(ctx, syncHwclock, "--systohc"); hwResponse != nil {
+			tte.restoreOriginalTime(ctx, state)
+			tte.unblockNtp(ctx, uid, state, timedatectlAvailable)
+			os.Remove(stateFile)
+			return hwResponse
+		}
+		state.HwclockSynced = true
+		if err := writeTravelState(stateFile, state); err != nil {
+			log.Errorf(ctx, "write the time travel state failed, %v", err)
+		}
+	}
+	result := travelResult{Uid: uid, NtpBlockMode: state.NtpBlockMode, NtpServices: state.NtpServices}
+	if output, ok := response.Result.(string); ok {
+		result.Time = strings.TrimSpace(output)
+	}
+	return spec.ReturnSuccess(result)
 }
 
 // setSystemTime attempts to set system time using multiple methods for better compatibility
@@ -185,6 +394,9 @@ func (tte *TravelTimeExecutor) setSystemTime(ctx context.Context, targetTime tim
 		"Jan 2 15:04:05 2006", // Unix date format
 	}
 
+	// date interprets the time in the local timezone
+	targetTime = targetTime.Local()
+
 	var lastError error
 	for _, format := range timeFormats {
 		timeStr := targetTime.Format(format)
@@ -212,6 +424,53 @@ func (tte *TravelTimeExecutor) setSystemTime(ctx context.Context, targetTime tim
 		log.Warnf(ctx, "timedatectl failed: %s", response.Err)
 	}
 
-	return spec.ResponseFailWithFlags(spec.OsCmdExecFailed,
+	return exec.Fail(exec.SettingApplyFailed, "clock", "set time",
 		fmt.Sprintf("Failed to set system time with all available methods. Last error: %v", lastError))
 }
+
+func getTravelStateFile(uid string) string {
+	return fmt.Sprintf("/tmp/chaos-time-travel-%s.json", uid)
+}
+
+func writeTravelState(stateFile string, state travelState) error {
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, bytes, 0600)
+}
+
+func readTravelState(stateFile string) (travelState, error) {
+	var state travelState
+	bytes, err := os.ReadFile(stateFile)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(bytes, &state)
+	return state, err
+}
+
+// the layouts of the to flag, the ones without zone offset are interpreted in the timezone flag
+var targetTimeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+}
+
+// parseTargetTime parses the absolute time in the timezone, which is the local timezone if it is empty
+func parseTargetTime(value, timezone string) (time.Time, error) {
+	location := time.Local
+	if timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return time.Time{}, err
+		}
+	}
+	for _, layout := range targetTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("it must be RFC3339 or 2006-01-02 15:04:05")
+}
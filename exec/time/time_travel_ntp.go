@@ -0,0 +1,181 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package time
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+)
+
+const (
+	NtpBlockModeAuto        = "auto"
+	NtpBlockModeTimedatectl = "timedatectl"
+	NtpBlockModeService     = "service"
+	NtpBlockModeFirewall    = "firewall"
+	NtpBlockModeNone        = "none"
+)
+
+// the NTP daemons which are stopped by the service mode, systemd-timesyncd is controlled by timedatectl in the auto mode
+var ntpServices = []string{"chronyd", "chrony", "ntpd", "ntp", "ntpsec", "openntpd"}
+
+const timesyncdService = "systemd-timesyncd"
+
+// detectNtpBlockMode chooses the way to block NTP by the active daemon
+func (tte *TravelTimeExecutor) detectNtpBlockMode(ctx context.Context, timedatectlAvailable bool) string {
+	if len(tte.getActiveNtpServices(ctx, ntpServices)) > 0 {
+		return NtpBlockModeService
+	}
+	if timedatectlAvailable && len(tte.getActiveNtpServices(ctx, []string{timesyncdService})) > 0 {
+		return NtpBlockModeTimedatectl
+	}
+	if tte.channel.IsCommandAvailable(ctx, "iptables") {
+		return NtpBlockModeFirewall
+	}
+	if timedatectlAvailable {
+		return NtpBlockModeTimedatectl
+	}
+	return NtpBlockModeNone
+}
+
+func (tte *TravelTimeExecutor) getActiveNtpServices(ctx context.Context, services []string) []string {
+	active := make([]string, 0)
+	if !tte.channel.IsCommandAvailable(ctx, "systemctl") {
+		return active
+	}
+	for _, service := range services {
+		if response := tte.channel.Run(ctx, "systemctl", fmt.Sprintf("is-active --quiet %s", service)); response.Success {
+			active = append(active, service)
+		}
+	}
+	return active
+}
+
+// blockNtp blocks the NTP synchronization by the mode, what it did is recorded in the state
+func (tte *TravelTimeExecutor) blockNtp(ctx context.Context, uid, mode string, timedatectlAvailable bool, state *travelState) *spec.Response {
+	state.NtpBlockMode = mode
+	switch mode {
+	case NtpBlockModeTimedatectl:
+		if !timedatectlAvailable {
+			log.Errorf(ctx, "timedatectl is not available")
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, "ntp-block-mode", mode, "timedatectl is not available")
+		}
+		response := tte.channel.Run(ctx, "timedatectl", `set-ntp false`)
+		if !response.Success {
+			// Check if the error is due to NTP not being supported
+			if strings.Contains(response.Err, "NTP not supported") {
+				log.Warnf(ctx, "NTP is not supported on this system, continuing without disabling NTP")
+			} else {
+				// For other errors, still return the error
+				return response
+			}
+		}
+	case NtpBlockModeService:
+		if !tte.channel.IsCommandAvailable(ctx, "systemctl") {
+			log.Errorf(ctx, "%s", spec.CommandSystemctlNotFound.Msg)
+			return spec.ResponseFailWithFlags(spec.CommandSystemctlNotFound)
+		}
+		services := tte.getActiveNtpServices(ctx, append(append([]string{}, ntpServices...), timesyncdService))
+		if len(services) == 0 {
+			log.Warnf(ctx, "no active NTP service found, continuing without stopping NTP service")
+		}
+		for _, service := range services {
+			if response := tte.channel.Run(ctx, "systemctl", fmt.Sprintf("stop %s", service)); !response.Success {
+				tte.unblockNtp(ctx, uid, *state, timedatectlAvailable)
+				return response
+			}
+			state.NtpServices = append(state.NtpServices, service)
+		}
+	case NtpBlockModeFirewall:
+		if !tte.channel.IsCommandAvailable(ctx, "iptables") {
+			log.Errorf(ctx, "%s", spec.CommandIptablesNotFound.Msg)
+			return spec.ResponseFailWithFlags(spec.CommandIptablesNotFound)
+		}
+		if response := exec.RunIptables(ctx, tte.channel, fmt.Sprintf("-I OUTPUT %s", getNtpDropRule(uid))); !response.Success {
+			return response
+		}
+		state.NtpFirewall = true
+		if tte.channel.IsCommandAvailable(ctx, "ip6tables") {
+			if response := tte.channel.Run(ctx, "ip6tables", fmt.Sprintf("-I OUTPUT %s", getNtpDropRule(uid))); response.Success {
+				state.NtpFirewall6 = true
+			} else {
+				log.Warnf(ctx, "block NTP over ipv6 failed, %s", response.Err)
+			}
+		}
+	case NtpBlockModeNone:
+	default:
+		log.Errorf(ctx, "`%s`: ntp-block-mode is illegal", mode)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "ntp-block-mode", mode,
+			"only support auto, timedatectl, service, firewall and none")
+	}
+	log.Infof(ctx, "NTP is blocked by %s mode, services: %v", mode, state.NtpServices)
+	return nil
+}
+
+// unblockNtp undoes what blockNtp did, it returns whether NTP is able to re-sync the time
+func (tte *TravelTimeExecutor) unblockNtp(ctx context.Context, uid string, state travelState, timedatectlAvailable bool) (bool, *spec.Response) {
+	switch state.NtpBlockMode {
+	case NtpBlockModeTimedatectl:
+		if !timedatectlAvailable {
+			return false, nil
+		}
+		response := tte.channel.Run(ctx, "timedatectl", `set-ntp true`)
+		if !response.Success {
+			// Check if the error is due to NTP not being supported
+			if strings.Contains(response.Err, "NTP not supported") {
+				log.Warnf(ctx, "NTP is not supported on this system, skipping NTP re-enable")
+				return false, nil
+			}
+			// For other errors, the error is returned if the time cannot be restored precisely
+			log.Warnf(ctx, "re-enable NTP failed, %s", response.Err)
+			return false, response
+		}
+		return true, nil
+	case NtpBlockModeService:
+		var failed *spec.Response
+		for _, service := range state.NtpServices {
+			if response := tte.channel.Run(ctx, "systemctl", fmt.Sprintf("start %s", service)); !response.Success {
+				log.Warnf(ctx, "start the NTP service %s failed, %s", service, response.Err)
+				failed = response
+			}
+		}
+		return failed == nil && len(state.NtpServices) > 0, failed
+	case NtpBlockModeFirewall:
+		var failed *spec.Response
+		if state.NtpFirewall {
+			if response := exec.RunIptables(ctx, tte.channel, fmt.Sprintf("-D OUTPUT %s", getNtpDropRule(uid))); !response.Success {
+				failed = response
+			}
+		}
+		if state.NtpFirewall6 {
+			if response := tte.channel.Run(ctx, "ip6tables", fmt.Sprintf("-D OUTPUT %s", getNtpDropRule(uid))); !response.Success {
+				failed = response
+			}
+		}
+		return failed == nil, failed
+	}
+	return false, nil
+}
+
+func getNtpDropRule(uid string) string {
+	return fmt.Sprintf(`-p udp --dport 123 -m comment --comment "chaosblade-%s" -j DROP`, uid)
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package validation checks the flag values of the experiments before any system command runs, the
+// functions return the spec.ParameterIllegal response which contains the flag name, the value and the
+// reason, so the users don't need to understand the error of iptables, tc or dd.
+package validation
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+const (
+	KB int64 = 1024
+	MB       = 1024 * KB
+	GB       = 1024 * MB
+	TB       = 1024 * GB
+)
+
+// ValidateIPOrCIDRList checks the comma separated ipv4 or ipv6 addresses and cidrs, such as
+// 10.0.0.1,192.168.0.0/24, the blank items caused by the spaces and the trailing commas are ignored.
+// It returns the trimmed items.
+func ValidateIPOrCIDRList(flagName, value string) ([]string, *spec.Response) {
+	return validateIPOrCIDRList(flagName, value, false)
+}
+
+// ValidateIPv4OrCIDRList is the same as ValidateIPOrCIDRList, but only the ipv4 is accepted, it's used by
+// the experiments based on iptables and tc u32 filters which don't support the ipv6.
+func ValidateIPv4OrCIDRList(flagName, value string) ([]string, *spec.Response) {
+	return validateIPOrCIDRList(flagName, value, true)
+}
+
+func validateIPOrCIDRList(flagName, value string, ipv4Only bool) ([]string, *spec.Response) {
+	items := splitList(value)
+	if len(items) == 0 {
+		return nil, illegal(flagName, value, "it must contain at least one ip or cidr")
+	}
+	for _, item := range items {
+		var addr netip.Addr
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, illegal(flagName, value, fmt.Sprintf("%s is not an ip or cidr", item))
+			}
+			addr = prefix.Addr()
+		} else {
+			var err error
+			if addr, err = netip.ParseAddr(item); err != nil {
+				return nil, illegal(flagName, value, fmt.Sprintf("%s is not an ip or cidr", item))
+			}
+		}
+		if addr.Zone() != "" {
+			return nil, illegal(flagName, value, fmt.Sprintf("the zone of %s is not supported", item))
+		}
+		if ipv4Only && !addr.Unmap().Is4() {
+			return nil, illegal(flagName, value, fmt.Sprintf("%s is not an ipv4 address, the ipv6 is not supported", item))
+		}
+	}
+	return items, nil
+}
+
+// ValidatePortList checks the ports separated by commas, the range is connected by - or the : of
+// iptables, such as 80,8000-8080. It returns the sorted ranges without the overlaps, each range is
+// [start, end].
+func ValidatePortList(flagName, value string) ([][]int, *spec.Response) {
+	items := splitList(value)
+	if len(items) == 0 {
+		return nil, illegal(flagName, value, "it must contain at least one port")
+	}
+	ranges := make([][]int, 0, len(items))
+	for _, item := range items {
+		bounds := strings.Split(strings.ReplaceAll(item, ":", "-"), "-")
+		if len(bounds) > 2 {
+			return nil, illegal(flagName, value, fmt.Sprintf("%s is not a port or a port range, such as 8000-8080", item))
+		}
+		portRange := make([]int, 0, 2)
+		for _, bound := range bounds {
+			port, err := strconv.Atoi(strings.TrimSpace(bound))
+			if err != nil || port < 1 || port > math.MaxUint16 {
+				return nil, illegal(flagName, value, fmt.Sprintf("%s is not a port in 1-65535", item))
+			}
+			portRange = append(portRange, port)
+		}
+		if len(portRange) == 1 {
+			portRange = append(portRange, portRange[0])
+		}
+		if portRange[0] > portRange[1] {
+			return nil, illegal(flagName, value, fmt.Sprintf("the start of %s is greater than the end", item))
+		}
+		ranges = append(ranges, portRange)
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+	merged := [][]int{ranges[0]}
+	for _, portRange := range ranges[1:] {
+		last := merged[len(merged)-1]
+		if portRange[0] <= last[1]+1 {
+			if portRange[1] > last[1] {
+				last[1] = portRange[1]
+			}
+			continue
+		}
+		merged = append(merged, portRange)
+	}
+	return merged, nil
+}
+
+// JoinPortRanges formats the ranges returned by ValidatePortList, the separator of the range is - for tc
+// and netsh, and : for iptables.
+func JoinPortRanges(ranges [][]int, rangeSeparator string) string {
+	items := make([]string, 0, len(ranges))
+	for _, portRange := range ranges {
+		if portRange[0] == portRange[1] {
+			items = append(items, strconv.Itoa(portRange[0]))
+		} else {
+			items = append(items, fmt.Sprintf("%d%s%d", portRange[0], rangeSeparator, portRange[1]))
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// ValidatePercent checks the integer percent in 0-100, the % suffix is allowed, such as 60%
+func ValidatePercent(flagName, value string) (int, *spec.Response) {
+	percent, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "%"))
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, illegal(flagName, value, "it must be an integer in 0-100")
+	}
+	return percent, nil
+}
+
+// ValidateDecimalPercent checks the percent in 0-100 which may be a decimal, such as 0.5 for tc netem
+func ValidateDecimalPercent(flagName, value string) (float64, *spec.Response) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || math.IsNaN(percent) || percent < 0 || percent > 100 {
+		return 0, illegal(flagName, value, "it must be a number in 0-100")
+	}
+	return percent, nil
+}
+
+// ValidatePositiveDecimal checks the number in (0, max] which may be a decimal, such as 0.5 cores
+func ValidatePositiveDecimal(flagName, value string, max float64) (float64, *spec.Response) {
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(number) || number <= 0 || number > max {
+		return 0, illegal(flagName, value, fmt.Sprintf("it must be a number greater than 0 and not greater than %v", max))
+	}
+	return number, nil
+}
+
+// ValidateInt checks the integer in [min, max]
+func ValidateInt(flagName, value string, min, max int) (int, *spec.Response) {
+	number, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || number < min || number > max {
+		if max == math.MaxInt {
+			return 0, illegal(flagName, value, fmt.Sprintf("it must be an integer not less than %d", min))
+		}
+		return 0, illegal(flagName, value, fmt.Sprintf("it must be an integer in %d-%d", min, max))
+	}
+	return number, nil
+}
+
+// ValidateSizeBytes checks the size and returns it in bytes. The size without the unit is in the unit
+// of the flag, such as MB for disk fill, the B suffix means bytes, and the K, M, G and T units, with
+// the optional B or iB suffix, are the powers of 1024, such as 512M or 1GiB. The size must not be
+// less than min bytes.
+func ValidateSizeBytes(flagName, value string, unit, min int64) (int64, *spec.Response) {
+	size := strings.ToUpper(strings.TrimSpace(value))
+	multiple := unit
+	if trimmed := strings.TrimSuffix(size, "B"); trimmed != size {
+		size, multiple = trimmed, 1
+	}
+	binary := false
+	if trimmed := strings.TrimSuffix(size, "I"); trimmed != size {
+		size, binary = trimmed, true
+	}
+	if n := len(size); n > 0 {
+		if sizeUnit, ok := sizeUnits[size[n-1]]; ok {
+			size, multiple = strings.TrimSpace(size[:n-1]), sizeUnit
+		} else if binary {
+			size = ""
+		}
+	}
+	number, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || number < 0 || number > math.MaxInt64/multiple {
+		return 0, illegal(flagName, value, "it must be a non-negative integer with the optional unit K, M, G or T")
+	}
+	if bytes := number * multiple; bytes >= min {
+		return bytes, nil
+	}
+	return 0, illegal(flagName, value, fmt.Sprintf("it must not be less than %s", formatSize(min)))
+}
+
+var sizeUnits = map[byte]int64{'K': KB, 'M': MB, 'G': GB, 'T': TB}
+
+// ValidateDuration checks the duration which is the seconds, such as 60, or the Go duration, such as 5m,
+// and it must not be less than min.
+func ValidateDuration(flagName, value string, min time.Duration) (time.Duration, *spec.Response) {
+	value = strings.TrimSpace(value)
+	var duration time.Duration
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds > int64(math.MaxInt64/time.Second) || seconds < 0 {
+			return 0, illegal(flagName, value, "it must be a non-negative number of seconds or a duration, such as 5m")
+		}
+		duration = time.Duration(seconds) * time.Second
+	} else if duration, err = time.ParseDuration(value); err != nil || duration < 0 {
+		return 0, illegal(flagName, value, "it must be a non-negative number of seconds or a duration, such as 5m")
+	}
+	if duration < min {
+		return 0, illegal(flagName, value, fmt.Sprintf("it must not be less than %v", min))
+	}
+	return duration, nil
+}
+
+// splitList splits the comma separated value and drops the blank items
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func formatSize(bytes int64) string {
+	for _, unit := range []struct {
+		size int64
+		name string
+	}{{TB, "T"}, {GB, "G"}, {MB, "M"}, {KB, "K"}} {
+		if bytes >= unit.size && bytes%unit.size == 0 {
+			return fmt.Sprintf("%d%s", bytes/unit.size, unit.name)
+		}
+	}
+	return fmt.Sprintf("%d bytes", bytes)
+}
+
+func illegal(flagName, value, reason string) *spec.Response {
+	return spec.ResponseFailWithFlags(spec.ParameterIllegal, flagName, value, reason)
+}
@@ -0,0 +1,210 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package validation
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidateIPOrCIDRList(t *testing.T) {
+	tests := []struct {
+		value    string
+		ipv4Only bool
+		expect   []string
+		ok       bool
+	}{
+		{"10.0.0.1", true, []string{"10.0.0.1"}, true},
+		{" 10.0.0.1 , 192.168.0.0/24 ", true, []string{"10.0.0.1", "192.168.0.0/24"}, true},
+		{"10.0.0.1,", true, []string{"10.0.0.1"}, true},
+		{"10.0.0.1,,10.0.0.2", true, []string{"10.0.0.1", "10.0.0.2"}, true},
+		{"::ffff:10.0.0.1", true, []string{"::ffff:10.0.0.1"}, true},
+		{"fd00::1,fd00::/8", false, []string{"fd00::1", "fd00::/8"}, true},
+		{"fd00::1", true, nil, false},
+		{"fe80::1%eth0", false, nil, false},
+		{"10.0.0.256", false, nil, false},
+		{"10.0.0.0/33", false, nil, false},
+		{"10.0.0.1 10.0.0.2", false, nil, false},
+		{"localhost", false, nil, false},
+		{" , ", false, nil, false},
+		{"", false, nil, false},
+	}
+	for _, tt := range tests {
+		validate := ValidateIPOrCIDRList
+		if tt.ipv4Only {
+			validate = ValidateIPv4OrCIDRList
+		}
+		ips, response := validate("destination-ip", tt.value)
+		if (response == nil) != tt.ok {
+			t.Errorf("validate %q, expect ok: %v, response: %v", tt.value, tt.ok, response)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(ips, tt.expect) {
+			t.Errorf("validate %q, expect: %v, actual: %v", tt.value, tt.expect, ips)
+		}
+	}
+}
+
+func TestValidatePortList(t *testing.T) {
+	tests := []struct {
+		value  string
+		expect [][]int
+		ok     bool
+	}{
+		{"80", [][]int{{80, 80}}, true},
+		{"80,443,", [][]int{{80, 80}, {443, 443}}, true},
+		{" 8000 - 8080 , 80", [][]int{{80, 80}, {8000, 8080}}, true},
+		{"8000-8080,8050-8090,8091", [][]int{{8000, 8091}}, true},
+		{"1-65535", [][]int{{1, 65535}}, true},
+		{"0", nil, false},
+		{"65536", nil, false},
+		{"8080-8000", nil, false},
+		{"80-", nil, false},
+		{"80:90", [][]int{{80, 90}}, true},
+		{"1-2-3", nil, false},
+		{"http", nil, false},
+		{",", nil, false},
+	}
+	for _, tt := range tests {
+		ranges, response := ValidatePortList("remote-port", tt.value)
+		if (response == nil) != tt.ok {
+			t.Errorf("validate %q, expect ok: %v, response: %v", tt.value, tt.ok, response)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(ranges, tt.expect) {
+			t.Errorf("validate %q, expect: %v, actual: %v", tt.value, tt.expect, ranges)
+		}
+	}
+	if actual := JoinPortRanges([][]int{{80, 80}, {8000, 8080}}, ":"); actual != "80,8000:8080" {
+		t.Errorf("unexpected joined ports: %s", actual)
+	}
+}
+
+func TestValidatePercent(t *testing.T) {
+	tests := []struct {
+		value  string
+		expect int
+		ok     bool
+	}{
+		{"60", 60, true},
+		{" 60% ", 60, true},
+		{"0", 0, true},
+		{"100", 100, true},
+		{"101", 0, false},
+		{"-1", 0, false},
+		{"50.5", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		percent, response := ValidatePercent("cpu-percent", tt.value)
+		if (response == nil) != tt.ok || percent != tt.expect {
+			t.Errorf("validate %q, expect: %d %v, actual: %d %v", tt.value, tt.expect, tt.ok, percent, response)
+		}
+	}
+	for value, ok := range map[string]bool{"0.5": true, "50%": true, "100.1": false, "NaN": false, "-0.1": false} {
+		if _, response := ValidateDecimalPercent("percent", value); (response == nil) != ok {
+			t.Errorf("validate %q, expect ok: %v, response: %v", value, ok, response)
+		}
+	}
+}
+
+func TestValidatePositiveDecimal(t *testing.T) {
+	for value, ok := range map[string]bool{"0.5": true, " 8 ": true, "8.5": false, "0": false, "-1": false, "NaN": false, "1c": false} {
+		if _, response := ValidatePositiveDecimal("cpu-cores", value, 8); (response == nil) != ok {
+			t.Errorf("validate %q, expect ok: %v, response: %v", value, ok, response)
+		}
+	}
+}
+
+func TestValidateInt(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max int
+		expect   int
+		ok       bool
+	}{
+		{"3", 1, math.MaxInt, 3, true},
+		{" 600 ", 0, 600, 600, true},
+		{"601", 0, 600, 0, false},
+		{"0", 1, math.MaxInt, 0, false},
+		{"1e3", 0, math.MaxInt, 0, false},
+	}
+	for _, tt := range tests {
+		number, response := ValidateInt("count", tt.value, tt.min, tt.max)
+		if (response == nil) != tt.ok || number != tt.expect {
+			t.Errorf("validate %q, expect: %d %v, actual: %d %v", tt.value, tt.expect, tt.ok, number, response)
+		}
+	}
+}
+
+func TestValidateSizeBytes(t *testing.T) {
+	tests := []struct {
+		value  string
+		expect int64
+		ok     bool
+	}{
+		{"10", 10 * MB, true},
+		{"1024k", MB, true},
+		{"1G", GB, true},
+		{"1GB", GB, true},
+		{"1 GiB", GB, true},
+		{"2t", 2 * TB, true},
+		{"2097152B", 2 * MB, true},
+		{"0", 0, false},
+		{"1023K", 0, false},
+		{"-1", 0, false},
+		{"1.5G", 0, false},
+		{"1iB", 0, false},
+		{"10X", 0, false},
+		{"9999999999T", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		size, response := ValidateSizeBytes("size", tt.value, MB, MB)
+		if (response == nil) != tt.ok || size != tt.expect {
+			t.Errorf("validate %q, expect: %d %v, actual: %d %v", tt.value, tt.expect, tt.ok, size, response)
+		}
+	}
+	if size, response := ValidateSizeBytes("reserve", "0", MB, 0); response != nil || size != 0 {
+		t.Errorf("validate 0 without the min, actual: %d %v", size, response)
+	}
+}
+
+func TestValidateDuration(t *testing.T) {
+	tests := []struct {
+		value  string
+		expect time.Duration
+		ok     bool
+	}{
+		{"60", time.Minute, true},
+		{" 5m ", 5 * time.Minute, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"500ms", 0, false},
+		{"0", 0, false},
+		{"-1", 0, false},
+		{"5 m", 0, false},
+		{"forever", 0, false},
+	}
+	for _, tt := range tests {
+		duration, response := ValidateDuration("timeout", tt.value, time.Second)
+		if (response == nil) != tt.ok || duration != tt.expect {
+			t.Errorf("validate %q, expect: %v %v, actual: %v %v", tt.value, tt.expect, tt.ok, duration, response)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/model"
+)
+
+// gcReport is the result of chaos_os --gc
+type gcReport struct {
+	Processes []exec.ChaosProcess `json:"processes"`
+	Reaped    []gcReaped          `json:"reaped"`
+	// PrunedStates are the uids of the records of the destroyed experiments which are removed
+	PrunedStates []string `json:"prunedStates"`
+}
+
+type gcReaped struct {
+	exec.ChaosProcess
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// runGC lists the running experiment processes, and destroys the ones whose uid is not in
+// the allow list or which live longer than ttl, for example: chaos_os --gc --ttl 24h. The records of the
+// experiments destroyed longer than state-ttl ago are removed too.
+func runGC(args []string) *spec.Response {
+	cmd := flag.NewFlagSet(os.Args[0]+" --gc", flag.ContinueOnError)
+	cmd.SetOutput(io.Discard)
+	ttlValue := cmd.String("ttl", "", "Destroy the experiments running longer than the ttl, such as 24h")
+	allowValue := cmd.String("allow-uids", "", "Destroy the experiments whose uid is not in the list, separate multiple uids with commas (,)")
+	stateTtlValue := cmd.String("state-ttl", exec.DestroyedStateTTL.String(), "Remove the records of the experiments destroyed longer than the ttl ago")
+	debugValue := cmd.String("debug", "", "debug")
+	if err := cmd.Parse(args); err != nil {
+		return spec.ReturnFail(spec.ParameterIllegal, fmt.Sprintf("invalid parameter, %v", err))
+	}
+	if *debugValue == spec.True {
+		util.Debug = true
+	}
+	util.InitLog(util.Bin)
+	ctx := context.Background()
+
+	var ttl time.Duration
+	if *ttlValue != "" {
+		var err error
+		ttl, err = time.ParseDuration(*ttlValue)
+		if err != nil || ttl <= 0 {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "ttl", *ttlValue, "it must be a positive duration, such as 24h")
+		}
+	}
+	stateTtl, err := time.ParseDuration(*stateTtlValue)
+	if err != nil || stateTtl < 0 {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "state-ttl", *stateTtlValue, "it must be a non-negative duration, such as 24h")
+	}
+	allowUids := make(map[string]bool)
+	for _, uid := range strings.Split(*allowValue, ",") {
+		if uid = strings.TrimSpace(uid); uid != "" {
+			allowUids[uid] = true
+		}
+	}
+
+	processes, err := exec.ListChaosProcesses(ctx, model.GetAllOsBins())
+	if err != nil {
+		log.Errorf(ctx, "list chaos processes failed, %v", err)
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("list chaos processes failed, %v", err))
+	}
+	report := gcReport{Processes: processes, Reaped: make([]gcReaped, 0)}
+	now := time.Now()
+	for _, p := range processes {
+		if !p.IsStale(allowUids, ttl, now) {
+			continue
+		}
+		reaped := gcReaped{ChaosProcess: p, Success: true}
+		if err := reap(ctx, p); err != nil {
+			log.Warnf(ctx, "reap process %d of %s failed, %v", p.Pid, p.Uid, err)
+			reaped.Success = false
+			reaped.Error = err.Error()
+		}
+		report.Reaped = append(report.Reaped, reaped)
+	}
+	// the records of the reaped experiments are destroyed just now, so they are kept until the state-ttl
+	if report.PrunedStates, err = exec.PruneDestroyedStates(ctx, stateTtl, now); err != nil {
+		log.Warnf(ctx, "prune the records of the destroyed experiments failed, %v", err)
+	}
+	return spec.ReturnSuccess(report)
+}
+
+// reap destroys the experiment of the process by its executor so that its state is recovered too,
+// the process is killed directly if the executor cannot be found.
+func reap(ctx context.Context, p exec.ChaosProcess) error {
+	executor := executors[p.Target+p.Action]
+	if executor != nil && p.Uid != "" {
+		actionFlags, err := parseActionFlags(p.Target+p.Action, p.Args[4:], flag.ContinueOnError)
+		if err != nil {
+			return err
+		}
+		// the command line of the watchdog has the uid only, the flags of the create are in the record
+		if isWatchdog(p.Args) {
+			if state, err := loadWatchdog(p.Uid); err == nil {
+				actionFlags = state.Watchdog.Flags
+			}
+		}
+		expModel := &spec.ExpModel{
+			Target:      p.Target,
+			ActionName:  p.Action,
+			ActionFlags: actionFlags,
+		}
+		ctx = context.WithValue(ctx, spec.Uid, p.Uid)
+		ctx = spec.SetDestroyFlag(ctx, p.Uid)
+		cl := channel.NewLocalChannel()
+		executor.SetChannel(cl)
+		if response := executor.Exec(p.Uid, ctx, expModel); !response.Success {
+			return fmt.Errorf("%s", response.Err)
+		}
+		if response := exec.DestroyWatchdogRecord(ctx, cl, p.Uid); response != nil && !response.Success {
+			return fmt.Errorf("%s", response.Err)
+		}
+	}
+	proc, err := os.FindProcess(int(p.Pid))
+	if err != nil {
+		return nil
+	}
+	// the process may have been destroyed by the executor already
+	if err := proc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
@@ -5,13 +5,20 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"runtime"
+	"time"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/channel"
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
 
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/container"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/model"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/validation"
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs"
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
 )
 
 var (
@@ -22,6 +29,7 @@ var (
 )
 
 func init() {
+	exec.ExecutorFactory = model.NewOsExecutor
 	for _, commandSpec := range models {
 		modelMap[commandSpec.Name()] = commandSpec
 		xes := make([]spec.ExpFlag, len(commandSpec.Flags()))
@@ -50,6 +58,10 @@ func init() {
 				}
 			}
 			util.MergeModels()
+			// process kill and stop define the dry-run flag themselves
+			if !hasFlag(flags, model.DryRunFlag.Name) {
+				flags = append(flags, model.DryRunFlag)
+			}
 			modelActionFlags[commandSpec.Name()+modelAction.Name()] = append(
 				append(flags, append(xes, matchers...)...),
 				model.UidFlag,
@@ -59,6 +71,15 @@ func init() {
 				model.NsMntFlag,
 				model.NsNetFlag,
 				model.DebugFlag,
+				model.TimeoutFlag,
+				model.MetricsDirFlag,
+				model.LogDirFlag,
+				model.LogLevelFlag,
+				model.WatchdogFlag,
+				model.AllowOverlapFlag,
+				model.ProtectedPathsFlag,
+				model.ContainerIdFlag,
+				model.ContainerRuntimeFlag,
 			)
 		}
 	}
@@ -66,6 +87,18 @@ func init() {
 
 func main() {
 	args := os.Args
+	if len(args) > 1 && (args[1] == "--gc" || args[1] == "-gc") {
+		exitAndPrint(runGC(args[2:]), 0)
+	}
+	if len(args) > 1 && (args[1] == "--check" || args[1] == "-check") {
+		exitAndPrint(runCheck(args[2:]), 0)
+	}
+	if len(args) > 1 && args[1] == "spec" {
+		if response := runSpec(args[2:]); response != nil {
+			exitAndPrint(response, 0)
+		}
+		os.Exit(0)
+	}
 	if len(args) < 4 {
 		exitAndPrint(spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("invalid parameter, %v", args)), 0)
 	} else {
@@ -79,49 +112,68 @@ func main() {
 			Target:     target,
 			ActionName: action,
 			ActionFlags: func() map[string]string {
-				flagsx := modelActionFlags[target+action]
-
-				flagsValues := make(map[string]*string, len(flagsx))
-
-				cmd := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-
-				for _, f := range flagsx {
-					s := cmd.String(f.Name, f.Default, f.Desc)
-					flagsValues[f.Name] = s
-				}
-
-				if err := cmd.Parse(os.Args[4:]); err != nil {
+				actionFlags, err := parseActionFlags(target+action, os.Args[4:], flag.ExitOnError)
+				if err != nil {
 					exitAndPrint(spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("invalid parameter, %v", err)), 0)
 				}
-
-				actionFlags := make(map[string]string, len(flagsx))
-				for k, v := range flagsValues {
-					actionFlags[k] = *v
-				}
 				return actionFlags
 			}(),
 		}
 
 		ctx := context.Background()
-		if mode != spec.Create && mode != spec.Destroy {
+		if mode != spec.Create && mode != spec.Destroy && mode != exec.StatusKey {
 			exitAndPrint(spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("invalid parameter, %v", args)), 0)
 		}
 
 		uid := expModel.ActionFlags[model.UidFlag.Name]
+		if mode == spec.Create && uid == "" {
+			uid, _ = util.GenerateUid()
+			// the uid is encoded into the command line of the hanging process, which is destroyed by the uid
+			if isProcessHang(target, action, expModel.ActionFlags) && expModel.ActionFlags[model.DryRunFlag.Name] != spec.True {
+				if err := execWithUid(uid); err != nil {
+					exitAndPrint(spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("restart with the uid failed, %v", err)), 0)
+				}
+			}
+		}
+
+		// the watchdog destroys the experiment by the recorded flags of the create, its command line has the uid only
+		var watchdogState *exec.ExperimentState
+		if mode == spec.Create && expModel.ActionFlags[model.WatchdogFlag.Name] == spec.True {
+			state, err := loadWatchdog(uid)
+			if err != nil {
+				exitAndPrint(spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("load the record of the watchdog failed, %v", err)), 0)
+			}
+			watchdogState = state
+			expModel.ActionFlags = state.Watchdog.Flags
+			expModel.ActionFlags[model.UidFlag.Name] = uid
+		}
 
 		ctx = context.WithValue(ctx, spec.Uid, uid)
 		if mode == spec.Destroy {
 			ctx = spec.SetDestroyFlag(ctx, uid)
-		} else {
-			if uid == "" {
-				uid, _ = util.GenerateUid()
-			}
 		}
 
+		if metricsDir := expModel.ActionFlags[model.MetricsDirFlag.Name]; metricsDir != "" {
+			ctx = context.WithValue(ctx, exec.MetricsDirKey, metricsDir)
+		}
+
+		if expModel.ActionFlags[model.AllowOverlapFlag.Name] == spec.True {
+			ctx = exec.WithAllowOverlap(ctx)
+		}
+		if mode == spec.Create {
+			var err error
+			if ctx, err = exec.WithProtectedPaths(ctx, expModel.ActionFlags[model.ProtectedPathsFlag.Name]); err != nil {
+				exitAndPrint(spec.ReturnFail(spec.ParameterIllegal, fmt.Sprintf("invalid parameter, %v", err)), 0)
+			}
+		}
 		if expModel.ActionFlags[model.DebugFlag.Name] == spec.True {
 			util.Debug = true
 		}
 		util.InitLog(util.Bin)
+		logDir := expModel.ActionFlags[model.LogDirFlag.Name]
+		if err := exec.InitExperimentLog(logDir, expModel.ActionFlags[model.LogLevelFlag.Name], uid); err != nil {
+			exitAndPrint(spec.ReturnFail(spec.ParameterIllegal, fmt.Sprintf("invalid parameter, %v", err)), 0)
+		}
 		log.Infof(ctx, "mode: %s, target: %s, action: %s, flags %v", mode, target, action, expModel.ActionFlags)
 
 		key := expModel.Target + expModel.ActionName
@@ -129,8 +181,18 @@ func main() {
 		if executor == nil {
 			exitAndPrint(spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("not found executor, target: %s, action: %s", target, action)), 0)
 		} else {
+			var containerTarget *container.Target
+			var response *spec.Response
+			if ctx, containerTarget, response = container.Apply(ctx, expModel.ActionFlags); response != nil {
+				exitAndPrint(response, 0)
+			}
+			var cl spec.Channel
+			if containerTarget != nil {
+				// the experiment in the container always enters its namespaces
+				expModel.ActionFlags[model.ChannelFlag.Name] = spec.NSExecBin
+			}
 			if expModel.ActionFlags[model.ChannelFlag.Name] == spec.LocalChannel {
-				executor.SetChannel(channel.NewLocalChannel())
+				cl = channel.NewLocalChannel()
 			} else if expModel.ActionFlags[model.ChannelFlag.Name] == spec.NSExecBin {
 
 				ctx = context.WithValue(ctx, model.NsTargetFlag.Name, expModel.ActionFlags[model.NsTargetFlag.Name])
@@ -138,20 +200,156 @@ func main() {
 				if expModel.ActionFlags[model.NsPidFlag.Name] == spec.True {
 					ctx = context.WithValue(ctx, model.NsPidFlag.Name, spec.True)
 				}
-				if expModel.ActionFlags[model.NsMntFlag.Name] == spec.True {
+				if expModel.ActionFlags[model.NsMntFlag.Name] == spec.True || model.NeedsMountNamespace(target) {
 					ctx = context.WithValue(ctx, model.NsMntFlag.Name, spec.True)
 				}
 				if expModel.ActionFlags[model.NsNetFlag.Name] == spec.True {
 					ctx = context.WithValue(ctx, model.NsNetFlag.Name, spec.True)
 				}
 
-				executor.SetChannel(channel.NewNSExecChannel())
+				cl = channel.NewNSExecChannel()
+				if containerTarget != nil {
+					cl = container.NewChannel(containerTarget, cl)
+				}
 			} else {
-				executor.SetChannel(channel.NewLocalChannel())
+				cl = channel.NewLocalChannel()
+			}
+			executor.SetChannel(cl)
+			// chaos_os status target action --uid uid verifies whether the fault is still effective
+			if mode == exec.StatusKey {
+				exitAndPrint(executor.Exec(uid, exec.WithStatus(ctx), expModel), 0)
+			}
+			if expModel.ActionFlags[model.DryRunFlag.Name] == spec.True {
+				ctx = exec.WithDryRun(ctx)
+			}
+			if exec.IsDryRun(ctx) {
+				exitAndPrint(exec.ExecDryRun(ctx, executor, cl, uid, expModel, model.GetDryRunSupport(target, action)), 0)
+			}
+			if watchdogState != nil {
+				exitAndPrint(runWatchdog(ctx, cl, uid, watchdogState, executor, expModel), 0)
+			}
+			// fail fast before the experiment changes anything if the capabilities or the commands are missing
+			if mode == spec.Create {
+				if response = exec.Preflight(ctx, cl, target, action); response != nil {
+					exitAndPrint(response, 0)
+				}
+			}
+			// there is no pgrep and kill on Windows, the hanging process is destroyed by the recorded pid
+			if mode == spec.Create && runtime.GOOS == "windows" && isProcessHang(target, action, expModel.ActionFlags) {
+				if err := exec.RecordPid(uid); err != nil {
+					log.Warnf(ctx, "record the pid of the experiment failed, %v", err)
+				}
 			}
-			exitAndPrint(executor.Exec(uid, ctx, expModel), 0)
+			var timeout time.Duration
+			if value := expModel.ActionFlags[model.TimeoutFlag.Name]; mode == spec.Create && value != "" {
+				var response *spec.Response
+				if timeout, response = validation.ValidateDuration(model.TimeoutFlag.Name, value, time.Second); response != nil {
+					exitAndPrint(response, 0)
+				}
+				if isProcessHang(target, action, expModel.ActionFlags) {
+					exitAndPrint(spec.ResponseFailWithFlags(spec.ParameterIllegal, model.TimeoutFlag.Name, value,
+						"it's not supported by the action which keeps running until destroyed"), 0)
+				}
+			}
+			if mode == spec.Destroy {
+				if err := cancelWatchdog(ctx, uid, target, action); err != nil {
+					log.Warnf(ctx, "cancel the watchdog of the experiment failed, %v", err)
+				}
+			}
+			// the container may be restarted since it's resolved, and the pid is reused by another process
+			if containerTarget != nil {
+				if response = container.Validate(ctx, containerTarget); response != nil {
+					exitAndPrint(response, 0)
+				}
+				if mode == spec.Create {
+					if err := container.SaveRecord(uid, containerTarget); err != nil {
+						log.Warnf(ctx, "record the container of the experiment failed, %v", err)
+					}
+				}
+			}
+			// the goroutines of the experiment on the ns_target don't need more threads than the cpus of its cgroup
+			if pid := expModel.ActionFlags[model.NsTargetFlag.Name]; mode == spec.Create && pid != "" {
+				cgroupCtx := cgroups.WithHostProc(ctx, expModel.ActionFlags[cgroups.HostProcKey])
+				if prev, _, err := automaxprocs.Apply(cgroupCtx, expModel.ActionFlags["cgroup-root"], pid); err != nil {
+					log.Warnf(ctx, "apply GOMAXPROCS by the cgroup of the pid %s failed, %v", pid, err)
+				} else {
+					log.Infof(ctx, "GOMAXPROCS is %d by the cgroup of the pid %s, previous: %d", runtime.GOMAXPROCS(0), pid, prev)
+				}
+			}
+			response = executor.Exec(uid, ctx, expModel)
+			if containerTarget != nil && mode == spec.Destroy && response.Success {
+				container.RemoveRecord(uid)
+			}
+			// the experiment destroyed before the timeout leaves the record of the watchdog
+			if mode == spec.Destroy && response.Success {
+				if recordResponse := exec.DestroyWatchdogRecord(ctx, cl, uid); recordResponse != nil && !recordResponse.Success {
+					response = recordResponse
+				}
+			}
+			if mode == spec.Destroy {
+				log.Infof(ctx, "destroy the experiment %s, success: %t, code: %d, err: %s", uid, response.Success, response.Code, response.Err)
+				response = exec.WithExperimentLogTail(response, logDir, uid)
+			}
+			if timeout > 0 && response.Success {
+				if err := startWatchdog(ctx, uid, timeout, expModel); err != nil {
+					log.Errorf(ctx, "start the watchdog of the experiment failed, %v", err)
+					executor.Exec(uid, spec.SetDestroyFlag(ctx, uid), expModel)
+					exitAndPrint(spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("start the watchdog failed, %v", err)), 0)
+				}
+			}
+			exitAndPrint(response, 0)
+		}
+	}
+}
+
+// parseActionFlags parses the flags of the target action from the command line arguments
+func parseActionFlags(key string, args []string, errorHandling flag.ErrorHandling) (map[string]string, error) {
+	flagsx := modelActionFlags[key]
+
+	flagsValues := make(map[string]*string, len(flagsx))
+
+	cmd := flag.NewFlagSet(os.Args[0], errorHandling)
+
+	for _, f := range flagsx {
+		s := cmd.String(f.Name, f.Default, f.Desc)
+		flagsValues[f.Name] = s
+	}
+
+	if err := cmd.Parse(args); err != nil {
+		return nil, err
+	}
+
+	actionFlags := make(map[string]string, len(flagsx))
+	for k, v := range flagsValues {
+		actionFlags[k] = *v
+	}
+	return actionFlags, nil
+}
+
+// isProcessHang returns whether the action keeps running until the experiment is destroyed with the flags
+func isProcessHang(target, action string, flags map[string]string) bool {
+	commandSpec, ok := modelMap[target]
+	if !ok {
+		return false
+	}
+	for _, actionSpec := range commandSpec.Actions() {
+		if actionSpec.Name() == action {
+			if checker, ok := actionSpec.(exec.ProcessHangChecker); ok {
+				return checker.IsProcessHang(flags)
+			}
+			return actionSpec.ProcessHang()
+		}
+	}
+	return false
+}
+
+func hasFlag(flags []spec.ExpFlag, name string) bool {
+	for _, f := range flags {
+		if f.Name == name {
+			return true
 		}
 	}
+	return false
 }
 
 func exitAndPrint(response *spec.Response, code int) {
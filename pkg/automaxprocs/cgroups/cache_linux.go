@@ -0,0 +1,73 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"context"
+	"sync"
+)
+
+// onceEntry is the cached result of a cache key, it's resolved by the first caller only
+type onceEntry struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+var (
+	// _hierarchies are the detected hierarchies keyed by the cgroup root
+	_hierarchies sync.Map
+	// _mountPoints are the parsed mount points keyed by the mountinfo path
+	_mountPoints sync.Map
+)
+
+// loadOnce returns the cached value of the key, the resolve is called once by the first caller and the
+// concurrent callers wait for it, the later ones cost a map lookup only
+func loadOnce(cache *sync.Map, key string, resolve func() (interface{}, error)) (interface{}, error) {
+	v, ok := cache.Load(key)
+	if !ok {
+		v, _ = cache.LoadOrStore(key, &onceEntry{})
+	}
+	entry := v.(*onceEntry)
+	entry.once.Do(func() {
+		entry.value, entry.err = resolve()
+	})
+	return entry.value, entry.err
+}
+
+// cachedCGroupHierarchy returns the hierarchy of the cgroup root which is detected from the mountinfo of
+// the chaosblade process once. The returned hierarchy is shared, so it must not be modified.
+func cachedCGroupHierarchy(ctx context.Context, cgroupRoot string) *CGroupHierarchy {
+	mountInfoPath := GetFSRoot(ctx).Proc("self", "mountinfo")
+	v, _ := loadOnce(&_hierarchies, cgroupRoot+"\x00"+mountInfoPath, func() (interface{}, error) {
+		return detectCGroupHierarchy(ctx, cgroupRoot, mountInfoPath), nil
+	})
+	return v.(*CGroupHierarchy)
+}
+
+// cachedMountPoints returns the mount points of the mountinfo which is parsed once, the mount points
+// before the malformed line are returned with the error
+func cachedMountPoints(mountInfoPath string) ([]*MountPoint, error) {
+	v, err := loadOnce(&_mountPoints, mountInfoPath, func() (interface{}, error) {
+		var mountPoints []*MountPoint
+		err := parseMountInfo(mountInfoPath, hostDefaultCgroupFsPath, func(mp *MountPoint) error {
+			mountPoints = append(mountPoints, mp)
+			return nil
+		})
+		return mountPoints, err
+	})
+	return v.([]*MountPoint), err
+}
+
+// Invalidate drops the cached cgroup hierarchies and mount points, so that the next detection reads the
+// mountinfo again. The cgroup root detected by DetectCGroupRoot is kept.
+func Invalidate() {
+	_hierarchies.Range(func(key, _ interface{}) bool {
+		_hierarchies.Delete(key)
+		return true
+	})
+	_mountPoints.Range(func(key, _ interface{}) bool {
+		_mountPoints.Delete(key)
+		return true
+	})
+}
@@ -0,0 +1,88 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_loadOnce(t *testing.T) {
+	var cache sync.Map
+	var calls int32
+	resolve := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "value", nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := loadOnce(&cache, "key", resolve); err != nil || v != "value" {
+				t.Errorf("loadOnce() = (%v, %v), want value", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("resolve is called %d times, want 1", calls)
+	}
+}
+
+func TestDetectCGroupHierarchy_Cached(t *testing.T) {
+	ctx := context.Background()
+	Invalidate()
+	root, mountInfo := cgroupFixture(t, "unified", "", "27 25 0:24 / ROOT/unified rw - cgroup2 cgroup2 rw")
+	results := make([]*CGroupHierarchy, 32)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = DetectCGroupHierarchy(ctx, root)
+		}(i)
+	}
+	wg.Wait()
+	for i, h := range results {
+		if h != results[0] {
+			t.Fatalf("the hierarchy %d isn't the cached one", i)
+		}
+	}
+	if results[0].UnifiedMount == "" {
+		t.Errorf("DetectCGroupHierarchy() = %+v, want the unified mount under %s", results[0], root)
+	}
+
+	Invalidate()
+	if h := DetectCGroupHierarchy(ctx, root); h == results[0] {
+		t.Errorf("the hierarchy is still cached after Invalidate")
+	}
+
+	first, err := cachedMountPoints(mountInfo)
+	if err != nil || len(first) != 1 {
+		t.Fatalf("cachedMountPoints() = (%v, %v), want one mount point", first, err)
+	}
+	if second, _ := cachedMountPoints(mountInfo); &second[0] != &first[0] {
+		t.Errorf("the mount points are parsed again")
+	}
+}
+
+func BenchmarkDetectCGroupHierarchy(b *testing.B) {
+	ctx := context.Background()
+	root := DetectCGroupRoot(ctx).Path
+	b.Run("cached", func(b *testing.B) {
+		DetectCGroupHierarchy(ctx, root)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			DetectCGroupHierarchy(ctx, root)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Invalidate()
+			DetectCGroupHierarchy(ctx, root)
+		}
+	})
+}
@@ -4,10 +4,14 @@ package cgroups
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 )
@@ -21,6 +25,12 @@ const (
 	CGroupV2MemoryController = "memory"
 	// CGroupV2MemoryLimitFile is the memory limit file for cgroup v2
 	CGroupV2MemoryLimitFile = "memory.max"
+	// CGroupV2MemoryHighFile is the memory throttle limit file for cgroup v2, the cgroup is reclaimed heavily above it
+	CGroupV2MemoryHighFile = "memory.high"
+	// CGroupV2MemorySwapMaxFile is the swap limit file for cgroup v2
+	CGroupV2MemorySwapMaxFile = "memory.swap.max"
+	// CGroupV2MemoryCurrentFile is the memory usage file for cgroup v2
+	CGroupV2MemoryCurrentFile = "memory.current"
 )
 
 // CGroupV2Impl represents a cgroup v2 control group implementation
@@ -82,62 +92,300 @@ func (cg *CGroupV2Impl) CPUQuota() (float64, bool, error) {
 	return cpuQuota, true, nil
 }
 
-// MemoryLimit returns the memory limit for cgroup v2
+// MemoryLimit returns the memory limit for cgroup v2, it's the memory.max of MemoryLimits
 func (cg *CGroupV2Impl) MemoryLimit() (int64, bool, error) {
-	limitFile := filepath.Join(cg.path, CGroupV2MemoryLimitFile)
-	content, err := os.ReadFile(limitFile)
+	limits, err := cg.MemoryLimits()
 	if err != nil {
-		log.Errorf(context.Background(), "failed to read memory.max file: %v", err)
 		return 0, false, err
 	}
+	return limits.Max.Value, limits.Max.Defined, nil
+}
 
-	limitStr := strings.TrimSpace(string(content))
-	log.Infof(context.Background(), "cgroup v2 memory.max content: %s", limitStr)
-
-	// Handle "max" case (unlimited)
-	if limitStr == "max" {
-		return 0, false, nil
+// MemoryLimits returns memory.max, memory.high, memory.swap.max and memory.current for cgroup v2. The value of
+// the file which is max (unlimited) or absent, such as the files of the root cgroup, is undefined.
+func (cg *CGroupV2Impl) MemoryLimits() (*MemoryLimits, error) {
+	limits := &MemoryLimits{}
+	for file, value := range map[string]*MemoryValue{
+		CGroupV2MemoryLimitFile:   &limits.Max,
+		CGroupV2MemoryHighFile:    &limits.High,
+		CGroupV2MemorySwapMaxFile: &limits.SwapMax,
+		CGroupV2MemoryCurrentFile: &limits.Current,
+	} {
+		content, err := readStatFile(cg.path, file)
+		if err != nil {
+			if IsNotAvailable(err) {
+				continue
+			}
+			log.Errorf(context.Background(), "failed to read %s file: %v", file, err)
+			return nil, err
+		}
+		if *value, err = parseMemoryValue(content); err != nil {
+			log.Errorf(context.Background(), "failed to parse %s: %v", file, err)
+			return nil, err
+		}
 	}
+	log.Infof(context.Background(), "cgroup v2 memory limits: %+v", *limits)
+	return limits, nil
+}
 
-	limit, err := strconv.ParseInt(limitStr, 10, 64)
+// CPUSetCount returns the count of the CPUs in cpuset.cpus.effective for cgroup v2, it's undefined if the
+// cpuset controller isn't enabled for the cgroup
+func (cg *CGroupV2Impl) CPUSetCount() (int, bool, error) {
+	content, err := readStatFile(cg.path, CGroupV2CPUSetEffectiveFile)
 	if err != nil {
-		log.Errorf(context.Background(), "failed to parse memory limit: %v", err)
+		if IsNotAvailable(err) {
+			return 0, false, nil
+		}
+		log.Errorf(context.Background(), "failed to read %s file: %v", CGroupV2CPUSetEffectiveFile, err)
 		return 0, false, err
 	}
+	count, defined, err := cpuSetCount(content)
+	log.Infof(context.Background(), "cgroup v2 cpuset.cpus.effective: %s, count: %d", strings.TrimSpace(content), count)
+	return count, defined, err
+}
+
+// IOMax returns the io limits keyed by the device, the zero limit is unlimited, NotAvailableError is returned if
+// the io controller isn't enabled for the cgroup
+func (cg *CGroupV2Impl) IOMax() (map[string]IOMax, error) {
+	content, err := readStatFile(cg.path, CGroupV2IOMaxFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseIOMax(content), nil
+}
 
-	log.Infof(context.Background(), "cgroup v2 memory limit: %d", limit)
-	return limit, true, nil
+// SetIOMax limits the io of the block device, such as 8:0, the zero limit is unlimited. NotAvailableError is
+// returned if the io controller isn't enabled for the cgroup, see Manager.SetIOMax which enables it.
+func (cg *CGroupV2Impl) SetIOMax(device string, max IOMax) error {
+	d, err := ParseDevice(device)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(cg.path, CGroupV2IOMaxFile)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return NotAvailableError{File: CGroupV2IOMaxFile, Err: err}
+		}
+		return err
+	}
+	return writeControlFile(cg.path, CGroupV2IOMaxFile, formatIOMax(d.String(), max))
 }
 
-// FindCGroupV2Path finds the cgroup v2 path for a given PID
-func FindCGroupV2Path(ctx context.Context, pid string, cgroupRoot string) (string, error) {
-	if cgroupRoot == "" {
-		cgroupRoot = "/sys/fs/cgroup"
+// IOStat returns the io statistics keyed by the device, NotAvailableError is returned if the file doesn't exist
+func (cg *CGroupV2Impl) IOStat() (map[string]IOStat, error) {
+	content, err := readStatFile(cg.path, CGroupV2IOStatFile)
+	if err != nil {
+		return nil, err
 	}
+	return parseIOStat(content), nil
+}
 
-	// Read /proc/PID/cgroup to find the cgroup path
-	cgroupFile := filepath.Join("/proc", pid, "cgroup")
-	content, err := os.ReadFile(cgroupFile)
+// CPUStat returns the cpu.stat of the cgroup, NotAvailableError is returned if the file doesn't exist
+func (cg *CGroupV2Impl) CPUStat() (*CPUStat, error) {
+	content, err := readStatFile(cg.path, CGroupV2CPUStatFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseCPUStat(content)
+}
+
+// Pressure returns the CPU pressure stall information of the cgroup, NotAvailableError is returned if the
+// kernel doesn't support it or it's disabled by psi=0
+func (cg *CGroupV2Impl) Pressure() (*Pressure, error) {
+	return cg.readPressure(CGroupV2CPUPressureFile)
+}
+
+// MemoryPressure returns the memory pressure stall information of the cgroup, see Pressure
+func (cg *CGroupV2Impl) MemoryPressure() (*Pressure, error) {
+	return cg.readPressure(CGroupV2MemoryPressureFile)
+}
+
+// SystemMemoryPressure returns the memory pressure stall information of the whole system, it's read from the
+// host proc filesystem of the context if it's set, see MemoryPressure
+func SystemMemoryPressure(ctx context.Context) (*Pressure, error) {
+	proc := GetHostProc(ctx)
+	if proc == "" {
+		proc = "/proc"
+	}
+	content, err := readStatFile(filepath.Join(proc, "pressure"), "memory")
+	if err != nil {
+		return nil, err
+	}
+	pressure, err := parsePressure(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse the memory pressure of the system failed, %v", err)
+	}
+	return pressure, nil
+}
+
+func (cg *CGroupV2Impl) readPressure(file string) (*Pressure, error) {
+	content, err := readStatFile(cg.path, file)
+	if err != nil {
+		return nil, err
+	}
+	pressure, err := parsePressure(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s failed, %v", file, err)
+	}
+	return pressure, nil
+}
+
+// readStatFile reads the statistics file of the cgroup, the missing file and the one which can't be read
+// because the feature is disabled are NotAvailableError
+func readStatFile(dir, file string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, file))
 	if err != nil {
-		log.Errorf(ctx, "failed to read cgroup file for PID %s: %v", pid, err)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.EOPNOTSUPP) {
+			return "", NotAvailableError{File: file, Err: err}
+		}
 		return "", err
 	}
+	return string(content), nil
+}
+
+// _hostCGroupMounts are the usual mount points of the host cgroupfs in the container, they are tried if the
+// cgroup path isn't found under the cgroup root
+var _hostCGroupMounts = []string{"/host/sys/fs/cgroup", "/host-sys/fs/cgroup", "/rootfs/sys/fs/cgroup"}
+
+// FindCGroupV2Path finds the cgroup v2 path for a given PID, the detected cgroup root is used if the cgroupRoot is empty.
+// The path is under the unified mount, which is a directory under the root on the hybrid system.
+//
+// chaosblade running in the container may see the host cgroupfs at another mount point and the cgroup path
+// relative to its own cgroup namespace, so the /proc/<pid>/cgroup of the host proc filesystem of HostProcKey is
+// read too, and the path is verified under the cgroup root, the usual host cgroupfs mounts and the cgroupfs
+// of the host pid 1. The first existing one is returned. The proc filesystem and the cgroup root of the FSRoot
+// of the context are used.
+func FindCGroupV2Path(ctx context.Context, pid string, cgroupRoot string) (string, error) {
+	hierarchy := DetectCGroupHierarchy(ctx, cgroupRoot)
+	if hierarchy.UnifiedMount == "" {
+		log.Debugf(ctx, "no cgroup v2 hierarchy under %s", hierarchy.Root)
+		return "", nil
+	}
+	cgroupFiles := []string{GetFSRoot(ctx).Proc(pid, "cgroup")}
+	mounts := []string{hierarchy.UnifiedMount}
+	if hostProc := GetHostProc(ctx); hostProc != "" {
+		cgroupFiles = append(cgroupFiles, filepath.Join(hostProc, pid, "cgroup"))
+		mounts = append(mounts, filepath.Join(hostProc, "1", "root", CGroupV2UnifiedMount))
+	}
+	for _, mount := range append(mounts[1:], _hostCGroupMounts...) {
+		if _, err := os.Stat(mount); err == nil {
+			if unified := DetectCGroupHierarchy(ctx, mount).UnifiedMount; unified != "" {
+				mounts = append(mounts, unified)
+			}
+		}
+	}
+	return findCGroupV2Path(ctx, pid, cgroupFiles, uniquePaths(mounts))
+}
 
-	lines := strings.Split(string(content), "\n")
-	for _, line := range lines {
-		if line == "" {
+// findCGroupV2Path returns the first existing path of the cgroup read from the cgroup files under the mounts
+func findCGroupV2Path(ctx context.Context, pid string, cgroupFiles, mounts []string) (string, error) {
+	var paths []string
+	var readErr error
+	outside := false
+	for _, cgroupFile := range cgroupFiles {
+		cgroupPath, err := readCGroupV2Path(cgroupFile)
+		if err != nil {
+			log.Debugf(ctx, "failed to read cgroup file %s for PID %s: %v", cgroupFile, pid, err)
+			readErr = err
 			continue
 		}
+		if cgroupPath != "" {
+			candidates, out := cgroupPathCandidates(cgroupPath)
+			paths = append(paths, candidates...)
+			outside = outside || out
+		}
+	}
+	if len(paths) == 0 {
+		if readErr != nil {
+			log.Errorf(ctx, "failed to read cgroup file for PID %s: %v", pid, readErr)
+		}
+		return "", readErr
+	}
+	var tried []string
+	for _, mount := range mounts {
+		for _, cgroupPath := range uniquePaths(paths) {
+			fullPath := filepath.Join(mount, cgroupPath)
+			if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+				log.Infof(ctx, "found cgroup v2 path for PID %s: %s", pid, fullPath)
+				return fullPath, nil
+			}
+			tried = append(tried, fullPath)
+		}
+	}
+	if outside {
+		for _, mount := range mounts {
+			for _, cgroupPath := range uniquePaths(paths) {
+				if fullPath := findOutsideCGroup(mount, cgroupPath); fullPath != "" {
+					log.Infof(ctx, "found cgroup v2 path for PID %s out of the cgroup namespace: %s", pid, fullPath)
+					return fullPath, nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("the cgroup v2 path of PID %s doesn't exist, tried %s", pid, strings.Join(tried, ", "))
+}
 
-		// cgroup v2 format: 0::/path/to/cgroup
+// readCGroupV2Path returns the path of the cgroup v2 line in the cgroup file, such as 0::/path/to/cgroup
+func readCGroupV2Path(cgroupFile string) (string, error) {
+	content, err := os.ReadFile(cgroupFile)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
 		parts := strings.SplitN(line, ":", 3)
 		if len(parts) == 3 && parts[0] == "0" && parts[1] == "" {
-			cgroupPath := parts[2]
-			fullPath := filepath.Join(cgroupRoot, cgroupPath)
-			log.Infof(ctx, "found cgroup v2 path for PID %s: %s", pid, fullPath)
-			return fullPath, nil
+			return parts[2], nil
 		}
 	}
-
 	return "", nil
 }
+
+// _maxNamespaceDepth is the max depth of the ancestors of the cgroup namespace root which are searched for the
+// cgroup out of the namespace
+const _maxNamespaceDepth = 4
+
+// cgroupPathCandidates returns the path relative to the cgroup root. The path of the cgroup out of the cgroup
+// namespace of the reader is relative to the namespace root, such as /../../pod2/container2, it's returned
+// without the leading .. and marked as outside, whose ancestors are searched then, see findOutsideCGroup.
+func cgroupPathCandidates(cgroupPath string) ([]string, bool) {
+	parts := strings.Split(strings.TrimPrefix(cgroupPath, "/"), "/")
+	i := 0
+	for i < len(parts) && parts[i] == ".." {
+		i++
+	}
+	if i == 0 {
+		return []string{cgroupPath}, false
+	}
+	return []string{"/" + strings.Join(parts[i:], "/")}, true
+}
+
+// findOutsideCGroup searches the cgroup out of the namespace under the ancestors of the namespace root, which
+// are unknown, so the ancestors at each depth are globbed and the only match is returned
+func findOutsideCGroup(mount, cgroupPath string) string {
+	for depth := 1; depth <= _maxNamespaceDepth; depth++ {
+		pattern := filepath.Join(mount, strings.Repeat("*/", depth)+strings.TrimPrefix(cgroupPath, "/"))
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return ""
+		}
+		if len(matches) == 1 {
+			return matches[0]
+		}
+		if len(matches) > 1 {
+			return ""
+		}
+	}
+	return ""
+}
+
+func uniquePaths(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	unique := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,109 @@
+//go:build !linux
+
+package cgroups
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	// CGroupV2CPUController is the CPU controller for cgroup v2
+	CGroupV2CPUController = "cpu"
+	// CGroupV2CPUQuotaFile is the CPU quota file for cgroup v2
+	CGroupV2CPUQuotaFile = "cpu.max"
+	// CGroupV2MemoryController is the memory controller for cgroup v2
+	CGroupV2MemoryController = "memory"
+	// CGroupV2MemoryLimitFile is the memory limit file for cgroup v2
+	CGroupV2MemoryLimitFile = "memory.max"
+	// CGroupV2MemoryHighFile is the memory throttle limit file for cgroup v2, the cgroup is reclaimed heavily above it
+	CGroupV2MemoryHighFile = "memory.high"
+	// CGroupV2MemorySwapMaxFile is the swap limit file for cgroup v2
+	CGroupV2MemorySwapMaxFile = "memory.swap.max"
+	// CGroupV2MemoryCurrentFile is the memory usage file for cgroup v2
+	CGroupV2MemoryCurrentFile = "memory.current"
+)
+
+// CGroupV2Impl represents a cgroup v2 control group implementation
+type CGroupV2Impl struct {
+	path string
+}
+
+// NewCGroupV2Impl creates a new CGroupV2Impl instance
+func NewCGroupV2Impl(path string) *CGroupV2Impl {
+	return &CGroupV2Impl{path: path}
+}
+
+// CPUQuota returns the CPU quota for cgroup v2
+// cgroups are only available on Linux, so this function returns an error
+func (cg *CGroupV2Impl) CPUQuota() (float64, bool, error) {
+	return 0, false, nil
+}
+
+// MemoryLimit returns the memory limit for cgroup v2
+// cgroups are only available on Linux, so this function returns an error
+func (cg *CGroupV2Impl) MemoryLimit() (int64, bool, error) {
+	return 0, false, nil
+}
+
+// MemoryLimits returns the memory limits and the usage for cgroup v2
+// cgroups are only available on Linux, so all of them are undefined
+func (cg *CGroupV2Impl) MemoryLimits() (*MemoryLimits, error) {
+	return &MemoryLimits{}, nil
+}
+
+// CPUSetCount returns the count of the CPUs in cpuset.cpus.effective for cgroup v2
+// cgroups are only available on Linux, so it's undefined
+func (cg *CGroupV2Impl) CPUSetCount() (int, bool, error) {
+	return 0, false, nil
+}
+
+// CPUStat returns the cpu.stat of the cgroup
+// cgroups are only available on Linux, so this function returns NotAvailableError
+func (cg *CGroupV2Impl) CPUStat() (*CPUStat, error) {
+	return nil, NotAvailableError{File: CGroupV2CPUStatFile, Err: errUnsupported}
+}
+
+// Pressure returns the CPU pressure stall information of the cgroup
+// cgroups are only available on Linux, so this function returns NotAvailableError
+func (cg *CGroupV2Impl) Pressure() (*Pressure, error) {
+	return nil, NotAvailableError{File: CGroupV2CPUPressureFile, Err: errUnsupported}
+}
+
+// MemoryPressure returns the memory pressure stall information of the cgroup
+// cgroups are only available on Linux, so this function returns NotAvailableError
+func (cg *CGroupV2Impl) MemoryPressure() (*Pressure, error) {
+	return nil, NotAvailableError{File: CGroupV2MemoryPressureFile, Err: errUnsupported}
+}
+
+// SystemMemoryPressure returns the memory pressure stall information of the whole system
+// the pressure stall information is only available on Linux, so this function returns NotAvailableError
+func SystemMemoryPressure(ctx context.Context) (*Pressure, error) {
+	return nil, NotAvailableError{File: "pressure/memory", Err: errUnsupported}
+}
+
+// IOMax returns the io limits keyed by the device
+// cgroups are only available on Linux, so this function returns NotAvailableError
+func (cg *CGroupV2Impl) IOMax() (map[string]IOMax, error) {
+	return nil, NotAvailableError{File: CGroupV2IOMaxFile, Err: errUnsupported}
+}
+
+// SetIOMax limits the io of the block device
+// cgroups are only available on Linux, so this function returns NotAvailableError
+func (cg *CGroupV2Impl) SetIOMax(device string, max IOMax) error {
+	return NotAvailableError{File: CGroupV2IOMaxFile, Err: errUnsupported}
+}
+
+// IOStat returns the io statistics keyed by the device
+// cgroups are only available on Linux, so this function returns NotAvailableError
+func (cg *CGroupV2Impl) IOStat() (map[string]IOStat, error) {
+	return nil, NotAvailableError{File: CGroupV2IOStatFile, Err: errUnsupported}
+}
+
+var errUnsupported = errors.New("cgroups are only available on Linux")
+
+// FindCGroupV2Path finds the cgroup v2 path for a given PID
+// cgroups are only available on Linux, so this function returns an error
+func FindCGroupV2Path(ctx context.Context, pid string, cgroupRoot string) (string, error) {
+	return "", nil
+}
@@ -4,6 +4,8 @@ package cgroups
 
 import (
 	"context"
+	"errors"
+	"io"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 )
@@ -87,3 +89,100 @@ func (cg CGroups) CPUQuota() (float64, bool, error) {
 	log.Infof(context.Background(), "CPU cgroup quota: %v, period: %v", cfsQuotaUs, cfsPeriodUs)
 	return float64(cfsQuotaUs) / float64(cfsPeriodUs), true, nil
 }
+
+// CPUSetCount returns the count of the CPUs in cpuset.cpus of the cpuset cgroup controller, it's undefined if
+// the cpuset controller isn't mounted or no CPU is set.
+func (cg CGroups) CPUSetCount() (int, bool, error) {
+	cpusetCGroup, exists := cg[_cgroupSubsysCPUSet]
+	if !exists {
+		log.Warnf(context.Background(), "cpuset cgroup is not found in cg")
+		return 0, false, nil
+	}
+	cpus, err := cpusetCGroup.readFirstLine(_cgroupCPUSetCPUsParam)
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	count, defined, err := cpuSetCount(cpus)
+	log.Infof(context.Background(), "cpuset cgroup cpus: %s, count: %d", cpus, count)
+	return count, defined, err
+}
+
+// blkio returns the blkio cgroup, NotAvailableError of the file is returned if the blkio controller isn't mounted
+func (cg CGroups) blkio(file string) (*CGroup, error) {
+	blkioCGroup, exists := cg[_cgroupSubsysBlkio]
+	if !exists {
+		return nil, NotAvailableError{File: file, Err: errors.New("the blkio cgroup is not found")}
+	}
+	return blkioCGroup, nil
+}
+
+// IOMax returns the blkio throttle limits keyed by the device, the zero limit is unlimited
+func (cg CGroups) IOMax() (map[string]IOMax, error) {
+	blkioCGroup, err := cg.blkio(_blkioReadBpsParam)
+	if err != nil {
+		return nil, err
+	}
+	limits := make(map[string]IOMax)
+	for _, param := range []struct {
+		file  string
+		field func(*IOMax) *int64
+	}{
+		{_blkioReadBpsParam, func(max *IOMax) *int64 { return &max.ReadBps }},
+		{_blkioWriteBpsParam, func(max *IOMax) *int64 { return &max.WriteBps }},
+		{_blkioReadIopsParam, func(max *IOMax) *int64 { return &max.ReadIops }},
+		{_blkioWriteIopsParam, func(max *IOMax) *int64 { return &max.WriteIops }},
+	} {
+		content, err := readStatFile(blkioCGroup.Path(), param.file)
+		if err != nil {
+			return nil, err
+		}
+		for device, limit := range parseBlkioThrottle(content) {
+			max := limits[device]
+			*param.field(&max) = limit
+			limits[device] = max
+		}
+	}
+	return limits, nil
+}
+
+// SetIOMax writes the blkio throttle limits of the block device, such as 8:0, the zero limit removes the throttle
+func (cg CGroups) SetIOMax(device string, max IOMax) error {
+	d, err := ParseDevice(device)
+	if err != nil {
+		return err
+	}
+	blkioCGroup, err := cg.blkio(_blkioReadBpsParam)
+	if err != nil {
+		return err
+	}
+	return writeBlkioThrottle(blkioCGroup.Path(), d.String(), max)
+}
+
+// IOStat returns the bytes and the IOs of the blkio throttle statistics keyed by the device
+func (cg CGroups) IOStat() (map[string]IOStat, error) {
+	blkioCGroup, err := cg.blkio(_blkioServiceBytesParam)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]IOStat)
+	bytes, err := readStatFile(blkioCGroup.Path(), _blkioServiceBytesParam)
+	if err != nil {
+		return nil, err
+	}
+	parseBlkioService(bytes, stats,
+		func(stat *IOStat) *uint64 { return &stat.ReadBytes },
+		func(stat *IOStat) *uint64 { return &stat.WriteBytes },
+		func(stat *IOStat) *uint64 { return &stat.DiscardBytes })
+	ios, err := readStatFile(blkioCGroup.Path(), _blkioServicedParam)
+	if err != nil {
+		return nil, err
+	}
+	parseBlkioService(ios, stats,
+		func(stat *IOStat) *uint64 { return &stat.ReadIOs },
+		func(stat *IOStat) *uint64 { return &stat.WriteIOs },
+		func(stat *IOStat) *uint64 { return &stat.DiscardIOs })
+	return stats, nil
+}
@@ -0,0 +1,62 @@
+package cgroups
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// _cgroupSubsysCPUSet is the cpuset CGroup subsystem.
+	_cgroupSubsysCPUSet = "cpuset"
+	// _cgroupCPUSetCPUsParam is the file name of the CPUs of the cgroup v1 cpuset.
+	_cgroupCPUSetCPUsParam = "cpuset.cpus"
+	// CGroupV2CPUSetEffectiveFile is the file of the CPUs granted to the cgroup v2, which is constrained by the
+	// ancestors, unlike cpuset.cpus which is empty unless it's set explicitly
+	CGroupV2CPUSetEffectiveFile = "cpuset.cpus.effective"
+)
+
+// ParseCPUList parses the cpu list format of the kernel, such as 0-3,8,10-11, the duplicated CPUs are counted
+// once. The empty list is valid, which means no CPU is set.
+func ParseCPUList(list string) ([]int, error) {
+	list = strings.TrimSpace(list)
+	cpus := make([]int, 0)
+	if list == "" {
+		return cpus, nil
+	}
+	seen := make(map[int]bool)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		first, last := item, item
+		if i := strings.Index(item, "-"); i >= 0 {
+			first, last = item[:i], item[i+1:]
+		}
+		start, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid cpu %q in the cpu list %q", first, list)
+		}
+		end, err := strconv.Atoi(strings.TrimSpace(last))
+		if err != nil || end < start {
+			return nil, fmt.Errorf("invalid cpu range %q in the cpu list %q", item, list)
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	return cpus, nil
+}
+
+// cpuSetCount returns the count of the CPUs in the cpu list, it's undefined if the list is empty
+func cpuSetCount(list string) (int, bool, error) {
+	cpus, err := ParseCPUList(list)
+	if err != nil {
+		return 0, false, err
+	}
+	return len(cpus), len(cpus) > 0, nil
+}
@@ -0,0 +1,36 @@
+package cgroups
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{list: "", want: []int{}},
+		{list: "\n", want: []int{}},
+		{list: "0", want: []int{0}},
+		{list: "0-3", want: []int{0, 1, 2, 3}},
+		{list: "0-1,4,6-7\n", want: []int{0, 1, 4, 6, 7}},
+		{list: "0-2,1-3", want: []int{0, 1, 2, 3}},
+		{list: "3-1", wantErr: true},
+		{list: "a", wantErr: true},
+		{list: "0-", wantErr: true},
+		{list: "-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			got, err := ParseCPUList(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCPUList(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCPUList(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
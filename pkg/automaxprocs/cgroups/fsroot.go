@@ -0,0 +1,38 @@
+package cgroups
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// FSRootKey is the context key of the FSRoot
+const FSRootKey = "cgroups-fs-root"
+
+// DefaultProcPath is the mount point of the proc filesystem
+const DefaultProcPath = "/proc"
+
+// FSRoot is where the cgroups package reads the proc filesystem and the cgroup hierarchies from, the tests
+// point it to a fake tree. The empty CGroupRoot means the detected cgroup root.
+type FSRoot struct {
+	ProcPath   string
+	CGroupRoot string
+}
+
+// WithFSRoot returns the context with the filesystem root, the empty fields of it are the defaults
+func WithFSRoot(ctx context.Context, root FSRoot) context.Context {
+	return context.WithValue(ctx, FSRootKey, root)
+}
+
+// GetFSRoot returns the filesystem root of the context, the real paths are returned if it's absent
+func GetFSRoot(ctx context.Context) FSRoot {
+	root, _ := ctx.Value(FSRootKey).(FSRoot)
+	if root.ProcPath == "" {
+		root.ProcPath = DefaultProcPath
+	}
+	return root
+}
+
+// Proc returns the path of the elements under the proc filesystem, such as Proc(pid, "cgroup")
+func (r FSRoot) Proc(elem ...string) string {
+	return filepath.Join(append([]string{r.ProcPath}, elem...)...)
+}
@@ -0,0 +1,252 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeFSRoot builds the fake proc filesystem and cgroup root under the temp directory, the paths of the files
+// are relative to it such as proc/1/cgroup and cgroup/cpu.max, and ROOT in the contents is the cgroup root
+func fakeFSRoot(t *testing.T, files map[string]string) FSRoot {
+	dir := t.TempDir()
+	root := FSRoot{ProcPath: filepath.Join(dir, "proc"), CGroupRoot: filepath.Join(dir, "cgroup")}
+	if err := os.MkdirAll(root.CGroupRoot, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		content = strings.ReplaceAll(content, "ROOT", root.CGroupRoot)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+const (
+	_fakeV2Mount  = "30 23 0:26 / ROOT rw,nosuid,nodev,noexec,relatime shared:4 - cgroup2 cgroup2 rw,nsdelegate\n"
+	_fakeV1Mounts = "34 25 0:29 / ROOT/cpu,cpuacct rw,nosuid shared:9 - cgroup cgroup rw,cpu,cpuacct\n" +
+		"35 25 0:30 / ROOT/memory rw,nosuid shared:10 - cgroup cgroup rw,memory\n"
+	_fakeUnifiedMount = "27 25 0:24 / ROOT/unified rw,nosuid shared:6 - cgroup2 cgroup2 rw,nsdelegate\n"
+)
+
+func TestDetectCGroupVersion_FSRoot(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  CGroupVersion
+	}{
+		{
+			name: "v1",
+			files: map[string]string{
+				"proc/self/mountinfo": _fakeV1Mounts,
+				"cgroup/cpu,cpuacct/": "",
+				"cgroup/memory/":      "",
+			},
+			want: CGroupV1,
+		},
+		{
+			name: "v2",
+			files: map[string]string{
+				"proc/self/mountinfo":       _fakeV2Mount,
+				"cgroup/cgroup.controllers": "cpu memory pids\n",
+			},
+			want: CGroupV2,
+		},
+		{
+			name: "hybrid",
+			files: map[string]string{
+				"proc/self/mountinfo":               _fakeV1Mounts + _fakeUnifiedMount,
+				"cgroup/unified/cgroup.controllers": "\n",
+			},
+			want: CGroupHybrid,
+		},
+		{
+			name: "v2 without the mountinfo",
+			files: map[string]string{
+				"cgroup/cgroup.controllers": "cpu memory\n",
+			},
+			want: CGroupV2,
+		},
+		{
+			name: "v1 without the mountinfo",
+			files: map[string]string{
+				"cgroup/memory/": "",
+			},
+			want: CGroupV1,
+		},
+		{
+			name: "malformed mountinfo",
+			files: map[string]string{
+				"proc/self/mountinfo":       "garbage\n",
+				"cgroup/cgroup.controllers": "cpu\n",
+			},
+			want: CGroupV2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithFSRoot(context.Background(), fakeFSRoot(t, tt.files))
+			if got := DetectCGroupVersion(ctx, ""); got != tt.want {
+				t.Errorf("DetectCGroupVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindCGroupV2Path_FSRoot(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "v2",
+			files: map[string]string{
+				"proc/self/mountinfo":              _fakeV2Mount,
+				"cgroup/cgroup.controllers":        "cpu memory\n",
+				"proc/42/cgroup":                   "0::/kubepods/pod1/container1\n",
+				"cgroup/kubepods/pod1/container1/": "",
+			},
+			want: "kubepods/pod1/container1",
+		},
+		{
+			name: "hybrid",
+			files: map[string]string{
+				"proc/self/mountinfo":                        _fakeV1Mounts + _fakeUnifiedMount,
+				"cgroup/unified/cgroup.controllers":          "\n",
+				"proc/42/cgroup":                             "4:memory:/user.slice\n0::/user.slice/session-1.scope\n",
+				"cgroup/unified/user.slice/session-1.scope/": "",
+			},
+			want: "unified/user.slice/session-1.scope",
+		},
+		{
+			name: "v1 only",
+			files: map[string]string{
+				"proc/self/mountinfo": _fakeV1Mounts,
+				"cgroup/memory/":      "",
+				"proc/42/cgroup":      "4:memory:/user.slice\n",
+			},
+		},
+		{
+			name: "missing cgroup file",
+			files: map[string]string{
+				"proc/self/mountinfo":       _fakeV2Mount,
+				"cgroup/cgroup.controllers": "cpu\n",
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed cgroup file",
+			files: map[string]string{
+				"proc/self/mountinfo":       _fakeV2Mount,
+				"cgroup/cgroup.controllers": "cpu\n",
+				"proc/42/cgroup":            "garbage\n",
+			},
+		},
+		{
+			name: "missing cgroup directory",
+			files: map[string]string{
+				"proc/self/mountinfo":       _fakeV2Mount,
+				"cgroup/cgroup.controllers": "cpu\n",
+				"proc/42/cgroup":            "0::/gone\n",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := fakeFSRoot(t, tt.files)
+			got, err := FindCGroupV2Path(WithFSRoot(context.Background(), root), "42", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindCGroupV2Path() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := ""
+			if tt.want != "" {
+				want = filepath.Join(root.CGroupRoot, tt.want)
+			}
+			if got != want {
+				t.Errorf("FindCGroupV2Path() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCGroupV2Impl_CPUQuota(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        float64
+		wantDefined bool
+		wantErr     bool
+	}{
+		{name: "quota", files: map[string]string{"cpu.max": "150000 100000\n"}, want: 1.5, wantDefined: true},
+		{name: "unlimited", files: map[string]string{"cpu.max": "max 100000\n"}},
+		{name: "missing file", files: map[string]string{}, wantErr: true},
+		{name: "missing period", files: map[string]string{"cpu.max": "150000\n"}},
+		{name: "malformed quota", files: map[string]string{"cpu.max": "abc 100000\n"}, wantErr: true},
+		{name: "malformed period", files: map[string]string{"cpu.max": "150000 abc\n"}, wantErr: true},
+		{name: "zero period", files: map[string]string{"cpu.max": "150000 0\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, defined, err := NewCGroupV2Impl(writeStatFiles(t, tt.files)).CPUQuota()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CPUQuota() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want || defined != tt.wantDefined {
+				t.Errorf("CPUQuota() = (%v, %v), want (%v, %v)", got, defined, tt.want, tt.wantDefined)
+			}
+		})
+	}
+}
+
+func TestCGroupV2Impl_FSRoot(t *testing.T) {
+	root := fakeFSRoot(t, map[string]string{
+		"proc/self/mountinfo":              _fakeV2Mount,
+		"cgroup/cgroup.controllers":        "cpu memory\n",
+		"proc/42/cgroup":                   "0::/app\n",
+		"cgroup/app/cpu.max":               "200000 100000\n",
+		"cgroup/app/cpu.stat":              "usage_usec 100\nuser_usec 60\nsystem_usec 40\nnr_throttled 3\n",
+		"cgroup/app/memory.max":            "1073741824\n",
+		"cgroup/app/memory.current":        "536870912\n",
+		"cgroup/app/memory.swap.max":       "max\n",
+		"cgroup/app/memory.high":           "not-a-number\n",
+		"cgroup/app/cpuset.cpus.effective": "0-1\n",
+	})
+	path, err := FindCGroupV2Path(WithFSRoot(context.Background(), root), "42", "")
+	if err != nil {
+		t.Fatalf("FindCGroupV2Path() error = %v", err)
+	}
+	cg := NewCGroupV2Impl(path)
+	if quota, defined, err := cg.CPUQuota(); err != nil || !defined || quota != 2 {
+		t.Errorf("CPUQuota() = (%v, %v, %v), want 2", quota, defined, err)
+	}
+	if stat, err := cg.CPUStat(); err != nil || stat.UsageUsec != 100 || stat.NrThrottled != 3 {
+		t.Errorf("CPUStat() = (%+v, %v), want the usage 100 and 3 throttled periods", stat, err)
+	}
+	if _, err := cg.Pressure(); !IsNotAvailable(err) {
+		t.Errorf("Pressure() error = %v, want not available", err)
+	}
+	if _, err := cg.MemoryLimits(); err == nil {
+		t.Errorf("MemoryLimits() succeeds with the malformed memory.high")
+	}
+	if count, defined, err := cg.CPUSetCount(); err != nil || !defined || count != 2 {
+		t.Errorf("CPUSetCount() = (%v, %v, %v), want 2", count, defined, err)
+	}
+}
@@ -0,0 +1,22 @@
+package cgroups
+
+import "context"
+
+// HostProcKey is the flag and the context key of the mount point of the host proc filesystem, such as
+// /host/proc, which chaosblade running in the container reads the /proc/<pid>/cgroup of the host pid from
+const HostProcKey = "host-proc"
+
+// WithHostProc returns the context with the mount point of the host proc filesystem, the context is returned
+// as is if it's empty
+func WithHostProc(ctx context.Context, hostProc string) context.Context {
+	if hostProc == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, HostProcKey, hostProc)
+}
+
+// GetHostProc returns the mount point of the host proc filesystem of the context
+func GetHostProc(ctx context.Context) string {
+	hostProc, _ := ctx.Value(HostProcKey).(string)
+	return hostProc
+}
@@ -0,0 +1,203 @@
+package cgroups
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CGroupV2IOController is the io controller for cgroup v2
+	CGroupV2IOController = "io"
+	// CGroupV2IOMaxFile is the io limit file for cgroup v2
+	CGroupV2IOMaxFile = "io.max"
+	// CGroupV2IOStatFile is the io statistics file for cgroup v2
+	CGroupV2IOStatFile = "io.stat"
+
+	// _cgroupSubsysBlkio is the blkio CGroup subsystem.
+	_cgroupSubsysBlkio = "blkio"
+	// _blkioServiceBytesParam is the file of the bytes transferred per device of the cgroup v1 blkio.
+	_blkioServiceBytesParam = "blkio.throttle.io_service_bytes"
+	// _blkioServicedParam is the file of the IOs completed per device of the cgroup v1 blkio.
+	_blkioServicedParam  = "blkio.throttle.io_serviced"
+	_blkioReadBpsParam   = "blkio.throttle.read_bps_device"
+	_blkioWriteBpsParam  = "blkio.throttle.write_bps_device"
+	_blkioReadIopsParam  = "blkio.throttle.read_iops_device"
+	_blkioWriteIopsParam = "blkio.throttle.write_iops_device"
+)
+
+// Device is the major:minor number of a block device
+type Device struct {
+	Major uint64
+	Minor uint64
+}
+
+// ParseDevice parses the device number such as 8:0
+func ParseDevice(device string) (Device, error) {
+	major, minor, ok := strings.Cut(strings.TrimSpace(device), ":")
+	if !ok {
+		return Device{}, fmt.Errorf("invalid device %q, it must be major:minor", device)
+	}
+	var d Device
+	var err error
+	if d.Major, err = strconv.ParseUint(major, 10, 32); err != nil {
+		return Device{}, fmt.Errorf("invalid major number of the device %q", device)
+	}
+	if d.Minor, err = strconv.ParseUint(minor, 10, 32); err != nil {
+		return Device{}, fmt.Errorf("invalid minor number of the device %q", device)
+	}
+	return d, nil
+}
+
+func (d Device) String() string {
+	return fmt.Sprintf("%d:%d", d.Major, d.Minor)
+}
+
+// IOStat is the io statistics of a block device, the discard ones are zero on cgroup v1 of the old kernels
+type IOStat struct {
+	ReadBytes    uint64
+	WriteBytes   uint64
+	ReadIOs      uint64
+	WriteIOs     uint64
+	DiscardBytes uint64
+	DiscardIOs   uint64
+}
+
+// formatIOMax returns the io.max line of the device, the zero limit is max
+func formatIOMax(device string, max IOMax) string {
+	return fmt.Sprintf("%s rbps=%s wbps=%s riops=%s wiops=%s", device,
+		limitValue(max.ReadBps, "max"), limitValue(max.WriteBps, "max"),
+		limitValue(max.ReadIops, "max"), limitValue(max.WriteIops, "max"))
+}
+
+// parseIOMax parses the content of io.max, such as 8:0 rbps=max wbps=1048576 riops=max wiops=max, the limits
+// are keyed by the device. The malformed lines and the unknown keys are skipped.
+func parseIOMax(content string) map[string]IOMax {
+	limits := make(map[string]IOMax)
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+		device, err := ParseDevice(parts[0])
+		if err != nil {
+			continue
+		}
+		var max IOMax
+		fields := map[string]*int64{
+			"rbps":  &max.ReadBps,
+			"wbps":  &max.WriteBps,
+			"riops": &max.ReadIops,
+			"wiops": &max.WriteIops,
+		}
+		valid := true
+		for _, part := range parts[1:] {
+			key, value, ok := strings.Cut(part, "=")
+			field, known := fields[key]
+			if !ok || !known || value == "max" {
+				continue
+			}
+			if *field, err = strconv.ParseInt(value, 10, 64); err != nil {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			limits[device.String()] = max
+		}
+	}
+	return limits
+}
+
+// parseIOStat parses the content of io.stat, such as 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353
+// dbytes=0 dios=0, the statistics are keyed by the device. The malformed lines and the unknown keys are skipped.
+func parseIOStat(content string) map[string]IOStat {
+	stats := make(map[string]IOStat)
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+		device, err := ParseDevice(parts[0])
+		if err != nil {
+			continue
+		}
+		var stat IOStat
+		fields := map[string]*uint64{
+			"rbytes": &stat.ReadBytes,
+			"wbytes": &stat.WriteBytes,
+			"rios":   &stat.ReadIOs,
+			"wios":   &stat.WriteIOs,
+			"dbytes": &stat.DiscardBytes,
+			"dios":   &stat.DiscardIOs,
+		}
+		valid := true
+		for _, part := range parts[1:] {
+			key, value, ok := strings.Cut(part, "=")
+			field, known := fields[key]
+			if !ok || !known {
+				continue
+			}
+			if *field, err = strconv.ParseUint(value, 10, 64); err != nil {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			stats[device.String()] = stat
+		}
+	}
+	return stats
+}
+
+// parseBlkioThrottle parses the content of the blkio throttle file of the cgroup v1, such as 8:0 1048576, the
+// limits are keyed by the device. The malformed lines are skipped.
+func parseBlkioThrottle(content string) map[string]int64 {
+	limits := make(map[string]int64)
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			continue
+		}
+		device, err := ParseDevice(parts[0])
+		if err != nil {
+			continue
+		}
+		if limit, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+			limits[device.String()] = limit
+		}
+	}
+	return limits
+}
+
+// parseBlkioService parses the content of the blkio io_service_bytes or io_serviced file of the cgroup v1,
+// such as 8:0 Read 1459200, and sets the read, write and discard counters by the fields of the stats keyed by
+// the device. The Total lines and the malformed ones are skipped.
+func parseBlkioService(content string, stats map[string]IOStat, read, write, discard func(*IOStat) *uint64) {
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 3 {
+			continue
+		}
+		device, err := ParseDevice(parts[0])
+		if err != nil {
+			continue
+		}
+		value, err := strconv.ParseUint(parts[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		stat := stats[device.String()]
+		switch parts[1] {
+		case "Read":
+			*read(&stat) = value
+		case "Write":
+			*write(&stat) = value
+		case "Discard":
+			*discard(&stat) = value
+		default:
+			continue
+		}
+		stats[device.String()] = stat
+	}
+}
@@ -0,0 +1,160 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDevice(t *testing.T) {
+	tests := []struct {
+		device  string
+		want    Device
+		wantErr bool
+	}{
+		{device: "8:0", want: Device{Major: 8}},
+		{device: " 259:3\n", want: Device{Major: 259, Minor: 3}},
+		{device: "8", wantErr: true},
+		{device: "a:0", wantErr: true},
+		{device: "8:-1", wantErr: true},
+		{device: "8:0:1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.device, func(t *testing.T) {
+			got, err := ParseDevice(tt.device)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDevice(%q) error = %v, wantErr %v", tt.device, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDevice(%q) = %v, want %v", tt.device, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCGroupV2Impl_IOMax(t *testing.T) {
+	dir := writeStatFiles(t, map[string]string{
+		CGroupV2IOMaxFile: "8:0 rbps=1048576 wbps=max riops=max wiops=100\n" +
+			"8:16 rbps=max wbps=max riops=max wiops=max\n" +
+			"garbage\n" +
+			"259:0 rbps=abc wbps=max riops=max wiops=max\n",
+		CGroupV2IOStatFile: "8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=4096 dios=1\n" +
+			"8:16 rbytes=90430464 wbytes=299008000 rios=8950 wios=1252\n" +
+			"x:y rbytes=1\n",
+	})
+	cg := NewCGroupV2Impl(dir)
+	limits, err := cg.IOMax()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLimits := map[string]IOMax{"8:0": {ReadBps: 1048576, WriteIops: 100}, "8:16": {}}
+	if !reflect.DeepEqual(limits, wantLimits) {
+		t.Errorf("IOMax() = %+v, want %+v", limits, wantLimits)
+	}
+	stats, err := cg.IOStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStats := map[string]IOStat{
+		"8:0":  {ReadBytes: 1459200, WriteBytes: 314773504, ReadIOs: 192, WriteIOs: 353, DiscardBytes: 4096, DiscardIOs: 1},
+		"8:16": {ReadBytes: 90430464, WriteBytes: 299008000, ReadIOs: 8950, WriteIOs: 1252},
+	}
+	if !reflect.DeepEqual(stats, wantStats) {
+		t.Errorf("IOStat() = %+v, want %+v", stats, wantStats)
+	}
+
+	if err := cg.SetIOMax("8:0", IOMax{WriteBps: 2048}); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(dir, CGroupV2IOMaxFile), "8:0 rbps=max wbps=2048 riops=max wiops=max")
+	if err := cg.SetIOMax("sda", IOMax{}); err == nil {
+		t.Errorf("SetIOMax(sda) succeeded, want error")
+	}
+}
+
+func TestCGroupV2Impl_IONotEnabled(t *testing.T) {
+	cg := NewCGroupV2Impl(t.TempDir())
+	if _, err := cg.IOMax(); !IsNotAvailable(err) {
+		t.Errorf("IOMax() error = %v, want not available", err)
+	}
+	if _, err := cg.IOStat(); !IsNotAvailable(err) {
+		t.Errorf("IOStat() error = %v, want not available", err)
+	}
+	if err := cg.SetIOMax("8:0", IOMax{ReadBps: 1}); !IsNotAvailable(err) {
+		t.Errorf("SetIOMax() error = %v, want not available", err)
+	}
+}
+
+func TestCGroups_IO(t *testing.T) {
+	dir := writeStatFiles(t, map[string]string{
+		"blkio.throttle.read_bps_device":   "8:0 1048576\n",
+		"blkio.throttle.write_bps_device":  "8:0 2048\n8:16 4096\n",
+		"blkio.throttle.read_iops_device":  "",
+		"blkio.throttle.write_iops_device": "8:16 50\nmalformed\n",
+		"blkio.throttle.io_service_bytes": "8:0 Read 1459200\n8:0 Write 314773504\n8:0 Sync 1\n8:0 Total 316232704\n" +
+			"Total 316232704\n",
+		"blkio.throttle.io_serviced": "8:0 Read 192\n8:0 Write 353\n8:0 Discard 2\n8:0 Total 547\nTotal 547\n",
+	})
+	cg := CGroups{"blkio": NewCGroup(dir)}
+	limits, err := cg.IOMax()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLimits := map[string]IOMax{"8:0": {ReadBps: 1048576, WriteBps: 2048}, "8:16": {WriteBps: 4096, WriteIops: 50}}
+	if !reflect.DeepEqual(limits, wantLimits) {
+		t.Errorf("IOMax() = %+v, want %+v", limits, wantLimits)
+	}
+	stats, err := cg.IOStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStats := map[string]IOStat{"8:0": {ReadBytes: 1459200, WriteBytes: 314773504, ReadIOs: 192, WriteIOs: 353, DiscardIOs: 2}}
+	if !reflect.DeepEqual(stats, wantStats) {
+		t.Errorf("IOStat() = %+v, want %+v", stats, wantStats)
+	}
+
+	if err := cg.SetIOMax("8:16", IOMax{ReadIops: 10}); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(dir, "blkio.throttle.read_bps_device"), "8:16 0")
+	assertFile(t, filepath.Join(dir, "blkio.throttle.read_iops_device"), "8:16 10")
+
+	if _, err := (CGroups{}).IOMax(); !IsNotAvailable(err) {
+		t.Errorf("IOMax() without blkio error = %v, want not available", err)
+	}
+}
+
+func TestManagerV2Fake_SetIOMaxEnablesParent(t *testing.T) {
+	root := t.TempDir()
+	writeFakeFile(t, filepath.Join(root, "cgroup.controllers"), "cpu io memory\n")
+	m, err := NewManager(context.Background(), root, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := m.CreateChild("chaos-io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := child.SetIOMax("8:0", IOMax{ReadBps: 1024}); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(root, "cgroup.subtree_control"), "+io")
+	assertFile(t, filepath.Join(root, "chaos-io", CGroupV2IOMaxFile), "8:0 rbps=1024 wbps=max riops=max wiops=max")
+
+	// the io controller isn't available in the parent
+	writeFakeFile(t, filepath.Join(root, "chaos-io", "cgroup.controllers"), "cpu memory\n")
+	grandchild, err := child.CreateChild("nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := grandchild.SetIOMax("8:0", IOMax{ReadBps: 1024}); err == nil {
+		t.Errorf("SetIOMax() succeeded without the io controller in the parent, want error")
+	}
+	if _, err := os.Stat(filepath.Join(root, "chaos-io", "nested", CGroupV2IOMaxFile)); !os.IsNotExist(err) {
+		t.Errorf("io.max is written without the io controller, %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package cgroups
+
+// IOMax is the io limits of a block device, the zero value means unlimited
+type IOMax struct {
+	ReadBps   int64
+	WriteBps  int64
+	ReadIops  int64
+	WriteIops int64
+}
+
+// Manager creates and changes the cgroups owned by chaosblade, the cgroup v1 and v2 are both supported.
+// The path of the manager is relative to the cgroup root, the cgroup of the v1 is the same path in every
+// mounted hierarchy.
+type Manager interface {
+	// Path returns the path of the cgroup relative to the cgroup root
+	Path() string
+	// CreateChild creates the child cgroup, it fails if the child exists, which may be owned by others
+	CreateChild(name string) (Manager, error)
+	// EnableControllers enables the controllers for the children, the v1 only checks they are mounted
+	EnableControllers(controllers ...string) error
+	// SetCPUMax limits the cpu time to the quota in every period, the quota <= 0 means unlimited
+	SetCPUMax(quota, period int64) error
+	// SetMemoryMax limits the memory in bytes, the limit <= 0 means unlimited
+	SetMemoryMax(limit int64) error
+	// SetIOMax limits the io of the block device, such as 8:0
+	SetIOMax(device string, max IOMax) error
+	// SetPidsMax limits the task count, the limit <= 0 means unlimited
+	SetPidsMax(limit int64) error
+	// AttachPid moves the process into the cgroup
+	AttachPid(pid int) error
+	// Pids returns the processes in the cgroup and its children
+	Pids() ([]int, error)
+	// Kill kills the processes in the cgroup and its children
+	Kill() error
+	// Delete kills the processes, waits for the cgroup to be empty and removes it with its children
+	Delete() error
+}
@@ -0,0 +1,502 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+)
+
+const (
+	_cgroupProcsFile = "cgroup.procs"
+	// _emptyTimeout is how long Delete waits for the killed processes to exit
+	_emptyTimeout  = 5 * time.Second
+	_emptyInterval = 50 * time.Millisecond
+)
+
+// _v1Hierarchies are the controllers of the cgroup v1 which the manager changes, each of them is a hierarchy
+// under the cgroup root, or a symlink to the hierarchy in which it is co-mounted with others
+var _v1Hierarchies = []string{"cpu", "cpuacct", "cpuset", "memory", "blkio", "pids", "freezer"}
+
+// removeDir removes the empty cgroup, the control files in it don't prevent the removal.
+// It's replaced by the tests running on the fake cgroup filesystem.
+var removeDir = os.Remove
+
+// NewManager returns the manager of the existing cgroup, the cgroup root is detected if it's empty
+func NewManager(ctx context.Context, cgroupRoot, path string) (Manager, error) {
+	cgroupRoot = ResolveCGroupRoot(ctx, cgroupRoot)
+	path = filepath.Join("/", path)
+	if DetectCGroupVersion(ctx, cgroupRoot) == CGroupV2 {
+		dir := filepath.Join(cgroupRoot, path)
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+		return &v2Manager{root: cgroupRoot, path: path}, nil
+	}
+	// the co-mounted controllers such as cpu,cpuacct share one hierarchy by the symlinks
+	m := &v1Manager{root: cgroupRoot, path: path, controllers: make(map[string]string)}
+	mounts := make(map[string]string)
+	for _, controller := range _v1Hierarchies {
+		mount, err := filepath.EvalSymlinks(filepath.Join(cgroupRoot, controller))
+		if err != nil {
+			continue
+		}
+		if hierarchy, ok := mounts[mount]; ok {
+			m.controllers[controller] = hierarchy
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(mount, path)); err == nil && info.IsDir() {
+			mounts[mount] = controller
+			m.controllers[controller] = controller
+			m.hierarchies = append(m.hierarchies, controller)
+		}
+	}
+	if len(m.hierarchies) == 0 {
+		return nil, fmt.Errorf("the cgroup %s is not found in any hierarchy under %s", path, cgroupRoot)
+	}
+	return m, nil
+}
+
+// checkChildName refuses the names which escape from the cgroup
+func checkChildName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("illegal cgroup name %q", name)
+	}
+	return nil
+}
+
+func writeControlFile(dir, file, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("write %q to %s failed, %w", value, filepath.Join(dir, file), err)
+	}
+	return nil
+}
+
+// subtreePids returns the processes in the cgroup directory and its children
+func subtreePids(dir string) ([]int, error) {
+	pids := make([]int, 0)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// the child is removed during walking
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		bytes, err := os.ReadFile(filepath.Join(path, _cgroupProcsFile))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		for _, field := range strings.Fields(string(bytes)) {
+			if pid, err := strconv.Atoi(field); err == nil {
+				pids = append(pids, pid)
+			}
+		}
+		return nil
+	})
+	return pids, err
+}
+
+// killSubtree sends SIGKILL to the processes in the cgroup directory and its children
+func killSubtree(dir string) error {
+	pids, err := subtreePids(dir)
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("kill the process %d in %s failed, %w", pid, dir, err)
+		}
+	}
+	return nil
+}
+
+// waitEmpty waits for the killed processes to exit, the cgroup can't be removed until then
+func waitEmpty(dir string) error {
+	deadline := time.Now().Add(_emptyTimeout)
+	for {
+		pids, err := subtreePids(dir)
+		if err != nil {
+			return err
+		}
+		if len(pids) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the processes %v in %s don't exit in %s", pids, dir, _emptyTimeout)
+		}
+		time.Sleep(_emptyInterval)
+	}
+}
+
+// removeSubtree removes the children before the parent, the deepest ones first
+func removeSubtree(dir string) error {
+	dirs := make([]string, 0)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], string(filepath.Separator)) > strings.Count(dirs[j], string(filepath.Separator))
+	})
+	for _, d := range dirs {
+		if err := removeDir(d); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove the cgroup %s failed, %w", d, err)
+		}
+	}
+	return nil
+}
+
+// v2Manager manages the cgroup in the unified hierarchy
+type v2Manager struct {
+	root string
+	path string
+}
+
+func (m *v2Manager) dir() string {
+	return filepath.Join(m.root, m.path)
+}
+
+func (m *v2Manager) Path() string {
+	return m.path
+}
+
+func (m *v2Manager) CreateChild(name string) (Manager, error) {
+	if err := checkChildName(name); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(filepath.Join(m.dir(), name), 0755); err != nil {
+		return nil, err
+	}
+	return &v2Manager{root: m.root, path: filepath.Join(m.path, name)}, nil
+}
+
+func (m *v2Manager) EnableControllers(controllers ...string) error {
+	bytes, err := os.ReadFile(filepath.Join(m.dir(), "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	available := strings.Fields(string(bytes))
+	values := make([]string, 0, len(controllers))
+	for _, controller := range controllers {
+		found := false
+		for _, a := range available {
+			if a == controller {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("the %s controller is not available in %s", controller, m.dir())
+		}
+		values = append(values, "+"+controller)
+	}
+	return writeControlFile(m.dir(), "cgroup.subtree_control", strings.Join(values, " "))
+}
+
+func (m *v2Manager) SetCPUMax(quota, period int64) error {
+	return writeControlFile(m.dir(), "cpu.max", fmt.Sprintf("%s %d", limitValue(quota, "max"), period))
+}
+
+func (m *v2Manager) SetMemoryMax(limit int64) error {
+	return writeControlFile(m.dir(), "memory.max", limitValue(limit, "max"))
+}
+
+// SetIOMax enables the io controller in the parent first if io.max is absent, which is created by the kernel
+// only after the controller is enabled in the cgroup.subtree_control of the parent
+func (m *v2Manager) SetIOMax(device string, max IOMax) error {
+	if _, err := os.Stat(filepath.Join(m.dir(), CGroupV2IOMaxFile)); errors.Is(err, fs.ErrNotExist) {
+		if err := m.enableInParent(CGroupV2IOController); err != nil {
+			return err
+		}
+	}
+	return writeControlFile(m.dir(), CGroupV2IOMaxFile, formatIOMax(device, max))
+}
+
+// enableInParent enables the controller for the cgroup in the cgroup.subtree_control of the parent
+func (m *v2Manager) enableInParent(controller string) error {
+	if m.path == "/" {
+		return fmt.Errorf("the %s controller can't be enabled for the root cgroup %s", controller, m.dir())
+	}
+	parent := &v2Manager{root: m.root, path: filepath.Dir(m.path)}
+	if err := parent.EnableControllers(controller); err != nil {
+		return err
+	}
+	log.Infof(context.Background(), "enabled the %s controller in %s for %s", controller, parent.dir(), m.dir())
+	return nil
+}
+
+func (m *v2Manager) SetPidsMax(limit int64) error {
+	return writeControlFile(m.dir(), "pids.max", limitValue(limit, "max"))
+}
+
+func (m *v2Manager) AttachPid(pid int) error {
+	return writeControlFile(m.dir(), _cgroupProcsFile, strconv.Itoa(pid))
+}
+
+func (m *v2Manager) Pids() ([]int, error) {
+	return subtreePids(m.dir())
+}
+
+// Kill uses cgroup.kill if the kernel supports it, otherwise the processes are frozen before killing,
+// so that none of them can fork any more
+func (m *v2Manager) Kill() error {
+	if _, err := os.Stat(filepath.Join(m.dir(), "cgroup.kill")); err == nil {
+		return writeControlFile(m.dir(), "cgroup.kill", "1")
+	}
+	if err := writeControlFile(m.dir(), "cgroup.freeze", "1"); err != nil {
+		log.Warnf(context.Background(), "freeze the cgroup %s failed, %v", m.dir(), err)
+	}
+	err := killSubtree(m.dir())
+	if thawErr := writeControlFile(m.dir(), "cgroup.freeze", "0"); thawErr != nil {
+		log.Warnf(context.Background(), "thaw the cgroup %s failed, %v", m.dir(), thawErr)
+	}
+	return err
+}
+
+func (m *v2Manager) Delete() error {
+	if err := m.Kill(); err != nil {
+		return err
+	}
+	if err := waitEmpty(m.dir()); err != nil {
+		return err
+	}
+	return removeSubtree(m.dir())
+}
+
+// v1Manager manages the cgroup of the same path in the mounted hierarchies
+type v1Manager struct {
+	root        string
+	path        string
+	hierarchies []string
+	// controllers maps the controllers to the hierarchies which they are mounted in
+	controllers map[string]string
+}
+
+func (m *v1Manager) dir(hierarchy string) string {
+	return filepath.Join(m.root, hierarchy, m.path)
+}
+
+// controllerDir returns the cgroup in the hierarchy of the controller, it fails if the hierarchy isn't mounted
+func (m *v1Manager) controllerDir(controller string) (string, error) {
+	if hierarchy, ok := m.controllers[controller]; ok {
+		return m.dir(hierarchy), nil
+	}
+	return "", fmt.Errorf("the %s controller is not mounted under %s", controller, m.root)
+}
+
+func (m *v1Manager) Path() string {
+	return m.path
+}
+
+func (m *v1Manager) CreateChild(name string) (Manager, error) {
+	if err := checkChildName(name); err != nil {
+		return nil, err
+	}
+	child := &v1Manager{root: m.root, path: filepath.Join(m.path, name), controllers: m.controllers}
+	for _, hierarchy := range m.hierarchies {
+		if err := os.Mkdir(child.dir(hierarchy), 0755); err != nil {
+			// the partial child is removed, it's empty
+			for _, created := range child.hierarchies {
+				removeDir(child.dir(created))
+			}
+			return nil, err
+		}
+		child.hierarchies = append(child.hierarchies, hierarchy)
+		if hierarchy == m.controllers["cpuset"] {
+			if err := inheritCpuset(m.dir(hierarchy), child.dir(hierarchy)); err != nil {
+				child.Delete()
+				return nil, err
+			}
+		}
+	}
+	return child, nil
+}
+
+// inheritCpuset copies the cpus and mems of the parent, no process can be attached to the cpuset cgroup
+// of the v1 until they are set
+func inheritCpuset(parent, child string) error {
+	for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+		bytes, err := os.ReadFile(filepath.Join(parent, file))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if err := writeControlFile(child, file, strings.TrimSpace(string(bytes))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *v1Manager) EnableControllers(controllers ...string) error {
+	for _, controller := range controllers {
+		if _, err := m.controllerDir(controller); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *v1Manager) SetCPUMax(quota, period int64) error {
+	dir, err := m.controllerDir("cpu")
+	if err != nil {
+		return err
+	}
+	if err := writeControlFile(dir, "cpu.cfs_period_us", strconv.FormatInt(period, 10)); err != nil {
+		return err
+	}
+	return writeControlFile(dir, "cpu.cfs_quota_us", limitValue(quota, "-1"))
+}
+
+func (m *v1Manager) SetMemoryMax(limit int64) error {
+	dir, err := m.controllerDir("memory")
+	if err != nil {
+		return err
+	}
+	return writeControlFile(dir, "memory.limit_in_bytes", limitValue(limit, "-1"))
+}
+
+// SetIOMax writes the throttle files of blkio, the limit 0 removes the throttle of the device
+func (m *v1Manager) SetIOMax(device string, max IOMax) error {
+	dir, err := m.controllerDir("blkio")
+	if err != nil {
+		return err
+	}
+	return writeBlkioThrottle(dir, device, max)
+}
+
+// writeBlkioThrottle writes the read and write throttle files of blkio for the device
+func writeBlkioThrottle(dir, device string, max IOMax) error {
+	limits := []struct {
+		file  string
+		value int64
+	}{
+		{_blkioReadBpsParam, max.ReadBps},
+		{_blkioWriteBpsParam, max.WriteBps},
+		{_blkioReadIopsParam, max.ReadIops},
+		{_blkioWriteIopsParam, max.WriteIops},
+	}
+	for _, limit := range limits {
+		if err := writeControlFile(dir, limit.file, fmt.Sprintf("%s %s", device, limitValue(limit.value, "0"))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *v1Manager) SetPidsMax(limit int64) error {
+	dir, err := m.controllerDir("pids")
+	if err != nil {
+		return err
+	}
+	return writeControlFile(dir, "pids.max", limitValue(limit, "max"))
+}
+
+func (m *v1Manager) AttachPid(pid int) error {
+	for _, hierarchy := range m.hierarchies {
+		if err := writeControlFile(m.dir(hierarchy), _cgroupProcsFile, strconv.Itoa(pid)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pids returns the processes in any hierarchy, the process attached by others may be in some of them only
+func (m *v1Manager) Pids() ([]int, error) {
+	seen := make(map[int]bool)
+	pids := make([]int, 0)
+	for _, hierarchy := range m.hierarchies {
+		hierarchyPids, err := subtreePids(m.dir(hierarchy))
+		if err != nil {
+			return nil, err
+		}
+		for _, pid := range hierarchyPids {
+			if !seen[pid] {
+				seen[pid] = true
+				pids = append(pids, pid)
+			}
+		}
+	}
+	sort.Ints(pids)
+	return pids, nil
+}
+
+// Kill freezes the processes before killing if the freezer is mounted, otherwise they are killed
+// in every hierarchy, the processes forked during the killing are left to Delete
+func (m *v1Manager) Kill() error {
+	freezer, err := m.controllerDir("freezer")
+	if err == nil {
+		if err := writeControlFile(freezer, "freezer.state", "FROZEN"); err != nil {
+			log.Warnf(context.Background(), "freeze the cgroup %s failed, %v", freezer, err)
+		}
+		defer func() {
+			if err := writeControlFile(freezer, "freezer.state", "THAWED"); err != nil {
+				log.Warnf(context.Background(), "thaw the cgroup %s failed, %v", freezer, err)
+			}
+		}()
+	}
+	for _, hierarchy := range m.hierarchies {
+		if err := killSubtree(m.dir(hierarchy)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete kills the processes again while waiting, because they may fork without the freezer
+func (m *v1Manager) Delete() error {
+	deadline := time.Now().Add(_emptyTimeout)
+	for {
+		if err := m.Kill(); err != nil {
+			return err
+		}
+		pids, err := m.Pids()
+		if err != nil {
+			return err
+		}
+		if len(pids) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the processes %v in %s don't exit in %s", pids, m.path, _emptyTimeout)
+		}
+		time.Sleep(_emptyInterval)
+	}
+	for _, hierarchy := range m.hierarchies {
+		if err := removeSubtree(m.dir(hierarchy)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fakeRemoveDir(t *testing.T) {
+	remove := removeDir
+	removeDir = os.RemoveAll
+	t.Cleanup(func() { removeDir = remove })
+}
+
+func writeFakeFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes) != want {
+		t.Errorf("%s = %q, want %q", path, string(bytes), want)
+	}
+}
+
+func TestManagerV2Fake(t *testing.T) {
+	fakeRemoveDir(t)
+	root := t.TempDir()
+	writeFakeFile(t, filepath.Join(root, "cgroup.controllers"), "cpuset cpu io memory pids\n")
+	ctx := context.Background()
+	m, err := NewManager(ctx, root, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.EnableControllers("cpu", "memory"); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(root, "cgroup.subtree_control"), "+cpu +memory")
+	if err := m.EnableControllers("rdma"); err == nil {
+		t.Errorf("EnableControllers(rdma) succeeded, want error")
+	}
+	for _, name := range []string{"", "..", "a/b"} {
+		if _, err := m.CreateChild(name); err == nil {
+			t.Errorf("CreateChild(%q) succeeded, want error", name)
+		}
+	}
+	child, err := m.CreateChild("chaos-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CreateChild("chaos-1"); err == nil {
+		t.Errorf("CreateChild of the existing cgroup succeeded, want error")
+	}
+	dir := filepath.Join(root, "chaos-1")
+	if child.Path() != "/chaos-1" {
+		t.Errorf("Path() = %s, want /chaos-1", child.Path())
+	}
+	steps := []struct {
+		set  func() error
+		file string
+		want string
+	}{
+		{func() error { return child.SetCPUMax(50000, 100000) }, "cpu.max", "50000 100000"},
+		{func() error { return child.SetCPUMax(0, 100000) }, "cpu.max", "max 100000"},
+		{func() error { return child.SetMemoryMax(1 << 20) }, "memory.max", "1048576"},
+		{func() error { return child.SetIOMax("8:0", IOMax{WriteBps: 1024}) }, "io.max", "8:0 rbps=max wbps=1024 riops=max wiops=max"},
+		{func() error { return child.SetPidsMax(-1) }, "pids.max", "max"},
+		{func() error { return child.AttachPid(123) }, "cgroup.procs", "123"},
+	}
+	for _, step := range steps {
+		if err := step.set(); err != nil {
+			t.Fatal(err)
+		}
+		assertFile(t, filepath.Join(dir, step.file), step.want)
+	}
+	if pids, err := child.Pids(); err != nil || len(pids) != 1 || pids[0] != 123 {
+		t.Errorf("Pids() = %v, %v, want [123]", pids, err)
+	}
+
+	// the processes exit after cgroup.kill
+	writeFakeFile(t, filepath.Join(dir, "cgroup.kill"), "")
+	writeFakeFile(t, filepath.Join(dir, "cgroup.procs"), "")
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := child.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("the cgroup %s is not removed, %v", dir, err)
+	}
+}
+
+func TestManagerV1Fake(t *testing.T) {
+	fakeRemoveDir(t)
+	root := t.TempDir()
+	for _, hierarchy := range []string{"cpu,cpuacct", "memory", "pids", "blkio"} {
+		if err := os.Mkdir(filepath.Join(root, hierarchy), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, link := range []string{"cpu", "cpuacct"} {
+		if err := os.Symlink("cpu,cpuacct", filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	m, err := NewManager(ctx, root, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.EnableControllers("cpu", "cpuacct", "memory"); err != nil {
+		t.Errorf("EnableControllers of the mounted controllers failed, %v", err)
+	}
+	if err := m.EnableControllers("freezer"); err == nil {
+		t.Errorf("EnableControllers(freezer) succeeded, want error")
+	}
+	child, err := m.CreateChild("chaos-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		set  func() error
+		file string
+		want string
+	}{
+		{func() error { return child.SetCPUMax(50000, 100000) }, "cpu/chaos-1/cpu.cfs_quota_us", "50000"},
+		{func() error { return child.SetCPUMax(-1, 100000) }, "cpu/chaos-1/cpu.cfs_quota_us", "-1"},
+		{func() error { return child.SetMemoryMax(1 << 20) }, "memory/chaos-1/memory.limit_in_bytes", "1048576"},
+		{func() error { return child.SetIOMax("8:0", IOMax{ReadIops: 100}) }, "blkio/chaos-1/blkio.throttle.read_iops_device", "8:0 100"},
+		{func() error { return child.SetIOMax("8:0", IOMax{}) }, "blkio/chaos-1/blkio.throttle.read_iops_device", "8:0 0"},
+		{func() error { return child.SetPidsMax(10) }, "pids/chaos-1/pids.max", "10"},
+		{func() error { return child.AttachPid(123) }, "memory/chaos-1/cgroup.procs", "123"},
+	}
+	for _, step := range steps {
+		if err := step.set(); err != nil {
+			t.Fatal(err)
+		}
+		assertFile(t, filepath.Join(root, step.file), step.want)
+	}
+	assertFile(t, filepath.Join(root, "cpu,cpuacct/chaos-1/cgroup.procs"), "123")
+
+	for _, hierarchy := range []string{"cpu,cpuacct", "memory", "pids", "blkio"} {
+		writeFakeFile(t, filepath.Join(root, hierarchy, "chaos-1", "cgroup.procs"), "")
+	}
+	if err := child.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	for _, hierarchy := range []string{"cpu,cpuacct", "memory", "pids", "blkio"} {
+		if _, err := os.Stat(filepath.Join(root, hierarchy, "chaos-1")); !os.IsNotExist(err) {
+			t.Errorf("the cgroup in %s is not removed, %v", hierarchy, err)
+		}
+	}
+}
+
+// TestManagerSubtree runs on the real cgroup filesystem, it's skipped if the cgroup can't be created
+func TestManagerSubtree(t *testing.T) {
+	ctx := context.Background()
+	m, err := NewManager(ctx, "", "/")
+	if err != nil {
+		t.Skipf("the cgroup root is not available, %v", err)
+	}
+	child, err := m.CreateChild(fmt.Sprintf("chaosblade-test-%d", os.Getpid()))
+	if err != nil {
+		t.Skipf("create the cgroup failed, %v", err)
+	}
+	cmd := exec.Command("/bin/sh", "-c", "sleep 60 & sleep 60")
+	if err := cmd.Start(); err != nil {
+		child.Delete()
+		t.Fatal(err)
+	}
+	if err := child.AttachPid(cmd.Process.Pid); err != nil {
+		cmd.Process.Kill()
+		child.Delete()
+		t.Fatal(err)
+	}
+	if pids, err := child.Pids(); err != nil || len(pids) == 0 {
+		t.Errorf("Pids() = %v, %v, want the attached process", pids, err)
+	}
+	if err := child.Delete(); err != nil {
+		cmd.Process.Kill()
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err == nil || !strings.Contains(err.Error(), "killed") {
+		t.Errorf("the process in the cgroup exits with %v, want killed", err)
+	}
+}
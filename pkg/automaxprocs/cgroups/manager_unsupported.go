@@ -0,0 +1,14 @@
+//go:build !linux
+
+package cgroups
+
+import (
+	"context"
+	"errors"
+)
+
+// NewManager returns the manager of the cgroup
+// cgroups are only available on Linux, so this function returns an error
+func NewManager(ctx context.Context, cgroupRoot, path string) (Manager, error) {
+	return nil, errors.New("cgroups are only available on Linux")
+}
@@ -0,0 +1,162 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+)
+
+// CGroupRoot is the detected cgroup root and the mount which it's detected from
+type CGroupRoot struct {
+	Path    string
+	Version CGroupVersion
+	// Evidence describes why the root is chosen, it's logged for the users who doubt the choice
+	Evidence string
+}
+
+// mountInfoSource is a mountinfo file, the mount points in it are visible under the prefix
+type mountInfoSource struct {
+	path   string
+	prefix string
+}
+
+// rootCandidate is a cgroup mount, the root of the v1 hierarchies is the parent of the mount point
+type rootCandidate struct {
+	path     string
+	version  CGroupVersion
+	fsRoot   string
+	evidence string
+	// host is the mount in the mount namespace of the pid 1, which chaosblade doesn't share in the container
+	host bool
+}
+
+// _v1Controllers are the controllers which the experiments read, the named hierarchies such as
+// name=systemd don't make a cgroup v1 root
+var _v1Controllers = map[string]bool{
+	"cpu":     true,
+	"cpuacct": true,
+	"memory":  true,
+	"pids":    true,
+	"blkio":   true,
+	"freezer": true,
+}
+
+var (
+	detectedRoot     *CGroupRoot
+	detectedRootOnce sync.Once
+)
+
+// ResolveCGroupRoot returns the cgroup root of the --cgroup-root flag, the one of the FSRoot of the context and
+// the detected one are used in order if it's absent
+func ResolveCGroupRoot(ctx context.Context, cgroupRoot string) string {
+	if cgroupRoot != "" {
+		return cgroupRoot
+	}
+	if root := GetFSRoot(ctx).CGroupRoot; root != "" {
+		return root
+	}
+	return DetectCGroupRoot(ctx).Path
+}
+
+// DetectCGroupRoot finds the cgroup v1 hierarchies and the v2 unified mount in /proc/self/mountinfo, and in
+// /proc/1/mountinfo if chaosblade runs in another mount namespace, such as the privileged container with the
+// host pid namespace. The mount of the whole hierarchy is preferred to the one of a nested cgroup, and the host
+// mount is preferred to the one of the container. The default /sys/fs/cgroup is used if nothing is found.
+func DetectCGroupRoot(ctx context.Context) *CGroupRoot {
+	detectedRootOnce.Do(func() {
+		sources := []mountInfoSource{{path: "/proc/self/mountinfo"}}
+		if self, err := os.Readlink("/proc/self/ns/mnt"); err == nil {
+			if init, err := os.Readlink("/proc/1/ns/mnt"); err == nil && init != self {
+				sources = append(sources, mountInfoSource{path: "/proc/1/mountinfo", prefix: "/proc/1/root"})
+			}
+		}
+		detectedRoot = detectCGroupRoot(ctx, sources)
+		log.Infof(ctx, "use the cgroup root %s, evidence: %s", detectedRoot.Path, detectedRoot.Evidence)
+	})
+	return detectedRoot
+}
+
+func detectCGroupRoot(ctx context.Context, sources []mountInfoSource) *CGroupRoot {
+	var v1, v2 []rootCandidate
+	for i, source := range sources {
+		mountPoints, err := cachedMountPoints(source.path)
+		if err != nil {
+			log.Warnf(ctx, "read the cgroup mounts in %s failed, %v", source.path, err)
+		}
+		for _, mp := range mountPoints {
+			candidate := rootCandidate{
+				fsRoot: mp.Root,
+				host:   i > 0,
+				evidence: fmt.Sprintf("%s mount %s of the cgroup %s in %s",
+					mp.FSType, mp.MountPoint, mp.Root, source.path),
+			}
+			switch mp.FSType {
+			case CGroupV2FS:
+				candidate.path, candidate.version = filepath.Join(source.prefix, mp.MountPoint), CGroupV2
+				v2 = append(v2, candidate)
+			case CGroupV1FS:
+				for _, opt := range mp.SuperOptions {
+					if _v1Controllers[opt] {
+						candidate.path, candidate.version = filepath.Join(source.prefix, filepath.Dir(mp.MountPoint)), CGroupV1
+						v1 = append(v1, candidate)
+						break
+					}
+				}
+			}
+		}
+	}
+	// the v2 mount on the hybrid system is an extra hierarchy, such as /sys/fs/cgroup/unified
+	candidates := v1
+	if len(candidates) == 0 {
+		candidates = v2
+	}
+	var best *rootCandidate
+	bestScore := -1
+	for i := range candidates {
+		candidate := &candidates[i]
+		if candidate.host {
+			if _, err := os.Stat(candidate.path); err != nil {
+				log.Debugf(ctx, "the host cgroup root %s is not accessible, %v", candidate.path, err)
+				continue
+			}
+		}
+		score := 0
+		if candidate.fsRoot == "/" {
+			score += 2
+		}
+		if candidate.host {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	if best == nil {
+		return &CGroupRoot{
+			Path:     CGroupV2UnifiedMount,
+			Version:  CGroupUnknown,
+			Evidence: "no cgroup mount is found, use the default",
+		}
+	}
+	evidence := best.evidence
+	if best.fsRoot != "/" {
+		evidence += ", only the nested cgroup is mounted"
+	}
+	version := best.version
+	if version == CGroupV1 {
+		for _, unified := range v2 {
+			if unified.host == best.host && isUnderPath(unified.path, best.path) {
+				version = CGroupHybrid
+				evidence += fmt.Sprintf(", the cgroup v2 hierarchy is mounted at %s", unified.path)
+				break
+			}
+		}
+	}
+	return &CGroupRoot{Path: best.path, Version: version, Evidence: evidence}
+}
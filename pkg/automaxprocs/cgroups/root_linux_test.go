@@ -0,0 +1,104 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeMountInfo(t *testing.T, lines ...string) string {
+	path := filepath.Join(t.TempDir(), "mountinfo")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_detectCGroupRoot(t *testing.T) {
+	const (
+		proc   = "22 1 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw"
+		v2     = "30 23 0:26 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:4 - cgroup2 cgroup2 rw,nsdelegate"
+		v1CPU  = "34 25 0:29 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid,nodev,noexec,relatime shared:9 - cgroup cgroup rw,cpu,cpuacct"
+		v1Mem  = "35 25 0:30 / /sys/fs/cgroup/memory rw,nosuid,nodev,noexec,relatime shared:10 - cgroup cgroup rw,memory"
+		v1Name = "26 25 0:23 / /sys/fs/cgroup/systemd rw,nosuid,nodev,noexec,relatime shared:5 - cgroup cgroup rw,xattr,name=systemd"
+		hybrid = "27 25 0:24 / /sys/fs/cgroup/unified rw,nosuid,nodev,noexec,relatime shared:6 - cgroup2 cgroup2 rw,nsdelegate"
+		// the container sees its own cgroup only, the host cgroupfs is mounted at /host-sys/fs/cgroup
+		nestedCPU = "1100 1090 0:29 /kubepods/pod1/abc /sys/fs/cgroup/cpu,cpuacct ro,nosuid,nodev,noexec,relatime master:9 - cgroup cgroup rw,cpu,cpuacct"
+		hostCPU   = "1200 1090 0:29 / /host-sys/fs/cgroup/cpu,cpuacct rw,nosuid,nodev,noexec,relatime master:9 - cgroup cgroup rw,cpu,cpuacct"
+	)
+	hostRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(hostRoot, "sys/fs/cgroup"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		sources []mountInfoSource
+		want    string
+		version CGroupVersion
+	}{
+		{
+			name:    "unified",
+			sources: []mountInfoSource{{path: writeMountInfo(t, proc, v2)}},
+			want:    "/sys/fs/cgroup",
+			version: CGroupV2,
+		},
+		{
+			name:    "hybrid",
+			sources: []mountInfoSource{{path: writeMountInfo(t, proc, v1Name, hybrid, v1CPU, v1Mem)}},
+			want:    "/sys/fs/cgroup",
+			version: CGroupHybrid,
+		},
+		{
+			name:    "host cgroupfs mounted in the container",
+			sources: []mountInfoSource{{path: writeMountInfo(t, proc, nestedCPU, hostCPU)}},
+			want:    "/host-sys/fs/cgroup",
+			version: CGroupV1,
+		},
+		{
+			name: "host mount namespace of the pid 1",
+			sources: []mountInfoSource{
+				{path: writeMountInfo(t, proc, v2)},
+				{path: writeMountInfo(t, proc, v2), prefix: hostRoot},
+			},
+			want:    filepath.Join(hostRoot, "sys/fs/cgroup"),
+			version: CGroupV2,
+		},
+		{
+			name: "inaccessible host mount namespace",
+			sources: []mountInfoSource{
+				{path: writeMountInfo(t, proc, v2)},
+				{path: writeMountInfo(t, proc, v2), prefix: filepath.Join(hostRoot, "absent")},
+			},
+			want:    "/sys/fs/cgroup",
+			version: CGroupV2,
+		},
+		{
+			name:    "no cgroup mount",
+			sources: []mountInfoSource{{path: writeMountInfo(t, proc)}, {path: "/nonexistent/mountinfo"}},
+			want:    "/sys/fs/cgroup",
+			version: CGroupUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectCGroupRoot(context.Background(), tt.sources)
+			if got.Path != tt.want || got.Version != tt.version || got.Evidence == "" {
+				t.Errorf("detectCGroupRoot() = %+v, want %s, version %v", got, tt.want, tt.version)
+			}
+		})
+	}
+}
+
+func TestResolveCGroupRoot(t *testing.T) {
+	ctx := context.Background()
+	if got := ResolveCGroupRoot(ctx, "/host-sys/fs/cgroup"); got != "/host-sys/fs/cgroup" {
+		t.Errorf("ResolveCGroupRoot() = %s, want the flag value", got)
+	}
+	if got := ResolveCGroupRoot(ctx, ""); got != DetectCGroupRoot(ctx).Path {
+		t.Errorf("ResolveCGroupRoot() = %s, want the detected %s", got, DetectCGroupRoot(ctx).Path)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+package cgroups
+
+import "context"
+
+// CGroupRoot is the detected cgroup root and the mount which it's detected from
+type CGroupRoot struct {
+	Path     string
+	Version  CGroupVersion
+	Evidence string
+}
+
+// ResolveCGroupRoot returns the cgroup root of the --cgroup-root flag, or the default if it's absent
+// cgroups are only available on Linux, so nothing is detected
+func ResolveCGroupRoot(ctx context.Context, cgroupRoot string) string {
+	if cgroupRoot != "" {
+		return cgroupRoot
+	}
+	if root := GetFSRoot(ctx).CGroupRoot; root != "" {
+		return root
+	}
+	return CGroupV2UnifiedMount
+}
+
+// DetectCGroupRoot returns the default cgroup root
+// cgroups are only available on Linux, so nothing is detected
+func DetectCGroupRoot(ctx context.Context) *CGroupRoot {
+	return &CGroupRoot{Path: CGroupV2UnifiedMount, Version: CGroupUnknown, Evidence: "cgroups are only available on Linux"}
+}
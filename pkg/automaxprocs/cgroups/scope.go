@@ -0,0 +1,42 @@
+package cgroups
+
+import (
+	"context"
+	"fmt"
+)
+
+// CGroupScopeKey is the flag and the context key of the cgroup which the usage of the target is measured in
+const CGroupScopeKey = "cgroup-scope"
+
+const (
+	// CGroupScopeLeaf measures the cgroup of the target process itself
+	CGroupScopeLeaf = "leaf"
+	// CGroupScopeLimitOwner measures the nearest ancestor of the cgroup of the target process which owns the
+	// limit, such as the service slice of the per-connection scope, the cgroup itself is used if no one owns it
+	CGroupScopeLimitOwner = "limit-owner"
+)
+
+// CheckCGroupScope checks the value of the cgroup-scope flag, the empty one is the default
+func CheckCGroupScope(scope string) error {
+	switch scope {
+	case "", CGroupScopeLeaf, CGroupScopeLimitOwner:
+		return nil
+	}
+	return fmt.Errorf("it must be %s or %s", CGroupScopeLeaf, CGroupScopeLimitOwner)
+}
+
+// WithCGroupScope returns the context with the cgroup scope, the context is returned as is if it's empty
+func WithCGroupScope(ctx context.Context, scope string) context.Context {
+	if scope == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, CGroupScopeKey, scope)
+}
+
+// GetCGroupScope returns the cgroup scope of the context, it's CGroupScopeLimitOwner by default
+func GetCGroupScope(ctx context.Context) string {
+	if scope, _ := ctx.Value(CGroupScopeKey).(string); scope != "" {
+		return scope
+	}
+	return CGroupScopeLimitOwner
+}
@@ -0,0 +1,140 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+)
+
+const (
+	// _cgroupSubsysMemory is the memory CGroup subsystem.
+	_cgroupSubsysMemory            = "memory"
+	_cgroupMemoryLimitParam        = "memory.limit_in_bytes"
+	_cgroupMemoryUsageParam        = "memory.usage_in_bytes"
+	_cgroupMemoryUseHierarchyParam = "memory.use_hierarchy"
+	// _v1MemoryUnlimited is the lower bound of the unlimited memory.limit_in_bytes, which is the page counter
+	// max rounded down to the page size, such as 9223372036854771712
+	_v1MemoryUnlimited = int64(1) << 62
+)
+
+// ScopedCGroupV2Path returns the cgroup v2 path which the usage of the controller is measured in by the cgroup
+// scope of the context, see CGroupScopeLimitOwner
+func ScopedCGroupV2Path(ctx context.Context, path, controller string) string {
+	if GetCGroupScope(ctx) != CGroupScopeLimitOwner {
+		return path
+	}
+	owner := LimitOwnerV2(path, controller)
+	if owner != path {
+		log.Infof(ctx, "the %s limit of %s is owned by %s, measure there", controller, path, owner)
+	}
+	return owner
+}
+
+// LimitOwnerV2 returns the nearest cgroup of the path and its ancestors whose limit of the controller is set,
+// which is cpu.max for cpu and memory.max or memory.high for memory. The path itself is returned if no one owns it.
+func LimitOwnerV2(path, controller string) string {
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		// the parent of the root cgroup is out of the cgroup filesystem
+		if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err != nil {
+			return path
+		}
+		if ownsLimitV2(dir, controller) {
+			return dir
+		}
+		if dir == filepath.Dir(dir) {
+			return path
+		}
+	}
+}
+
+func ownsLimitV2(dir, controller string) bool {
+	cg := NewCGroupV2Impl(dir)
+	switch controller {
+	case CGroupV2CPUController:
+		// the root cgroup has no cpu.max
+		if _, err := os.Stat(filepath.Join(dir, CGroupV2CPUQuotaFile)); err != nil {
+			return false
+		}
+		_, defined, err := cg.CPUQuota()
+		return err == nil && defined
+	case CGroupV2MemoryController:
+		limits, err := cg.MemoryLimits()
+		if err != nil {
+			return false
+		}
+		_, _, defined := limits.Ceiling()
+		return defined
+	}
+	return false
+}
+
+// LimitOwnerV1 returns the nearest cgroup of the path and its ancestors in the cgroup v1 hierarchy whose limit of
+// the controller is set, which is cpu.cfs_quota_us for cpu and memory.limit_in_bytes for memory. The path is
+// relative to the hierarchy, and it's returned if no one owns the limit.
+func LimitOwnerV1(hierarchy, path, controller string) string {
+	for dir := filepath.Join("/", path); ; dir = filepath.Dir(dir) {
+		if ownsLimitV1(filepath.Join(hierarchy, dir), controller) {
+			return dir
+		}
+		if dir == "/" {
+			return path
+		}
+	}
+}
+
+func ownsLimitV1(dir, controller string) bool {
+	switch controller {
+	case _cgroupSubsysCPU:
+		quota, err := readInt64File(filepath.Join(dir, _cgroupCPUCFSQuotaUsParam))
+		return err == nil && quota > 0
+	case _cgroupSubsysMemory:
+		limit, err := readInt64File(filepath.Join(dir, _cgroupMemoryLimitParam))
+		return err == nil && limit < _v1MemoryUnlimited
+	}
+	return false
+}
+
+// MemoryUsageV1 returns the memory usage of the cgroup v1 directory with its descendants, the usage of the
+// cgroup is hierarchical unless memory.use_hierarchy is 0, which is summed up with the descendants then
+func MemoryUsageV1(dir string) (int64, error) {
+	usage, err := readInt64File(filepath.Join(dir, _cgroupMemoryUsageParam))
+	if err != nil {
+		return 0, err
+	}
+	if useHierarchy, err := readInt64File(filepath.Join(dir, _cgroupMemoryUseHierarchyParam)); err != nil || useHierarchy != 0 {
+		return usage, nil
+	}
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// the child is removed during walking
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() || path == dir {
+			return nil
+		}
+		if childUsage, err := readInt64File(filepath.Join(path, _cgroupMemoryUsageParam)); err == nil {
+			usage += childUsage
+		}
+		return nil
+	})
+	return usage, err
+}
+
+func readInt64File(path string) (int64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
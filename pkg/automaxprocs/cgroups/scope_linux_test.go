@@ -0,0 +1,141 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCGroupTree writes the files of the cgroup tree under the temp directory, the paths are relative to it
+func writeCGroupTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestLimitOwnerV2(t *testing.T) {
+	root := writeCGroupTree(t, map[string]string{
+		"cgroup.controllers":                                       "cpu memory\n",
+		"system.slice/cgroup.controllers":                          "cpu memory\n",
+		"system.slice/cpu.max":                                     "max 100000\n",
+		"system.slice/memory.max":                                  "max\n",
+		"system.slice/app.service/cgroup.controllers":              "cpu memory\n",
+		"system.slice/app.service/cpu.max":                         "200000 100000\n",
+		"system.slice/app.service/memory.max":                      "max\n",
+		"system.slice/app.service/memory.high":                     "1073741824\n",
+		"system.slice/app.service/conn-1.scope/cgroup.controllers": "cpu memory\n",
+		"system.slice/app.service/conn-1.scope/cpu.max":            "max 100000\n",
+		"system.slice/app.service/conn-1.scope/memory.max":         "max\n",
+		"system.slice/other.service/cgroup.controllers":            "cpu memory\n",
+		"system.slice/other.service/cpu.max":                       "max 100000\n",
+		"system.slice/other.service/memory.max":                    "max\n",
+	})
+	scope := filepath.Join(root, "system.slice/app.service/conn-1.scope")
+	service := filepath.Join(root, "system.slice/app.service")
+	other := filepath.Join(root, "system.slice/other.service")
+	tests := []struct {
+		name       string
+		path       string
+		controller string
+		want       string
+	}{
+		{name: "cpu limit on the service", path: scope, controller: CGroupV2CPUController, want: service},
+		{name: "memory.high on the service", path: scope, controller: CGroupV2MemoryController, want: service},
+		{name: "the limit owner itself", path: service, controller: CGroupV2CPUController, want: service},
+		{name: "no limit", path: other, controller: CGroupV2CPUController, want: other},
+		{name: "unknown controller", path: scope, controller: "pids", want: scope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LimitOwnerV2(tt.path, tt.controller); got != tt.want {
+				t.Errorf("LimitOwnerV2() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	leaf := ScopedCGroupV2Path(WithCGroupScope(context.Background(), CGroupScopeLeaf), scope, CGroupV2CPUController)
+	if leaf != scope {
+		t.Errorf("ScopedCGroupV2Path(leaf) = %s, want %s", leaf, scope)
+	}
+	if owner := ScopedCGroupV2Path(context.Background(), scope, CGroupV2CPUController); owner != service {
+		t.Errorf("ScopedCGroupV2Path() = %s, want the limit owner %s by default", owner, service)
+	}
+}
+
+func TestLimitOwnerV1(t *testing.T) {
+	root := writeCGroupTree(t, map[string]string{
+		"cpu/cpu.cfs_quota_us":                                  "-1\n",
+		"cpu/kubepods/cpu.cfs_quota_us":                         "-1\n",
+		"cpu/kubepods/pod1/cpu.cfs_quota_us":                    "200000\n",
+		"cpu/kubepods/pod1/container1/cpu.cfs_quota_us":         "-1\n",
+		"memory/memory.limit_in_bytes":                          "9223372036854771712\n",
+		"memory/kubepods/memory.limit_in_bytes":                 "8589934592\n",
+		"memory/kubepods/pod1/memory.limit_in_bytes":            "9223372036854771712\n",
+		"memory/kubepods/pod1/container1/memory.limit_in_bytes": "9223372036854771712\n",
+		"memory/besteffort/memory.limit_in_bytes":               "9223372036854771712\n",
+	})
+	tests := []struct {
+		controller string
+		path       string
+		want       string
+	}{
+		{controller: "cpu", path: "/kubepods/pod1/container1", want: "/kubepods/pod1"},
+		{controller: "memory", path: "/kubepods/pod1/container1", want: "/kubepods"},
+		{controller: "memory", path: "/besteffort", want: "/besteffort"},
+		{controller: "cpu", path: "/", want: "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.controller+tt.path, func(t *testing.T) {
+			if got := LimitOwnerV1(filepath.Join(root, tt.controller), tt.path, tt.controller); got != tt.want {
+				t.Errorf("LimitOwnerV1() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryUsageV1(t *testing.T) {
+	files := map[string]string{
+		"memory.usage_in_bytes":     "100\n",
+		"a/memory.usage_in_bytes":   "20\n",
+		"a/b/memory.usage_in_bytes": "3\n",
+		"c/memory.usage_in_bytes":   "40\n",
+		"c/memory.use_hierarchy":    "1\n",
+		"memory.limit_in_bytes":     "1024\n",
+	}
+	files["memory.use_hierarchy"] = "1\n"
+	if usage, err := MemoryUsageV1(writeCGroupTree(t, files)); err != nil || usage != 100 {
+		t.Errorf("MemoryUsageV1() = (%d, %v), want the hierarchical usage 100", usage, err)
+	}
+	files["memory.use_hierarchy"] = "0\n"
+	if usage, err := MemoryUsageV1(writeCGroupTree(t, files)); err != nil || usage != 163 {
+		t.Errorf("MemoryUsageV1() = (%d, %v), want the sum 163", usage, err)
+	}
+	if _, err := MemoryUsageV1(t.TempDir()); err == nil {
+		t.Errorf("MemoryUsageV1() succeeds without memory.usage_in_bytes")
+	}
+}
+
+func TestCheckCGroupScope(t *testing.T) {
+	for _, scope := range []string{"", CGroupScopeLeaf, CGroupScopeLimitOwner} {
+		if err := CheckCGroupScope(scope); err != nil {
+			t.Errorf("CheckCGroupScope(%q) = %v", scope, err)
+		}
+	}
+	if err := CheckCGroupScope("parent"); err == nil {
+		t.Errorf("CheckCGroupScope(parent) succeeds, want error")
+	}
+	if scope := GetCGroupScope(WithCGroupScope(context.Background(), "")); scope != CGroupScopeLimitOwner {
+		t.Errorf("GetCGroupScope() = %s, want the default %s", scope, CGroupScopeLimitOwner)
+	}
+}
@@ -0,0 +1,195 @@
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CGroupV2CPUStatFile is the CPU statistics file for cgroup v2
+	CGroupV2CPUStatFile = "cpu.stat"
+	// CGroupV2CPUPressureFile is the CPU pressure stall information file for cgroup v2
+	CGroupV2CPUPressureFile = "cpu.pressure"
+	// CGroupV2MemoryPressureFile is the memory pressure stall information file for cgroup v2
+	CGroupV2MemoryPressureFile = "memory.pressure"
+)
+
+// NotAvailableError is returned if the statistics file doesn't exist, because the kernel is too old, the
+// controller isn't enabled or the pressure stall information is disabled
+type NotAvailableError struct {
+	File string
+	Err  error
+}
+
+func (err NotAvailableError) Error() string {
+	return fmt.Sprintf("%s is not available: %v", err.File, err.Err)
+}
+
+func (err NotAvailableError) Unwrap() error {
+	return err.Err
+}
+
+// IsNotAvailable returns true if the err is NotAvailableError
+func IsNotAvailable(err error) bool {
+	var notAvailable NotAvailableError
+	return errors.As(err, &notAvailable)
+}
+
+// CPUStat is the cpu.stat of the cgroup v2, the times are in microseconds. The throttling statistics are zero
+// if the cpu controller isn't enabled for the cgroup.
+type CPUStat struct {
+	UsageUsec     uint64
+	UserUsec      uint64
+	SystemUsec    uint64
+	NrPeriods     uint64
+	NrThrottled   uint64
+	ThrottledUsec uint64
+}
+
+// PressureLine is the some or full line of the pressure file, the averages are percents of the time stalled
+// in the last 10, 60 and 300 seconds, and the total is the stalled time in microseconds
+type PressureLine struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	Total  uint64
+}
+
+// Pressure is the pressure stall information of the cgroup. HasFull is false if the full line is absent,
+// such as the cpu.pressure of the kernels before 5.13.
+type Pressure struct {
+	Some    PressureLine
+	Full    PressureLine
+	HasFull bool
+}
+
+// MemoryValue is the value of the memory control file in bytes, Defined is false if it's max or absent
+type MemoryValue struct {
+	Value   int64
+	Defined bool
+}
+
+// MemoryLimits is the memory limits and the usage of the cgroup v2
+type MemoryLimits struct {
+	Max     MemoryValue
+	High    MemoryValue
+	SwapMax MemoryValue
+	Current MemoryValue
+}
+
+// Ceiling returns the effective memory ceiling of the cgroup and the file it's from, it's the lower one of
+// memory.max and memory.high, because the cgroup above memory.high is throttled and reclaimed heavily
+func (l *MemoryLimits) Ceiling() (int64, string, bool) {
+	switch {
+	case l.High.Defined && (!l.Max.Defined || l.High.Value < l.Max.Value):
+		return l.High.Value, CGroupV2MemoryHighFile, true
+	case l.Max.Defined:
+		return l.Max.Value, CGroupV2MemoryLimitFile, true
+	}
+	return 0, "", false
+}
+
+// parseMemoryValue parses the content of the memory control file, max is undefined
+func parseMemoryValue(content string) (MemoryValue, error) {
+	content = strings.TrimSpace(content)
+	if content == "max" {
+		return MemoryValue{}, nil
+	}
+	value, err := strconv.ParseInt(content, 10, 64)
+	if err != nil {
+		return MemoryValue{}, err
+	}
+	return MemoryValue{Value: value, Defined: true}, nil
+}
+
+// parseCPUStat parses the content of cpu.stat, the malformed lines and the unknown keys are skipped
+func parseCPUStat(content string) (*CPUStat, error) {
+	stat := &CPUStat{}
+	fields := map[string]*uint64{
+		"usage_usec":     &stat.UsageUsec,
+		"user_usec":      &stat.UserUsec,
+		"system_usec":    &stat.SystemUsec,
+		"nr_periods":     &stat.NrPeriods,
+		"nr_throttled":   &stat.NrThrottled,
+		"throttled_usec": &stat.ThrottledUsec,
+	}
+	parsed := 0
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			continue
+		}
+		field, ok := fields[parts[0]]
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		*field = value
+		parsed++
+	}
+	if parsed == 0 {
+		return nil, fmt.Errorf("no statistics in %s", CGroupV2CPUStatFile)
+	}
+	return stat, nil
+}
+
+// parsePressure parses the content of the pressure file, such as
+// some avg10=0.00 avg60=0.00 avg300=0.00 total=0. The malformed lines are skipped, the some line is required.
+func parsePressure(content string) (*Pressure, error) {
+	pressure := &Pressure{}
+	hasSome := false
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+		value, ok := parsePressureLine(parts[1:])
+		if !ok {
+			continue
+		}
+		switch parts[0] {
+		case "some":
+			pressure.Some, hasSome = value, true
+		case "full":
+			pressure.Full, pressure.HasFull = value, true
+		}
+	}
+	if !hasSome {
+		return nil, fmt.Errorf("no some line in the pressure")
+	}
+	return pressure, nil
+}
+
+func parsePressureLine(fields []string) (PressureLine, bool) {
+	var line PressureLine
+	parsed := 0
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return line, false
+		}
+		var err error
+		switch key {
+		case "avg10":
+			line.Avg10, err = strconv.ParseFloat(value, 64)
+		case "avg60":
+			line.Avg60, err = strconv.ParseFloat(value, 64)
+		case "avg300":
+			line.Avg300, err = strconv.ParseFloat(value, 64)
+		case "total":
+			line.Total, err = strconv.ParseUint(value, 10, 64)
+		default:
+			continue
+		}
+		if err != nil {
+			return line, false
+		}
+		parsed++
+	}
+	return line, parsed == 4
+}
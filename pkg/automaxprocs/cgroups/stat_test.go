@@ -0,0 +1,195 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeStatFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCGroupV2Impl_CPUStat(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    CPUStat
+		wantErr bool
+	}{
+		{
+			name: "cpu controller enabled",
+			content: "usage_usec 8713542\nuser_usec 5210031\nsystem_usec 3503511\n" +
+				"nr_periods 1200\nnr_throttled 37\nthrottled_usec 912345\n",
+			want: CPUStat{UsageUsec: 8713542, UserUsec: 5210031, SystemUsec: 3503511,
+				NrPeriods: 1200, NrThrottled: 37, ThrottledUsec: 912345},
+		},
+		{
+			name:    "cpu controller disabled",
+			content: "usage_usec 100\nuser_usec 60\nsystem_usec 40\n",
+			want:    CPUStat{UsageUsec: 100, UserUsec: 60, SystemUsec: 40},
+		},
+		{
+			name:    "malformed lines are skipped",
+			content: "usage_usec\nuser_usec abc\nsystem_usec 40 50\nnr_bursts 3\n\nnr_throttled 2\n",
+			want:    CPUStat{NrThrottled: 2},
+		},
+		{
+			name:    "no statistics",
+			content: "garbage\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := NewCGroupV2Impl(writeStatFiles(t, map[string]string{CGroupV2CPUStatFile: tt.content}))
+			got, err := cg.CPUStat()
+			if tt.wantErr {
+				if err == nil || IsNotAvailable(err) {
+					t.Errorf("CPUStat() = %+v, %v, want the parse error", got, err)
+				}
+				return
+			}
+			if err != nil || *got != tt.want {
+				t.Errorf("CPUStat() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCGroupV2Impl_Pressure(t *testing.T) {
+	cg := NewCGroupV2Impl(writeStatFiles(t, map[string]string{
+		CGroupV2CPUPressureFile: "some avg10=1.50 avg60=0.75 avg300=0.10 total=123456\n" +
+			"full avg10=0.50 avg60=0.25 avg300=0.00 total=4567\n",
+		// the memory pressure with a malformed full line
+		CGroupV2MemoryPressureFile: "some avg10=12.00 avg60=8.00 avg300=2.00 total=99\nfull avg10=x avg60=0 avg300=0 total=0\n",
+	}))
+	pressure, err := cg.Pressure()
+	if err != nil {
+		t.Fatalf("Pressure() failed, %v", err)
+	}
+	want := Pressure{
+		Some:    PressureLine{Avg10: 1.5, Avg60: 0.75, Avg300: 0.1, Total: 123456},
+		Full:    PressureLine{Avg10: 0.5, Avg60: 0.25, Total: 4567},
+		HasFull: true,
+	}
+	if *pressure != want {
+		t.Errorf("Pressure() = %+v, want %+v", pressure, want)
+	}
+
+	memory, err := cg.MemoryPressure()
+	if err != nil {
+		t.Fatalf("MemoryPressure() failed, %v", err)
+	}
+	if memory.Some.Avg10 != 12 || memory.Some.Avg60 != 8 || memory.HasFull {
+		t.Errorf("MemoryPressure() = %+v, want the some line only", memory)
+	}
+}
+
+func TestCGroupV2Impl_NotAvailable(t *testing.T) {
+	cg := NewCGroupV2Impl(t.TempDir())
+	if _, err := cg.CPUStat(); !IsNotAvailable(err) {
+		t.Errorf("CPUStat() err = %v, want NotAvailableError", err)
+	}
+	if _, err := cg.Pressure(); !IsNotAvailable(err) {
+		t.Errorf("Pressure() err = %v, want NotAvailableError", err)
+	}
+	if _, err := cg.MemoryPressure(); !IsNotAvailable(err) {
+		t.Errorf("MemoryPressure() err = %v, want NotAvailableError", err)
+	}
+
+	// the pressure without the some line isn't the missing feature
+	cg = NewCGroupV2Impl(writeStatFiles(t, map[string]string{CGroupV2CPUPressureFile: "bogus\n"}))
+	if _, err := cg.Pressure(); err == nil || IsNotAvailable(err) {
+		t.Errorf("Pressure() err = %v, want the parse error", err)
+	}
+}
+
+func TestCGroupV2Impl_MemoryLimits(t *testing.T) {
+	const gi = 1 << 30
+	files := map[string]string{
+		CGroupV2MemoryLimitFile:   "2147483648\n",
+		CGroupV2MemoryHighFile:    "1073741824\n",
+		CGroupV2MemorySwapMaxFile: "0\n",
+		CGroupV2MemoryCurrentFile: "536870912\n",
+	}
+	cg := NewCGroupV2Impl(writeStatFiles(t, files))
+	limits, err := cg.MemoryLimits()
+	if err != nil {
+		t.Fatalf("MemoryLimits() failed, %v", err)
+	}
+	want := MemoryLimits{
+		Max:     MemoryValue{Value: 2 * gi, Defined: true},
+		High:    MemoryValue{Value: gi, Defined: true},
+		SwapMax: MemoryValue{Value: 0, Defined: true},
+		Current: MemoryValue{Value: gi / 2, Defined: true},
+	}
+	if *limits != want {
+		t.Errorf("MemoryLimits() = %+v, want %+v", limits, want)
+	}
+	if ceiling, source, ok := limits.Ceiling(); !ok || ceiling != gi || source != CGroupV2MemoryHighFile {
+		t.Errorf("Ceiling() = %d, %s, %t, want memory.high", ceiling, source, ok)
+	}
+	if limit, defined, err := cg.MemoryLimit(); err != nil || !defined || limit != 2*gi {
+		t.Errorf("MemoryLimit() = %d, %t, %v, want memory.max", limit, defined, err)
+	}
+
+	// the max sentinel of each file is undefined
+	for _, file := range []string{CGroupV2MemoryLimitFile, CGroupV2MemoryHighFile, CGroupV2MemorySwapMaxFile} {
+		t.Run(file+" max", func(t *testing.T) {
+			sentinel := map[string]string{}
+			for name, content := range files {
+				sentinel[name] = content
+			}
+			sentinel[file] = "max\n"
+			limits, err := NewCGroupV2Impl(writeStatFiles(t, sentinel)).MemoryLimits()
+			if err != nil {
+				t.Fatalf("MemoryLimits() failed, %v", err)
+			}
+			values := map[string]MemoryValue{
+				CGroupV2MemoryLimitFile:   limits.Max,
+				CGroupV2MemoryHighFile:    limits.High,
+				CGroupV2MemorySwapMaxFile: limits.SwapMax,
+			}
+			for name, value := range values {
+				if value.Defined == (name == file) {
+					t.Errorf("%s defined = %t", name, value.Defined)
+				}
+			}
+		})
+	}
+
+	// memory.max is the ceiling if memory.high is max
+	limits = &MemoryLimits{Max: MemoryValue{Value: 2 * gi, Defined: true}}
+	if ceiling, source, ok := limits.Ceiling(); !ok || ceiling != 2*gi || source != CGroupV2MemoryLimitFile {
+		t.Errorf("Ceiling() = %d, %s, %t, want memory.max", ceiling, source, ok)
+	}
+	if _, _, ok := (&MemoryLimits{}).Ceiling(); ok {
+		t.Errorf("Ceiling() of the unlimited cgroup is defined")
+	}
+}
+
+func TestCGroupV2Impl_MemoryLimitsRootCGroup(t *testing.T) {
+	// the root cgroup only has memory.current
+	cg := NewCGroupV2Impl(writeStatFiles(t, map[string]string{CGroupV2MemoryCurrentFile: "1024\n"}))
+	limits, err := cg.MemoryLimits()
+	if err != nil {
+		t.Fatalf("MemoryLimits() failed, %v", err)
+	}
+	if limits.Max.Defined || limits.High.Defined || limits.SwapMax.Defined || limits.Current.Value != 1024 {
+		t.Errorf("MemoryLimits() = %+v, want memory.current only", limits)
+	}
+
+	cg = NewCGroupV2Impl(writeStatFiles(t, map[string]string{CGroupV2MemoryHighFile: "lots\n"}))
+	if _, err := cg.MemoryLimits(); err == nil {
+		t.Errorf("MemoryLimits() of the malformed memory.high succeeded")
+	}
+}
@@ -1,16 +1,32 @@
 package cgroups
 
 import (
+	"context"
+	"strconv"
 	"strings"
-
-	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 )
 
 const hostDefaultCgroupFsPath = "/sys/fs/cgroup/"
 
 func replaceCgroupFsPathForDaemonSetPod(mountPointPath, actualCGRoot string) string {
 	if len(actualCGRoot) == 0 {
-		actualCGRoot = spec.DefaultCGroupPath
+		actualCGRoot = withTrailingSlash(DetectCGroupRoot(context.Background()).Path)
 	}
 	return strings.Replace(mountPointPath, hostDefaultCgroupFsPath, actualCGRoot, 1)
 }
+
+// withTrailingSlash makes the root replace the whole directory name in the mount point paths
+func withTrailingSlash(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return path
+	}
+	return path + "/"
+}
+
+// limitValue returns the value of the limit files, the limit <= 0 is written as unlimited
+func limitValue(limit int64, unlimited string) string {
+	if limit <= 0 {
+		return unlimited
+	}
+	return strconv.FormatInt(limit, 10)
+}
@@ -3,7 +3,6 @@
 package cgroups
 
 import (
-	"bufio"
 	"context"
 	"os"
 	"path/filepath"
@@ -31,67 +30,139 @@ const (
 	CGroupV2
 	// CGroupUnknown represents unknown cgroup version
 	CGroupUnknown
+	// CGroupHybrid represents the cgroup v1 hierarchies with an extra cgroup v2 one, which is mounted by
+	// systemd in the hybrid mode at /sys/fs/cgroup/unified
+	CGroupHybrid
 )
 
-// DetectCGroupVersion detects the cgroup version by checking the mount points
-func DetectCGroupVersion(ctx context.Context, cgroupRoot string) CGroupVersion {
-	if cgroupRoot == "" {
-		cgroupRoot = "/sys/fs/cgroup"
-	}
+// CGroupHierarchy is the layout of the cgroup hierarchies under the cgroup root
+type CGroupHierarchy struct {
+	Root    string
+	Version CGroupVersion
+	// UnifiedMount is the mount point of the cgroup v2 hierarchy, it's the root on the pure v2 system,
+	// and a directory under the root such as /sys/fs/cgroup/unified on the hybrid one
+	UnifiedMount string
+	// V1Controllers are the controllers mounted as the cgroup v1 hierarchies under the root
+	V1Controllers map[string]bool
+	// V2Controllers are the controllers enabled in the unified hierarchy, which is a stub without any
+	// controller on most hybrid systems
+	V2Controllers map[string]bool
+}
 
-	// Check if cgroup v2 unified mount exists
-	unifiedMount := filepath.Join(cgroupRoot, "cgroup.controllers")
-	if _, err := os.Stat(unifiedMount); err == nil {
-		log.Infof(ctx, "detected cgroup v2 unified mount at %s", cgroupRoot)
+// ControllerVersion returns the version of the hierarchy which hosts the controller, the controller of
+// the hybrid system is on the v1 hierarchy unless it's enabled in the unified one
+func (h *CGroupHierarchy) ControllerVersion(controller string) CGroupVersion {
+	if h.Version != CGroupHybrid {
+		return h.Version
+	}
+	if h.V2Controllers[controller] {
 		return CGroupV2
 	}
+	return CGroupV1
+}
+
+// DetectCGroupHierarchy detects the cgroup hierarchies under the cgroup root by the mount points in the
+// self/mountinfo of the proc filesystem of the FSRoot of the context, the cgroup root of the FSRoot or the
+// detected one is used if the cgroupRoot is empty. The hierarchy is detected once for each root
+// and cached, see Invalidate. The returned hierarchy is shared, so it must not be modified.
+func DetectCGroupHierarchy(ctx context.Context, cgroupRoot string) *CGroupHierarchy {
+	return cachedCGroupHierarchy(ctx, ResolveCGroupRoot(ctx, cgroupRoot))
+}
 
-	// Check mountinfo for cgroup v2
-	mountInfoPath := "/proc/self/mountinfo"
-	if file, err := os.Open(mountInfoPath); err == nil {
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			fields := strings.Fields(line)
-			if len(fields) >= 7 {
-				mountPoint := fields[4]
-				fstype := fields[6]
-				// Check if it's a cgroup v2 mount
-				if fstype == CGroupV2FS && strings.HasPrefix(mountPoint, cgroupRoot) {
-					log.Infof(ctx, "detected cgroup v2 mount at %s", mountPoint)
-					return CGroupV2
+func detectCGroupHierarchy(ctx context.Context, cgroupRoot, mountInfoPath string) *CGroupHierarchy {
+	h := &CGroupHierarchy{
+		Root:          cgroupRoot,
+		V1Controllers: make(map[string]bool),
+		V2Controllers: make(map[string]bool),
+	}
+	mountPoints, err := cachedMountPoints(mountInfoPath)
+	if err != nil {
+		log.Warnf(ctx, "read the cgroup mounts in %s failed, %v", mountInfoPath, err)
+	}
+	for _, mp := range mountPoints {
+		if !isUnderPath(mp.MountPoint, cgroupRoot) {
+			continue
+		}
+		switch mp.FSType {
+		case CGroupV2FS:
+			// the mount of the root itself wins over the one of a nested cgroup
+			if h.UnifiedMount == "" || filepath.Clean(mp.MountPoint) == filepath.Clean(cgroupRoot) {
+				h.UnifiedMount = mp.MountPoint
+			}
+		case CGroupV1FS:
+			for _, opt := range mp.SuperOptions {
+				if _v1Controllers[opt] {
+					h.V1Controllers[opt] = true
 				}
 			}
 		}
 	}
 
-	// Check for cgroup v1 mounts
-	if file, err := os.Open(mountInfoPath); err == nil {
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			fields := strings.Fields(line)
-			if len(fields) >= 7 {
-				fstype := fields[6]
-				if fstype == CGroupV1FS {
-					log.Infof(ctx, "detected cgroup v1 mount")
-					return CGroupV1
-				}
+	// the root which isn't visible in the mountinfo, such as the one under /proc/1/root, is checked by its files
+	if h.UnifiedMount == "" {
+		for _, dir := range []string{cgroupRoot, filepath.Join(cgroupRoot, "unified")} {
+			if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err == nil {
+				h.UnifiedMount = dir
+				break
+			}
+		}
+	}
+	if len(h.V1Controllers) == 0 && filepath.Clean(h.UnifiedMount) != filepath.Clean(cgroupRoot) {
+		for controller := range _v1Controllers {
+			if info, err := os.Stat(filepath.Join(cgroupRoot, controller)); err == nil && info.IsDir() {
+				h.V1Controllers[controller] = true
+			}
+		}
+	}
+	if h.UnifiedMount != "" {
+		if content, err := os.ReadFile(filepath.Join(h.UnifiedMount, "cgroup.controllers")); err == nil {
+			for _, controller := range strings.Fields(string(content)) {
+				h.V2Controllers[controller] = true
 			}
 		}
 	}
 
-	log.Warnf(ctx, "unable to detect cgroup version, defaulting to v1")
-	return CGroupV1
+	switch {
+	case h.UnifiedMount != "" && len(h.V1Controllers) > 0:
+		h.Version = CGroupHybrid
+		log.Infof(ctx, "detected cgroup hybrid hierarchies at %s, the unified mount is %s", cgroupRoot, h.UnifiedMount)
+	case h.UnifiedMount != "":
+		h.Version = CGroupV2
+		log.Infof(ctx, "detected cgroup v2 mount at %s", h.UnifiedMount)
+	case len(h.V1Controllers) > 0:
+		h.Version = CGroupV1
+		log.Infof(ctx, "detected cgroup v1 mount at %s", cgroupRoot)
+	default:
+		h.Version = CGroupV1
+		log.Warnf(ctx, "unable to detect cgroup version, defaulting to v1")
+	}
+	return h
 }
 
-// IsCGroupV2 checks if the system is using cgroup v2
+// isUnderPath returns true if the path is the dir or under it
+func isUnderPath(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// DetectCGroupVersion detects the cgroup version by checking the mount points, the detected cgroup root is
+// used if the cgroupRoot is empty. The hybrid system is CGroupHybrid, see DetectCGroupHierarchy for the
+// hierarchy which hosts a controller.
+func DetectCGroupVersion(ctx context.Context, cgroupRoot string) CGroupVersion {
+	return DetectCGroupHierarchy(ctx, cgroupRoot).Version
+}
+
+// IsCGroupV2 checks if the system is using cgroup v2 only
 func IsCGroupV2(ctx context.Context, cgroupRoot string) bool {
 	return DetectCGroupVersion(ctx, cgroupRoot) == CGroupV2
 }
 
+// IsControllerV2 checks if the controller is hosted by the cgroup v2 hierarchy, which is true for all the
+// controllers on the pure v2 system and for the ones enabled in the unified hierarchy on the hybrid system
+func IsControllerV2(ctx context.Context, cgroupRoot, controller string) bool {
+	return DetectCGroupHierarchy(ctx, cgroupRoot).ControllerVersion(controller) == CGroupV2
+}
+
 // CGroupV2Control represents a cgroup v2 control group
 type CGroupV2Control struct {
 	path string
@@ -4,6 +4,9 @@ package cgroups
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -12,14 +15,14 @@ func TestDetectCGroupVersion(t *testing.T) {
 
 	// 测试默认路径
 	version := DetectCGroupVersion(ctx, "")
-	if version != CGroupV1 && version != CGroupV2 {
-		t.Errorf("Expected CGroupV1 or CGroupV2, got %v", version)
+	if version != CGroupV1 && version != CGroupV2 && version != CGroupHybrid {
+		t.Errorf("Expected CGroupV1, CGroupV2 or CGroupHybrid, got %v", version)
 	}
 
 	// 测试指定路径
 	version = DetectCGroupVersion(ctx, "/sys/fs/cgroup")
-	if version != CGroupV1 && version != CGroupV2 {
-		t.Errorf("Expected CGroupV1 or CGroupV2, got %v", version)
+	if version != CGroupV1 && version != CGroupV2 && version != CGroupHybrid {
+		t.Errorf("Expected CGroupV1, CGroupV2 or CGroupHybrid, got %v", version)
 	}
 }
 
@@ -35,3 +38,182 @@ func TestIsCGroupV2(t *testing.T) {
 		t.Errorf("IsCGroupV2() = %v, expected %v", isV2, expected)
 	}
 }
+
+// cgroupFixture writes the mountinfo of the sample system whose cgroup root is a temp directory, the
+// lines are formatted with the root, and the cgroup.controllers of the unified mount is created
+func cgroupFixture(t *testing.T, unified, controllers string, lines ...string) (string, string) {
+	root := t.TempDir()
+	for i := range lines {
+		lines[i] = strings.ReplaceAll(lines[i], "ROOT", root)
+	}
+	if unified != "" {
+		dir := filepath.Join(root, unified)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte(controllers+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, writeMountInfo(t, lines...)
+}
+
+func Test_detectCGroupHierarchy(t *testing.T) {
+	const (
+		proc    = "22 1 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw"
+		tmpfs   = "25 24 0:22 / ROOT ro,nosuid,nodev,noexec shared:3 - tmpfs tmpfs ro,mode=755"
+		v2      = "30 23 0:26 / ROOT rw,nosuid,nodev,noexec,relatime shared:4 - cgroup2 cgroup2 rw,nsdelegate"
+		unified = "27 25 0:24 / ROOT/unified rw,nosuid,nodev,noexec,relatime shared:6 - cgroup2 cgroup2 rw,nsdelegate"
+		v1Name  = "26 25 0:23 / ROOT/systemd rw,nosuid,nodev,noexec,relatime shared:5 - cgroup cgroup rw,xattr,name=systemd"
+		v1CPU   = "34 25 0:29 / ROOT/cpu,cpuacct rw,nosuid,nodev,noexec,relatime shared:9 - cgroup cgroup rw,cpu,cpuacct"
+		v1Mem   = "35 25 0:30 / ROOT/memory rw,nosuid,nodev,noexec,relatime shared:10 - cgroup cgroup rw,memory"
+		v1Pids  = "36 25 0:31 / ROOT/pids rw,nosuid,nodev,noexec,relatime shared:11 - cgroup cgroup rw,pids"
+	)
+	tests := []struct {
+		name        string
+		unified     string
+		controllers string
+		lines       []string
+		version     CGroupVersion
+		mount       string
+		cpu         CGroupVersion
+		memory      CGroupVersion
+	}{
+		{
+			name:    "pure v1",
+			lines:   []string{proc, tmpfs, v1Name, v1CPU, v1Mem, v1Pids},
+			version: CGroupV1,
+			cpu:     CGroupV1,
+			memory:  CGroupV1,
+		},
+		{
+			name:        "pure v2",
+			unified:     ".",
+			controllers: "cpuset cpu io memory pids",
+			lines:       []string{proc, v2},
+			version:     CGroupV2,
+			mount:       ".",
+			cpu:         CGroupV2,
+			memory:      CGroupV2,
+		},
+		{
+			name:    "hybrid with the stub unified hierarchy",
+			unified: "unified",
+			lines:   []string{proc, tmpfs, v1Name, unified, v1CPU, v1Mem, v1Pids},
+			version: CGroupHybrid,
+			mount:   "unified",
+			cpu:     CGroupV1,
+			memory:  CGroupV1,
+		},
+		{
+			name:        "hybrid with the cpu controller on the unified hierarchy",
+			unified:     "unified",
+			controllers: "cpu",
+			lines:       []string{proc, tmpfs, v1Name, unified, v1Mem, v1Pids},
+			version:     CGroupHybrid,
+			mount:       "unified",
+			cpu:         CGroupV2,
+			memory:      CGroupV1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, mountInfo := cgroupFixture(t, tt.unified, tt.controllers, tt.lines...)
+			got := detectCGroupHierarchy(context.Background(), root, mountInfo)
+			mount := ""
+			if tt.mount != "" {
+				mount = filepath.Join(root, tt.mount)
+			}
+			if got.Version != tt.version || filepath.Clean(got.UnifiedMount) != filepath.Clean(mount) && mount != "" ||
+				mount == "" && got.UnifiedMount != "" {
+				t.Errorf("detectCGroupHierarchy() = %+v, want version %v, unified mount %q", got, tt.version, mount)
+			}
+			if cpu := got.ControllerVersion("cpu"); cpu != tt.cpu {
+				t.Errorf("ControllerVersion(cpu) = %v, want %v", cpu, tt.cpu)
+			}
+			if memory := got.ControllerVersion("memory"); memory != tt.memory {
+				t.Errorf("ControllerVersion(memory) = %v, want %v", memory, tt.memory)
+			}
+		})
+	}
+}
+
+// writeCGroupFile writes the /proc/<pid>/cgroup of the hybrid system whose v2 cgroup is the path
+func writeCGroupFile(t *testing.T, dir, cgroupPath string) string {
+	file := filepath.Join(dir, "cgroup")
+	content := "12:memory:/user.slice\n1:name=systemd:/user.slice\n0::" + cgroupPath + "\n"
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func mkdirCGroup(t *testing.T, mount, cgroupPath string) string {
+	dir := filepath.Join(mount, cgroupPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func Test_findCGroupV2Path(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	// the local cgroupfs of the container only has its own cgroup, the host one is bind-mounted at /host-sys
+	local := filepath.Join(tmp, "sys/fs/cgroup")
+	host := filepath.Join(tmp, "host-sys/fs/cgroup")
+	hostProc := filepath.Join(tmp, "host/proc")
+	mkdirCGroup(t, local, "/")
+
+	t.Run("unified mount of the hybrid system", func(t *testing.T) {
+		unified := filepath.Join(tmp, "hybrid/unified")
+		want := mkdirCGroup(t, unified, "/user.slice/session-1.scope")
+		cgroupFile := writeCGroupFile(t, filepath.Join(tmp, "proc/1"), "/user.slice/session-1.scope")
+		if got, err := findCGroupV2Path(ctx, "1", []string{cgroupFile}, []string{unified}); err != nil || got != want {
+			t.Errorf("findCGroupV2Path() = %s, %v, want %s", got, err, want)
+		}
+	})
+
+	t.Run("host cgroupfs at another mount point", func(t *testing.T) {
+		want := mkdirCGroup(t, host, "/kubepods/pod1/container1")
+		cgroupFile := writeCGroupFile(t, filepath.Join(tmp, "proc/2"), "/kubepods/pod1/container1")
+		if got, err := findCGroupV2Path(ctx, "2", []string{cgroupFile}, []string{local, host}); err != nil || got != want {
+			t.Errorf("findCGroupV2Path() = %s, %v, want %s", got, err, want)
+		}
+	})
+
+	t.Run("host pid only visible in the host proc", func(t *testing.T) {
+		want := mkdirCGroup(t, host, "/system.slice/app.service")
+		cgroupFile := writeCGroupFile(t, filepath.Join(hostProc, "3"), "/system.slice/app.service")
+		files := []string{filepath.Join(tmp, "proc/3/cgroup"), cgroupFile}
+		if got, err := findCGroupV2Path(ctx, "3", files, []string{local, host}); err != nil || got != want {
+			t.Errorf("findCGroupV2Path() = %s, %v, want %s", got, err, want)
+		}
+	})
+
+	t.Run("cgroup out of the cgroup namespace", func(t *testing.T) {
+		// chaosblade runs in /kubepods/burstable/pod1/container1, the target is in pod2
+		want := mkdirCGroup(t, host, "/kubepods/burstable/pod2/container2")
+		cgroupFile := writeCGroupFile(t, filepath.Join(tmp, "proc/4"), "/../../pod2/container2")
+		if got, err := findCGroupV2Path(ctx, "4", []string{cgroupFile}, []string{local, host}); err != nil || got != want {
+			t.Errorf("findCGroupV2Path() = %s, %v, want %s", got, err, want)
+		}
+	})
+
+	t.Run("no existing path", func(t *testing.T) {
+		cgroupFile := writeCGroupFile(t, filepath.Join(tmp, "proc/5"), "/kubepods/pod5/container5")
+		got, err := findCGroupV2Path(ctx, "5", []string{cgroupFile}, []string{local, host})
+		if err == nil || got != "" || !strings.Contains(err.Error(), filepath.Join(host, "kubepods/pod5/container5")) {
+			t.Errorf("findCGroupV2Path() = %s, %v, want the error of the tried paths", got, err)
+		}
+	})
+
+	t.Run("unreadable cgroup file", func(t *testing.T) {
+		if got, err := findCGroupV2Path(ctx, "6", []string{filepath.Join(tmp, "proc/6/cgroup")}, []string{local}); err == nil || got != "" {
+			t.Errorf("findCGroupV2Path() = %s, %v, want the error", got, err)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+//go:build !linux
+
+package cgroups
+
+import "context"
+
+const (
+	// CGroupV1FS is the cgroup v1 filesystem type
+	CGroupV1FS = "cgroup"
+	// CGroupV2FS is the cgroup v2 filesystem type
+	CGroupV2FS = "cgroup2"
+	// CGroupV2UnifiedMount is the unified mount point for cgroup v2
+	CGroupV2UnifiedMount = "/sys/fs/cgroup"
+)
+
+// CGroupVersion represents the cgroup version
+type CGroupVersion int
+
+const (
+	// CGroupV1 represents cgroup v1
+	CGroupV1 CGroupVersion = iota
+	// CGroupV2 represents cgroup v2
+	CGroupV2
+	// CGroupUnknown represents unknown cgroup version
+	CGroupUnknown
+	// CGroupHybrid represents the cgroup v1 hierarchies with an extra cgroup v2 one
+	CGroupHybrid
+)
+
+// DetectCGroupVersion detects the cgroup version by checking the mount points
+// cgroups are only available on Linux, so we return CGroupUnknown
+func DetectCGroupVersion(ctx context.Context, cgroupRoot string) CGroupVersion {
+	return CGroupUnknown
+}
+
+// IsCGroupV2 checks if the system is using cgroup v2
+// cgroups are only available on Linux, so we return false
+func IsCGroupV2(ctx context.Context, cgroupRoot string) bool {
+	return false
+}
+
+// CGroupHierarchy is the layout of the cgroup hierarchies under the cgroup root
+type CGroupHierarchy struct {
+	Root          string
+	Version       CGroupVersion
+	UnifiedMount  string
+	V1Controllers map[string]bool
+	V2Controllers map[string]bool
+}
+
+// ControllerVersion returns the version of the hierarchy which hosts the controller
+func (h *CGroupHierarchy) ControllerVersion(controller string) CGroupVersion {
+	return h.Version
+}
+
+// DetectCGroupHierarchy detects the cgroup hierarchies under the cgroup root
+// cgroups are only available on Linux, so we return CGroupUnknown
+func DetectCGroupHierarchy(ctx context.Context, cgroupRoot string) *CGroupHierarchy {
+	return &CGroupHierarchy{Root: cgroupRoot, Version: CGroupUnknown}
+}
+
+// IsControllerV2 checks if the controller is hosted by the cgroup v2 hierarchy
+// cgroups are only available on Linux, so we return false
+func IsControllerV2(ctx context.Context, cgroupRoot, controller string) bool {
+	return false
+}
+
+// CGroupV2Control represents a cgroup v2 control group
+type CGroupV2Control struct {
+	path string
+}
+
+// NewCGroupV2Control creates a new CGroupV2Control instance
+func NewCGroupV2Control(path string) *CGroupV2Control {
+	return &CGroupV2Control{path: path}
+}
+
+// Path returns the path of the CGroupV2Control
+func (cg *CGroupV2Control) Path() string {
+	return cg.path
+}
+
+// Invalidate drops the cached cgroup hierarchies
+// cgroups are only available on Linux, so nothing is cached
+func Invalidate() {}
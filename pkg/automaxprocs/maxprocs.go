@@ -27,12 +27,14 @@ func GetCPUCntByPidForCgroups1(ctx context.Context, actualCGRoot, pid string) (i
 
 	switch status {
 	case iruntime.CPUQuotaUndefined:
-		log.Warnf(ctx, "maxprocs: Leaving NumCPU=%v: CPU quota undefined", numCPU)
+		log.Warnf(ctx, "maxprocs: Leaving NumCPU=%v: CPU quota and cpuset undefined", numCPU)
 		return numCPU, nil
 	case iruntime.CPUQuotaMinUsed:
 		log.Warnf(ctx, "CPU quota below minimum: %v", cnt)
 	case iruntime.CPUQuotaUsed:
 		log.Infof(ctx, "get numCPU count by pid %s, cgroups1 cpu quota: %d, numCPU: %v", pid, cnt, numCPU)
+	case iruntime.CPUSetUsed:
+		log.Infof(ctx, "get numCPU count by pid %s, cgroups1 cpuset: %d, numCPU: %v", pid, cnt, numCPU)
 	}
 
 	return cnt, nil
@@ -55,12 +57,14 @@ func GetCPUCntByPidForCgroups2(ctx context.Context, actualCGRoot, pid string) (i
 
 	switch status {
 	case iruntime.CPUQuotaUndefined:
-		log.Warnf(ctx, "maxprocs: Leaving NumCPU=%v: CPU quota undefined", numCPU)
+		log.Warnf(ctx, "maxprocs: Leaving NumCPU=%v: CPU quota and cpuset undefined", numCPU)
 		return numCPU, nil
 	case iruntime.CPUQuotaMinUsed:
 		log.Warnf(ctx, "CPU quota below minimum: %v", cnt)
 	case iruntime.CPUQuotaUsed:
 		log.Infof(ctx, "get numCPU count by pid %s, cgroups2 cpu quota: %d, numCPU: %v", pid, cnt, numCPU)
+	case iruntime.CPUSetUsed:
+		log.Infof(ctx, "get numCPU count by pid %s, cgroups2 cpuset: %d, numCPU: %v", pid, cnt, numCPU)
 	}
 
 	return cnt, nil
@@ -68,8 +72,8 @@ func GetCPUCntByPidForCgroups2(ctx context.Context, actualCGRoot, pid string) (i
 
 // GetCPUCntByPid 自动检测 cgroup 版本并获取 CPU 数量
 func GetCPUCntByPid(ctx context.Context, actualCGRoot, pid string) (int, error) {
-	// 检测 cgroup 版本
-	version := cgroups.DetectCGroupVersion(ctx, actualCGRoot)
+	// 检测 cpu 控制器所在的 cgroup 层级
+	version := cgroups.DetectCGroupHierarchy(ctx, actualCGRoot).ControllerVersion("cpu")
 
 	switch version {
 	case cgroups.CGroupV2:
@@ -86,3 +90,23 @@ func GetCPUCntByPid(ctx context.Context, actualCGRoot, pid string) (int, error)
 		return GetCPUCntByPidForCgroups1(ctx, actualCGRoot, pid)
 	}
 }
+
+// Apply sets GOMAXPROCS to the CPU count of the cgroup of the pid, so that the chaos process targeting a container
+// doesn't run as many threads as the CPUs of the host. The previous value is returned with the undo function
+// which restores it, GOMAXPROCS is unchanged if the CPU count fails to be read.
+func Apply(ctx context.Context, actualCGRoot, pid string) (int, func(), error) {
+	prev := runtime.GOMAXPROCS(0)
+	undo := func() {}
+	cnt, err := GetCPUCntByPid(ctx, actualCGRoot, pid)
+	if err != nil {
+		return prev, undo, err
+	}
+	if cnt <= 0 || cnt == prev {
+		return prev, undo, nil
+	}
+	runtime.GOMAXPROCS(cnt)
+	log.Infof(ctx, "maxprocs: Updating GOMAXPROCS=%v by the cgroup of pid %s, previous: %v", cnt, pid, prev)
+	return prev, func() {
+		runtime.GOMAXPROCS(prev)
+	}, nil
+}
@@ -0,0 +1,102 @@
+//go:build linux
+
+package automaxprocs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
+)
+
+// fakeFSRoot writes the files of the fake proc filesystem and cgroup root under the temp directory, whose paths are
+// relative to it, such as proc/42/cgroup and cgroup/app/cpu.max, and ROOT in the contents is the cgroup root
+func fakeFSRoot(t *testing.T, files map[string]string) cgroups.FSRoot {
+	dir := t.TempDir()
+	root := cgroups.FSRoot{ProcPath: filepath.Join(dir, "proc"), CGroupRoot: filepath.Join(dir, "cgroup")}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.ReplaceAll(content, "ROOT", root.CGroupRoot)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestApply(t *testing.T) {
+	const (
+		selfV1Mounts = "34 25 0:29 / ROOT/cpu,cpuacct rw,nosuid - cgroup cgroup rw,cpu,cpuacct\n"
+		pidV1Mounts  = "34 25 0:29 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid - cgroup cgroup rw,cpu,cpuacct\n"
+	)
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "v2",
+			files: map[string]string{
+				"proc/self/mountinfo":       "30 23 0:26 / ROOT rw,nosuid - cgroup2 cgroup2 rw\n",
+				"proc/42/cgroup":            "0::/app\n",
+				"cgroup/cgroup.controllers": "cpu\n",
+				"cgroup/app/cpu.max":        "150000 100000\n",
+			},
+			want: 2,
+		},
+		{
+			name: "v1",
+			files: map[string]string{
+				"proc/self/mountinfo":                      selfV1Mounts,
+				"proc/42/mountinfo":                        pidV1Mounts,
+				"proc/42/cgroup":                           "4:cpu,cpuacct:/app\n",
+				"cgroup/cpu,cpuacct/app/cpu.cfs_quota_us":  "100000\n",
+				"cgroup/cpu,cpuacct/app/cpu.cfs_period_us": "100000\n",
+			},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := runtime.GOMAXPROCS(0)
+			ctx := cgroups.WithFSRoot(context.Background(), fakeFSRoot(t, tt.files))
+			prev, undo, err := Apply(ctx, "", "42")
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			defer undo()
+			if prev != before {
+				t.Errorf("Apply() previous = %d, want %d", prev, before)
+			}
+			if got := runtime.GOMAXPROCS(0); got != tt.want {
+				t.Errorf("GOMAXPROCS = %d, want %d", got, tt.want)
+			}
+			undo()
+			if got := runtime.GOMAXPROCS(0); got != before {
+				t.Errorf("GOMAXPROCS = %d after undo, want %d", got, before)
+			}
+		})
+	}
+}
+
+func TestApply_Failed(t *testing.T) {
+	before := runtime.GOMAXPROCS(0)
+	ctx := cgroups.WithFSRoot(context.Background(), fakeFSRoot(t, map[string]string{
+		"proc/self/mountinfo":       "30 23 0:26 / ROOT rw,nosuid - cgroup2 cgroup2 rw\n",
+		"cgroup/cgroup.controllers": "cpu\n",
+	}))
+	prev, undo, err := Apply(ctx, "", "42")
+	if err == nil {
+		t.Errorf("Apply() succeeds without the cgroup file of the pid")
+	}
+	undo()
+	if prev != before || runtime.GOMAXPROCS(0) != before {
+		t.Errorf("GOMAXPROCS changes from %d to %d, previous %d", before, runtime.GOMAXPROCS(0), prev)
+	}
+}
@@ -4,7 +4,7 @@ package runtime
 
 import (
 	"context"
-	"fmt"
+	"strings"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 
@@ -13,7 +13,7 @@ import (
 
 // GetCPUQuotaToCPUCntByPidFroCgroups1 converts the CPU quota applied to the calling process
 // to a valid CPU cnt value. The quota is converted from float to int using round.
-// If round == nil, DefaultRoundFunc is used.
+// If round == nil, DefaultRoundFunc is used. The proc filesystem and the cgroup root of the FSRoot of the ctx are used.
 // Only support cgroups1!
 func GetCPUQuotaToCPUCntByPidFroCgroups1(
 	ctx context.Context,
@@ -26,24 +26,33 @@ func GetCPUQuotaToCPUCntByPidFroCgroups1(
 		round = DefaultRoundFunc
 	}
 
-	cg, err := cgroups.NewCGroups(fmt.Sprintf("/proc/%s/mountinfo", pid), fmt.Sprintf("/proc/%s/cgroup", pid), actualCGRoot)
+	fsRoot := cgroups.GetFSRoot(ctx)
+	if actualCGRoot == "" && fsRoot.CGroupRoot != "" {
+		actualCGRoot = strings.TrimSuffix(fsRoot.CGroupRoot, "/") + "/"
+	}
+	cg, err := cgroups.NewCGroups(fsRoot.Proc(pid, "mountinfo"), fsRoot.Proc(pid, "cgroup"), actualCGRoot)
 	if err != nil {
 		log.Errorf(ctx, "get cgroup failed for cpu cnt, err: %v, pid: %v", err, pid)
 		return -1, CPUQuotaUndefined, err
 	}
+	return cpuCntOfCGroups(ctx, cg, pid, minValue, round)
+}
+
+// cpuCntOfCGroups returns the smaller one of the CPU quota and the cpuset count of the cgroups
+func cpuCntOfCGroups(ctx context.Context, cg cgroups.CGroups, pid string, minValue int, round func(v float64) int) (int, CPUQuotaStatus, error) {
 	quota, defined, err := cg.CPUQuota()
 	if err != nil {
 		log.Errorf(ctx, "get cgroup cpu quota failed, err: %v, pid: %v", err, pid)
 	}
-	if !defined {
-		log.Warnf(ctx, "cpu quota is not defined, pid: %v", pid)
-		return -1, CPUQuotaUndefined, err
+	cpuset, cpusetDefined, cpusetErr := cg.CPUSetCount()
+	if cpusetErr != nil {
+		log.Warnf(ctx, "get cgroup cpuset failed, err: %v, pid: %v", cpusetErr, pid)
 	}
-
-	maxProcs := round(quota)
-	log.Infof(ctx, "get cpu quota success, pid: %v, quota: %v, round quota: %v", pid, quota, maxProcs)
-	if minValue > 0 && maxProcs < minValue {
-		return minValue, CPUQuotaMinUsed, nil
+	cnt, status := cpuCnt(quota, defined, cpuset, cpusetDefined, minValue, round)
+	if status == CPUQuotaUndefined {
+		log.Warnf(ctx, "cpu quota and cpuset are not defined, pid: %v", pid)
+		return -1, CPUQuotaUndefined, err
 	}
-	return maxProcs, CPUQuotaUsed, nil
+	log.Infof(ctx, "get cpu cnt success, pid: %v, quota: %v, cpuset: %v, cnt: %v, status: %v", pid, quota, cpuset, cnt, status)
+	return cnt, status, nil
 }
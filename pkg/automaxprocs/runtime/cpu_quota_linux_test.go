@@ -0,0 +1,305 @@
+//go:build linux
+
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/pkg/automaxprocs/cgroups"
+)
+
+func writeCGroupFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCPUCntOfCGroups(t *testing.T) {
+	tests := []struct {
+		name       string
+		cpu        map[string]string
+		cpuset     map[string]string
+		wantCnt    int
+		wantStatus CPUQuotaStatus
+	}{
+		{
+			name:       "quota only",
+			cpu:        map[string]string{"cpu.cfs_quota_us": "150000\n", "cpu.cfs_period_us": "100000\n"},
+			wantCnt:    2,
+			wantStatus: CPUQuotaUsed,
+		},
+		{
+			name:       "cpuset only",
+			cpu:        map[string]string{"cpu.cfs_quota_us": "-1\n", "cpu.cfs_period_us": "100000\n"},
+			cpuset:     map[string]string{"cpuset.cpus": "0-2\n"},
+			wantCnt:    3,
+			wantStatus: CPUSetUsed,
+		},
+		{
+			name:       "cpuset smaller than quota",
+			cpu:        map[string]string{"cpu.cfs_quota_us": "400000\n", "cpu.cfs_period_us": "100000\n"},
+			cpuset:     map[string]string{"cpuset.cpus": "0,2\n"},
+			wantCnt:    2,
+			wantStatus: CPUSetUsed,
+		},
+		{
+			name:       "quota smaller than cpuset",
+			cpu:        map[string]string{"cpu.cfs_quota_us": "100000\n", "cpu.cfs_period_us": "100000\n"},
+			cpuset:     map[string]string{"cpuset.cpus": "0-7\n"},
+			wantCnt:    1,
+			wantStatus: CPUQuotaUsed,
+		},
+		{
+			name:       "neither",
+			cpu:        map[string]string{"cpu.cfs_quota_us": "-1\n", "cpu.cfs_period_us": "100000\n"},
+			cpuset:     map[string]string{"cpuset.cpus": "\n"},
+			wantCnt:    -1,
+			wantStatus: CPUQuotaUndefined,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := cgroups.CGroups{"cpu": cgroups.NewCGroup(writeCGroupFiles(t, tt.cpu))}
+			if tt.cpuset != nil {
+				cg["cpuset"] = cgroups.NewCGroup(writeCGroupFiles(t, tt.cpuset))
+			}
+			cnt, status, err := cpuCntOfCGroups(context.Background(), cg, "1", 0, nil)
+			if err != nil {
+				t.Fatalf("cpuCntOfCGroups() error = %v", err)
+			}
+			if cnt != tt.wantCnt || status != tt.wantStatus {
+				t.Errorf("cpuCntOfCGroups() = (%v, %v), want (%v, %v)", cnt, status, tt.wantCnt, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCPUCntOfCGroupV2(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		minValue   int
+		wantCnt    int
+		wantStatus CPUQuotaStatus
+	}{
+		{
+			name:       "quota only",
+			files:      map[string]string{"cpu.max": "250000 100000\n"},
+			wantCnt:    3,
+			wantStatus: CPUQuotaUsed,
+		},
+		{
+			name:       "cpuset only",
+			files:      map[string]string{"cpu.max": "max 100000\n", "cpuset.cpus.effective": "0-3,6\n"},
+			wantCnt:    5,
+			wantStatus: CPUSetUsed,
+		},
+		{
+			name:       "cpuset smaller than quota",
+			files:      map[string]string{"cpu.max": "800000 100000\n", "cpuset.cpus.effective": "4-5\n"},
+			wantCnt:    2,
+			wantStatus: CPUSetUsed,
+		},
+		{
+			name:       "quota smaller than cpuset",
+			files:      map[string]string{"cpu.max": "50000 100000\n", "cpuset.cpus.effective": "0-15\n"},
+			wantCnt:    1,
+			wantStatus: CPUQuotaUsed,
+		},
+		{
+			name:       "cpuset below the min value",
+			files:      map[string]string{"cpu.max": "max 100000\n", "cpuset.cpus.effective": "3\n"},
+			minValue:   2,
+			wantCnt:    2,
+			wantStatus: CPUQuotaMinUsed,
+		},
+		{
+			name:       "neither",
+			files:      map[string]string{"cpu.max": "max 100000\n"},
+			wantCnt:    -1,
+			wantStatus: CPUQuotaUndefined,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := cgroups.NewCGroupV2Impl(writeCGroupFiles(t, tt.files))
+			cnt, status, err := cpuCntOfCGroupV2(context.Background(), cg, "1", tt.minValue, nil)
+			if err != nil {
+				t.Fatalf("cpuCntOfCGroupV2() error = %v", err)
+			}
+			if cnt != tt.wantCnt || status != tt.wantStatus {
+				t.Errorf("cpuCntOfCGroupV2() = (%v, %v), want (%v, %v)", cnt, status, tt.wantCnt, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// fakeFSRoot writes the files of the fake proc filesystem and cgroup root under the temp directory, whose paths
+// are relative to it, such as proc/42/cgroup and cgroup/app/cpu.max
+func fakeFSRoot(t *testing.T, files map[string]string) cgroups.FSRoot {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cgroups.FSRoot{ProcPath: filepath.Join(dir, "proc"), CGroupRoot: filepath.Join(dir, "cgroup")}
+}
+
+func TestGetCPUQuotaToCPUCntByPidForCgroups2_FSRoot(t *testing.T) {
+	const mountInfo = "30 23 0:26 / /sys/fs/cgroup rw,nosuid - cgroup2 cgroup2 rw\n"
+	tests := []struct {
+		name       string
+		files      map[string]string
+		wantCnt    int
+		wantStatus CPUQuotaStatus
+		wantErr    bool
+	}{
+		{
+			name: "quota",
+			files: map[string]string{
+				"proc/42/cgroup":            "0::/app\n",
+				"cgroup/cgroup.controllers": "cpu\n",
+				"cgroup/app/cpu.max":        "300000 100000\n",
+			},
+			wantCnt:    3,
+			wantStatus: CPUQuotaUsed,
+		},
+		{
+			name: "unlimited",
+			files: map[string]string{
+				"proc/42/cgroup":            "0::/app\n",
+				"cgroup/cgroup.controllers": "cpu\n",
+				"cgroup/app/cpu.max":        "max 100000\n",
+			},
+			wantCnt:    -1,
+			wantStatus: CPUQuotaUndefined,
+		},
+		{
+			name: "missing cpu.max",
+			files: map[string]string{
+				"proc/42/cgroup":                   "0::/app\n",
+				"cgroup/cgroup.controllers":        "cpuset\n",
+				"cgroup/app/cpuset.cpus.effective": "0-3\n",
+			},
+			wantCnt:    4,
+			wantStatus: CPUSetUsed,
+		},
+		{
+			name: "malformed cpu.max",
+			files: map[string]string{
+				"proc/42/cgroup":            "0::/app\n",
+				"cgroup/cgroup.controllers": "cpu\n",
+				"cgroup/app/cpu.max":        "abc 100000\n",
+			},
+			wantCnt:    -1,
+			wantStatus: CPUQuotaUndefined,
+			wantErr:    true,
+		},
+		{
+			name: "missing cgroup file",
+			files: map[string]string{
+				"cgroup/cgroup.controllers": "cpu\n",
+			},
+			wantCnt:    -1,
+			wantStatus: CPUQuotaUndefined,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["proc/self/mountinfo"] = mountInfo
+			ctx := cgroups.WithFSRoot(context.Background(), fakeFSRoot(t, tt.files))
+			cnt, status, err := GetCPUQuotaToCPUCntByPidForCgroups2(ctx, "", "42", 1, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetCPUQuotaToCPUCntByPidForCgroups2() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if cnt != tt.wantCnt || status != tt.wantStatus {
+				t.Errorf("GetCPUQuotaToCPUCntByPidForCgroups2() = (%v, %v), want (%v, %v)", cnt, status, tt.wantCnt, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestGetCPUQuotaToCPUCntByPidFroCgroups1_FSRoot(t *testing.T) {
+	const mountInfo = "34 25 0:29 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid - cgroup cgroup rw,cpu,cpuacct\n" +
+		"36 25 0:31 / /sys/fs/cgroup/cpuset rw,nosuid - cgroup cgroup rw,cpuset\n"
+	const cgroup = "5:cpuset:/app\n4:cpu,cpuacct:/app\n"
+	tests := []struct {
+		name       string
+		files      map[string]string
+		wantCnt    int
+		wantStatus CPUQuotaStatus
+		wantErr    bool
+	}{
+		{
+			name: "quota",
+			files: map[string]string{
+				"proc/42/mountinfo":                        mountInfo,
+				"proc/42/cgroup":                           cgroup,
+				"cgroup/cpu,cpuacct/app/cpu.cfs_quota_us":  "200000\n",
+				"cgroup/cpu,cpuacct/app/cpu.cfs_period_us": "100000\n",
+				"cgroup/cpuset/app/cpuset.cpus":            "0-7\n",
+			},
+			wantCnt:    2,
+			wantStatus: CPUQuotaUsed,
+		},
+		{
+			name: "cpuset",
+			files: map[string]string{
+				"proc/42/mountinfo":                        mountInfo,
+				"proc/42/cgroup":                           cgroup,
+				"cgroup/cpu,cpuacct/app/cpu.cfs_quota_us":  "-1\n",
+				"cgroup/cpu,cpuacct/app/cpu.cfs_period_us": "100000\n",
+				"cgroup/cpuset/app/cpuset.cpus":            "2,4\n",
+			},
+			wantCnt:    2,
+			wantStatus: CPUSetUsed,
+		},
+		{
+			name: "malformed quota",
+			files: map[string]string{
+				"proc/42/mountinfo":                        mountInfo,
+				"proc/42/cgroup":                           cgroup,
+				"cgroup/cpu,cpuacct/app/cpu.cfs_quota_us":  "abc\n",
+				"cgroup/cpu,cpuacct/app/cpu.cfs_period_us": "100000\n",
+				"cgroup/cpuset/app/cpuset.cpus":            "\n",
+			},
+			wantCnt:    -1,
+			wantStatus: CPUQuotaUndefined,
+			wantErr:    true,
+		},
+		{
+			name: "missing mountinfo",
+			files: map[string]string{
+				"proc/42/cgroup": cgroup,
+			},
+			wantCnt:    -1,
+			wantStatus: CPUQuotaUndefined,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := cgroups.WithFSRoot(context.Background(), fakeFSRoot(t, tt.files))
+			cnt, status, err := GetCPUQuotaToCPUCntByPidFroCgroups1(ctx, "", "42", 1, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetCPUQuotaToCPUCntByPidFroCgroups1() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if cnt != tt.wantCnt || status != tt.wantStatus {
+				t.Errorf("GetCPUQuotaToCPUCntByPidFroCgroups1() = (%v, %v), want (%v, %v)", cnt, status, tt.wantCnt, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -36,23 +36,24 @@ func GetCPUQuotaToCPUCntByPidForCgroups2(
 		return -1, CPUQuotaUndefined, nil
 	}
 
-	// Create CGroupV2Impl instance and get CPU quota
-	cg := cgroups.NewCGroupV2Impl(cgroupPath)
+	return cpuCntOfCGroupV2(ctx, cgroups.NewCGroupV2Impl(cgroupPath), pid, minValue, round)
+}
+
+// cpuCntOfCGroupV2 returns the smaller one of the CPU quota and the count of cpuset.cpus.effective of the cgroup
+func cpuCntOfCGroupV2(ctx context.Context, cg *cgroups.CGroupV2Impl, pid string, minValue int, round func(v float64) int) (int, CPUQuotaStatus, error) {
 	quota, defined, err := cg.CPUQuota()
 	if err != nil {
 		log.Errorf(ctx, "failed to get cgroup v2 cpu quota for PID %s: %v", pid, err)
-		return -1, CPUQuotaUndefined, err
 	}
-
-	if !defined {
-		log.Warnf(ctx, "cpu quota is not defined for PID %s in cgroup v2", pid)
-		return -1, CPUQuotaUndefined, nil
+	cpuset, cpusetDefined, cpusetErr := cg.CPUSetCount()
+	if cpusetErr != nil {
+		log.Warnf(ctx, "failed to get cgroup v2 cpuset for PID %s: %v", pid, cpusetErr)
 	}
-
-	maxProcs := round(quota)
-	log.Infof(ctx, "get cgroup v2 cpu quota success, pid: %v, quota: %v, round quota: %v", pid, quota, maxProcs)
-	if minValue > 0 && maxProcs < minValue {
-		return minValue, CPUQuotaMinUsed, nil
+	cnt, status := cpuCnt(quota, defined, cpuset, cpusetDefined, minValue, round)
+	if status == CPUQuotaUndefined {
+		log.Warnf(ctx, "cpu quota and cpuset are not defined for PID %s in cgroup v2", pid)
+		return -1, CPUQuotaUndefined, err
 	}
-	return maxProcs, CPUQuotaUsed, nil
+	log.Infof(ctx, "get cgroup v2 cpu cnt success, pid: %v, quota: %v, cpuset: %v, cnt: %v, status: %v", pid, quota, cpuset, cnt, status)
+	return cnt, status, nil
 }
@@ -16,3 +16,16 @@ func GetCPUQuotaToCPUCntByPidFroCgroups1(_ context.Context, _, _ string, _ int,
 func CPUQuotaToGOMAXPROCS(_ int, _ func(v float64) int) (int, CPUQuotaStatus, error) {
 	return -1, CPUQuotaUndefined, nil
 }
+
+// GetCPUQuotaToCPUCntByPidForCgroups2 converts the CPU quota applied to the calling process
+// to a valid CPU cnt value for cgroup v2. cgroups are only available on Linux,
+// so the quota is always undefined.
+func GetCPUQuotaToCPUCntByPidForCgroups2(
+	ctx context.Context,
+	actualCGRoot string,
+	pid string,
+	minValue int,
+	round func(v float64) int,
+) (int, CPUQuotaStatus, error) {
+	return -1, CPUQuotaUndefined, nil
+}
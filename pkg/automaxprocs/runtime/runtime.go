@@ -12,8 +12,32 @@ const (
 	CPUQuotaUsed
 	// CPUQuotaMinUsed is returned when CPU quota is smaller than the min value
 	CPUQuotaMinUsed
+	// CPUSetUsed is returned when the count of the CPUs in the cpuset is used, because it's smaller than the
+	// CPU quota or the quota is undefined
+	CPUSetUsed
 )
 
+// cpuCnt returns the smaller one of the quota-derived cnt and the cpuset count, and the status of the source
+func cpuCnt(quota float64, quotaDefined bool, cpuset int, cpusetDefined bool, minValue int, round func(v float64) int) (int, CPUQuotaStatus) {
+	if round == nil {
+		round = DefaultRoundFunc
+	}
+	cnt, status := -1, CPUQuotaUndefined
+	if quotaDefined {
+		cnt, status = round(quota), CPUQuotaUsed
+	}
+	if cpusetDefined && (cnt < 0 || cpuset < cnt) {
+		cnt, status = cpuset, CPUSetUsed
+	}
+	if status == CPUQuotaUndefined {
+		return -1, CPUQuotaUndefined
+	}
+	if minValue > 0 && cnt < minValue {
+		return minValue, CPUQuotaMinUsed
+	}
+	return cnt, status
+}
+
 // DefaultRoundFunc is the default function to convert CPU quota from float to int. It rounds the value down (floor).
 func DefaultRoundFunc(v float64) int {
 	return int(math.Ceil(v))
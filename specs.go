@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/model"
+)
+
+// runSpec prints the catalog of all the experiment specs, such as chaos_os spec --format yaml
+func runSpec(args []string) *spec.Response {
+	cmd := flag.NewFlagSet(os.Args[0]+" spec", flag.ContinueOnError)
+	cmd.SetOutput(io.Discard)
+	format := cmd.String("format", model.SpecFormatJSON, "The output format, json or yaml")
+	if err := cmd.Parse(args); err != nil {
+		return spec.ReturnFail(spec.ParameterIllegal, fmt.Sprintf("invalid parameter, %v", err))
+	}
+	bytes, err := model.ExportSpecs().Marshal(*format)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "format", *format, err)
+	}
+	if _, err := os.Stdout.Write(bytes); err != nil {
+		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("write the specs failed, %v", err))
+	}
+	return nil
+}
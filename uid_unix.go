@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/model"
+)
+
+// execWithUid replaces the current process with the same command and the generated uid, so that the
+// hanging process can be found and destroyed by the uid. It returns only if the exec fails.
+func execWithUid(uid string) error {
+	bin, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := append(removeFlag(os.Args, model.UidFlag.Name), "--"+model.UidFlag.Name, uid)
+	return syscall.Exec(bin, args, os.Environ())
+}
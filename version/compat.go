@@ -0,0 +1,244 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 构建时注入的兼容性信息（通过 ldflags 设置）
+var (
+	// MinSpecVersion 是依赖的 chaosblade-spec-go 的最低版本，通过构建时注入
+	MinSpecVersion = "1.7.5"
+
+	// BuildFeatures 是构建时启用的特性，以逗号分隔，通过构建时注入
+	BuildFeatures = FeatureCGroupV2
+)
+
+// 已知的特性，未启用的特性在 Requirements 中为 false
+const (
+	FeatureCGroupV2        = "cgroupv2"
+	FeatureNftablesBackend = "nftables-backend"
+)
+
+var knownFeatures = []string{FeatureCGroupV2, FeatureNftablesBackend}
+
+// Requirements 是版本兼容性信息
+type Requirements struct {
+	MinSpecVersion string          `json:"min_spec_version"`
+	Features       map[string]bool `json:"features"`
+}
+
+// GetRequirements 返回构建时注入的兼容性信息
+func GetRequirements() *Requirements {
+	features := make(map[string]bool, len(knownFeatures))
+	for _, feature := range knownFeatures {
+		features[feature] = false
+	}
+	for _, feature := range strings.Split(BuildFeatures, ",") {
+		if feature = strings.TrimSpace(feature); feature != "" {
+			features[feature] = true
+		}
+	}
+	return &Requirements{
+		MinSpecVersion: MinSpecVersion,
+		Features:       features,
+	}
+}
+
+// HasFeature 判断是否启用了特性
+func (r *Requirements) HasFeature(feature string) bool {
+	return r.Features[feature]
+}
+
+// SupportsSpec 判断 chaosblade-spec-go 的版本是否满足最低版本
+func (r *Requirements) SupportsSpec(specVersion string) bool {
+	return Compare(specVersion, r.MinSpecVersion) >= 0
+}
+
+// Version 是解析后的语义化版本，dev 和 latest 是从主干构建的伪版本
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease []string
+	Pseudo     string
+}
+
+// Parse 解析语义化版本，支持 v 前缀、预发布标签（1.8.0-rc1）和 git describe 的后缀（1.7.4-3-gabcdef0-dirty），
+// git describe 的后缀和构建元数据（+xxx）不参与比较
+func Parse(v string) (*Version, error) {
+	s := strings.TrimSpace(v)
+	if s == "dev" || s == "latest" {
+		return &Version{Pseudo: s}, nil
+	}
+	s = strings.TrimPrefix(s, "v")
+	if index := strings.Index(s, "+"); index >= 0 {
+		s = s[:index]
+	}
+	s = trimDescribeSuffix(s)
+	core, preRelease, hasPreRelease := strings.Cut(s, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("`%s`: illegal version", v)
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("`%s`: illegal version", v)
+		}
+		numbers[i] = number
+	}
+	version := &Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}
+	if hasPreRelease {
+		if preRelease == "" {
+			return nil, fmt.Errorf("`%s`: illegal pre-release", v)
+		}
+		version.PreRelease = strings.Split(preRelease, ".")
+		for _, identifier := range version.PreRelease {
+			if identifier == "" {
+				return nil, fmt.Errorf("`%s`: illegal pre-release", v)
+			}
+		}
+	}
+	return version, nil
+}
+
+// trimDescribeSuffix 去除 git describe 追加的 -dirty 和 -<commits>-g<hash>
+func trimDescribeSuffix(s string) string {
+	s = strings.TrimSuffix(s, "-dirty")
+	fields := strings.Split(s, "-")
+	if n := len(fields); n >= 3 && strings.HasPrefix(fields[n-1], "g") && isNumeric(fields[n-2]) {
+		if _, err := strconv.ParseUint(fields[n-1][1:], 16, 64); err == nil {
+			return strings.Join(fields[:n-2], "-")
+		}
+	}
+	return s
+}
+
+// Compare 比较两个版本，a < b 返回 -1，a == b 返回 0，a > b 返回 1。
+// 伪版本 dev 和 latest 高于所有发布版本，无法解析的版本低于所有版本
+func Compare(a, b string) int {
+	va, errA := Parse(a)
+	vb, errB := Parse(b)
+	switch {
+	case errA != nil && errB != nil:
+		return 0
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return va.Compare(vb)
+}
+
+// AtLeast 判断当前版本是否不低于 v
+func AtLeast(v string) bool {
+	return Compare(BladeVersion, v) >= 0
+}
+
+// Compare 比较两个版本，规则同 Compare
+func (v *Version) Compare(o *Version) int {
+	if v.Pseudo != "" || o.Pseudo != "" {
+		return compareBool(v.Pseudo != "", o.Pseudo != "")
+	}
+	for _, pair := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c := compareInt(pair[0], pair[1]); c != 0 {
+			return c
+		}
+	}
+	// 预发布版本低于正式版本
+	if len(v.PreRelease) == 0 || len(o.PreRelease) == 0 {
+		return compareBool(len(v.PreRelease) == 0, len(o.PreRelease) == 0)
+	}
+	for i := 0; i < len(v.PreRelease) && i < len(o.PreRelease); i++ {
+		if c := compareIdentifier(v.PreRelease[i], o.PreRelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(v.PreRelease), len(o.PreRelease))
+}
+
+// compareIdentifier 比较预发布标签，数字低于字母，字母前缀相同时按数字后缀比较，所以 rc2 低于 rc10
+func compareIdentifier(a, b string) int {
+	numericA, numericB := isNumeric(a), isNumeric(b)
+	if numericA && numericB {
+		return compareNumeric(a, b)
+	}
+	if numericA || numericB {
+		return compareBool(numericB, numericA)
+	}
+	prefixA, suffixA := splitNumericSuffix(a)
+	prefixB, suffixB := splitNumericSuffix(b)
+	if prefixA == prefixB && suffixA != "" && suffixB != "" {
+		return compareNumeric(suffixA, suffixB)
+	}
+	return strings.Compare(a, b)
+}
+
+func splitNumericSuffix(s string) (string, string) {
+	index := len(s)
+	for index > 0 && s[index-1] >= '0' && s[index-1] <= '9' {
+		index--
+	}
+	return s[:index], s[index:]
+}
+
+// compareNumeric 比较数字字符串，不受长度限制
+func compareNumeric(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if c := compareInt(len(a), len(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareBool 认为 true 高于 false
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.7.4", "1.7.4", 0},
+		{"v1.7.4", "1.7.4", 0},
+		{"1.7", "1.7.0", 0},
+		{"1.7.4", "1.7.10", -1},
+		{"1.10.0", "1.9.9", 1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.8.0-rc1", "1.8.0", -1},
+		{"1.8.0-rc1", "1.7.9", 1},
+		{"1.8.0-rc1", "1.8.0-rc2", -1},
+		{"1.8.0-rc2", "1.8.0-rc10", -1},
+		{"1.8.0-alpha", "1.8.0-beta", -1},
+		{"1.8.0-alpha", "1.8.0-alpha.1", -1},
+		{"1.8.0-1", "1.8.0-alpha", -1},
+		{"1.8.0-rc.2", "1.8.0-rc.10", -1},
+		{"1.8.0+build.1", "1.8.0", 0},
+		{"v1.7.4-3-g1a2b3c4", "1.7.4", 0},
+		{"v1.7.4-3-g1a2b3c4-dirty", "1.7.5", -1},
+		{"1.8.0-rc1-dirty", "1.8.0-rc1", 0},
+		{"dev", "99.0.0", 1},
+		{"latest", "1.7.4", 1},
+		{"dev", "latest", 0},
+		{"1.7.4", "dev", -1},
+		{"unknown", "1.0.0", -1},
+		{"1.0.0", "1.x", 1},
+		{"unknown", "4e93173-dirty", 0},
+	}
+	for _, tt := range tests {
+		if got := Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Compare(tt.b, tt.a); got != -tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestParseIllegal(t *testing.T) {
+	for _, v := range []string{"", "1", "1.2.3.4", "1.-2.3", "1.2.3-", "1.2.3-rc..1", "a.b.c"} {
+		if _, err := Parse(v); err == nil {
+			t.Errorf("Parse(%q) expected an error", v)
+		}
+	}
+}
+
+func TestAtLeast(t *testing.T) {
+	original := BladeVersion
+	defer func() { BladeVersion = original }()
+
+	BladeVersion = "1.8.0-rc1"
+	if AtLeast("1.8.0") || !AtLeast("1.7.4") || !AtLeast("1.8.0-beta") {
+		t.Errorf("unexpected AtLeast of %s", BladeVersion)
+	}
+	BladeVersion = "dev"
+	if !AtLeast("1.8.0") {
+		t.Errorf("dev should be at least any release")
+	}
+}
+
+func TestRequirements(t *testing.T) {
+	original := BuildFeatures
+	defer func() { BuildFeatures = original }()
+
+	BuildFeatures = "cgroupv2, custom"
+	requirements := GetRequirements()
+	if !requirements.HasFeature(FeatureCGroupV2) || !requirements.HasFeature("custom") {
+		t.Errorf("expected the build features, %v", requirements.Features)
+	}
+	if enabled, ok := requirements.Features[FeatureNftablesBackend]; !ok || enabled {
+		t.Errorf("expected the known feature disabled, %v", requirements.Features)
+	}
+	if !requirements.SupportsSpec("v"+MinSpecVersion) || requirements.SupportsSpec("1.6.0") {
+		t.Errorf("unexpected spec compatibility of %s", requirements.MinSpecVersion)
+	}
+}
@@ -30,6 +30,8 @@ type VersionInfo struct {
 	GoVersion    string    `json:"go_version"`
 	Platform     string    `json:"platform"`
 	Architecture string    `json:"architecture"`
+	// Requirements 是版本兼容性信息
+	Requirements *Requirements `json:"requirements"`
 }
 
 // 构建时注入的变量（通过 ldflags 设置）
@@ -58,6 +60,7 @@ func GetVersionInfo() *VersionInfo {
 		GoVersion:    runtime.Version(),
 		Platform:     runtime.GOOS,
 		Architecture: runtime.GOARCH,
+		Requirements: GetRequirements(),
 	}
 }
 
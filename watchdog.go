@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec"
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/model"
+)
+
+// startWatchdog records the deadline and the flags of the experiment, and starts the detached watchdog process
+// with the uid and the watchdog flag only, so that it can be found by the uid and reaped by chaos_os --gc, and
+// it destroys the experiment by the record instead of the command line.
+func startWatchdog(ctx context.Context, uid string, timeout time.Duration, expModel *spec.ExpModel) error {
+	if err := exec.RecordWatchdog(ctx, uid, expModel.Target, expModel.ActionName, expModel.ActionFlags, time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	cmd := osexec.Command(os.Args[0], spec.Create, expModel.Target, expModel.ActionName,
+		"--"+model.UidFlag.Name, uid, "--"+model.WatchdogFlag.Name, spec.True)
+	cmd.SysProcAttr = watchdogSysProcAttr()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// loadWatchdog reads the record of the experiment which the watchdog destroys
+func loadWatchdog(uid string) (*exec.ExperimentState, error) {
+	state, err := exec.LoadState(uid)
+	if err != nil {
+		return nil, err
+	}
+	if state.Watchdog == nil {
+		return nil, fmt.Errorf("the experiment %s has no timeout", uid)
+	}
+	return state, nil
+}
+
+// runWatchdog sleeps until the deadline of the record and then destroys the experiment by the executor with
+// the recorded flags, the record is destroyed too
+func runWatchdog(ctx context.Context, cl spec.Channel, uid string, state *exec.ExperimentState, executor spec.Executor, expModel *spec.ExpModel) *spec.Response {
+	log.Infof(ctx, "the experiment will be destroyed at %v", state.Watchdog.Deadline)
+	time.Sleep(time.Until(state.Watchdog.Deadline))
+	// the experiment may be destroyed by the others, such as chaos_os --gc
+	if state, err := exec.LoadState(uid); err != nil || state.Destroyed {
+		log.Infof(ctx, "the experiment %s has been destroyed", uid)
+		return spec.ReturnSuccess("the experiment has been destroyed")
+	}
+	ctx = spec.SetDestroyFlag(ctx, uid)
+	response := executor.Exec(uid, ctx, expModel)
+	if !response.Success {
+		log.Errorf(ctx, "destroy the experiment after the timeout failed, %s", response.Err)
+		return response
+	}
+	if recordResponse := exec.DestroyWatchdogRecord(ctx, cl, uid); recordResponse != nil && !recordResponse.Success {
+		log.Errorf(ctx, "destroy the record of the experiment after the timeout failed, %s", recordResponse.Err)
+		return recordResponse
+	}
+	return response
+}
+
+// cancelWatchdog kills the watchdog processes of the experiment, it's invoked when the experiment is destroyed
+func cancelWatchdog(ctx context.Context, uid, target, action string) error {
+	if uid == "" {
+		return nil
+	}
+	processes, err := exec.ListChaosProcesses(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, p := range processes {
+		if p.Uid != uid || p.Target != target || p.Action != action || !isWatchdog(p.Args) {
+			continue
+		}
+		proc, err := os.FindProcess(int(p.Pid))
+		if err != nil {
+			continue
+		}
+		if err := proc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+		log.Infof(ctx, "the watchdog process %d is killed", p.Pid)
+	}
+	return nil
+}
+
+func isWatchdog(args []string) bool {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if name == model.WatchdogFlag.Name && i+1 < len(args) {
+			return args[i+1] == spec.True
+		}
+		if name == model.WatchdogFlag.Name+"="+spec.True {
+			return true
+		}
+	}
+	return false
+}
+
+// removeFlag removes the --name value, --name=value and the single dash forms from the arguments
+func removeFlag(args []string, name string) []string {
+	result := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		flagName := strings.TrimLeft(arg, "-")
+		if flagName != arg {
+			if flagName == name {
+				i++
+				continue
+			}
+			if strings.HasPrefix(flagName, name+"=") {
+				continue
+			}
+		}
+		result = append(result, arg)
+	}
+	return result
+}
@@ -0,0 +1,13 @@
+package main
+
+import "syscall"
+
+const (
+	detachedProcess       = 0x00000008
+	createNewProcessGroup = 0x00000200
+)
+
+// watchdogSysProcAttr starts the watchdog without the console of the caller
+func watchdogSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: detachedProcess | createNewProcessGroup}
+}